@@ -0,0 +1,158 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/memdb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestSplitter(t *testing.T) {
+	var s Splitter
+	var got []Statement
+	feed := func(line string) {
+		for _, stmt := range s.Feed(line) {
+			got = append(got, *stmt)
+		}
+	}
+
+	feed("select 1; select 2")
+	assert.True(t, s.Pending())
+	feed(", 3\\G")
+	feed("select ';' -- the comment;")
+	assert.True(t, s.Pending())
+	feed("from t # the ; too")
+	feed("where a = \"x\\\"; y\" /* ; */;")
+	feed("select `a;")
+	assert.Equal(t, byte('`'), s.Quote())
+	feed("b`\\g")
+	feed("insert /* the")
+	assert.Equal(t, byte('*'), s.Quote())
+	feed("; */ into t values (1);;")
+	assert.False(t, s.Pending())
+
+	want := []Statement{
+		{Query: "select 1"},
+		{Query: "select 2\n, 3", Vertical: true},
+		{Query: "select ';' \nfrom t \nwhere a = \"x\\\"; y\" /* ; */"},
+		{Query: "select `a;\nb`"},
+		{Query: "insert /* the\n; */ into t values (1)"},
+	}
+	assert.Equal(t, want, got)
+
+	feed("select 'x")
+	s.Reset()
+	assert.False(t, s.Pending())
+	assert.Equal(t, byte(0), s.Quote())
+}
+
+func TestPrint(t *testing.T) {
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT64}, {Name: "name", Type: querypb.Type_VARCHAR}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.NewInt64(1), sqltypes.NewVarChar("alice")},
+			{sqltypes.NewInt64(100), sqltypes.NULL},
+		},
+	}
+	var buf bytes.Buffer
+	PrintResult(&buf, qr, false, 10*time.Millisecond)
+	assert.Equal(t, `+-----+-------+
+| id  | name  |
++-----+-------+
+|   1 | alice |
+| 100 | NULL  |
++-----+-------+
+2 rows in set (0.01 sec)
+
+`, buf.String())
+
+	buf.Reset()
+	PrintResult(&buf, qr, true, 0)
+	assert.Equal(t, `*************************** 1. row ***************************
+  id: 1
+name: alice
+*************************** 2. row ***************************
+  id: 100
+name: NULL
+2 rows in set (0.00 sec)
+
+`, buf.String())
+
+	buf.Reset()
+	PrintResult(&buf, &sqltypes.Result{Fields: qr.Fields}, false, 0)
+	assert.Equal(t, "Empty set (0.00 sec)\n\n", buf.String())
+
+	buf.Reset()
+	PrintResult(&buf, &sqltypes.Result{RowsAffected: 1}, false, 0)
+	assert.Equal(t, "Query OK, 1 row affected (0.00 sec)\n\n", buf.String())
+}
+
+func TestClient(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	svr, err := driver.MockMysqlServer(log, memdb.NewHandler(log))
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	var out, errOut bytes.Buffer
+	client := &Client{out: &out, errOut: &errOut}
+	client.connect = func() (driver.Conn, error) {
+		return driver.NewConn("mock", "mock", svr.Addr(), "test", "")
+	}
+	defer func() { client.conn.Close() }()
+
+	dir, err := ioutil.TempDir("", "mysqlstack-cli")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "init.sql")
+	assert.Nil(t, ioutil.WriteFile(script, []byte("create table t (id int, name varchar(8));\ninsert into t values\n(1, 'a'), (2, 'b');\n"), 0644))
+
+	run := func(input string) error {
+		lines := strings.Split(input, "\n")
+		return client.Run(func(string) (string, error) {
+			if len(lines) == 0 {
+				return "", io.EOF
+			}
+			line := lines[0]
+			lines = lines[1:]
+			return line, nil
+		}, 0)
+	}
+
+	assert.Nil(t, run("source "+script+"\nselect name from t\nwhere id = 2\\G\nselect 1"))
+	assert.Equal(t, "", errOut.String())
+	assert.Contains(t, out.String(), "Query OK, 2 rows affected")
+	assert.Contains(t, out.String(), "*************************** 1. row ***************************\nname: b\n")
+	assert.Contains(t, out.String(), "| 1 |")
+
+	// The first error stops the statements unless the force.
+	out.Reset()
+	assert.NotNil(t, run("select * from t1;\nselect 2;"))
+	assert.Equal(t, "ERROR 1146 (42S02): Table 'test.t1' doesn't exist\n", errOut.String())
+	assert.NotContains(t, out.String(), "| 2 |")
+	client.force = true
+	assert.Nil(t, run("select * from t1;\nselect 2;"))
+	assert.Contains(t, out.String(), "| 2 |")
+
+	assert.Equal(t, errQuit, run("quit"))
+}
@@ -0,0 +1,255 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// mysqlstack-cli is the command line client of the driver, the statements are terminated by the ; or the \G
+// of the vertical output and may span lines, the source (or the \.) executes the statements of a file.
+//
+//	mysqlstack-cli -h 127.0.0.1 -P 3306 -u root -p secret -D test
+//	mysqlstack-cli -u root -e "select 1; show databases"
+//	mysqlstack-cli -u root < dump.sql
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// maxSourceDepth is the depth of the nested sources.
+const maxSourceDepth = 16
+
+// errQuit is returned by the commands of the quit.
+var errQuit = errors.New("cli.quit")
+
+const help = `List of the commands:
+?         (\?) Synonym for 'help'.
+exit      (\q) Exit mysqlstack-cli. Same as quit.
+go        (\g) Send command to mysql server.
+ego       (\G) Send command to mysql server, display result vertically.
+help      (\h) Display this help.
+quit      (\q) Quit mysqlstack-cli.
+source    (\.) Execute an SQL script file. Takes a file name as an argument.
+clear     (\c) Clear the current input statement.
+`
+
+// Client is the client of a connection, it executes the statements and prints the results.
+type Client struct {
+	connect func() (driver.Conn, error)
+	conn    driver.Conn
+	out     io.Writer
+	errOut  io.Writer
+
+	// The first error stops the statements unless the force.
+	force bool
+}
+
+// Exec executes the statement and prints the result, the connection is connected again if it's lost.
+func (c *Client) Exec(stmt *Statement) error {
+	if c.conn == nil || c.conn.Closed() {
+		if c.conn != nil {
+			fmt.Fprintln(c.errOut, "No connection. Trying to reconnect...")
+		}
+		conn, err := c.connect()
+		if err != nil {
+			return c.error(err)
+		}
+		c.conn = conn
+	}
+	start := time.Now()
+	qr, err := c.conn.FetchAll(stmt.Query, -1)
+	if err != nil {
+		return c.error(err)
+	}
+	PrintResult(c.out, qr, stmt.Vertical, time.Since(start))
+	return nil
+}
+
+// error prints the error in the format of the mysql client and returns it unless the force.
+func (c *Client) error(err error) error {
+	if serr, ok := err.(*sqldb.SQLError); ok {
+		fmt.Fprintf(c.errOut, "ERROR %d (%s): %s\n", serr.Num, serr.State, serr.Message)
+	} else {
+		fmt.Fprintf(c.errOut, "ERROR: %v\n", err)
+	}
+	if c.force {
+		return nil
+	}
+	return err
+}
+
+// command runs the client command of the line, handled is false if the line isn't one.
+func (c *Client) command(line string, depth int) (handled bool, err error) {
+	line = strings.TrimSpace(line)
+	word, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i > 0 {
+		word, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	arg = strings.TrimSpace(strings.TrimSuffix(arg, ";"))
+	switch strings.ToLower(strings.TrimSuffix(word, ";")) {
+	case "quit", "exit", `\q`:
+		return true, errQuit
+	case "help", `\h`, "?", `\?`:
+		fmt.Fprint(c.out, help)
+		return true, nil
+	case "source", `\.`:
+		if arg == "" {
+			return true, c.error(fmt.Errorf("usage: source <file>"))
+		}
+		return true, c.Source(arg, depth+1)
+	}
+	return false, nil
+}
+
+// Run runs the lines of the next, until the io.EOF or the quit.
+// The prompt of the next is the one of the first line or the continuation one of the statement.
+func (c *Client) Run(next func(prompt string) (string, error), depth int) error {
+	var splitter Splitter
+	for {
+		prompt := "mysql> "
+		if q := splitter.Quote(); q != 0 && q != '*' {
+			prompt = fmt.Sprintf("    %c> ", q)
+		} else if q == '*' {
+			prompt = "   /*> "
+		} else if splitter.Pending() {
+			prompt = "    -> "
+		}
+		line, err := next(prompt)
+		switch err {
+		case nil:
+		case ErrInterrupt:
+			splitter.Reset()
+			continue
+		case io.EOF:
+			// The statement without the terminator at the end is executed as the mysql client does.
+			if splitter.Pending() {
+				return c.run(splitter.Feed(";"))
+			}
+			return nil
+		default:
+			return err
+		}
+
+		if !splitter.Pending() {
+			handled, err := c.command(line, depth)
+			if err != nil {
+				return err
+			}
+			if handled {
+				continue
+			}
+		}
+		if trimmed := strings.TrimSpace(line); splitter.Quote() == 0 && strings.HasSuffix(trimmed, `\c`) {
+			splitter.Reset()
+			continue
+		}
+		if err := c.run(splitter.Feed(line)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) run(stmts []*Statement) error {
+	for _, stmt := range stmts {
+		if err := c.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Source executes the statements of the file.
+func (c *Client) Source(path string, depth int) error {
+	if depth > maxSourceDepth {
+		return c.error(fmt.Errorf("source.file.nested.too.deep:%s", path))
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return c.error(fmt.Errorf("failed to open file '%s', error: %v", path, err))
+	}
+	defer f.Close()
+	editor := NewEditor(f, c.out)
+	return c.Run(editor.readPlain, depth)
+}
+
+func main() {
+	host := flag.String("h", "127.0.0.1", "the host of the server")
+	port := flag.Int("P", 3306, "the port of the server")
+	user := flag.String("u", "root", "the user")
+	password := flag.String("p", "", "the password")
+	database := flag.String("D", "", "the database")
+	charset := flag.String("charset", "", "the charset of the connection")
+	dsn := flag.String("dsn", "", "the data source name, it takes the place of the other connection flags")
+	execute := flag.String("e", "", "the statements to execute, the client quits after them")
+	force := flag.Bool("f", false, "continue even if an error occurs")
+	flag.Parse()
+
+	client := &Client{out: os.Stdout, errOut: os.Stderr, force: *force}
+	client.connect = func() (driver.Conn, error) {
+		if *dsn != "" {
+			return driver.NewConnWithDSN(*dsn)
+		}
+		return driver.NewConn(*user, *password, net.JoinHostPort(*host, strconv.Itoa(*port)), *database, *charset)
+	}
+	conn, err := client.connect()
+	if err != nil {
+		client.error(err)
+		os.Exit(1)
+	}
+	client.conn = conn
+	defer func() { client.conn.Close() }()
+
+	interactive := false
+	if *execute != "" {
+		lines := strings.Split(*execute, "\n")
+		err = client.Run(func(string) (string, error) {
+			if len(lines) == 0 {
+				return "", io.EOF
+			}
+			line := lines[0]
+			lines = lines[1:]
+			return line, nil
+		}, 0)
+	} else {
+		editor := NewEditor(os.Stdin, os.Stdout)
+		if interactive = editor.Interactive(); interactive {
+			history := ""
+			if home, err := os.UserHomeDir(); err == nil {
+				history = filepath.Join(home, ".mysqlstack_history")
+				editor.LoadHistory(history)
+			}
+			fmt.Printf("Welcome to the mysqlstack-cli. Commands end with ; or \\g.\n")
+			fmt.Printf("Your connection id is %d\n", conn.ConnectionID())
+			fmt.Printf("Server version: %s\n\n", conn.ServerVersion())
+			fmt.Printf("Type 'help;' or '\\h' for help. Type '\\c' to clear the current input statement.\n\n")
+			// The errors are printed, the session goes on.
+			client.force = true
+			if history != "" {
+				defer editor.SaveHistory(history)
+			}
+		}
+		err = client.Run(editor.ReadLine, 0)
+	}
+	if interactive {
+		fmt.Println("Bye")
+	}
+	if err != nil && err != errQuit {
+		client.conn.Close()
+		os.Exit(1)
+	}
+}
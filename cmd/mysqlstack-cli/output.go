@@ -0,0 +1,130 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// cell returns the text of the value, the NULL is NULL.
+func cell(v sqltypes.Value) string {
+	if v.IsNull() {
+		return "NULL"
+	}
+	return v.String()
+}
+
+// PrintTable prints the resultset in the table of the mysql client, the numbers are right aligned.
+func PrintTable(w io.Writer, qr *sqltypes.Result) {
+	widths := make([]int, len(qr.Fields))
+	for i, f := range qr.Fields {
+		widths[i] = utf8.RuneCountInString(f.Name)
+	}
+	for _, row := range qr.Rows {
+		for i, v := range row {
+			if n := utf8.RuneCountInString(cell(v)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+
+	var sep strings.Builder
+	sep.WriteString("+")
+	for _, width := range widths {
+		sep.WriteString(strings.Repeat("-", width+2))
+		sep.WriteString("+")
+	}
+	line := func(texts []string, right func(i int) bool) {
+		var b strings.Builder
+		b.WriteString("|")
+		for i, text := range texts {
+			pad := strings.Repeat(" ", widths[i]-utf8.RuneCountInString(text))
+			if right(i) {
+				fmt.Fprintf(&b, " %s%s |", pad, text)
+			} else {
+				fmt.Fprintf(&b, " %s%s |", text, pad)
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+
+	fmt.Fprintln(w, sep.String())
+	names := make([]string, len(qr.Fields))
+	for i, f := range qr.Fields {
+		names[i] = f.Name
+	}
+	line(names, func(int) bool { return false })
+	fmt.Fprintln(w, sep.String())
+	for _, row := range qr.Rows {
+		texts := make([]string, len(row))
+		for i, v := range row {
+			texts[i] = cell(v)
+		}
+		line(texts, func(i int) bool {
+			t := qr.Fields[i].Type
+			return sqltypes.IsIntegral(t) || sqltypes.IsFloat(t) || t == sqltypes.Decimal
+		})
+	}
+	fmt.Fprintln(w, sep.String())
+}
+
+// PrintVertical prints the resultset a column per line as the \G of the mysql client.
+func PrintVertical(w io.Writer, qr *sqltypes.Result) {
+	width := 0
+	for _, f := range qr.Fields {
+		if n := utf8.RuneCountInString(f.Name); n > width {
+			width = n
+		}
+	}
+	for r, row := range qr.Rows {
+		fmt.Fprintf(w, "%s %d. row %s\n", strings.Repeat("*", 27), r+1, strings.Repeat("*", 27))
+		for i, v := range row {
+			name := qr.Fields[i].Name
+			fmt.Fprintf(w, "%s%s: %s\n", strings.Repeat(" ", width-utf8.RuneCountInString(name)), name, cell(v))
+		}
+	}
+}
+
+// plural returns the n of the noun, the noun is plural unless the n is 1.
+func plural(n uint64, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// PrintResult prints the resultset or the OK of the statement and the summary line.
+func PrintResult(w io.Writer, qr *sqltypes.Result, vertical bool, elapsed time.Duration) {
+	took := fmt.Sprintf("(%.2f sec)", elapsed.Seconds())
+	if len(qr.Fields) == 0 {
+		fmt.Fprintf(w, "Query OK, %s affected %s\n", plural(qr.RowsAffected, "row"), took)
+		if qr.Info != "" {
+			fmt.Fprintln(w, qr.Info)
+		}
+		fmt.Fprintln(w)
+		return
+	}
+	if len(qr.Rows) == 0 {
+		fmt.Fprintf(w, "Empty set %s\n\n", took)
+		return
+	}
+	if vertical {
+		PrintVertical(w, qr)
+	} else {
+		PrintTable(w, qr)
+	}
+	fmt.Fprintf(w, "%s in set %s\n\n", plural(uint64(len(qr.Rows)), "row"), took)
+}
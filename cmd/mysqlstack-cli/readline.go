@@ -0,0 +1,254 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupt is returned by the ReadLine if the line is dropped by the Ctrl-C.
+var ErrInterrupt = errors.New("cli.readline.interrupted")
+
+// maxHistory is the lines the history keeps.
+const maxHistory = 1000
+
+// Editor reads the lines of the terminal with the emacs keys and the history,
+// it reads the plain lines if the input isn't a terminal. The characters are taken as one column wide.
+type Editor struct {
+	in      *os.File
+	out     io.Writer
+	r       *bufio.Reader
+	history []string
+}
+
+// NewEditor creates the editor of the input and the output.
+func NewEditor(in *os.File, out io.Writer) *Editor {
+	return &Editor{in: in, out: out, r: bufio.NewReader(in)}
+}
+
+// Interactive checks whether the input is a terminal.
+func (e *Editor) Interactive() bool {
+	return isTerminal(e.in.Fd())
+}
+
+// LoadHistory loads the lines of the history file, it's ok if the file doesn't exist.
+func (e *Editor) LoadHistory(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		e.addHistory(line)
+	}
+	return nil
+}
+
+// SaveHistory saves the history to the file.
+func (e *Editor) SaveHistory(path string) error {
+	return ioutil.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+}
+
+func (e *Editor) addHistory(line string) {
+	if strings.TrimSpace(line) == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+	}
+}
+
+// ReadLine reads a line after the prompt, it's io.EOF at the end of the input or the Ctrl-D on the empty line.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if !e.Interactive() {
+		return e.readPlain(prompt)
+	}
+	restore, err := makeRaw(e.in.Fd())
+	if err != nil {
+		return e.readPlain(prompt)
+	}
+	defer restore()
+	return e.edit(prompt)
+}
+
+// readPlain reads the line without the editing, the prompt is written only to the terminal.
+func (e *Editor) readPlain(prompt string) (string, error) {
+	if e.Interactive() {
+		fmt.Fprint(e.out, prompt)
+	}
+	line, err := e.r.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// edit reads the line in the raw mode.
+func (e *Editor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	// The history being browsed, the len(history) is the line being edited.
+	hist, editing := len(e.history), ""
+
+	refresh := func() {
+		fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	browse := func(to int) {
+		if to < 0 || to > len(e.history) {
+			return
+		}
+		if hist == len(e.history) {
+			editing = string(buf)
+		}
+		hist = to
+		if hist == len(e.history) {
+			buf = []rune(editing)
+		} else {
+			buf = []rune(e.history[hist])
+		}
+		pos = len(buf)
+		refresh()
+	}
+
+	fmt.Fprint(e.out, prompt)
+	for {
+		r, _, err := e.r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\r\n")
+			line := string(buf)
+			e.addHistory(line)
+			return line, nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(buf) {
+				buf = append(buf[:pos], buf[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(buf)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(buf) {
+				pos++
+			}
+		case 11: // Ctrl-K
+			buf = buf[:pos]
+		case 21: // Ctrl-U
+			buf, pos = buf[pos:], 0
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			for start > 0 && !unicode.IsSpace(buf[start-1]) {
+				start--
+			}
+			buf, pos = append(buf[:start], buf[pos:]...), start
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			browse(hist - 1)
+			continue
+		case 14: // Ctrl-N
+			browse(hist + 1)
+			continue
+		case 27: // The escape sequences of the arrows, the home, the end and the delete.
+			seq, err := e.escape()
+			if err != nil {
+				return "", err
+			}
+			switch seq {
+			case "[A", "OA":
+				browse(hist - 1)
+				continue
+			case "[B", "OB":
+				browse(hist + 1)
+				continue
+			case "[C", "OC":
+				if pos < len(buf) {
+					pos++
+				}
+			case "[D", "OD":
+				if pos > 0 {
+					pos--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				pos = 0
+			case "[F", "OF", "[4~", "[8~":
+				pos = len(buf)
+			case "[3~":
+				if pos < len(buf) {
+					buf = append(buf[:pos], buf[pos+1:]...)
+				}
+			}
+		default:
+			if r == '\t' || unicode.IsPrint(r) {
+				buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+				pos++
+			}
+		}
+		refresh()
+	}
+}
+
+// escape reads the escape sequence after the ESC like the [A of the up arrow.
+func (e *Editor) escape() (string, error) {
+	c, err := e.r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	seq := []byte{c}
+	if c != '[' && c != 'O' {
+		return string(seq), nil
+	}
+	for {
+		c, err := e.r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		seq = append(seq, c)
+		// The final byte of the sequence is a letter or the ~.
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c == '~' || len(seq) > 8 {
+			return string(seq), nil
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"strings"
+)
+
+// Statement is a statement of the input, Vertical if it's terminated by the \G.
+type Statement struct {
+	Query    string
+	Vertical bool
+}
+
+// Splitter splits the lines of the input into the statements terminated by the ; or the \G,
+// the terminators in the quotes, the backticks and the comments don't count.
+type Splitter struct {
+	buf strings.Builder
+
+	// The quote the buffer ends in, 0 if none, '*' in the /* comment.
+	quote byte
+}
+
+// Pending checks whether there's a statement not terminated yet, the prompt of the next line is the continuation one.
+func (s *Splitter) Pending() bool {
+	return strings.TrimSpace(s.buf.String()) != ""
+}
+
+// Quote returns the quote the statement not terminated is in, 0 if none.
+func (s *Splitter) Quote() byte {
+	return s.quote
+}
+
+// Reset drops the statement not terminated.
+func (s *Splitter) Reset() {
+	s.buf.Reset()
+	s.quote = 0
+}
+
+// Feed feeds a line and returns the statements terminated by it.
+func (s *Splitter) Feed(line string) []*Statement {
+	var stmts []*Statement
+	if s.buf.Len() > 0 {
+		s.buf.WriteByte('\n')
+	}
+	start := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch s.quote {
+		case 0:
+		case '*':
+			if c == '*' && i+1 < len(line) && line[i+1] == '/' {
+				s.quote = 0
+				i++
+			}
+			continue
+		default:
+			if c == '\\' && s.quote != '`' {
+				i++
+			} else if c == s.quote {
+				s.quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			s.quote = c
+		case c == '/' && i+1 < len(line) && line[i+1] == '*':
+			s.quote = '*'
+			i++
+		case c == '#' || (c == '-' && strings.HasPrefix(line[i:], "-- ")) || line[i:] == "--":
+			// The rest of the line is the comment, it's dropped.
+			s.buf.WriteString(line[start:i])
+			start, i = len(line), len(line)
+		case c == ';' || (c == '\\' && i+1 < len(line) && (line[i+1] == 'G' || line[i+1] == 'g')):
+			s.buf.WriteString(line[start:i])
+			if query := strings.TrimSpace(s.buf.String()); query != "" {
+				stmts = append(stmts, &Statement{Query: query, Vertical: c == '\\' && line[i+1] == 'G'})
+			}
+			s.buf.Reset()
+			if c == '\\' {
+				i++
+			}
+			start = i + 1
+		}
+	}
+	if start < len(line) {
+		s.buf.WriteString(line[start:])
+	}
+	return stmts
+}
@@ -0,0 +1,176 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// Duration is the time.Duration of the config, it's the string like "10s" in the JSON.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses the duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("proxy.config.duration[%s].not.string", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("proxy.config.duration[%s].invalid", s)
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalJSON formats the duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// TLSConfig is the TLS of the listener.
+type TLSConfig struct {
+	// Cert and Key are the PEM files of the server certificate.
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+
+	// CA is the PEM file of the CAs verifying the client certificates given, empty doesn't ask them.
+	CA string `json:"ca"`
+
+	// Require rejects the sessions not switched to TLS.
+	Require bool `json:"require"`
+}
+
+// UserConfig is a user of the proxy.
+type UserConfig struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+
+	// BackendUser and BackendPassword are the credentials the sessions of the user connect the backends with,
+	// empty are the ones of the backend DSNs.
+	BackendUser     string `json:"backend_user"`
+	BackendPassword string `json:"backend_password"`
+
+	// Schemas are the databases the user can use, empty allows all.
+	Schemas []string `json:"schemas"`
+}
+
+// LogConfig is the log of the proxy.
+type LogConfig struct {
+	// Level is the DEBUG, the INFO, the WARNING or the ERROR, empty is the INFO.
+	Level string `json:"level"`
+
+	// File is the log file reopened by the SIGHUP, empty is the stdout.
+	File string `json:"file"`
+}
+
+// Config is the config file of the proxy.
+type Config struct {
+	// Listen is the address the clients connect to.
+	Listen string `json:"listen"`
+
+	// Backend is the DSN of the default backend.
+	Backend string `json:"backend"`
+
+	// Schemas are the DSNs of the backends of the schemas, the schemas not listed go to the default backend.
+	Schemas map[string]string `json:"schemas"`
+
+	TLS *TLSConfig `json:"tls"`
+
+	// Users are the users of the proxy, empty accepts every client.
+	Users []*UserConfig `json:"users"`
+
+	Log LogConfig `json:"log"`
+
+	// Metrics is the http address of the /metrics of the Prometheus, empty disables it.
+	Metrics string `json:"metrics"`
+
+	// HandshakeTimeout is the timeout from the greeting to the auth OK, zero is no timeout.
+	HandshakeTimeout Duration `json:"handshake_timeout"`
+
+	// ServerVersion is the version the greeting advertises, empty is the default one.
+	ServerVersion string `json:"server_version"`
+}
+
+// LoadConfig loads and validates the config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("proxy.config[%s].parse.error:%v", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate checks the config.
+func (c *Config) Validate() error {
+	if c.Listen == "" {
+		return fmt.Errorf("proxy.config.listen.can.not.be.empty")
+	}
+	if c.Backend == "" {
+		return fmt.Errorf("proxy.config.backend.can.not.be.empty")
+	}
+	if _, err := driver.ParseDSN(c.Backend); err != nil {
+		return fmt.Errorf("proxy.config.backend.dsn.error:%v", err)
+	}
+	for schema, dsn := range c.Schemas {
+		if _, err := driver.ParseDSN(dsn); err != nil {
+			return fmt.Errorf("proxy.config.schema[%s].dsn.error:%v", schema, err)
+		}
+	}
+	if c.TLS != nil && (c.TLS.Cert == "" || c.TLS.Key == "") {
+		return fmt.Errorf("proxy.config.tls.cert.and.key.required")
+	}
+	users := make(map[string]bool)
+	for _, u := range c.Users {
+		if u.User == "" {
+			return fmt.Errorf("proxy.config.user.can.not.be.empty")
+		}
+		if users[u.User] {
+			return fmt.Errorf("proxy.config.user[%s].duplicate", u.User)
+		}
+		users[u.User] = true
+	}
+	if _, ok := logLevel(c.Log.Level); !ok {
+		return fmt.Errorf("proxy.config.log.level[%s].unknown", c.Log.Level)
+	}
+	if c.HandshakeTimeout.Duration < 0 {
+		return fmt.Errorf("proxy.config.handshake.timeout[%v].negative", c.HandshakeTimeout)
+	}
+	return nil
+}
+
+// logLevel returns the level of the name, empty is the INFO.
+func logLevel(name string) (xlog.LogLevel, bool) {
+	if name == "" {
+		return xlog.INFO, true
+	}
+	for i, v := range xlog.LevelNames {
+		if v != "" && strings.EqualFold(v, name) {
+			return xlog.LogLevel(i), true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,89 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// mysqlstack-proxy is the pass-through proxy of the MySQL protocol, the queries of the clients go to the backends
+// of their schemas. The config file is JSON:
+//
+//	{
+//	  "listen": "127.0.0.1:3307",
+//	  "backend": "root:secret@tcp(127.0.0.1:3306)/",
+//	  "schemas": {"orders": "root:secret@tcp(10.0.0.2:3306)/?tls=skip-verify"},
+//	  "tls": {"cert": "server.pem", "key": "server-key.pem", "ca": "ca.pem", "require": false},
+//	  "users": [{"user": "app", "password": "app", "backend_user": "app_rw", "backend_password": "secret", "schemas": ["orders"]}],
+//	  "log": {"level": "INFO", "file": "/var/log/mysqlstack-proxy.log"},
+//	  "metrics": "127.0.0.1:9104",
+//	  "handshake_timeout": "10s"
+//	}
+//
+//	mysqlstack-proxy -config proxy.json
+//
+// The SIGHUP reopens the log file, the SIGINT and the SIGTERM stop the proxy.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// newLog creates the log of the config.
+func newLog(cfg *LogConfig) (*xlog.Log, error) {
+	level, _ := logLevel(cfg.Level)
+	if cfg.File == "" {
+		return xlog.NewStdLog(xlog.Name("mysqlstack-proxy"), xlog.Level(level)), nil
+	}
+	return xlog.NewFileLog(cfg.File, xlog.Name("mysqlstack-proxy"), xlog.Level(level))
+}
+
+func main() {
+	path := flag.String("config", "", "the config file")
+	flag.Parse()
+	if *path == "" {
+		fmt.Fprintln(os.Stderr, "usage: mysqlstack-proxy -config proxy.json")
+		os.Exit(2)
+	}
+
+	cfg, err := LoadConfig(*path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mysqlstack-proxy: %v\n", err)
+		os.Exit(1)
+	}
+	log, err := newLog(&cfg.Log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "mysqlstack-proxy: %v\n", err)
+		os.Exit(1)
+	}
+	defer log.Close()
+
+	proxy, err := NewProxy(cfg, log)
+	if err != nil {
+		log.Error("proxy.start.error:%+v", err)
+		log.Close()
+		os.Exit(1)
+	}
+	proxy.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			if err := log.Reopen(); err != nil {
+				log.Error("proxy.log.reopen.error:%+v", err)
+			}
+			continue
+		}
+		log.Info("proxy.signal[%v].stopping", sig)
+		break
+	}
+	proxy.Close()
+}
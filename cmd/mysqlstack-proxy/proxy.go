@@ -0,0 +1,186 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// metricsNamespace is the prefix of the metrics.
+const metricsNamespace = "mysqlstack_proxy"
+
+// Proxy is the listener of the clients passing the queries through to the backends of the schemas.
+type Proxy struct {
+	log      *xlog.Log
+	listener *driver.Listener
+	metrics  net.Listener
+	server   *http.Server
+}
+
+// NewProxy creates the proxy of the config, it listens on the addresses but doesn't accept the clients until the Start.
+func NewProxy(cfg *Config, log *xlog.Log) (*Proxy, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	users := make(map[string]driver.ProxyUser)
+	for _, u := range cfg.Users {
+		if u.BackendUser != "" {
+			users[u.User] = driver.ProxyUser{User: u.BackendUser, Password: u.BackendPassword}
+		}
+	}
+	backend, err := proxyHandler(log, cfg.Backend, users)
+	if err != nil {
+		return nil, err
+	}
+	router := driver.NewSchemaRouter(backend)
+	for schema, dsn := range cfg.Schemas {
+		h, err := proxyHandler(log, dsn, users)
+		if err != nil {
+			return nil, err
+		}
+		router.SetHandler(schema, h)
+	}
+
+	lcfg := &driver.ListenerConfig{
+		Address:          cfg.Listen,
+		Log:              log,
+		HandshakeTimeout: cfg.HandshakeTimeout.Duration,
+	}
+	if cfg.ServerVersion != "" {
+		lcfg.Greeting = driver.DefaultGreetingConfig()
+		lcfg.Greeting.ServerVersion = cfg.ServerVersion
+	}
+	if cfg.TLS != nil {
+		if lcfg.TLS, err = serverTLS(cfg.TLS); err != nil {
+			return nil, err
+		}
+		lcfg.RequireSecureTransport = cfg.TLS.Require
+	}
+	if len(cfg.Users) > 0 {
+		lcfg.AuthDelegate = authDelegate(cfg.Users)
+	} else {
+		log.Warning("proxy.config.users.empty.every.client.is.accepted")
+	}
+
+	p := &Proxy{log: log}
+	if p.listener, err = driver.NewListenerWithConfig(lcfg, router); err != nil {
+		return nil, err
+	}
+	if cfg.Metrics != "" {
+		if p.metrics, err = net.Listen("tcp", cfg.Metrics); err != nil {
+			p.listener.Close()
+			return nil, err
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc("/metrics", p.serveMetrics)
+		p.server = &http.Server{Handler: mux}
+	}
+	return p, nil
+}
+
+// proxyHandler creates the ProxyHandler to the backend of the dsn.
+func proxyHandler(log *xlog.Log, dsn string, users map[string]driver.ProxyUser) (*driver.ProxyHandler, error) {
+	d, err := driver.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := d.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	h := driver.NewProxyHandlerWithConfig(log, cfg)
+	h.SetUserMap(users)
+	return h, nil
+}
+
+// serverTLS loads the TLS of the listener, the client certificates are verified if they're given and the CA is set.
+func serverTLS(cfg *TLSConfig) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("proxy.tls.load.cert.error:%v", err)
+	}
+	c := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if cfg.CA != "" {
+		data, err := ioutil.ReadFile(cfg.CA)
+		if err != nil {
+			return nil, fmt.Errorf("proxy.tls.load.ca.error:%v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("proxy.tls.ca[%s].no.certificate", cfg.CA)
+		}
+		c.ClientCAs = pool
+		c.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return c, nil
+}
+
+// authDelegate checks the mysql_native_password of the users.
+func authDelegate(users []*UserConfig) driver.AuthDelegate {
+	byName := make(map[string]*UserConfig, len(users))
+	for _, u := range users {
+		byName[u.User] = u
+	}
+	return driver.AuthDelegateFunc(func(req *driver.AuthRequest) (*driver.AuthResult, error) {
+		u, ok := byName[req.User]
+		if !ok || subtle.ConstantTimeCompare(req.AuthResponse, proto.NativePassword(u.Password, req.Salt)) != 1 {
+			return nil, fmt.Errorf("proxy.user[%s].access.denied", req.User)
+		}
+		return &driver.AuthResult{Schemas: u.Schemas}, nil
+	})
+}
+
+// serveMetrics writes the status counters of the listener.
+func (p *Proxy) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if err := driver.WriteStatusMetrics(w, metricsNamespace, p.listener.Status()); err != nil {
+		p.log.Error("proxy.metrics.write.error:%+v", err)
+	}
+}
+
+// Start starts accepting the clients and serving the metrics.
+func (p *Proxy) Start() {
+	go p.listener.Accept()
+	if p.server != nil {
+		go p.server.Serve(p.metrics)
+	}
+	p.log.Info("proxy.listening.on[%s].metrics[%s]", p.Addr(), p.MetricsAddr())
+}
+
+// Addr returns the address the clients connect to.
+func (p *Proxy) Addr() string {
+	return p.listener.Addr()
+}
+
+// MetricsAddr returns the address of the metrics, empty if it's disabled.
+func (p *Proxy) MetricsAddr() string {
+	if p.metrics == nil {
+		return ""
+	}
+	return p.metrics.Addr().String()
+}
+
+// Close stops the listener and the metrics.
+func (p *Proxy) Close() {
+	p.listener.Close()
+	if p.server != nil {
+		p.server.Close()
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/memdb"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// freeAddr returns a local address nobody listens on.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+func TestProxy(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	// The default backend accepts every user, the one of the orders only the mock.
+	db := memdb.NewHandler(log)
+	backend, err := driver.MockMysqlServer(log, db)
+	assert.Nil(t, err)
+	defer backend.Close()
+	th := driver.NewTestHandler(log)
+	orders, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer orders.Close()
+	th.AddQuery("USE orders", &sqltypes.Result{})
+	th.AddQuery("SELECT 'orders'", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "orders", Type: querypb.Type_VARCHAR}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("orders")}},
+	})
+
+	cfg := &Config{
+		Listen:  freeAddr(t),
+		Backend: fmt.Sprintf("nobody:nobody@tcp(%s)/", backend.Addr()),
+		Schemas: map[string]string{"orders": fmt.Sprintf("nobody:nobody@tcp(%s)/", orders.Addr())},
+		Users: []*UserConfig{
+			{User: "app", Password: "app", BackendUser: "mock", BackendPassword: "mock"},
+			{User: "guest", Password: ""},
+			{User: "ro", Password: "ro", Schemas: []string{"test"}},
+		},
+		Metrics: freeAddr(t),
+	}
+	proxy, err := NewProxy(cfg, log)
+	assert.Nil(t, err)
+	proxy.Start()
+	defer proxy.Close()
+
+	errNum := func(err error) uint16 {
+		if serr, ok := err.(*sqldb.SQLError); ok {
+			return serr.Num
+		}
+		return 0
+	}
+
+	// The queries of the schemas go to their backends, the app connects the orders as the mock.
+	app, err := driver.NewConn("app", "app", proxy.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer app.Close()
+	assert.Nil(t, app.Exec("CREATE TABLE t (id INT)"))
+	assert.Nil(t, app.Exec("INSERT INTO t VALUES (1), (2)"))
+	qr, err := app.FetchAll("SELECT id FROM t ORDER BY id", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(qr.Rows))
+	assert.Equal(t, []string{"t"}, db.Tables())
+	assert.Nil(t, app.Exec("USE orders"))
+	qr, err = app.FetchAll("SELECT 'orders'", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "orders", qr.Rows[0][0].String())
+
+	// The guest isn't mapped, the orders rejects the credentials of the DSN.
+	guest, err := driver.NewConn("guest", "", proxy.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer guest.Close()
+	assert.Nil(t, guest.Exec("SELECT id FROM t"))
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), errNum(guest.Exec("USE orders")))
+
+	// The ro can only use the test.
+	ro, err := driver.NewConn("ro", "ro", proxy.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer ro.Close()
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), errNum(ro.Exec("USE orders")))
+
+	_, err = driver.NewConn("app", "wrong", proxy.Addr(), "test", "")
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), errNum(err))
+	_, err = driver.NewConn("nobody", "", proxy.Addr(), "test", "")
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), errNum(err))
+
+	resp, err := http.Get("http://" + proxy.MetricsAddr() + "/metrics")
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), "mysqlstack_proxy_threads_connected 3\n")
+	assert.Contains(t, string(body), "mysqlstack_proxy_aborted_connects_total 2\n")
+	assert.Contains(t, string(body), "mysqlstack_proxy_com_insert_total 1\n")
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mysqlstack-proxy")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "proxy.json")
+
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{
+		"listen": "127.0.0.1:3307",
+		"backend": "root:secret@tcp(127.0.0.1:3306)/",
+		"schemas": {"orders": "root:secret@tcp(10.0.0.2:3306)/"},
+		"users": [{"user": "app", "password": "app", "schemas": ["orders"]}],
+		"log": {"level": "warning"},
+		"handshake_timeout": "10s"
+	}`), 0644))
+	cfg, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1:3307", cfg.Listen)
+	assert.Equal(t, "root:secret@tcp(10.0.0.2:3306)/", cfg.Schemas["orders"])
+	assert.Equal(t, []string{"orders"}, cfg.Users[0].Schemas)
+	assert.Equal(t, "10s", cfg.HandshakeTimeout.String())
+	level, ok := logLevel(cfg.Log.Level)
+	assert.True(t, ok)
+	assert.Equal(t, xlog.WARNING, level)
+
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{`{"backend": "root@tcp(127.0.0.1:3306)/"}`, "proxy.config.listen.can.not.be.empty"},
+		{`{"listen": ":3307"}`, "proxy.config.backend.can.not.be.empty"},
+		{`{"listen": ":3307", "backend": "/", "tls": {"cert": "a.pem"}}`, "proxy.config.tls.cert.and.key.required"},
+		{`{"listen": ":3307", "backend": "/", "users": [{"user": "a"}, {"user": "a"}]}`, "proxy.config.user[a].duplicate"},
+		{`{"listen": ":3307", "backend": "/", "log": {"level": "LOUD"}}`, "proxy.config.log.level[LOUD].unknown"},
+		{`{"listen": ":3307", "backend": "/", "handshake_timeout": "soon"}`, "proxy.config[" + path + "].parse.error:proxy.config.duration[soon].invalid"},
+	}
+	for _, test := range tests {
+		assert.Nil(t, ioutil.WriteFile(path, []byte(test.config), 0644))
+		_, err := LoadConfig(path)
+		assert.NotNil(t, err)
+		if err != nil {
+			assert.Equal(t, test.err, err.Error())
+		}
+	}
+}
@@ -312,7 +312,7 @@ func (b *Buffer) WriteZero(n int) {
 }
 
 func (b *Buffer) ReadZero(n int) (err error) {
-	if (b.seek + n) > b.pos {
+	if n < 0 || n > b.pos-b.seek {
 		err = ErrIOEOF
 		return
 	}
@@ -328,7 +328,7 @@ func (b *Buffer) WriteString(s string) {
 }
 
 func (b *Buffer) ReadString(n int) (s string, err error) {
-	if (b.seek + n) > b.pos {
+	if n < 0 || n > b.pos-b.seek {
 		err = ErrIOEOF
 		return
 	}
@@ -399,7 +399,7 @@ func (b *Buffer) ReadBytes(n int) (v []byte, err error) {
 		return nil, nil
 	}
 
-	if (b.seek + n) > b.pos {
+	if n < 0 || n > b.pos-b.seek {
 		err = ErrIOEOF
 		return
 	}
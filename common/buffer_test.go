@@ -208,6 +208,21 @@ func TestBufferReadError(t *testing.T) {
 		_, got := writer.ReadBytes(4)
 		assert.Equal(t, want.Error(), got.Error())
 	}
+
+	// The length encoded from the wire overflows the seek.
+	{
+		data := []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0x01}
+		writer := ReadBuffer(data)
+		_, got := writer.ReadLenEncodeString()
+		assert.Equal(t, io.EOF.Error(), got.Error())
+	}
+
+	{
+		data := []byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xf0, 0x01}
+		writer := ReadBuffer(data)
+		_, got := writer.ReadLenEncodeBytes()
+		assert.Equal(t, io.EOF.Error(), got.Error())
+	}
 }
 
 func TestBufferReadString(t *testing.T) {
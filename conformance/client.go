@@ -0,0 +1,213 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// bigPacketSize is over the max payload of a packet, the query and the row are split into 2 packets.
+const bigPacketSize = packet.PACKET_MAX_SIZE + 10
+
+// ClientChecks are the checks of the client against the real server of the cfg.DSN.
+func ClientChecks() []Check {
+	return []Check{
+		{Name: "client.handshake", Run: clientHandshake},
+		{Name: "client.auth.plugins", Run: clientAuthPlugins},
+		{Name: "client.text.results", Run: clientTextResults},
+		{Name: "client.binary.values", Run: clientBinaryValues},
+		{Name: "client.big.packets", Run: clientBigPackets},
+		{Name: "client.errors", Run: clientErrors},
+	}
+}
+
+// connect connects to the real server, the check is skipped without the DSN.
+func connect(cfg *Config, modify func(d *driver.DSN)) (driver.Conn, error) {
+	if cfg.DSN == "" {
+		return nil, Skip("conformance.client.dsn.not.set")
+	}
+	d, err := driver.ParseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if modify != nil {
+		modify(d)
+	}
+	conn, err := driver.NewConnWithDSN(d.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+func clientHandshake(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if v := conn.ServerVersion(); v == nil || v.Major == 0 {
+		return fmt.Errorf("conformance.handshake.server.version[%+v].invalid", v)
+	}
+	if err := conn.Ping(); err != nil {
+		return err
+	}
+	qr, err := conn.FetchAll("SELECT CONNECTION_ID()", -1)
+	if err != nil {
+		return err
+	}
+	if id := qr.Rows[0][0].String(); id != strconv.FormatUint(uint64(conn.ConnectionID()), 10) {
+		return fmt.Errorf("conformance.handshake.connection.id[%d].server[%s].mismatch", conn.ConnectionID(), id)
+	}
+
+	// An unknown database is rejected in the handshake.
+	_, err = connect(cfg, func(d *driver.DSN) { d.DBName = "conformance_no_such_db" })
+	return expectSQLError(err, sqldb.ER_BAD_DB_ERROR, "42000")
+}
+
+// clientAuthPlugins connects by the auth plugin of the user, the server switches it if the user uses another one.
+func clientAuthPlugins(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	conn.Close()
+
+	_, err = connect(cfg, func(d *driver.DSN) { d.Passwd += "-conformance-wrong" })
+	return expectSQLError(err, sqldb.ER_ACCESS_DENIED_ERROR, "28000")
+}
+
+func clientTextResults(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	qr, err := conn.FetchAll("SELECT 1 AS a, NULL AS b, 'x' AS c, -9223372036854775808 AS d UNION ALL SELECT 2, 'y', '', 18446744073709551615", -1)
+	if err != nil {
+		return err
+	}
+	if len(qr.Fields) != 4 || qr.Fields[0].Name != "a" || qr.Fields[3].Name != "d" {
+		return fmt.Errorf("conformance.text.results.fields[%v].mismatch", qr.Fields)
+	}
+	want := [][]string{{"1", "NULL", "x", "-9223372036854775808"}, {"2", "y", "", "18446744073709551615"}}
+	if len(qr.Rows) != len(want) {
+		return fmt.Errorf("conformance.text.results.rows[%d].want[%d]", len(qr.Rows), len(want))
+	}
+	for i, row := range qr.Rows {
+		for j, v := range row {
+			got := v.String()
+			if v.IsNull() {
+				got = "NULL"
+			}
+			if got != want[i][j] {
+				return fmt.Errorf("conformance.text.results.row[%d].value[%d].got[%s].want[%s]", i, j, got, want[i][j])
+			}
+		}
+	}
+
+	// The empty resultset still has the fields.
+	qr, err = conn.FetchAll("SELECT 1 AS a FROM DUAL WHERE 1 = 0", -1)
+	if err != nil {
+		return err
+	}
+	if len(qr.Fields) != 1 || len(qr.Rows) != 0 {
+		return fmt.Errorf("conformance.text.results.empty.fields[%d].rows[%d]", len(qr.Fields), len(qr.Rows))
+	}
+	return nil
+}
+
+// clientBinaryValues checks the bytes of the binary columns are kept, the client speaks the text protocol only.
+func clientBinaryValues(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	want := []byte{0x00, 0xff, 0x0a, 0x0d, 0x5c, 0x27, 0xfb}
+	qr, err := conn.FetchAll("SELECT UNHEX('00FF0A0D5C27FB')", -1)
+	if err != nil {
+		return err
+	}
+	if qr.Fields[0].Charset != sqldb.CharacterSetBinary {
+		return fmt.Errorf("conformance.binary.values.charset[%d].want[%d]", qr.Fields[0].Charset, sqldb.CharacterSetBinary)
+	}
+	if got := qr.Rows[0][0].Raw(); !bytes.Equal(got, want) {
+		return fmt.Errorf("conformance.binary.values.got[%x].want[%x]", got, want)
+	}
+	return nil
+}
+
+// clientBigPackets sends a query and reads a row bigger than a packet, it's skipped if the max_allowed_packet is small.
+func clientBigPackets(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	qr, err := conn.FetchAll("SELECT @@max_allowed_packet", -1)
+	if err != nil {
+		return err
+	}
+	max, err := strconv.ParseUint(qr.Rows[0][0].String(), 10, 64)
+	if err != nil {
+		return err
+	}
+	if max < bigPacketSize+1024 {
+		return Skip("conformance.big.packets.max_allowed_packet[%d].too.small", max)
+	}
+
+	qr, err = conn.FetchAll(fmt.Sprintf("SELECT REPEAT('a', %d)", bigPacketSize), -1)
+	if err != nil {
+		return err
+	}
+	if got := len(qr.Rows[0][0].Raw()); got != bigPacketSize {
+		return fmt.Errorf("conformance.big.packets.row.size[%d].want[%d]", got, bigPacketSize)
+	}
+
+	qr, err = conn.FetchAll(fmt.Sprintf("SELECT LENGTH('%s')", strings.Repeat("a", bigPacketSize)), -1)
+	if err != nil {
+		return err
+	}
+	if got := qr.Rows[0][0].String(); got != strconv.Itoa(bigPacketSize) {
+		return fmt.Errorf("conformance.big.packets.query.size[%s].want[%d]", got, bigPacketSize)
+	}
+	return conn.Ping()
+}
+
+// clientErrors checks the ERR packets and the connection is usable after them.
+func clientErrors(cfg *Config) error {
+	conn, err := connect(cfg, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.FetchAll("SELEKT 1", -1)
+	if err := expectSQLError(err, sqldb.ER_PARSE_ERROR, "42000"); err != nil {
+		return err
+	}
+	_, err = conn.FetchAll("SELECT * FROM mysql.conformance_no_such_table", -1)
+	if err := expectSQLError(err, sqldb.ER_NO_SUCH_TABLE, "42S02"); err != nil {
+		return err
+	}
+	return conn.Ping()
+}
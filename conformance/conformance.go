@@ -0,0 +1,95 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package conformance checks the wire protocol against the real MySQL/MariaDB: the client checks
+// talk to a real server by the DSN, the server checks run the real mysql CLI against the mock server.
+//
+//	MYSQL_CONFORMANCE_DSN='root:secret@tcp(127.0.0.1:3306)/' go test ./conformance/
+//
+// The checks are skipped without the DSN or the mysql CLI, a protocol regression fails them.
+package conformance
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// Config is the environment the checks run in.
+type Config struct {
+	// DSN is the data source of the real server the client checks connect to.
+	DSN string
+
+	// MySQL is the mysql CLI the server checks run, it's looked up in the PATH if empty.
+	MySQL string
+
+	Log *xlog.Log
+}
+
+// Check is a conformance check, the error returned fails it.
+type Check struct {
+	Name string
+	Run  func(cfg *Config) error
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name    string
+	Err     error
+	Skipped bool
+	Elapsed time.Duration
+}
+
+type skipError struct {
+	reason string
+}
+
+func (e *skipError) Error() string {
+	return e.reason
+}
+
+// Skip returns the error skips the check, the environment can't run it.
+func Skip(format string, args ...interface{}) error {
+	return &skipError{reason: fmt.Sprintf(format, args...)}
+}
+
+// Run runs the checks in order.
+func Run(cfg *Config, checks []Check) []*Result {
+	results := make([]*Result, 0, len(checks))
+	for _, check := range checks {
+		start := time.Now()
+		err := check.Run(cfg)
+		r := &Result{Name: check.Name, Err: err, Elapsed: time.Since(start)}
+		if _, ok := err.(*skipError); ok {
+			r.Skipped = true
+		}
+		if cfg.Log != nil {
+			cfg.Log.Info("conformance.check[%s].elapsed[%v].skipped[%v].error:%v", r.Name, r.Elapsed, r.Skipped, r.Err)
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// expectSQLError checks the err is the SQLError of the num.
+func expectSQLError(err error, num uint16, state string) error {
+	if err == nil {
+		return fmt.Errorf("conformance.expect.error[%d].but.got.nil", num)
+	}
+	sqlErr, ok := err.(*sqldb.SQLError)
+	if !ok {
+		return fmt.Errorf("conformance.expect.sql.error[%d].but.got:%v", num, err)
+	}
+	if sqlErr.Num != num || (state != "" && sqlErr.State != state) {
+		return fmt.Errorf("conformance.expect.error[%d (%s)].but.got:%v", num, state, err)
+	}
+	return nil
+}
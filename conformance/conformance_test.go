@@ -0,0 +1,72 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package conformance
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func runChecks(t *testing.T, cfg *Config, checks []Check) {
+	for _, r := range Run(cfg, checks) {
+		switch {
+		case r.Skipped:
+			t.Logf("%s: skipped: %v", r.Name, r.Err)
+		case r.Err != nil:
+			t.Errorf("%s: %v", r.Name, r.Err)
+		}
+	}
+}
+
+func TestConformanceRun(t *testing.T) {
+	checks := []Check{
+		{Name: "pass", Run: func(cfg *Config) error { return nil }},
+		{Name: "fail", Run: func(cfg *Config) error { return errors.New("failed") }},
+		{Name: "skip", Run: func(cfg *Config) error { return Skip("skipped.%d", 1) }},
+	}
+	results := Run(&Config{}, checks)
+	assert.Equal(t, 3, len(results))
+	assert.Nil(t, results[0].Err)
+	assert.EqualError(t, results[1].Err, "failed")
+	assert.False(t, results[1].Skipped)
+	assert.EqualError(t, results[2].Err, "skipped.1")
+	assert.True(t, results[2].Skipped)
+
+	// The client checks are skipped without the DSN.
+	for _, r := range Run(&Config{MySQL: "conformance-no-such-cli"}, append(ClientChecks(), ServerChecks()...)) {
+		assert.True(t, r.Skipped, r.Name)
+	}
+}
+
+func TestConformanceExpectSQLError(t *testing.T) {
+	assert.Nil(t, expectSQLError(sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t1"), sqldb.ER_NO_SUCH_TABLE, "42S02"))
+	assert.NotNil(t, expectSQLError(sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t1"), sqldb.ER_BAD_DB_ERROR, ""))
+	assert.NotNil(t, expectSQLError(errors.New("io"), sqldb.ER_NO_SUCH_TABLE, ""))
+	assert.NotNil(t, expectSQLError(nil, sqldb.ER_NO_SUCH_TABLE, ""))
+}
+
+// TestConformanceClient runs the client checks against the server of the MYSQL_CONFORMANCE_DSN.
+func TestConformanceClient(t *testing.T) {
+	dsn := os.Getenv("MYSQL_CONFORMANCE_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_CONFORMANCE_DSN not set")
+	}
+	runChecks(t, &Config{DSN: dsn, Log: xlog.NewStdLog(xlog.Level(xlog.ERROR))}, ClientChecks())
+}
+
+// TestConformanceServer runs the server checks by the mysql CLI of the MYSQL_CONFORMANCE_CLI or in the PATH.
+func TestConformanceServer(t *testing.T) {
+	runChecks(t, &Config{MySQL: os.Getenv("MYSQL_CONFORMANCE_CLI"), Log: xlog.NewStdLog(xlog.Level(xlog.ERROR))}, ServerChecks())
+}
@@ -0,0 +1,169 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os/exec"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// ServerChecks are the checks of the server, the real mysql CLI of the cfg.MySQL talks to the mock server.
+func ServerChecks() []Check {
+	return []Check{
+		{Name: "server.handshake", Run: serverHandshake},
+		{Name: "server.text.results", Run: serverTextResults},
+		{Name: "server.big.packets", Run: serverBigPackets},
+		{Name: "server.errors", Run: serverErrors},
+	}
+}
+
+// mockServer starts the mock server for the CLI, the check is skipped without the CLI.
+func mockServer(cfg *Config) (*driver.Listener, *driver.TestHandler, error) {
+	cli := cfg.MySQL
+	if cli == "" {
+		cli = "mysql"
+	}
+	if _, err := exec.LookPath(cli); err != nil {
+		return nil, nil, Skip("conformance.server.mysql.cli[%s].not.found", cli)
+	}
+
+	log := cfg.Log
+	if log == nil {
+		log = xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	}
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	if err != nil {
+		return nil, nil, err
+	}
+	return svr, th, nil
+}
+
+// runCLI runs the query by the mysql CLI in the batch mode, the stdout is returned or the stderr as the error.
+func runCLI(cfg *Config, svr *driver.Listener, user string, query string, args ...string) (string, error) {
+	cli := cfg.MySQL
+	if cli == "" {
+		cli = "mysql"
+	}
+	_, port, err := net.SplitHostPort(svr.Addr())
+	if err != nil {
+		return "", err
+	}
+
+	args = append([]string{"--protocol=TCP", "--host=127.0.0.1", "--port=" + port, "--user=" + user, "--password=mock", "--batch"}, args...)
+	cmd := exec.Command(cli, append(args, "--execute="+query)...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("conformance.mysql.cli.query[%.64s].error:%v, stderr:%s", query, err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// serverHandshake connects by the default auth plugin of the CLI, the server switches it to the mysql_native_password.
+func serverHandshake(cfg *Config) error {
+	svr, th, err := mockServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer svr.Close()
+
+	th.AddQuery("select 1", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "1", Type: querypb.Type_INT64}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))}},
+	})
+	out, err := runCLI(cfg, svr, "mock", "select 1")
+	if err != nil {
+		return err
+	}
+	if out != "1\n1\n" {
+		return fmt.Errorf("conformance.server.handshake.output[%q].mismatch", out)
+	}
+
+	// The user is rejected by the handler.
+	_, err = runCLI(cfg, svr, "conformance", "select 1")
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("ERROR %d", sqldb.ER_ACCESS_DENIED_ERROR)) {
+		return fmt.Errorf("conformance.server.handshake.expect.access.denied.but.got:%v", err)
+	}
+	return nil
+}
+
+func serverTextResults(cfg *Config) error {
+	svr, th, err := mockServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer svr.Close()
+
+	th.AddQuery("select a, b from t1", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}, {Name: "b", Type: querypb.Type_VARCHAR}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")), sqltypes.NULL},
+		},
+	})
+	out, err := runCLI(cfg, svr, "mock", "select a, b from t1")
+	if err != nil {
+		return err
+	}
+	if want := "a\tb\n1\tx\n2\tNULL\n"; out != want {
+		return fmt.Errorf("conformance.server.text.results.output[%q].want[%q]", out, want)
+	}
+	return nil
+}
+
+// serverBigPackets sends a row bigger than a packet, the CLI reads the split packets.
+func serverBigPackets(cfg *Config) error {
+	svr, th, err := mockServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer svr.Close()
+
+	big := strings.Repeat("a", bigPacketSize)
+	th.AddQuery("select big", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "big", Type: querypb.Type_BLOB}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_BLOB, []byte(big))}},
+	})
+	out, err := runCLI(cfg, svr, "mock", "select big", "--max-allowed-packet=64M")
+	if err != nil {
+		return err
+	}
+	if out != "big\n"+big+"\n" {
+		return fmt.Errorf("conformance.server.big.packets.output.size[%d].want[%d]", len(out), len(big)+5)
+	}
+	return nil
+}
+
+func serverErrors(cfg *Config) error {
+	svr, th, err := mockServer(cfg)
+	if err != nil {
+		return err
+	}
+	defer svr.Close()
+
+	th.AddQueryError("select * from t2", sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t2"))
+	_, err = runCLI(cfg, svr, "mock", "select * from t2")
+	if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("ERROR %d (42S02)", sqldb.ER_NO_SUCH_TABLE)) {
+		return fmt.Errorf("conformance.server.errors.expect.no.such.table.but.got:%v", err)
+	}
+	return nil
+}
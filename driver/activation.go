@@ -0,0 +1,93 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// activationFdsStart is the first file descriptor passed by the socket activation, the SD_LISTEN_FDS_START.
+const activationFdsStart = 3
+
+// ActivationListeners returns the sockets passed by the systemd socket activation in the order of the LISTEN_FDS,
+// the names of the LISTEN_FDNAMES are the FileNames of the sockets of the .socket unit.
+// The new process of a zero-downtime restart inherits the sockets of the former one in the same way, see Listener.File,
+// the LISTEN_PID can be left out by the former process which doesn't know the pid of the new one.
+// It returns nil if no socket is passed to the process, the variables are unset so the children don't inherit them.
+// The sockets are served by the NewListenerWithListener.
+func ActivationListeners() ([]net.Listener, []string, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+	return activationListeners(activationFdsStart)
+}
+
+func activationListeners(start int) ([]net.Listener, []string, error) {
+	if pid := os.Getenv("LISTEN_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return nil, nil, nil
+	}
+	fds := os.Getenv("LISTEN_FDS")
+	if fds == "" {
+		return nil, nil, nil
+	}
+	n, err := strconv.Atoi(fds)
+	if err != nil || n < 0 {
+		return nil, nil, fmt.Errorf("driver.activation.listen.fds[%s].invalid", fds)
+	}
+	var names []string
+	if v := os.Getenv("LISTEN_FDNAMES"); v != "" {
+		names = strings.Split(v, ":")
+	}
+	if names != nil && len(names) != n {
+		return nil, nil, fmt.Errorf("driver.activation.listen.fdnames[%s].count.mismatch[%d]", os.Getenv("LISTEN_FDNAMES"), n)
+	}
+
+	listeners := make([]net.Listener, 0, n)
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("LISTEN_FD_%d", start+i)
+		if names != nil {
+			name = names[i]
+		}
+		// The FileListener dups the socket, the inherited one is closed.
+		f := os.NewFile(uintptr(start+i), name)
+		listener, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, nil, fmt.Errorf("driver.activation.fd[%d].name[%s].error:%v", start+i, name, err)
+		}
+		listeners = append(listeners, listener)
+	}
+	if names == nil {
+		names = make([]string, n)
+	}
+	return listeners, names, nil
+}
+
+// File returns a dup of the socket listened on, the new process of a zero-downtime restart inherits it
+// by the ExtraFiles of the exec.Cmd with the LISTEN_FDS=1 and serves it by the ActivationListeners.
+// The listener goes on until it's closed, the live sessions are served until they end.
+func (l *Listener) File() (*os.File, error) {
+	listener, ok := l.listener.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("driver.listener.file.unsupported[%T]", l.listener)
+	}
+	return listener.File()
+}
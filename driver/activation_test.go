@@ -0,0 +1,117 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// listenTestSocket listens on a random port like the MockMysqlServer.
+func listenTestSocket() (ln net.Listener, err error) {
+	for i := 0; i < 5; i++ {
+		if ln, err = net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", randomPort(10000, 20000))); err == nil {
+			return ln, nil
+		}
+	}
+	return nil, err
+}
+
+func TestNewListenerWithListener(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	ln, err := listenTestSocket()
+	assert.Nil(t, err)
+
+	// The socket is kept if the config is invalid.
+	_, err = NewListenerWithListener(&ListenerConfig{HandshakeTimeout: -time.Second}, ln, th)
+	assert.NotNil(t, err)
+
+	svr, err := NewListenerWithListener(&ListenerConfig{Log: log, Address: "ignored"}, ln, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	go svr.Accept()
+	assert.Equal(t, ln.Addr().String(), svr.Addr())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Nil(t, client.Ping())
+}
+
+func TestActivationListeners(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	ln, err := listenTestSocket()
+	assert.Nil(t, err)
+	svr, err := NewListenerWithListener(&ListenerConfig{Log: log}, ln, th)
+	assert.Nil(t, err)
+	go svr.Accept()
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The socket is passed like to the new process, the fd isn't owned by an os.File.
+	f, err := svr.File()
+	assert.Nil(t, err)
+	fd, err := syscall.Dup(int(f.Fd()))
+	assert.Nil(t, err)
+	f.Close()
+
+	defer os.Unsetenv("LISTEN_PID")
+	defer os.Unsetenv("LISTEN_FDS")
+	defer os.Unsetenv("LISTEN_FDNAMES")
+
+	// Not passed to the process.
+	os.Setenv("LISTEN_PID", "1")
+	os.Setenv("LISTEN_FDS", "1")
+	listeners, _, err := activationListeners(fd)
+	assert.Nil(t, err)
+	assert.Nil(t, listeners)
+
+	os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	os.Setenv("LISTEN_FDNAMES", "mysql:admin")
+	_, _, err = activationListeners(fd)
+	assert.EqualError(t, err, "driver.activation.listen.fdnames[mysql:admin].count.mismatch[1]")
+
+	os.Setenv("LISTEN_FDNAMES", "mysql")
+	listeners, names, err := activationListeners(fd)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"mysql"}, names)
+	assert.Equal(t, 1, len(listeners))
+
+	// The former listener stops accepting, its session goes on and the new one serves the same address.
+	svr.Close()
+	next, err := NewListenerWithListener(&ListenerConfig{Log: log}, listeners[0], th)
+	assert.Nil(t, err)
+	defer next.Close()
+	go next.Accept()
+	assert.Equal(t, svr.Addr(), next.Addr())
+	assert.Nil(t, client.Ping())
+	restarted, err := NewConn("mock", "mock", next.Addr(), "", "")
+	assert.Nil(t, err)
+	defer restarted.Close()
+	assert.Nil(t, restarted.Ping())
+
+	// The variables are unset, the sockets of the other process aren't touched.
+	os.Setenv("LISTEN_PID", "1")
+	listeners, _, err = ActivationListeners()
+	assert.Nil(t, err)
+	assert.Nil(t, listeners)
+	assert.Equal(t, "", os.Getenv("LISTEN_FDS"))
+}
@@ -0,0 +1,106 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// AdminHandler is the optional Handler to serve the COM_DEBUG and COM_SHUTDOWN of the mysqladmin debug and shutdown,
+// the commands are answered as not implemented if the handler doesn't implement it.
+type AdminHandler interface {
+	// ComDebug dumps the internal state of the handler to the log, the Listener dumps its own before it.
+	ComDebug(session *Session) error
+
+	// ComShutdown checks the privilege of the session to shut down the server at the level like the sqldb.SHUTDOWN_DEFAULT,
+	// the error like the ER_SPECIFIC_ACCESS_DENIED_ERROR of the SHUTDOWN privilege denies it.
+	// If it returns nil, the shutdown is acknowledged and the Listener stops accepting, the sessions running
+	// go on until they quit and the handler shuts down the rest of the server.
+	ComShutdown(session *Session, level byte) error
+}
+
+// RefreshHandler is the optional Handler to serve the COM_REFRESH of the mysqladmin flush-* and reload,
+// the command is answered as not implemented if the handler doesn't implement it.
+type RefreshHandler interface {
+	// ComRefresh checks the RELOAD privilege of the session and flushes what the handler owns of the flags
+	// like the sqldb.REFRESH_TABLES and sqldb.REFRESH_HOSTS, the error denies the refresh.
+	// If it returns nil, the Listener reopens the log for the sqldb.REFRESH_LOG and resets the status counters
+	// for the sqldb.REFRESH_STATUS.
+	ComRefresh(session *Session, flags byte) error
+}
+
+// handleRefresh handles the COM_REFRESH, the error returned is the write error.
+func (l *Listener) handleRefresh(session *Session, data []byte) error {
+	rh, ok := l.handler.(RefreshHandler)
+	if !ok {
+		return l.writeNotImplemented(session, data[0])
+	}
+	if len(data) < 2 {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, ""))
+	}
+
+	flags := data[1]
+	if err := rh.ComRefresh(session, flags); err != nil {
+		l.log.Error("server.handle.refresh.from.session[%v].flags[%d].error:%+v", session.ID(), flags, err)
+		return session.writeErrFromError(err)
+	}
+	if flags&sqldb.REFRESH_LOG > 0 {
+		if err := l.log.Reopen(); err != nil {
+			l.log.Error("server.refresh.log.reopen.error:%+v", err)
+			return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "reopen the log: %v", err))
+		}
+	}
+	if flags&sqldb.REFRESH_STATUS > 0 {
+		l.ResetStatus()
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleDebug handles the COM_DEBUG, the error returned is the write error.
+func (l *Listener) handleDebug(session *Session, data []byte) error {
+	ah, ok := l.handler.(AdminHandler)
+	if !ok {
+		return l.writeNotImplemented(session, data[0])
+	}
+
+	l.mu.RLock()
+	l.log.Info("server.debug.from.session[%v].user[%s]: address[%s], version[%s], trace[%v], capture[%v], memory.limit[%d], status[%+v]",
+		session.ID(), session.User(), l.address, l.greeting.ServerVersion, l.trace, l.pcap != nil, l.sessionMemoryLimit, l.Status())
+	l.mu.RUnlock()
+	if err := ah.ComDebug(session); err != nil {
+		l.log.Error("server.handle.debug.from.session[%v].error:%+v", session.ID(), err)
+		return session.writeErrFromError(err)
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleShutdown handles the COM_SHUTDOWN, the error returned is the write error.
+func (l *Listener) handleShutdown(session *Session, data []byte) error {
+	ah, ok := l.handler.(AdminHandler)
+	if !ok {
+		return l.writeNotImplemented(session, data[0])
+	}
+
+	// The level is optional, the clients since 5.7 don't send it.
+	level := sqldb.SHUTDOWN_DEFAULT
+	if len(data) > 1 {
+		level = data[1]
+	}
+	if err := ah.ComShutdown(session, level); err != nil {
+		l.log.Warning("server.shutdown.from.session[%v].user[%s].denied:%+v", session.ID(), session.User(), err)
+		return session.writeErrFromError(err)
+	}
+	l.log.Warning("server.shutdown.from.session[%v].user[%s].level[%d]", session.ID(), session.User(), level)
+	if err := session.packets.WriteOK(0, 0, session.Status(), 0); err != nil {
+		return err
+	}
+	l.Close()
+	return nil
+}
@@ -0,0 +1,202 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+type adminHandler struct {
+	*TestHandler
+	mu        sync.Mutex
+	debugs    int
+	privilege bool
+	shutdowns []byte
+	refreshes []byte
+}
+
+func (h *adminHandler) ComRefresh(s *Session, flags byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.privilege {
+		return sqldb.NewSQLError(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; you need (at least one of) the %-.128s privilege(s) for this operation", "RELOAD")
+	}
+	h.refreshes = append(h.refreshes, flags)
+	return nil
+}
+
+func (h *adminHandler) ComDebug(s *Session) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.debugs++
+	return nil
+}
+
+func (h *adminHandler) ComShutdown(s *Session, level byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.privilege {
+		return sqldb.NewSQLError(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; you need (at least one of) the %-.128s privilege(s) for this operation", "SHUTDOWN")
+	}
+	h.shutdowns = append(h.shutdowns, level)
+	return nil
+}
+
+func TestServerAdminCommands(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &adminHandler{TestHandler: NewTestHandler(log)}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	// Debug.
+	{
+		client, err := NewConn("mock", "mock", address, "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.Nil(t, client.Command(sqldb.COM_DEBUG))
+		assert.Nil(t, client.Ping())
+		th.mu.Lock()
+		assert.Equal(t, 1, th.debugs)
+		th.mu.Unlock()
+	}
+
+	// Shutdown denied.
+	{
+		client, err := NewConn("mock", "mock", address, "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		err = client.Command(sqldb.COM_SHUTDOWN)
+		assert.Equal(t, uint16(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR), err.(*sqldb.SQLError).Num)
+		assert.Nil(t, client.Ping())
+	}
+
+	// Shutdown, the session goes on but the listener stops accepting.
+	{
+		th.mu.Lock()
+		th.privilege = true
+		th.mu.Unlock()
+		client, err := NewConn("mock", "mock", address, "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.Nil(t, client.WriteCommand(sqldb.COM_SHUTDOWN, []byte{sqldb.SHUTDOWN_WAIT_CONNECTIONS}))
+		_, err = client.NextPacket()
+		assert.Nil(t, err)
+		th.mu.Lock()
+		assert.Equal(t, []byte{sqldb.SHUTDOWN_WAIT_CONNECTIONS}, th.shutdowns)
+		th.mu.Unlock()
+		assert.Nil(t, client.Ping())
+
+		_, err = NewConn("mock", "mock", address, "", "")
+		assert.NotNil(t, err)
+	}
+}
+
+func TestServerAdminNotImplemented(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	err = client.Command(sqldb.COM_SHUTDOWN)
+	assert.Equal(t, "command handling not implemented yet: COM_SHUTDOWN (errno 1105) (sqlstate HY000)", err.Error())
+	err = client.Command(sqldb.COM_DEBUG)
+	assert.Equal(t, "command handling not implemented yet: COM_DEBUG (errno 1105) (sqlstate HY000)", err.Error())
+	err = client.Command(sqldb.COM_REFRESH)
+	assert.Equal(t, "command handling not implemented yet: COM_REFRESH (errno 1105) (sqlstate HY000)", err.Error())
+}
+
+func TestServerRefresh(t *testing.T) {
+	dir, err := ioutil.TempDir("", "driver")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.log")
+	log, err := xlog.NewFileLog(path, xlog.Level(xlog.ERROR))
+	assert.Nil(t, err)
+
+	th := &adminHandler{TestHandler: NewTestHandler(log)}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	refresh := func(client Conn, flags byte) error {
+		if err := client.WriteCommand(sqldb.COM_REFRESH, []byte{flags}); err != nil {
+			return err
+		}
+		data, err := client.NextPacket()
+		if err != nil {
+			return err
+		}
+		if data[0] == proto.ERR_PACKET {
+			return proto.UnPackERR(data)
+		}
+		return nil
+	}
+
+	// The counters reset by the FLUSH STATUS.
+	counters := func() ServerStatus {
+		status := svr.Status()
+		return ServerStatus{Connections: status.Connections, AbortedConnects: status.AbortedConnects, Questions: status.Questions}
+	}
+
+	// Aborted.
+	_, err = NewConn("xx", "mock", address, "", "")
+	assert.NotNil(t, err)
+
+	client, err := NewConn("mock", "mock", address, "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Nil(t, client.Ping())
+	assert.Equal(t, ServerStatus{Connections: 2, AbortedConnects: 1, Questions: 1}, counters())
+
+	// Denied.
+	err = refresh(client, sqldb.REFRESH_STATUS)
+	assert.Equal(t, uint16(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR), err.(*sqldb.SQLError).Num)
+	assert.Equal(t, ServerStatus{Connections: 2, AbortedConnects: 1, Questions: 2}, counters())
+
+	th.mu.Lock()
+	th.privilege = true
+	th.mu.Unlock()
+
+	// Status.
+	assert.Nil(t, refresh(client, sqldb.REFRESH_STATUS|sqldb.REFRESH_TABLES))
+	assert.Equal(t, ServerStatus{}, counters())
+	assert.Equal(t, uint64(1), svr.Status().ThreadsConnected)
+
+	// Logs, the log is reopened once moved away.
+	rotated := path + ".1"
+	assert.Nil(t, os.Rename(path, rotated))
+	assert.Nil(t, refresh(client, sqldb.REFRESH_LOG))
+	err = client.Command(sqldb.COM_SLEEP)
+	assert.NotNil(t, err)
+	cur, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.True(t, strings.Contains(string(cur), "COM_SLEEP"))
+
+	th.mu.Lock()
+	assert.Equal(t, []byte{sqldb.REFRESH_STATUS | sqldb.REFRESH_TABLES, sqldb.REFRESH_LOG}, th.refreshes)
+	th.mu.Unlock()
+}
@@ -0,0 +1,34 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+const (
+	// ClientName is the _client_name connection attribute.
+	ClientName = "go-mysqlstack"
+)
+
+// DefaultConnectAttrs returns the connection attributes every client sends,
+// they show up in performance_schema.session_connect_attrs.
+func DefaultConnectAttrs() map[string]string {
+	return map[string]string{
+		"_client_name": ClientName,
+		"_pid":         strconv.Itoa(os.Getpid()),
+		"_os":          runtime.GOOS,
+		"_platform":    runtime.GOARCH,
+		"program_name": filepath.Base(os.Args[0]),
+	}
+}
@@ -0,0 +1,243 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+// AuthRequest is the handshake of the session the AuthDelegate checks.
+type AuthRequest struct {
+	User string
+	Host string
+
+	// Plugin is the auth plugin of the AuthResponse like the mysql_native_password.
+	Plugin       string
+	AuthResponse []byte
+	Salt         []byte
+
+	// Database is the database of the handshake, empty if none.
+	Database string
+
+	// TLSState is the TLS state of the session, nil if it isn't encrypted.
+	TLSState *tls.ConnectionState
+}
+
+// AuthResult is the accepted session.
+type AuthResult struct {
+	// EffectiveUser is the user the session acts as, empty is the user of the handshake.
+	EffectiveUser string
+
+	// Schemas are the databases the session can use, empty allows all. They limit the database of the handshake,
+	// the COM_INIT_DB and the USE, and the databases the queries name like the otherdb of the otherdb.tbl.
+	Schemas []string
+
+	// PasswordExpired accepts the session in the sandbox mode, see Listener.SetPasswordExpired.
+	PasswordExpired bool
+}
+
+// AuthDelegate checks the credentials instead of the Handler.AuthCheck, like by the LDAP, the Vault or an HTTP service.
+// The error denies the session, it's sent as the ER_ACCESS_DENIED_ERROR if it isn't a SQLError.
+type AuthDelegate interface {
+	Authenticate(req *AuthRequest) (*AuthResult, error)
+}
+
+// AuthDelegateFunc adapts the func to the AuthDelegate.
+type AuthDelegateFunc func(req *AuthRequest) (*AuthResult, error)
+
+// Authenticate calls the f.
+func (f AuthDelegateFunc) Authenticate(req *AuthRequest) (*AuthResult, error) {
+	return f(req)
+}
+
+// SetAuthDelegate sets the delegate checking the credentials of the coming sessions, nil checks them by the Handler.AuthCheck.
+func (l *Listener) SetAuthDelegate(delegate AuthDelegate) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.delegate = delegate
+}
+
+func (l *Listener) authDelegate() AuthDelegate {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.delegate
+}
+
+// authenticate checks the session by the delegate and applies the result.
+func (l *Listener) authenticate(session *Session, delegate AuthDelegate) error {
+	req := &AuthRequest{
+		User:         session.User(),
+		Host:         session.host(),
+		Plugin:       session.auth.PluginName(),
+		AuthResponse: session.Scramble(),
+		Salt:         session.Salt(),
+		Database:     session.auth.Database(),
+		TLSState:     session.TLSState(),
+	}
+	result, err := delegate.Authenticate(req)
+	if err != nil {
+		var se *sqldb.SQLError
+		if !errors.As(err, &se) {
+			err = session.accessDenied()
+		}
+		return err
+	}
+	if result == nil {
+		result = &AuthResult{}
+	}
+	session.setAuthResult(result)
+	if req.Database != "" {
+		return session.checkSchema(req.Database)
+	}
+	return nil
+}
+
+// setAuthResult sets the effective user, the schemas and the sandbox mode of the session.
+func (s *Session) setAuthResult(result *AuthResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.effectiveUser = result.EffectiveUser
+	s.sandbox = result.PasswordExpired
+	s.schemas = nil
+	if len(result.Schemas) > 0 {
+		s.schemas = make(map[string]bool, len(result.Schemas))
+		for _, schema := range result.Schemas {
+			s.schemas[schema] = true
+		}
+	}
+}
+
+// AuthUser returns the user the session authenticated as, it's the user of the handshake.
+func (s *Session) AuthUser() string {
+	return s.User()
+}
+
+// EffectiveUser returns the user the session acts as like the CURRENT_USER() of MySQL,
+// it's the AuthUser if the AuthDelegate or the SetEffectiveUser didn't change it.
+func (s *Session) EffectiveUser() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.effectiveUser != "" {
+		return s.effectiveUser
+	}
+	return s.auth.User()
+}
+
+// SetEffectiveUser sets the user the session acts as like the proxy user of MySQL,
+// the Handler.AuthCheck calls it to impersonate the user, empty acts as the AuthUser.
+func (s *Session) SetEffectiveUser(user string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.effectiveUser = user
+}
+
+// ProxyUser returns the AuthUser like 'ldap'@'127.0.0.1' if the session acts as another user,
+// it's the proxy_user variable of MySQL, empty if the session isn't proxied.
+func (s *Session) ProxyUser() string {
+	if user := s.AuthUser(); user != s.EffectiveUser() {
+		return fmt.Sprintf("'%s'@'%s'", user, s.host())
+	}
+	return ""
+}
+
+// SchemaAllowed checks whether the session can use the schema, all are allowed if the AuthDelegate didn't limit them.
+func (s *Session) SchemaAllowed(schema string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schemas == nil || s.schemas[schema]
+}
+
+// checkSchema returns the ER_DBACCESS_DENIED_ERROR if the session can't use the schema.
+func (s *Session) checkSchema(schema string) error {
+	if s.SchemaAllowed(schema) {
+		return nil
+	}
+	return sqldb.NewSQLError(sqldb.ER_DBACCESS_DENIED_ERROR, "Access denied for user '%s'@'%s' to database '%s'", s.EffectiveUser(), s.host(), schema)
+}
+
+// checkQuery checks the schemas the query names like the checkSchema: the database of the USE,
+// the SHOW and the DDL and the qualifiers of the tables and the columns, the unqualified ones are
+// in the current database which was already checked.
+// The statement can't be parsed is denied as its schemas are unknown unless it's the BEGIN, the COMMIT, the ROLLBACK or the SET,
+// so are the EXPLAIN, the DESCRIBE and the OPTIMIZE whose tables the parser skips.
+func (s *Session) checkQuery(query string) error {
+	s.mu.RLock()
+	limited := s.schemas != nil
+	s.mu.RUnlock()
+	if !limited {
+		return nil
+	}
+	stmt, err := s.Parse(query)
+	if err != nil {
+		switch sqlparser.Preview(query) {
+		case sqlparser.StmtBegin, sqlparser.StmtCommit, sqlparser.StmtRollback, sqlparser.StmtSet, sqlparser.StmtKill:
+			return nil
+		}
+		return s.queryDenied(query)
+	}
+	switch stmt.(type) {
+	case *sqlparser.Explain, *sqlparser.OtherRead, *sqlparser.OtherAdmin:
+		return s.queryDenied(query)
+	}
+	for _, schema := range querySchemas(stmt) {
+		if err := s.checkSchema(schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryDenied returns the ER_DBACCESS_DENIED_ERROR of the query whose schemas are unknown.
+func (s *Session) queryDenied(query string) error {
+	return sqldb.NewSQLError(sqldb.ER_DBACCESS_DENIED_ERROR, "Access denied for user '%s'@'%s' to database '%s'", s.EffectiveUser(), s.host(), strings.TrimSpace(query))
+}
+
+// querySchemas returns the databases the statement names.
+func querySchemas(stmt sqlparser.Statement) []string {
+	var schemas []string
+	switch stmt := stmt.(type) {
+	case *sqlparser.Use:
+		schemas = append(schemas, stmt.DBName.String())
+	case *sqlparser.Show:
+		// The SHOW doesn't walk its names.
+		if !stmt.Database.IsEmpty() {
+			schemas = append(schemas, stmt.Database.Name.String())
+		}
+		if !stmt.Table.Qualifier.IsEmpty() {
+			schemas = append(schemas, stmt.Table.Qualifier.String())
+		}
+	case *sqlparser.DDL:
+		if !stmt.Database.IsEmpty() {
+			schemas = append(schemas, stmt.Database.String())
+		}
+	}
+	sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if table, ok := node.(sqlparser.TableName); ok && !table.Qualifier.IsEmpty() {
+			schemas = append(schemas, table.Qualifier.String())
+		}
+		return true, nil
+	}, stmt)
+	return schemas
+}
+
+// accessDenied returns the ER_ACCESS_DENIED_ERROR of the session user.
+func (s *Session) accessDenied() error {
+	using := "NO"
+	if len(s.Scramble()) > 0 {
+		using = "YES"
+	}
+	return sqldb.NewSQLError(sqldb.ER_ACCESS_DENIED_ERROR, "Access denied for user '%s'@'%s' (using password: %s)", s.User(), s.host(), using)
+}
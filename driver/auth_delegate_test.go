@@ -0,0 +1,224 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// effectiveUserHandler records the effective user of the last query.
+type effectiveUserHandler struct {
+	*TestHandler
+	mu   sync.Mutex
+	user string
+	dbs  []string
+}
+
+func (h *effectiveUserHandler) ComInitDB(s *Session, db string) error {
+	h.mu.Lock()
+	h.dbs = append(h.dbs, db)
+	h.mu.Unlock()
+	return h.TestHandler.ComInitDB(s, db)
+}
+
+func (h *effectiveUserHandler) initDBs() []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.dbs
+}
+
+func (h *effectiveUserHandler) ComQuery(s *Session, query string, callback func(*sqltypes.Result) error) error {
+	h.mu.Lock()
+	h.user = s.EffectiveUser()
+	h.mu.Unlock()
+	return h.TestHandler.ComQuery(s, query, callback)
+}
+
+func (h *effectiveUserHandler) lastUser() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.user
+}
+
+func TestListenerAuthDelegate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &effectiveUserHandler{TestHandler: NewTestHandler(log)}
+	var req *AuthRequest
+	delegate := AuthDelegateFunc(func(r *AuthRequest) (*AuthResult, error) {
+		req = r
+		switch r.User {
+		case "ldap":
+			if !bytes.Equal(r.AuthResponse, proto.NativePassword("secret", r.Salt)) {
+				return nil, errors.New("ldap.bind.failed")
+			}
+			return &AuthResult{EffectiveUser: "app", Schemas: []string{"test"}}, nil
+		case "locked":
+			return nil, sqldb.NewSQLError(sqldb.ER_HOST_NOT_PRIVILEGED, "Host '%s' is not allowed to connect to this MySQL server", r.Host)
+		}
+		return nil, errors.New("unknown.user")
+	})
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, AuthDelegate: delegate}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("USE test", &sqltypes.Result{})
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+	th.AddQuery("SELECT * FROM test.t1", &sqltypes.Result{})
+	th.AddQuery("BEGIN", &sqltypes.Result{})
+
+	// The handler rejects the "ldap" user, but it isn't asked.
+	client, err := NewConn("ldap", "secret", svr.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Equal(t, "ldap", req.User)
+	assert.Equal(t, "127.0.0.1", req.Host)
+	assert.Equal(t, proto.DefaultAuthPluginName, req.Plugin)
+	assert.Equal(t, "test", req.Database)
+	assert.Nil(t, req.TLSState)
+	_, err = client.FetchAll("SELECT 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "app", th.lastUser())
+
+	// The schemas are limited.
+	err = client.InitDB("other")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num)
+	err = client.Exec("USE other")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num)
+	assert.Nil(t, client.Exec("USE test"))
+	assert.Nil(t, client.InitDB("test"))
+	// The USE can't be parsed is denied.
+	err = client.Exec("USE `test")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num)
+	_, err = NewConn("ldap", "secret", svr.Addr(), "other", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num)
+
+	// The databases the queries name are limited too.
+	_, err = client.FetchAll("SELECT * FROM test.t1", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("BEGIN", -1)
+	assert.Nil(t, err)
+	queries := []string{
+		"SELECT * FROM other.t1",
+		"SELECT * FROM t1 JOIN other.t2 ON t1.id = t2.id",
+		"SELECT other.t1.id FROM t1",
+		"SELECT * FROM t1 WHERE id IN (SELECT id FROM other.t2)",
+		"INSERT INTO other.t1 VALUES (1)",
+		"UPDATE other.t1 SET a = 1",
+		"DELETE FROM other.t1",
+		"DROP TABLE other.t1",
+		"DROP DATABASE other",
+		"SHOW TABLES FROM other",
+		"SHOW CREATE TABLE other.t1",
+		// They can't be parsed.
+		"SELECT * FROM other.t1 WHERE",
+		"CALL other.p()",
+		"LOAD DATA INFILE 'x' INTO TABLE other.t1",
+		"HANDLER other.t1 OPEN",
+		"LOCK TABLES other.t1 READ",
+		"WITH x AS (SELECT * FROM other.t1) SELECT * FROM x",
+		"GRANT ALL ON other.* TO 'app'@'%'",
+		"DO (SELECT 1 FROM other.t1)",
+		"CHECKSUM TABLE other.t1",
+		// Their tables aren't parsed.
+		"EXPLAIN SELECT * FROM other.t1",
+		"DESCRIBE other.t1",
+		"OPTIMIZE TABLE other.t1",
+	}
+	for _, query := range queries {
+		_, err = client.FetchAll(query, -1)
+		num, _ = sqldb.ErrorNum(err)
+		assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num, query)
+	}
+	// The prepared statements are executed as the queries.
+	stmt, err := client.Prepare("SELECT * FROM test.t1 WHERE id = ?")
+	assert.Nil(t, err)
+	assert.Nil(t, stmt.Close())
+	_, err = client.Prepare("SELECT * FROM other.t1 WHERE id = ?")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_DBACCESS_DENIED_ERROR), num)
+
+	// The denied ones, the handler never sees their databases.
+	dbs := len(th.initDBs())
+	_, err = NewConn("ldap", "wrong", svr.Addr(), "test", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+	assert.Equal(t, dbs, len(th.initDBs()))
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+	_, err = NewConn("locked", "", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_HOST_NOT_PRIVILEGED), num)
+
+	// Back to the handler.
+	svr.SetAuthDelegate(nil)
+	plain, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer plain.Close()
+	assert.Nil(t, plain.InitDB("other"))
+}
+
+// proxyHandler impersonates the "app" user by the "ldap" one.
+type proxyHandler struct {
+	*TestHandler
+}
+
+func (h *proxyHandler) AuthCheck(s *Session) error {
+	if s.AuthUser() == "ldap" {
+		s.SetEffectiveUser("app")
+		return nil
+	}
+	return h.TestHandler.AuthCheck(s)
+}
+
+func TestSessionProxyUser(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &proxyHandler{TestHandler: NewTestHandler(log)}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSessionFunctions(true)
+	svr.SetSystemVariables(true)
+
+	client, err := NewConn("ldap", "", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	qr, err := client.FetchAll("SELECT USER(), CURRENT_USER(), @@proxy_user", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "ldap@127.0.0.1", qr.Rows[0][0].String())
+	assert.Equal(t, "app@127.0.0.1", qr.Rows[0][1].String())
+	assert.Equal(t, "'ldap'@'127.0.0.1'", qr.Rows[0][2].String())
+	err = client.Exec("SET proxy_user = 'other'")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_INCORRECT_GLOBAL_LOCAL_VAR), num)
+
+	// Not proxied.
+	plain, err := NewConn("mock", "", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer plain.Close()
+	qr, err = plain.FetchAll("SELECT USER(), CURRENT_USER(), @@proxy_user", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "mock@127.0.0.1", qr.Rows[0][0].String())
+	assert.Equal(t, "mock@127.0.0.1", qr.Rows[0][1].String())
+	assert.True(t, qr.Rows[0][2].IsNull())
+}
@@ -0,0 +1,211 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// Replica is the reader of the router, its health, lag and latency are kept by the health checks.
+type Replica struct {
+	dsn    string
+	pool   *Pool
+	active int64
+
+	mu      sync.RWMutex
+	healthy bool
+	lag     time.Duration
+	latency time.Duration
+}
+
+func newReplica(dsn string, maxIdle int) (*Replica, error) {
+	pool, err := NewPool(dsn, maxIdle)
+	if err != nil {
+		return nil, err
+	}
+	return &Replica{dsn: dsn, pool: pool, healthy: true}, nil
+}
+
+// DSN returns the dsn of the replica.
+func (r *Replica) DSN() string {
+	return r.dsn
+}
+
+// Active returns the count of the routing connections using the replica.
+func (r *Replica) Active() int64 {
+	return atomic.LoadInt64(&r.active)
+}
+
+// Healthy returns false if the last health check failed or the lag exceeded the max lag.
+func (r *Replica) Healthy() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.healthy
+}
+
+// Lag returns the replication lag of the last health check.
+func (r *Replica) Lag() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.lag
+}
+
+// Latency returns the moving average of the health check latencies, zero before the first check.
+func (r *Replica) Latency() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.latency
+}
+
+// check runs the replica status query of the replica, the replica with the larger lag than the maxLag
+// or the broken replication is unhealthy, the server which is not a replica is healthy without the lag.
+func (r *Replica) check(maxLag time.Duration) error {
+	start := time.Now()
+	lag, err := r.replicationLag()
+	elapsed := time.Since(start)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err != nil {
+		r.healthy = false
+		return err
+	}
+	if r.latency == 0 {
+		r.latency = elapsed
+	} else {
+		r.latency = (r.latency*7 + elapsed) / 8
+	}
+	r.lag = lag
+	if r.healthy = maxLag <= 0 || lag <= maxLag; !r.healthy {
+		return fmt.Errorf("driver.replica[%s].lag[%v].exceeds[%v]", r.dsn, lag, maxLag)
+	}
+	return nil
+}
+
+// replicationLag returns the Seconds_Behind_Source of the SHOW REPLICA STATUS,
+// the SHOW SLAVE STATUS is used by the servers before MySQL 8.0.22.
+func (r *Replica) replicationLag() (time.Duration, error) {
+	c, err := r.pool.Get()
+	if err != nil {
+		return 0, err
+	}
+	defer r.pool.Put(c)
+
+	column := "Seconds_Behind_Source"
+	qr, err := c.FetchAll("SHOW REPLICA STATUS", -1)
+	if sqldb.IsErrorNum(err, sqldb.ER_PARSE_ERROR) {
+		column = "Seconds_Behind_Master"
+		qr, err = c.FetchAll("SHOW SLAVE STATUS", -1)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Rows) == 0 {
+		return 0, nil
+	}
+	for i, field := range qr.Fields {
+		if field.Name != column {
+			continue
+		}
+		v := qr.Rows[0][i]
+		if v.IsNull() {
+			return 0, fmt.Errorf("driver.replica[%s].replication.is.not.running", r.dsn)
+		}
+		seconds, err := v.ToUint64()
+		if err != nil {
+			return 0, fmt.Errorf("driver.replica[%s].invalid.%s[%s]", r.dsn, column, v.String())
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, fmt.Errorf("driver.replica[%s].status.missing.%s", r.dsn, column)
+}
+
+// Balancer picks the reader of the router from the healthy replicas, it must be safe for the concurrent use.
+type Balancer interface {
+	Pick(replicas []*Replica) *Replica
+}
+
+// RoundRobinBalancer picks the replicas in turn.
+type RoundRobinBalancer struct {
+	next uint32
+}
+
+// Pick impl.
+func (b *RoundRobinBalancer) Pick(replicas []*Replica) *Replica {
+	n := atomic.AddUint32(&b.next, 1)
+	return replicas[int(n-1)%len(replicas)]
+}
+
+// LeastConnBalancer picks the replica with the fewest active routing connections.
+type LeastConnBalancer struct{}
+
+// Pick impl.
+func (b *LeastConnBalancer) Pick(replicas []*Replica) *Replica {
+	best := replicas[0]
+	for _, r := range replicas[1:] {
+		if r.Active() < best.Active() {
+			best = r
+		}
+	}
+	return best
+}
+
+// LatencyBalancer picks the replica randomly by the weight of the inverse latency,
+// the replica not checked yet has the weight of the fastest one.
+type LatencyBalancer struct {
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewLatencyBalancer creates the LatencyBalancer.
+func NewLatencyBalancer() *LatencyBalancer {
+	return &LatencyBalancer{rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// Pick impl.
+func (b *LatencyBalancer) Pick(replicas []*Replica) *Replica {
+	fastest := time.Duration(0)
+	for _, r := range replicas {
+		if l := r.Latency(); l > 0 && (fastest == 0 || l < fastest) {
+			fastest = l
+		}
+	}
+	if fastest == 0 {
+		fastest = time.Millisecond
+	}
+
+	weights := make([]float64, len(replicas))
+	var total float64
+	for i, r := range replicas {
+		l := r.Latency()
+		if l <= 0 {
+			l = fastest
+		}
+		weights[i] = 1 / float64(l)
+		total += weights[i]
+	}
+
+	b.mu.Lock()
+	x := b.rand.Float64() * total
+	b.mu.Unlock()
+	for i, w := range weights {
+		if x < w {
+			return replicas[i]
+		}
+		x -= w
+	}
+	return replicas[len(replicas)-1]
+}
@@ -0,0 +1,47 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/proto"
+)
+
+// BinlogDumpHandler is the optional Handler to serve the binlog dumps to the slaves,
+// the commands are answered as not implemented if the handler doesn't implement it.
+type BinlogDumpHandler interface {
+	// ComBinlogDump streams the events from the position by the stream,
+	// the dump is ended with EOF if it returns nil, or an ERR with the error.
+	ComBinlogDump(session *Session, dump *proto.BinlogDump, stream *BinlogStream) error
+
+	// ComBinlogDumpGTID streams the events not in the gtid set, it ends as the ComBinlogDump.
+	ComBinlogDumpGTID(session *Session, dump *proto.BinlogDumpGTID, stream *BinlogStream) error
+}
+
+// BinlogStream writes the binlog events of the dump to the slave.
+type BinlogStream struct {
+	session *Session
+}
+
+// Session returns the slave session.
+func (s *BinlogStream) Session() *Session {
+	return s.session
+}
+
+// WriteEvent writes the event includes the header and the checksum.
+func (s *BinlogStream) WriteEvent(data []byte) error {
+	pkt := make([]byte, 0, 1+len(data))
+	pkt = append(pkt, proto.OK_PACKET)
+	pkt = append(pkt, data...)
+	return s.session.packets.Write(pkt)
+}
+
+func (s *BinlogStream) writeEOF() error {
+	return s.session.packets.Write([]byte{proto.EOF_PACKET})
+}
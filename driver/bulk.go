@@ -0,0 +1,235 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+)
+
+const (
+	// DefaultMaxAllowedPacket is the max_allowed_packet default value of MySQL 5.7.
+	DefaultMaxAllowedPacket = 4 << 20
+)
+
+// DupPolicy tells the bulk inserter how to handle the duplicate key rows.
+type DupPolicy int
+
+const (
+	// DupError returns the ER_DUP_ENTRY error to the caller.
+	DupError DupPolicy = iota
+
+	// DupIgnore uses INSERT IGNORE.
+	DupIgnore
+
+	// DupReplace uses REPLACE INTO.
+	DupReplace
+
+	// DupUpdate uses ON DUPLICATE KEY UPDATE col=VALUES(col) for all the columns.
+	DupUpdate
+
+	// DupRetryIgnore uses INSERT, and retries the batch with INSERT IGNORE if it fails with ER_DUP_ENTRY.
+	DupRetryIgnore
+)
+
+// BulkInserter batches the rows into multi-row INSERT statements,
+// every statement is sized under the max allowed packet.
+type BulkInserter struct {
+	conn      Conn
+	table     string
+	columns   []string
+	policy    DupPolicy
+	maxPacket int
+	maxRows   int
+
+	// values is the VALUES list of the pending batch.
+	values       bytes.Buffer
+	pending      int
+	rows         int
+	statements   int
+	rowsAffected uint64
+}
+
+// NewBulkInserter creates the bulk inserter for the table columns.
+func NewBulkInserter(conn Conn, table string, columns ...string) *BulkInserter {
+	return &BulkInserter{
+		conn:      conn,
+		table:     table,
+		columns:   columns,
+		maxPacket: DefaultMaxAllowedPacket,
+	}
+}
+
+// SetMaxPacket sets the max statement size, it should be the server max_allowed_packet.
+func (b *BulkInserter) SetMaxPacket(size int) {
+	b.maxPacket = size
+}
+
+// SetMaxRows sets the max rows of one statement, 0 is unlimited.
+func (b *BulkInserter) SetMaxRows(rows int) {
+	b.maxRows = rows
+}
+
+// SetDupPolicy sets the duplicate key policy.
+func (b *BulkInserter) SetDupPolicy(policy DupPolicy) {
+	b.policy = policy
+}
+
+// Add adds one row, the pending batch is flushed first if the row doesn't fit in.
+func (b *BulkInserter) Add(values ...interface{}) error {
+	if len(values) != len(b.columns) {
+		return fmt.Errorf("driver.bulk.values.count[%d].columns.count[%d].mismatch", len(values), len(b.columns))
+	}
+
+	var row bytes.Buffer
+	row.WriteByte('(')
+	for i, v := range values {
+		if i > 0 {
+			row.WriteByte(',')
+		}
+		lit, err := escape.Literal(v)
+		if err != nil {
+			return err
+		}
+		row.WriteString(lit)
+	}
+	row.WriteByte(')')
+
+	// 1 byte for the command, 1 byte for the values separator.
+	overhead := b.overhead()
+	if overhead+row.Len() > b.maxPacket {
+		return fmt.Errorf("driver.bulk.row.size[%d].exceeds.max.packet[%d]", row.Len(), b.maxPacket)
+	}
+	if b.pending > 0 {
+		if (b.maxRows > 0 && b.pending >= b.maxRows) || overhead+b.values.Len()+1+row.Len() > b.maxPacket {
+			if err := b.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if b.pending > 0 {
+		b.values.WriteByte(',')
+	}
+	b.values.Write(row.Bytes())
+	b.pending++
+	return nil
+}
+
+// AddRows adds the rows from the channel until it's closed.
+func (b *BulkInserter) AddRows(rows <-chan []interface{}) error {
+	for row := range rows {
+		if err := b.Add(row...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush sends the pending batch to the server.
+func (b *BulkInserter) Flush() error {
+	if b.pending == 0 {
+		return nil
+	}
+
+	qr, err := b.conn.FetchAll(b.statement(b.policy), 0)
+	if err != nil && b.policy == DupRetryIgnore && sqldb.IsDupEntry(err) {
+		qr, err = b.conn.FetchAll(b.statement(DupIgnore), 0)
+	}
+	if err != nil {
+		return err
+	}
+	b.statements++
+	b.rows += b.pending
+	b.rowsAffected += qr.RowsAffected
+	b.values.Reset()
+	b.pending = 0
+	return nil
+}
+
+// Rows returns the number of rows flushed.
+func (b *BulkInserter) Rows() int {
+	return b.rows
+}
+
+// Statements returns the number of statements executed.
+func (b *BulkInserter) Statements() int {
+	return b.statements
+}
+
+// RowsAffected returns the total affected rows reported by server.
+func (b *BulkInserter) RowsAffected() uint64 {
+	return b.rowsAffected
+}
+
+func (b *BulkInserter) statement(policy DupPolicy) string {
+	return b.prefix(policy) + b.values.String() + b.suffix(policy)
+}
+
+// overhead returns the bytes of the statement besides the values, the command byte and the longest prefix
+// and suffix the batch can be sent with, like the INSERT IGNORE of the DupRetryIgnore retry.
+func (b *BulkInserter) overhead() int {
+	policy := b.policy
+	if policy == DupRetryIgnore {
+		policy = DupIgnore
+	}
+	return 1 + len(b.prefix(policy)) + len(b.suffix(policy))
+}
+
+func (b *BulkInserter) prefix(policy DupPolicy) string {
+	var buf bytes.Buffer
+	switch policy {
+	case DupIgnore:
+		buf.WriteString("INSERT IGNORE INTO ")
+	case DupReplace:
+		buf.WriteString("REPLACE INTO ")
+	default:
+		buf.WriteString("INSERT INTO ")
+	}
+	buf.WriteString(quoteTable(b.table))
+	buf.WriteByte('(')
+	for i, col := range b.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(escape.QuoteIdentifier(col))
+	}
+	buf.WriteString(") VALUES ")
+	return buf.String()
+}
+
+func (b *BulkInserter) suffix(policy DupPolicy) string {
+	if policy != DupUpdate {
+		return ""
+	}
+	var buf bytes.Buffer
+	buf.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for i, col := range b.columns {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		quoted := escape.QuoteIdentifier(col)
+		fmt.Fprintf(&buf, "%s=VALUES(%s)", quoted, quoted)
+	}
+	return buf.String()
+}
+
+// quoteTable quotes the table name, the db.tbl is quoted as two identifiers.
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = escape.QuoteIdentifier(part)
+	}
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,130 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkInserter(t *testing.T) {
+	result := &sqltypes.Result{RowsAffected: 2}
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	client, err := NewConn("mock", "mock", address, "test", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Batch by the max packet size.
+	{
+		q1 := "INSERT INTO `t1`(`a`,`b`) VALUES (1,'x'),(2,'y\\'s')"
+		q2 := "INSERT INTO `t1`(`a`,`b`) VALUES (3,NULL)"
+		th.AddQuery(q1, result)
+		th.AddQuery(q2, &sqltypes.Result{RowsAffected: 1})
+
+		bulk := NewBulkInserter(client, "t1", "a", "b")
+		bulk.SetMaxPacket(len(q1) + 1)
+		assert.Nil(t, bulk.Add(1, "x"))
+		assert.Nil(t, bulk.Add(2, "y's"))
+		assert.Nil(t, bulk.Add(3, nil))
+		assert.Nil(t, bulk.Flush())
+		assert.Equal(t, 3, bulk.Rows())
+		assert.Equal(t, 2, bulk.Statements())
+		assert.Equal(t, uint64(3), bulk.RowsAffected())
+		assert.Equal(t, 1, th.GetQueryCalledNum(q1))
+		assert.Equal(t, 1, th.GetQueryCalledNum(q2))
+
+		// Too large row.
+		assert.NotNil(t, bulk.Add(4, "a very long string exceeds the packet size"))
+		// Values count mismatch.
+		assert.NotNil(t, bulk.Add(4))
+	}
+
+	// Batch by the max rows, from the channel.
+	{
+		q1 := "REPLACE INTO `t2`(`a`) VALUES (1),(2)"
+		q2 := "REPLACE INTO `t2`(`a`) VALUES (3)"
+		th.AddQuery(q1, result)
+		th.AddQuery(q2, result)
+
+		bulk := NewBulkInserter(client, "t2", "a")
+		bulk.SetMaxRows(2)
+		bulk.SetDupPolicy(DupReplace)
+		rows := make(chan []interface{}, 3)
+		for i := 1; i <= 3; i++ {
+			rows <- []interface{}{i}
+		}
+		close(rows)
+		assert.Nil(t, bulk.AddRows(rows))
+		assert.Nil(t, bulk.Flush())
+		assert.Equal(t, 2, bulk.Statements())
+		assert.Equal(t, 1, th.GetQueryCalledNum(q1))
+		assert.Equal(t, 1, th.GetQueryCalledNum(q2))
+	}
+
+	// On duplicate key update.
+	{
+		q := "INSERT INTO `t3`(`a`,`b`) VALUES (1,2) ON DUPLICATE KEY UPDATE `a`=VALUES(`a`),`b`=VALUES(`b`)"
+		th.AddQuery(q, result)
+
+		bulk := NewBulkInserter(client, "t3", "a", "b")
+		bulk.SetDupPolicy(DupUpdate)
+		assert.Nil(t, bulk.Add(1, 2))
+		assert.Nil(t, bulk.Flush())
+		assert.Equal(t, 1, th.GetQueryCalledNum(q))
+	}
+
+	// Retry on duplicate.
+	{
+		q := "INSERT INTO `t4`(`a`) VALUES (1),(1)"
+		qi := "INSERT IGNORE INTO `t4`(`a`) VALUES (1),(1)"
+		th.AddQueryError(q, sqldb.NewSQLError1(sqldb.ER_DUP_ENTRY, "23000", "Duplicate entry '%s' for key '%s'", "1", "PRIMARY"))
+		th.AddQuery(qi, &sqltypes.Result{RowsAffected: 1})
+
+		bulk := NewBulkInserter(client, "t4", "a")
+		assert.Nil(t, bulk.Add(1))
+		assert.Nil(t, bulk.Add(1))
+		err := bulk.Flush()
+		assert.Equal(t, "Duplicate entry '1' for key 'PRIMARY' (errno 1062) (sqlstate 23000)", err.Error())
+
+		bulk.SetDupPolicy(DupRetryIgnore)
+		assert.Nil(t, bulk.Flush())
+		assert.Equal(t, uint64(1), bulk.RowsAffected())
+		assert.Equal(t, 1, th.GetQueryCalledNum(qi))
+	}
+
+	// The batch of the retry fits the max packet with the INSERT IGNORE, the db.tbl is quoted by the parts.
+	{
+		q1 := "INSERT INTO `db`.`t5`(`a`) VALUES (1)"
+		q2 := "INSERT INTO `db`.`t5`(`a`) VALUES (2)"
+		th.AddQuery(q1, &sqltypes.Result{RowsAffected: 1})
+		th.AddQuery(q2, &sqltypes.Result{RowsAffected: 1})
+
+		bulk := NewBulkInserter(client, "db.t5", "a")
+		bulk.SetDupPolicy(DupRetryIgnore)
+		// The INSERT of both rows fits, the INSERT IGNORE of them doesn't.
+		bulk.SetMaxPacket(1 + len("INSERT IGNORE INTO `db`.`t5`(`a`) VALUES (1)"))
+		assert.Nil(t, bulk.Add(1))
+		assert.Nil(t, bulk.Add(2))
+		assert.Nil(t, bulk.Flush())
+		assert.Equal(t, 2, bulk.Statements())
+		assert.Equal(t, 1, th.GetQueryCalledNum(q1))
+		assert.Equal(t, 1, th.GetQueryCalledNum(q2))
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+// maxWatchPending is the most bytes the watch keeps before it gives up.
+const maxWatchPending = 64 * 1024
+
+// watchedConn is the connection of the session, it can watch the client closing while a handler runs.
+// The bytes read by the watch are kept and returned first by the next Read, so a pipelined command is not lost.
+type watchedConn struct {
+	// The bytes read, it's first for the 64-bit alignment of the atomic.
+	bytesRead uint64
+
+	net.Conn
+	mu      sync.Mutex
+	pending []byte
+}
+
+func newWatchedConn(conn net.Conn) *watchedConn {
+	return &watchedConn{Conn: conn}
+}
+
+func (c *watchedConn) Read(b []byte) (int, error) {
+	c.mu.Lock()
+	if len(c.pending) > 0 {
+		n := copy(b, c.pending)
+		c.pending = c.pending[n:]
+		c.mu.Unlock()
+		atomic.AddUint64(&c.bytesRead, uint64(n))
+		return n, nil
+	}
+	c.mu.Unlock()
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.bytesRead, uint64(n))
+	return n, err
+}
+
+// watch reads the connection in a goroutine and calls the cancel once it's closed by the client,
+// the returned func stops the watch by the read deadline and waits for the goroutine.
+func (c *watchedConn) watch(cancel context.CancelFunc) func() {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The COM_QUIT is sent before the close, so keep reading until the EOF.
+		b := make([]byte, 4096)
+		for {
+			n, err := c.Conn.Read(b)
+			c.mu.Lock()
+			c.pending = append(c.pending, b[:n]...)
+			size := len(c.pending)
+			c.mu.Unlock()
+			if err != nil {
+				if ne, ok := err.(net.Error); !ok || !ne.Timeout() {
+					cancel()
+				}
+				return
+			}
+			// The client keeps sending the pipelined commands, it's alive.
+			if size >= maxWatchPending {
+				return
+			}
+		}
+	}()
+	return func() {
+		c.Conn.SetReadDeadline(time.Now())
+		<-done
+		c.Conn.SetReadDeadline(time.Time{})
+	}
+}
+
+// Context returns the context of the running statement, it's canceled once the client disconnects
+// before the handler returns or the statement is killed, and it has the deadline of the max execution time.
+// The long queries should stop by it. It's context.Background() between the statements.
+func (s *Session) Context() context.Context {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.ctx == nil {
+		return context.Background()
+	}
+	return s.ctx
+}
+
+// executionTimeout returns the max execution time of the statement, 0 if unlimited.
+// It's the MAX_EXECUTION_TIME hint or the max_execution_time of the session, it applies to the SELECT only.
+func (s *Session) executionTimeout(query string) time.Duration {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return 0
+	}
+	if ms, ok := sqlparser.MaxExecutionTime(query); ok {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if v, ok := s.SystemVariable("max_execution_time"); ok {
+		if ms, err := v.ParseUint64(); err == nil {
+			return time.Duration(ms) * time.Millisecond
+		}
+	}
+	return 0
+}
+
+// interruptError returns the error the running statement was killed by, nil if not.
+// It's the ER_OUT_OF_RESOURCES of the memory limit or the ER_QUERY_TIMEOUT of the max execution time.
+func (s *Session) interruptError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.memErr != nil {
+		return s.memErr
+	}
+	if s.ctx != nil && s.ctx.Err() == context.DeadlineExceeded {
+		return sqldb.NewSQLError(sqldb.ER_QUERY_TIMEOUT, "")
+	}
+	return nil
+}
+
+// beginStatement sets the context of the statement and watches the client while the handler runs,
+// the context is timed out by the timeout if it's not 0.
+// The returned func must be called after the handler returns, it returns the interruptError of the statement.
+func (s *Session) beginStatement(timeout time.Duration) func() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	s.mu.Lock()
+	s.ctx, s.cancel = ctx, cancel
+	s.resultWritten = false
+	s.mu.Unlock()
+
+	stop := s.watcher.watch(cancel)
+	end := func() error {
+		stop()
+		err := s.interruptError()
+		cancel()
+		s.mu.Lock()
+		s.ctx, s.cancel, s.endStmt = nil, nil, nil
+		s.mu.Unlock()
+		return err
+	}
+	s.mu.Lock()
+	s.endStmt = end
+	s.mu.Unlock()
+	return end
+}
+
+// resultSent checks whether a result of the running command was written to the client.
+func (s *Session) resultSent() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resultWritten
+}
+
+// resetResult clears the result written by the former command.
+func (s *Session) resetResult() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resultWritten = false
+}
+
+// abortStatement ends the running statement whose handler panicked, the watch of the client is stopped
+// before the next command is read.
+func (s *Session) abortStatement() {
+	s.mu.RLock()
+	end := s.endStmt
+	s.mu.RUnlock()
+	if end != nil {
+		end()
+	}
+}
@@ -0,0 +1,126 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// HandshakePolicy is the minimum protocol security level of the clients, the ones below it are refused
+// with the descriptive errors. The clients without the CLIENT_PROTOCOL_41 or of the mysql_old_password
+// are always refused.
+type HandshakePolicy struct {
+	// RequireSecureConnection refuses the clients without the CLIENT_SECURE_CONNECTION,
+	// they send the NUL terminated scramble.
+	RequireSecureConnection bool
+
+	// RequirePluginAuth refuses the clients without the CLIENT_PLUGIN_AUTH, they can't be switched to the
+	// auth plugin of the server.
+	RequirePluginAuth bool
+}
+
+// required returns the capabilities the clients must set.
+func (p HandshakePolicy) required() uint32 {
+	required := proto.RequiredClientCapability
+	if p.RequireSecureConnection {
+		required |= sqldb.CLIENT_SECURE_CONNECTION
+	}
+	if p.RequirePluginAuth {
+		required |= sqldb.CLIENT_PLUGIN_AUTH
+	}
+	return required
+}
+
+// deprecatedCapability are the capabilities whose absence is the deprecated insecure handshake.
+const deprecatedCapability = sqldb.CLIENT_SECURE_CONNECTION | sqldb.CLIENT_PLUGIN_AUTH
+
+// SetHandshakePolicy sets the minimum protocol security level of the coming sessions.
+func (l *Listener) SetHandshakePolicy(policy HandshakePolicy) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handshakePolicy = policy
+}
+
+// HandshakePolicy returns the minimum protocol security level of the sessions.
+func (l *Listener) HandshakePolicy() HandshakePolicy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.handshakePolicy
+}
+
+// handshakeError returns the error the client is refused with by the error of the handshake response.
+func handshakeError(err error) *sqldb.SQLError {
+	var ce *proto.CapabilityError
+	if errors.As(err, &ce) && ce.Reason == proto.CapabilityMissing {
+		return sqldb.NewSQLError(sqldb.ER_HANDSHAKE_ERROR, "Bad handshake: the server requires the clients of the %s", sqldb.CapabilityString(ce.Flags))
+	}
+	return sqldb.NewSQLError(sqldb.ER_HANDSHAKE_ERROR, "")
+}
+
+// SetStrictCapabilities rejects the coming sessions whose handshake responses are mis-negotiated with the
+// ER_HANDSHAKE_ERROR, like the CLIENT_SSL not sent over the TLS or the unknown charset.
+// They are only warned if it's off, the default.
+func (l *Listener) SetStrictCapabilities(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.strictCapabilities = on
+}
+
+func (l *Listener) strictCapability() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.strictCapabilities
+}
+
+// checkCapability logs the capabilities negotiated and validates the handshake response of the session,
+// the error is sent to the client if the session is rejected.
+func (l *Listener) checkCapability(session *Session) error {
+	log := l.log
+	server, client := session.greeting.Capability, session.auth.ClientFlags()
+	log.Debug("server.session[%v].capability.server[%s].client[%s].negotiated[%s]", session.ID(),
+		sqldb.CapabilityString(server), sqldb.CapabilityString(client), sqldb.CapabilityString(session.capabilities()))
+	if unknown := sqldb.UnknownCapability(client); unknown != 0 {
+		log.Warning("server.session[%v].unknown.capability[%s]", session.ID(), sqldb.CapabilityString(unknown))
+	}
+
+	// The clients below the policy are always refused.
+	if err := proto.CheckClientCapability(client, l.HandshakePolicy().required()); err != nil {
+		log.Warning("server.session[%v].capability.below.handshake.policy:%v", session.ID(), err)
+		session.writeErrFromError(handshakeError(err))
+		return err
+	}
+	if insecure := deprecatedCapability &^ client; insecure != 0 {
+		log.Warning("server.session[%v].deprecated.insecure.handshake.without[%s]", session.ID(), sqldb.CapabilityString(insecure))
+	}
+
+	var err error
+	if client&sqldb.CLIENT_SSL > 0 && session.TLSState() == nil {
+		err = &proto.CapabilityError{Reason: proto.CapabilityUnexpected, Flags: sqldb.CLIENT_SSL}
+	} else if _, ok := sqldb.LookupCollation(uint16(session.Charset())); !ok {
+		err = sqldb.NewSQLError(sqldb.ER_UNKNOWN_COLLATION, "Unknown collation: '%d'", session.Charset())
+	}
+	if err == nil {
+		return nil
+	}
+	if !l.strictCapability() {
+		log.Warning("server.session[%v].capability.mis-negotiated:%v", session.ID(), err)
+		return nil
+	}
+	log.Warning("server.session[%v].capability.rejected:%v", session.ID(), err)
+	if se, ok := err.(*sqldb.SQLError); ok {
+		session.writeErrFromError(se)
+	} else {
+		session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_HANDSHAKE_ERROR, "Bad handshake: %v", err))
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"net"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerStrictCapabilities(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, StrictCapabilities: true}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	// connect sends the handshake response of the capability and the charset, returns the answer.
+	connect := func(capability uint32, charset uint8) []byte {
+		conn, err := net.Dial("tcp", svr.Addr())
+		assert.Nil(t, err)
+		defer conn.Close()
+		packets := packet.NewPackets(conn)
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		greeting := proto.NewGreeting(0)
+		assert.Nil(t, greeting.UnPack(data))
+		assert.Nil(t, packets.Write(proto.NewAuth().Pack(capability, charset, "mock", "mock", greeting.Salt, "")))
+		data, err = packets.Next()
+		assert.Nil(t, err)
+		return data
+	}
+
+	// The unknown bits are warned only.
+	data := connect(proto.DefaultClientCapability|1<<28, sqldb.CharacterSetUtf8)
+	assert.Equal(t, proto.OK_PACKET, data[0])
+
+	// The CLIENT_SSL without the SSLRequest.
+	data = connect(proto.DefaultClientCapability|sqldb.CLIENT_SSL, sqldb.CharacterSetUtf8)
+	err = packet.NewPackets(nil).ParseERR(data)
+	assert.Equal(t, "Bad handshake: capability.unexpected[CLIENT_SSL] (errno 1043) (sqlstate 08S01)", err.Error())
+
+	// The unknown charset.
+	data = connect(proto.DefaultClientCapability, 0)
+	err = packet.NewPackets(nil).ParseERR(data)
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_COLLATION), err.(*sqldb.SQLError).Num)
+
+	// They are warned only if it's not strict.
+	svr.SetStrictCapabilities(false)
+	data = connect(proto.DefaultClientCapability|sqldb.CLIENT_SSL, 0)
+	assert.Equal(t, proto.OK_PACKET, data[0])
+}
+
+func TestListenerHandshakePolicy(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, HandshakePolicy: HandshakePolicy{RequireSecureConnection: true}}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	assert.Equal(t, HandshakePolicy{RequireSecureConnection: true}, svr.HandshakePolicy())
+
+	// connect sends the handshake response of the capability, returns the answer.
+	connect := func(capability uint32) []byte {
+		conn, err := net.Dial("tcp", svr.Addr())
+		assert.Nil(t, err)
+		defer conn.Close()
+		packets := packet.NewPackets(conn)
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		greeting := proto.NewGreeting(0)
+		assert.Nil(t, greeting.UnPack(data))
+		assert.Nil(t, packets.Write(proto.NewAuth().Pack(capability, sqldb.CharacterSetUtf8, "mock", "mock", greeting.Salt, "")))
+		data, err = packets.Next()
+		assert.Nil(t, err)
+		return data
+	}
+
+	data := connect(proto.DefaultClientCapability)
+	assert.Equal(t, proto.OK_PACKET, data[0])
+
+	// The client without the CLIENT_SECURE_CONNECTION.
+	insecure := proto.DefaultClientCapability &^ sqldb.CLIENT_SECURE_CONNECTION
+	err = packet.NewPackets(nil).ParseERR(connect(insecure))
+	assert.Equal(t, "Bad handshake: the server requires the clients of the CLIENT_SECURE_CONNECTION (errno 1043) (sqlstate 08S01)", err.Error())
+
+	// The client without the CLIENT_PLUGIN_AUTH.
+	svr.SetHandshakePolicy(HandshakePolicy{RequirePluginAuth: true})
+	data = connect(insecure)
+	assert.Equal(t, proto.OK_PACKET, data[0])
+	err = packet.NewPackets(nil).ParseERR(connect(proto.DefaultClientCapability &^ sqldb.CLIENT_PLUGIN_AUTH))
+	assert.Equal(t, uint16(sqldb.ER_HANDSHAKE_ERROR), err.(*sqldb.SQLError).Num)
+
+	// The client without the CLIENT_PROTOCOL_41 is always refused.
+	svr.SetHandshakePolicy(HandshakePolicy{})
+	err = packet.NewPackets(nil).ParseERR(connect(proto.DefaultClientCapability &^ sqldb.CLIENT_PROTOCOL_41))
+	assert.Equal(t, "Bad handshake: the server requires the clients of the CLIENT_PROTOCOL_41 (errno 1043) (sqlstate 08S01)", err.Error())
+}
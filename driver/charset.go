@@ -0,0 +1,114 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// initCharset sets the character_set_client, character_set_connection and character_set_results
+// by the charset of the handshake, like the MySQL server does.
+func (s *Session) initCharset() {
+	c, ok := sqldb.LookupCollation(uint16(s.Charset()))
+	if !ok {
+		return
+	}
+	for _, n := range []string{"character_set_client", "character_set_connection", "character_set_results"} {
+		s.SetSystemVariable(n, sqltypes.NewVarChar(c.Charset))
+	}
+	s.SetSystemVariable("collation_connection", sqltypes.NewVarChar(c.Name))
+}
+
+// fieldCharset returns the collation id of the field metadata,
+// the text columns are in the collation_connection and the others are binary.
+func (s *Session) fieldCharset(typ querypb.Type) uint32 {
+	if !sqltypes.IsText(typ) {
+		return sqldb.CharacterSetBinary
+	}
+	if v, ok := s.SystemVariable("collation_connection"); ok {
+		if c, ok := sqldb.LookupCollationByName(v.String()); ok {
+			return uint32(c.ID)
+		}
+	}
+	return sqldb.DefaultCollation
+}
+
+// charsetEncoding returns the Encoding of the charset variable, nil if the bytes pass through.
+func (s *Session) charsetEncoding(variable string) (string, sqldb.Encoding) {
+	v, ok := s.SystemVariable(variable)
+	if !ok || v.IsNull() {
+		return "", nil
+	}
+	return v.String(), sqldb.LookupEncoding(v.String())
+}
+
+// decodeQuery transcodes the query of the character_set_client to the UTF-8 before the dispatch.
+func (s *Session) decodeQuery(query string) (string, error) {
+	charset, enc := s.charsetEncoding("character_set_client")
+	if enc == nil {
+		return query, nil
+	}
+	decoded, err := enc.Decode(common.StringToBytes(query))
+	if err != nil {
+		prefix := query
+		if len(prefix) > 64 {
+			prefix = prefix[:64]
+		}
+		return "", sqldb.NewSQLError(sqldb.ER_INVALID_CHARACTER_STRING, "Invalid %s character string: '%s'", charset, prefix)
+	}
+	return string(decoded), nil
+}
+
+// resultsEncoder returns the encoder of the text columns to the character_set_results,
+// nil if the rows pass through untranslated.
+func (s *Session) resultsEncoder(fields []*querypb.Field) func(i int, val []byte) []byte {
+	_, enc := s.charsetEncoding("character_set_results")
+	if enc == nil {
+		return nil
+	}
+
+	text := make([]bool, len(fields))
+	for i, f := range fields {
+		text[i] = sqltypes.IsText(f.Type) && f.Charset != sqldb.CharacterSetBinary
+	}
+	return func(i int, val []byte) []byte {
+		if i < len(text) && text[i] {
+			return enc.Encode(val)
+		}
+		return val
+	}
+}
+
+// SetNames changes the connection charset by the SET NAMES, the collation is optional.
+// The names are checked by the collation catalog before sent.
+func (c *conn) SetNames(charset, collation string) error {
+	cs, ok := sqldb.LookupCharset(charset)
+	if !ok {
+		return fmt.Errorf("driver.set.names.unknown.charset[%s]", charset)
+	}
+	query := fmt.Sprintf("SET NAMES %s", cs.Name)
+	if collation != "" {
+		coll, ok := sqldb.LookupCollationByName(collation)
+		if !ok {
+			return fmt.Errorf("driver.set.names.unknown.collation[%s]", collation)
+		}
+		if coll.Charset != cs.Name {
+			return fmt.Errorf("driver.set.names.collation[%s].not.valid.for.charset[%s]", coll.Name, cs.Name)
+		}
+		query += " COLLATE " + coll.Name
+	}
+	return c.Exec(query)
+}
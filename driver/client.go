@@ -11,6 +11,8 @@ package driver
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
 	"net"
 	"strings"
 	"time"
@@ -26,6 +28,9 @@ import (
 
 var _ Conn = &conn{}
 
+// Conn is the client connection.
+// The errors are the server errors of the ERR packets or the client errors(the CR_ codes, see sqldb.IsClientError)
+// of the network and protocol failures, the NextPacket and WriteCommand return the raw errors.
 type Conn interface {
 	Ping() error
 	Quit()
@@ -34,11 +39,45 @@ type Conn interface {
 	Cleanup()
 	NextPacket() ([]byte, error)
 
+	// WriteCommand writes the command packet, the response is read by NextPacket.
+	WriteCommand(command byte, data []byte) error
+
 	// ConnectionID is the connection id at greeting.
 	ConnectionID() uint32
 
+	// Addr is the backend address this connection is talking to.
+	Addr() string
+
+	// ServerVersion is the server version at greeting.
+	ServerVersion() *proto.ServerVersion
+
 	// Query get the row cursor.
 	Query(sql string) (Rows, error)
+
+	// QueryWithAttributes gets the row cursor of the query carrying the query attributes,
+	// they are dropped if the CLIENT_QUERY_ATTRIBUTES isn't negotiated.
+	QueryWithAttributes(sql string, attrs []proto.QueryAttribute) (Rows, error)
+
+	// QueryContext gets the row cursor of the query killed by the KILL QUERY once the ctx is done.
+	QueryContext(ctx context.Context, sql string) (Rows, error)
+
+	// Prepare prepares the statement of the ? placeholders on the server.
+	Prepare(query string) (*Stmt, error)
+
+	// PrepareNamed prepares the statement of the :name placeholders on the server.
+	PrepareNamed(query string) (*NamedStmt, error)
+
+	// SetRetryPolicy sets the retry policy of the Query, Exec and FetchAll, nil disables it.
+	SetRetryPolicy(policy *RetryPolicy)
+
+	// SetStmtCacheSize sets the capacity of the prepared statements cache, zero disables it.
+	SetStmtCacheSize(capacity int) error
+
+	// StmtCacheLen returns the count of the cached prepared statements.
+	StmtCacheLen() int
+
+	// Stats returns the counters of the queries, the bytes and the errors.
+	Stats() ConnStats
 	Exec(sql string) error
 
 	// FetchAll fetchs all results.
@@ -46,13 +85,63 @@ type Conn interface {
 
 	// FetchAllWithFunc fetchs all results but the row cursor can be interrupted by the fn.
 	FetchAllWithFunc(sql string, maxrows int, fn Func) (*sqltypes.Result, error)
+
+	// FetchAllInto fetchs all results into the slice of the structs the dst points to, see ScanResult.
+	FetchAllInto(sql string, dst interface{}) error
+
+	// Transaction.
+	Begin() error
+	Commit() error
+	Rollback() error
+
+	// Transaction runs the fn in a transaction, commits if fn returns nil, otherwise rolls back.
+	Transaction(fn func(tx *Tx) error) error
+
+	// InTransaction checks the SERVER_STATUS_IN_TRANS flag reported by the server.
+	InTransaction() bool
+
+	// Status returns the server status flags from the last OK/EOF packet.
+	Status() uint16
+
+	// WarningCount returns the warning count from the last OK/EOF packet.
+	WarningCount() uint16
+
+	// Warnings fetches the warnings of the last statement by the SHOW WARNINGS.
+	Warnings() ([]Warning, error)
+
+	// SetNames changes the connection charset by the SET NAMES, the collation is optional.
+	SetNames(charset, collation string) error
+
+	// Schema introspection.
+	Databases() ([]string, error)
+	Tables(schema string) ([]*Table, error)
+	Columns(schema string, table string) ([]*Column, error)
+	Indexes(schema string, table string) ([]*Index, error)
 }
 
 type conn struct {
+	address  string
 	netConn  net.Conn
 	auth     *proto.Auth
 	greeting *proto.Greeting
 	packets  *packet.Packets
+
+	// status and warnings are from the last OK/EOF packet.
+	status   uint16
+	warnings uint16
+
+	// For reconnect.
+	config    *ClientConfig
+	reconnect *reconnector
+
+	// stmts is the prepared statements cache, nil if it's disabled.
+	stmts *stmtCache
+
+	// policy retries the queries, nil if it's disabled.
+	policy *RetryPolicy
+
+	// stats are the counters of the Stats.
+	stats *connStats
 }
 
 func (c *conn) handleErrorPacket(data []byte) error {
@@ -62,15 +151,16 @@ func (c *conn) handleErrorPacket(data []byte) error {
 	return nil
 }
 
-func (c *conn) handShake(username, password, database, charset string) error {
+func (c *conn) handShake(cfg *ClientConfig, address string, attrs map[string]string) error {
 	var err error
 	var data []byte
+	capability := cfg.Capability()
 
 	//Parses the initial handshake from the server.
 	{
 		// greeting read
 		if data, err = c.packets.Next(); err != nil {
-			return err
+			return readError(err, "at 'reading initial communication packet'")
 		}
 
 		// check greeting packet
@@ -80,7 +170,7 @@ func (c *conn) handShake(username, password, database, charset string) error {
 
 		// unpack greeting packet
 		if err = c.greeting.UnPack(data); err != nil {
-			return err
+			return malformedError(err)
 		}
 
 		// check greating Capability
@@ -91,23 +181,47 @@ func (c *conn) handShake(username, password, database, charset string) error {
 	}
 
 	{
-		cs, ok := sqldb.CharacterSetMap[strings.ToLower(charset)]
+		cs, ok := sqldb.CharacterSetMap[strings.ToLower(cfg.Charset)]
 		if !ok {
-			cs = sqldb.CharacterSetUtf8
+			// The collation name like utf8mb4_unicode_ci is also accepted.
+			cs = sqldb.DefaultCollation
+			if c, ok := sqldb.LookupCollationByName(cfg.Charset); ok && c.ID <= 0xff {
+				cs = uint8(c.ID)
+			}
+		}
+		// Switch to TLS before the credentials are sent.
+		if tlsConfig := cfg.tlsConfig(address); tlsConfig != nil {
+			if c.greeting.Capability&sqldb.CLIENT_SSL == 0 {
+				return sqldb.NewSQLError(sqldb.CR_SSL_CONNECTION_ERROR, "SSL connection error: %s", "SSL is required but the server doesn't support it")
+			}
+			capability |= sqldb.CLIENT_SSL
+			if err = c.startTLS(tlsConfig, capability, cs); err != nil {
+				return err
+			}
+		}
+		// Only send the attributes if the server supports.
+		if c.greeting.Capability&sqldb.CLIENT_CONNECT_ATTRS > 0 {
+			c.auth.SetConnectAttrs(attrs)
 		}
 		// auth pack
 		data := c.auth.Pack(
-			proto.DefaultClientCapability,
+			capability,
 			cs,
-			username,
-			password,
+			cfg.User,
+			cfg.Passwd,
 			c.greeting.Salt,
-			database,
+			cfg.DBName,
 		)
 
 		// auth write
 		if err = c.packets.Write(data); err != nil {
-			return err
+			return writeError(err)
+		}
+		c.packets.SetCapability(capability & c.greeting.Capability)
+		if cfg.Log != nil {
+			cfg.Log.Debug("driver.conn[%s].capability.server[%s].client[%s].negotiated[%s]", address,
+				sqldb.CapabilityString(c.greeting.Capability), sqldb.CapabilityString(capability),
+				sqldb.CapabilityString(capability&c.greeting.Capability))
 		}
 
 		// clean the authreponse bytes to improve the gc pause.
@@ -117,25 +231,142 @@ func (c *conn) handShake(username, password, database, charset string) error {
 	{
 		// read
 		if data, err = c.packets.Next(); err != nil {
-			return err
+			return readError(err, "at 'reading authorization packet'")
 		}
 
 		if err = c.handleErrorPacket(data); err != nil {
 			return err
 		}
+
+		// The server asks another plugin.
+		if data[0] == proto.AUTH_SWITCH_PACKET {
+			if data, err = c.authSwitch(data, cfg.Passwd); err != nil {
+				return err
+			}
+		}
+
+		var ok *proto.OK
+		if ok, err = c.packets.ParseOK(data); err != nil {
+			return malformedError(err)
+		}
+		c.setStatus(ok.StatusFlags, ok.Warnings)
 	}
 	return nil
 }
 
+// startTLS sends the SSLRequest and switches the connection to TLS.
+func (c *conn) startTLS(cfg *tls.Config, capability uint32, charset uint8) error {
+	if err := c.packets.Write(c.auth.PackSSLRequest(capability, charset)); err != nil {
+		return writeError(err)
+	}
+	return c.packets.Upgrade(func(nc net.Conn) (net.Conn, error) {
+		tc := tls.Client(nc, cfg)
+		if err := tc.Handshake(); err != nil {
+			return nil, sqldb.WrapSQLError(err, sqldb.CR_SSL_CONNECTION_ERROR, "SSL connection error: %v", err)
+		}
+		return tc, nil
+	})
+}
+
+// authSwitch answers the auth switch request, returns the packet after the answer.
+func (c *conn) authSwitch(data []byte, password string) ([]byte, error) {
+	req, err := proto.UnPackAuthSwitchRequest(data)
+	if err != nil {
+		return nil, malformedError(err)
+	}
+	resp, err := req.Response(password, c.greeting.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.packets.Write(resp); err != nil {
+		return nil, writeError(err)
+	}
+	if data, err = c.packets.Next(); err != nil {
+		return nil, readError(err, "at 'reading authorization packet'")
+	}
+	if err = c.handleErrorPacket(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+const (
+	// DefaultConnectTimeout is the timeout of each connect attempt.
+	DefaultConnectTimeout = time.Duration(30) * time.Second
+)
+
 // NewConn used to create a new client connection.
-// The timeout is 30 seconds.
+// The address can be a comma separated list like "host1:3306,host2:3306",
+// the backends are tried in order until one of them finishes the handshake.
+// The timeout is 30 seconds per attempt.
 func NewConn(username, password, address, database, charset string) (*conn, error) {
-	var err error
-	c := &conn{}
-	timeout := time.Duration(30) * time.Second
-	if c.netConn, err = net.DialTimeout("tcp", address, timeout); err != nil {
+	return NewConnWithAddrs(username, password, strings.Split(address, ","), database, charset, DefaultConnectTimeout)
+}
+
+// NewConnWithAddrs used to create a new client connection with failover.
+// Every address is tried in order with its own timeout, a dial or handshake
+// error moves on to the next one, the last error is returned if all failed.
+func NewConnWithAddrs(username, password string, addrs []string, database, charset string, timeout time.Duration) (*conn, error) {
+	cfg := &ClientConfig{
+		User:    username,
+		Passwd:  password,
+		Addrs:   addrs,
+		DBName:  database,
+		Charset: charset,
+		Timeout: timeout,
+	}
+	return newConn(cfg.withDefaults())
+}
+
+// NewConnWithConfig used to create a new client connection by the config, the config is validated first.
+// The addresses are tried in order like the NewConnWithAddrs.
+func NewConnWithConfig(cfg *ClientConfig) (*conn, error) {
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
+	return newConn(cfg.withDefaults())
+}
+
+func newConn(cfg *ClientConfig) (*conn, error) {
+	var c *conn
+
+	err := errors.New("driver.conn.addrs.can.not.be.empty")
+	attrs := DefaultConnectAttrs()
+	for k, v := range cfg.ConnectAttrs {
+		attrs[k] = v
+	}
+	for _, address := range cfg.Addrs {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if c, err = connect(cfg, address, attrs); err == nil {
+			config := *cfg
+			c.config = &config
+			if cfg.StmtCacheSize > 0 {
+				c.stmts = newStmtCache(cfg.StmtCacheSize)
+			}
+			return c, nil
+		}
+		if cfg.Log != nil {
+			cfg.Log.Warning("driver.conn.connect[%s].error:%v", address, err)
+		}
+		// The password is expired on all the addresses.
+		if sqldb.IsPasswordExpired(err) {
+			break
+		}
+	}
+	return nil, err
+}
+
+func connect(cfg *ClientConfig, address string, attrs map[string]string) (*conn, error) {
+	c := &conn{address: address, stats: &connStats{}}
+	nc, err := net.DialTimeout("tcp", address, cfg.Timeout)
+	if err != nil {
+		return nil, dialError(err, address)
+	}
+	tc := &timeoutConn{Conn: nc}
+	c.netConn = &countingConn{Conn: tc, stats: c.stats}
 	defer func() {
 		if err != nil {
 			c.Cleanup()
@@ -143,19 +374,66 @@ func NewConn(username, password, address, database, charset string) (*conn, erro
 	}()
 	// Set timeouts, make the handshake timeout if the underflying connection blocked.
 	// This timeout only used in handshake, we will disable(set zero time) it at last.
-	c.netConn.SetReadDeadline(time.Now().Add(timeout))
-	defer c.netConn.SetReadDeadline(time.Time{})
+	c.netConn.SetDeadline(time.Now().Add(cfg.Timeout))
+	defer c.netConn.SetDeadline(time.Time{})
 
 	c.auth = proto.NewAuth()
 	c.greeting = proto.NewGreeting(0)
 	c.packets = packet.NewPackets(c.netConn)
-	if err = c.handShake(username, password, database, charset); err != nil {
+	if err = c.handShake(cfg, address, attrs); err != nil {
 		return nil, err
 	}
+	tc.readTimeout, tc.writeTimeout = cfg.ReadTimeout, cfg.WriteTimeout
 	return c, nil
 }
 
+// timeoutConn sets the deadline before every read and write, the zero timeout doesn't.
+type timeoutConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+func (c *timeoutConn) Read(b []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+	}
+	return c.Conn.Read(b)
+}
+
+func (c *timeoutConn) Write(b []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	}
+	return c.Conn.Write(b)
+}
+
 func (c *conn) query(command byte, sql string) (Rows, error) {
+	if command == sqldb.COM_QUERY {
+		return c.queryWithAttributes(sql, nil)
+	}
+	rows, err := c.command(command, common.StringToBytes(sql))
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// queryWithAttributes sends the COM_QUERY, the attributes prefix the query if the CLIENT_QUERY_ATTRIBUTES is negotiated.
+func (c *conn) queryWithAttributes(sql string, attrs []proto.QueryAttribute) (Rows, error) {
+	payload, err := proto.PackComQuery(&proto.ComQuery{Query: sql, Attributes: attrs}, c.packets.Capability())
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.command(sqldb.COM_QUERY, payload)
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// command writes the command and reads the OK or the columns of the resultset, the rows are read by the row cursor.
+func (c *conn) command(command byte, payload []byte) (*TextRows, error) {
 	var ok *proto.OK
 	var myerr, err error
 	var columns []*querypb.Field
@@ -165,88 +443,158 @@ func (c *conn) query(command byte, sql string) (Rows, error) {
 	defer func() {
 		if err != nil {
 			c.Cleanup()
+			c.countCommand(command, err)
+		} else {
+			c.countCommand(command, myerr)
 		}
 	}()
 
 	// Query.
-	if err = c.packets.WriteCommand(command, common.StringToBytes(sql)); err != nil {
-		return nil, err
+	if err = c.packets.WriteCommand(command, payload); err != nil {
+		return nil, writeError(err)
 	}
 
 	// Read column number.
 	ok, colNumber, myerr, err = c.packets.ReadComQueryResponse()
 	if err != nil {
-		return nil, err
+		return nil, readError(err, "during query")
 	}
 	if myerr != nil {
 		return nil, myerr
 	}
 
-	if colNumber > 0 {
+	if colNumber == 0 {
+		c.setStatus(ok.StatusFlags, ok.Warnings)
+	} else {
 		if columns, err = c.packets.ReadColumns(colNumber); err != nil {
-			return nil, err
+			return nil, readError(err, "during query")
 		}
 
 		// Read EOF.
 		if (c.greeting.Capability & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
-			if err = c.packets.ReadEOF(); err != nil {
-				return nil, err
+			if err = c.readEOF(); err != nil {
+				return nil, readError(err, "during query")
 			}
 		}
 	}
 	rows := NewTextRows(c)
+	if c.config != nil && c.config.LazyRows {
+		rows.next, rows.lazy = c.packets.NextReuse, true
+	}
 	rows.rowsAffected = ok.AffectedRows
 	rows.insertID = ok.LastInsertID
+	rows.info = ok.Info
+	rows.stateChanges = ok.SessionStateChanges
 	rows.fields = columns
 	return rows, nil
 }
 
+// readEOF reads the EOF of the column definitions, the status is kept as the one of the resultset terminator.
+func (c *conn) readEOF() error {
+	data, err := c.packets.Next()
+	if err != nil {
+		return err
+	}
+	switch data[0] {
+	case proto.EOF_PACKET:
+		eof, err := proto.UnPackEOF(data)
+		if err != nil {
+			return err
+		}
+		c.setStatus(eof.StatusFlags, eof.Warnings)
+		return nil
+	case proto.ERR_PACKET:
+		return c.packets.ParseERR(data)
+	default:
+		return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "unexpected.eof.packet[%+v]", data)
+	}
+}
+
 // ConnectionID is the connection id at greeting
 func (c *conn) ConnectionID() uint32 {
 	return c.greeting.ConnectionID
 }
 
+// ServerVersion returns the parsed server version at greeting,
+// the flavor is MySQL with zero numbers if the version can't be parsed.
+func (c *conn) ServerVersion() *proto.ServerVersion {
+	v, err := proto.ParseServerVersion(c.greeting.ServerVersion())
+	if err != nil {
+		return &proto.ServerVersion{Flavor: proto.FlavorMySQL, Raw: c.greeting.ServerVersion()}
+	}
+	return v
+}
+
+// Addr returns the active backend address.
+func (c *conn) Addr() string {
+	return c.address
+}
+
 // Query execute the query and return the row iterator
 func (c *conn) Query(sql string) (Rows, error) {
-	return c.query(sqldb.COM_QUERY, sql)
+	var rows Rows
+	err := c.retryQuery(sql, func() error {
+		var err error
+		rows, err = c.query(sqldb.COM_QUERY, sql)
+		return err
+	})
+	return rows, err
 }
 
-func (c *conn) Ping() error {
-	rows, err := c.query(sqldb.COM_PING, "")
-	if err != nil {
+// QueryWithAttributes executes the query carrying the attributes like the trace ids and returns the row iterator,
+// the server gets them only if the ClientConfig.QueryAttributes is set and it supports the CLIENT_QUERY_ATTRIBUTES.
+func (c *conn) QueryWithAttributes(sql string, attrs []proto.QueryAttribute) (Rows, error) {
+	var rows Rows
+	err := c.retryQuery(sql, func() error {
+		var err error
+		rows, err = c.queryWithAttributes(sql, attrs)
 		return err
-	}
+	})
+	return rows, err
+}
 
-	if err := rows.Close(); err != nil {
-		return err
-	}
-	return nil
+func (c *conn) Ping() error {
+	return c.retry(true, func() error {
+		rows, err := c.query(sqldb.COM_PING, "")
+		if err != nil {
+			return err
+		}
+
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		return nil
+	})
 }
 
 func (c *conn) InitDB(db string) error {
-	rows, err := c.query(sqldb.COM_INIT_DB, db)
-	if err != nil {
-		return err
-	}
-
-	if err := rows.Close(); err != nil {
-		return err
-	}
+	return c.retry(true, func() error {
+		rows, err := c.query(sqldb.COM_INIT_DB, db)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		c.config.DBName = db
+		return nil
+	})
 }
 
 // Exec executes the query and drain the results
 func (c *conn) Exec(sql string) error {
-	rows, err := c.query(sqldb.COM_QUERY, sql)
-	if err != nil {
-		return err
-	}
+	return c.retryQuery(sql, func() error {
+		rows, err := c.query(sqldb.COM_QUERY, sql)
+		if err != nil {
+			return err
+		}
 
-	if err := rows.Close(); err != nil {
-		c.Cleanup()
-	}
-	return nil
+		if err := rows.Close(); err != nil {
+			c.Cleanup()
+		}
+		return nil
+	})
 }
 
 func (c *conn) FetchAll(sql string, maxrows int) (*sqltypes.Result, error) {
@@ -258,15 +606,34 @@ func (c *conn) FetchAll(sql string, maxrows int) (*sqltypes.Result, error) {
 type Func func(rows Rows) error
 
 func (c *conn) FetchAllWithFunc(sql string, maxrows int, fn Func) (*sqltypes.Result, error) {
+	var qr *sqltypes.Result
+	err := c.retryQuery(sql, func() error {
+		var err error
+		qr, err = c.fetchAllWithFunc(sql, maxrows, fn)
+		return err
+	})
+	return qr, err
+}
+
+func (c *conn) fetchAllWithFunc(sql string, maxrows int, fn Func) (*sqltypes.Result, error) {
+	iRows, err := c.query(sqldb.COM_QUERY, sql)
+	if err != nil {
+		return nil, err
+	}
+	return c.fetchRows(iRows, maxrows, fn)
+}
+
+// fetchRows fetchs the rows of the cursor to the result, the maxrows is unlimited if it's negative.
+func (c *conn) fetchRows(iRows Rows, maxrows int, fn Func) (*sqltypes.Result, error) {
 	var err error
-	var iRows Rows
 	var qrRow []sqltypes.Value
 	var qrRows [][]sqltypes.Value
 
-	if iRows, err = c.query(sqldb.COM_QUERY, sql); err != nil {
-		return nil, err
+	// The lazy rows are overwritten by the next one, the retained ones are copied.
+	var arena *rowArena
+	if isLazy(iRows) {
+		arena = &rowArena{}
 	}
-
 	for iRows.Next() {
 		// callback check.
 		if err = fn(iRows); err != nil {
@@ -282,6 +649,9 @@ func (c *conn) FetchAllWithFunc(sql string, maxrows int, fn Func) (*sqltypes.Res
 			return nil, err
 		}
 		if qrRow != nil {
+			if arena != nil {
+				qrRow = arena.copy(qrRow)
+			}
 			qrRows = append(qrRows, qrRow)
 		}
 	}
@@ -301,6 +671,10 @@ func (c *conn) FetchAllWithFunc(sql string, maxrows int, fn Func) (*sqltypes.Res
 		RowsAffected: rowsAffected,
 		InsertID:     iRows.LastInsertID(),
 		Rows:         qrRows,
+		Info:         iRows.Info(),
+		StatusFlags:  c.status,
+
+		SessionStateChanges: iRows.SessionStateChanges(),
 	}
 	return qr, err
 }
@@ -310,6 +684,11 @@ func (c *conn) NextPacket() ([]byte, error) {
 	return c.packets.Next()
 }
 
+// WriteCommand writes the command packet with the payload.
+func (c *conn) WriteCommand(command byte, data []byte) error {
+	return c.packets.WriteCommand(command, data)
+}
+
 func (c *conn) Command(command byte) error {
 	rows, err := c.query(command, "")
 	if err != nil {
@@ -331,6 +710,10 @@ func (c *conn) Cleanup() {
 		c.netConn.Close()
 		c.netConn = nil
 	}
+	// The statements are gone with the connection.
+	if c.stmts != nil {
+		c.stmts.purge()
+	}
 }
 
 // Close closes the connection
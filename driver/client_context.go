@@ -0,0 +1,118 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// QueryContext is the Query bound to the ctx: once the ctx is done before the rows are drained,
+// the query is killed by the KILL QUERY of the connection id on a side connection and the rest of the rows
+// are drained by the Close, so the server stops producing the abandoned resultset and the connection stays usable.
+// The rows of the killed query end with the error of the ctx, the query isn't retried.
+// If the side connection fails, the connection is closed instead.
+func (c *conn) QueryContext(ctx context.Context, sql string) (Rows, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	stop := c.watchContext(ctx)
+	rows, err := c.Query(sql)
+	if err != nil {
+		if stop() {
+			return nil, ctx.Err()
+		}
+		return nil, err
+	}
+	return &contextRows{Rows: rows, ctx: ctx, stop: stop}, nil
+}
+
+// watchContext kills the running query once the ctx is done,
+// the stop ends the watch, waits for the kill and returns whether the query was killed.
+func (c *conn) watchContext(ctx context.Context) (stop func() bool) {
+	if ctx.Done() == nil {
+		return func() bool { return false }
+	}
+	// The side connection is of the same server and the user, without the database.
+	cfg := *c.config
+	cfg.Addrs = []string{c.address}
+	cfg.DBName = ""
+	cfg.StmtCacheSize = 0
+	id, nc := c.ConnectionID(), c.netConn
+
+	var killed bool
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+		}
+		killed = true
+		if err := killQuery(&cfg, id); err != nil {
+			if cfg.Log != nil {
+				cfg.Log.Warning("driver.conn[%d].kill.query.error:%v", id, err)
+			}
+			nc.Close()
+		}
+	}()
+
+	var once sync.Once
+	return func() bool {
+		once.Do(func() { close(done) })
+		<-finished
+		return killed
+	}
+}
+
+// killQuery kills the running query of the connection id by the KILL QUERY on a new connection.
+func killQuery(cfg *ClientConfig, id uint32) error {
+	side, err := newConn(cfg)
+	if err != nil {
+		return err
+	}
+	defer side.Close()
+	return side.Exec(fmt.Sprintf("KILL QUERY %d", id))
+}
+
+// contextRows are the rows of the QueryContext, the watch of the ctx stops once they're drained.
+type contextRows struct {
+	Rows
+	ctx    context.Context
+	stop   func() bool
+	killed bool
+}
+
+// Next returns the next row, the watch stops at the end of the rows.
+func (r *contextRows) Next() bool {
+	if r.Rows.Next() {
+		return true
+	}
+	r.killed = r.stop()
+	return false
+}
+
+// Close drains the rest rows, the ones of the killed query are drained until the server stops.
+func (r *contextRows) Close() error {
+	for r.Next() {
+	}
+	return r.LastError()
+}
+
+// LastError returns the error of the ctx if the query was killed.
+func (r *contextRows) LastError() error {
+	if r.killed {
+		return r.ctx.Err()
+	}
+	return r.Rows.LastError()
+}
@@ -0,0 +1,75 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestClientQueryContext(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	// The rows are more than the socket buffers, the server blocks on the writes until the client reads.
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 128))
+	for i := 0; i < 200000; i++ {
+		b.AppendRow(i, strings.Repeat("x", 100))
+	}
+	th.AddQueryStream("SELECT * FROM big", b.Result())
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The rows drained.
+	rows, err := client.QueryContext(context.Background(), "SELECT 1")
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+
+	// Canceled mid-stream.
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err = client.QueryContext(ctx, "SELECT * FROM big")
+	assert.Nil(t, err)
+	for i := 0; i < 10 && rows.Next(); i++ {
+	}
+	cancel()
+	kill := fmt.Sprintf("kill query %d", client.ConnectionID())
+	for th.GetQueryCalledNum(kill) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	assert.Equal(t, context.Canceled, rows.Close())
+	assert.Truef(t, n < 200000-10, "%v", n)
+
+	// The connection is usable after the kill.
+	assert.False(t, client.Closed())
+	_, err = client.FetchAll("SELECT 1", -1)
+	assert.Nil(t, err)
+
+	// Canceled before.
+	_, err = client.QueryContext(ctx, "SELECT 1")
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,338 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	_ Rows = &BinaryRows{}
+	_ Rows = &CursorRows{}
+)
+
+// Stmt is the prepared statement of the client connection, it's not safe for the concurrent use as the Conn.
+type Stmt struct {
+	c *conn
+
+	// ID is the statement id of the server.
+	ID uint32
+
+	// ParamCount is the count of the ? placeholders.
+	ParamCount int
+
+	// Fields are the columns of the resultset the server reported at the prepare, they may be empty.
+	Fields []*querypb.Field
+
+	// longData are the params sent by the SendLongData for the next execution.
+	longData map[uint16]bool
+
+	// The query and whether the statement is owned by the statements cache.
+	query  string
+	cached bool
+}
+
+// longDataChunkSize is the max data size of the COM_STMT_SEND_LONG_DATA, the payload fits the default max_allowed_packet.
+const longDataChunkSize = DefaultMaxAllowedPacket - 7
+
+// Prepare prepares the query on the server, the statement of the same query is reused if the statements cache is enabled.
+// The cached statement is valid until it's evicted, the least recently used one is closed once the cache is full.
+func (c *conn) Prepare(query string) (*Stmt, error) {
+	if c.stmts == nil {
+		return c.prepare(query)
+	}
+	if stmt, ok := c.stmts.get(query); ok {
+		return stmt, nil
+	}
+	stmt, err := c.prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	stmt.cached = true
+	if evicted := c.stmts.put(stmt); evicted != nil {
+		if err := evicted.close(); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// prepare prepares the query on the server.
+// https://dev.mysql.com/doc/internals/en/com-stmt-prepare.html
+func (c *conn) prepare(query string) (*Stmt, error) {
+	var err error
+	var data []byte
+	var ok *proto.StmtPrepareOK
+
+	// if err != nil means the connection is broken(packet error)
+	defer func() {
+		if err != nil {
+			c.Cleanup()
+		}
+	}()
+
+	if err = c.packets.WriteCommand(sqldb.COM_STMT_PREPARE, common.StringToBytes(query)); err != nil {
+		return nil, writeError(err)
+	}
+	if data, err = c.packets.Next(); err != nil {
+		return nil, readError(err, "during prepare")
+	}
+	if data[0] == proto.ERR_PACKET {
+		return nil, c.packets.ParseERR(data)
+	}
+	if ok, err = proto.UnPackStmtPrepareOK(data); err != nil {
+		return nil, malformedError(err)
+	}
+
+	stmt := &Stmt{c: c, ID: ok.StatementID, ParamCount: int(ok.ParamCount), query: query}
+	if ok.ParamCount > 0 {
+		if _, err = c.readDefinitions(int(ok.ParamCount)); err != nil {
+			return nil, err
+		}
+	}
+	if ok.ColumnCount > 0 {
+		if stmt.Fields, err = c.readDefinitions(int(ok.ColumnCount)); err != nil {
+			return nil, err
+		}
+	}
+	return stmt, nil
+}
+
+// readDefinitions reads the column definitions and the EOF.
+func (c *conn) readDefinitions(count int) ([]*querypb.Field, error) {
+	fields, err := c.packets.ReadColumns(count)
+	if err != nil {
+		return nil, readError(err, "during prepare")
+	}
+	if (c.greeting.Capability & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		if err = c.packets.ReadEOF(); err != nil {
+			return nil, readError(err, "during prepare")
+		}
+	}
+	return fields, nil
+}
+
+// SendLongData sends the data of the param by the COM_STMT_SEND_LONG_DATA chunks, the server appends them to the param
+// of the next execution whose arg is ignored, the NULL arg is sent as the BLOB one.
+// https://dev.mysql.com/doc/internals/en/com-stmt-send-long-data.html
+func (s *Stmt) SendLongData(paramID int, data []byte) error {
+	if paramID < 0 || paramID >= s.ParamCount {
+		return sqldb.NewSQLError(sqldb.ER_WRONG_ARGUMENTS, "Incorrect arguments to %s", "mysqld_stmt_send_long_data")
+	}
+	for {
+		chunk := data
+		if len(chunk) > longDataChunkSize {
+			chunk = chunk[:longDataChunkSize]
+		}
+		payload := proto.PackStmtSendLongData(&proto.StmtSendLongData{StatementID: s.ID, ParamID: uint16(paramID), Data: chunk})
+		if err := s.c.packets.WriteCommand(sqldb.COM_STMT_SEND_LONG_DATA, payload); err != nil {
+			s.c.Cleanup()
+			return writeError(err)
+		}
+		if data = data[len(chunk):]; len(data) == 0 {
+			break
+		}
+	}
+	if s.longData == nil {
+		s.longData = make(map[uint16]bool)
+	}
+	s.longData[uint16(paramID)] = true
+	return nil
+}
+
+// executePayload packs the COM_STMT_EXECUTE of the args, the long data are cleared by it.
+func (s *Stmt) executePayload(flags byte, args []sqltypes.Value) ([]byte, error) {
+	return s.executePayloadWithAttributes(flags, args, nil)
+}
+
+// executePayloadWithAttributes packs the COM_STMT_EXECUTE of the args followed by the attributes
+// if the CLIENT_QUERY_ATTRIBUTES is negotiated.
+func (s *Stmt) executePayloadWithAttributes(flags byte, args []sqltypes.Value, attrs []proto.QueryAttribute) ([]byte, error) {
+	if len(args) != s.ParamCount {
+		return nil, sqldb.NewSQLError(sqldb.ER_WRONG_ARGUMENTS, "Incorrect arguments to %s", "mysqld_stmt_execute")
+	}
+	longData := s.longData
+	s.longData = nil
+	if len(longData) > 0 {
+		params := make([]sqltypes.Value, len(args))
+		copy(params, args)
+		for i := range longData {
+			if params[i].IsNull() {
+				params[i] = sqltypes.MakeTrusted(sqltypes.Blob, nil)
+			}
+		}
+		args = params
+	}
+	execute := &proto.StmtExecute{StatementID: s.ID, Flags: flags, Params: args, LongData: longData, Attributes: attrs}
+	return proto.PackStmtExecuteWithCapability(execute, s.c.packets.Capability())
+}
+
+// Query executes the statement with the args and returns the row cursor.
+// https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+func (s *Stmt) Query(args ...sqltypes.Value) (Rows, error) {
+	payload, err := s.executePayload(proto.CURSOR_TYPE_NO_CURSOR, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.c.command(sqldb.COM_STMT_EXECUTE, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryRows{TextRows: *rows}, nil
+}
+
+// QueryWithAttributes executes the statement with the args carrying the query attributes and returns the row cursor,
+// the attributes are dropped if the CLIENT_QUERY_ATTRIBUTES isn't negotiated.
+func (s *Stmt) QueryWithAttributes(attrs []proto.QueryAttribute, args ...sqltypes.Value) (Rows, error) {
+	payload, err := s.executePayloadWithAttributes(proto.CURSOR_TYPE_NO_CURSOR, args, attrs)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.c.command(sqldb.COM_STMT_EXECUTE, payload)
+	if err != nil {
+		return nil, err
+	}
+	return &BinaryRows{TextRows: *rows}, nil
+}
+
+// QueryCursor executes the statement with the args by the server cursor, the rows are fetched by the COM_STMT_FETCH
+// batches of the fetchSize rows while iterating the Rows.
+// https://dev.mysql.com/doc/internals/en/com-stmt-fetch.html
+func (s *Stmt) QueryCursor(fetchSize int, args ...sqltypes.Value) (Rows, error) {
+	if fetchSize <= 0 {
+		return nil, sqldb.NewSQLError(sqldb.ER_WRONG_ARGUMENTS, "Incorrect arguments to %s", "mysqld_stmt_execute")
+	}
+	payload, err := s.executePayload(proto.CURSOR_TYPE_READ_ONLY, args)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := s.c.command(sqldb.COM_STMT_EXECUTE, payload)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows.fields) == 0 {
+		return &BinaryRows{TextRows: *rows}, nil
+	}
+
+	// The EOF of the columns is sent even to the CLIENT_DEPRECATE_EOF clients if the cursor is opened.
+	if (s.c.greeting.Capability & sqldb.CLIENT_DEPRECATE_EOF) > 0 {
+		if err = s.c.readEOF(); err != nil {
+			s.c.Cleanup()
+			return nil, readError(err, "during query")
+		}
+	}
+	if (s.c.status & sqldb.SERVER_STATUS_CURSOR_EXISTS) == 0 {
+		// The server sends the rows at once without the cursor.
+		return &BinaryRows{TextRows: *rows}, nil
+	}
+	cursor := &CursorRows{BinaryRows: BinaryRows{TextRows: *rows}, stmt: s, fetchSize: fetchSize}
+	// The first batch is fetched by the first Next.
+	cursor.end = true
+	return cursor, nil
+}
+
+// Execute executes the statement with the args and fetches all the results.
+func (s *Stmt) Execute(args ...sqltypes.Value) (*sqltypes.Result, error) {
+	rows, err := s.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	return s.c.fetchRows(rows, -1, func(rows Rows) error { return nil })
+}
+
+// Reset clears the long data and closes the cursor of the statement on the server, so are the long data of the client.
+// https://dev.mysql.com/doc/internals/en/com-stmt-reset.html
+func (s *Stmt) Reset() error {
+	s.longData = nil
+	rows, err := s.c.command(sqldb.COM_STMT_RESET, proto.PackStmtID(s.ID))
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+// Close releases the statement on the server, the server doesn't answer.
+// The cached statement is released by the eviction instead.
+func (s *Stmt) Close() error {
+	if s.cached {
+		return nil
+	}
+	return s.close()
+}
+
+func (s *Stmt) close() error {
+	if err := s.c.packets.WriteCommand(sqldb.COM_STMT_CLOSE, proto.PackStmtID(s.ID)); err != nil {
+		s.c.Cleanup()
+		return writeError(err)
+	}
+	return nil
+}
+
+// BinaryRows is the row cursor of the prepared statements, the rows are the binary protocol ones.
+type BinaryRows struct {
+	TextRows
+}
+
+// RowValues returns the values of the binary protocol row as the text ones.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-resultset-row.html
+func (r *BinaryRows) RowValues() ([]sqltypes.Value, error) {
+	if r.fields == nil {
+		return nil, errors.New("rows.fields is NIL")
+	}
+
+	row, err := proto.UnPackBinaryRow(r.fields, r.data)
+	if err != nil {
+		r.c.Cleanup()
+		return nil, malformedError(err)
+	}
+	for _, v := range row {
+		r.bytes += v.Len()
+	}
+	return row, nil
+}
+
+// CursorRows is the row cursor of the statement executed by the server cursor,
+// the next batch is fetched once the rows of the last one are read.
+// Close drains the batch being read only, the server cursor is closed by the next execution or the Stmt.Close.
+type CursorRows struct {
+	BinaryRows
+	stmt      *Stmt
+	fetchSize int
+}
+
+// Next returns true if the row is read, the COM_STMT_FETCH is sent while the server cursor has the rows.
+func (r *CursorRows) Next() bool {
+	if r.BinaryRows.Next() {
+		return true
+	}
+	if r.err != nil {
+		return false
+	}
+
+	status := r.stmt.c.status
+	if (status&sqldb.SERVER_STATUS_CURSOR_EXISTS) == 0 || (status&sqldb.SERVER_STATUS_LAST_ROW_SENT) > 0 {
+		return false
+	}
+	payload := proto.PackStmtFetch(&proto.StmtFetch{StatementID: r.stmt.ID, NumRows: uint32(r.fetchSize)})
+	if err := r.stmt.c.packets.WriteCommand(sqldb.COM_STMT_FETCH, payload); err != nil {
+		r.err = writeError(err)
+		r.stmt.c.Cleanup()
+		return false
+	}
+	r.end = false
+	return r.BinaryRows.Next()
+}
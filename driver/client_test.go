@@ -11,8 +11,14 @@ package driver
 
 import (
 	"errors"
+	"io"
+	"net"
 	"testing"
+	"time"
 
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
 	"github.com/stretchr/testify/assert"
 
 	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
@@ -53,7 +59,8 @@ func TestClient(t *testing.T) {
 }
 
 func TestClientClosed(t *testing.T) {
-	result2 := &sqltypes.Result{}
+	// The status flags read back are the session ones.
+	result2 := &sqltypes.Result{StatusFlags: sqldb.SERVER_STATUS_AUTOCOMMIT}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
 	th := NewTestHandler(log)
@@ -190,3 +197,185 @@ func TestClientStream(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestClientFailover(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	// The first backend is down, failover to the second.
+	{
+		client, err := NewConn("mock", "mock", "127.0.0.1:1,"+address, "test", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.Equal(t, address, client.Addr())
+
+		err = client.Ping()
+		assert.Nil(t, err)
+	}
+
+	// All backends are down.
+	{
+		_, err := NewConnWithAddrs("mock", "mock", []string{"127.0.0.1:1", "127.0.0.1:2"}, "test", "", time.Second)
+		assert.NotNil(t, err)
+	}
+
+	// Empty backends.
+	{
+		_, err := NewConnWithAddrs("mock", "mock", nil, "test", "", time.Second)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestClientAuthSwitch(t *testing.T) {
+	tests := []struct {
+		req  *proto.AuthSwitchRequest
+		want func(salt []byte) []byte
+	}{
+		{
+			&proto.AuthSwitchRequest{PluginName: proto.DefaultAuthPluginName, AuthData: proto.DefaultSalt},
+			func(salt []byte) []byte { return proto.NativePassword("mock", proto.DefaultSalt) },
+		},
+		{
+			// The old server asks the 323 scramble of the greeting salt.
+			&proto.AuthSwitchRequest{PluginName: proto.OldAuthPluginName},
+			func(salt []byte) []byte {
+				resp, _ := (&proto.AuthSwitchRequest{PluginName: proto.OldAuthPluginName}).Response("mock", salt)
+				return resp
+			},
+		},
+	}
+
+	for _, test := range tests {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn, err := listener.Accept()
+			assert.Nil(t, err)
+			defer conn.Close()
+
+			packets := packet.NewPackets(conn)
+			greeting := proto.NewGreeting(1)
+			assert.Nil(t, packets.Write(greeting.Pack()))
+			_, err = packets.Next()
+			assert.Nil(t, err)
+
+			data := test.req.Pack()
+			if test.req.PluginName == proto.OldAuthPluginName {
+				data = []byte{proto.AUTH_SWITCH_PACKET}
+			}
+			assert.Nil(t, packets.Write(data))
+			resp, err := packets.Next()
+			assert.Nil(t, err)
+			assert.Equal(t, test.want(greeting.Salt), resp)
+			assert.Nil(t, packets.WriteOK(0, 0, 0, 0))
+		}()
+
+		client, err := NewConn("mock", "mock", listener.Addr().String(), "", "")
+		assert.Nil(t, err)
+		<-done
+		client.Cleanup()
+		listener.Close()
+	}
+
+	// The plugin the client doesn't support.
+	{
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		defer listener.Close()
+		go func() {
+			conn, err := listener.Accept()
+			assert.Nil(t, err)
+			defer conn.Close()
+			packets := packet.NewPackets(conn)
+			packets.Write(proto.NewGreeting(1).Pack())
+			packets.Next()
+			packets.Write((&proto.AuthSwitchRequest{PluginName: "caching_sha2_password", AuthData: proto.DefaultSalt}).Pack())
+			packets.Next()
+		}()
+		_, err = NewConn("mock", "mock", listener.Addr().String(), "", "")
+		assert.Equal(t, uint16(sqldb.CR_AUTH_PLUGIN_CANNOT_LOAD), err.(*sqldb.SQLError).Num)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	// serve accepts one conn and runs the fn on it.
+	serve := func(fn func(packets *packet.Packets)) (string, func()) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.Nil(t, err)
+		go func() {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			fn(packet.NewPackets(conn))
+		}()
+		return listener.Addr().String(), func() { listener.Close() }
+	}
+
+	// Dial failed.
+	{
+		_, err := NewConnWithAddrs("mock", "mock", []string{"127.0.0.1:1"}, "", "", time.Second)
+		assert.Equal(t, uint16(sqldb.CR_CONN_HOST_ERROR), err.(*sqldb.SQLError).Num)
+		assert.True(t, sqldb.IsConnErr(err))
+		assert.True(t, sqldb.IsClientError(err))
+	}
+
+	// The server closes in the handshake.
+	{
+		address, stop := serve(func(packets *packet.Packets) {
+			packets.Write(proto.NewGreeting(1).Pack())
+			packets.Next()
+		})
+		_, err := NewConn("mock", "mock", address, "", "")
+		assert.Equal(t, uint16(sqldb.CR_SERVER_LOST), err.(*sqldb.SQLError).Num)
+		assert.True(t, errors.Is(err, io.EOF))
+		stop()
+	}
+
+	// The malformed greeting.
+	{
+		address, stop := serve(func(packets *packet.Packets) {
+			packets.Write([]byte{0x0a, 'x'})
+			packets.Next()
+		})
+		_, err := NewConn("mock", "mock", address, "", "")
+		assert.Equal(t, uint16(sqldb.CR_MALFORMED_PACKET), err.(*sqldb.SQLError).Num)
+		assert.False(t, sqldb.IsConnErr(err))
+		stop()
+	}
+
+	// The server closes during the query, the server errors are kept.
+	{
+		address, stop := serve(func(packets *packet.Packets) {
+			packets.Write(proto.NewGreeting(1).Pack())
+			packets.Next()
+			packets.WriteOK(0, 0, 0, 0)
+
+			packets.ResetSeq()
+			packets.Next()
+			packets.WriteERR(sqldb.ER_NO_SUCH_TABLE, "42S02", "Table 'test.t1' doesn't exist")
+			packets.ResetSeq()
+			packets.Next()
+		})
+		client, err := NewConn("mock", "mock", address, "", "")
+		assert.Nil(t, err)
+
+		_, err = client.Query("SELECT * FROM t1")
+		assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), err.(*sqldb.SQLError).Num)
+		assert.False(t, sqldb.IsClientError(err))
+
+		_, err = client.Query("SELECT 1")
+		assert.Equal(t, uint16(sqldb.CR_SERVER_LOST), err.(*sqldb.SQLError).Num)
+		assert.True(t, sqldb.IsConnErr(err))
+		assert.True(t, client.Closed())
+		stop()
+	}
+}
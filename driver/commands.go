@@ -0,0 +1,189 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// CommandHandler is the Handler with the callbacks per command, the Listener parses the commands for it.
+// The Handler not implementing it is served by the BaseCommandHandler, so are the callbacks not overridden
+// by the handler embedding the BaseCommandHandler.
+type CommandHandler interface {
+	Handler
+
+	// ComPing answers the COM_PING, the error is sent instead of the OK.
+	ComPing(session *Session) error
+
+	// ComFieldList returns the columns of the table of the COM_FIELD_LIST, the wildcard is the LIKE pattern
+	// of the column names, empty for all of them.
+	ComFieldList(session *Session, table string, wildcard string) ([]*querypb.Field, error)
+
+	// ComProcessKill kills the connection of the COM_PROCESS_KILL.
+	ComProcessKill(session *Session, id uint32) error
+
+	// ComStmtPrepare prepares the statement, the stmt.ParamCount is the count of the placeholders of the query,
+	// the handler can set the stmt.Fields if the columns of the resultset are known before the execution.
+	ComStmtPrepare(session *Session, stmt *Statement) error
+
+	// ComStmtExecute executes the statement with the params, the results are sent by the callback as the ComQuery ones.
+	ComStmtExecute(session *Session, stmt *Statement, params []sqltypes.Value, callback func(*sqltypes.Result) error) error
+
+	// ComStmtClose releases the statement, the client doesn't wait for the answer.
+	ComStmtClose(session *Session, stmt *Statement)
+
+	// ComOther handles the commands without the callbacks, the data has the command byte.
+	// The error is sent instead of the OK.
+	ComOther(session *Session, command byte, data []byte) error
+}
+
+// BaseCommandHandler adapts the Handler to the CommandHandler by the ComQuery.
+type BaseCommandHandler struct {
+	Handler
+}
+
+// NewCommandHandler returns the handler if it's a CommandHandler, otherwise the BaseCommandHandler of it.
+func NewCommandHandler(handler Handler) CommandHandler {
+	if ch, ok := handler.(CommandHandler); ok {
+		return ch
+	}
+	return &BaseCommandHandler{Handler: handler}
+}
+
+// ComPing impl, it's always OK.
+func (h *BaseCommandHandler) ComPing(session *Session) error {
+	return nil
+}
+
+// ComFieldList impl, the columns are the ones of the 'SELECT * FROM table LIMIT 0'.
+func (h *BaseCommandHandler) ComFieldList(session *Session, table string, wildcard string) ([]*querypb.Field, error) {
+	var fields []*querypb.Field
+	query := fmt.Sprintf("SELECT * FROM %s LIMIT 0", sqlparser.String(sqlparser.NewTableIdent(table)))
+	err := h.ComQuery(session, query, func(qr *sqltypes.Result) error {
+		if fields == nil {
+			fields = qr.Fields
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if wildcard == "" {
+		return fields, nil
+	}
+
+	re := likeRegexp(wildcard)
+	matched := make([]*querypb.Field, 0, len(fields))
+	for _, field := range fields {
+		if re.MatchString(field.Name) {
+			matched = append(matched, field)
+		}
+	}
+	return matched, nil
+}
+
+// ComProcessKill impl, it's the 'KILL id'.
+func (h *BaseCommandHandler) ComProcessKill(session *Session, id uint32) error {
+	return h.ComQuery(session, fmt.Sprintf("KILL %d", id), func(qr *sqltypes.Result) error { return nil })
+}
+
+// ComStmtPrepare impl, the columns are sent with the results.
+func (h *BaseCommandHandler) ComStmtPrepare(session *Session, stmt *Statement) error {
+	return nil
+}
+
+// ComStmtExecute impl, the query with the params interpolated is executed.
+func (h *BaseCommandHandler) ComStmtExecute(session *Session, stmt *Statement, params []sqltypes.Value, callback func(*sqltypes.Result) error) error {
+	query, err := stmt.Interpolate(params)
+	if err != nil {
+		return err
+	}
+	return h.ComQuery(session, query, callback)
+}
+
+// ComStmtClose impl.
+func (h *BaseCommandHandler) ComStmtClose(session *Session, stmt *Statement) {
+}
+
+// ComOther impl, the command is not implemented.
+func (h *BaseCommandHandler) ComOther(session *Session, command byte, data []byte) error {
+	return sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "command handling not implemented yet: %s", sqldb.CommandString(command))
+}
+
+// handlePing handles the COM_PING, the error returned is the write error.
+func (l *Listener) handlePing(session *Session) error {
+	if err := l.commands.ComPing(session); err != nil {
+		return session.writeErrFromError(err)
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleFieldList handles the COM_FIELD_LIST, the error returned is the write error.
+// https://dev.mysql.com/doc/internals/en/com-field-list.html
+func (l *Listener) handleFieldList(session *Session, data []byte) error {
+	buf := common.ReadBuffer(data[1:])
+	table, err := buf.ReadStringNUL()
+	if err != nil {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, ""))
+	}
+	wildcard, _ := buf.ReadString(buf.Length() - buf.Seek())
+
+	fields, err := l.commands.ComFieldList(session, table, wildcard)
+	if err != nil {
+		l.log.Error("server.handle.field.list.from.session[%v].error:%+v.table[%s]", session.ID(), err, table)
+		return session.writeErrFromError(err)
+	}
+	for _, field := range fields {
+		// The definitions have the default values, NULL.
+		def := proto.NewColumnDefinition(field)
+		def.FieldList = true
+		if err = session.packets.Append(proto.PackColumnDefinition(def)); err != nil {
+			return err
+		}
+	}
+	if (session.capabilities() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		err = session.packets.AppendEOFWithStatus(session.Status(), 0)
+	} else {
+		err = session.packets.AppendOKPacketWithEOFHeader(session.okPacket(&sqltypes.Result{}))
+	}
+	if err != nil {
+		return err
+	}
+	return session.flush()
+}
+
+// handleProcessKill handles the COM_PROCESS_KILL, the error returned is the write error.
+func (l *Listener) handleProcessKill(session *Session, data []byte) error {
+	id, err := common.ReadBuffer(data[1:]).ReadU32()
+	if err != nil {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, ""))
+	}
+	if err = l.commands.ComProcessKill(session, id); err != nil {
+		return session.writeErrFromError(err)
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleOther handles the commands without the callbacks, the error returned is the write error.
+func (l *Listener) handleOther(session *Session, data []byte) error {
+	if err := l.commands.ComOther(session, data[0], data); err != nil {
+		l.log.Error("session.command:%s.error:%+v", sqldb.CommandString(data[0]), err)
+		return session.writeErrFromError(err)
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
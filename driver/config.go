@@ -0,0 +1,272 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// ListenerConfig is the config of the NewListenerWithConfig, the zero values are the defaults.
+type ListenerConfig struct {
+	// Address is the tcp address listened on.
+	Address string
+
+	// Log is the logger, nil is the xlog.GetLog.
+	Log *xlog.Log
+
+	// LogLevel sets the level of the Log like "WARNING", empty keeps the level of it.
+	LogLevel string
+
+	// Greeting is the handshake advertised like the server version, the charset and the capability masks,
+	// nil is the DefaultGreetingConfig.
+	Greeting *GreetingConfig
+
+	// TLS enables the clients switching to TLS by the SSLRequest, the CLIENT_SSL is advertised.
+	TLS *tls.Config
+
+	// RequireSecureTransport rejects the sessions not switched to TLS like the require_secure_transport of MySQL.
+	RequireSecureTransport bool
+
+	// SecureTransportUsers are the users rejected if their sessions are not switched to TLS.
+	SecureTransportUsers []string
+
+	// StrictCapabilities rejects the mis-negotiated handshake responses, see Listener.SetStrictCapabilities.
+	StrictCapabilities bool
+
+	// HandshakePolicy is the minimum protocol security level of the clients, see HandshakePolicy.
+	HandshakePolicy HandshakePolicy
+
+	// AuthDelegate checks the credentials instead of the Handler.AuthCheck, nil is the handler.
+	AuthDelegate AuthDelegate
+
+	// X509Users are the client certificates the users must present, the TLS must verify the client certificates.
+	X509Users map[string]*X509Rule
+
+	// AuthThrottle delays and blocks the failed authentications, nil disables it.
+	AuthThrottle *AuthThrottle
+
+	// ThreadPool bounds the commands the sessions execute at once, nil executes them without limit.
+	ThreadPool *ThreadPool
+
+	// ExpiredUsers are the users whose passwords are expired, see Listener.SetPasswordExpired.
+	ExpiredUsers []string
+
+	// HandshakeTimeout is the timeout from the greeting to the auth OK, zero is no timeout.
+	HandshakeTimeout time.Duration
+
+	// SessionMemoryLimit is the bytes a statement of the session can buffer, zero is unlimited.
+	SessionMemoryLimit int64
+
+	// FlushPolicy is when the rows of the resultsets are flushed before the results are written, see FlushPolicy.
+	FlushPolicy FlushPolicy
+
+	// OutputQueueSize is the bytes of the output queue of the sessions, zero writes to the clients synchronously,
+	// see Listener.SetOutputQueueSize.
+	OutputQueueSize int
+
+	// InfoSchema answers the information_schema queries of the SCHEMATA, the TABLES, the COLUMNS and the PROCESSLIST,
+	// nil leaves them to the handler, see Listener.SetInfoSchema.
+	InfoSchema InfoSchemaProvider
+
+	// ResultTimeZone is the zone of the TIMESTAMP values the handler returns, nil disables the conversion.
+	ResultTimeZone *time.Location
+
+	// Trace traces the packets of the sessions from the greeting.
+	Trace bool
+}
+
+// Validate checks the config, the NewListenerWithConfig rejects the invalid one.
+func (c *ListenerConfig) Validate() error {
+	if c.Greeting != nil {
+		if err := c.Greeting.validate(); err != nil {
+			return err
+		}
+	}
+	if c.TLS != nil && len(c.TLS.Certificates) == 0 && c.TLS.GetCertificate == nil && c.TLS.GetConfigForClient == nil {
+		return fmt.Errorf("driver.listener.config.tls.without.certificate")
+	}
+	if c.TLS == nil && (c.RequireSecureTransport || len(c.SecureTransportUsers) > 0) {
+		return fmt.Errorf("driver.listener.config.secure.transport.required.without.tls")
+	}
+	if len(c.X509Users) > 0 && (c.TLS == nil || c.TLS.ClientAuth < tls.VerifyClientCertIfGiven) {
+		return fmt.Errorf("driver.listener.config.x509.users.without.client.certificate.verification")
+	}
+	if _, ok := xlog.ParseLevel(c.LogLevel); c.LogLevel != "" && !ok {
+		return fmt.Errorf("driver.listener.config.unknown.log.level[%s]", c.LogLevel)
+	}
+	if c.HandshakeTimeout < 0 {
+		return fmt.Errorf("driver.listener.config.handshake.timeout[%v].negative", c.HandshakeTimeout)
+	}
+	if c.ThreadPool != nil {
+		if c.ThreadPool.Workers < 0 || c.ThreadPool.QueueSize < 0 || c.ThreadPool.QueueTimeout < 0 {
+			return fmt.Errorf("driver.listener.config.thread.pool.negative")
+		}
+	}
+	if c.SessionMemoryLimit < 0 {
+		return fmt.Errorf("driver.listener.config.session.memory.limit[%v].negative", c.SessionMemoryLimit)
+	}
+	if err := c.FlushPolicy.validate(); err != nil {
+		return err
+	}
+	if c.OutputQueueSize < 0 {
+		return fmt.Errorf("driver.listener.config.output.queue.size[%v].negative", c.OutputQueueSize)
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the config with the defaults filled.
+func (c *ListenerConfig) withDefaults() *ListenerConfig {
+	cfg := *c
+	if cfg.Log == nil {
+		cfg.Log = xlog.GetLog()
+	}
+	if cfg.Greeting == nil {
+		cfg.Greeting = DefaultGreetingConfig()
+	} else {
+		greeting := *cfg.Greeting
+		cfg.Greeting = &greeting
+	}
+	return &cfg
+}
+
+// ClientConfig is the config of the NewConnWithConfig, the zero values are the defaults.
+type ClientConfig struct {
+	User   string
+	Passwd string
+
+	// Addrs are the backends tried in order until one of them finishes the handshake.
+	Addrs  []string
+	DBName string
+
+	// Charset is the charset or the collation name of the connection, empty is the sqldb.DefaultCollation.
+	Charset string
+
+	// Timeout is the timeout of each connect attempt, zero is the DefaultConnectTimeout.
+	Timeout time.Duration
+
+	// ReadTimeout and WriteTimeout are the I/O timeouts of the connection after the handshake, zero is no timeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// TLS switches the connection to TLS by the SSLRequest, the server without the CLIENT_SSL is rejected.
+	// The ServerName is the host of the address if it's empty.
+	TLS *tls.Config
+
+	// CapabilityMask is the capability bits cleared from the proto.DefaultClientCapability.
+	CapabilityMask uint32
+
+	// CapabilityForce is the capability bits set, it wins over the mask.
+	CapabilityForce uint32
+
+	// ClientFoundRows asks the server to report the matched rows instead of the changed rows.
+	ClientFoundRows bool
+
+	// ExpiredPasswords sets the CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS, the connection of the expired password is
+	// accepted in the sandbox mode and the statements except the password change fail with the ER_MUST_CHANGE_PASSWORD.
+	ExpiredPasswords bool
+
+	// QueryAttributes sets the CLIENT_QUERY_ATTRIBUTES, the attributes of the QueryWithAttributes and the
+	// Stmt.QueryWithAttributes are sent to the servers supporting it.
+	QueryAttributes bool
+
+	// StmtCacheSize is the capacity of the LRU cache of the prepared statements, zero disables it.
+	StmtCacheSize int
+
+	// LazyRows decodes the rows of the text resultsets into the buffers reused by the cursor instead of the fresh ones,
+	// the values of the RowValues are only valid until the next Next and are copied by the Value.Copy to be retained.
+	// The FetchAll copies the rows into the slabs shared by the rows.
+	LazyRows bool
+
+	// ConnectAttrs are the custom connection attributes sent in the handshake,
+	// merged with DefaultConnectAttrs.
+	ConnectAttrs map[string]string
+
+	// Log is the logger of the failed connect attempts, nil disables the logging.
+	Log *xlog.Log
+}
+
+// Capability returns the capabilities the client asks.
+func (c *ClientConfig) Capability() uint32 {
+	capability := proto.DefaultClientCapability&^c.CapabilityMask | c.CapabilityForce
+	if c.ClientFoundRows {
+		capability |= sqldb.CLIENT_FOUND_ROWS
+	}
+	if c.ExpiredPasswords {
+		capability |= sqldb.CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS
+	}
+	if c.QueryAttributes {
+		capability |= sqldb.CLIENT_QUERY_ATTRIBUTES
+	}
+	return capability
+}
+
+// Validate checks the config, the NewConnWithConfig rejects the invalid one.
+func (c *ClientConfig) Validate() error {
+	addrs := 0
+	for _, addr := range c.Addrs {
+		if strings.TrimSpace(addr) != "" {
+			addrs++
+		}
+	}
+	if addrs == 0 {
+		return fmt.Errorf("driver.conn.addrs.can.not.be.empty")
+	}
+	if c.Charset != "" {
+		if _, ok := sqldb.CharacterSetMap[strings.ToLower(c.Charset)]; !ok {
+			if _, ok := sqldb.LookupCollationByName(c.Charset); !ok {
+				return fmt.Errorf("driver.client.config.unknown.charset[%s]", c.Charset)
+			}
+		}
+	}
+	if c.Timeout < 0 || c.ReadTimeout < 0 || c.WriteTimeout < 0 {
+		return fmt.Errorf("driver.client.config.timeout.negative")
+	}
+	if c.Capability()&sqldb.CLIENT_PROTOCOL_41 == 0 {
+		return fmt.Errorf("driver.client.config.capability.mask.protocol.41")
+	}
+	if c.StmtCacheSize < 0 {
+		return fmt.Errorf("driver.client.config.stmt.cache.size[%d].negative", c.StmtCacheSize)
+	}
+	return nil
+}
+
+// withDefaults returns a copy of the config with the defaults filled.
+func (c *ClientConfig) withDefaults() *ClientConfig {
+	cfg := *c
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultConnectTimeout
+	}
+	cfg.Addrs = append([]string(nil), c.Addrs...)
+	return &cfg
+}
+
+// tlsConfig returns the TLS config of the address, nil if the TLS is off.
+func (c *ClientConfig) tlsConfig(address string) *tls.Config {
+	if c.TLS == nil {
+		return nil
+	}
+	cfg := c.TLS.Clone()
+	if cfg.ServerName == "" {
+		host := address
+		if h, _, err := net.SplitHostPort(address); err == nil {
+			host = h
+		}
+		cfg.ServerName = host
+	}
+	return cfg
+}
@@ -0,0 +1,272 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// newTestTLSConfig returns the server config of a self-signed certificate for the localhost and the client config trusting it.
+func newTestTLSConfig(t *testing.T) (*tls.Config, *tls.Config) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool, ServerName: "localhost"}
+	return server, client
+}
+
+func TestListenerConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg *ListenerConfig
+		err string
+	}{
+		{cfg: &ListenerConfig{}},
+		{cfg: &ListenerConfig{Greeting: &GreetingConfig{ServerVersion: "5.7", Charset: sqldb.CharacterSetUtf8}}},
+		{cfg: &ListenerConfig{Greeting: &GreetingConfig{Charset: sqldb.CharacterSetUtf8}}, err: "driver.greeting.config.server.version.empty"},
+		{cfg: &ListenerConfig{Greeting: &GreetingConfig{ServerVersion: "5.7", Charset: 0}}, err: "driver.greeting.config.unknown.charset[0]"},
+		{cfg: &ListenerConfig{Greeting: &GreetingConfig{ServerVersion: "5.7", Charset: sqldb.CharacterSetUtf8, CapabilityMask: sqldb.CLIENT_PROTOCOL_41}}, err: "driver.greeting.config.capability.mask.protocol.41"},
+		{cfg: &ListenerConfig{TLS: &tls.Config{}}, err: "driver.listener.config.tls.without.certificate"},
+		{cfg: &ListenerConfig{HandshakeTimeout: -time.Second}, err: "driver.listener.config.handshake.timeout[-1s].negative"},
+		{cfg: &ListenerConfig{SessionMemoryLimit: -1}, err: "driver.listener.config.session.memory.limit[-1].negative"},
+		{cfg: &ListenerConfig{ThreadPool: &ThreadPool{Workers: -1}}, err: "driver.listener.config.thread.pool.negative"},
+	}
+	for _, test := range tests {
+		err := test.cfg.Validate()
+		if test.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+
+	_, err := NewListenerWithConfig(&ListenerConfig{SessionMemoryLimit: -1}, nil)
+	assert.NotNil(t, err)
+}
+
+func TestClientConfigValidate(t *testing.T) {
+	tests := []struct {
+		cfg *ClientConfig
+		err string
+	}{
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}}},
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}, Charset: "utf8mb4_unicode_ci"}},
+		{cfg: &ClientConfig{Addrs: []string{" "}}, err: "driver.conn.addrs.can.not.be.empty"},
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}, Charset: "xx"}, err: "driver.client.config.unknown.charset[xx]"},
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}, ReadTimeout: -time.Second}, err: "driver.client.config.timeout.negative"},
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}, CapabilityMask: sqldb.CLIENT_PROTOCOL_41}, err: "driver.client.config.capability.mask.protocol.41"},
+		{cfg: &ClientConfig{Addrs: []string{"127.0.0.1:3306"}, StmtCacheSize: -1}, err: "driver.client.config.stmt.cache.size[-1].negative"},
+	}
+	for _, test := range tests {
+		err := test.cfg.Validate()
+		if test.err == "" {
+			assert.Nil(t, err)
+		} else {
+			assert.EqualError(t, err, test.err)
+		}
+	}
+
+	cfg := &ClientConfig{CapabilityMask: sqldb.CLIENT_DEPRECATE_EOF, CapabilityForce: sqldb.CLIENT_LOCAL_FILES, ClientFoundRows: true}
+	assert.Equal(t, uint32(0), cfg.Capability()&sqldb.CLIENT_DEPRECATE_EOF)
+	assert.True(t, cfg.Capability()&sqldb.CLIENT_LOCAL_FILES > 0)
+	assert.True(t, cfg.Capability()&sqldb.CLIENT_FOUND_ROWS > 0)
+}
+
+func TestListenerConfig(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	greeting := DefaultGreetingConfig()
+	greeting.ServerVersion = "8.0.30-mock"
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{
+		Log:                log,
+		Greeting:           greeting,
+		SessionMemoryLimit: 1024,
+		HandshakeTimeout:   100 * time.Millisecond,
+	}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	assert.Equal(t, "8.0.30-mock", svr.GreetingConfig().ServerVersion)
+	assert.Equal(t, int64(1024), svr.SessionMemoryLimit())
+
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}})
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.True(t, client.ServerVersion().AtLeast(8, 0, 30))
+
+	// The handshake timeout doesn't apply after the auth.
+	time.Sleep(200 * time.Millisecond)
+	assert.Nil(t, client.Ping())
+
+	// The client stalled in the handshake is closed.
+	nc, err := net.Dial("tcp", svr.Addr())
+	assert.Nil(t, err)
+	defer nc.Close()
+	nc.SetReadDeadline(time.Now().Add(time.Second))
+	b := make([]byte, 4096)
+	for err == nil {
+		_, err = nc.Read(b)
+	}
+	ne, ok := err.(net.Error)
+	assert.False(t, ok && ne.Timeout())
+}
+
+// tlsAuthHandler records whether the last authed session is on TLS.
+type tlsAuthHandler struct {
+	*TestHandler
+	mu    sync.Mutex
+	tlsOn bool
+}
+
+func (h *tlsAuthHandler) AuthCheck(s *Session) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tlsOn = s.TLSState() != nil
+	return h.TestHandler.AuthCheck(s)
+}
+
+func (h *tlsAuthHandler) lastTLS() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.tlsOn
+}
+
+func TestClientConfigTLS(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &tlsAuthHandler{TestHandler: NewTestHandler(log)}
+	serverTLS, clientTLS := newTestTLSConfig(t)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, TLS: serverTLS}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT 1", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "1", Type: querypb.Type_INT64}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewInt64(1)}},
+	})
+
+	// The session is on TLS.
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, DBName: "test", TLS: clientTLS})
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.True(t, th.lastTLS())
+	qr, err := client.FetchAll("SELECT 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(qr.Rows))
+
+	// The plain clients are still served.
+	plain, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer plain.Close()
+	assert.False(t, th.lastTLS())
+	assert.Nil(t, plain.Ping())
+
+	// The untrusted certificate.
+	_, err = NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: &tls.Config{ServerName: "localhost"}})
+	assert.NotNil(t, err)
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.CR_SSL_CONNECTION_ERROR), num)
+}
+
+func TestClientConfigTLSNotSupported(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	_, clientTLS := newTestTLSConfig(t)
+	_, err = NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: clientTLS})
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.CR_SSL_CONNECTION_ERROR), num)
+}
+
+func TestClientConfigReadTimeout(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQueryDelay("SELECT SLOW", &sqltypes.Result{}, 500)
+
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, ReadTimeout: 100 * time.Millisecond})
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Nil(t, client.Ping())
+	_, err = client.FetchAll("SELECT SLOW", -1)
+	assert.True(t, sqldb.IsConnErr(err))
+	assert.True(t, client.Closed())
+}
+
+func TestListenerRequireSecureTransport(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	serverTLS, clientTLS := newTestTLSConfig(t)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, TLS: serverTLS, SecureTransportUsers: []string{"mock"}}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	// The user requires TLS.
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_SECURE_TRANSPORT_REQUIRED), num)
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: clientTLS})
+	assert.Nil(t, err)
+	assert.Nil(t, client.Ping())
+	client.Close()
+
+	// The other users don't.
+	_, err = NewConn("other", "mock", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+
+	// The whole listener requires TLS.
+	svr.SetSecureTransportUsers()
+	plain, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	plain.Close()
+	svr.SetRequireSecureTransport(true)
+	_, err = NewConn("other", "mock", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_SECURE_TRANSPORT_REQUIRED), num)
+
+	// The TLS is required.
+	assert.EqualError(t, (&ListenerConfig{RequireSecureTransport: true}).Validate(), "driver.listener.config.secure.transport.required.without.tls")
+}
@@ -0,0 +1,113 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// DefaultCopyBatchRows is the rows the CopyResult buffers before writing them to the session.
+	DefaultCopyBatchRows = 128
+
+	// DefaultCopyBatchSize is the bytes of the values the CopyResult buffers before writing them to the session.
+	DefaultCopyBatchSize = 1024 * 1024
+)
+
+// CopyResult runs the query on the backend and streams its result to the session in the batches of the defaults,
+// see CopyResultBatch.
+func CopyResult(dst *Session, src Conn, query string) error {
+	return CopyResultBatch(dst, src, query, DefaultCopyBatchRows, DefaultCopyBatchSize)
+}
+
+// CopyResultBatch runs the query on the backend and streams its result to the session: the fields are written
+// as soon as they arrive, the rows in the batches of at most batchRows rows or batchSize bytes and the terminator
+// with the counts and the status of the backend, the resultset is never materialized.
+// It's called by the ComQuery of the handler instead of the callback, the error of the backend after the fields
+// is returned for the ComQuery to send as the ERR packet ending the resultset.
+// If the session fails, the backend connection is cleaned up instead of draining the rest of the rows.
+func CopyResultBatch(dst *Session, src Conn, query string, batchRows, batchSize int) error {
+	rows, err := src.Query(query)
+	if err != nil {
+		return err
+	}
+
+	fields := rows.Fields()
+	if len(fields) == 0 {
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		return dst.writeResult(&sqltypes.Result{
+			RowsAffected: rows.RowsAffected(),
+			InsertID:     rows.LastInsertID(),
+			Info:         rows.Info(),
+			Warnings:     src.WarningCount(),
+			StatusFlags:  src.Status(),
+
+			SessionStateChanges: rows.SessionStateChanges(),
+		})
+	}
+
+	write := func(qr *sqltypes.Result) error {
+		if err := dst.writeResult(qr); err != nil {
+			src.Cleanup()
+			return err
+		}
+		return nil
+	}
+	if err := write(&sqltypes.Result{Fields: fields, State: sqltypes.RState_Fields}); err != nil {
+		return err
+	}
+
+	var count uint64
+	var size int
+	batch := &sqltypes.Result{Fields: fields, State: sqltypes.RState_Rows}
+	lazy := isLazy(rows)
+	for rows.Next() {
+		row, err := rows.RowValues()
+		if err != nil {
+			return err
+		}
+		// The row of all NULLs is nil.
+		if row == nil {
+			row = make([]sqltypes.Value, len(fields))
+		} else if lazy {
+			// The batch retains the row.
+			row = sqltypes.Row(row).DeepCopy()
+		}
+		for _, v := range row {
+			size += v.Len()
+		}
+		count++
+		batch.Rows = append(batch.Rows, row)
+		if len(batch.Rows) >= batchRows || size >= batchSize {
+			if err := write(batch); err != nil {
+				return err
+			}
+			batch = &sqltypes.Result{Fields: fields, State: sqltypes.RState_Rows}
+			size = 0
+		}
+	}
+	if err := rows.LastError(); err != nil {
+		return err
+	}
+	if len(batch.Rows) > 0 {
+		if err := write(batch); err != nil {
+			return err
+		}
+	}
+	return write(&sqltypes.Result{
+		Fields:       fields,
+		State:        sqltypes.RState_Finished,
+		RowsAffected: count,
+		Warnings:     src.WarningCount(),
+		StatusFlags:  src.Status(),
+	})
+}
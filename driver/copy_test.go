@@ -0,0 +1,124 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// copyHandler copies the results of the backend to the sessions in the batches of 2 rows.
+type copyHandler struct {
+	*TestHandler
+	backend Conn
+}
+
+func (h *copyHandler) ComQuery(s *Session, query string, callback func(*sqltypes.Result) error) error {
+	return CopyResultBatch(s, h.backend, query, 2, DefaultCopyBatchSize)
+}
+
+func TestCopyResult(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	backend, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer backend.Close()
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 32))
+	for i := 1; i <= 5; i++ {
+		b.AppendRow(i, fmt.Sprintf("name%d", i))
+	}
+	b.AppendRow(nil, nil)
+	th.AddQuery("SELECT * FROM t1", b.Result())
+	th.AddQuery("UPDATE t1 SET a = 3", &sqltypes.Result{RowsAffected: 2, InsertID: 7})
+	th.AddQueryError("SELECT * FROM t2", sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t2"))
+
+	conn, err := NewConn("mock", "mock", backend.Addr(), "", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+	proxy, err := MockMysqlServer(log, &copyHandler{TestHandler: NewTestHandler(log), backend: conn})
+	assert.Nil(t, err)
+	defer proxy.Close()
+
+	client, err := NewConn("mock", "mock", proxy.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	qr, err := client.FetchAll("SELECT * FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, b.Result().Fields[0].Name, qr.Fields[0].Name)
+	assert.Equal(t, b.Result().Rows[:5], qr.Rows)
+
+	// The all NULLs row is copied.
+	rows, err := client.Query("SELECT * FROM t1")
+	assert.Nil(t, err)
+	n := 0
+	for rows.Next() {
+		n++
+	}
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, 6, n)
+
+	qr, err = client.FetchAll("UPDATE t1 SET a = 3", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), qr.RowsAffected)
+	assert.Equal(t, uint64(7), qr.InsertID)
+
+	_, err = client.FetchAll("SELECT * FROM t2", -1)
+	assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), err.(*sqldb.SQLError).Num)
+
+	// The backend connection is usable after the error.
+	qr, err = client.FetchAll("SELECT * FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(qr.Rows))
+
+	// The batches retain the lazy rows of the backend.
+	lazy, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{backend.Addr()}, LazyRows: true})
+	assert.Nil(t, err)
+	defer lazy.Close()
+	lazyProxy, err := MockMysqlServer(log, &copyHandler{TestHandler: NewTestHandler(log), backend: lazy})
+	assert.Nil(t, err)
+	defer lazyProxy.Close()
+	lazyClient, err := NewConn("mock", "mock", lazyProxy.Addr(), "", "")
+	assert.Nil(t, err)
+	defer lazyClient.Close()
+	qr, err = lazyClient.FetchAll("SELECT * FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, b.Result().Rows[:5], qr.Rows)
+}
+
+func TestProxyCopyResult(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	backend, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer backend.Close()
+	th.AddQuery("SELECT * FROM users", newScanResult())
+
+	proxy, err := MockMysqlServer(log, NewProxyHandler(log, backend.Addr(), "mock", "mock"))
+	assert.Nil(t, err)
+	defer proxy.Close()
+
+	client, err := NewConn("mock", "mock", proxy.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var users []scanUser
+	assert.Nil(t, client.FetchAllInto("SELECT * FROM users", &users))
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "bob", users[1].UserName)
+	assert.Nil(t, users[1].Email)
+}
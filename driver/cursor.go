@@ -0,0 +1,124 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// cursor is the resultset of the statement executed with the CURSOR_TYPE_READ_ONLY,
+// it's buffered by the execution and the rows are sent by the COM_STMT_FETCH batches.
+type cursor struct {
+	result *sqltypes.Result
+	offset int
+}
+
+func newCursor() *cursor {
+	return &cursor{result: &sqltypes.Result{}}
+}
+
+// add buffers the result the handler sent.
+func (c *cursor) add(qr *sqltypes.Result) {
+	if c.result.Fields == nil {
+		c.result.Fields = qr.Fields
+	}
+	c.result.Rows = append(c.result.Rows, qr.Rows...)
+	if qr.Warnings > 0 {
+		c.result.Warnings = qr.Warnings
+	}
+}
+
+// next returns the next n rows at most.
+func (c *cursor) next(n int) [][]sqltypes.Value {
+	end := c.offset + n
+	if end > len(c.result.Rows) || n < 0 {
+		end = len(c.result.Rows)
+	}
+	rows := c.result.Rows[c.offset:end]
+	c.offset = end
+	return rows
+}
+
+func (c *cursor) done() bool {
+	return c.offset >= len(c.result.Rows)
+}
+
+// bufferCursor buffers the result of the cursor execution, the results without the fields are written as they are.
+func (s *Session) bufferCursor(c *cursor, qr *sqltypes.Result) error {
+	if len(qr.Fields) == 0 && c.result.Fields == nil {
+		return s.writeBinaryResult(qr)
+	}
+	if err := s.interruptError(); err != nil {
+		return err
+	}
+	if err := s.AllocMemory(resultSize(qr)); err != nil {
+		return err
+	}
+	s.trackResult(qr)
+	c.add(s.convertTimestamps(qr))
+	return nil
+}
+
+// writeCursorOpen writes the columns of the cursor, the EOF is sent even to the CLIENT_DEPRECATE_EOF clients
+// since it carries the SERVER_STATUS_CURSOR_EXISTS, the rows are sent by the COM_STMT_FETCH.
+func (s *Session) writeCursorOpen(c *cursor) error {
+	if err := s.packets.AppendColumns(c.result.Fields); err != nil {
+		return err
+	}
+	if err := s.packets.AppendEOFWithStatus(s.Status()|sqldb.SERVER_STATUS_CURSOR_EXISTS, s.warningsOf(c.result)); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// writeCursorRows writes the next n rows of the cursor of the stmt and the EOF,
+// the cursor is closed with the SERVER_STATUS_LAST_ROW_SENT once all the rows are sent.
+func (s *Session) writeCursorRows(stmt *Statement, n int) error {
+	c := stmt.cursor
+	if err := s.writeRows(&sqltypes.Result{Fields: c.result.Fields, Rows: c.next(n)}, true); err != nil {
+		return err
+	}
+
+	status := s.Status() | sqldb.SERVER_STATUS_CURSOR_EXISTS
+	if c.done() {
+		status |= sqldb.SERVER_STATUS_LAST_ROW_SENT
+		stmt.cursor = nil
+	}
+	var err error
+	if (s.capabilities() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		err = s.packets.AppendEOFWithStatus(status, s.WarningCount())
+	} else {
+		err = s.packets.AppendOKPacketWithEOFHeader(&proto.OK{StatusFlags: status, Warnings: s.WarningCount()})
+	}
+	if err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// handleStmtFetch handles the COM_STMT_FETCH, the error returned is the write error.
+// https://dev.mysql.com/doc/internals/en/com-stmt-fetch.html
+func (l *Listener) handleStmtFetch(session *Session, data []byte) error {
+	fetch, err := proto.UnPackStmtFetch(data[1:])
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	stmt, ok := session.statement(fetch.StatementID)
+	if !ok {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_UNKNOWN_STMT_HANDLER, "Unknown prepared statement handler (%v) given to %s", fetch.StatementID, "mysqld_stmt_fetch"))
+	}
+	if stmt.cursor == nil {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_STMT_HAS_NO_OPEN_CURSOR, "The statement (%v) has no open cursor.", fetch.StatementID))
+	}
+	return session.writeCursorRows(stmt, int(fetch.NumRows))
+}
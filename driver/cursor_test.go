@@ -0,0 +1,117 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestServerCursor(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for i := 0; i < 5; i++ {
+		result.Rows = append(result.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(fmt.Sprintf("%d", i))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("name%d", i))),
+		})
+	}
+	th.AddQuery("select * from t1 where id < 5", result)
+	th.AddQuery("update t1 set name = 'x' where id = 1", &sqltypes.Result{RowsAffected: 1})
+
+	check := func() {
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		stmt, err := client.Prepare("SELECT * FROM t1 WHERE id < ?")
+		assert.Nil(t, err)
+		for _, fetchSize := range []int{1, 2, 5, 10} {
+			rows, err := stmt.QueryCursor(fetchSize, sqltypes.NewInt64(5))
+			assert.Nil(t, err)
+			_, ok := rows.(*CursorRows)
+			assert.True(t, ok)
+			assert.Equal(t, 2, len(rows.Fields()))
+
+			qr, err := client.fetchRows(rows, -1, func(rows Rows) error { return nil })
+			assert.Nil(t, err)
+			assert.Equal(t, result.Rows, qr.Rows)
+			assert.NotEqual(t, uint16(0), client.Status()&sqldb.SERVER_STATUS_LAST_ROW_SENT)
+		}
+
+		// The cursor is closed once the last row is sent.
+		fetch := proto.PackStmtFetch(&proto.StmtFetch{StatementID: stmt.ID, NumRows: 1})
+		_, err = client.command(sqldb.COM_STMT_FETCH, fetch)
+		assert.Equal(t, uint16(sqldb.ER_STMT_HAS_NO_OPEN_CURSOR), err.(*sqldb.SQLError).Num)
+
+		// The execution closes the cursor not fetched all.
+		rows, err := stmt.QueryCursor(2, sqltypes.NewInt64(5))
+		assert.Nil(t, err)
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Close())
+		qr, err := stmt.Execute(sqltypes.NewInt64(5))
+		assert.Nil(t, err)
+		assert.Equal(t, result.Rows, qr.Rows)
+		_, err = client.command(sqldb.COM_STMT_FETCH, fetch)
+		assert.Equal(t, uint16(sqldb.ER_STMT_HAS_NO_OPEN_CURSOR), err.(*sqldb.SQLError).Num)
+
+		// The reset closes the cursor.
+		rows, err = stmt.QueryCursor(2, sqltypes.NewInt64(5))
+		assert.Nil(t, err)
+		assert.True(t, rows.Next())
+		assert.Nil(t, rows.Close())
+		assert.Nil(t, stmt.Reset())
+		_, err = client.command(sqldb.COM_STMT_FETCH, fetch)
+		assert.Equal(t, uint16(sqldb.ER_STMT_HAS_NO_OPEN_CURSOR), err.(*sqldb.SQLError).Num)
+
+		// The statement without the resultset has no cursor.
+		{
+			stmt, err := client.Prepare("UPDATE t1 SET name = ? WHERE id = ?")
+			assert.Nil(t, err)
+			rows, err := stmt.QueryCursor(2, sqltypes.NewVarChar("x"), sqltypes.NewInt64(1))
+			assert.Nil(t, err)
+			assert.False(t, rows.Next())
+			assert.Equal(t, uint64(1), rows.RowsAffected())
+		}
+
+		// Unknown statement and the wrong fetch size.
+		fetch = proto.PackStmtFetch(&proto.StmtFetch{StatementID: 100, NumRows: 1})
+		_, err = client.command(sqldb.COM_STMT_FETCH, fetch)
+		assert.Equal(t, uint16(sqldb.ER_UNKNOWN_STMT_HANDLER), err.(*sqldb.SQLError).Num)
+		_, err = stmt.QueryCursor(0, sqltypes.NewInt64(5))
+		assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+		assert.Nil(t, client.Ping())
+	}
+	check()
+
+	// The classic EOF ends the batches.
+	cfg := DefaultGreetingConfig()
+	cfg.CapabilityMask = sqldb.CLIENT_DEPRECATE_EOF
+	assert.Nil(t, svr.SetGreetingConfig(cfg))
+	check()
+}
@@ -0,0 +1,272 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDSNAddr is the address used if the DSN doesn't have one.
+	DefaultDSNAddr = "127.0.0.1:3306"
+)
+
+// DSN is the data source name in the go-sql-driver format:
+// [username[:password]@][protocol[(address1,address2...)]]/dbname[?param1=value1&paramN=valueN]
+type DSN struct {
+	User     string
+	Passwd   string
+	Net      string
+	Addrs    []string
+	DBName   string
+	Charset  string
+	Timeout  time.Duration
+	TLS      string
+	Compress bool
+
+	// ClientFoundRows asks the server to report the matched rows instead of the changed rows.
+	ClientFoundRows bool
+
+	// StmtCacheSize is the capacity of the LRU cache of the prepared statements, zero disables it.
+	StmtCacheSize int
+
+	// LazyRows decodes the rows of the cursors into the reused buffers, see ClientConfig.LazyRows.
+	LazyRows bool
+
+	// ConnectAttrs are the custom connection attributes sent in the handshake,
+	// merged with DefaultConnectAttrs.
+	ConnectAttrs map[string]string
+
+	// Params holds the unknown parameters.
+	Params map[string]string
+}
+
+// ParseDSN parses the dsn string to a DSN.
+func ParseDSN(dsn string) (*DSN, error) {
+	d := &DSN{
+		Net:     "tcp",
+		Timeout: DefaultConnectTimeout,
+		Params:  make(map[string]string),
+	}
+
+	// [user[:password]@][net[(addr)]]/dbname[?params]
+	slash := strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return nil, fmt.Errorf("dsn[%s].missing.the.slash.before.dbname", dsn)
+	}
+	head, tail := dsn[:slash], dsn[slash+1:]
+
+	// user[:password]
+	if at := strings.LastIndex(head, "@"); at >= 0 {
+		userinfo := head[:at]
+		head = head[at+1:]
+		if colon := strings.Index(userinfo, ":"); colon >= 0 {
+			d.User, d.Passwd = userinfo[:colon], userinfo[colon+1:]
+		} else {
+			d.User = userinfo
+		}
+	}
+
+	// net[(addr)]
+	if lp := strings.Index(head, "("); lp >= 0 {
+		if !strings.HasSuffix(head, ")") {
+			return nil, fmt.Errorf("dsn[%s].invalid.network.address", dsn)
+		}
+		d.Net = head[:lp]
+		for _, addr := range strings.Split(head[lp+1:len(head)-1], ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				d.Addrs = append(d.Addrs, addr)
+			}
+		}
+	} else if head != "" {
+		d.Net = head
+	}
+	if d.Net != "tcp" {
+		return nil, fmt.Errorf("dsn[%s].unsupported.network[%s]", dsn, d.Net)
+	}
+	if len(d.Addrs) == 0 {
+		d.Addrs = []string{DefaultDSNAddr}
+	}
+
+	// dbname[?params]
+	if q := strings.Index(tail, "?"); q >= 0 {
+		if err := d.parseParams(tail[q+1:]); err != nil {
+			return nil, err
+		}
+		tail = tail[:q]
+	}
+	dbname, err := url.PathUnescape(tail)
+	if err != nil {
+		return nil, fmt.Errorf("dsn.invalid.dbname[%s]:%v", tail, err)
+	}
+	d.DBName = dbname
+	return d, nil
+}
+
+func (d *DSN) parseParams(params string) error {
+	values, err := url.ParseQuery(params)
+	if err != nil {
+		return fmt.Errorf("dsn.invalid.params[%s]:%v", params, err)
+	}
+	for k := range values {
+		v := values.Get(k)
+		switch k {
+		case "charset":
+			// go-sql-driver allows a list like "utf8mb4,utf8", we take the first.
+			d.Charset = strings.Split(v, ",")[0]
+		case "timeout":
+			if d.Timeout, err = time.ParseDuration(v); err != nil {
+				return fmt.Errorf("dsn.invalid.timeout[%s]:%v", v, err)
+			}
+		case "tls":
+			d.TLS = v
+		case "compress":
+			if d.Compress, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("dsn.invalid.compress[%s]:%v", v, err)
+			}
+		case "clientFoundRows":
+			if d.ClientFoundRows, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("dsn.invalid.clientFoundRows[%s]:%v", v, err)
+			}
+		case "stmtCacheSize":
+			if d.StmtCacheSize, err = strconv.Atoi(v); err != nil || d.StmtCacheSize < 0 {
+				return fmt.Errorf("dsn.invalid.stmtCacheSize[%s]", v)
+			}
+		case "lazyRows":
+			if d.LazyRows, err = strconv.ParseBool(v); err != nil {
+				return fmt.Errorf("dsn.invalid.lazyRows[%s]:%v", v, err)
+			}
+		case "connectionAttributes":
+			// key1:value1,key2:value2
+			d.ConnectAttrs = make(map[string]string)
+			for _, kv := range strings.Split(v, ",") {
+				if kv = strings.TrimSpace(kv); kv == "" {
+					continue
+				}
+				pair := strings.SplitN(kv, ":", 2)
+				if len(pair) != 2 || pair[0] == "" {
+					return fmt.Errorf("dsn.invalid.connectionAttributes[%s]", v)
+				}
+				d.ConnectAttrs[pair[0]] = pair[1]
+			}
+		default:
+			d.Params[k] = v
+		}
+	}
+	return nil
+}
+
+// String formats the DSN in the go-sql-driver format.
+func (d *DSN) String() string {
+	buf := bytes.NewBufferString("")
+	if d.User != "" {
+		buf.WriteString(d.User)
+		if d.Passwd != "" {
+			buf.WriteString(":")
+			buf.WriteString(d.Passwd)
+		}
+		buf.WriteString("@")
+	}
+	fmt.Fprintf(buf, "%s(%s)/%s", d.Net, strings.Join(d.Addrs, ","), url.PathEscape(d.DBName))
+
+	values := url.Values{}
+	if d.Charset != "" {
+		values.Set("charset", d.Charset)
+	}
+	if d.Timeout != DefaultConnectTimeout {
+		values.Set("timeout", d.Timeout.String())
+	}
+	if d.TLS != "" {
+		values.Set("tls", d.TLS)
+	}
+	if d.Compress {
+		values.Set("compress", "true")
+	}
+	if d.ClientFoundRows {
+		values.Set("clientFoundRows", "true")
+	}
+	if d.StmtCacheSize > 0 {
+		values.Set("stmtCacheSize", strconv.Itoa(d.StmtCacheSize))
+	}
+	if d.LazyRows {
+		values.Set("lazyRows", "true")
+	}
+	if len(d.ConnectAttrs) > 0 {
+		attrs := make([]string, 0, len(d.ConnectAttrs))
+		for k, v := range d.ConnectAttrs {
+			attrs = append(attrs, k+":"+v)
+		}
+		sort.Strings(attrs)
+		values.Set("connectionAttributes", strings.Join(attrs, ","))
+	}
+	keys := make([]string, 0, len(d.Params))
+	for k := range d.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values.Set(k, d.Params[k])
+	}
+	if len(values) > 0 {
+		buf.WriteString("?")
+		buf.WriteString(values.Encode())
+	}
+	return buf.String()
+}
+
+// ClientConfig returns the config of the client connection, the tls is "true" or "skip-verify" if it's on.
+func (d *DSN) ClientConfig() (*ClientConfig, error) {
+	var tlsConfig *tls.Config
+	switch strings.ToLower(d.TLS) {
+	case "", "false":
+	case "true":
+		tlsConfig = &tls.Config{}
+	case "skip-verify":
+		tlsConfig = &tls.Config{InsecureSkipVerify: true}
+	default:
+		return nil, fmt.Errorf("dsn.tls[%s].not.supported", d.TLS)
+	}
+	if d.Compress {
+		return nil, fmt.Errorf("dsn.compress.not.supported.yet")
+	}
+	return &ClientConfig{
+		User:            d.User,
+		Passwd:          d.Passwd,
+		Addrs:           append([]string(nil), d.Addrs...),
+		DBName:          d.DBName,
+		Charset:         d.Charset,
+		Timeout:         d.Timeout,
+		TLS:             tlsConfig,
+		ClientFoundRows: d.ClientFoundRows,
+		StmtCacheSize:   d.StmtCacheSize,
+		LazyRows:        d.LazyRows,
+		ConnectAttrs:    d.ConnectAttrs,
+	}, nil
+}
+
+// NewConnWithDSN used to create a new client connection from the DSN string.
+func NewConnWithDSN(dsn string) (*conn, error) {
+	d, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := d.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return newConn(cfg.withDefaults())
+}
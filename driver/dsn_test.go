@@ -0,0 +1,132 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDSN(t *testing.T) {
+	tests := []struct {
+		dsn  string
+		want *DSN
+	}{
+		{
+			dsn:  "/",
+			want: &DSN{Net: "tcp", Addrs: []string{DefaultDSNAddr}, Timeout: DefaultConnectTimeout, Params: map[string]string{}},
+		},
+		{
+			dsn:  "mock:mock@tcp(127.0.0.1:3306)/test",
+			want: &DSN{User: "mock", Passwd: "mock", Net: "tcp", Addrs: []string{"127.0.0.1:3306"}, DBName: "test", Timeout: DefaultConnectTimeout, Params: map[string]string{}},
+		},
+		{
+			dsn:  "u:p@ss@w0rd@tcp(h1:3306,h2:3307)/db?charset=utf8mb4,utf8&timeout=5s&tls=false&compress=0&parseTime=true",
+			want: &DSN{User: "u", Passwd: "p@ss@w0rd", Net: "tcp", Addrs: []string{"h1:3306", "h2:3307"}, DBName: "db", Charset: "utf8mb4", Timeout: 5 * time.Second, TLS: "false", Params: map[string]string{"parseTime": "true"}},
+		},
+		{
+			dsn:  "root@tcp(h1)/db?connectionAttributes=program_name:app,tag:a:b",
+			want: &DSN{User: "root", Net: "tcp", Addrs: []string{"h1"}, DBName: "db", Timeout: DefaultConnectTimeout, ConnectAttrs: map[string]string{"program_name": "app", "tag": "a:b"}, Params: map[string]string{}},
+		},
+		{
+			dsn:  "root@tcp(h1)/db?clientFoundRows=true",
+			want: &DSN{User: "root", Net: "tcp", Addrs: []string{"h1"}, DBName: "db", Timeout: DefaultConnectTimeout, ClientFoundRows: true, Params: map[string]string{}},
+		},
+		{
+			dsn:  "root@tcp(h1)/db?stmtCacheSize=16",
+			want: &DSN{User: "root", Net: "tcp", Addrs: []string{"h1"}, DBName: "db", Timeout: DefaultConnectTimeout, StmtCacheSize: 16, Params: map[string]string{}},
+		},
+		{
+			dsn:  "root@tcp(h1)/db?lazyRows=true",
+			want: &DSN{User: "root", Net: "tcp", Addrs: []string{"h1"}, DBName: "db", Timeout: DefaultConnectTimeout, LazyRows: true, Params: map[string]string{}},
+		},
+		{
+			dsn:  "root@tcp/db",
+			want: &DSN{User: "root", Net: "tcp", Addrs: []string{DefaultDSNAddr}, DBName: "db", Timeout: DefaultConnectTimeout, Params: map[string]string{}},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ParseDSN(test.dsn)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, got)
+
+		// Round trip.
+		again, err := ParseDSN(got.String())
+		assert.Nil(t, err)
+		assert.Equal(t, got, again)
+	}
+}
+
+func TestParseDSNError(t *testing.T) {
+	dsns := []string{
+		"mock:mock@tcp(127.0.0.1:3306)",
+		"mock:mock@tcp(127.0.0.1:3306/test",
+		"mock:mock@unix(/tmp/mysql.sock)/test",
+		"mock:mock@tcp(127.0.0.1:3306)/test?timeout=xx",
+		"mock:mock@tcp(127.0.0.1:3306)/test?compress=xx",
+		"mock:mock@tcp(127.0.0.1:3306)/test?clientFoundRows=xx",
+		"mock:mock@tcp(127.0.0.1:3306)/test?stmtCacheSize=-1",
+		"mock:mock@tcp(127.0.0.1:3306)/test?%zz",
+		"mock:mock@tcp(127.0.0.1:3306)/test?connectionAttributes=xx",
+	}
+	for _, dsn := range dsns {
+		_, err := ParseDSN(dsn)
+		assert.NotNil(t, err, dsn)
+	}
+}
+
+func TestClientWithDSN(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	{
+		client, err := NewConnWithDSN(fmt.Sprintf("mock:mock@tcp(%s)/test?charset=utf8&timeout=1s", address))
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.Nil(t, client.Ping())
+	}
+
+	{
+		_, err := NewConnWithDSN(fmt.Sprintf("mock:mock@tcp(%s)/test?tls=true", address))
+		assert.NotNil(t, err)
+	}
+
+	{
+		_, err := NewConnWithDSN(fmt.Sprintf("mock:mock@tcp(%s)/test?compress=true", address))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestDSNClientConfig(t *testing.T) {
+	d, err := ParseDSN("u:p@tcp(h1:3306)/db?tls=skip-verify&clientFoundRows=true&stmtCacheSize=8&lazyRows=1")
+	assert.Nil(t, err)
+	cfg, err := d.ClientConfig()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"h1:3306"}, cfg.Addrs)
+	assert.True(t, cfg.TLS.InsecureSkipVerify)
+	assert.True(t, cfg.ClientFoundRows)
+	assert.True(t, cfg.LazyRows)
+	assert.Equal(t, 8, cfg.StmtCacheSize)
+	assert.Nil(t, cfg.Validate())
+
+	d, err = ParseDSN("u:p@tcp(h1:3306)/db?tls=custom")
+	assert.Nil(t, err)
+	_, err = d.ClientConfig()
+	assert.EqualError(t, err, "dsn.tls[custom].not.supported")
+}
@@ -0,0 +1,96 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// ErrorTranslator rewrites the error before it's sent to the client or kept as the warning of the SHOW ERRORS,
+// returns nil to keep the error as it is.
+// It's called by the sessions concurrently, maybe more than once for an error.
+type ErrorTranslator func(session *Session, err error) error
+
+// ChainErrorTranslators returns the translator runs the translators in order, each one gets the result of the previous.
+func ChainErrorTranslators(translators ...ErrorTranslator) ErrorTranslator {
+	return func(session *Session, err error) error {
+		for _, translate := range translators {
+			if terr := translate(session, err); terr != nil {
+				err = terr
+			}
+		}
+		return err
+	}
+}
+
+// MaskInternalErrors returns the translator replaces the internal errors with the ER_INTERNAL_ERROR of the message,
+// they are the errors not a SQLError, the ER_UNKNOWN_ERROR and the client errors of the backends.
+// The errors of the passed numbers are kept too.
+func MaskInternalErrors(message string, pass ...uint16) ErrorTranslator {
+	masked := sqldb.NewSQLError(sqldb.ER_INTERNAL_ERROR, "Internal error: %s", message)
+	return func(session *Session, err error) error {
+		var se *sqldb.SQLError
+		if !errors.As(err, &se) || se.Num == sqldb.ER_UNKNOWN_ERROR || sqldb.IsClientError(se) {
+			if sqldb.IsErrorNum(err, pass...) {
+				return nil
+			}
+			return masked
+		}
+		return nil
+	}
+}
+
+// ScrubErrorMessages returns the translator replaces the matches of the re in the messages with the repl,
+// like the host names of the backends. The code and state are kept.
+func ScrubErrorMessages(re *regexp.Regexp, repl string) ErrorTranslator {
+	return func(session *Session, err error) error {
+		se := sessionError(err)
+		if !re.MatchString(se.Message) {
+			return nil
+		}
+		return &sqldb.SQLError{Num: se.Num, State: se.State, Message: re.ReplaceAllString(se.Message, repl)}
+	}
+}
+
+// sessionError returns the SQLError the err is sent as.
+func sessionError(err error) *sqldb.SQLError {
+	var se *sqldb.SQLError
+	if errors.As(err, &se) {
+		return se
+	}
+	return sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "%v", err)
+}
+
+// SetErrorTranslator sets the translator of the errors of the coming sessions, nil sends the errors as they are.
+func (l *Listener) SetErrorTranslator(translator ErrorTranslator) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.translator = translator
+}
+
+func (l *Listener) errorTranslator() ErrorTranslator {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.translator
+}
+
+// translateError returns the error the client gets.
+func (s *Session) translateError(err error) error {
+	if s.translator == nil {
+		return err
+	}
+	if terr := s.translator(s, err); terr != nil {
+		return terr
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerErrorTranslator(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	hosts := regexp.MustCompile(`backend-[0-9]+\.internal:[0-9]+`)
+	svr.SetErrorTranslator(ChainErrorTranslators(
+		MaskInternalErrors("the statement failed", sqldb.CR_SERVER_GONE_ERROR),
+		ScrubErrorMessages(hosts, "backend"),
+	))
+
+	th.AddQueryError("select internal", errors.New("dial.backend-1.internal:3306.failed"))
+	th.AddQueryError("select lost", sqldb.NewSQLError(sqldb.CR_SERVER_LOST, "Lost connection to backend-1.internal:3306"))
+	th.AddQueryError("select gone", sqldb.NewSQLError(sqldb.CR_SERVER_GONE_ERROR, "backend-2.internal:3306 has gone away"))
+	th.AddQueryError("select table", sqldb.NewSQLError1(sqldb.ER_NO_SUCH_TABLE, "42S02", "Table 'db.t1' doesn't exist"))
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"select internal", "Internal error: the statement failed (errno 1815) (sqlstate HY000)"},
+		{"select lost", "Internal error: the statement failed (errno 1815) (sqlstate HY000)"},
+		{"select gone", "backend has gone away (errno 2006) (sqlstate HY000)"},
+		{"select table", "Table 'db.t1' doesn't exist (errno 1146) (sqlstate 42S02)"},
+	}
+	for _, test := range tests {
+		_, err := client.FetchAll(test.query, -1)
+		assert.Equal(t, test.want, err.Error(), test.query)
+	}
+
+	// The SHOW ERRORS is masked too.
+	_, err = client.FetchAll("select internal", -1)
+	assert.NotNil(t, err)
+	warnings, err := client.Warnings()
+	assert.Nil(t, err)
+	assert.Equal(t, []Warning{{Level: WarningLevelError, Code: sqldb.ER_INTERNAL_ERROR, Message: "Internal error: the statement failed"}}, warnings)
+}
+
+func TestErrorTranslatorNil(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	session := newSession(log, 1, nil)
+	err := errors.New("raw")
+	assert.Equal(t, err, session.translateError(err))
+
+	session.translator = func(*Session, error) error { return nil }
+	assert.Equal(t, err, session.translateError(err))
+	assert.Equal(t, err, ChainErrorTranslators()(session, err))
+}
@@ -0,0 +1,58 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// The client errors returned by the conn, the network or protocol error is the cause of them.
+// The server errors(the ERR packets) are returned as they are.
+//
+// CR_CONN_HOST_ERROR:   the dial failed.
+// CR_SERVER_GONE_ERROR: the write to the server failed.
+// CR_SERVER_LOST:       the read from the server failed.
+// CR_MALFORMED_PACKET:  the packet from the server can't be parsed.
+
+// dialError returns the CR_CONN_HOST_ERROR of the dial error.
+func dialError(err error, address string) error {
+	if err == nil {
+		return nil
+	}
+	return sqldb.WrapSQLError(err, sqldb.CR_CONN_HOST_ERROR, "Can't connect to MySQL server on '%s' (%v)", address, err)
+}
+
+// writeError returns the CR_SERVER_GONE_ERROR of the write error.
+func writeError(err error) error {
+	if err == nil || sqldb.IsClientError(err) {
+		return err
+	}
+	return sqldb.WrapSQLError(err, sqldb.CR_SERVER_GONE_ERROR, "MySQL server has gone away (%v)", err)
+}
+
+// readError returns the CR_SERVER_LOST of the network error or the CR_MALFORMED_PACKET of the others,
+// the when is like 'reading initial communication packet'.
+func readError(err error, when string) error {
+	if err == nil || sqldb.IsClientError(err) {
+		return err
+	}
+	if sqldb.IsConnErr(err) {
+		return sqldb.WrapSQLError(err, sqldb.CR_SERVER_LOST, "Lost connection to MySQL server %s (%v)", when, err)
+	}
+	return malformedError(err)
+}
+
+// malformedError returns the CR_MALFORMED_PACKET of the parse error.
+func malformedError(err error) error {
+	if err == nil || sqldb.IsClientError(err) {
+		return err
+	}
+	return sqldb.WrapSQLError(err, sqldb.CR_MALFORMED_PACKET, "Malformed packet (%v)", err)
+}
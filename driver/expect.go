@@ -0,0 +1,112 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Expectation is a query the TestHandler expects, it's answered once by the result or the error.
+type Expectation struct {
+	query     string
+	result    *sqltypes.Result
+	err       error
+	triggered bool
+}
+
+// WillReturn sets the result of the query.
+func (e *Expectation) WillReturn(result *sqltypes.Result) *Expectation {
+	e.result = result
+	return e
+}
+
+// WillReturnAffected sets the OK result of the statement by the affected rows and the last insert id.
+func (e *Expectation) WillReturnAffected(rowsAffected, insertID uint64) *Expectation {
+	e.result = &sqltypes.Result{RowsAffected: rowsAffected, InsertID: insertID}
+	return e
+}
+
+// WillReturnError rejects the query by the err.
+func (e *Expectation) WillReturnError(err error) *Expectation {
+	e.err = err
+	return e
+}
+
+func (e *Expectation) String() string {
+	return e.query
+}
+
+// ExpectQuery expects the query returns a resultset, set by the WillReturn.
+// The query is matched case-insensitively, the expectations are met in order unless MatchExpectationsInOrder(false).
+func (th *TestHandler) ExpectQuery(query string) *Expectation {
+	return th.expect(query)
+}
+
+// ExpectExec expects the statement returns the OK, set by the WillReturnAffected.
+func (th *TestHandler) ExpectExec(query string) *Expectation {
+	return th.expect(query)
+}
+
+func (th *TestHandler) expect(query string) *Expectation {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	e := &Expectation{query: strings.ToLower(strings.TrimSpace(query)), result: &sqltypes.Result{}}
+	th.expectations = append(th.expectations, e)
+	return e
+}
+
+// MatchExpectationsInOrder sets whether the expectations must be met in the order they were added,
+// it's true by default.
+func (th *TestHandler) MatchExpectationsInOrder(ordered bool) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.expectUnordered = !ordered
+}
+
+// ExpectationsWereMet returns the error of the first expectation not met, nil if all were.
+func (th *TestHandler) ExpectationsWereMet() error {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	for _, e := range th.expectations {
+		if !e.triggered {
+			return fmt.Errorf("mock.handler.expectation[%s].was.not.met", e)
+		}
+	}
+	return nil
+}
+
+// matchExpectation triggers the expectation of the lowercased query, nil if the query is not expected.
+// In order, the query expected later than the next one is an error.
+func (th *TestHandler) matchExpectation(query string) (*Expectation, error) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	query = strings.TrimSpace(query)
+	var next *Expectation
+	for _, e := range th.expectations {
+		if e.triggered {
+			continue
+		}
+		if next == nil {
+			next = e
+		}
+		if e.query != query {
+			continue
+		}
+		if !th.expectUnordered && e != next {
+			return nil, fmt.Errorf("mock.handler.query[%s].was.not.expected.next.expectation[%s]", query, next)
+		}
+		e.triggered = true
+		return e, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,149 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestExpectations(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	}
+	th.ExpectQuery("SELECT a FROM t1").WillReturn(result)
+	th.ExpectExec("UPDATE t1 SET a = 2").WillReturnAffected(3, 0)
+	th.ExpectExec("INSERT INTO t1 VALUES (1)").WillReturnError(sqldb.NewSQLError(sqldb.ER_DUP_ENTRY, "Duplicate entry '%s' for key '%s'", "1", "PRIMARY"))
+	assert.NotNil(t, th.ExpectationsWereMet())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Out of order.
+	{
+		_, err := client.FetchAll("UPDATE t1 SET a = 2", -1)
+		assert.NotNil(t, err)
+	}
+
+	{
+		qr, err := client.FetchAll("select a from t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+	{
+		qr, err := client.FetchAll("UPDATE t1 SET a = 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), qr.RowsAffected)
+	}
+	assert.NotNil(t, th.ExpectationsWereMet())
+	{
+		_, err := client.FetchAll("INSERT INTO t1 VALUES (1)", -1)
+		assert.Equal(t, uint16(sqldb.ER_DUP_ENTRY), err.(*sqldb.SQLError).Num)
+	}
+	assert.Nil(t, th.ExpectationsWereMet())
+
+	// The met expectations are not answered again.
+	{
+		_, err := client.FetchAll("SELECT a FROM t1", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestExpectationsUnordered(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.MatchExpectationsInOrder(false)
+	th.ExpectExec("DELETE FROM t1").WillReturnAffected(1, 0)
+	th.ExpectExec("INSERT INTO t1 VALUES (1)").WillReturnAffected(1, 7)
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	{
+		qr, err := client.FetchAll("INSERT INTO t1 VALUES (1)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(7), qr.InsertID)
+	}
+
+	// The queries not expected fall back to the AddQuery.
+	{
+		_, err := client.FetchAll("SELECT 1", -1)
+		assert.Nil(t, err)
+	}
+	assert.NotNil(t, th.ExpectationsWereMet())
+
+	{
+		_, err := client.FetchAll("DELETE FROM t1", -1)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, th.ExpectationsWereMet())
+}
+
+func TestQueryPatternFuncAndFingerprint(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.AddQueryPatternFunc(`select name from users where id = (\d+)`, func(query string, groups []string) (*sqltypes.Result, error) {
+		if groups[0] == "0" {
+			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "users")
+		}
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "name", Type: querypb.Type_VARCHAR}},
+			Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("user" + groups[0])}},
+		}, nil
+	})
+	th.AddQueryFingerprint("UPDATE t1 SET a = 1 WHERE id = 1", &sqltypes.Result{RowsAffected: 5})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	{
+		qr, err := client.FetchAll("SELECT name FROM users WHERE id = 42", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "user42", qr.Rows[0][0].String())
+	}
+	{
+		_, err := client.FetchAll("SELECT name FROM users WHERE id = 0", -1)
+		assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), err.(*sqldb.SQLError).Num)
+	}
+	{
+		qr, err := client.FetchAll("update t1  set a='x'\nwhere id = 99", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(5), qr.RowsAffected)
+	}
+	{
+		_, err := client.FetchAll("UPDATE t2 SET a = 1 WHERE id = 1", -1)
+		assert.NotNil(t, err)
+	}
+}
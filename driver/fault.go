@@ -0,0 +1,118 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Fault is injected into the queries by the TestHandler, to test the client timeout, retry and partial-read handling.
+type Fault struct {
+	// Latency delays the response, it's interrupted by the statement context.
+	Latency time.Duration
+
+	// Error rejects the query after the latency.
+	Error error
+
+	// Truncate sends a packet shorter than its header says and closes the connection.
+	Truncate bool
+
+	// Drop closes the connection after the DropAfterRows rows sent, the client gets the resultset cut.
+	Drop          bool
+	DropAfterRows int
+
+	// DripInterval sends the rows one packet a time with the interval.
+	DripInterval time.Duration
+}
+
+// before injects the faults before the result, the error returned ends the query.
+func (f *Fault) before(s *Session, query string) error {
+	if f.Latency > 0 {
+		select {
+		case <-s.Context().Done():
+			return fmt.Errorf("mock.fault.session[%v].query[%s].was.canceled:%v", s.ID(), query, s.Context().Err())
+		case <-time.After(f.Latency):
+		}
+	}
+	if f.Error != nil {
+		return f.Error
+	}
+	if f.Truncate {
+		// The header says 255 bytes but 1 byte follows.
+		s.watcher.Write([]byte{0xff, 0x00, 0x00, 0x01, 0x00})
+		s.Close()
+		return fmt.Errorf("mock.fault.session[%v].query[%s].packet.truncated", s.ID(), query)
+	}
+	return nil
+}
+
+// callback wraps the callback of the handler to drop the connection or drip the rows.
+func (f *Fault) callback(s *Session, query string, callback func(*sqltypes.Result) error) func(*sqltypes.Result) error {
+	if !f.Drop && f.DripInterval == 0 {
+		return callback
+	}
+
+	sent := 0
+	drop := func() error {
+		s.Close()
+		return fmt.Errorf("mock.fault.session[%v].query[%s].connection.dropped.after.%d.rows", s.ID(), query, sent)
+	}
+	return func(qr *sqltypes.Result) error {
+		if len(qr.Fields) == 0 {
+			if f.Drop {
+				return drop()
+			}
+			return callback(qr)
+		}
+
+		if qr.State == sqltypes.RState_None || qr.State == sqltypes.RState_Fields {
+			if err := callback(&sqltypes.Result{Fields: qr.Fields, State: sqltypes.RState_Fields}); err != nil {
+				return err
+			}
+		}
+		if qr.State == sqltypes.RState_None || qr.State == sqltypes.RState_Rows {
+			for _, row := range qr.Rows {
+				if f.Drop && sent >= f.DropAfterRows {
+					return drop()
+				}
+				time.Sleep(f.DripInterval)
+				if err := callback(&sqltypes.Result{Fields: qr.Fields, Rows: [][]sqltypes.Value{row}, State: sqltypes.RState_Rows}); err != nil {
+					return err
+				}
+				sent++
+			}
+		}
+		if qr.State == sqltypes.RState_None || qr.State == sqltypes.RState_Finished {
+			if f.Drop && sent >= f.DropAfterRows {
+				return drop()
+			}
+			finished := *qr
+			finished.Rows = nil
+			finished.State = sqltypes.RState_Finished
+			return callback(&finished)
+		}
+		return nil
+	}
+}
+
+// SetFault injects the fault into all the queries, nil clears it.
+func (th *TestHandler) SetFault(fault *Fault) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.fault = fault
+}
+
+// AddQueryFault adds a query and its result with the fault injected.
+func (th *TestHandler) AddQueryFault(query string, result *sqltypes.Result, fault *Fault) {
+	th.setCond(&Cond{Type: COND_FAULT, Query: query, Result: result, Fault: fault})
+}
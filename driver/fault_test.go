@@ -0,0 +1,96 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestFaultInjection(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}},
+	}
+	for _, v := range []string{"1", "2", "3"} {
+		result.Rows = append(result.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte(v))})
+	}
+	th.AddQueryFault("SELECT latency", result, &Fault{Latency: 100 * time.Millisecond})
+	th.AddQueryFault("SELECT error", result, &Fault{Error: sqldb.NewSQLError(sqldb.ER_CON_COUNT_ERROR, "Too many connections")})
+	th.AddQueryFault("SELECT drip", result, &Fault{DripInterval: 50 * time.Millisecond})
+	th.AddQueryFault("SELECT drop", result, &Fault{Drop: true, DropAfterRows: 1})
+	th.AddQueryFault("SELECT truncate", result, &Fault{Truncate: true})
+
+	newConn := func() Conn {
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		return client
+	}
+
+	client := newConn()
+	defer client.Close()
+	{
+		start := time.Now()
+		qr, err := client.FetchAll("SELECT latency", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(qr.Rows))
+		assert.True(t, time.Since(start) >= 100*time.Millisecond)
+	}
+	{
+		_, err := client.FetchAll("SELECT error", -1)
+		assert.Equal(t, uint16(sqldb.ER_CON_COUNT_ERROR), err.(*sqldb.SQLError).Num)
+	}
+	{
+		start := time.Now()
+		qr, err := client.FetchAll("SELECT drip", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, result.Rows, qr.Rows)
+		assert.True(t, time.Since(start) >= 150*time.Millisecond)
+	}
+
+	// The connection is dropped in the resultset.
+	{
+		client := newConn()
+		defer client.Close()
+		_, err := client.FetchAll("SELECT drop", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The packet is truncated.
+	{
+		client := newConn()
+		defer client.Close()
+		_, err := client.FetchAll("SELECT truncate", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The fault of all the queries.
+	{
+		th.AddQuery("SELECT 1", result)
+		th.SetFault(&Fault{Error: sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "injected")})
+		_, err := client.FetchAll("SELECT 1", -1)
+		assert.Equal(t, "injected (errno 1105) (sqlstate HY000)", err.Error())
+		th.SetFault(nil)
+		_, err = client.FetchAll("SELECT 1", -1)
+		assert.Nil(t, err)
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+)
+
+// FlushPolicy is when the rows of the resultset are flushed to the client before the result is written,
+// the first rows of a huge resultset arrive early and the buffered ones stay bounded.
+// The zero flushes at the end of every result the handler writes only.
+type FlushPolicy struct {
+	// Rows flushes after every Rows rows, 0 disables it.
+	Rows int
+
+	// Bytes flushes once the payloads of the rows appended since the last flush reach the Bytes, 0 disables it.
+	Bytes int
+}
+
+func (p FlushPolicy) validate() error {
+	if p.Rows < 0 || p.Bytes < 0 {
+		return fmt.Errorf("driver.flush.policy.rows[%d].bytes[%d].negative", p.Rows, p.Bytes)
+	}
+	return nil
+}
+
+// SetFlushPolicy sets the flush policy of the coming sessions.
+func (l *Listener) SetFlushPolicy(policy FlushPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushPolicy = policy
+	return nil
+}
+
+// FlushPolicy returns the flush policy of the coming sessions.
+func (l *Listener) FlushPolicy() FlushPolicy {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.flushPolicy
+}
+
+// SetFlushPolicy sets the flush policy of the session, like a smaller one for the streaming query.
+func (s *Session) SetFlushPolicy(policy FlushPolicy) error {
+	if err := policy.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.flushPolicy = policy
+	return nil
+}
+
+// FlushPolicy returns the flush policy of the session.
+func (s *Session) FlushPolicy() FlushPolicy {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.flushPolicy
+}
+
+// appendRow appends the row packet and flushes the stream if the policy says.
+func (s *Session) appendRow(policy FlushPolicy, data []byte) error {
+	if err := s.packets.Append(data); err != nil {
+		return err
+	}
+	s.pendingRows++
+	s.pendingBytes += len(data)
+	if (policy.Rows > 0 && s.pendingRows >= policy.Rows) || (policy.Bytes > 0 && s.pendingBytes >= policy.Bytes) {
+		return s.flush()
+	}
+	return nil
+}
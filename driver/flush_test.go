@@ -0,0 +1,90 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestSessionFlushPolicy(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	conn := packet.NewMockConn()
+	session := newSession(log, 1, conn)
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewVarCharField("a", 0, 8))
+	for i := 0; i < 3; i++ {
+		b.AppendRow("abc")
+	}
+	// Each row packet is 4 bytes of the header and 4 bytes of the value.
+	rows := b.Result()
+
+	// The rows are buffered until the result is flushed by default.
+	assert.Nil(t, session.writeRows(rows, false))
+	assert.Equal(t, 0, len(conn.Datas()))
+	assert.Nil(t, session.flush())
+	assert.Equal(t, 24, len(conn.Datas()))
+
+	// Every 2 rows.
+	conn = packet.NewMockConn()
+	session = newSession(log, 1, conn)
+	assert.Nil(t, session.SetFlushPolicy(FlushPolicy{Rows: 2}))
+	assert.Nil(t, session.writeRows(rows, false))
+	assert.Equal(t, 16, len(conn.Datas()))
+	assert.Nil(t, session.flush())
+
+	// The binary rows are 4 bytes of the header and 6 bytes of the payload.
+	conn = packet.NewMockConn()
+	session = newSession(log, 1, conn)
+	session.SetFlushPolicy(FlushPolicy{Rows: 2})
+	assert.Nil(t, session.writeRows(rows, true))
+	assert.Equal(t, 20, len(conn.Datas()))
+
+	// Every 8 bytes of the payloads.
+	conn = packet.NewMockConn()
+	session = newSession(log, 1, conn)
+	assert.Nil(t, session.SetFlushPolicy(FlushPolicy{Bytes: 8}))
+	assert.Nil(t, session.writeRows(rows, false))
+	assert.Equal(t, 16, len(conn.Datas()))
+
+	assert.EqualError(t, session.SetFlushPolicy(FlushPolicy{Rows: -1}), "driver.flush.policy.rows[-1].bytes[0].negative")
+	assert.Equal(t, FlushPolicy{Bytes: 8}, session.FlushPolicy())
+}
+
+func TestListenerFlushPolicy(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, FlushPolicy: FlushPolicy{Rows: 10, Bytes: 1024}}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	assert.Equal(t, FlushPolicy{Rows: 10, Bytes: 1024}, svr.FlushPolicy())
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 64))
+	for i := 0; i < 1000; i++ {
+		b.AppendRow(i, fmt.Sprintf("name%d", i))
+	}
+	th.AddQuery("SELECT * FROM t", b.Result())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	qr, err := client.FetchAll("SELECT * FROM t", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, b.Result().Rows, qr.Rows)
+
+	assert.NotNil(t, svr.SetFlushPolicy(FlushPolicy{Bytes: -1}))
+	assert.NotNil(t, (&ListenerConfig{FlushPolicy: FlushPolicy{Rows: -1}}).Validate())
+}
@@ -0,0 +1,582 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// InfoSchemaProvider supplies the schemas, the tables and the columns the Listener answers the information_schema
+// queries from, so the GUI clients can browse the server. The errors are sent to the client.
+type InfoSchemaProvider interface {
+	// Schemas returns the schemas the session can see.
+	Schemas(session *Session) ([]string, error)
+
+	// Tables returns the tables and the views of the schema.
+	Tables(session *Session, schema string) ([]*Table, error)
+
+	// Columns returns the columns of the table in the ordinal order.
+	Columns(session *Session, schema, table string) ([]*Column, error)
+}
+
+const infoSchemaName = "information_schema"
+
+// infoColumn is a column of a virtual table.
+type infoColumn struct {
+	name string
+	typ  querypb.Type
+}
+
+// infoTable is a virtual table of the information_schema.
+type infoTable struct {
+	name    string
+	columns []infoColumn
+}
+
+func varcharColumns(names ...string) []infoColumn {
+	columns := make([]infoColumn, len(names))
+	for i, name := range names {
+		columns[i] = infoColumn{name: name, typ: querypb.Type_VARCHAR}
+	}
+	return columns
+}
+
+var infoTables = []*infoTable{
+	{
+		name:    "SCHEMATA",
+		columns: varcharColumns("CATALOG_NAME", "SCHEMA_NAME", "DEFAULT_CHARACTER_SET_NAME", "DEFAULT_COLLATION_NAME", "SQL_PATH"),
+	},
+	{
+		name: "TABLES",
+		columns: append(varcharColumns("TABLE_CATALOG", "TABLE_SCHEMA", "TABLE_NAME", "TABLE_TYPE", "ENGINE"),
+			infoColumn{name: "TABLE_ROWS", typ: querypb.Type_UINT64},
+			infoColumn{name: "TABLE_COLLATION", typ: querypb.Type_VARCHAR},
+			infoColumn{name: "TABLE_COMMENT", typ: querypb.Type_VARCHAR}),
+	},
+	{
+		name: "COLUMNS",
+		columns: append(varcharColumns("TABLE_CATALOG", "TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME"),
+			append([]infoColumn{{name: "ORDINAL_POSITION", typ: querypb.Type_UINT64}},
+				varcharColumns("COLUMN_DEFAULT", "IS_NULLABLE", "DATA_TYPE", "COLUMN_TYPE", "COLLATION_NAME", "COLUMN_KEY", "EXTRA", "COLUMN_COMMENT")...)...),
+	},
+	{
+		name: "PROCESSLIST",
+		columns: append([]infoColumn{{name: "ID", typ: querypb.Type_UINT64}},
+			append(varcharColumns("USER", "HOST", "DB", "COMMAND"),
+				infoColumn{name: "TIME", typ: querypb.Type_UINT64},
+				infoColumn{name: "STATE", typ: querypb.Type_VARCHAR},
+				infoColumn{name: "INFO", typ: querypb.Type_VARCHAR})...),
+	},
+}
+
+func lookupInfoTable(name string) *infoTable {
+	for _, t := range infoTables {
+		if strings.EqualFold(t.name, name) {
+			return t
+		}
+	}
+	return nil
+}
+
+// rows returns the rows of the table in the order of the columns.
+func (t *infoTable) rows(l *Listener, p InfoSchemaProvider, s *Session, f *infoFilter) ([][]sqltypes.Value, error) {
+	switch t.name {
+	case "SCHEMATA":
+		return schemataRows(p, s)
+	case "TABLES":
+		return tablesRows(p, s, f)
+	case "COLUMNS":
+		return columnsRows(p, s, f)
+	}
+	return processlistRows(l, s), nil
+}
+
+func (t *infoTable) column(name string) int {
+	for i, c := range t.columns {
+		if strings.EqualFold(c.name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// infoFilter is the TABLE_SCHEMA and the TABLE_NAME the WHERE requires, the provider is asked only for them.
+type infoFilter struct {
+	schema, table string
+}
+
+// schemas returns the schemas of the provider and the information_schema, only the required one if any.
+func (f *infoFilter) schemas(p InfoSchemaProvider, s *Session) ([]string, error) {
+	schemas, err := p.Schemas(s)
+	if err != nil {
+		return nil, err
+	}
+	schemas = append(schemas, infoSchemaName)
+	if f.schema == "" {
+		return schemas, nil
+	}
+	for _, schema := range schemas {
+		if strings.EqualFold(schema, f.schema) {
+			return []string{schema}, nil
+		}
+	}
+	return nil, nil
+}
+
+func varchar(s string) sqltypes.Value {
+	return sqltypes.NewVarChar(s)
+}
+
+func uint64Value(n uint64) sqltypes.Value {
+	return sqltypes.MakeTrusted(querypb.Type_UINT64, strconv.AppendUint(nil, n, 10))
+}
+
+func schemataRows(p InfoSchemaProvider, s *Session) ([][]sqltypes.Value, error) {
+	schemas, err := p.Schemas(s)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]sqltypes.Value
+	for _, schema := range append([]string{infoSchemaName}, schemas...) {
+		rows = append(rows, []sqltypes.Value{varchar("def"), varchar(schema), varchar("utf8mb4"), varchar("utf8mb4_general_ci"), sqltypes.NULL})
+	}
+	return rows, nil
+}
+
+// tables returns the tables of the schema, the ones of the information_schema are the virtual tables.
+func (f *infoFilter) tables(p InfoSchemaProvider, s *Session, schema string) ([]*Table, error) {
+	var tables []*Table
+	if strings.EqualFold(schema, infoSchemaName) {
+		for _, t := range infoTables {
+			tables = append(tables, &Table{Name: t.name, Type: "SYSTEM VIEW"})
+		}
+	} else {
+		var err error
+		if tables, err = p.Tables(s, schema); err != nil {
+			return nil, err
+		}
+	}
+	if f.table == "" {
+		return tables, nil
+	}
+	for _, t := range tables {
+		if strings.EqualFold(t.Name, f.table) {
+			return []*Table{t}, nil
+		}
+	}
+	return nil, nil
+}
+
+func tablesRows(p InfoSchemaProvider, s *Session, f *infoFilter) ([][]sqltypes.Value, error) {
+	schemas, err := f.schemas(p, s)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]sqltypes.Value
+	for _, schema := range schemas {
+		tables, err := f.tables(p, s, schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tables {
+			engine, collation := varchar("InnoDB"), varchar("utf8mb4_general_ci")
+			if t.Type != "BASE TABLE" {
+				engine, collation = sqltypes.NULL, sqltypes.NULL
+			}
+			rows = append(rows, []sqltypes.Value{varchar("def"), varchar(schema), varchar(t.Name), varchar(t.Type), engine, sqltypes.NULL, collation, varchar("")})
+		}
+	}
+	return rows, nil
+}
+
+// dataType returns the DATA_TYPE of the COLUMN_TYPE like the int of the int(11) unsigned.
+func dataType(columnType string) string {
+	if i := strings.IndexAny(columnType, "( "); i >= 0 {
+		columnType = columnType[:i]
+	}
+	return strings.ToLower(columnType)
+}
+
+func columnsRows(p InfoSchemaProvider, s *Session, f *infoFilter) ([][]sqltypes.Value, error) {
+	schemas, err := f.schemas(p, s)
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]sqltypes.Value
+	for _, schema := range schemas {
+		tables, err := f.tables(p, s, schema)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range tables {
+			var columns []*Column
+			if vt := lookupInfoTable(t.Name); vt != nil && strings.EqualFold(schema, infoSchemaName) {
+				for _, c := range vt.columns {
+					typ := "varchar(64)"
+					if c.typ == querypb.Type_UINT64 {
+						typ = "bigint unsigned"
+					}
+					columns = append(columns, &Column{Name: c.name, Type: typ, Nullable: true})
+				}
+			} else if columns, err = p.Columns(s, schema, t.Name); err != nil {
+				return nil, err
+			}
+			for i, c := range columns {
+				def, nullable, collation := sqltypes.NULL, "NO", sqltypes.NULL
+				if c.Default != nil {
+					def = varchar(*c.Default)
+				}
+				if c.Nullable {
+					nullable = "YES"
+				}
+				if c.Collation != "" {
+					collation = varchar(c.Collation)
+				}
+				rows = append(rows, []sqltypes.Value{
+					varchar("def"), varchar(schema), varchar(t.Name), varchar(c.Name), uint64Value(uint64(i + 1)),
+					def, varchar(nullable), varchar(dataType(c.Type)), varchar(c.Type), collation, varchar(c.Key), varchar(c.Extra), varchar(c.Comment),
+				})
+			}
+		}
+	}
+	return rows, nil
+}
+
+func processlistRows(l *Listener, s *Session) [][]sqltypes.Value {
+	var rows [][]sqltypes.Value
+	for _, info := range l.Processlist() {
+		command, state := "Sleep", ""
+		if info.ID == s.ID() {
+			command, state = "Query", "executing"
+		}
+		db := sqltypes.NULL
+		if info.Schema != "" {
+			db = varchar(info.Schema)
+		}
+		rows = append(rows, []sqltypes.Value{uint64Value(uint64(info.ID)), varchar(info.User), varchar(info.Addr), db, varchar(command), uint64Value(0), varchar(state), sqltypes.NULL})
+	}
+	return rows
+}
+
+// SetInfoSchema sets the provider of the information_schema queries, nil leaves them to the handler.
+func (l *Listener) SetInfoSchema(p InfoSchemaProvider) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.infoSchema = p
+}
+
+func (l *Listener) infoSchemaProvider() InfoSchemaProvider {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.infoSchema
+}
+
+// infoSchemaSelect answers the SELECT of the SCHEMATA, the TABLES, the COLUMNS and the PROCESSLIST of the
+// information_schema by the provider: the columns or the *, the WHERE of the =, the !=, the IN, the LIKE and the
+// IS NULL of the columns with the AND, the OR and the NOT, the ORDER BY the columns and the LIMIT.
+// It returns false to leave the others like the joins and the functions to the handler.
+func (l *Listener) infoSchemaSelect(session *Session, query string) (*sqltypes.Result, bool, error) {
+	p := l.infoSchemaProvider()
+	if p == nil || sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, false, nil
+	}
+	stmt, err := session.Parse(query)
+	if err != nil {
+		return nil, false, nil
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.From) != 1 || sel.GroupBy != nil || sel.Having != nil || sel.Distinct != "" {
+		return nil, false, nil
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, false, nil
+	}
+	name, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil, false, nil
+	}
+	qualifier := name.Qualifier.String()
+	if qualifier == "" {
+		qualifier = session.Schema()
+	}
+	table := lookupInfoTable(name.Name.String())
+	if table == nil || !strings.EqualFold(qualifier, infoSchemaName) {
+		return nil, false, nil
+	}
+
+	// The output columns.
+	var outputs []int
+	qr := &sqltypes.Result{}
+	for _, e := range sel.SelectExprs {
+		switch e := e.(type) {
+		case *sqlparser.StarExpr:
+			for i, c := range table.columns {
+				outputs = append(outputs, i)
+				qr.Fields = append(qr.Fields, &querypb.Field{Name: c.name, Type: c.typ, Charset: session.fieldCharset(c.typ)})
+			}
+		case *sqlparser.AliasedExpr:
+			col, ok := e.Expr.(*sqlparser.ColName)
+			if !ok {
+				return nil, false, nil
+			}
+			i := table.column(col.Name.String())
+			if i < 0 {
+				return nil, false, nil
+			}
+			as := e.As.String()
+			if as == "" {
+				as = col.Name.String()
+			}
+			outputs = append(outputs, i)
+			qr.Fields = append(qr.Fields, &querypb.Field{Name: as, Type: table.columns[i].typ, Charset: session.fieldCharset(table.columns[i].typ)})
+		default:
+			return nil, false, nil
+		}
+	}
+
+	e := &infoEval{table: table}
+	filter := &infoFilter{}
+	if sel.Where != nil {
+		if !e.check(sel.Where.Expr) {
+			return nil, false, nil
+		}
+		e.filter(sel.Where.Expr, filter)
+	}
+	orders := make([]int, len(sel.OrderBy))
+	for i, order := range sel.OrderBy {
+		col, ok := order.Expr.(*sqlparser.ColName)
+		if !ok || table.column(col.Name.String()) < 0 {
+			return nil, false, nil
+		}
+		orders[i] = table.column(col.Name.String())
+	}
+	offset, count, ok := infoLimit(sel.Limit)
+	if !ok {
+		return nil, false, nil
+	}
+
+	rows, err := table.rows(l, p, session, filter)
+	if err != nil {
+		return nil, true, err
+	}
+	var matched [][]sqltypes.Value
+	for _, row := range rows {
+		if sel.Where == nil || e.match(sel.Where.Expr, row) {
+			matched = append(matched, row)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		for k, order := range sel.OrderBy {
+			if c := compareInfoValues(matched[i][orders[k]], matched[j][orders[k]]); c != 0 {
+				return (c < 0) != (order.Direction == sqlparser.DescScr)
+			}
+		}
+		return false
+	})
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+	if count >= 0 && count < len(matched) {
+		matched = matched[:count]
+	}
+	for _, row := range matched {
+		out := make([]sqltypes.Value, len(outputs))
+		for i, c := range outputs {
+			out[i] = row[c]
+		}
+		qr.Rows = append(qr.Rows, out)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, true, nil
+}
+
+// infoLimit returns the offset and the count of the LIMIT, the count is -1 if unlimited.
+func infoLimit(limit *sqlparser.Limit) (int, int, bool) {
+	if limit == nil {
+		return 0, -1, true
+	}
+	toInt := func(e sqlparser.Expr) (int, bool) {
+		if e == nil {
+			return 0, true
+		}
+		v, ok := e.(*sqlparser.SQLVal)
+		if !ok || v.Type != sqlparser.IntVal {
+			return 0, false
+		}
+		n, err := strconv.Atoi(string(v.Val))
+		return n, err == nil
+	}
+	offset, ok1 := toInt(limit.Offset)
+	count, ok2 := toInt(limit.Rowcount)
+	return offset, count, ok1 && ok2
+}
+
+// compareInfoValues compares the values, the numbers numerically, the strings case insensitively and the NULL first.
+func compareInfoValues(a, b sqltypes.Value) int {
+	switch {
+	case a.IsNull() && b.IsNull():
+		return 0
+	case a.IsNull():
+		return -1
+	case b.IsNull():
+		return 1
+	}
+	if a.Type() == querypb.Type_UINT64 && b.Type() == querypb.Type_UINT64 {
+		x, _ := a.ToUint64()
+		y, _ := b.ToUint64()
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+}
+
+// infoEval evaluates the WHERE of the virtual table.
+type infoEval struct {
+	table *infoTable
+}
+
+// check checks the expression can be evaluated.
+func (e *infoEval) check(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return e.check(expr.Left) && e.check(expr.Right)
+	case *sqlparser.OrExpr:
+		return e.check(expr.Left) && e.check(expr.Right)
+	case *sqlparser.NotExpr:
+		return e.check(expr.Expr)
+	case *sqlparser.ParenExpr:
+		return e.check(expr.Expr)
+	case *sqlparser.IsExpr:
+		return (expr.Operator == sqlparser.IsNullStr || expr.Operator == sqlparser.IsNotNullStr) && e.operand(expr.Expr)
+	case *sqlparser.ComparisonExpr:
+		if expr.Escape != nil || !e.operand(expr.Left) {
+			return false
+		}
+		switch expr.Operator {
+		case sqlparser.EqualStr, sqlparser.NotEqualStr, sqlparser.LikeStr, sqlparser.NotLikeStr:
+			return e.operand(expr.Right)
+		case sqlparser.InStr, sqlparser.NotInStr:
+			tuple, ok := expr.Right.(sqlparser.ValTuple)
+			if !ok {
+				return false
+			}
+			for _, v := range tuple {
+				if !e.operand(v) {
+					return false
+				}
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// operand checks the expression is a column of the table or a literal.
+func (e *infoEval) operand(expr sqlparser.Expr) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.ColName:
+		return e.table.column(expr.Name.String()) >= 0
+	case *sqlparser.SQLVal:
+		return expr.Type == sqlparser.StrVal || expr.Type == sqlparser.IntVal
+	case *sqlparser.NullVal:
+		return true
+	}
+	return false
+}
+
+// value returns the value of the checked operand.
+func (e *infoEval) value(expr sqlparser.Expr, row []sqltypes.Value) sqltypes.Value {
+	switch expr := expr.(type) {
+	case *sqlparser.ColName:
+		return row[e.table.column(expr.Name.String())]
+	case *sqlparser.SQLVal:
+		if expr.Type == sqlparser.IntVal {
+			return sqltypes.MakeTrusted(querypb.Type_UINT64, expr.Val)
+		}
+		return varchar(string(expr.Val))
+	}
+	return sqltypes.NULL
+}
+
+// match evaluates the checked expression on the row, the comparisons with the NULL are false.
+func (e *infoEval) match(expr sqlparser.Expr, row []sqltypes.Value) bool {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return e.match(expr.Left, row) && e.match(expr.Right, row)
+	case *sqlparser.OrExpr:
+		return e.match(expr.Left, row) || e.match(expr.Right, row)
+	case *sqlparser.NotExpr:
+		return !e.match(expr.Expr, row)
+	case *sqlparser.ParenExpr:
+		return e.match(expr.Expr, row)
+	case *sqlparser.IsExpr:
+		return e.value(expr.Expr, row).IsNull() == (expr.Operator == sqlparser.IsNullStr)
+	case *sqlparser.ComparisonExpr:
+		left := e.value(expr.Left, row)
+		if left.IsNull() {
+			return false
+		}
+		switch expr.Operator {
+		case sqlparser.EqualStr, sqlparser.NotEqualStr:
+			right := e.value(expr.Right, row)
+			return !right.IsNull() && (compareInfoValues(left, right) == 0) == (expr.Operator == sqlparser.EqualStr)
+		case sqlparser.LikeStr, sqlparser.NotLikeStr:
+			right := e.value(expr.Right, row)
+			return !right.IsNull() && likeRegexp(right.String()).MatchString(left.String()) == (expr.Operator == sqlparser.LikeStr)
+		case sqlparser.InStr, sqlparser.NotInStr:
+			in := false
+			for _, v := range expr.Right.(sqlparser.ValTuple) {
+				if right := e.value(v, row); !right.IsNull() && compareInfoValues(left, right) == 0 {
+					in = true
+					break
+				}
+			}
+			return in == (expr.Operator == sqlparser.InStr)
+		}
+	}
+	return false
+}
+
+// filter takes the TABLE_SCHEMA and the TABLE_NAME compared by the = of the top AND terms.
+func (e *infoEval) filter(expr sqlparser.Expr, f *infoFilter) {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		e.filter(expr.Left, f)
+		e.filter(expr.Right, f)
+	case *sqlparser.ParenExpr:
+		e.filter(expr.Expr, f)
+	case *sqlparser.ComparisonExpr:
+		col, ok := expr.Left.(*sqlparser.ColName)
+		val, isVal := expr.Right.(*sqlparser.SQLVal)
+		if !ok || !isVal || expr.Operator != sqlparser.EqualStr || val.Type != sqlparser.StrVal {
+			return
+		}
+		switch {
+		case col.Name.EqualString("table_schema"):
+			f.schema = string(val.Val)
+		case col.Name.EqualString("table_name"):
+			f.table = string(val.Val)
+		}
+	}
+}
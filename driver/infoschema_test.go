@@ -0,0 +1,108 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// testInfoSchema is the schema db1 of the tables t1 and v1, the calls of the Tables are counted.
+type testInfoSchema struct {
+	tables int
+}
+
+func (p *testInfoSchema) Schemas(session *Session) ([]string, error) {
+	return []string{"db1", "db2"}, nil
+}
+
+func (p *testInfoSchema) Tables(session *Session, schema string) ([]*Table, error) {
+	p.tables++
+	if schema == "db2" {
+		return nil, sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, "Unknown database '%s'", schema)
+	}
+	return []*Table{{Name: "t1", Type: "BASE TABLE"}, {Name: "v1", Type: "VIEW"}}, nil
+}
+
+func (p *testInfoSchema) Columns(session *Session, schema, table string) ([]*Column, error) {
+	def := "0"
+	return []*Column{
+		{Name: "id", Type: "int(11) unsigned", Key: "PRI"},
+		{Name: "name", Type: "varchar(32)", Collation: "utf8mb4_general_ci", Nullable: true, Default: &def},
+	}, nil
+}
+
+func TestListenerInfoSchema(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	p := &testInfoSchema{}
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, InfoSchema: p}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT * FROM information_schema.ENGINES", &sqltypes.Result{})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	fetch := func(query string) [][]string {
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		var rows [][]string
+		for _, row := range qr.Rows {
+			var r []string
+			for _, v := range row {
+				r = append(r, v.String())
+			}
+			rows = append(rows, r)
+		}
+		return rows
+	}
+
+	assert.Equal(t, [][]string{{"information_schema"}, {"db1"}, {"db2"}}, fetch("SELECT schema_name FROM information_schema.schemata"))
+	assert.Equal(t, [][]string{{"db2"}, {"db1"}}, fetch("select SCHEMA_NAME as s from INFORMATION_SCHEMA.SCHEMATA where schema_name like 'db%' order by schema_name desc"))
+
+	// The provider is asked only for the schema of the WHERE.
+	rows := fetch("SELECT TABLE_NAME, TABLE_TYPE, ENGINE FROM information_schema.TABLES WHERE TABLE_SCHEMA = 'db1' AND table_type IN ('BASE TABLE', 'VIEW') ORDER BY TABLE_NAME")
+	assert.Equal(t, [][]string{{"t1", "BASE TABLE", "InnoDB"}, {"v1", "VIEW", ""}}, rows)
+	assert.Equal(t, 1, p.tables)
+	assert.Equal(t, 4, len(fetch("SELECT * FROM information_schema.tables WHERE table_schema = 'information_schema'")))
+
+	rows = fetch("SELECT COLUMN_NAME, ORDINAL_POSITION, COLUMN_DEFAULT, IS_NULLABLE, DATA_TYPE, COLUMN_TYPE, COLUMN_KEY FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = 'db1' AND TABLE_NAME = 't1' AND NOT (column_key != 'PRI' AND column_default IS NULL)")
+	assert.Equal(t, [][]string{{"id", "1", "", "NO", "int", "int(11) unsigned", "PRI"}, {"name", "2", "0", "YES", "varchar", "varchar(32)", ""}}, rows)
+	assert.Equal(t, [][]string{{"SCHEMA_NAME"}}, fetch("SELECT column_name FROM information_schema.columns WHERE table_schema = 'information_schema' AND table_name = 'schemata' LIMIT 1, 1"))
+
+	rows = fetch("SELECT ID, USER, COMMAND FROM information_schema.PROCESSLIST")
+	assert.Equal(t, [][]string{{fmt.Sprintf("%d", client.ConnectionID()), "mock", "Query"}}, rows)
+
+	// The current schema is the information_schema.
+	other, err := NewConn("mock", "mock", svr.Addr(), "information_schema", "")
+	assert.Nil(t, err)
+	defer other.Close()
+	qr, err := other.FetchAll("SELECT * FROM schemata", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(qr.Rows))
+
+	// The errors of the provider.
+	_, err = client.FetchAll("SELECT * FROM information_schema.TABLES", -1)
+	assert.Equal(t, uint16(sqldb.ER_BAD_DB_ERROR), err.(*sqldb.SQLError).Num)
+
+	// The unsupported ones are left to the handler.
+	_, err = client.FetchAll("SELECT * FROM information_schema.ENGINES", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("SELECT COUNT(*) FROM information_schema.TABLES", -1)
+	assert.NotNil(t, err)
+}
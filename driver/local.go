@@ -0,0 +1,158 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strconv"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// localEval evaluates one select expression by the session state, returns false if it can't.
+type localEval func(s *Session, expr sqlparser.Expr) (sqltypes.Value, bool)
+
+// localSelect answers the 'SELECT expr [AS alias], ...' without the FROM if all the exprs are evaluated,
+// returns false to leave the query to the handler.
+func (s *Session) localSelect(query string, eval localEval) (*sqltypes.Result, bool) {
+	if sqlparser.Preview(query) != sqlparser.StmtSelect {
+		return nil, false
+	}
+	stmt, err := s.Parse(query)
+	if err != nil {
+		return nil, false
+	}
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || sel.Where != nil || sel.GroupBy != nil || sel.Having != nil || sel.OrderBy != nil || sel.Limit != nil {
+		return nil, false
+	}
+	if sqlparser.String(sel.From) != "dual" {
+		return nil, false
+	}
+
+	qr := &sqltypes.Result{}
+	row := make([]sqltypes.Value, 0, len(sel.SelectExprs))
+	for _, e := range sel.SelectExprs {
+		aliased, ok := e.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, false
+		}
+		v, ok := eval(s, aliased.Expr)
+		if !ok {
+			return nil, false
+		}
+		name := aliased.As.String()
+		if name == "" {
+			name = sqlparser.String(aliased.Expr)
+		}
+		qr.Fields = append(qr.Fields, &querypb.Field{Name: name, Type: v.Type(), Charset: s.fieldCharset(v.Type())})
+		row = append(row, v)
+	}
+	qr.Rows = [][]sqltypes.Value{row}
+	return qr, true
+}
+
+// evalSessionFunction evaluates the LAST_INSERT_ID(), ROW_COUNT(), USER() and CURRENT_USER(),
+// the LAST_INSERT_ID(N) sets the value for the following calls as MySQL does.
+// The USER() is the AuthUser and the CURRENT_USER() is the EffectiveUser of the proxied session.
+func evalSessionFunction(s *Session, expr sqlparser.Expr) (sqltypes.Value, bool) {
+	fn, ok := expr.(*sqlparser.FuncExpr)
+	if !ok || !fn.Qualifier.IsEmpty() || fn.Distinct {
+		return sqltypes.Value{}, false
+	}
+
+	switch fn.Name.Lowered() {
+	case "last_insert_id":
+		switch len(fn.Exprs) {
+		case 0:
+		case 1:
+			arg, ok := fn.Exprs[0].(*sqlparser.AliasedExpr)
+			if !ok {
+				return sqltypes.Value{}, false
+			}
+			val, ok := arg.Expr.(*sqlparser.SQLVal)
+			if !ok || val.Type != sqlparser.IntVal {
+				return sqltypes.Value{}, false
+			}
+			id, err := strconv.ParseUint(string(val.Val), 10, 64)
+			if err != nil {
+				return sqltypes.Value{}, false
+			}
+			s.SetLastInsertID(id)
+		default:
+			return sqltypes.Value{}, false
+		}
+		return sqltypes.MakeTrusted(querypb.Type_UINT64, strconv.AppendUint(nil, s.LastInsertID(), 10)), true
+	case "row_count":
+		if len(fn.Exprs) != 0 {
+			return sqltypes.Value{}, false
+		}
+		return sqltypes.MakeTrusted(querypb.Type_INT64, strconv.AppendInt(nil, s.RowCount(), 10)), true
+	case "user", "session_user", "system_user":
+		if len(fn.Exprs) != 0 {
+			return sqltypes.Value{}, false
+		}
+		return sqltypes.NewVarChar(s.AuthUser() + "@" + s.host()), true
+	case "current_user":
+		if len(fn.Exprs) != 0 {
+			return sqltypes.Value{}, false
+		}
+		return sqltypes.NewVarChar(s.EffectiveUser() + "@" + s.host()), true
+	}
+	return sqltypes.Value{}, false
+}
+
+// trackResult keeps the LAST_INSERT_ID and the ROW_COUNT of the statement by the result written,
+// the ROW_COUNT is -1 for the statements returning a resultset.
+func (s *Session) trackResult(result *sqltypes.Result) {
+	affected := s.affectedRows(result)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if result.InsertID > 0 {
+		s.lastInsertID = result.InsertID
+	}
+	switch {
+	case len(result.Fields) == 0 && result.State == sqltypes.RState_None:
+		s.rowCount = int64(affected)
+	case result.State == sqltypes.RState_None || result.State == sqltypes.RState_Fields:
+		s.rowCount = -1
+	}
+}
+
+// LastInsertID returns the LAST_INSERT_ID of the session,
+// it's the last non-zero insert id the handler returned.
+func (s *Session) LastInsertID() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lastInsertID
+}
+
+// SetLastInsertID sets the LAST_INSERT_ID of the session, the handler calls it if it assigns the id by itself.
+func (s *Session) SetLastInsertID(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastInsertID = id
+}
+
+// RowCount returns the ROW_COUNT of the session, the affected rows of the last statement,
+// -1 if it returned a resultset or failed.
+func (s *Session) RowCount() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rowCount
+}
+
+func (s *Session) setRowCount(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rowCount = n
+}
@@ -0,0 +1,98 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// resultSize estimates the bytes the result buffers, it's the field names and the row values.
+func resultSize(result *sqltypes.Result) int64 {
+	var n int64
+	for _, f := range result.Fields {
+		n += int64(len(f.Database) + len(f.Table) + len(f.OrgTable) + len(f.Name) + len(f.OrgName))
+	}
+	for _, row := range result.Rows {
+		for _, v := range row {
+			n += int64(len(v.Raw()))
+		}
+	}
+	return n
+}
+
+// MemoryUsage returns the bytes accounted to the running statement of the session,
+// it's the incoming packet, the result being written and the ones the handler allocated.
+func (s *Session) MemoryUsage() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.memUsed
+}
+
+// AllocMemory accounts the n bytes to the statement, the handler charges its own buffers by it.
+// Exceeding the session memory limit kills the statement: the context is canceled and
+// the ER_OUT_OF_RESOURCES is returned, also to the client once the handler returns.
+func (s *Session) AllocMemory(n int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.memErr != nil {
+		return s.memErr
+	}
+	if s.memLimit > 0 && s.memUsed+n > s.memLimit {
+		s.memErr = sqldb.NewSQLError(sqldb.ER_OUT_OF_RESOURCES, "Out of resources: the session[%d] needs %d bytes of the memory, it exceeds the limit %d bytes", s.id, s.memUsed+n, s.memLimit)
+		if s.cancel != nil {
+			s.cancel()
+		}
+		return s.memErr
+	}
+	s.memUsed += n
+	return nil
+}
+
+// FreeMemory releases the n bytes accounted by the AllocMemory.
+func (s *Session) FreeMemory(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memUsed -= n
+	if s.memUsed < 0 {
+		s.memUsed = 0
+	}
+}
+
+// resetMemory clears the accounting before the next command, the usage is per statement
+// except the long data kept for the executions.
+func (s *Session) resetMemory() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.memUsed = s.longDataMem
+	s.memErr = nil
+}
+
+// allocLongData accounts the n bytes of the long data, they stay accounted until the freeLongData.
+func (s *Session) allocLongData(n int64) error {
+	if err := s.AllocMemory(n); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.longDataMem += n
+	return nil
+}
+
+// freeLongData releases the n bytes accounted by the allocLongData.
+func (s *Session) freeLongData(n int64) {
+	s.mu.Lock()
+	s.longDataMem -= n
+	if s.longDataMem < 0 {
+		s.longDataMem = 0
+	}
+	s.mu.Unlock()
+	s.FreeMemory(n)
+}
@@ -13,12 +13,15 @@ import (
 	"fmt"
 	"math/rand"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/XeLabs/go-mysqlstack/proto"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
 	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/XeLabs/go-mysqlstack/xlog"
 )
@@ -36,8 +39,13 @@ type exprResult struct {
 	expr   *regexp.Regexp
 	result *sqltypes.Result
 	err    error
+	fn     ResultFunc
 }
 
+// ResultFunc generates the result of the query matched by the AddQueryPatternFunc,
+// the groups are the capture groups of the pattern.
+type ResultFunc func(query string, groups []string) (*sqltypes.Result, error)
+
 type CondType int
 
 const (
@@ -46,6 +54,7 @@ const (
 	COND_ERROR
 	COND_PANIC
 	COND_STREAM
+	COND_FAULT
 )
 
 type Cond struct {
@@ -66,6 +75,9 @@ type Cond struct {
 
 	// Delay(ms) for results return
 	Delay int
+
+	// Fault injected by the AddQueryFault
+	Fault *Fault
 }
 
 type CondList struct {
@@ -92,17 +104,32 @@ type TestHandler struct {
 	patterns      []exprResult
 	patternErrors []exprResult
 
+	// fingerprints maps the query fingerprints to results.
+	fingerprints map[string]*sqltypes.Result
+
 	// How many times a query was called.
 	queryCalled map[string]int
+
+	// slaves are the registered slaves by the session id.
+	slaves map[uint32]*proto.RegisterSlave
+
+	// expectations are the queries expected by the ExpectQuery and ExpectExec.
+	expectations    []*Expectation
+	expectUnordered bool
+
+	// fault is injected into all the queries.
+	fault *Fault
 }
 
 func NewTestHandler(log *xlog.Log) *TestHandler {
 	return &TestHandler{
-		log:         log,
-		ss:          make(map[uint32]*SessionTuple),
-		conds:       make(map[string]*Cond),
-		queryCalled: make(map[string]int),
-		condList:    make(map[string]*CondList),
+		log:          log,
+		ss:           make(map[uint32]*SessionTuple),
+		conds:        make(map[string]*Cond),
+		queryCalled:  make(map[string]int),
+		condList:     make(map[string]*CondList),
+		fingerprints: make(map[string]*sqltypes.Result),
+		slaves:       make(map[uint32]*proto.RegisterSlave),
 	}
 }
 
@@ -122,6 +149,8 @@ func (th *TestHandler) ResetAll() {
 	}
 	th.patterns = make([]exprResult, 0, 4)
 	th.patternErrors = make([]exprResult, 0, 4)
+	th.fingerprints = make(map[string]*sqltypes.Result)
+	th.expectations = nil
 }
 
 func (th *TestHandler) ResetPatternErrors() {
@@ -167,6 +196,34 @@ func (th *TestHandler) SessionClosed(s *Session) {
 	th.mu.Lock()
 	defer th.mu.Unlock()
 	delete(th.ss, s.ID())
+	delete(th.slaves, s.ID())
+}
+
+// ComRegisterSlave impl.
+func (th *TestHandler) ComRegisterSlave(s *Session, slave *proto.RegisterSlave) error {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	for id, other := range th.slaves {
+		if id != s.ID() && other.ServerID == slave.ServerID {
+			return sqldb.NewSQLError1(sqldb.ER_MASTER_FATAL_ERROR_READING_BINLOG, "HY000", "A slave with the same server_uuid/server_id as this slave has connected to the master")
+		}
+	}
+	th.slaves[s.ID()] = slave
+	return nil
+}
+
+// Slaves returns the registered slaves ordered by the server id, like the SHOW SLAVE HOSTS.
+func (th *TestHandler) Slaves() []*proto.RegisterSlave {
+	th.mu.RLock()
+	defer th.mu.RUnlock()
+	slaves := make([]*proto.RegisterSlave, 0, len(th.slaves))
+	for _, slave := range th.slaves {
+		slaves = append(slaves, slave)
+	}
+	sort.Slice(slaves, func(i, j int) bool {
+		return slaves[i].ServerID < slaves[j].ServerID
+	})
+	return slaves
 }
 
 // ComInitDB impl.
@@ -186,8 +243,27 @@ func (th *TestHandler) ComQuery(s *Session, query string, callback func(qr *sqlt
 	th.queryCalled[query]++
 	cond := th.conds[query]
 	sessTuple := th.ss[s.ID()]
+	fault := th.fault
 	th.mu.Unlock()
 
+	// The fault from SetFault().
+	if fault != nil {
+		if err := fault.before(s, query); err != nil {
+			return err
+		}
+		callback = fault.callback(s, query, callback)
+	}
+
+	// Check the expectations from ExpectQuery() and ExpectExec().
+	if e, err := th.matchExpectation(query); err != nil {
+		return err
+	} else if e != nil {
+		if e.err != nil {
+			return e.err
+		}
+		return callback(e.result)
+	}
+
 	if cond != nil {
 		switch cond.Type {
 		case COND_DELAY:
@@ -196,11 +272,18 @@ func (th *TestHandler) ComQuery(s *Session, query string, callback func(qr *sqlt
 			case <-sessTuple.killed:
 				sessTuple.closed = true
 				return fmt.Errorf("mock.session[%v].query[%s].was.killed...", s.ID(), query)
+			case <-s.Context().Done():
+				return fmt.Errorf("mock.session[%v].query[%s].was.canceled:%v", s.ID(), query, s.Context().Err())
 			case <-time.After(time.Millisecond * time.Duration(cond.Delay)):
 				log.Debug("mock.handler.delay.done...")
 			}
 			callback(cond.Result)
 			return nil
+		case COND_FAULT:
+			if err := cond.Fault.before(s, query); err != nil {
+				return err
+			}
+			return cond.Fault.callback(s, query, callback)(cond.Result)
 		case COND_ERROR:
 			return cond.Error
 		case COND_PANIC:
@@ -218,6 +301,11 @@ func (th *TestHandler) ComQuery(s *Session, query string, callback func(qr *sqlt
 
 			// Send Row by row for stream.
 			for _, row := range cond.Result.Rows {
+				select {
+				case <-sessTuple.killed:
+					return sqldb.NewSQLError(sqldb.ER_QUERY_INTERRUPTED, "")
+				default:
+				}
 				qr := &sqltypes.Result{Fields: flds, State: sqltypes.RState_Rows}
 				qr.Rows = append(qr.Rows, row)
 				if err := callback(qr); err != nil {
@@ -234,6 +322,23 @@ func (th *TestHandler) ComQuery(s *Session, query string, callback func(qr *sqlt
 		}
 	}
 
+	// kill query filter, the session is kept.
+	if strings.HasPrefix(query, "kill query ") {
+		if id, err := strconv.ParseUint(strings.TrimPrefix(query, "kill query "), 10, 32); err == nil {
+			th.mu.Lock()
+			if sessTuple, ok := th.ss[uint32(id)]; ok {
+				log.Debug("mock.session[%v].to.kill.the.query.of.session[%v]...", s.ID(), id)
+				select {
+				case sessTuple.killed <- true:
+				default:
+				}
+			}
+			th.mu.Unlock()
+		}
+		callback(&sqltypes.Result{})
+		return nil
+	}
+
 	// kill filter.
 	if strings.HasPrefix(query, "kill") {
 		if id, err := strconv.ParseUint(strings.Split(query, " ")[1], 10, 32); err == nil {
@@ -261,12 +366,25 @@ func (th *TestHandler) ComQuery(s *Session, query string, callback func(qr *sqlt
 		}
 	}
 	for _, pat := range th.patterns {
-		if pat.expr.MatchString(query) {
+		if m := pat.expr.FindStringSubmatch(query); m != nil {
+			if pat.fn != nil {
+				qr, err := pat.fn(query, m[1:])
+				if err != nil {
+					return err
+				}
+				return callback(qr)
+			}
 			callback(pat.result)
 			return nil
 		}
 	}
 
+	// Check query fingerprints from AddQueryFingerprint().
+	if qr, ok := th.fingerprints[sqlparser.Fingerprint(query)]; ok {
+		callback(qr)
+		return nil
+	}
+
 	if v, ok := th.condList[query]; ok {
 		idx := 0
 		if v.idx >= v.len {
@@ -329,14 +447,32 @@ func (th *TestHandler) AddQueryPattern(queryPattern string, expectedResult *sqlt
 	result := *expectedResult
 	th.mu.Lock()
 	defer th.mu.Unlock()
-	th.patterns = append(th.patterns, exprResult{expr, &result, nil})
+	th.patterns = append(th.patterns, exprResult{expr, &result, nil, nil})
+}
+
+// AddQueryPatternFunc adds a pattern like the AddQueryPattern, the result is generated by the fn
+// with the capture groups, the queries differ in the values can share it.
+// The fn is called with the handler locked, it must not call the handler.
+func (th *TestHandler) AddQueryPatternFunc(queryPattern string, fn ResultFunc) {
+	expr := regexp.MustCompile("(?is)^" + queryPattern + "$")
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.patterns = append(th.patterns, exprResult{expr: expr, fn: fn})
+}
+
+// AddQueryFingerprint adds an expected result for the queries with the same sqlparser.Fingerprint,
+// so the literals and the blanks are ignored. It's checked if no exact matches or patterns are found.
+func (th *TestHandler) AddQueryFingerprint(query string, result *sqltypes.Result) {
+	th.mu.Lock()
+	defer th.mu.Unlock()
+	th.fingerprints[sqlparser.Fingerprint(query)] = result
 }
 
 func (th *TestHandler) AddQueryErrorPattern(queryPattern string, err error) {
 	expr := regexp.MustCompile("(?is)^" + queryPattern + "$")
 	th.mu.Lock()
 	defer th.mu.Unlock()
-	th.patternErrors = append(th.patternErrors, exprResult{expr, nil, err})
+	th.patternErrors = append(th.patternErrors, exprResult{expr, nil, err, nil})
 }
 
 // This code was derived from https://github.com/youtube/vitess.
@@ -352,12 +488,18 @@ func (th *TestHandler) GetQueryCalledNum(query string) int {
 }
 
 func MockMysqlServer(log *xlog.Log, h Handler) (svr *Listener, err error) {
+	return MockMysqlServerWithConfig(&ListenerConfig{Log: log}, h)
+}
+
+// MockMysqlServerWithConfig starts the mock server by the config on a random port, the cfg.Address is ignored.
+func MockMysqlServerWithConfig(cfg *ListenerConfig, h Handler) (svr *Listener, err error) {
+	c := *cfg
 	port := randomPort(10000, 20000)
-	addr := fmt.Sprintf(":%d", port)
+	c.Address = fmt.Sprintf(":%d", port)
 	for i := 0; i < 5; i++ {
-		if svr, err = NewListener(log, addr, h); err != nil {
+		if svr, err = NewListenerWithConfig(&c, h); err != nil {
 			port = randomPort(5000, 20000)
-			addr = fmt.Sprintf("127.0.0.1:%d", port)
+			c.Address = fmt.Sprintf("127.0.0.1:%d", port)
 		} else {
 			break
 		}
@@ -370,6 +512,6 @@ func MockMysqlServer(log *xlog.Log, h Handler) (svr *Listener, err error) {
 		svr.Accept()
 	}()
 	time.Sleep(100 * time.Millisecond)
-	log.Debug("mock.server[%v].start...", addr)
+	svr.log.Debug("mock.server[%v].start...", c.Address)
 	return
 }
@@ -0,0 +1,130 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// NamedStmt is the prepared statement of the :name placeholders, the args are bound by the names.
+type NamedStmt struct {
+	*Stmt
+
+	// Names are the names of the placeholders by the positions, a name may be bound more than once.
+	Names []string
+}
+
+// PrepareNamed prepares the query whose :name placeholders are rewritten to the ? ones,
+// the @name user variables and the @@name system variables are kept for the server.
+func (c *conn) PrepareNamed(query string) (*NamedStmt, error) {
+	var mode sqlparser.SQLMode
+	if c.Status()&sqldb.SERVER_STATUS_NO_BACKSLASH_ESCAPES > 0 {
+		mode = sqlparser.ModeNoBackslashEscapes
+	}
+	rewritten, names := namedPlaceholders(query, mode)
+	stmt, err := c.Prepare(rewritten)
+	if err != nil {
+		return nil, err
+	}
+	if stmt.ParamCount != len(names) {
+		stmt.Close()
+		return nil, fmt.Errorf("driver.stmt.named.params[%d].mismatch.the.server.ones[%d]", len(names), stmt.ParamCount)
+	}
+	return &NamedStmt{Stmt: stmt, Names: names}, nil
+}
+
+// Bind returns the positional args of the named ones, every name must have the arg.
+func (s *NamedStmt) Bind(args map[string]sqltypes.Value) ([]sqltypes.Value, error) {
+	values := make([]sqltypes.Value, len(s.Names))
+	for i, name := range s.Names {
+		v, ok := args[name]
+		if !ok {
+			return nil, fmt.Errorf("driver.stmt.named.param[%s].missing", name)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+// Query executes the statement with the named args and returns the row cursor.
+func (s *NamedStmt) Query(args map[string]sqltypes.Value) (Rows, error) {
+	values, err := s.Bind(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Query(values...)
+}
+
+// Execute executes the statement with the named args and fetches all the results.
+func (s *NamedStmt) Execute(args map[string]sqltypes.Value) (*sqltypes.Result, error) {
+	values, err := s.Bind(args)
+	if err != nil {
+		return nil, err
+	}
+	return s.Stmt.Execute(values...)
+}
+
+// namedPlaceholders rewrites the :name placeholders of the query to the ? ones and returns the names by the positions,
+// the ones in the quoted strings, the quoted identifiers and the comments are skipped,
+// so are the @ user variables, the @@ system variables, the :: and := operators.
+func namedPlaceholders(query string, mode sqlparser.SQLMode) (string, []string) {
+	var buf strings.Builder
+	var names []string
+	last := 0
+	for i := 0; i < len(query); i++ {
+		if j := sqlparser.SkipLiteral(query, i, mode); j != i {
+			i = j
+			continue
+		}
+		if query[i] == '@' {
+			// The variable or the account host, like @x:=1 or u@h.
+			for i+1 < len(query) && (query[i+1] == '@' || query[i+1] == '.' || isNameChar(query[i+1])) {
+				i++
+			}
+			continue
+		}
+		if query[i] != ':' {
+			continue
+		}
+		if i > 0 && (query[i-1] == ':' || isNameChar(query[i-1])) {
+			continue
+		}
+		j := i + 1
+		if j >= len(query) || !isNameStart(query[j]) {
+			continue
+		}
+		for j < len(query) && isNameChar(query[j]) {
+			j++
+		}
+		buf.WriteString(query[last:i])
+		buf.WriteByte('?')
+		names = append(names, query[i+1:j])
+		last = j
+		i = j - 1
+	}
+	if names == nil {
+		return query, nil
+	}
+	buf.WriteString(query[last:])
+	return buf.String(), names
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
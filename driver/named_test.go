@@ -0,0 +1,96 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestNamedPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+		names []string
+	}{
+		{query: "select 1", want: "select 1"},
+		{query: "select * from t where a = :a and b = :b_1 or c = :a", want: "select * from t where a = ? and b = ? or c = ?", names: []string{"a", "b_1", "a"}},
+		{query: "select ':a', \"@b\", `:c`, '10:30' -- :d\n, @@sql_mode, @@session.autocommit, :e", want: "select ':a', \"@b\", `:c`, '10:30' -- :d\n, @@sql_mode, @@session.autocommit, ?", names: []string{"e"}},
+		// The user variables are kept.
+		{query: "set @x := 1, @y := :v", want: "set @x := 1, @y := ?", names: []string{"v"}},
+		{query: "SELECT @x := 1", want: "SELECT @x := 1"},
+		{query: "SELECT @x:=:v, @`a`:=:w, @'b:c'", want: "SELECT @x:=?, @`a`:=?, @'b:c'", names: []string{"v", "w"}},
+		{query: "SET @a = ?", want: "SET @a = ?"},
+		{query: "select * from t where a = @b_1 or b = @@global.c", want: "select * from t where a = @b_1 or b = @@global.c"},
+		{query: "select a::int, b:1 from t /* :x */", want: "select a::int, b:1 from t /* :x */"},
+		{query: "grant all on *.* to root@localhost", want: "grant all on *.* to root@localhost"},
+		{query: "select :", want: "select :"},
+	}
+	for _, test := range tests {
+		got, names := namedPlaceholders(test.query, 0)
+		assert.Equal(t, test.want, got, test.query)
+		assert.Equal(t, test.names, names, test.query)
+	}
+}
+
+func TestClientNamedStmt(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("7"))}},
+	}
+	th.AddQuery("select id from t1 where id > 1 and name = 'x' and id < 10 and id != 1", result)
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	stmt, err := client.PrepareNamed("SELECT id FROM t1 WHERE id > :min AND name = :name AND id < :max AND id != :min")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"min", "name", "max", "min"}, stmt.Names)
+	assert.Equal(t, 4, stmt.ParamCount)
+
+	args := map[string]sqltypes.Value{
+		"min":  sqltypes.NewInt64(1),
+		"max":  sqltypes.NewInt64(10),
+		"name": sqltypes.NewVarChar("x"),
+	}
+	qr, err := stmt.Execute(args)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Rows, qr.Rows)
+
+	rows, err := stmt.Query(args)
+	assert.Nil(t, err)
+	assert.True(t, rows.Next())
+	assert.Nil(t, rows.Close())
+
+	// The missing name.
+	delete(args, "max")
+	_, err = stmt.Execute(args)
+	assert.NotNil(t, err)
+	_, err = stmt.Query(args)
+	assert.NotNil(t, err)
+
+	// The positional placeholders are not allowed with the named ones.
+	_, err = client.PrepareNamed("SELECT id FROM t1 WHERE id > ? AND id < :max")
+	assert.NotNil(t, err)
+	assert.Nil(t, stmt.Close())
+	assert.Nil(t, client.Ping())
+}
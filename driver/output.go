@@ -0,0 +1,176 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// outputConn is the connection the session writes the packets to.
+// The writes are timed out by the net_write_timeout of the session, a client not reading can't block the session forever.
+// If the queue is started, the writes are queued and a goroutine writes them to the client: the Write blocks
+// once the queue is full, so the handler or the backend stops producing the rows for a slow client
+// instead of buffering them.
+type outputConn struct {
+	// The bytes written to the client, it's first for the 64-bit alignment of the atomic.
+	bytesWritten uint64
+
+	net.Conn
+
+	// The net_write_timeout in nanoseconds, zero leaves the deadlines as they are like the handshake one.
+	timeout int64
+
+	mu    sync.Mutex
+	cond  *sync.Cond
+	size  int
+	queue []byte
+	err   error
+	// The writes started before the drain and waiting for the room of the queue, they're written before the loop ends.
+	writers int
+	closing bool
+	done    chan struct{}
+}
+
+func newOutputConn(conn net.Conn) *outputConn {
+	c := &outputConn{Conn: conn}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// setTimeout sets the timeout of the writes coming next.
+func (c *outputConn) setTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&c.timeout, int64(timeout))
+}
+
+// start starts queueing the writes up to the size bytes, it's called before the first write.
+func (c *outputConn) start(size int) {
+	if size <= 0 {
+		return
+	}
+	c.size = size
+	c.done = make(chan struct{})
+	go c.loop()
+}
+
+func (c *outputConn) write(b []byte) (int, error) {
+	if timeout := time.Duration(atomic.LoadInt64(&c.timeout)); timeout > 0 {
+		c.Conn.SetWriteDeadline(time.Now().Add(timeout))
+	}
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.bytesWritten, uint64(n))
+	return n, err
+}
+
+// Write queues the bytes, it waits while the queue is full and returns the error the queued ones failed with.
+// The writes started after the drain are refused, the ones waiting when it's called are still queued.
+func (c *outputConn) Write(b []byte) (int, error) {
+	if c.done == nil {
+		return c.write(b)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil && c.closing {
+		return 0, io.ErrClosedPipe
+	}
+	c.writers++
+	c.cond.Broadcast()
+	for c.err == nil && len(c.queue) > 0 && len(c.queue)+len(b) > c.size {
+		c.cond.Wait()
+	}
+	c.writers--
+	if c.err != nil {
+		return 0, c.err
+	}
+	c.queue = append(c.queue, b...)
+	c.cond.Broadcast()
+	return len(b), nil
+}
+
+// loop writes the queued bytes until the queue and the waiting writes are drained after the drain or a write fails.
+func (c *outputConn) loop() {
+	defer close(c.done)
+	var buf []byte
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		for len(c.queue) == 0 && (!c.closing || c.writers > 0) && c.err == nil {
+			c.cond.Wait()
+		}
+		if len(c.queue) == 0 || c.err != nil {
+			return
+		}
+		buf, c.queue = c.queue, buf[:0]
+		// The queue has the room for the waiting writes while the buf is written.
+		c.cond.Broadcast()
+		c.mu.Unlock()
+		_, err := c.write(buf)
+		c.mu.Lock()
+		if err != nil {
+			c.err = err
+		}
+		c.cond.Broadcast()
+	}
+}
+
+// queued returns the bytes queued but not written yet.
+func (c *outputConn) queued() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.queue)
+}
+
+// drain stops the queue and waits the queued bytes and the waiting writes written,
+// like the ERR packet before the session is closed.
+func (c *outputConn) drain() {
+	if c.done == nil {
+		return
+	}
+	c.mu.Lock()
+	c.closing = true
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	<-c.done
+}
+
+// netWriteTimeout returns the net_write_timeout of the session, zero if it's not a number.
+func (s *Session) netWriteTimeout() time.Duration {
+	if v, ok := s.SystemVariable("net_write_timeout"); ok {
+		if sec, err := v.ParseUint64(); err == nil {
+			return time.Duration(sec) * time.Second
+		}
+	}
+	return 0
+}
+
+// OutputQueued returns the bytes of the packets queued for the client but not written yet,
+// it's always 0 if the listener doesn't queue the output.
+func (s *Session) OutputQueued() int {
+	return s.output.queued()
+}
+
+// SetOutputQueueSize sets the bytes of the output queue of the coming sessions, the sessions write the packets to the
+// client in the background and a slow client blocks the writes once the queue is full.
+// The 0 writes to the client synchronously, it's the default.
+func (l *Listener) SetOutputQueueSize(bytes int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.outputQueueSize = bytes
+}
+
+// OutputQueueSize returns the bytes of the output queue of the coming sessions, 0 if the output isn't queued.
+func (l *Listener) OutputQueueSize() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.outputQueueSize
+}
@@ -0,0 +1,150 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// waitOutput waits until the cond of the output holds, it's checked whenever the output changes.
+func waitOutput(c *outputConn, cond func() bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for !cond() {
+		c.cond.Wait()
+	}
+}
+
+// fillOutput queues the 16 bytes the loop of the 8 bytes queue can't write until the client reads,
+// and starts the write of the ABCDEFGH waiting for the room, the written is closed once it's queued.
+func fillOutput(t *testing.T, output *outputConn) chan struct{} {
+	// The first write is being written by the loop and the second one is queued.
+	_, err := output.Write([]byte("12345678"))
+	assert.Nil(t, err)
+	waitOutput(output, func() bool { return len(output.queue) == 0 })
+	_, err = output.Write([]byte("abcdefgh"))
+	assert.Nil(t, err)
+	assert.Equal(t, 8, output.queued())
+
+	// The queue is full, the write waits the client.
+	written := make(chan struct{})
+	go func() {
+		_, err := output.Write([]byte("ABCDEFGH"))
+		assert.Nil(t, err)
+		close(written)
+	}()
+	// The loop is blocked writing the first bytes to the client not reading, the writer must be still waiting.
+	waitOutput(output, func() bool { return output.writers == 1 })
+	select {
+	case <-written:
+		t.Fatal("the write of the full queue must wait")
+	default:
+	}
+	return written
+}
+
+func TestOutputConnQueue(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	output := newOutputConn(server)
+	output.start(8)
+	written := fillOutput(t, output)
+
+	got := make([]byte, 24)
+	read := make(chan error)
+	go func() {
+		_, err := io.ReadFull(client, got)
+		read <- err
+	}()
+	<-written
+	output.drain()
+	assert.Nil(t, <-read)
+	assert.Equal(t, "12345678abcdefghABCDEFGH", string(got))
+	_, err := output.Write([]byte("x"))
+	assert.Equal(t, io.ErrClosedPipe, err)
+}
+
+func TestOutputConnDrainWaitingWrite(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	output := newOutputConn(server)
+	output.start(8)
+	written := fillOutput(t, output)
+
+	// The write waiting when the drain is called is still written.
+	drained := make(chan struct{})
+	go func() {
+		output.drain()
+		close(drained)
+	}()
+	waitOutput(output, func() bool { return output.closing })
+	got := make([]byte, 24)
+	_, err := io.ReadFull(client, got)
+	assert.Nil(t, err)
+	<-written
+	<-drained
+	assert.Equal(t, "12345678abcdefghABCDEFGH", string(got))
+}
+
+func TestOutputConnTimeout(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	output := newOutputConn(server)
+	output.setTimeout(50 * time.Millisecond)
+
+	// The synchronous write.
+	_, err := output.Write([]byte("x"))
+	assert.NotNil(t, err)
+	assert.True(t, err.(net.Error).Timeout())
+
+	// The queued write fails in the background, the next write gets the error.
+	output.start(8)
+	_, err = output.Write([]byte("x"))
+	assert.Nil(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = output.Write([]byte("y"))
+	assert.NotNil(t, err)
+	assert.True(t, err.(net.Error).Timeout())
+	output.drain()
+}
+
+func TestListenerOutputQueue(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, OutputQueueSize: 1024}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	assert.Equal(t, 1024, svr.OutputQueueSize())
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 64))
+	for i := 0; i < 1000; i++ {
+		b.AppendRow(i, fmt.Sprintf("name%d", i))
+	}
+	th.AddQuery("SELECT * FROM t", b.Result())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	for i := 0; i < 3; i++ {
+		qr, err := client.FetchAll("SELECT * FROM t", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, b.Result().Rows, qr.Rows)
+	}
+	assert.NotNil(t, (&ListenerConfig{OutputQueueSize: -1}).Validate())
+}
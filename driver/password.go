@@ -0,0 +1,72 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/subtle"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+)
+
+// Credentials are the passwords of a user like the dual password of MySQL 8.0,
+// the clients of both passwords are accepted while the password rotates to the new one.
+type Credentials struct {
+	// Password is the primary password.
+	Password string
+
+	// RetainedPassword is the secondary password kept by the RETAIN CURRENT PASSWORD, empty if none.
+	RetainedPassword string
+}
+
+// Rotate returns the credentials of the new password like the ALTER USER IDENTIFIED BY,
+// the current password is retained for the clients not rotated yet if retain is true.
+func (c Credentials) Rotate(password string, retain bool) Credentials {
+	next := Credentials{Password: password}
+	if retain {
+		next.RetainedPassword = c.Password
+	}
+	return next
+}
+
+// DiscardOld returns the credentials without the retained password like the DISCARD OLD PASSWORD.
+func (c Credentials) DiscardOld() Credentials {
+	return Credentials{Password: c.Password}
+}
+
+// CheckPassword checks the scramble of the session is the mysql_native_password one of the password,
+// the scrambles are compared in the constant time not to leak how much of them matches.
+func (s *Session) CheckPassword(password string) bool {
+	return subtle.ConstantTimeCompare(s.Scramble(), proto.NativePassword(password, s.Salt())) == 1
+}
+
+// CheckCredentials checks the session by the primary password and then the retained one,
+// it returns the ER_ACCESS_DENIED_ERROR if neither matches. See RetainedPasswordUsed.
+func (s *Session) CheckCredentials(c *Credentials) error {
+	retained := false
+	switch {
+	case s.CheckPassword(c.Password):
+	case c.RetainedPassword != "" && s.CheckPassword(c.RetainedPassword):
+		retained = true
+	default:
+		return s.accessDenied()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.retainedPassword = retained
+	return nil
+}
+
+// RetainedPasswordUsed checks whether the session was accepted by the retained password,
+// the client still has to be rotated before the old password is discarded.
+func (s *Session) RetainedPasswordUsed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.retainedPassword
+}
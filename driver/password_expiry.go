@@ -0,0 +1,214 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+// SetPasswordExpired marks the password of the user expired for the coming sessions, the users are case sensitive.
+// The clients with the CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS are accepted in the sandbox mode, the others are
+// rejected with the ER_MUST_CHANGE_PASSWORD_LOGIN. The mark is cleared once a sandboxed session changed the password.
+func (l *Listener) SetPasswordExpired(user string, expired bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !expired {
+		delete(l.expiredUsers, user)
+		return
+	}
+	if l.expiredUsers == nil {
+		l.expiredUsers = make(map[string]bool)
+	}
+	l.expiredUsers[user] = true
+}
+
+// PasswordExpired checks whether the password of the user is marked expired.
+func (l *Listener) PasswordExpired(user string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.expiredUsers[user]
+}
+
+// checkPasswordExpired puts the authed session of the expired password into the sandbox mode,
+// the client can't handle it is rejected.
+func (l *Listener) checkPasswordExpired(session *Session) error {
+	if !session.Sandboxed() && !l.PasswordExpired(session.User()) {
+		return nil
+	}
+	if session.capabilities()&sqldb.CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS == 0 {
+		return sqldb.NewSQLError(sqldb.ER_MUST_CHANGE_PASSWORD_LOGIN, "")
+	}
+	session.setSandbox(true)
+	return nil
+}
+
+// passwordChanged takes the session out of the sandbox mode after the query changed the password of the session user,
+// the SET PASSWORD FOR another account leaves the expiry as it is.
+func (l *Listener) passwordChanged(session *Session, query string) {
+	if !session.Sandboxed() || !session.isOwnPasswordChange(query) {
+		return
+	}
+	session.setSandbox(false)
+	l.SetPasswordExpired(session.User(), false)
+}
+
+// Sandboxed checks whether the session is in the sandbox mode of the expired password,
+// only the SET PASSWORD and the ALTER USER of the session user run until the password is changed.
+func (s *Session) Sandboxed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sandbox
+}
+
+func (s *Session) setSandbox(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sandbox = on
+}
+
+// checkSandbox returns the ER_MUST_CHANGE_PASSWORD if the sandboxed session can't run the command,
+// the COM_QUERY is checked by the checkSandboxQuery.
+func (s *Session) checkSandbox(command byte) error {
+	if !s.Sandboxed() {
+		return nil
+	}
+	switch command {
+	case sqldb.COM_QUIT, sqldb.COM_PING, sqldb.COM_QUERY:
+		return nil
+	}
+	return sqldb.NewSQLError(sqldb.ER_MUST_CHANGE_PASSWORD, "")
+}
+
+// checkSandboxQuery returns the ER_MUST_CHANGE_PASSWORD if the sandboxed session can't run the query,
+// it must be a single SET PASSWORD or ALTER USER changing the password of the session user.
+func (s *Session) checkSandboxQuery(query string) error {
+	if !s.Sandboxed() || (!multiStatements(query, s.SQLMode()) && s.isOwnPasswordChange(query)) {
+		return nil
+	}
+	return sqldb.NewSQLError(sqldb.ER_MUST_CHANGE_PASSWORD, "")
+}
+
+// isOwnPasswordChange checks whether the query changes the password of the session user.
+func (s *Session) isOwnPasswordChange(query string) bool {
+	user, current, ok := passwordTarget(query, s.SQLMode())
+	return ok && (current || user == s.User())
+}
+
+// multiStatements checks whether the query has another statement after a ';'.
+func multiStatements(query string, mode sqlparser.SQLMode) bool {
+	var ended bool
+	for i := 0; i < len(query); i++ {
+		// The comments after the ';' are not the statements.
+		if c := query[i]; c == '#' || c == '-' || c == '/' {
+			if j := sqlparser.SkipLiteral(query, i, mode); j != i {
+				i = j
+				continue
+			}
+		}
+		switch c := query[i]; {
+		case c == ';':
+			ended = true
+		case isSpace(c):
+		case ended:
+			return true
+		default:
+			i = sqlparser.SkipLiteral(query, i, mode)
+		}
+	}
+	return false
+}
+
+// passwordTarget returns the user of the account whose password the SET PASSWORD or the ALTER USER changes,
+// the current is true for the SET PASSWORD without the FOR and the USER() or the CURRENT_USER.
+// It's false if the query isn't a password change or it changes more than one account.
+func passwordTarget(query string, mode sqlparser.SQLMode) (user string, current bool, ok bool) {
+	query = strings.TrimSpace(sqlparser.StripLeadingComments(query))
+	words := strings.Fields(strings.ToLower(query))
+	var rest string
+	switch {
+	case len(words) > 1 && words[0] == "alter" && words[1] == "user":
+		rest = strings.TrimSpace(query[len("alter"):])
+		rest = strings.TrimSpace(rest[len("user"):])
+		if strings.HasPrefix(strings.ToLower(rest), "if exists") {
+			rest = strings.TrimSpace(rest[len("if exists"):])
+		}
+	case len(words) > 1 && words[0] == "set" && (words[1] == "password" || strings.HasPrefix(words[1], "password=")):
+		// The password_history or the @password are not the password.
+		rest = strings.TrimSpace(query[len("set"):])
+		rest = strings.TrimSpace(rest[len("password"):])
+		switch {
+		case strings.HasPrefix(rest, "="):
+			current = true
+		case strings.HasPrefix(strings.ToLower(rest), "for") && len(rest) > 3 && !isNameChar(rest[3]):
+			rest = strings.TrimSpace(rest[len("for"):])
+		default:
+			return "", false, false
+		}
+	default:
+		return "", false, false
+	}
+
+	if !current {
+		lower := strings.ToLower(rest)
+		for _, fn := range []string{"user()", "current_user()", "current_user"} {
+			if strings.HasPrefix(lower, fn) {
+				current, rest = true, rest[len(fn):]
+				break
+			}
+		}
+	}
+	if !current {
+		if user, rest = accountUser(rest, mode); user == "" {
+			return "", false, false
+		}
+	}
+	// The other accounts of the ALTER USER list or the other variables of the SET.
+	for i := 0; i < len(rest); i++ {
+		if j := sqlparser.SkipLiteral(rest, i, mode); j != i {
+			i = j
+			continue
+		}
+		if rest[i] == ',' {
+			return "", false, false
+		}
+	}
+	return user, current, true
+}
+
+// accountUser returns the user of the account like 'u'@'h' or u@h at the start of the s and the rest after the account.
+func accountUser(s string, mode sqlparser.SQLMode) (string, string) {
+	if s == "" {
+		return "", s
+	}
+	var user string
+	switch c := s[0]; c {
+	case '\'', '"', '`':
+		// The doubled quote is the quote in the name.
+		j := sqlparser.SkipLiteral(s, 0, mode)
+		if j >= len(s) {
+			return "", s
+		}
+		user = strings.Replace(s[1:j], string([]byte{c, c}), string(c), -1)
+		s = s[j+1:]
+	default:
+		j := 0
+		for j < len(s) && (isNameChar(s[j]) || s[j] == '$' || s[j] == '.' || s[j] == '-') {
+			j++
+		}
+		user, s = s[:j], s[j:]
+	}
+	if strings.HasPrefix(s, "@") {
+		_, s = accountUser(s[1:], mode)
+	}
+	return user, s
+}
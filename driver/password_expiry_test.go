@@ -0,0 +1,118 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestListenerPasswordExpired(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, ExpiredUsers: []string{"mock"}}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+	th.AddQuery("SET autocommit = 1", &sqltypes.Result{})
+	th.AddQuery("SET PASSWORD FOR other = 'new'", &sqltypes.Result{})
+	th.AddQuery("ALTER USER USER() IDENTIFIED BY 'new'", &sqltypes.Result{})
+	assert.True(t, svr.PasswordExpired("mock"))
+
+	// The client can't handle the expired password.
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_MUST_CHANGE_PASSWORD_LOGIN), num)
+	assert.True(t, sqldb.IsPasswordExpired(err))
+
+	// The sandbox mode.
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, ExpiredPasswords: true})
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Nil(t, client.Ping())
+	_, err = client.FetchAll("SELECT 1", -1)
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_MUST_CHANGE_PASSWORD), num)
+	assert.True(t, sqldb.IsPasswordExpired(err))
+	err = client.InitDB("test")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_MUST_CHANGE_PASSWORD), num)
+	err = client.Exec("SET autocommit = 1")
+	assert.True(t, sqldb.IsPasswordExpired(err))
+	// The multi statements can't hide another one after the password change.
+	err = client.Exec("ALTER USER USER() IDENTIFIED BY 'new'; SELECT 1")
+	assert.True(t, sqldb.IsPasswordExpired(err))
+	// The password of the other account doesn't take the session out.
+	svr.SetPasswordExpired("other", true)
+	err = client.Exec("SET PASSWORD FOR other = 'new'")
+	assert.True(t, sqldb.IsPasswordExpired(err))
+	assert.True(t, svr.PasswordExpired("mock"))
+	assert.True(t, svr.PasswordExpired("other"))
+
+	// The password changed.
+	assert.Nil(t, client.Exec("ALTER USER USER() IDENTIFIED BY 'new'"))
+	assert.False(t, svr.PasswordExpired("mock"))
+	_, err = client.FetchAll("SELECT 1", -1)
+	assert.Nil(t, err)
+	plain, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	plain.Close()
+
+	// The expired result of the delegate.
+	svr.SetAuthDelegate(AuthDelegateFunc(func(r *AuthRequest) (*AuthResult, error) {
+		return &AuthResult{PasswordExpired: true}, nil
+	}))
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_MUST_CHANGE_PASSWORD_LOGIN), num)
+	assert.False(t, svr.PasswordExpired("mock"))
+}
+
+func TestPasswordTarget(t *testing.T) {
+	tests := []struct {
+		query   string
+		user    string
+		current bool
+		ok      bool
+	}{
+		{"ALTER USER USER() IDENTIFIED BY 'new'", "", true, true},
+		{"alter user current_user identified by 'new'", "", true, true},
+		{"/* x */ alter user 'mock'@'%' identified by 'new'", "mock", false, true},
+		{"ALTER USER IF EXISTS `mo``ck`@localhost IDENTIFIED BY 'a,b'", "mo`ck", false, true},
+		{"ALTER USER mock IDENTIFIED BY 'new', other IDENTIFIED BY 'new'", "", false, false},
+		{"SET PASSWORD = 'new'", "", true, true},
+		{"set password for 'mock'@'%' = 'new'", "mock", false, true},
+		{"SET PASSWORD FOR other@'%' = 'new'", "other", false, true},
+		{"SET autocommit = 1", "", false, false},
+		{"SET password_history = 0", "", false, false},
+		{"SET password_require_current = OFF", "", false, false},
+		{"SET passwordX = 1, @a = (SELECT 1)", "", false, false},
+		{"SET PASSWORD = 'new', @a = (SELECT 1)", "", false, false},
+		{"SET PASSWORD='a,b'", "", true, true},
+		{"ALTER TABLE t ADD c INT", "", false, false},
+		{"SELECT 1", "", false, false},
+	}
+	for _, test := range tests {
+		user, current, ok := passwordTarget(test.query, 0)
+		assert.Equal(t, test.user, user, test.query)
+		assert.Equal(t, test.current, current, test.query)
+		assert.Equal(t, test.ok, ok, test.query)
+	}
+
+	assert.False(t, multiStatements("SET PASSWORD = 'a;b'; -- x", 0))
+	assert.True(t, multiStatements("SET PASSWORD = 'new'; SELECT 1", 0))
+	assert.True(t, multiStatements("SET PASSWORD = 'a\\'; SELECT 1", sqlparser.ModeNoBackslashEscapes))
+}
@@ -0,0 +1,102 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// credentialsHandler checks the sessions by the credentials of the users.
+type credentialsHandler struct {
+	*TestHandler
+	mu       sync.Mutex
+	users    map[string]Credentials
+	retained bool
+}
+
+func (h *credentialsHandler) AuthCheck(s *Session) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c, ok := h.users[s.User()]
+	if !ok {
+		return s.accessDenied()
+	}
+	if err := s.CheckCredentials(&c); err != nil {
+		return err
+	}
+	h.retained = s.RetainedPasswordUsed()
+	return nil
+}
+
+func (h *credentialsHandler) set(user string, c Credentials) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.users[user] = c
+}
+
+func (h *credentialsHandler) lastRetained() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.retained
+}
+
+func TestCredentialsRotate(t *testing.T) {
+	c := Credentials{Password: "old"}
+	c = c.Rotate("new", true)
+	assert.Equal(t, Credentials{Password: "new", RetainedPassword: "old"}, c)
+	assert.Equal(t, Credentials{Password: "newer"}, c.Rotate("newer", false))
+	assert.Equal(t, Credentials{Password: "new"}, c.DiscardOld())
+}
+
+func TestSessionCheckCredentials(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &credentialsHandler{TestHandler: NewTestHandler(log), users: map[string]Credentials{"app": {Password: "old"}}}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	connect := func(passwd string) error {
+		client, err := NewConn("app", passwd, svr.Addr(), "", "")
+		if err != nil {
+			return err
+		}
+		return client.Close()
+	}
+	assert.Nil(t, connect("old"))
+	assert.False(t, th.lastRetained())
+
+	// Both passwords are accepted while rotating.
+	th.set("app", Credentials{Password: "old"}.Rotate("new", true))
+	assert.Nil(t, connect("new"))
+	assert.False(t, th.lastRetained())
+	assert.Nil(t, connect("old"))
+	assert.True(t, th.lastRetained())
+	err = connect("other")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+
+	// The old password discarded.
+	th.set("app", Credentials{Password: "new", RetainedPassword: "old"}.DiscardOld())
+	assert.Nil(t, connect("new"))
+	err = connect("old")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+
+	// The empty password isn't a retained one.
+	th.set("app", Credentials{Password: "new"})
+	err = connect("")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+}
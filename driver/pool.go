@@ -0,0 +1,260 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrPoolClosed returns if the pool was closed.
+	ErrPoolClosed = errors.New("driver.pool.closed")
+
+	// ErrConnInTransaction returns when putting a connection back to the pool mid-transaction.
+	ErrConnInTransaction = errors.New("driver.pool.conn.in.transaction")
+)
+
+// Pool is a pool of client connections created from the same DSN.
+type Pool struct {
+	mu      sync.Mutex
+	config  *ClientConfig
+	maxIdle int
+	idles   []Conn
+	closed  bool
+
+	// maxOpen limits the connections in use and the dialing ones, the Gets wait by the cond once it's reached.
+	maxOpen int
+	dialing int
+	cond    *sync.Cond
+
+	// The counters of the Stats.
+	inUse        int
+	waitCount    uint64
+	waitDuration time.Duration
+
+	// For the keepalive loop.
+	stop chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewPool creates a new pool, at most maxIdle connections are kept.
+// The connections cache the prepared statements if the dsn has the stmtCacheSize.
+func NewPool(dsn string, maxIdle int) (*Pool, error) {
+	d, err := ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := d.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	p := &Pool{
+		config:  cfg.withDefaults(),
+		maxIdle: maxIdle,
+	}
+	p.cond = sync.NewCond(&p.mu)
+	return p, nil
+}
+
+// SetMaxOpen limits the connections in use and the dialing ones to n, the Get waits for a Put once
+// the limit is reached. The 0 is unlimited, it's the default.
+func (p *Pool) SetMaxOpen(n int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.maxOpen = n
+	p.cond.Broadcast()
+}
+
+// Get gets an idle connection or creates a new one, it waits for a Put if the max open connections are in use.
+// The WaitCount and the WaitDuration of the Stats only count the waits, not the dials.
+func (p *Pool) Get() (Conn, error) {
+	p.mu.Lock()
+	var start time.Time
+	defer func() {
+		if !start.IsZero() {
+			p.waitDuration += time.Since(start)
+		}
+		p.mu.Unlock()
+	}()
+	for {
+		if p.closed {
+			return nil, ErrPoolClosed
+		}
+		for len(p.idles) > 0 {
+			c := p.idles[len(p.idles)-1]
+			p.idles = p.idles[:len(p.idles)-1]
+			if !c.Closed() {
+				p.inUse++
+				return c, nil
+			}
+		}
+		if p.maxOpen <= 0 || p.inUse+p.dialing < p.maxOpen {
+			break
+		}
+		if start.IsZero() {
+			start = time.Now()
+			p.waitCount++
+		}
+		p.cond.Wait()
+	}
+	if !start.IsZero() {
+		p.waitDuration += time.Since(start)
+		start = time.Time{}
+	}
+
+	p.dialing++
+	p.mu.Unlock()
+	c, err := newConn(p.config)
+	p.mu.Lock()
+	p.dialing--
+	if err != nil {
+		p.cond.Signal()
+		return nil, err
+	}
+	p.inUse++
+	return c, nil
+}
+
+// Put returns the connection to the pool.
+// The connection which is still in a transaction is refused with ErrConnInTransaction,
+// the caller must commit or rollback it first.
+func (p *Pool) Put(c Conn) error {
+	if !c.Closed() && c.InTransaction() {
+		return ErrConnInTransaction
+	}
+	p.mu.Lock()
+	if p.inUse > 0 {
+		p.inUse--
+	}
+	p.cond.Signal()
+	p.mu.Unlock()
+	return p.put(c)
+}
+
+// discard closes the connection got from the pool instead of putting it back, like the one with the session state changed.
+func (p *Pool) discard(c Conn) {
+	c.Close()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.inUse > 0 {
+		p.inUse--
+	}
+	p.cond.Signal()
+}
+
+// put puts the connection to the idle ones.
+func (p *Pool) put(c Conn) error {
+	if c.Closed() {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idles) >= p.maxIdle {
+		c.Close()
+		return nil
+	}
+	p.idles = append(p.idles, c)
+	p.cond.Signal()
+	return nil
+}
+
+// StartKeepalive pings the idle connections every interval in the background until the pool is closed,
+// the broken ones are evicted and the pool is filled up to the minIdle connections(at most the maxIdle).
+func (p *Pool) StartKeepalive(interval time.Duration, minIdle int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	p.wg.Add(1)
+	go func(stop chan struct{}) {
+		defer p.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		p.keepalive(minIdle)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.keepalive(minIdle)
+			}
+		}
+	}(p.stop)
+}
+
+// keepalive pings the idle connections out of the pool, the alive ones are put back, then the new ones are dialed
+// till the minIdle.
+func (p *Pool) keepalive(minIdle int) {
+	p.mu.Lock()
+	idles := p.idles
+	p.idles = nil
+	p.mu.Unlock()
+
+	for _, c := range idles {
+		if c.Closed() || c.Ping() != nil {
+			c.Close()
+			continue
+		}
+		p.put(c)
+	}
+
+	if minIdle > p.maxIdle {
+		minIdle = p.maxIdle
+	}
+	for {
+		p.mu.Lock()
+		n, closed := len(p.idles), p.closed
+		p.mu.Unlock()
+		if closed || n >= minIdle {
+			return
+		}
+		c, err := newConn(p.config)
+		if err != nil {
+			return
+		}
+		p.put(c)
+	}
+}
+
+// Stats returns the counters of the pool.
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return PoolStats{
+		Open:         p.inUse + len(p.idles),
+		Idle:         len(p.idles),
+		InUse:        p.inUse,
+		WaitCount:    p.waitCount,
+		WaitDuration: p.waitDuration,
+	}
+}
+
+// Close closes all the idle connections and stops the keepalive loop.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	for _, c := range p.idles {
+		c.Close()
+	}
+	p.idles = nil
+	p.closed = true
+	p.cond.Broadcast()
+	stop := p.stop
+	p.stop = nil
+	p.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		p.wg.Wait()
+	}
+}
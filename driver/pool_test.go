@@ -0,0 +1,77 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolKeepalive(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	pool, err := NewPool(fmt.Sprintf("mock:mock@tcp(%s)/", svr.Addr()), 3)
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	idleIDs := func() []uint32 {
+		pool.mu.Lock()
+		defer pool.mu.Unlock()
+		var ids []uint32
+		for _, c := range pool.idles {
+			ids = append(ids, c.ConnectionID())
+		}
+		return ids
+	}
+	wait := func(cond func() bool) {
+		for i := 0; i < 200 && !cond(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		assert.True(t, cond())
+	}
+
+	// Pre-warmed to the min idle.
+	pool.StartKeepalive(10*time.Millisecond, 2)
+	pool.StartKeepalive(10*time.Millisecond, 2)
+	wait(func() bool { return len(idleIDs()) == 2 })
+
+	// The broken one is evicted and replaced.
+	ids := idleIDs()
+	killer, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer killer.Close()
+	assert.Nil(t, killer.Exec(fmt.Sprintf("KILL %d", ids[0])))
+	wait(func() bool {
+		now := idleIDs()
+		return len(now) == 2 && now[0] != ids[0] && now[1] != ids[0]
+	})
+
+	// The connections are got and put back as usual.
+	c, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, c.Ping())
+	assert.Nil(t, pool.Put(c))
+
+	// Stopped by the Close.
+	pool.Close()
+	assert.Equal(t, 0, len(idleIDs()))
+	_, err = pool.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 0, len(idleIDs()))
+}
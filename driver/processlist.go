@@ -0,0 +1,84 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sort"
+	"sync/atomic"
+)
+
+// ProcessInfo is the session of the Processlist.
+type ProcessInfo struct {
+	ID     uint32
+	User   string
+	Addr   string
+	Schema string
+
+	// Stats are the I/O counters of the session.
+	Stats SessionStats
+}
+
+// countCommand counts the command read from the client.
+func (s *Session) countCommand() {
+	atomic.AddUint64(&s.commands, 1)
+}
+
+// Stats returns the I/O counters of the session, it's safe to call from another session.
+func (s *Session) Stats() SessionStats {
+	reads, writes := s.packets.Counts()
+	return SessionStats{
+		BytesRead:      atomic.LoadUint64(&s.watcher.bytesRead),
+		BytesWritten:   atomic.LoadUint64(&s.output.bytesWritten),
+		PacketsRead:    reads,
+		PacketsWritten: writes,
+		Commands:       atomic.LoadUint64(&s.commands),
+	}
+}
+
+func (l *Listener) addSession(s *Session) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sessions == nil {
+		l.sessions = make(map[uint32]*Session)
+	}
+	l.sessions[s.id] = s
+}
+
+// closeSession removes the closed session and adds its bytes to the status counters.
+func (l *Listener) closeSession(s *Session) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.sessions, s.id)
+	l.status.closed(s.Stats())
+}
+
+// Processlist returns the authenticated sessions of the listener ordered by the ids,
+// like the SHOW PROCESSLIST with the I/O counters to find the bandwidth-heavy connections.
+func (l *Listener) Processlist() []*ProcessInfo {
+	l.mu.RLock()
+	sessions := make([]*Session, 0, len(l.sessions))
+	for _, s := range l.sessions {
+		sessions = append(sessions, s)
+	}
+	l.mu.RUnlock()
+
+	list := make([]*ProcessInfo, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, &ProcessInfo{
+			ID:     s.ID(),
+			User:   s.User(),
+			Addr:   s.Addr(),
+			Schema: s.Schema(),
+			Stats:  s.Stats(),
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list
+}
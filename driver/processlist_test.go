@@ -0,0 +1,70 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestListenerProcesslist(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewVarCharField("name", 0, 1024))
+	for i := 0; i < 100; i++ {
+		b.AppendRow(strings.Repeat("x", 1000))
+	}
+	th.AddQuery("SELECT * FROM big", b.Result())
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+
+	light, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer light.Close()
+	heavy, err := NewConn("mock", "mock", svr.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer heavy.Close()
+
+	assert.Nil(t, light.Exec("SELECT 1"))
+	for i := 0; i < 3; i++ {
+		_, err = heavy.FetchAll("SELECT * FROM big", -1)
+		assert.Nil(t, err)
+	}
+
+	list := svr.Processlist()
+	assert.Equal(t, 2, len(list))
+	assert.Equal(t, light.ConnectionID(), list[0].ID)
+	assert.Equal(t, "mock", list[0].User)
+	assert.Equal(t, "test", list[1].Schema)
+	assert.Equal(t, uint64(1), list[0].Stats.Commands)
+	assert.Equal(t, uint64(3), list[1].Stats.Commands)
+	assert.True(t, list[1].Stats.BytesWritten > 300000)
+	assert.True(t, list[0].Stats.BytesWritten < 1000)
+	// The handshake response and the commands.
+	assert.Equal(t, uint64(2), list[0].Stats.PacketsRead)
+	assert.Equal(t, light.Stats().BytesRead, list[0].Stats.BytesWritten)
+	assert.Equal(t, light.Stats().BytesWritten, list[0].Stats.BytesRead)
+
+	// The closed session is gone.
+	light.Close()
+	for len(svr.Processlist()) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, heavy.ConnectionID(), svr.Processlist()[0].ID)
+}
@@ -0,0 +1,53 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+var (
+	// ErrProgressAfterResult is returned if the progress is reported after the result of the statement was written.
+	ErrProgressAfterResult = errors.New("session.progress.after.result")
+)
+
+// ProgressSupported checks whether the client takes the MariaDB progress reports,
+// the greeting must advertise the MARIADB_CLIENT_PROGRESS, see GreetingConfig.MariaDBCapability.
+func (s *Session) ProgressSupported() bool {
+	return s.greeting.MariaDBCapability&s.auth.MariaDBClientFlags()&sqldb.MARIADB_CLIENT_PROGRESS > 0
+}
+
+// ReportProgress sends the MariaDB progress report of the running statement, the stage starts from 1
+// and the percent is of the stage. It's a no-op if the client doesn't take them.
+// It's called by the handler goroutine before the first result of the statement.
+func (s *Session) ReportProgress(stage, maxStage uint8, percent float64, info string) error {
+	if !s.ProgressSupported() {
+		return nil
+	}
+	s.mu.RLock()
+	written := s.resultWritten
+	s.mu.RUnlock()
+	if written {
+		return ErrProgressAfterResult
+	}
+
+	if percent < 0 {
+		percent = 0
+	}
+	return s.packets.Write(proto.PackProgress(&proto.Progress{
+		Stage:    stage,
+		MaxStage: maxStage,
+		Progress: uint32(percent * 1000),
+		Info:     info,
+	}))
+}
@@ -0,0 +1,139 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// progressHandler reports the stages of the 'alter' queries.
+type progressHandler struct {
+	*TestHandler
+	errs chan error
+}
+
+func (h *progressHandler) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	if !strings.EqualFold(query, "alter table t1 engine=innodb") {
+		return h.TestHandler.ComQuery(session, query, callback)
+	}
+	if err := session.ReportProgress(1, 2, 50, "copy to tmp table"); err != nil {
+		return err
+	}
+	if err := session.ReportProgress(2, 2, 100, "rename result table"); err != nil {
+		return err
+	}
+	err := callback(&sqltypes.Result{})
+	h.errs <- session.ReportProgress(2, 2, 100, "end")
+	return err
+}
+
+func TestServerProgress(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &progressHandler{TestHandler: NewTestHandler(log), errs: make(chan error, 4)}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	cfg := DefaultGreetingConfig()
+	cfg.ServerVersion = "5.5.5-10.3.8-MariaDB"
+	cfg.CapabilityMask = sqldb.CLIENT_LONG_PASSWORD
+	cfg.MariaDBCapability = sqldb.MARIADB_CLIENT_PROGRESS
+	assert.Nil(t, svr.SetGreetingConfig(cfg))
+
+	// The MariaDB client takes the progress.
+	{
+		conn, err := net.Dial("tcp", svr.Addr())
+		assert.Nil(t, err)
+		defer conn.Close()
+		packets := packet.NewPackets(conn)
+
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		greeting := proto.NewGreeting(0)
+		assert.Nil(t, greeting.UnPack(data))
+		assert.Equal(t, sqldb.MARIADB_CLIENT_PROGRESS, greeting.MariaDBCapability)
+
+		auth := proto.NewAuth()
+		auth.SetMariaDBClientFlags(sqldb.MARIADB_CLIENT_PROGRESS)
+		capability := proto.DefaultClientCapability &^ sqldb.CLIENT_LONG_PASSWORD
+		assert.Nil(t, packets.Write(auth.Pack(capability, sqldb.DefaultCollation, "mock", "mock", greeting.Salt, "")))
+		data, err = packets.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, proto.OK_PACKET, data[0])
+
+		packets.ResetSeq()
+		assert.Nil(t, packets.WriteCommand(sqldb.COM_QUERY, []byte("ALTER TABLE t1 ENGINE=InnoDB")))
+		var got []*proto.Progress
+		for {
+			data, err = packets.Next()
+			assert.Nil(t, err)
+			if !proto.IsProgress(data) {
+				break
+			}
+			progress, err := proto.UnPackProgress(data)
+			assert.Nil(t, err)
+			got = append(got, progress)
+		}
+		assert.Equal(t, proto.OK_PACKET, data[0])
+		assert.Equal(t, []*proto.Progress{
+			{Stage: 1, MaxStage: 2, Progress: 50000, Info: "copy to tmp table"},
+			{Stage: 2, MaxStage: 2, Progress: 100000, Info: "rename result table"},
+		}, got)
+		assert.Equal(t, ErrProgressAfterResult, <-th.errs)
+	}
+
+	// The client without the MARIADB_CLIENT_PROGRESS gets none.
+	{
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.Nil(t, client.Exec("ALTER TABLE t1 ENGINE=InnoDB"))
+		assert.Nil(t, <-th.errs)
+	}
+}
+
+func TestClientSkipProgress(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		packets := packet.NewPackets(conn)
+		packets.Write(proto.NewGreeting(1).Pack())
+		packets.Next()
+		packets.WriteOK(0, 0, 0, 0)
+
+		packets.ResetSeq()
+		packets.Next()
+		packets.Write(proto.PackProgress(&proto.Progress{Stage: 1, MaxStage: 1, Progress: 1000}))
+		packets.WriteOK(3, 0, 0, 0)
+		packets.Next()
+	}()
+
+	client, err := NewConn("mock", "mock", listener.Addr().String(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	qr, err := client.FetchAll("ALTER TABLE t1 ENGINE=InnoDB", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), qr.RowsAffected)
+}
@@ -0,0 +1,166 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// ProxyHandler is the Handler passes the queries through to a backend MySQL, every session has its backend connection.
+// The sessions are authenticated by the backend with the user and password of the proxy, or the ones the user map
+// gives the effective user of the session.
+type ProxyHandler struct {
+	log      *xlog.Log
+	cfg      *ClientConfig
+	mu       sync.Mutex
+	backends map[uint32]Conn
+	recorder *Recorder
+	users    map[string]ProxyUser
+}
+
+// ProxyUser is the backend credentials of a user of the proxy.
+type ProxyUser struct {
+	User     string
+	Password string
+}
+
+// NewProxyHandler creates the ProxyHandler to the backend address.
+func NewProxyHandler(log *xlog.Log, address, user, password string) *ProxyHandler {
+	return NewProxyHandlerWithConfig(log, &ClientConfig{User: user, Passwd: password, Addrs: strings.Split(address, ",")})
+}
+
+// NewProxyHandlerWithConfig creates the ProxyHandler to the backend of the config like the DSN.ClientConfig,
+// the DBName is replaced by the schema of the session.
+func NewProxyHandlerWithConfig(log *xlog.Log, cfg *ClientConfig) *ProxyHandler {
+	c := *cfg
+	return &ProxyHandler{
+		log:      log,
+		cfg:      &c,
+		backends: make(map[uint32]Conn),
+	}
+}
+
+// SetUserMap maps the effective users of the sessions to the backend credentials,
+// the users not mapped connect with the user and password of the proxy.
+func (h *ProxyHandler) SetUserMap(users map[string]ProxyUser) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.users = users
+}
+
+func (h *ProxyHandler) address() string {
+	return strings.Join(h.cfg.Addrs, ",")
+}
+
+// SetRecorder records the exchanges with the backend to the recorder, nil stops the recording.
+func (h *ProxyHandler) SetRecorder(recorder *Recorder) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.recorder = recorder
+}
+
+// backend returns the backend connection of the session, it's connected to the schema of the session at the first use.
+func (h *ProxyHandler) backend(s *Session) (Conn, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if conn, ok := h.backends[s.ID()]; ok {
+		return conn, nil
+	}
+	cfg := *h.cfg
+	cfg.DBName = s.Schema()
+	if user, ok := h.users[s.EffectiveUser()]; ok {
+		cfg.User, cfg.Passwd = user.User, user.Password
+	}
+	conn, err := NewConnWithConfig(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	h.backends[s.ID()] = conn
+	return conn, nil
+}
+
+// NewSession impl.
+func (h *ProxyHandler) NewSession(s *Session) {}
+
+// SessionClosed impl.
+func (h *ProxyHandler) SessionClosed(s *Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if conn, ok := h.backends[s.ID()]; ok {
+		delete(h.backends, s.ID())
+		conn.Close()
+	}
+}
+
+// SessionCheck impl.
+func (h *ProxyHandler) SessionCheck(s *Session) error {
+	return nil
+}
+
+// AuthCheck impl.
+func (h *ProxyHandler) AuthCheck(s *Session) error {
+	return nil
+}
+
+// ComInitDB impl.
+func (h *ProxyHandler) ComInitDB(s *Session, db string) error {
+	h.mu.Lock()
+	conn, ok := h.backends[s.ID()]
+	h.mu.Unlock()
+	if !ok {
+		// The backend connects to the db at the first query.
+		return nil
+	}
+	return conn.Exec(fmt.Sprintf("USE `%s`", db))
+}
+
+// ComQuery impl.
+func (h *ProxyHandler) ComQuery(s *Session, query string, callback func(*sqltypes.Result) error) error {
+	conn, err := h.backend(s)
+	if err != nil {
+		h.log.Error("proxy.session[%v].connect.backend[%s].error:%+v", s.ID(), h.address(), err)
+		return err
+	}
+
+	h.mu.Lock()
+	recorder := h.recorder
+	h.mu.Unlock()
+	var qr *sqltypes.Result
+	if recorder != nil {
+		// The recorder needs the whole result.
+		qr, err = conn.FetchAll(query, -1)
+		if rerr := recorder.Record(query, qr, err); rerr != nil {
+			h.log.Error("proxy.session[%v].record.query[%s].error:%+v", s.ID(), query, rerr)
+		}
+	} else {
+		err = CopyResult(s, conn, query)
+	}
+	if err != nil {
+		if _, ok := sqldb.ErrorNum(err); !ok || sqldb.IsClientError(err) {
+			// The backend connection is broken, the next query reconnects.
+			h.mu.Lock()
+			delete(h.backends, s.ID())
+			h.mu.Unlock()
+			conn.Close()
+		}
+		return err
+	}
+	if qr == nil {
+		// The result was copied to the session.
+		return nil
+	}
+	return callback(qr)
+}
@@ -0,0 +1,136 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+const (
+	// DefaultReconnectAttempts is the max redial times for one broken operation.
+	DefaultReconnectAttempts = 3
+
+	// DefaultReconnectBackoff is the wait time before the first redial,
+	// it's doubled on every attempt.
+	DefaultReconnectBackoff = time.Duration(50) * time.Millisecond
+
+	// DefaultReconnectMaxBackoff is the cap of the wait time.
+	DefaultReconnectMaxBackoff = time.Duration(2) * time.Second
+)
+
+// ReconnectHook is called before every redial attempt(start from 1) with the error broke the connection.
+// Returns false to veto the reconnect, then the original error is returned to the caller.
+type ReconnectHook func(attempt int, err error) bool
+
+type reconnector struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	hook       ReconnectHook
+}
+
+// SetAutoReconnect enables or disables the auto reconnect mode.
+// In this mode, if an idempotent operation(Ping, InitDB, SELECT/SHOW queries) fails
+// because the connection was broken, the client redials with exponential backoff,
+// re-authenticates, restores the schema and charset, then retries the operation once.
+func (c *conn) SetAutoReconnect(enable bool, hook ReconnectHook) {
+	if !enable {
+		c.reconnect = nil
+		return
+	}
+	c.reconnect = &reconnector{
+		attempts:   DefaultReconnectAttempts,
+		backoff:    DefaultReconnectBackoff,
+		maxBackoff: DefaultReconnectMaxBackoff,
+		hook:       hook,
+	}
+}
+
+// retry runs the fn and retries it once after a reconnect if the connection was broken.
+// Never retry in a transaction, the new connection lost it.
+func (c *conn) retry(idempotent bool, fn func() error) error {
+	inTrans := c.InTransaction()
+	err := fn()
+	if err == nil || c.reconnect == nil || !idempotent || inTrans || !c.broken(err) {
+		return err
+	}
+	if rerr := c.redial(err); rerr != nil {
+		return err
+	}
+	return fn()
+}
+
+// redial replaces the broken connection with a new one.
+func (c *conn) redial(cause error) error {
+	var err error
+	var nc *conn
+
+	r := c.reconnect
+	backoff := r.backoff
+	for attempt := 1; attempt <= r.attempts; attempt++ {
+		if r.hook != nil && !r.hook(attempt, cause) {
+			return cause
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > r.maxBackoff {
+			backoff = r.maxBackoff
+		}
+
+		if nc, err = newConn(c.config); err == nil {
+			c.adopt(nc)
+			return nil
+		}
+		cause = err
+	}
+	return err
+}
+
+// adopt replaces the connection with the new one of the same dsn.
+func (c *conn) adopt(nc *conn) {
+	c.Cleanup()
+	c.address = nc.address
+	c.netConn = nc.netConn
+	c.auth = nc.auth
+	c.greeting = nc.greeting
+	c.packets = nc.packets
+	// The status of the former connection is gone with it.
+	c.setStatus(nc.status, nc.warnings)
+
+	// The counters are kept by the ones of the connection.
+	if cc, ok := nc.netConn.(*countingConn); ok && c.stats != nil {
+		c.stats.merge(nc.stats)
+		cc.stats = c.stats
+	}
+}
+
+// broken checks whether the error broke the underlying connection.
+func (c *conn) broken(err error) bool {
+	if c.Closed() {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// isIdempotent checks whether the query can be retried safely.
+func isIdempotent(sql string) bool {
+	switch sqlparser.Preview(sql) {
+	case sqlparser.StmtSelect, sqlparser.StmtShow:
+		return true
+	}
+	return false
+}
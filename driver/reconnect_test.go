@@ -0,0 +1,132 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+func TestClientAutoReconnect(t *testing.T) {
+	result1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("10")),
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+	th.AddQuery("SELECT * FROM t1", result1)
+
+	killer, err := NewConn("mock", "mock", address, "test", "")
+	assert.Nil(t, err)
+	defer killer.Close()
+
+	// Reconnect and retry.
+	{
+		var attempts int
+		client, err := NewConn("mock", "mock", address, "test", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		client.SetAutoReconnect(true, func(attempt int, err error) bool {
+			attempts++
+			return true
+		})
+
+		oldID := client.ConnectionID()
+		_, err = killer.Query(fmt.Sprintf("KILL %d", oldID))
+		assert.Nil(t, err)
+
+		got, err := client.FetchAll("SELECT * FROM t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, result1.Rows, got.Rows)
+		assert.Equal(t, 1, attempts)
+		assert.NotEqual(t, oldID, client.ConnectionID())
+
+		err = client.Ping()
+		assert.Nil(t, err)
+	}
+
+	// Veto by hook.
+	{
+		client, err := NewConn("mock", "mock", address, "test", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		client.SetAutoReconnect(true, func(attempt int, err error) bool {
+			return false
+		})
+
+		_, err = killer.Query(fmt.Sprintf("KILL %d", client.ConnectionID()))
+		assert.Nil(t, err)
+
+		err = client.Ping()
+		assert.NotNil(t, err)
+		assert.True(t, client.Closed())
+	}
+
+	// Non-idempotent query is not retried.
+	{
+		client, err := NewConn("mock", "mock", address, "test", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		client.SetAutoReconnect(true, nil)
+
+		_, err = killer.Query(fmt.Sprintf("KILL %d", client.ConnectionID()))
+		assert.Nil(t, err)
+
+		_, err = client.Query("INSERT INTO t VALUES(1)")
+		assert.NotNil(t, err)
+
+		// Next idempotent operation reconnects.
+		err = client.Ping()
+		assert.Nil(t, err)
+	}
+}
+
+func TestClientAdoptStatus(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	fresh, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	status := fresh.Status()
+
+	// The status of the new connection replaces the one of the broken connection.
+	client.setStatus(sqldb.SERVER_STATUS_IN_TRANS, 2)
+	client.adopt(fresh)
+	assert.False(t, client.InTransaction())
+	assert.Equal(t, status, client.Status())
+	assert.Equal(t, uint16(0), client.WarningCount())
+}
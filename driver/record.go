@@ -0,0 +1,177 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// Exchange is a query and its response recorded by the Recorder, a line of JSON in the recording.
+// The NULL values of the rows are the nulls.
+type Exchange struct {
+	Query        string           `json:"query"`
+	Fields       []*querypb.Field `json:"fields,omitempty"`
+	Rows         [][][]byte       `json:"rows,omitempty"`
+	RowsAffected uint64           `json:"rows_affected,omitempty"`
+	InsertID     uint64           `json:"insert_id,omitempty"`
+	Warnings     uint16           `json:"warnings,omitempty"`
+	Error        *sqldb.SQLError  `json:"error,omitempty"`
+}
+
+// newExchange creates the Exchange of the query answered by the result or the err.
+func newExchange(query string, result *sqltypes.Result, err error) *Exchange {
+	e := &Exchange{Query: query}
+	if err != nil {
+		e.Error = sqldb.NewSQLErrorFromError(err).(*sqldb.SQLError)
+		return e
+	}
+	e.Fields = result.Fields
+	e.RowsAffected = result.RowsAffected
+	e.InsertID = result.InsertID
+	e.Warnings = result.Warnings
+	for _, row := range result.Rows {
+		values := make([][]byte, len(row))
+		for i, v := range row {
+			if !v.IsNull() {
+				values[i] = append([]byte{}, v.Raw()...)
+			}
+		}
+		e.Rows = append(e.Rows, values)
+	}
+	return e
+}
+
+// Result returns the recorded response, the error if the query was rejected.
+func (e *Exchange) Result() (*sqltypes.Result, error) {
+	if e.Error != nil {
+		return nil, e.Error
+	}
+	qr := &sqltypes.Result{
+		Fields:       e.Fields,
+		RowsAffected: e.RowsAffected,
+		InsertID:     e.InsertID,
+		Warnings:     e.Warnings,
+	}
+	for _, values := range e.Rows {
+		if len(values) != len(e.Fields) {
+			return nil, fmt.Errorf("replay.query[%s].row.values[%d].fields[%d].mismatch", e.Query, len(values), len(e.Fields))
+		}
+		row := make([]sqltypes.Value, len(values))
+		for i, b := range values {
+			row[i] = sqltypes.NULL
+			if b != nil {
+				row[i] = sqltypes.MakeTrusted(e.Fields[i].Type, b)
+			}
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	return qr, nil
+}
+
+// Recorder writes the exchanges as the JSON lines, it's safe for the concurrent sessions.
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder creates the Recorder writes to the w.
+func NewRecorder(w io.Writer) *Recorder {
+	return &Recorder{enc: json.NewEncoder(w)}
+}
+
+// Record writes the exchange of the query.
+func (r *Recorder) Record(query string, result *sqltypes.Result, err error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.enc.Encode(newExchange(query, result, err))
+}
+
+// ReadExchanges reads the exchanges of a recording.
+func ReadExchanges(r io.Reader) ([]*Exchange, error) {
+	var exchanges []*Exchange
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		e := &Exchange{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("replay.recording.line[%d].error:%v", line, err)
+		}
+		exchanges = append(exchanges, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return exchanges, nil
+}
+
+// ReplayHandler is the Handler answers the queries from a recording, the identical queries are answered
+// in the order they were recorded and the last answer repeats.
+type ReplayHandler struct {
+	*TestHandler
+	mu        sync.Mutex
+	exchanges map[string][]*Exchange
+}
+
+// NewReplayHandler creates the ReplayHandler from the recording by the Recorder.
+func NewReplayHandler(log *xlog.Log, recording io.Reader) (*ReplayHandler, error) {
+	exchanges, err := ReadExchanges(recording)
+	if err != nil {
+		return nil, err
+	}
+	h := &ReplayHandler{TestHandler: NewTestHandler(log), exchanges: make(map[string][]*Exchange)}
+	for _, e := range exchanges {
+		h.exchanges[e.Query] = append(h.exchanges[e.Query], e)
+	}
+	return h, nil
+}
+
+// AuthCheck impl, any user is accepted.
+func (h *ReplayHandler) AuthCheck(s *Session) error {
+	return nil
+}
+
+// ComInitDB impl.
+func (h *ReplayHandler) ComInitDB(s *Session, db string) error {
+	return nil
+}
+
+// ComQuery impl.
+func (h *ReplayHandler) ComQuery(s *Session, query string, callback func(*sqltypes.Result) error) error {
+	h.mu.Lock()
+	queue := h.exchanges[query]
+	if len(queue) == 0 {
+		h.mu.Unlock()
+		return fmt.Errorf("replay.handler.query[%s].not.recorded", query)
+	}
+	e := queue[0]
+	if len(queue) > 1 {
+		h.exchanges[query] = queue[1:]
+	}
+	h.mu.Unlock()
+
+	qr, err := e.Result()
+	if err != nil {
+		return err
+	}
+	return callback(qr)
+}
@@ -0,0 +1,101 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestProxyRecordAndReplay(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	backend, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer backend.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}, {Name: "b", Type: querypb.Type_VARCHAR}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.NewVarChar("")},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")), sqltypes.NULL},
+		},
+	}
+	th.AddQuery("SELECT a, b FROM t1", result)
+	th.AddQuerys("select count(*) from t1", &sqltypes.Result{RowsAffected: 1}, &sqltypes.Result{RowsAffected: 2})
+	th.AddQuery("UPDATE t1 SET a = 3", &sqltypes.Result{RowsAffected: 2})
+	th.AddQueryError("SELECT * FROM t2", sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t2"))
+
+	// Record the exchanges by the proxy.
+	recording := &bytes.Buffer{}
+	ph := NewProxyHandler(log, backend.Addr(), "mock", "mock")
+	ph.SetRecorder(NewRecorder(recording))
+	proxy, err := MockMysqlServer(log, ph)
+	assert.Nil(t, err)
+	defer proxy.Close()
+
+	queries := []string{"SELECT a, b FROM t1", "select count(*) from t1", "select count(*) from t1", "UPDATE t1 SET a = 3", "SELECT * FROM t2"}
+	fetch := func(address string) ([]*sqltypes.Result, []error) {
+		client, err := NewConn("mock", "mock", address, "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		var results []*sqltypes.Result
+		var errs []error
+		for _, query := range queries {
+			qr, err := client.FetchAll(query, -1)
+			results = append(results, qr)
+			errs = append(errs, err)
+		}
+		return results, errs
+	}
+	recorded, recordedErrs := fetch(proxy.Addr())
+	assert.Equal(t, result.Rows, recorded[0].Rows)
+	assert.Equal(t, uint64(2), recorded[2].RowsAffected)
+	assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), recordedErrs[4].(*sqldb.SQLError).Num)
+
+	// Replay the recording without the backend.
+	rh, err := NewReplayHandler(log, bytes.NewReader(recording.Bytes()))
+	assert.Nil(t, err)
+	replay, err := MockMysqlServer(log, rh)
+	assert.Nil(t, err)
+	defer replay.Close()
+
+	replayed, replayedErrs := fetch(replay.Addr())
+	for i := range queries {
+		if recorded[i] == nil {
+			assert.Nil(t, replayed[i])
+		} else {
+			assert.Equal(t, recorded[i].Rows, replayed[i].Rows, queries[i])
+			assert.Equal(t, recorded[i].RowsAffected, replayed[i].RowsAffected, queries[i])
+		}
+		if recordedErrs[i] == nil {
+			assert.Nil(t, replayedErrs[i])
+		} else {
+			assert.Equal(t, recordedErrs[i].Error(), replayedErrs[i].Error())
+		}
+	}
+
+	// The queries not recorded.
+	{
+		client, err := NewConn("mock", "mock", replay.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("SELECT 1", -1)
+		assert.NotNil(t, err)
+	}
+}
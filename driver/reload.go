@@ -0,0 +1,153 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/tls"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// apply sets the settings of the validated config with the defaults filled, they're set at once,
+// a session coming in the middle sees the former or the new ones.
+func (l *Listener) apply(cfg *ListenerConfig) {
+	greeting := *cfg.Greeting
+	x509Rules := make(map[string]*X509Rule, len(cfg.X509Users))
+	for user, rule := range cfg.X509Users {
+		if rule != nil {
+			r := *rule
+			x509Rules[user] = &r
+		}
+	}
+	secureTransportUsers := make(map[string]bool, len(cfg.SecureTransportUsers))
+	for _, user := range cfg.SecureTransportUsers {
+		secureTransportUsers[user] = true
+	}
+
+	l.mu.Lock()
+	// The expiries marked by the SetPasswordExpired and the failures of the throttle survive the reload.
+	expiredUsers := make(map[string]bool, len(l.expiredUsers)+len(cfg.ExpiredUsers))
+	for user := range l.expiredUsers {
+		expiredUsers[user] = true
+	}
+	for _, user := range cfg.ExpiredUsers {
+		expiredUsers[user] = true
+	}
+	if cfg.AuthThrottle != nil && l.throttle != nil && cfg.AuthThrottle != l.throttle {
+		cfg.AuthThrottle.inherit(l.throttle)
+	}
+	l.greeting = &greeting
+	l.tls = cfg.TLS
+	l.requireSecureTransport = cfg.RequireSecureTransport
+	l.secureTransportUsers = secureTransportUsers
+	l.strictCapabilities = cfg.StrictCapabilities
+	l.handshakePolicy = cfg.HandshakePolicy
+	l.delegate = cfg.AuthDelegate
+	l.x509Rules = x509Rules
+	l.throttle = cfg.AuthThrottle
+	l.pool = cfg.ThreadPool
+	l.expiredUsers = expiredUsers
+	l.handshakeTimeout = cfg.HandshakeTimeout
+	l.sessionMemoryLimit = cfg.SessionMemoryLimit
+	l.flushPolicy = cfg.FlushPolicy
+	l.outputQueueSize = cfg.OutputQueueSize
+	l.infoSchema = cfg.InfoSchema
+	l.resultTimeZone = cfg.ResultTimeZone
+	l.trace = cfg.Trace
+	l.mu.Unlock()
+
+	l.sysvars.Set("version", sqltypes.NewVarChar(greeting.ServerVersion))
+	if cfg.LogLevel != "" {
+		l.log.SetLevel(cfg.LogLevel)
+	}
+}
+
+// Reload replaces the settings of the running listener by the config without dropping the sessions,
+// like rotating the TLS certificates or the users of a long-lived proxy.
+// The config is the whole one like the NewListenerWithConfig's, the zero values are the defaults,
+// it's validated first and nothing is applied if it's invalid.
+// The Address and the Log can't be changed, they are ignored. The LogLevel applies at once,
+// the others apply to the coming sessions, the live sessions keep the ones they started with.
+// The ExpiredUsers are added to the expired ones, the AuthThrottle takes over the failures and the lockouts
+// of the former one, the SetAuthThrottle or the nil AuthThrottle drops them.
+func (l *Listener) Reload(cfg *ListenerConfig) error {
+	if err := cfg.Validate(); err != nil {
+		l.log.Error("server.reload.config.error:%v", err)
+		return err
+	}
+	cfg = cfg.withDefaults()
+	l.apply(cfg)
+	l.log.Info("server.reload.done")
+	return nil
+}
+
+// ReloadOnSignal reloads the listener by the config the load returns once the process gets the signals,
+// the SIGHUP if none. The failed loads and reloads are logged, the former config is kept.
+// The returned func stops it.
+func (l *Listener) ReloadOnSignal(load func() (*ListenerConfig, error), sigs ...os.Signal) func() {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{syscall.SIGHUP}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				l.log.Info("server.reload.signal[%v]", sig)
+				cfg, err := load()
+				if err != nil {
+					l.log.Error("server.reload.load.error:%v", err)
+					continue
+				}
+				l.Reload(cfg)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+// SetTLSConfig sets the TLS the coming sessions switch to by the SSLRequest, nil disables it.
+// The certificates are rotated by setting the new config, the TLS sessions keep theirs.
+func (l *Listener) SetTLSConfig(cfg *tls.Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tls = cfg
+}
+
+func (l *Listener) tlsConfig() *tls.Config {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.tls
+}
+
+// SetHandshakeTimeout sets the timeout from the greeting to the auth OK of the coming sessions, 0 is no timeout.
+func (l *Listener) SetHandshakeTimeout(timeout time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.handshakeTimeout = timeout
+}
+
+// HandshakeTimeout returns the timeout from the greeting to the auth OK, 0 if no timeout.
+func (l *Listener) HandshakeTimeout() time.Duration {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.handshakeTimeout
+}
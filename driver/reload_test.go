@@ -0,0 +1,131 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListenerReload(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	live, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer live.Close()
+
+	// The invalid config is refused as a whole.
+	err = svr.Reload(&ListenerConfig{RequireSecureTransport: true, HandshakeTimeout: -time.Second})
+	assert.NotNil(t, err)
+	assert.False(t, svr.requiresSecureTransport("mock"))
+	err = svr.Reload(&ListenerConfig{LogLevel: "LOUD"})
+	assert.EqualError(t, err, "driver.listener.config.unknown.log.level[LOUD]")
+
+	// The TLS is required, the live session goes on.
+	serverTLS, clientTLS := newTestTLSConfig(t)
+	err = svr.Reload(&ListenerConfig{TLS: serverTLS, RequireSecureTransport: true, HandshakeTimeout: time.Second, LogLevel: "PANIC"})
+	assert.Nil(t, err)
+	assert.Equal(t, xlog.PANIC, log.Level())
+	assert.Equal(t, time.Second, svr.HandshakeTimeout())
+	assert.Nil(t, live.Ping())
+
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_SECURE_TRANSPORT_REQUIRED), num)
+	secure, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: clientTLS})
+	assert.Nil(t, err)
+	defer secure.Close()
+	assert.Nil(t, secure.Ping())
+
+	// The certificates are rotated, the TLS session goes on.
+	rotatedTLS, rotatedClientTLS := newTestTLSConfig(t)
+	svr.SetTLSConfig(rotatedTLS)
+	_, err = NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: clientTLS})
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.CR_SSL_CONNECTION_ERROR), num)
+	rotated, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: rotatedClientTLS})
+	assert.Nil(t, err)
+	defer rotated.Close()
+	assert.Nil(t, secure.Ping())
+}
+
+func TestListenerReloadOnSignal(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	loads := make(chan *ListenerConfig, 2)
+	loaded := make(chan struct{}, 2)
+	stop := svr.ReloadOnSignal(func() (*ListenerConfig, error) {
+		defer func() { loaded <- struct{}{} }()
+		if cfg := <-loads; cfg != nil {
+			return cfg, nil
+		}
+		return nil, errors.New("mock.load.error")
+	})
+	defer stop()
+	hup := func(cfg *ListenerConfig) {
+		loads <- cfg
+		p, err := os.FindProcess(os.Getpid())
+		assert.Nil(t, err)
+		assert.Nil(t, p.Signal(syscall.SIGHUP))
+		<-loaded
+	}
+
+	hup(&ListenerConfig{ExpiredUsers: []string{"mock"}, HandshakeTimeout: time.Second})
+	for !svr.PasswordExpired("mock") {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, time.Second, svr.HandshakeTimeout())
+
+	// The failed load keeps the former config.
+	hup(nil)
+	assert.True(t, svr.PasswordExpired("mock"))
+}
+
+func TestListenerReloadKeepsState(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, AuthThrottle: &AuthThrottle{LockoutThreshold: 1}}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	// The user is locked and the expiry is marked at runtime.
+	_, err = NewConn("other", "", svr.Addr(), "", "")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+	svr.SetPasswordExpired("app", true)
+
+	assert.Nil(t, svr.Reload(&ListenerConfig{AuthThrottle: &AuthThrottle{LockoutThreshold: 3}, ExpiredUsers: []string{"ops"}}))
+	_, err = NewConn("other", "", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK), num)
+	assert.True(t, svr.PasswordExpired("app"))
+	assert.True(t, svr.PasswordExpired("ops"))
+
+	// The nil throttle drops the lockouts.
+	assert.Nil(t, svr.Reload(&ListenerConfig{}))
+	_, err = NewConn("other", "", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+}
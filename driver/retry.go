@@ -0,0 +1,106 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// DefaultRetryAttempts is the max executions of the query including the first one.
+	DefaultRetryAttempts = 3
+)
+
+// RetryPolicy retries the Query, Exec and FetchAll failed by the transient errors.
+// The query in a transaction is never retried, the error may have rolled it back.
+type RetryPolicy struct {
+	// MaxAttempts is the max executions including the first one, the query isn't retried if it's less than 2.
+	MaxAttempts int
+
+	// Backoff is the wait time before the first retry, it's doubled on every retry up to the MaxBackoff.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable classifies the errors to retry, it's the IsRetryable if nil.
+	Retryable func(err error) bool
+
+	// Idempotent guards the queries to retry, only the SELECT and SHOW are retried if nil.
+	Idempotent func(query string) bool
+}
+
+// DefaultRetryPolicy returns the policy of the DefaultRetryAttempts with the reconnect backoffs.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts: DefaultRetryAttempts,
+		Backoff:     DefaultReconnectBackoff,
+		MaxBackoff:  DefaultReconnectMaxBackoff,
+	}
+}
+
+// IsRetryable returns true for the lost connections, the deadlocks and the lock wait timeouts.
+func IsRetryable(err error) bool {
+	return sqldb.IsErrorNum(err, sqldb.CR_SERVER_LOST, sqldb.CR_SERVER_GONE_ERROR, sqldb.CR_CONN_HOST_ERROR, sqldb.ER_LOCK_WAIT_TIMEOUT, sqldb.ER_LOCK_DEADLOCK)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+func (p *RetryPolicy) idempotent(query string) bool {
+	if p.Idempotent != nil {
+		return p.Idempotent(query)
+	}
+	return isIdempotent(query)
+}
+
+// SetRetryPolicy sets the retry policy of the queries, nil disables it.
+func (c *conn) SetRetryPolicy(policy *RetryPolicy) {
+	if policy == nil {
+		c.policy = nil
+		return
+	}
+	p := *policy
+	c.policy = &p
+}
+
+// retryQuery runs the fn of the query by the retry policy, every attempt is retried by the auto reconnect too.
+// The broken connection is redialed before the retry.
+func (c *conn) retryQuery(query string, fn func() error) error {
+	p := c.policy
+	if p == nil {
+		return c.retry(isIdempotent(query), fn)
+	}
+
+	inTrans := c.InTransaction()
+	idempotent := p.idempotent(query)
+	err := c.retry(idempotent, fn)
+	backoff := p.Backoff
+	for attempt := 2; attempt <= p.MaxAttempts && err != nil && !inTrans && idempotent && p.retryable(err); attempt++ {
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > p.MaxBackoff {
+			backoff = p.MaxBackoff
+		}
+
+		if c.Closed() {
+			var nc *conn
+			if nc, err = newConn(c.config); err != nil {
+				continue
+			}
+			c.adopt(nc)
+		}
+		err = c.retry(idempotent, fn)
+	}
+	return err
+}
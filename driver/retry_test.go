@@ -0,0 +1,156 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// flakyHandler fails the queries by the deadlock the fails times.
+type flakyHandler struct {
+	txnHandler
+	mu    sync.Mutex
+	fails int
+	calls int
+}
+
+func (h *flakyHandler) reset(fails int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.fails = fails
+	h.calls = 0
+}
+
+func (h *flakyHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+func (h *flakyHandler) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	if strings.HasPrefix(strings.ToLower(query), "select") || strings.HasPrefix(strings.ToLower(query), "insert") {
+		h.mu.Lock()
+		h.calls++
+		fail := h.calls <= h.fails
+		h.mu.Unlock()
+		if fail {
+			return sqldb.NewSQLError(sqldb.ER_LOCK_DEADLOCK, "")
+		}
+	}
+	return h.txnHandler.ComQuery(session, query, callback)
+}
+
+func TestClientRetryPolicy(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	fh := &flakyHandler{txnHandler: txnHandler{th}}
+	svr, err := MockMysqlServer(log, fh)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	}
+	th.AddQuery("SELECT id FROM t1", result)
+	th.AddQuery("INSERT INTO t1 VALUES(1)", &sqltypes.Result{RowsAffected: 1})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// No policy.
+	fh.reset(1)
+	_, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+
+	policy := DefaultRetryPolicy()
+	policy.Backoff = time.Millisecond
+	policy.MaxBackoff = time.Millisecond
+	client.SetRetryPolicy(policy)
+
+	// The SELECT is retried.
+	fh.reset(2)
+	qr, err := client.FetchAll("SELECT id FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Rows, qr.Rows)
+	assert.Equal(t, 3, fh.count())
+
+	// Out of the attempts.
+	fh.reset(3)
+	_, err = client.Query("SELECT id FROM t1")
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+	assert.Equal(t, 3, fh.count())
+
+	// The INSERT is not idempotent by default.
+	fh.reset(1)
+	err = client.Exec("INSERT INTO t1 VALUES(1)")
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+	assert.Equal(t, 1, fh.count())
+
+	policy.Idempotent = func(query string) bool { return true }
+	client.SetRetryPolicy(policy)
+	fh.reset(1)
+	assert.Nil(t, client.Exec("INSERT INTO t1 VALUES(1)"))
+	assert.Equal(t, 2, fh.count())
+
+	// Never in the transaction.
+	assert.Nil(t, client.Exec("BEGIN"))
+	fh.reset(1)
+	err = client.Exec("INSERT INTO t1 VALUES(1)")
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+	assert.Equal(t, 1, fh.count())
+	assert.Nil(t, client.Exec("ROLLBACK"))
+
+	// The classifier.
+	policy.Retryable = func(err error) bool { return false }
+	client.SetRetryPolicy(policy)
+	fh.reset(1)
+	_, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+
+	// The broken connection is redialed.
+	client.SetRetryPolicy(DefaultRetryPolicy())
+	fh.reset(0)
+	killer, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer killer.Close()
+	oldID := client.ConnectionID()
+	assert.Nil(t, killer.Exec(fmt.Sprintf("KILL %d", oldID)))
+	qr, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Rows, qr.Rows)
+	assert.NotEqual(t, oldID, client.ConnectionID())
+
+	// Disabled.
+	client.SetRetryPolicy(nil)
+	fh.reset(1)
+	_, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.True(t, sqldb.IsErrorNum(err, sqldb.ER_LOCK_DEADLOCK))
+}
+
+func TestIsRetryable(t *testing.T) {
+	assert.True(t, IsRetryable(sqldb.NewSQLError(sqldb.CR_SERVER_LOST, "")))
+	assert.True(t, IsRetryable(sqldb.NewSQLError(sqldb.ER_LOCK_WAIT_TIMEOUT, "")))
+	assert.False(t, IsRetryable(sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t1")))
+	assert.False(t, IsRetryable(errors.New("x")))
+}
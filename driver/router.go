@@ -0,0 +1,420 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Route is the target of the statement of the read/write splitting.
+type Route int
+
+const (
+	// RouteWriter routes the statement to the writer.
+	RouteWriter Route = iota
+
+	// RouteReader routes the statement to the readers.
+	RouteReader
+)
+
+// routedSessionsMax is the SET and USE statements replayed to the reader, the session is pinned to the writer beyond them.
+const routedSessionsMax = 64
+
+// writerFuncs are the functions of the SELECT taking or reading the state of the writer session.
+var writerFuncs = regexp.MustCompile(`(?i)\b(?:get_lock|release_lock|release_all_locks|is_free_lock|is_used_lock|last_insert_id|found_rows|row_count)\s*\(|\binto\b`)
+
+// Classify returns the route of the query, the SELECT without the locking read and the SHOW go to the readers,
+// the others go to the writer. The SELECT with the side effects or the state of the session goes to the writer too:
+// the INTO @var or OUTFILE, the GET_LOCK, the LAST_INSERT_ID and the user variables set on the writer.
+func Classify(query string) Route {
+	switch sqlparser.Preview(query) {
+	case sqlparser.StmtSelect:
+		if isLockingRead(query) || isSessionRead(query) {
+			return RouteWriter
+		}
+		return RouteReader
+	case sqlparser.StmtShow:
+		return RouteReader
+	}
+	return RouteWriter
+}
+
+// isLockingRead checks whether the SELECT has the FOR UPDATE or the LOCK IN SHARE MODE,
+// the query the parser doesn't support is checked by the text.
+func isLockingRead(query string) bool {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		lowered := strings.ToLower(query)
+		return strings.Contains(lowered, "for update") || strings.Contains(lowered, "lock in share mode") || strings.Contains(lowered, "for share")
+	}
+	switch stmt := stmt.(type) {
+	case *sqlparser.Select:
+		return stmt.Lock != ""
+	case *sqlparser.Union:
+		return stmt.Lock != ""
+	}
+	return false
+}
+
+// isSessionRead checks whether the SELECT has the INTO, the user variables or the functions of the session state,
+// the strings, the quoted identifiers and the comments are skipped.
+func isSessionRead(query string) bool {
+	var buf strings.Builder
+	for i := 0; i < len(query); i++ {
+		if j := sqlparser.SkipLiteral(query, i, 0); j != i {
+			buf.WriteByte(' ')
+			i = j
+			continue
+		}
+		// The @var but not the @@ system variable.
+		if query[i] == '@' {
+			if i+1 < len(query) && query[i+1] == '@' {
+				i++
+				continue
+			}
+			return true
+		}
+		buf.WriteByte(query[i])
+	}
+	return writerFuncs.MatchString(buf.String())
+}
+
+// Router is the read/write splitting client of the writer pool and the replica pools,
+// the readers are picked by the balancer from the healthy replicas.
+type Router struct {
+	writer   *Pool
+	replicas []*Replica
+
+	mu       sync.RWMutex
+	balancer Balancer
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewRouter creates the router of the writer dsn and the reader dsns, every pool keeps at most maxIdle connections.
+// The readers are balanced by the round robin.
+func NewRouter(writer string, readers []string, maxIdle int) (*Router, error) {
+	r := &Router{balancer: &RoundRobinBalancer{}}
+	var err error
+	if r.writer, err = NewPool(writer, maxIdle); err != nil {
+		return nil, err
+	}
+	for _, reader := range readers {
+		replica, err := newReplica(reader, maxIdle)
+		if err != nil {
+			return nil, err
+		}
+		r.replicas = append(r.replicas, replica)
+	}
+	return r, nil
+}
+
+// SetBalancer sets the balancer of the readers.
+func (r *Router) SetBalancer(balancer Balancer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.balancer = balancer
+}
+
+// Replicas returns the replicas of the readers.
+func (r *Router) Replicas() []*Replica {
+	return r.replicas
+}
+
+// Conn returns the routing connection, the backend connections are got from the pools at the first use.
+func (r *Router) Conn() *RoutedConn {
+	return &RoutedConn{router: r, autocommit: true}
+}
+
+// reader returns the replica picked by the balancer from the healthy ones, nil if there is none.
+func (r *Router) reader() *Replica {
+	healthy := make([]*Replica, 0, len(r.replicas))
+	for _, replica := range r.replicas {
+		if replica.Healthy() {
+			healthy = append(healthy, replica)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.balancer.Pick(healthy)
+}
+
+// CheckReplicas checks the health and the lag of all the replicas, the unhealthy ones are excluded from the readers
+// until they pass the next check. The maxLag isn't checked if it's zero, the first error is returned.
+func (r *Router) CheckReplicas(maxLag time.Duration) error {
+	var first error
+	for _, replica := range r.replicas {
+		if err := replica.check(maxLag); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// StartHealthCheck checks the replicas every interval in the background until the router is closed.
+func (r *Router) StartHealthCheck(interval time.Duration, maxLag time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.wg.Add(1)
+	go func(stop chan struct{}) {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				r.CheckReplicas(maxLag)
+			}
+		}
+	}(r.stop)
+}
+
+// Close stops the health check and closes all the pools.
+func (r *Router) Close() {
+	r.mu.Lock()
+	if r.stop != nil {
+		close(r.stop)
+		r.stop = nil
+	}
+	r.mu.Unlock()
+	r.wg.Wait()
+
+	r.writer.Close()
+	for _, replica := range r.replicas {
+		replica.pool.Close()
+	}
+}
+
+// RoutedConn routes the statements of the session to the writer or the reader connection by the Classify,
+// all the statements go to the writer in a transaction or with the autocommit off.
+// The SET and USE statements are applied to both of them, the reader falls back to the writer if it can't be connected.
+// The SET mixing the global and the session variables pins the session to the writer.
+// It's not safe for the concurrent use as the Conn.
+type RoutedConn struct {
+	router     *Router
+	writer     Conn
+	reader     Conn
+	replica    *Replica
+	autocommit bool
+
+	// sessions are the SET and USE statements replayed to the reader got later.
+	sessions []string
+
+	// writerOnly pins the session to the writer once its state can't be replayed to the reader,
+	// like the SET of the global and the session variables or too many session statements.
+	writerOnly bool
+}
+
+// Writer returns the writer connection.
+func (rc *RoutedConn) Writer() (Conn, error) {
+	if rc.writer == nil {
+		c, err := rc.router.writer.Get()
+		if err != nil {
+			return nil, err
+		}
+		rc.writer = c
+	}
+	return rc.writer, nil
+}
+
+// Reader returns the reader connection, it's the writer if there is no healthy reader or the reader can't be connected.
+func (rc *RoutedConn) Reader() (Conn, error) {
+	if rc.reader != nil {
+		return rc.reader, nil
+	}
+	if rc.writerOnly {
+		return rc.Writer()
+	}
+	if replica := rc.router.reader(); replica != nil {
+		if c, err := replica.pool.Get(); err == nil {
+			for _, query := range rc.sessions {
+				if err = c.Exec(query); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				atomic.AddInt64(&replica.active, 1)
+				rc.reader, rc.replica = c, replica
+				return c, nil
+			}
+			replica.pool.discard(c)
+		}
+	}
+	return rc.Writer()
+}
+
+// pinned checks whether the session is pinned to the writer.
+func (rc *RoutedConn) pinned() bool {
+	return rc.writerOnly || !rc.autocommit || (rc.writer != nil && rc.writer.InTransaction())
+}
+
+// route returns the connection of the query.
+func (rc *RoutedConn) route(query string) (Conn, error) {
+	if rc.pinned() || Classify(query) == RouteWriter {
+		return rc.Writer()
+	}
+	return rc.Reader()
+}
+
+// session applies the SET or USE statement to the writer and the reader, returns false if it isn't one of them.
+func (rc *RoutedConn) session(query string) (bool, error) {
+	var autocommit *bool
+	mixed := false
+	switch sqlparser.Preview(query) {
+	case sqlparser.StmtSet:
+		vars, err := sqlparser.ParseSetVars(query)
+		if err != nil {
+			return false, nil
+		}
+		global := true
+		for _, v := range vars {
+			// The global ones mustn't be replayed to the reader.
+			if v.Scope == sqlparser.SetScopeGlobal {
+				mixed = true
+				continue
+			}
+			global = false
+			if v.Scope == sqlparser.SetScopeSession && v.Name == "autocommit" {
+				if on, err := v.Bool(); err == nil {
+					autocommit = &on
+				}
+			}
+		}
+		if global {
+			return false, nil
+		}
+	case sqlparser.StmtUse:
+	default:
+		return false, nil
+	}
+
+	writer, err := rc.Writer()
+	if err != nil {
+		return true, err
+	}
+	if err = writer.Exec(query); err != nil {
+		return true, err
+	}
+	if autocommit != nil {
+		rc.autocommit = *autocommit
+	}
+	switch {
+	case rc.writerOnly:
+	case mixed || len(rc.sessions) >= routedSessionsMax:
+		// The reader can't follow the state of the session, it's closed and the writer serves the rest.
+		rc.writerOnly = true
+		rc.sessions = nil
+		if rc.reader != nil {
+			rc.replica.pool.discard(rc.reader)
+			atomic.AddInt64(&rc.replica.active, -1)
+			rc.reader, rc.replica = nil, nil
+		}
+	default:
+		if rc.reader != nil {
+			if err = rc.reader.Exec(query); err != nil {
+				return true, err
+			}
+		}
+		// The repeated statement changes nothing.
+		if n := len(rc.sessions); n == 0 || rc.sessions[n-1] != query {
+			rc.sessions = append(rc.sessions, query)
+		}
+	}
+	return true, nil
+}
+
+// Query executes the query on the routed connection and returns the row cursor.
+func (rc *RoutedConn) Query(query string) (Rows, error) {
+	if ok, err := rc.session(query); ok {
+		if err != nil {
+			return nil, err
+		}
+		return NewTextRows(rc.writer), nil
+	}
+	c, err := rc.route(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.Query(query)
+}
+
+// Exec executes the query on the routed connection and drains the results.
+func (rc *RoutedConn) Exec(query string) error {
+	if ok, err := rc.session(query); ok {
+		return err
+	}
+	c, err := rc.route(query)
+	if err != nil {
+		return err
+	}
+	return c.Exec(query)
+}
+
+// FetchAll executes the query on the routed connection and fetches all the results.
+func (rc *RoutedConn) FetchAll(query string, maxrows int) (*sqltypes.Result, error) {
+	if ok, err := rc.session(query); ok {
+		if err != nil {
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	}
+	c, err := rc.route(query)
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchAll(query, maxrows)
+}
+
+// Transaction runs the fn in a transaction of the writer.
+func (rc *RoutedConn) Transaction(fn func(tx *Tx) error) error {
+	writer, err := rc.Writer()
+	if err != nil {
+		return err
+	}
+	return writer.Transaction(fn)
+}
+
+// Close returns the connections to the pools, the ones with the session state changed by the SET or USE
+// and the writer in a transaction are closed.
+func (rc *RoutedConn) Close() {
+	release := func(pool *Pool, c Conn) {
+		if len(rc.sessions) > 0 || rc.writerOnly || pool.Put(c) != nil {
+			pool.discard(c)
+		}
+	}
+	if rc.writer != nil {
+		release(rc.router.writer, rc.writer)
+		rc.writer = nil
+	}
+	if rc.reader != nil {
+		release(rc.replica.pool, rc.reader)
+		atomic.AddInt64(&rc.replica.active, -1)
+		rc.reader, rc.replica = nil, nil
+	}
+	rc.sessions = nil
+	rc.writerOnly = false
+	rc.autocommit = true
+}
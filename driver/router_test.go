@@ -0,0 +1,298 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		query string
+		want  Route
+	}{
+		{query: "SELECT * FROM t1", want: RouteReader},
+		{query: "/* c */ select 1", want: RouteReader},
+		{query: "SHOW TABLES", want: RouteReader},
+		{query: "SELECT * FROM t1 WHERE id = 1 FOR UPDATE", want: RouteWriter},
+		{query: "SELECT * FROM t1 LOCK IN SHARE MODE", want: RouteWriter},
+		{query: "select * from t1 union select * from t2 for update", want: RouteWriter},
+		{query: "SELECT JSON_EXTRACT(a, '$.b') FROM t1 FOR SHARE", want: RouteWriter},
+		{query: "INSERT INTO t1 VALUES(1)", want: RouteWriter},
+		{query: "UPDATE t1 SET a = 1", want: RouteWriter},
+		{query: "DELETE FROM t1", want: RouteWriter},
+		{query: "CREATE TABLE t1(a INT)", want: RouteWriter},
+		{query: "BEGIN", want: RouteWriter},
+		{query: "SELECT a INTO @v FROM t1", want: RouteWriter},
+		{query: "SELECT * FROM t1 INTO OUTFILE '/tmp/t1'", want: RouteWriter},
+		{query: "SELECT GET_LOCK('l', 1)", want: RouteWriter},
+		{query: "select release_lock('l')", want: RouteWriter},
+		{query: "SELECT LAST_INSERT_ID()", want: RouteWriter},
+		{query: "SELECT @v", want: RouteWriter},
+		{query: "SELECT @@version, 'a@b', `into` FROM t1 -- into @v", want: RouteReader},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, Classify(test.query), test.query)
+	}
+}
+
+func TestRouter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	result := func(name string) *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "name", Type: querypb.Type_VARCHAR}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name))}},
+		}
+	}
+
+	wh := NewTestHandler(log)
+	writer, err := MockMysqlServer(log, &txnHandler{wh})
+	assert.Nil(t, err)
+	defer writer.Close()
+	rh := NewTestHandler(log)
+	reader, err := MockMysqlServer(log, &txnHandler{rh})
+	assert.Nil(t, err)
+	defer reader.Close()
+	for _, th := range []*TestHandler{wh, rh} {
+		th.AddQuery("set autocommit=0", &sqltypes.Result{})
+		th.AddQuery("set autocommit=1", &sqltypes.Result{})
+		th.AddQuery("use db1", &sqltypes.Result{})
+	}
+	wh.AddQuery("SELECT name FROM t1", result("writer"))
+	wh.AddQuery("SELECT name FROM t1 FOR UPDATE", result("writer"))
+	wh.AddQuery("INSERT INTO t1 VALUES('x')", &sqltypes.Result{RowsAffected: 1})
+	rh.AddQuery("SELECT name FROM t1", result("reader"))
+
+	router, err := NewRouter(fmt.Sprintf("mock:mock@tcp(%s)/", writer.Addr()), []string{fmt.Sprintf("mock:mock@tcp(%s)/", reader.Addr())}, 2)
+	assert.Nil(t, err)
+	defer router.Close()
+
+	routed := func(rc *RoutedConn, query string) string {
+		qr, err := rc.FetchAll(query, -1)
+		assert.Nil(t, err)
+		return qr.Rows[0][0].String()
+	}
+
+	rc := router.Conn()
+	defer rc.Close()
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+	assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1 FOR UPDATE"))
+	assert.Nil(t, rc.Exec("INSERT INTO t1 VALUES('x')"))
+
+	// Pinned in the transaction.
+	assert.Nil(t, rc.Exec("BEGIN"))
+	assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	assert.Nil(t, rc.Exec("COMMIT"))
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+	err = rc.Transaction(func(tx *Tx) error {
+		qr, err := tx.FetchAll("SELECT name FROM t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "writer", qr.Rows[0][0].String())
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// Pinned with the autocommit off.
+	assert.Nil(t, rc.Exec("SET autocommit=0"))
+	assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	_, err = rc.FetchAll("SET autocommit=1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+
+	// The session statements are replayed to the reader got later.
+	rc.Close()
+	assert.Nil(t, rc.Exec("USE db1"))
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+	rows, err := rc.Query("USE db1")
+	assert.Nil(t, err)
+	assert.False(t, rows.Next())
+	assert.Equal(t, 1, len(rc.sessions))
+
+	// The mixed SET isn't replayed to the reader, the session stays on the writer.
+	wh.AddQuery("SET GLOBAL max_connections = 10, @a = 1", &sqltypes.Result{})
+	assert.Nil(t, rc.Exec("SET GLOBAL max_connections = 10, @a = 1"))
+	assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	rc.Close()
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+	rc.Close()
+
+	// The session statements are bounded, the session beyond them stays on the writer.
+	assert.Equal(t, "reader", routed(rc, "SELECT name FROM t1"))
+	for i := 0; i <= routedSessionsMax; i++ {
+		query := fmt.Sprintf("SET @v%d = 1", i)
+		wh.AddQuery(query, &sqltypes.Result{})
+		rh.AddQuery(query, &sqltypes.Result{})
+		assert.Nil(t, rc.Exec(query))
+	}
+	assert.Equal(t, 0, len(rc.sessions))
+	assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	rc.Close()
+
+	// The connections closed for the session state are counted out of the pools.
+	stats := router.Replicas()[0].pool.Stats()
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, stats.Idle, stats.Open)
+	stats = router.writer.Stats()
+	assert.Equal(t, 0, stats.InUse)
+	assert.Equal(t, stats.Idle, stats.Open)
+
+	// The writer serves the reads without the readers.
+	{
+		router, err := NewRouter(fmt.Sprintf("mock:mock@tcp(%s)/", writer.Addr()), nil, 2)
+		assert.Nil(t, err)
+		defer router.Close()
+		rc := router.Conn()
+		defer rc.Close()
+		assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	}
+
+	// The writer serves the reads if the reader is down.
+	{
+		router, err := NewRouter(fmt.Sprintf("mock:mock@tcp(%s)/", writer.Addr()), []string{"mock:mock@tcp(127.0.0.1:1)/"}, 2)
+		assert.Nil(t, err)
+		defer router.Close()
+		rc := router.Conn()
+		defer rc.Close()
+		assert.Equal(t, "writer", routed(rc, "SELECT name FROM t1"))
+	}
+}
+
+func TestBalancers(t *testing.T) {
+	replicas := []*Replica{
+		{dsn: "r1", healthy: true, active: 3, latency: 10 * time.Millisecond},
+		{dsn: "r2", healthy: true, active: 1, latency: 1000 * time.Millisecond},
+		{dsn: "r3", healthy: true, active: 2},
+	}
+
+	rr := &RoundRobinBalancer{}
+	for i := 0; i < 6; i++ {
+		assert.Equal(t, replicas[i%3], rr.Pick(replicas))
+	}
+
+	lc := &LeastConnBalancer{}
+	assert.Equal(t, "r2", lc.Pick(replicas).DSN())
+
+	// The r3 not checked yet weighs as the fastest r1.
+	lb := NewLatencyBalancer()
+	picks := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		picks[lb.Pick(replicas).DSN()]++
+	}
+	assert.Truef(t, picks["r1"] > picks["r2"]*10, "%v", picks)
+	assert.Truef(t, picks["r3"] > picks["r2"]*10, "%v", picks)
+}
+
+func TestRouterHealthCheck(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	status := func(column string, lag sqltypes.Value) *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "Replica_IO_State", Type: querypb.Type_VARCHAR}, {Name: column, Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("Waiting for source"), lag}},
+		}
+	}
+	name := func(name string) *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "name", Type: querypb.Type_VARCHAR}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name))}},
+		}
+	}
+
+	var addrs []string
+	var handlers []*TestHandler
+	for _, n := range []string{"writer", "r1", "r2", "r3"} {
+		th := NewTestHandler(log)
+		svr, err := MockMysqlServer(log, th)
+		assert.Nil(t, err)
+		defer svr.Close()
+		th.AddQuery("SELECT name FROM t1", name(n))
+		addrs = append(addrs, fmt.Sprintf("mock:mock@tcp(%s)/", svr.Addr()))
+		handlers = append(handlers, th)
+	}
+	// The writer is not a replica, the r1 is fresh, the r2 lags and the r3 has the replication stopped.
+	handlers[0].AddQuery("SHOW REPLICA STATUS", &sqltypes.Result{Fields: status("Seconds_Behind_Source", sqltypes.NULL).Fields})
+	handlers[1].AddQuery("SHOW REPLICA STATUS", status("Seconds_Behind_Source", sqltypes.NewInt64(1)))
+	handlers[2].AddQuery("SHOW REPLICA STATUS", status("Seconds_Behind_Source", sqltypes.NewInt64(100)))
+	handlers[3].AddQueryError("SHOW REPLICA STATUS", sqldb.NewSQLError(sqldb.ER_PARSE_ERROR, "%s near '%-.80s' at line %d", "You have an error in your SQL syntax", "REPLICA STATUS", 1))
+	handlers[3].AddQuery("SHOW SLAVE STATUS", status("Seconds_Behind_Master", sqltypes.NULL))
+
+	router, err := NewRouter(addrs[0], addrs[1:], 2)
+	assert.Nil(t, err)
+	defer router.Close()
+	router.SetBalancer(&LeastConnBalancer{})
+
+	// All the replicas are healthy before the checks.
+	var conns []*RoutedConn
+	got := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		rc := router.Conn()
+		qr, err := rc.FetchAll("SELECT name FROM t1", -1)
+		assert.Nil(t, err)
+		got[qr.Rows[0][0].String()] = true
+		conns = append(conns, rc)
+	}
+	assert.Equal(t, map[string]bool{"r1": true, "r2": true, "r3": true}, got)
+	for _, replica := range router.Replicas() {
+		assert.Equal(t, int64(1), replica.Active())
+	}
+	for _, rc := range conns {
+		rc.Close()
+	}
+	assert.Equal(t, int64(0), router.Replicas()[0].Active())
+
+	assert.NotNil(t, router.CheckReplicas(10*time.Second))
+	replicas := router.Replicas()
+	assert.True(t, replicas[0].Healthy())
+	assert.Equal(t, time.Second, replicas[0].Lag())
+	assert.True(t, replicas[0].Latency() > 0)
+	assert.False(t, replicas[1].Healthy())
+	assert.Equal(t, 100*time.Second, replicas[1].Lag())
+	assert.False(t, replicas[2].Healthy())
+	for i := 0; i < 3; i++ {
+		rc := router.Conn()
+		qr, err := rc.FetchAll("SELECT name FROM t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "r1", qr.Rows[0][0].String())
+		rc.Close()
+	}
+
+	// The lag isn't checked without the max lag.
+	assert.NotNil(t, router.CheckReplicas(0))
+	assert.True(t, replicas[1].Healthy())
+	assert.False(t, replicas[2].Healthy())
+
+	// The writer serves the reads if all the replicas are unhealthy.
+	handlers[1].AddQuery("SHOW REPLICA STATUS", status("Seconds_Behind_Source", sqltypes.NULL))
+	handlers[2].AddQuery("SHOW REPLICA STATUS", status("Seconds_Behind_Source", sqltypes.NULL))
+	router.StartHealthCheck(10*time.Millisecond, 0)
+	for i := 0; i < 100 && (replicas[0].Healthy() || replicas[1].Healthy()); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	rc := router.Conn()
+	defer rc.Close()
+	qr, err := rc.FetchAll("SELECT name FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "writer", qr.Rows[0][0].String())
+
+	// The writer is not a replica.
+	writer, err := newReplica(addrs[0], 1)
+	assert.Nil(t, err)
+	defer writer.pool.Close()
+	assert.Nil(t, writer.check(time.Second))
+	assert.True(t, writer.Healthy())
+}
@@ -21,6 +21,11 @@ import (
 
 var _ Rows = &TextRows{}
 
+// statusHolder tracks the server status flags and the warning count from the resultset terminator.
+type statusHolder interface {
+	setStatus(status uint16, warnings uint16)
+}
+
 // Rows presents row cursor interface.
 type Rows interface {
 	Next() bool
@@ -29,9 +34,16 @@ type Rows interface {
 	Bytes() int
 	RowsAffected() uint64
 	LastInsertID() uint64
+
+	// Info and SessionStateChanges are the ones of the OK packet of the statement without the resultset.
+	Info() string
+	SessionStateChanges() []sqltypes.SessionStateChange
 	LastError() error
 	Fields() []*querypb.Field
 	RowValues() ([]sqltypes.Value, error)
+
+	// ScanStruct scans the current row into the struct the dst points to instead of the RowValues, see ScanRow.
+	ScanStruct(dst interface{}) error
 }
 
 type TextRows struct {
@@ -42,29 +54,89 @@ type TextRows struct {
 	bytes        int
 	rowsAffected uint64
 	insertID     uint64
+	info         string
+	stateChanges []sqltypes.SessionStateChange
 	buffer       *common.Buffer
 	fields       []*querypb.Field
+
+	// next reads the packets of the rows, lazy if they're read into the buffer reused by the connection
+	// and the values of the RowValues are the reused ones, see ClientConfig.LazyRows.
+	next   func() ([]byte, error)
+	lazy   bool
+	values []sqltypes.Value
 }
 
 func NewTextRows(c Conn) *TextRows {
 	return &TextRows{
 		c:      c,
 		buffer: common.NewBuffer(8),
+		next:   c.NextPacket,
+	}
+}
+
+// isLazy checks whether the values of the rows are only valid until the next Next.
+func isLazy(rows Rows) bool {
+	r, ok := rows.(*TextRows)
+	return ok && r.lazy
+}
+
+// The slabs of the rowArena.
+const (
+	arenaRows  = 64
+	arenaBytes = 16 * 1024
+)
+
+// rowArena copies the lazy rows into the slabs shared by the rows instead of the allocations per row,
+// a retained row keeps its slabs alive.
+type rowArena struct {
+	values []sqltypes.Value
+	bytes  []byte
+}
+
+func (a *rowArena) copy(row []sqltypes.Value) []sqltypes.Value {
+	if len(a.values) < len(row) {
+		a.values = make([]sqltypes.Value, len(row)*arenaRows)
+	}
+	ret := a.values[:len(row):len(row)]
+	a.values = a.values[len(row):]
+
+	size := 0
+	for _, v := range row {
+		size += v.Len()
+	}
+	if cap(a.bytes)-len(a.bytes) < size {
+		n := arenaBytes
+		if size > n {
+			n = size
+		}
+		a.bytes = make([]byte, 0, n)
 	}
+	for i, v := range row {
+		if v.IsNull() {
+			ret[i] = sqltypes.NULL
+			continue
+		}
+		start := len(a.bytes)
+		a.bytes = append(a.bytes, v.Raw()...)
+		ret[i] = sqltypes.MakeTrusted(v.Type(), a.bytes[start:len(a.bytes):len(a.bytes)])
+	}
+	return ret
 }
 
 // http://dev.mysql.com/doc/internals/en/com-query-response.html#packet-ProtocolText::ResultsetRow
 func (r *TextRows) Next() bool {
+	if r.end {
+		return false
+	}
+
+	// The ERR packet ends the resultset, the connection is still in sync.
+	var serverErr bool
 	defer func() {
-		if r.err != nil {
+		if r.err != nil && !serverErr {
 			r.c.Cleanup()
 		}
 	}()
 
-	if r.end {
-		return false
-	}
-
 	// if fields count is 0
 	// the packet is OK-Packet without Resultset.
 	if len(r.fields) == 0 {
@@ -72,7 +144,8 @@ func (r *TextRows) Next() bool {
 		return false
 	}
 
-	if r.data, r.err = r.c.NextPacket(); r.err != nil {
+	if r.data, r.err = r.next(); r.err != nil {
+		r.err = readError(r.err, "during query")
 		r.end = true
 		return false
 	}
@@ -84,11 +157,20 @@ func (r *TextRows) Next() bool {
 		// - an OK packet with an EOF header if
 		// sqldb.CLIENT_DEPRECATE_EOF is set.
 		r.end = true
+		var eof *proto.EOF
+		if eof, r.err = proto.UnPackEOF(r.data); r.err != nil {
+			r.err = malformedError(r.err)
+			return false
+		}
+		if h, ok := r.c.(statusHolder); ok {
+			h.setStatus(eof.StatusFlags, eof.Warnings)
+		}
 		return false
 
 	case proto.ERR_PACKET:
 		r.err = proto.UnPackERR(r.data)
 		r.end = true
+		serverErr = true
 		return false
 	}
 	r.buffer.Reset(r.data)
@@ -103,6 +185,7 @@ func (r *TextRows) Close() error {
 }
 
 // https://dev.mysql.com/doc/internals/en/com-query-response.html#packet-ProtocolText::ResultsetRow
+// The values of the lazy rows and their slice are overwritten by the next row.
 func (r *TextRows) RowValues() ([]sqltypes.Value, error) {
 	if r.fields == nil {
 		return nil, errors.New("rows.fields is NIL")
@@ -110,12 +193,23 @@ func (r *TextRows) RowValues() ([]sqltypes.Value, error) {
 
 	empty := true
 	colNumber := len(r.fields)
-	result := make([]sqltypes.Value, colNumber)
+	var result []sqltypes.Value
+	if r.lazy {
+		if cap(r.values) < colNumber {
+			r.values = make([]sqltypes.Value, colNumber)
+		}
+		result = r.values[:colNumber]
+		for i := range result {
+			result[i] = sqltypes.NULL
+		}
+	} else {
+		result = make([]sqltypes.Value, colNumber)
+	}
 	for i := 0; i < colNumber; i++ {
 		v, err := r.buffer.ReadLenEncodeBytes()
 		if err != nil {
 			r.c.Cleanup()
-			return nil, err
+			return nil, malformedError(err)
 		}
 
 		if v != nil {
@@ -152,6 +246,41 @@ func (r *TextRows) LastInsertID() uint64 {
 	return r.insertID
 }
 
+func (r *TextRows) Info() string {
+	return r.info
+}
+
+func (r *TextRows) SessionStateChanges() []sqltypes.SessionStateChange {
+	return r.stateChanges
+}
+
 func (r *TextRows) LastError() error {
 	return r.err
 }
+
+// ExportRows streams the rows of the cursor to the encoder like the sqltypes.CSVEncoder and flushes it,
+// the rows are drained and closed even if the encoder fails.
+func ExportRows(rows Rows, enc sqltypes.RowEncoder) error {
+	defer rows.Close()
+	fields := rows.Fields()
+	if err := enc.WriteFields(fields); err != nil {
+		return err
+	}
+	for rows.Next() {
+		row, err := rows.RowValues()
+		if err != nil {
+			return err
+		}
+		// The row of all NULLs is nil.
+		if row == nil {
+			row = make([]sqltypes.Value, len(fields))
+		}
+		if err := enc.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.LastError(); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
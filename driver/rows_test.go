@@ -10,8 +10,15 @@
 package driver
 
 import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
 	"testing"
 
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
 	"github.com/stretchr/testify/assert"
 
 	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
@@ -88,3 +95,185 @@ func TestRows(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestExportRows(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 32))
+	b.AppendRow(1, "a,b")
+	b.AppendRow(2, nil)
+	th.AddQuery("SELECT * FROM t", b.Result())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var buf bytes.Buffer
+	rows, err := client.Query("SELECT * FROM t")
+	assert.Nil(t, err)
+	enc := sqltypes.NewCSVEncoder(&buf)
+	enc.Header = true
+	assert.Nil(t, ExportRows(rows, enc))
+	assert.Equal(t, "id,name\r\n1,\"a,b\"\r\n2,\\N\r\n", buf.String())
+
+	buf.Reset()
+	rows, err = client.Query("SELECT * FROM t")
+	assert.Nil(t, err)
+	assert.Nil(t, ExportRows(rows, sqltypes.NewJSONEncoder(&buf)))
+	assert.Equal(t, `{"id":1,"name":"a,b"}`+"\n"+`{"id":2,"name":null}`+"\n", buf.String())
+
+	// The connection is usable after the export.
+	qr, err := client.FetchAll("SELECT * FROM t", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(qr.Rows))
+}
+
+func TestLazyRows(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	b := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 32))
+	b.AppendRow(1, "alice")
+	b.AppendRow(2, nil)
+	b.AppendRow(nil, nil)
+	b.AppendRow(3, "bob")
+	th.AddQuery("SELECT * FROM t", b.Result())
+
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, LazyRows: true})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The values of the cursor are reused, the copies are retained.
+	rows, err := client.Query("SELECT * FROM t")
+	assert.Nil(t, err)
+	var retained [][]sqltypes.Value
+	var first []sqltypes.Value
+	for rows.Next() {
+		row, err := rows.RowValues()
+		assert.Nil(t, err)
+		if row == nil {
+			retained = append(retained, nil)
+			continue
+		}
+		if first == nil {
+			first = row
+		}
+		assert.True(t, &first[0] == &row[0])
+		retained = append(retained, sqltypes.Row(row).DeepCopy())
+	}
+	assert.Nil(t, rows.LastError())
+	assert.Equal(t, [][]sqltypes.Value{
+		{sqltypes.NewInt64(1), sqltypes.NewVarChar("alice")},
+		{sqltypes.NewInt64(2), sqltypes.NULL},
+		nil,
+		{sqltypes.NewInt64(3), sqltypes.NewVarChar("bob")},
+	}, retained)
+
+	// The FetchAll copies the rows, they outlive the next query.
+	qr, err := client.FetchAll("SELECT * FROM t", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("SELECT * FROM t", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]sqltypes.Value{
+		{sqltypes.NewInt64(1), sqltypes.NewVarChar("alice")},
+		{sqltypes.NewInt64(2), sqltypes.NULL},
+		{sqltypes.NewInt64(3), sqltypes.NewVarChar("bob")},
+	}, qr.Rows)
+}
+
+// replayConn replays the response to every command written, the benchmarks decode it without a server.
+type replayConn struct {
+	*packet.MockConn
+	response []byte
+	pos      int
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if c.pos == len(c.response) {
+		return 0, io.EOF
+	}
+	n := copy(b, c.response[c.pos:])
+	c.pos += n
+	return n, nil
+}
+
+func (c *replayConn) Write(b []byte) (int, error) {
+	c.pos = 0
+	return len(b), nil
+}
+
+// replayClient returns the client of the connection replaying the wire response of the result.
+func replayClient(tb testing.TB, result *sqltypes.Result, lazy bool) *conn {
+	// The session reads the command first, the response starts at the sequence 1.
+	wire := packet.NewMockConn()
+	session := newSession(xlog.NewStdLog(xlog.Level(xlog.PANIC)), 1, wire)
+	wire.Write([]byte{0x01, 0x00, 0x00, 0x00, sqldb.COM_QUERY})
+	if _, err := session.packets.Next(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := session.writeResult(result); err != nil {
+		tb.Fatal(err)
+	}
+
+	greeting := proto.NewGreeting(1)
+	greeting.Capability = session.capabilities()
+	rc := &replayConn{MockConn: packet.NewMockConn(), response: append([]byte(nil), wire.Datas()...)}
+	return &conn{
+		netConn:  rc,
+		greeting: greeting,
+		packets:  packet.NewPackets(rc),
+		config:   &ClientConfig{LazyRows: lazy},
+	}
+}
+
+// benchmarkRows decodes the 1000 rows of 4 columns per op, the allocations are reported per row too.
+func benchmarkRows(b *testing.B, lazy bool, fetchAll bool) {
+	const rowCount = 1000
+	rb := sqltypes.NewResultBuilder(sqltypes.NewInt64Field("id"), sqltypes.NewVarCharField("name", 0, 32),
+		sqltypes.NewVarCharField("email", 0, 64), sqltypes.NewInt64Field("age"))
+	for i := 0; i < rowCount; i++ {
+		rb.AppendRow(i, fmt.Sprintf("user%d", i), fmt.Sprintf("user%d@example.org", i), i%100)
+	}
+	client := replayClient(b, rb.Result(), lazy)
+
+	var before, after runtime.MemStats
+	b.ReportAllocs()
+	b.ResetTimer()
+	runtime.ReadMemStats(&before)
+	for i := 0; i < b.N; i++ {
+		if fetchAll {
+			qr, err := client.FetchAll("SELECT * FROM users", -1)
+			if err != nil || len(qr.Rows) != rowCount {
+				b.Fatalf("rows:%v, err:%v", len(qr.Rows), err)
+			}
+			continue
+		}
+		rows, err := client.Query("SELECT * FROM users")
+		if err != nil {
+			b.Fatal(err)
+		}
+		for rows.Next() {
+			if _, err := rows.RowValues(); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := rows.LastError(); err != nil {
+			b.Fatal(err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	b.StopTimer()
+	b.ReportMetric(float64(after.Mallocs-before.Mallocs)/float64(b.N*rowCount), "allocs/row")
+}
+
+func BenchmarkQueryRows(b *testing.B)        { benchmarkRows(b, false, false) }
+func BenchmarkQueryRowsLazy(b *testing.B)    { benchmarkRows(b, true, false) }
+func BenchmarkFetchAllRows(b *testing.B)     { benchmarkRows(b, false, true) }
+func BenchmarkFetchAllRowsLazy(b *testing.B) { benchmarkRows(b, true, true) }
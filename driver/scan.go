@@ -0,0 +1,313 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+var (
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType    = reflect.TypeOf(time.Time{})
+
+	// structColumns caches the columns of the struct types.
+	structColumns sync.Map
+)
+
+// structColumnsOf returns the field indexes of the struct by the lowercased column names.
+// The column is the `mysql:"name"` tag, the snake case or the lowercased field name,
+// the `mysql:"-"` is skipped and the embedded structs are flattened.
+func structColumnsOf(typ reflect.Type) map[string][]int {
+	if cols, ok := structColumns.Load(typ); ok {
+		return cols.(map[string][]int)
+	}
+	cols := make(map[string][]int)
+	var walk func(typ reflect.Type, index []int)
+	walk = func(typ reflect.Type, index []int) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			idx := append(append([]int(nil), index...), i)
+			tag := f.Tag.Get("mysql")
+			if tag == "-" {
+				continue
+			}
+			if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct && !reflect.PtrTo(f.Type).Implements(scannerType) && f.Type != timeType {
+				walk(f.Type, idx)
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+			if tag != "" {
+				cols[strings.ToLower(tag)] = idx
+				continue
+			}
+			for _, name := range []string{strings.ToLower(f.Name), snakeCase(f.Name)} {
+				if _, ok := cols[name]; !ok {
+					cols[name] = idx
+				}
+			}
+		}
+	}
+	walk(typ, nil)
+	structColumns.Store(typ, cols)
+	return cols
+}
+
+// snakeCase returns the snake case of the field name like user_id of the UserID.
+func snakeCase(name string) string {
+	var buf strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// ScanRow scans the row into the struct the dst points to, the columns without the field are skipped.
+func ScanRow(fields []*querypb.Field, row []sqltypes.Value, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("driver.scan.dst[%T].must.be.a.struct.pointer", dst)
+	}
+	return scanStruct(fields, row, v.Elem())
+}
+
+func scanStruct(fields []*querypb.Field, row []sqltypes.Value, dst reflect.Value) error {
+	if len(fields) != len(row) {
+		return fmt.Errorf("driver.scan.fields[%d].values[%d].mismatch", len(fields), len(row))
+	}
+	cols := structColumnsOf(dst.Type())
+	for i, field := range fields {
+		index, ok := cols[strings.ToLower(field.Name)]
+		if !ok {
+			continue
+		}
+		if err := scanValue(row[i], fieldByIndex(dst, index)); err != nil {
+			return fmt.Errorf("driver.scan.column[%s]:%v", field.Name, err)
+		}
+	}
+	return nil
+}
+
+// fieldByIndex returns the field of the index, the nil embedded pointers are allocated.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// ScanResult scans the rows of the result into the slice of the structs or the struct pointers the dst points to.
+func ScanResult(qr *sqltypes.Result, dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("driver.scan.dst[%T].must.be.a.slice.pointer", dst)
+	}
+	slice := v.Elem()
+	elem := slice.Type().Elem()
+	ptr := elem.Kind() == reflect.Ptr
+	if ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("driver.scan.dst[%T].must.be.a.slice.pointer", dst)
+	}
+	out := reflect.MakeSlice(slice.Type(), 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		item := reflect.New(elem)
+		if err := scanStruct(qr.Fields, row, item.Elem()); err != nil {
+			return err
+		}
+		if !ptr {
+			item = item.Elem()
+		}
+		out = reflect.Append(out, item)
+	}
+	slice.Set(out)
+	return nil
+}
+
+// scanValue converts the value to the dst:
+//   - the NULL sets the pointers and the []byte to nil and the sql.Scanner like the sql.NullString to invalid,
+//     it can't be scanned to the others.
+//   - the sql.Scanner scans the int64, uint64, float64, time.Time of the temporals in UTC or the []byte.
+//   - the strings, the []byte copied, the numbers, the bool and the time.Time in UTC are converted by the
+//     coercion of MySQL, the truncated strings and the values overflowing the dst are errors.
+func scanValue(val sqltypes.Value, dst reflect.Value) error {
+	if dst.Kind() == reflect.Ptr {
+		if val.IsNull() {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return scanValue(val, dst.Elem())
+	}
+	if dst.CanAddr() && dst.Addr().Type().Implements(scannerType) {
+		src, err := scannerSource(val)
+		if err != nil {
+			return err
+		}
+		return dst.Addr().Interface().(sql.Scanner).Scan(src)
+	}
+	if val.IsNull() {
+		if dst.Kind() == reflect.Slice {
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		return fmt.Errorf("null.into[%v]", dst.Type())
+	}
+
+	raw := val.Raw()
+	switch dst.Kind() {
+	case reflect.String:
+		dst.SetString(string(raw))
+		return nil
+	case reflect.Slice:
+		if dst.Type().Elem().Kind() == reflect.Uint8 {
+			dst.SetBytes(append([]byte(nil), raw...))
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if dst.Type() == reflect.TypeOf(time.Duration(0)) {
+			break
+		}
+		n, err := val.ToInt64()
+		if err != nil {
+			return err
+		}
+		if dst.OverflowInt(n) {
+			return fmt.Errorf("value[%v].overflows[%v]", val, dst.Type())
+		}
+		dst.SetInt(n)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := val.ToUint64()
+		if err != nil {
+			return err
+		}
+		if dst.OverflowUint(n) {
+			return fmt.Errorf("value[%v].overflows[%v]", val, dst.Type())
+		}
+		dst.SetUint(n)
+		return nil
+	case reflect.Float32, reflect.Float64:
+		f, err := val.ToFloat64()
+		if err != nil {
+			return err
+		}
+		if dst.OverflowFloat(f) {
+			return fmt.Errorf("value[%v].overflows[%v]", val, dst.Type())
+		}
+		dst.SetFloat(f)
+		return nil
+	case reflect.Bool:
+		b, err := val.ToBool()
+		if err != nil {
+			return err
+		}
+		dst.SetBool(b)
+		return nil
+	case reflect.Struct:
+		if dst.Type() == timeType {
+			t, err := val.ToTime(time.UTC)
+			if err != nil {
+				return err
+			}
+			dst.Set(reflect.ValueOf(t))
+			return nil
+		}
+	}
+	return fmt.Errorf("type[%v].into[%v].unsupported", val.Type(), dst.Type())
+}
+
+// scannerSource returns the value the sql.Scanner scans.
+func scannerSource(val sqltypes.Value) (interface{}, error) {
+	switch val.Type() {
+	case sqltypes.Date, sqltypes.Datetime, sqltypes.Timestamp:
+		return val.ToTime(time.UTC)
+	case sqltypes.Decimal:
+		return append([]byte(nil), val.Raw()...), nil
+	}
+	if val.IsIntegral() || val.IsFloat() || val.IsNull() {
+		return val.ToNative(), nil
+	}
+	return append([]byte(nil), val.Raw()...), nil
+}
+
+// ScanStruct scans the current row into the struct the dst points to instead of the RowValues, see ScanRow.
+func (r *TextRows) ScanStruct(dst interface{}) error {
+	return scanRows(r, dst)
+}
+
+// ScanStruct scans the current row into the struct the dst points to instead of the RowValues, see ScanRow.
+func (r *BinaryRows) ScanStruct(dst interface{}) error {
+	return scanRows(r, dst)
+}
+
+func scanRows(r Rows, dst interface{}) error {
+	row, err := r.RowValues()
+	if err != nil {
+		return err
+	}
+	fields := r.Fields()
+	// The row of all NULLs is nil.
+	if row == nil {
+		row = make([]sqltypes.Value, len(fields))
+	}
+	return ScanRow(fields, row, dst)
+}
+
+// FetchAllInto fetchs all results into the slice of the structs the dst points to, see ScanResult.
+func (c *conn) FetchAllInto(query string, dst interface{}) error {
+	qr, err := c.FetchAll(query, -1)
+	if err != nil {
+		return err
+	}
+	return ScanResult(qr, dst)
+}
+
+// FetchAllInto fetchs all results in the transaction into the slice of the structs the dst points to.
+func (tx *Tx) FetchAllInto(query string, dst interface{}) error {
+	return tx.c.FetchAllInto(query, dst)
+}
+
+// FetchAllInto fetchs all results of the routed connection into the slice of the structs the dst points to.
+func (rc *RoutedConn) FetchAllInto(query string, dst interface{}) error {
+	qr, err := rc.FetchAll(query, -1)
+	if err != nil {
+		return err
+	}
+	return ScanResult(qr, dst)
+}
@@ -0,0 +1,146 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+type scanBase struct {
+	ID int64
+}
+
+type scanUser struct {
+	scanBase
+	UserName  string
+	Email     *string
+	Nick      sql.NullString
+	Score     float64
+	Age       uint8 `mysql:"years"`
+	Active    bool
+	CreatedAt time.Time
+	Avatar    []byte
+	Ignored   string `mysql:"-"`
+	hidden    string
+}
+
+func newScanResult() *sqltypes.Result {
+	b := sqltypes.NewResultBuilder(
+		sqltypes.NewInt64Field("id"),
+		sqltypes.NewVarCharField("user_name", 0, 32),
+		sqltypes.NewVarCharField("email", 0, 32),
+		sqltypes.NewVarCharField("nick", 0, 32),
+		sqltypes.NewFloat64Field("score"),
+		sqltypes.NewInt32Field("years"),
+		sqltypes.NewInt32Field("active"),
+		sqltypes.NewDatetimeField("created_at", 0),
+		sqltypes.NewBlobField("avatar"),
+		sqltypes.NewVarCharField("ignored", 0, 32),
+		sqltypes.NewVarCharField("extra", 0, 32),
+	)
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC)
+	b.AppendRow(1, "ann", "ann@xelabs.org", "a", 1.5, 30, 1, created, []byte{0x01}, "x", "y")
+	b.AppendRow(2, "bob", nil, nil, 0, 40, 0, created, nil, nil, nil)
+	return b.Result()
+}
+
+func TestScanResult(t *testing.T) {
+	var users []scanUser
+	assert.Nil(t, ScanResult(newScanResult(), &users))
+	assert.Equal(t, 2, len(users))
+	email := "ann@xelabs.org"
+	assert.Equal(t, scanUser{
+		scanBase:  scanBase{ID: 1},
+		UserName:  "ann",
+		Email:     &email,
+		Nick:      sql.NullString{String: "a", Valid: true},
+		Score:     1.5,
+		Age:       30,
+		Active:    true,
+		CreatedAt: time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC),
+		Avatar:    []byte{0x01},
+	}, users[0])
+	assert.Nil(t, users[1].Email)
+	assert.False(t, users[1].Nick.Valid)
+	assert.Nil(t, users[1].Avatar)
+
+	var ptrs []*scanUser
+	assert.Nil(t, ScanResult(newScanResult(), &ptrs))
+	assert.Equal(t, "bob", ptrs[1].UserName)
+
+	// The errors.
+	assert.EqualError(t, ScanResult(newScanResult(), users), "driver.scan.dst[[]driver.scanUser].must.be.a.slice.pointer")
+	assert.EqualError(t, ScanResult(newScanResult(), &[]int{}), "driver.scan.dst[*[]int].must.be.a.slice.pointer")
+	var small []struct{ Years int8 }
+	qr := newScanResult()
+	qr.Rows[0][5] = sqltypes.NewInt32(300)
+	assert.EqualError(t, ScanResult(qr, &small), "driver.scan.column[years]:value[300].overflows[int8]")
+	var strict []struct{ Email string }
+	assert.EqualError(t, ScanResult(newScanResult(), &strict), "driver.scan.column[email]:null.into[string]")
+	var u scanUser
+	assert.EqualError(t, ScanRow(nil, nil, u), "driver.scan.dst[driver.scanUser].must.be.a.struct.pointer")
+}
+
+func TestSnakeCase(t *testing.T) {
+	assert.Equal(t, "user_id", snakeCase("UserID"))
+	assert.Equal(t, "http_server", snakeCase("HTTPServer"))
+	assert.Equal(t, "created_at", snakeCase("CreatedAt"))
+	assert.Equal(t, "id", snakeCase("ID"))
+}
+
+func TestClientFetchAllInto(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT * FROM users", newScanResult())
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var users []scanUser
+	assert.Nil(t, client.FetchAllInto("SELECT * FROM users", &users))
+	assert.Equal(t, 2, len(users))
+	assert.Equal(t, "ann@xelabs.org", *users[0].Email)
+	assert.Equal(t, time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), users[1].CreatedAt)
+
+	rows, err := client.Query("SELECT * FROM users")
+	assert.Nil(t, err)
+	var names []string
+	for rows.Next() {
+		var u scanUser
+		assert.Nil(t, rows.ScanStruct(&u))
+		names = append(names, u.UserName)
+	}
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, []string{"ann", "bob"}, names)
+
+	// The binary protocol rows.
+	stmt, err := client.Prepare("SELECT * FROM users")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	brows, err := stmt.Query()
+	assert.Nil(t, err)
+	assert.True(t, brows.Next())
+	var u scanUser
+	assert.Nil(t, brows.ScanStruct(&u))
+	assert.Equal(t, int64(1), u.ID)
+	assert.Equal(t, 1.5, u.Score)
+	brows.Close()
+}
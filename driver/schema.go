@@ -0,0 +1,160 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+)
+
+// Table is a row of SHOW FULL TABLES.
+type Table struct {
+	Name string
+	// Type is 'BASE TABLE', 'VIEW' or 'SYSTEM VIEW'.
+	Type string
+}
+
+// Column is a row of SHOW FULL COLUMNS.
+type Column struct {
+	Name      string
+	Type      string
+	Collation string
+	Nullable  bool
+	// Key is 'PRI', 'UNI', 'MUL' or empty.
+	Key string
+	// Default is nil if the column has no default value.
+	Default *string
+	Extra   string
+	Comment string
+}
+
+// Index is the rows of SHOW INDEX grouped by the key name.
+type Index struct {
+	Name   string
+	Unique bool
+	// Columns are ordered by Seq_in_index.
+	Columns []string
+	Type    string
+	Comment string
+}
+
+// Databases returns the database names.
+func (c *conn) Databases() ([]string, error) {
+	qr, err := c.FetchAll("SHOW DATABASES", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	dbs := make([]string, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		dbs = append(dbs, row[0].String())
+	}
+	return dbs, nil
+}
+
+// Tables returns the tables and views of the schema.
+func (c *conn) Tables(schema string) ([]*Table, error) {
+	qr, err := c.FetchAll(fmt.Sprintf("SHOW FULL TABLES FROM %s", escape.QuoteIdentifier(schema)), -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Fields) < 2 {
+		return nil, fmt.Errorf("driver.schema.tables.unexpected.fields.count[%d]", len(qr.Fields))
+	}
+
+	tables := make([]*Table, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		tables = append(tables, &Table{
+			Name: row[0].String(),
+			Type: row[1].String(),
+		})
+	}
+	return tables, nil
+}
+
+// Columns returns the columns of the table.
+func (c *conn) Columns(schema string, table string) ([]*Column, error) {
+	qr, err := c.FetchAll(fmt.Sprintf("SHOW FULL COLUMNS FROM %s FROM %s", escape.QuoteIdentifier(table), escape.QuoteIdentifier(schema)), -1)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := schemaFields(qr, "field", "type", "collation", "null", "key", "default", "extra", "comment")
+	if err != nil {
+		return nil, err
+	}
+
+	columns := make([]*Column, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		col := &Column{
+			Name:      row[fields["field"]].String(),
+			Type:      row[fields["type"]].String(),
+			Collation: row[fields["collation"]].String(),
+			Nullable:  strings.EqualFold(row[fields["null"]].String(), "YES"),
+			Key:       row[fields["key"]].String(),
+			Extra:     row[fields["extra"]].String(),
+			Comment:   row[fields["comment"]].String(),
+		}
+		if def := row[fields["default"]]; !def.IsNull() {
+			s := def.String()
+			col.Default = &s
+		}
+		columns = append(columns, col)
+	}
+	return columns, nil
+}
+
+// Indexes returns the indexes of the table, in the order the server reports them.
+func (c *conn) Indexes(schema string, table string) ([]*Index, error) {
+	qr, err := c.FetchAll(fmt.Sprintf("SHOW INDEX FROM %s FROM %s", escape.QuoteIdentifier(table), escape.QuoteIdentifier(schema)), -1)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := schemaFields(qr, "non_unique", "key_name", "column_name", "index_type", "index_comment")
+	if err != nil {
+		return nil, err
+	}
+
+	var indexes []*Index
+	byName := make(map[string]*Index)
+	for _, row := range qr.Rows {
+		name := row[fields["key_name"]].String()
+		idx, ok := byName[name]
+		if !ok {
+			idx = &Index{
+				Name:    name,
+				Unique:  row[fields["non_unique"]].String() == "0",
+				Type:    row[fields["index_type"]].String(),
+				Comment: row[fields["index_comment"]].String(),
+			}
+			byName[name] = idx
+			indexes = append(indexes, idx)
+		}
+		// The rows are ordered by Seq_in_index within the key.
+		idx.Columns = append(idx.Columns, row[fields["column_name"]].String())
+	}
+	return indexes, nil
+}
+
+// schemaFields returns the positions of the wanted fields, the names are case insensitive.
+func schemaFields(qr *sqltypes.Result, names ...string) (map[string]int, error) {
+	fields := make(map[string]int, len(qr.Fields))
+	for i, field := range qr.Fields {
+		fields[strings.ToLower(field.Name)] = i
+	}
+	for _, name := range names {
+		if _, ok := fields[name]; !ok {
+			return nil, fmt.Errorf("driver.schema.field[%s].not.found", name)
+		}
+	}
+	return fields, nil
+}
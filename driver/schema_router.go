@@ -0,0 +1,204 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// SchemaRouter is the CommandHandler dispatching the commands of the sessions to the handlers of their current schemas,
+// the sessions without the schema or of the schemas not routed go to the default handler.
+// The USE switches the session to the handler of the new schema, the handler answers the USE itself and the schema
+// of the session is set once it succeeds. The tables qualified by the other schemas aren't routed, they're the handler's.
+// The sessions and the auths are checked by the default handler, the NewSession and the SessionClosed are called on all
+// the handlers, so the routes are set before the listener serves.
+type SchemaRouter struct {
+	def Handler
+
+	mu       sync.RWMutex
+	schemas  map[string]Handler
+	commands map[Handler]CommandHandler
+
+	// The handlers of the prepared statements by the session ids and the statement ids,
+	// a statement is executed by the handler prepared it even after the USE.
+	stmts map[uint32]map[uint32]CommandHandler
+}
+
+// NewSchemaRouter creates the router of the default handler.
+func NewSchemaRouter(def Handler) *SchemaRouter {
+	return &SchemaRouter{
+		def:      def,
+		schemas:  make(map[string]Handler),
+		commands: map[Handler]CommandHandler{def: NewCommandHandler(def)},
+		stmts:    make(map[uint32]map[uint32]CommandHandler),
+	}
+}
+
+// SetHandler routes the schema to the handler, the nil routes the schema to the default handler.
+// A handler routing many schemas is the same handler of them, it must be comparable like the pointers.
+func (r *SchemaRouter) SetHandler(schema string, handler Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if handler == nil {
+		delete(r.schemas, schema)
+		return
+	}
+	if _, ok := r.commands[handler]; !ok {
+		r.commands[handler] = NewCommandHandler(handler)
+	}
+	r.schemas[schema] = handler
+}
+
+// Handler returns the handler of the schema, the default handler if it isn't routed.
+func (r *SchemaRouter) Handler(schema string) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if h, ok := r.schemas[schema]; ok {
+		return h
+	}
+	return r.def
+}
+
+func (r *SchemaRouter) route(schema string) CommandHandler {
+	h := r.Handler(schema)
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.commands[h]
+}
+
+// handlers returns the default handler and the routed ones, every handler once.
+func (r *SchemaRouter) handlers() []CommandHandler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	handlers := []CommandHandler{r.commands[r.def]}
+	seen := map[Handler]bool{r.def: true}
+	for _, h := range r.schemas {
+		if !seen[h] {
+			seen[h] = true
+			handlers = append(handlers, r.commands[h])
+		}
+	}
+	return handlers
+}
+
+// NewSession impl.
+func (r *SchemaRouter) NewSession(session *Session) {
+	for _, h := range r.handlers() {
+		h.NewSession(session)
+	}
+}
+
+// SessionClosed impl.
+func (r *SchemaRouter) SessionClosed(session *Session) {
+	r.mu.Lock()
+	delete(r.stmts, session.ID())
+	r.mu.Unlock()
+	for _, h := range r.handlers() {
+		h.SessionClosed(session)
+	}
+}
+
+// SessionCheck impl.
+func (r *SchemaRouter) SessionCheck(session *Session) error {
+	return r.def.SessionCheck(session)
+}
+
+// AuthCheck impl.
+func (r *SchemaRouter) AuthCheck(session *Session) error {
+	return r.def.AuthCheck(session)
+}
+
+// ComInitDB impl, the db is answered by the handler of it.
+func (r *SchemaRouter) ComInitDB(session *Session, database string) error {
+	return r.route(database).ComInitDB(session, database)
+}
+
+// ComQuery impl, the USE is answered by the handler of the new schema.
+func (r *SchemaRouter) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	if sqlparser.Preview(query) == sqlparser.StmtUse {
+		if stmt, err := session.Parse(query); err == nil {
+			if use, ok := stmt.(*sqlparser.Use); ok {
+				db := use.DBName.String()
+				if err := r.route(db).ComQuery(session, query, callback); err != nil {
+					return err
+				}
+				session.SetSchema(db)
+				return nil
+			}
+		}
+	}
+	return r.route(session.Schema()).ComQuery(session, query, callback)
+}
+
+// ComPing impl.
+func (r *SchemaRouter) ComPing(session *Session) error {
+	return r.route(session.Schema()).ComPing(session)
+}
+
+// ComFieldList impl.
+func (r *SchemaRouter) ComFieldList(session *Session, table string, wildcard string) ([]*querypb.Field, error) {
+	return r.route(session.Schema()).ComFieldList(session, table, wildcard)
+}
+
+// ComProcessKill impl.
+func (r *SchemaRouter) ComProcessKill(session *Session, id uint32) error {
+	return r.route(session.Schema()).ComProcessKill(session, id)
+}
+
+// ComStmtPrepare impl.
+func (r *SchemaRouter) ComStmtPrepare(session *Session, stmt *Statement) error {
+	h := r.route(session.Schema())
+	if err := h.ComStmtPrepare(session, stmt); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stmts, ok := r.stmts[session.ID()]
+	if !ok {
+		stmts = make(map[uint32]CommandHandler)
+		r.stmts[session.ID()] = stmts
+	}
+	stmts[stmt.ID] = h
+	return nil
+}
+
+// stmtHandler returns the handler prepared the statement, the one of the current schema if it's unknown.
+func (r *SchemaRouter) stmtHandler(session *Session, stmt *Statement) CommandHandler {
+	r.mu.RLock()
+	h, ok := r.stmts[session.ID()][stmt.ID]
+	r.mu.RUnlock()
+	if ok {
+		return h
+	}
+	return r.route(session.Schema())
+}
+
+// ComStmtExecute impl.
+func (r *SchemaRouter) ComStmtExecute(session *Session, stmt *Statement, params []sqltypes.Value, callback func(*sqltypes.Result) error) error {
+	return r.stmtHandler(session, stmt).ComStmtExecute(session, stmt, params, callback)
+}
+
+// ComStmtClose impl.
+func (r *SchemaRouter) ComStmtClose(session *Session, stmt *Statement) {
+	r.stmtHandler(session, stmt).ComStmtClose(session, stmt)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.stmts[session.ID()], stmt.ID)
+}
+
+// ComOther impl.
+func (r *SchemaRouter) ComOther(session *Session, command byte, data []byte) error {
+	return r.route(session.Schema()).ComOther(session, command, data)
+}
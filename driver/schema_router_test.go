@@ -0,0 +1,81 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestSchemaRouter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	result := func(name string) *sqltypes.Result {
+		b := sqltypes.NewResultBuilder(sqltypes.NewVarCharField("engine", 0, 32))
+		b.AppendRow(name)
+		return b.Result()
+	}
+	def, db1 := NewTestHandler(log), NewTestHandler(log)
+	def.AddQuery("SELECT engine", result("default"))
+	db1.AddQuery("SELECT engine", result("db1"))
+	db1.AddQuery("SELECT engine FROM t WHERE id = 1", result("db1"))
+	db1.AddQuery("USE db1", &sqltypes.Result{})
+	def.AddQuery("USE db2", &sqltypes.Result{})
+
+	router := NewSchemaRouter(def)
+	router.SetHandler("db1", db1)
+	router.SetHandler("db3", db1)
+	assert.Equal(t, Handler(db1), router.Handler("db1"))
+	assert.Equal(t, Handler(def), router.Handler("db2"))
+	svr, err := MockMysqlServer(log, router)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	engine := func(client Conn) string {
+		qr, err := client.FetchAll("SELECT engine", -1)
+		assert.Nil(t, err)
+		return qr.Rows[0][0].String()
+	}
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Equal(t, "default", engine(client))
+
+	// The USE switches the handler.
+	assert.Nil(t, client.Exec("USE db1"))
+	assert.Equal(t, 1, db1.GetQueryCalledNum("USE db1"))
+	assert.Equal(t, "db1", engine(client))
+
+	// The statement is executed by the handler prepared it.
+	stmt, err := client.Prepare("SELECT engine FROM t WHERE id = ?")
+	assert.Nil(t, err)
+	assert.Nil(t, client.Exec("USE db2"))
+	assert.Equal(t, "default", engine(client))
+	qr, err := stmt.Execute(sqltypes.NewInt64(1))
+	assert.Nil(t, err)
+	assert.Equal(t, "db1", qr.Rows[0][0].String())
+	assert.Nil(t, stmt.Close())
+
+	// The COM_INIT_DB and the db of the handshake.
+	assert.Nil(t, client.InitDB("db3"))
+	assert.Equal(t, "db1", engine(client))
+	other, err := NewConn("mock", "mock", svr.Addr(), "db1", "")
+	assert.Nil(t, err)
+	defer other.Close()
+	assert.Equal(t, "db1", engine(other))
+
+	// The route removed.
+	router.SetHandler("db1", nil)
+	assert.Equal(t, "default", engine(other))
+}
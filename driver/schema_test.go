@@ -0,0 +1,112 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func schemaResult(names []string, rows ...[]string) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	for _, name := range names {
+		qr.Fields = append(qr.Fields, &querypb.Field{Name: name, Type: querypb.Type_VARCHAR})
+	}
+	for _, row := range rows {
+		var values []sqltypes.Value
+		for _, v := range row {
+			if v == "NULL" {
+				values = append(values, sqltypes.NULL)
+				continue
+			}
+			values = append(values, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v)))
+		}
+		qr.Rows = append(qr.Rows, values)
+	}
+	return qr
+}
+
+func TestClientSchema(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	client, err := NewConn("mock", "mock", address, "test", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Databases.
+	{
+		th.AddQuery("SHOW DATABASES", schemaResult([]string{"Database"}, []string{"mysql"}, []string{"db1"}))
+		dbs, err := client.Databases()
+		assert.Nil(t, err)
+		assert.Equal(t, []string{"mysql", "db1"}, dbs)
+	}
+
+	// Tables.
+	{
+		th.AddQuery("SHOW FULL TABLES FROM `db1`", schemaResult([]string{"Tables_in_db1", "Table_type"},
+			[]string{"t1", "BASE TABLE"},
+			[]string{"v1", "VIEW"}))
+		tables, err := client.Tables("db1")
+		assert.Nil(t, err)
+		want := []*Table{
+			{Name: "t1", Type: "BASE TABLE"},
+			{Name: "v1", Type: "VIEW"},
+		}
+		assert.Equal(t, want, tables)
+	}
+
+	// Columns.
+	{
+		th.AddQuery("SHOW FULL COLUMNS FROM `t1` FROM `db1`", schemaResult(
+			[]string{"Field", "Type", "Collation", "Null", "Key", "Default", "Extra", "Privileges", "Comment"},
+			[]string{"id", "int(11)", "NULL", "NO", "PRI", "NULL", "auto_increment", "select", ""},
+			[]string{"name", "varchar(64)", "utf8_general_ci", "YES", "", "", "", "select", "user name"}))
+		columns, err := client.Columns("db1", "t1")
+		assert.Nil(t, err)
+		empty := ""
+		want := []*Column{
+			{Name: "id", Type: "int(11)", Key: "PRI", Extra: "auto_increment"},
+			{Name: "name", Type: "varchar(64)", Collation: "utf8_general_ci", Nullable: true, Default: &empty, Comment: "user name"},
+		}
+		assert.Equal(t, want, columns)
+	}
+
+	// Indexes.
+	{
+		th.AddQuery("SHOW INDEX FROM `t1` FROM `db1`", schemaResult(
+			[]string{"Table", "Non_unique", "Key_name", "Seq_in_index", "Column_name", "Index_type", "Comment", "Index_comment"},
+			[]string{"t1", "0", "PRIMARY", "1", "id", "BTREE", "", ""},
+			[]string{"t1", "1", "idx_name", "1", "name", "BTREE", "", "by name"},
+			[]string{"t1", "1", "idx_name", "2", "id", "BTREE", "", "by name"}))
+		indexes, err := client.Indexes("db1", "t1")
+		assert.Nil(t, err)
+		want := []*Index{
+			{Name: "PRIMARY", Unique: true, Columns: []string{"id"}, Type: "BTREE"},
+			{Name: "idx_name", Columns: []string{"name", "id"}, Type: "BTREE", Comment: "by name"},
+		}
+		assert.Equal(t, want, indexes)
+	}
+
+	// Unexpected fields.
+	{
+		th.AddQuery("SHOW INDEX FROM `t2` FROM `db1`", schemaResult([]string{"Table"}))
+		_, err := client.Indexes("db1", "t2")
+		assert.NotNil(t, err)
+	}
+}
@@ -10,12 +10,22 @@
 package driver
 
 import (
+	"crypto/tls"
+	"errors"
+	"fmt"
 	"net"
 	"runtime"
 	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
 	"github.com/XeLabs/go-mysqlstack/xlog"
 
 	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
@@ -37,51 +47,407 @@ type Handler interface {
 	// Handle the cominitdb.
 	ComInitDB(session *Session, database string) error
 
-	// Handle the queries.
+	// Handle the queries, the session.Context() is canceled once the client disconnects before it returns.
 	ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error
 }
 
+// ReplicationHandler is the optional Handler to serve the replication commands,
+// the commands are answered as not implemented if the handler doesn't implement it.
+type ReplicationHandler interface {
+	// Handle the COM_REGISTER_SLAVE, the slave shows in SHOW SLAVE HOSTS until the session closed.
+	ComRegisterSlave(session *Session, slave *proto.RegisterSlave) error
+}
+
+// GreetingConfig is the handshake advertised by the Listener.
+type GreetingConfig struct {
+	// ServerVersion is the version string, many clients sniff it for the features.
+	ServerVersion string
+
+	// Charset is the default collation id.
+	Charset uint8
+
+	// Status is the initial status flags.
+	Status uint16
+
+	// CapabilityMask is the capability bits cleared from the proto.DefaultServerCapability.
+	CapabilityMask uint32
+
+	// CapabilityForce is the capability bits set, it wins over the mask.
+	CapabilityForce uint32
+
+	// MariaDBCapability is the MariaDB extended capabilities like the MARIADB_CLIENT_PROGRESS,
+	// they are sent if the CLIENT_LONG_PASSWORD(CLIENT_MYSQL) is masked.
+	MariaDBCapability uint32
+}
+
+// DefaultGreetingConfig returns the config of the proto.NewGreeting.
+func DefaultGreetingConfig() *GreetingConfig {
+	return &GreetingConfig{
+		ServerVersion: proto.DefaultServerVersion,
+		Charset:       sqldb.DefaultCollation,
+		Status:        sqldb.SERVER_STATUS_AUTOCOMMIT,
+	}
+}
+
+// Capability returns the capabilities advertised.
+func (c *GreetingConfig) Capability() uint32 {
+	return proto.DefaultServerCapability&^c.CapabilityMask | c.CapabilityForce
+}
+
+// validate checks the config, the CLIENT_PROTOCOL_41 can't be masked, the client is 4.1+ only.
+func (c *GreetingConfig) validate() error {
+	if c.Capability()&sqldb.CLIENT_PROTOCOL_41 == 0 {
+		return fmt.Errorf("driver.greeting.config.capability.mask.protocol.41")
+	}
+	if c.ServerVersion == "" {
+		return fmt.Errorf("driver.greeting.config.server.version.empty")
+	}
+	if _, ok := sqldb.LookupCollation(uint16(c.Charset)); !ok {
+		return fmt.Errorf("driver.greeting.config.unknown.charset[%d]", c.Charset)
+	}
+	return nil
+}
+
+func (c *GreetingConfig) apply(greeting *proto.Greeting) {
+	greeting.SetServerVersion(c.ServerVersion)
+	greeting.Charset = c.Charset
+	greeting.SetStatus(c.Status)
+	greeting.Capability = c.Capability()
+	greeting.MariaDBCapability = c.MariaDBCapability
+}
+
 type Listener struct {
 	// Logger.
 	log *xlog.Log
 
+	// The handshake advertised.
+	greeting *GreetingConfig
+
+	// The global system variables.
+	sysvars *SystemVariables
+
+	// The zone of the TIMESTAMP values the handler returns.
+	resultTimeZone *time.Location
+
+	// The bytes a session statement can buffer, 0 is unlimited.
+	sessionMemoryLimit int64
+
+	// The packets of the new sessions are traced from the greeting.
+	trace bool
+
+	// The TLS the sessions switch to by the SSLRequest, nil if not supported.
+	tls *tls.Config
+
+	// The timeout from the greeting to the auth OK, 0 is no timeout.
+	handshakeTimeout time.Duration
+
+	// The mis-negotiated handshake responses are rejected instead of warned.
+	strictCapabilities bool
+
+	// The minimum protocol security level of the clients.
+	handshakePolicy HandshakePolicy
+
+	// The sessions of all users or the users in the set must switch to TLS.
+	requireSecureTransport bool
+	secureTransportUsers   map[string]bool
+
+	// The client certificates the users must present.
+	x509Rules map[string]*X509Rule
+
+	// The checker of the credentials instead of the handler.
+	delegate AuthDelegate
+
+	// The users whose passwords are expired.
+	expiredUsers map[string]bool
+
+	// The throttle of the failed authentications.
+	throttle *AuthThrottle
+
+	// The workers the commands of the sessions execute on, nil executes them without limit.
+	pool *ThreadPool
+
+	// The traffic of the new sessions is captured to the pcap.
+	pcap *packet.PcapWriter
+
+	// The translator of the errors the sessions send.
+	translator ErrorTranslator
+
+	// The flush policy and the output queue bytes of the sessions.
+	flushPolicy     FlushPolicy
+	outputQueueSize int
+
+	// The status counters.
+	status *statusCounters
+
+	// The provider of the information_schema queries, nil leaves them to the handler.
+	infoSchema InfoSchemaProvider
+
+	// The live sessions by the ids, see Processlist.
+	sessions map[uint32]*Session
+
+	// The time the listener was created, see the Uptime.
+	started time.Time
+
 	address string
 
-	// Query handler.
-	handler Handler
+	// Query handler and the callbacks per command of it.
+	handler  Handler
+	commands CommandHandler
 
 	// This is the main listener socket.
 	listener net.Listener
 
+	// closed is set by the Close, the accept loop ends on it.
+	closed uint32
+
 	// Incrementing ID for connection id.
 	connectionID uint32
+
+	mu sync.RWMutex
+
+	// sessionFunctions, userVariables and systemVariables are answered at the protocol layer.
+	sessionFunctions bool
+	userVariables    bool
+	systemVariables  bool
 }
 
 // NewListener creates a new Listener.
 func NewListener(log *xlog.Log, address string, handler Handler) (*Listener, error) {
-	listener, err := net.Listen("tcp", address)
+	return NewListenerWithConfig(&ListenerConfig{Address: address, Log: log}, handler)
+}
+
+// NewListenerWithConfig creates a new Listener by the config, the config is validated first.
+func NewListenerWithConfig(cfg *ListenerConfig, handler Handler) (*Listener, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	listener, err := net.Listen("tcp", cfg.Address)
 	if err != nil {
 		return nil, err
 	}
+	return newListener(cfg, listener, handler), nil
+}
+
+// NewListenerWithListener creates a new Listener serving the socket listened on, like the one inherited from
+// the systemd or the former process, see ActivationListeners. The Address of the config is ignored.
+// The config is validated first, the socket isn't closed if it's invalid.
+func NewListenerWithListener(cfg *ListenerConfig, listener net.Listener, handler Handler) (*Listener, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	c := *cfg
+	c.Address = listener.Addr().String()
+	return newListener(&c, listener, handler), nil
+}
 
-	return &Listener{
-		log:          log,
-		address:      address,
+func newListener(cfg *ListenerConfig, listener net.Listener, handler Handler) *Listener {
+	cfg = cfg.withDefaults()
+	l := &Listener{
+		log:          cfg.Log,
+		sysvars:      NewSystemVariables(),
+		status:       &statusCounters{},
+		started:      time.Now(),
+		address:      cfg.Address,
 		handler:      handler,
+		commands:     NewCommandHandler(handler),
 		listener:     listener,
 		connectionID: 1,
-	}, nil
+	}
+	l.apply(cfg)
+	return l
+}
+
+// SetGreetingConfig sets the handshake of the coming sessions.
+// The CLIENT_PROTOCOL_41 can't be masked, the client is 4.1+ only.
+func (l *Listener) SetGreetingConfig(cfg *GreetingConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	c := *cfg
+	l.mu.Lock()
+	l.greeting = &c
+	l.mu.Unlock()
+	l.sysvars.Set("version", sqltypes.NewVarChar(c.ServerVersion))
+	return nil
+}
+
+// GreetingConfig returns a copy of the handshake config.
+func (l *Listener) GreetingConfig() *GreetingConfig {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	c := *l.greeting
+	return &c
+}
+
+// SetSessionFunctions enables answering the 'SELECT LAST_INSERT_ID(), ROW_COUNT()' of the session
+// without the handler, the values are tracked from the results the handler returned.
+// It's disabled by default.
+func (l *Listener) SetSessionFunctions(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessionFunctions = on
+}
+
+// SetUserVariables enables answering the 'SELECT @var' by the user variables tracked from the SET statements,
+// the handler doesn't see them. It's disabled by default.
+func (l *Listener) SetUserVariables(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.userVariables = on
+}
+
+// SetSystemVariables enables answering the 'SELECT @@var' and the SHOW VARIABLES by the system variables,
+// the connectors query them at connect time. It's disabled by default.
+func (l *Listener) SetSystemVariables(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.systemVariables = on
+}
+
+// GlobalVariables returns the global system variables, the sessions start with them.
+// The handler can set the variables of the real backend like version_comment.
+func (l *Listener) GlobalVariables() *SystemVariables {
+	return l.sysvars
+}
+
+// SetResultTimeZone sets the zone of the TIMESTAMP values the handler returns, like the time_zone of the backend.
+// The values are converted to the time_zone of the session before sent, nil disables the conversion.
+// It applies to the coming sessions.
+func (l *Listener) SetResultTimeZone(loc *time.Location) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.resultTimeZone = loc
+}
+
+// ResultTimeZone returns the zone of the TIMESTAMP values the handler returns, nil if not converted.
+func (l *Listener) ResultTimeZone() *time.Location {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.resultTimeZone
+}
+
+// SetSessionMemoryLimit sets the bytes a statement of the session can buffer, like the incoming packet
+// and the results being written, exceeding it kills the statement with the ER_OUT_OF_RESOURCES.
+// The 0 disables the limit, it applies to the coming sessions.
+func (l *Listener) SetSessionMemoryLimit(bytes int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sessionMemoryLimit = bytes
+}
+
+// SessionMemoryLimit returns the bytes a statement of the session can buffer, 0 if unlimited.
+func (l *Listener) SessionMemoryLimit() int64 {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.sessionMemoryLimit
+}
+
+// SetRequireSecureTransport rejects the coming sessions not switched to TLS with the ER_SECURE_TRANSPORT_REQUIRED.
+func (l *Listener) SetRequireSecureTransport(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.requireSecureTransport = on
+}
+
+// SetSecureTransportUsers sets the users whose coming sessions must switch to TLS, the users are case sensitive.
+// It replaces the former users, none clears them.
+func (l *Listener) SetSecureTransportUsers(users ...string) {
+	set := make(map[string]bool, len(users))
+	for _, user := range users {
+		set[user] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.secureTransportUsers = set
+}
+
+// requiresSecureTransport checks whether the session of the user must switch to TLS.
+func (l *Listener) requiresSecureTransport(user string) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.requireSecureTransport || l.secureTransportUsers[user]
+}
+
+// SetTrace traces the packets of the coming sessions from the greeting, see Session.SetTrace.
+func (l *Listener) SetTrace(on bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.trace = on
+}
+
+func (l *Listener) tracing() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.trace
+}
+
+// SetCapture captures the traffic of the coming sessions to the pcap, nil stops capturing them.
+func (l *Listener) SetCapture(w *packet.PcapWriter) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pcap = w
+}
+
+func (l *Listener) capture() *packet.PcapWriter {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.pcap
+}
+
+func (l *Listener) answerSystemVariables() bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.systemVariables
+}
+
+// localEval returns the evaluator of the local answered expressions, nil if none enabled.
+func (l *Listener) localEval() localEval {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var evals []localEval
+	if l.sessionFunctions {
+		evals = append(evals, evalSessionFunction)
+	}
+	if l.userVariables {
+		evals = append(evals, evalUserVar)
+	}
+	if l.systemVariables {
+		evals = append(evals, evalSystemVar)
+	}
+	if len(evals) == 0 {
+		return nil
+	}
+	return func(s *Session, expr sqlparser.Expr) (sqltypes.Value, bool) {
+		for _, eval := range evals {
+			if v, ok := eval(s, expr); ok {
+				return v, true
+			}
+		}
+		return sqltypes.Value{}, false
+	}
 }
 
 // Accept runs an accept loop until the listener is closed.
+// The errors like the EMFILE are retried after a backoff, the listener goes on once the load drops.
 func (l *Listener) Accept() {
 	runtime.GOMAXPROCS(runtime.NumCPU())
+	var delay time.Duration
 	for {
 		conn, err := l.listener.Accept()
 		if err != nil {
-			// Close() was probably called.
-			return
+			if atomic.LoadUint32(&l.closed) == 1 || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if delay == 0 {
+				delay = 5 * time.Millisecond
+			} else if delay *= 2; delay > time.Second {
+				delay = time.Second
+			}
+			l.log.Error("server.accept.error:%v.retry.in[%v]", err, delay)
+			time.Sleep(delay)
+			continue
 		}
+		delay = 0
 		ID := l.connectionID
 		l.connectionID++
 		go l.handle(conn, ID)
@@ -96,12 +462,41 @@ func (l *Listener) parserComQuery(data []byte) string {
 	// Trim the right.
 	data = data[1:]
 	last := len(data) - 1
-	if data[last] == ';' {
+	if last >= 0 && data[last] == ';' {
 		data = data[:last]
 	}
 	return common.BytesToString(data)
 }
 
+// parserComQueryWithAttributes parses the COM_QUERY of the session, the query attributes
+// prefix the query if the CLIENT_QUERY_ATTRIBUTES is negotiated.
+func (l *Listener) parserComQueryWithAttributes(session *Session, data []byte) (string, []proto.QueryAttribute, error) {
+	if session.capabilities()&sqldb.CLIENT_QUERY_ATTRIBUTES == 0 {
+		return l.parserComQuery(data), nil, nil
+	}
+	q, err := proto.UnPackComQuery(data[1:], sqldb.CLIENT_QUERY_ATTRIBUTES)
+	if err != nil {
+		return "", nil, err
+	}
+	return strings.TrimSuffix(q.Query, ";"), q.Attributes, nil
+}
+
+// comQuery is the COM_QUERY parsed once by the session for the pool and the dispatch.
+type comQuery struct {
+	query string
+	attrs []proto.QueryAttribute
+	err   error
+}
+
+func (l *Listener) parseComQuery(session *Session, data []byte) *comQuery {
+	if data[0] != sqldb.COM_QUERY {
+		return nil
+	}
+	q := &comQuery{}
+	q.query, q.attrs, q.err = l.parserComQueryWithAttributes(session, data)
+	return q
+}
+
 // handle is called in a go routine for each client connection.
 func (l *Listener) handle(conn net.Conn, ID uint32) {
 	var err error
@@ -117,7 +512,39 @@ func (l *Listener) handle(conn net.Conn, ID uint32) {
 			log.Error("server.handle.panic:\n%v\n%s", x, debug.Stack())
 		}
 	}()
+	if w := l.capture(); w != nil {
+		conn = w.Wrap(conn, true)
+	}
+	l.status.connected()
+	authed := false
+	defer func() {
+		if !authed {
+			l.status.abortedConnect()
+		}
+	}()
 	session := newSession(log, ID, conn)
+	defer l.closeSession(session)
+	greeting := l.GreetingConfig()
+	greeting.apply(session.greeting)
+	// The settings of the handshake are taken once, a reload applies to the coming sessions.
+	tlsConfig, handshakeTimeout := l.tlsConfig(), l.HandshakeTimeout()
+	if tlsConfig != nil && greeting.CapabilityMask&sqldb.CLIENT_SSL == 0 {
+		session.greeting.Capability |= sqldb.CLIENT_SSL
+	}
+	if handshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	}
+	session.setGlobals(l.sysvars)
+	session.resultTimeZone = l.ResultTimeZone()
+	session.memLimit = l.SessionMemoryLimit()
+	session.flushPolicy = l.FlushPolicy()
+	session.output.start(l.OutputQueueSize())
+	// The queued packets like the last ERR are written before the connection is closed.
+	defer session.output.drain()
+	session.translator = l.errorTranslator()
+	if l.tracing() {
+		session.SetTrace(true)
+	}
 	// Session check.
 	if err = l.handler.SessionCheck(session); err != nil {
 		log.Warning("session[%v].check.failed.error:%+v", ID, err)
@@ -141,82 +568,429 @@ func (l *Listener) handle(conn net.Conn, ID uint32) {
 		log.Error("server.read.auth.packet.error: %v", err)
 		return
 	}
+	if proto.IsSSLRequest(authPkt) && session.greeting.Capability&sqldb.CLIENT_SSL > 0 {
+		if err = session.startTLS(tlsConfig); err != nil {
+			log.Warning("server.session[%v].tls.handshake.error: %v", ID, err)
+			return
+		}
+		if authPkt, err = session.packets.Next(); err != nil {
+			log.Error("server.read.auth.packet.error: %v", err)
+			return
+		}
+	}
 	if err = session.auth.UnPack(authPkt); err != nil {
 		log.Error("server.unpack.auth.error: %v", err)
+		session.writeErrFromError(handshakeError(err))
+		return
+	}
+	session.packets.SetCapability(session.capabilities())
+	session.initCharset()
+	if err = l.checkCapability(session); err != nil {
+		return
+	}
+	if err = l.checkAuthPlugin(session); err != nil {
+		log.Warning("server.user[%+v].auth.plugin[%s].not.supported:%v", session.User(), session.auth.PluginName(), err)
+		return
+	}
+	if session.TLSState() == nil && l.requiresSecureTransport(session.User()) {
+		log.Warning("server.user[%+v].insecure.transport.rejected", session.User())
+		session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_SECURE_TRANSPORT_REQUIRED, ""))
 		return
 	}
 
-	// Check the database.
-	db := session.auth.Database()
-	if db != "" {
+	// Throttle check, the users and the hosts failed before are delayed or blocked.
+	throttle := l.authThrottle()
+	if err = throttle.wait(session.User(), session.host()); err != nil {
+		log.Warning("server.user[%+v].host[%s].blocked", session.User(), session.host())
+		session.writeErrFromError(err)
+		return
+	}
+
+	// X509 check, the password isn't checked if the certificate is enough.
+	certOnly, err := l.checkX509(session)
+	if err != nil {
+		log.Warning("server.user[%+v].x509.check.failed", session.User())
+		throttle.failed(session.User(), session.host(), err)
+		session.writeErrFromError(err)
+		return
+	}
+
+	//  Auth check.
+	if !certOnly {
+		if delegate := l.authDelegate(); delegate != nil {
+			err = l.authenticate(session, delegate)
+		} else {
+			err = l.handler.AuthCheck(session)
+		}
+		if err != nil {
+			log.Warning("server.user[%+v].auth.check.failed", session.User())
+			throttle.failed(session.User(), session.host(), err)
+			session.writeErrFromError(err)
+			return
+		}
+	}
+	throttle.succeeded(session.User(), session.host())
+
+	// Check the database, the handler sees it only after the user is authenticated.
+	if db := session.auth.Database(); db != "" {
 		if err = l.handler.ComInitDB(session, db); err != nil {
-			if werr := session.writeErrFromError(err); werr != nil {
-				return
-			}
+			session.writeErrFromError(err)
 			return
 		}
 		session.SetSchema(db)
 	}
-
-	//  Auth check.
-	if err = l.handler.AuthCheck(session); err != nil {
-		log.Warning("server.user[%+v].auth.check.failed", session.User())
+	if err = l.checkPasswordExpired(session); err != nil {
+		log.Warning("server.user[%+v].password.expired", session.User())
 		session.writeErrFromError(err)
 		return
-	} else {
-		if err = session.packets.WriteOK(0, 0, session.greeting.Status(), 0); err != nil {
-			return
-		}
+	}
+	if err = session.packets.WriteOK(0, 0, session.Status(), 0); err != nil {
+		return
+	}
+	authed = true
+	l.addSession(session)
+	if handshakeTimeout > 0 {
+		conn.SetDeadline(time.Time{})
 	}
 
 	for {
 		// Reset packet sequence ID.
 		session.packets.ResetSeq()
+		session.resetMemory()
+		session.resetResult()
+		session.setQueryAttributes(nil)
+		session.output.setTimeout(session.netWriteTimeout())
 		if data, err = session.packets.Next(); err != nil {
 			return
 		}
-
-		switch data[0] {
-		case sqldb.COM_QUIT:
-			return
-		case sqldb.COM_INIT_DB:
-			db := l.parserComInitDB(data)
-			if err = l.handler.ComInitDB(session, db); err != nil {
+		session.countCommand()
+		q := l.parseComQuery(session, data)
+		ok := true
+		if p := l.threadPool(); p != nil && pooled(data[0], q) {
+			if err = p.execute(func() { ok = l.dispatch(session, data, q) }); err != nil {
+				log.Warning("server.session[%v].thread.pool.overloaded:%v", ID, err)
 				if werr := session.writeErrFromError(err); werr != nil {
 					return
 				}
-			} else {
-				session.SetSchema(db)
-				if err = session.packets.WriteOK(0, 0, session.greeting.Status(), 0); err != nil {
-					return
+				continue
+			}
+		} else {
+			ok = l.dispatch(session, data, q)
+		}
+		if !ok {
+			return
+		}
+		// Reset packet sequence ID.
+		session.packets.ResetSeq()
+	}
+}
+
+// dispatch executes the command of the session, it returns false if the session ends.
+// The q is the parsed COM_QUERY, nil for the other commands.
+// The panics of the handler are answered with the ER_INTERNAL_ERROR, the session and the listener go on.
+func (l *Listener) dispatch(session *Session, data []byte, q *comQuery) (ok bool) {
+	var err error
+	defer func() {
+		if x := recover(); x != nil {
+			ok = l.recoverCommand(session, data[0], x)
+		}
+	}()
+	if err = session.AllocMemory(int64(len(data))); err != nil {
+		if werr := session.writeErrFromError(err); werr != nil {
+			return false
+		}
+		return true
+	}
+	if data[0] != sqldb.COM_QUIT {
+		l.status.question()
+	}
+	if err = session.checkSandbox(data[0]); err != nil {
+		if werr := session.writeErrFromError(err); werr != nil {
+			return false
+		}
+		return true
+	}
+
+	switch data[0] {
+	case sqldb.COM_QUIT:
+		return false
+	case sqldb.COM_INIT_DB:
+		db := l.parserComInitDB(data)
+		if err = session.checkSchema(db); err == nil {
+			err = l.handler.ComInitDB(session, db)
+		}
+		if err != nil {
+			if werr := session.writeErrFromError(err); werr != nil {
+				return false
+			}
+		} else {
+			session.SetSchema(db)
+			if err = session.packets.WriteOK(0, 0, session.Status(), 0); err != nil {
+				return false
+			}
+		}
+	case sqldb.COM_PING:
+		if err = l.handlePing(session); err != nil {
+			return false
+		}
+	case sqldb.COM_FIELD_LIST:
+		if err = l.handleFieldList(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_PROCESS_KILL:
+		if err = l.handleProcessKill(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_STMT_PREPARE:
+		if err = l.handleStmtPrepare(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_STMT_EXECUTE:
+		if err = l.handleStmtExecute(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_STMT_FETCH:
+		if err = l.handleStmtFetch(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_STMT_SEND_LONG_DATA:
+		l.handleStmtSendLongData(session, data)
+	case sqldb.COM_STMT_RESET:
+		if err = l.handleStmtReset(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_STMT_CLOSE:
+		l.handleStmtClose(session, data)
+	case sqldb.COM_QUERY:
+		query := q.query
+		if q.err != nil {
+			if werr := session.writeErrFromError(q.err); werr != nil {
+				return false
+			}
+			return true
+		}
+		session.setQueryAttributes(q.attrs)
+		if query, err = session.decodeQuery(query); err != nil {
+			if werr := session.writeErrFromError(err); werr != nil {
+				return false
+			}
+			return true
+		}
+		if err = session.checkSandboxQuery(query); err != nil {
+			if werr := session.writeErrFromError(err); werr != nil {
+				return false
+			}
+			return true
+		}
+		if err = session.checkQuery(query); err != nil {
+			if werr := session.writeErrFromError(err); werr != nil {
+				return false
+			}
+			return true
+		}
+		l.countStatement(session, query)
+		if qr, ok := l.showStatus(session, query); ok {
+			if err = session.writeResult(qr); err != nil {
+				return false
+			}
+			break
+		}
+		if qr, ok := session.showWarnings(query); ok {
+			if err = session.writeResult(qr); err != nil {
+				return false
+			}
+			break
+		}
+		session.clearWarnings()
+		if l.answerSystemVariables() {
+			if qr, ok := session.showVariables(query); ok {
+				if err = session.writeResult(qr); err != nil {
+					return false
 				}
+				break
 			}
-		case sqldb.COM_PING:
-			if err = session.packets.WriteOK(0, 0, session.greeting.Status(), 0); err != nil {
-				return
+		}
+		if eval := l.localEval(); eval != nil {
+			if qr, ok := session.localSelect(query, eval); ok {
+				if err = session.writeResult(qr); err != nil {
+					return false
+				}
+				break
+			}
+		}
+		if qr, ok, ierr := l.infoSchemaSelect(session, query); ok {
+			if ierr != nil {
+				session.addError(ierr)
+				if werr := session.writeErrFromError(ierr); werr != nil {
+					return false
+				}
+				return true
 			}
-		case sqldb.COM_QUERY:
-			query := l.parserComQuery(data)
-			if err = l.handler.ComQuery(session, query, func(qr *sqltypes.Result) error {
-				return session.writeResult(qr)
-			}); err != nil {
-				log.Error("server.handle.query.from.session[%v].error:%+v.query[%s]", ID, err, query)
+			if err = session.writeResult(qr); err != nil {
+				return false
+			}
+			break
+		}
+
+		undo, apply := func() {}, func() {}
+		if sqlparser.Preview(query) == sqlparser.StmtSet {
+			if undo, apply, err = session.trackSetVars(query); err != nil {
+				session.addError(err)
 				if werr := session.writeErrFromError(err); werr != nil {
-					return
+					return false
 				}
-				continue
+				return true
 			}
-		default:
-			cmd := sqldb.CommandString(data[0])
-			log.Error("session.command:%s.not.implemented", cmd)
-			sqlErr := sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "command handling not implemented yet: %s", cmd)
-			if err := session.writeErrFromError(sqlErr); err != nil {
-				return
+		}
+		end := session.beginStatement(session.executionTimeout(query))
+		err = l.handler.ComQuery(session, query, func(qr *sqltypes.Result) error {
+			return session.writeResult(qr)
+		})
+		if ierr := end(); ierr != nil {
+			err = ierr
+		}
+		if err != nil {
+			undo()
+			session.addError(err)
+			session.setRowCount(-1)
+			l.log.Error("server.handle.query.from.session[%v].error:%+v.query[%s]", session.ID(), err, query)
+			if werr := session.writeErrFromError(err); werr != nil {
+				return false
 			}
+			return true
 		}
-		// Reset packet sequence ID.
-		session.packets.ResetSeq()
+		apply()
+		l.passwordChanged(session, query)
+	case sqldb.COM_REFRESH:
+		if err = l.handleRefresh(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_DEBUG:
+		if err = l.handleDebug(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_SHUTDOWN:
+		if err = l.handleShutdown(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_REGISTER_SLAVE:
+		if err = l.handleRegisterSlave(session, data); err != nil {
+			return false
+		}
+	case sqldb.COM_BINLOG_DUMP, sqldb.COM_BINLOG_DUMP_GTID:
+		if err = l.handleBinlogDump(session, data); err != nil {
+			return false
+		}
+	default:
+		if err = l.handleOther(session, data); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// recoverCommand logs the panic of the command with the stack, the running statement is ended and
+// the client gets the ER_INTERNAL_ERROR unless the command has no response.
+// It returns false if the session must be closed, the error can't be written or a result was already sent.
+func (l *Listener) recoverCommand(session *Session, command byte, x interface{}) bool {
+	l.log.Error("server.session[%v].command[%s].panic:\n%v\n%s", session.ID(), sqldb.CommandString(command), x, debug.Stack())
+	session.abortStatement()
+	if command == sqldb.COM_STMT_SEND_LONG_DATA || command == sqldb.COM_STMT_CLOSE {
+		return true
+	}
+	// The ERR after a part of the result puts the client out of sync with the protocol, the session is closed.
+	if session.resultSent() {
+		return false
+	}
+	err := sqldb.NewSQLError(sqldb.ER_INTERNAL_ERROR, "Internal error: %s", "the command panicked")
+	session.addError(err)
+	return session.writeErrFromError(err) == nil
+}
+
+// checkAuthPlugin switches the client to the mysql_native_password if it answered another plugin,
+// the clients of the 323 scramble or without the CLIENT_PLUGIN_AUTH are rejected.
+func (l *Listener) checkAuthPlugin(session *Session) error {
+	var data []byte
+	var err error
+
+	plugin := session.auth.PluginName()
+	if plugin == proto.DefaultAuthPluginName {
+		return nil
+	}
+	if plugin == proto.OldAuthPluginName {
+		sqlErr := sqldb.NewSQLError(sqldb.ER_NOT_SUPPORTED_AUTH_MODE, "Client does not support authentication protocol requested by server; the insecure %s is refused, use the %s", plugin, proto.DefaultAuthPluginName)
+		session.writeErrFromError(sqlErr)
+		return sqlErr
+	}
+	if session.capabilities()&sqldb.CLIENT_PLUGIN_AUTH == 0 {
+		sqlErr := sqldb.NewSQLError(sqldb.ER_NOT_SUPPORTED_AUTH_MODE, "")
+		session.writeErrFromError(sqlErr)
+		return sqlErr
+	}
+
+	req := &proto.AuthSwitchRequest{PluginName: proto.DefaultAuthPluginName, AuthData: session.greeting.Salt}
+	if err = session.packets.Write(req.Pack()); err != nil {
+		return err
+	}
+	if data, err = session.packets.Next(); err != nil {
+		return err
+	}
+	session.auth.SwitchAuthResponse(proto.DefaultAuthPluginName, data)
+	return nil
+}
+
+// handleRegisterSlave handles the COM_REGISTER_SLAVE, the error returned is the write error.
+func (l *Listener) handleRegisterSlave(session *Session, data []byte) error {
+	rh, ok := l.handler.(ReplicationHandler)
+	if !ok {
+		return l.writeNotImplemented(session, data[0])
 	}
+
+	slave, err := proto.UnPackRegisterSlave(data[1:])
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	if err = rh.ComRegisterSlave(session, slave); err != nil {
+		l.log.Error("server.handle.register.slave.from.session[%v].error:%+v", session.ID(), err)
+		return session.writeErrFromError(err)
+	}
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleBinlogDump handles the COM_BINLOG_DUMP and COM_BINLOG_DUMP_GTID, the error returned is the write error.
+func (l *Listener) handleBinlogDump(session *Session, data []byte) error {
+	bh, ok := l.handler.(BinlogDumpHandler)
+	if !ok {
+		return l.writeNotImplemented(session, data[0])
+	}
+
+	var err error
+	stream := &BinlogStream{session: session}
+	if data[0] == sqldb.COM_BINLOG_DUMP {
+		var dump *proto.BinlogDump
+		if dump, err = proto.UnPackBinlogDump(data[1:]); err != nil {
+			return session.writeErrFromError(err)
+		}
+		err = bh.ComBinlogDump(session, dump, stream)
+	} else {
+		var dump *proto.BinlogDumpGTID
+		if dump, err = proto.UnPackBinlogDumpGTID(data[1:]); err != nil {
+			return session.writeErrFromError(err)
+		}
+		err = bh.ComBinlogDumpGTID(session, dump, stream)
+	}
+	if err != nil {
+		l.log.Error("server.handle.binlog.dump.from.session[%v].error:%+v", session.ID(), err)
+		return session.writeErrFromError(err)
+	}
+	return stream.writeEOF()
+}
+
+func (l *Listener) writeNotImplemented(session *Session, command byte) error {
+	cmd := sqldb.CommandString(command)
+	l.log.Error("session.command:%s.not.implemented", cmd)
+	sqlErr := sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "command handling not implemented yet: %s", cmd)
+	return session.writeErrFromError(sqlErr)
 }
 
 func (l *Listener) Addr() string {
@@ -225,5 +999,6 @@ func (l *Listener) Addr() string {
 
 // Close close the listener and all connections.
 func (l *Listener) Close() {
+	atomic.StoreUint32(&l.closed, 1)
 	l.listener.Close()
 }
@@ -10,9 +10,21 @@
 package driver
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
 	"github.com/XeLabs/go-mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
 
@@ -148,11 +160,21 @@ func TestServer(t *testing.T) {
 		defer client.Close()
 
 		th.AddQueryPanic("PANIC")
-		client.Exec("PANIC")
+		err = client.Exec("PANIC")
+		num, _ := sqldb.ErrorNum(err)
+		assert.Equal(t, uint16(sqldb.ER_INTERNAL_ERROR), num)
 
-		want := true
-		got := client.Closed()
-		assert.Equal(t, want, got)
+		// The session goes on.
+		assert.False(t, client.Closed())
+		assert.Nil(t, client.Ping())
+
+		// The prepared statements too.
+		stmt, err := client.Prepare("PANIC")
+		assert.Nil(t, err)
+		_, err = stmt.Execute()
+		num, _ = sqldb.ErrorNum(err)
+		assert.Equal(t, uint16(sqldb.ER_INTERNAL_ERROR), num)
+		assert.Nil(t, client.Ping())
 	}
 
 	// ping
@@ -181,7 +203,8 @@ func TestServer(t *testing.T) {
 }
 
 func TestServerSessionClose(t *testing.T) {
-	result2 := &sqltypes.Result{}
+	// The status flags read back are the session ones.
+	result2 := &sqltypes.Result{StatusFlags: sqldb.SERVER_STATUS_AUTOCOMMIT}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
 	th := NewTestHandler(log)
@@ -207,6 +230,55 @@ func TestServerSessionClose(t *testing.T) {
 	}
 }
 
+func TestServerWrappedError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The handler wraps the SQLError, the client gets its code.
+	sqlErr := sqldb.NewSQLError(sqldb.ER_READ_ONLY_MODE, "")
+	th.AddQueryError("insert into t1 values(1)", fmt.Errorf("backend.write:%w", sqlErr))
+	_, err = client.Query("insert into t1 values(1)")
+	assert.True(t, sqldb.IsReadOnly(err))
+	assert.Equal(t, "Running in read-only mode (errno 1836) (sqlstate HY000)", err.Error())
+}
+
+func TestServerOKInfo(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	changes := []sqltypes.SessionStateChange{
+		proto.SessionTrackSystemVariable("autocommit", "ON"),
+		proto.SessionTrackSchema("db1"),
+	}
+	th.AddQuery("update t1 set a=1", &sqltypes.Result{
+		RowsAffected:        1,
+		Info:                "Rows matched: 2  Changed: 1  Warnings: 0",
+		StatusFlags:         sqldb.SERVER_QUERY_WAS_SLOW,
+		SessionStateChanges: changes,
+	})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	qr, err := client.FetchAll("update t1 set a=1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), qr.RowsAffected)
+	assert.Equal(t, "Rows matched: 2  Changed: 1  Warnings: 0", qr.Info)
+	assert.Equal(t, uint16(sqldb.SERVER_STATUS_AUTOCOMMIT|sqldb.SERVER_QUERY_WAS_SLOW|sqldb.SERVER_SESSION_STATE_CHANGED), qr.StatusFlags)
+	assert.Equal(t, changes, qr.SessionStateChanges)
+}
+
 func TestServerComInitDB(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
 	th := NewTestHandler(log)
@@ -243,3 +315,1179 @@ func TestServerUnsupportedCommand(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestServerRegisterSlave(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	register := func(client Conn, slave *proto.RegisterSlave) error {
+		if err := client.WriteCommand(sqldb.COM_REGISTER_SLAVE, proto.PackRegisterSlave(slave)); err != nil {
+			return err
+		}
+		data, err := client.NextPacket()
+		if err != nil {
+			return err
+		}
+		if data[0] == proto.ERR_PACKET {
+			return proto.UnPackERR(data)
+		}
+		return nil
+	}
+
+	slave := &proto.RegisterSlave{ServerID: 100, Host: "127.0.0.1", User: "repl", Port: 3307}
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, register(client, slave))
+	assert.Equal(t, []*proto.RegisterSlave{slave}, th.Slaves())
+
+	// The same server id.
+	{
+		other, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		err = register(other, &proto.RegisterSlave{ServerID: 100})
+		assert.Equal(t, uint16(sqldb.ER_MASTER_FATAL_ERROR_READING_BINLOG), err.(*sqldb.SQLError).Num)
+		other.Close()
+	}
+
+	// Unregistered after the session closed.
+	client.Close()
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, len(th.Slaves()))
+
+	// The handler without the replication support.
+	{
+		svr, err := MockMysqlServer(log, struct{ Handler }{th})
+		assert.Nil(t, err)
+		defer svr.Close()
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		err = register(client, slave)
+		assert.Equal(t, "command handling not implemented yet: COM_REGISTER_SLAVE (errno 1105) (sqlstate HY000)", err.Error())
+	}
+}
+
+func TestServerAuthPlugin(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	// handshake sends the auth built by the fn, returns the packets after the greeting.
+	handshake := func(fn func(greeting *proto.Greeting) []byte) *packet.Packets {
+		conn, err := net.Dial("tcp", svr.Addr())
+		assert.Nil(t, err)
+		packets := packet.NewPackets(conn)
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		greeting := proto.NewGreeting(0)
+		assert.Nil(t, greeting.UnPack(data))
+		assert.Nil(t, packets.Write(fn(greeting)))
+		return packets
+	}
+
+	// The other plugin is switched to the mysql_native_password.
+	{
+		var salt []byte
+		packets := handshake(func(greeting *proto.Greeting) []byte {
+			salt = greeting.Salt
+			data := proto.NewAuth().Pack(proto.DefaultClientCapability, sqldb.CharacterSetUtf8, "mock", "mock", salt, "")
+			return bytes.Replace(data, []byte(proto.DefaultAuthPluginName), []byte("caching_sha2_password"), 1)
+		})
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		req, err := proto.UnPackAuthSwitchRequest(data)
+		assert.Nil(t, err)
+		assert.Equal(t, proto.DefaultAuthPluginName, req.PluginName)
+		assert.Equal(t, salt, req.AuthData)
+
+		resp, err := req.Response("mock", nil)
+		assert.Nil(t, err)
+		assert.Nil(t, packets.Write(resp))
+		data, err = packets.Next()
+		assert.Nil(t, err)
+		assert.Equal(t, proto.OK_PACKET, data[0])
+	}
+
+	// The 323 scramble is rejected.
+	{
+		packets := handshake(func(greeting *proto.Greeting) []byte {
+			resp, err := (&proto.AuthSwitchRequest{PluginName: proto.OldAuthPluginName}).Response("mock", greeting.Salt)
+			assert.Nil(t, err)
+			buf := common.NewBuffer(64)
+			buf.WriteU32(sqldb.CLIENT_PROTOCOL_41 | sqldb.CLIENT_LONG_PASSWORD)
+			buf.WriteU32(0)
+			buf.WriteU8(sqldb.CharacterSetUtf8)
+			buf.WriteZero(23)
+			buf.WriteString("mock")
+			buf.WriteZero(1)
+			buf.WriteBytes(resp)
+			return buf.Datas()
+		})
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		err = packets.ParseERR(data)
+		assert.Equal(t, uint16(sqldb.ER_NOT_SUPPORTED_AUTH_MODE), err.(*sqldb.SQLError).Num)
+	}
+
+	// The bad handshake is answered.
+	{
+		packets := handshake(func(greeting *proto.Greeting) []byte {
+			return []byte{0x00, 0x00, 0x00, 0x00}
+		})
+		data, err := packets.Next()
+		assert.Nil(t, err)
+		err = packets.ParseERR(data)
+		assert.Equal(t, uint16(sqldb.ER_HANDSHAKE_ERROR), err.(*sqldb.SQLError).Num)
+	}
+}
+
+func TestServerGreetingConfig(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	cfg := DefaultGreetingConfig()
+	assert.Equal(t, proto.DefaultServerCapability, cfg.Capability())
+	cfg.ServerVersion = "8.0.32-proxy"
+	cfg.Charset = sqldb.CharacterSetMap["utf8mb4"]
+	cfg.Status = 0
+	cfg.CapabilityMask = sqldb.CLIENT_DEPRECATE_EOF | sqldb.CLIENT_CONNECT_ATTRS
+	cfg.CapabilityForce = sqldb.CLIENT_CONNECT_ATTRS
+	assert.Nil(t, svr.SetGreetingConfig(cfg))
+	// The config is copied.
+	cfg.ServerVersion = "5.6"
+	assert.Equal(t, "8.0.32-proxy", svr.GreetingConfig().ServerVersion)
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.Equal(t, "8.0.32-proxy", client.ServerVersion().Raw)
+	assert.Equal(t, uint8(45), client.greeting.Charset)
+	assert.Equal(t, uint32(0), client.greeting.Capability&sqldb.CLIENT_DEPRECATE_EOF)
+	assert.NotEqual(t, uint32(0), client.greeting.Capability&sqldb.CLIENT_CONNECT_ATTRS)
+	assert.Equal(t, uint16(0), client.Status())
+
+	// The results are ended by the EOF.
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	}
+	th.AddQuery("SELECT id FROM t1", result)
+	got, err := client.FetchAll("SELECT id FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, result.Rows, got.Rows)
+
+	cfg.CapabilityMask = sqldb.CLIENT_PROTOCOL_41
+	assert.NotNil(t, svr.SetGreetingConfig(cfg))
+	cfg.CapabilityMask = 0
+	cfg.ServerVersion = ""
+	assert.NotNil(t, svr.SetGreetingConfig(cfg))
+}
+
+// txnHandler reports the transaction state by the BEGIN, COMMIT and ROLLBACK.
+type txnHandler struct {
+	*TestHandler
+}
+
+func (h *txnHandler) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	switch strings.ToLower(query) {
+	case "begin":
+		session.SetInTransaction(true)
+	case "commit", "rollback":
+		session.SetInTransaction(false)
+	default:
+		return h.TestHandler.ComQuery(session, query, callback)
+	}
+	return callback(&sqltypes.Result{})
+}
+
+func TestServerInTransaction(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, &txnHandler{th})
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	}
+	th.AddQuery("SELECT id FROM t1", result)
+
+	check := func() {
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+		assert.False(t, client.InTransaction())
+
+		assert.Nil(t, client.Exec("BEGIN"))
+		assert.True(t, client.InTransaction())
+		assert.Equal(t, uint16(sqldb.SERVER_STATUS_AUTOCOMMIT|sqldb.SERVER_STATUS_IN_TRANS), client.Status())
+
+		// The resultset terminator carries the flags.
+		_, err = client.FetchAll("SELECT id FROM t1", -1)
+		assert.Nil(t, err)
+		assert.True(t, client.InTransaction())
+
+		assert.Nil(t, client.Exec("COMMIT"))
+		assert.False(t, client.InTransaction())
+		_, err = client.FetchAll("SELECT id FROM t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(sqldb.SERVER_STATUS_AUTOCOMMIT), client.Status())
+	}
+	check()
+
+	// The classic EOF carries the flags too.
+	cfg := DefaultGreetingConfig()
+	cfg.CapabilityMask = sqldb.CLIENT_DEPRECATE_EOF
+	assert.Nil(t, svr.SetGreetingConfig(cfg))
+	check()
+}
+
+func TestServerAutocommit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, &txnHandler{th})
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	for _, query := range []string{
+		"SET autocommit=0",
+		"set @@session.autocommit = ON",
+		"SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY",
+		"SET @@global.autocommit=0",
+	} {
+		th.AddQuery(query, &sqltypes.Result{})
+	}
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	assert.True(t, session.Autocommit())
+
+	// The OK of the SET carries the new flags.
+	assert.Nil(t, client.Exec("SET autocommit=0"))
+	assert.False(t, session.Autocommit())
+	assert.Equal(t, uint16(0), client.Status())
+
+	// Enabling the autocommit commits the transaction.
+	assert.Nil(t, client.Exec("BEGIN"))
+	assert.True(t, client.InTransaction())
+	assert.Nil(t, client.Exec("set @@session.autocommit = ON"))
+	assert.True(t, session.Autocommit())
+	assert.False(t, session.InTransaction())
+	assert.Equal(t, uint16(sqldb.SERVER_STATUS_AUTOCOMMIT), client.Status())
+
+	// The global scope leaves the session alone.
+	assert.Nil(t, client.Exec("SET @@global.autocommit=0"))
+	assert.True(t, session.Autocommit())
+
+	// The handler failure restores the state.
+	assert.NotNil(t, client.Exec("SET autocommit=OFF"))
+	assert.True(t, session.Autocommit())
+
+	// The bad value is rejected before the handler.
+	err = client.Exec("SET autocommit=2")
+	assert.Equal(t, uint16(sqldb.ER_WRONG_VALUE_FOR_VAR), err.(*sqldb.SQLError).Num)
+	assert.True(t, session.Autocommit())
+
+	assert.Equal(t, "", session.TransactionIsolation())
+	assert.Nil(t, client.Exec("SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY"))
+	assert.Equal(t, "READ-COMMITTED", session.TransactionIsolation())
+	assert.True(t, session.TransactionReadOnly())
+}
+
+// warnHandler attaches the warnings to the queries like 'warn N'.
+type warnHandler struct {
+	*TestHandler
+}
+
+func (h *warnHandler) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	var n int
+	if _, err := fmt.Sscanf(query, "warn %d", &n); err != nil {
+		return h.TestHandler.ComQuery(session, query, callback)
+	}
+	for i := 0; i < n; i++ {
+		session.AddWarning(WarningLevelWarning, 1265, fmt.Sprintf("Data truncated for column 'c%d' at row 1", i))
+	}
+	return callback(&sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	})
+}
+
+func TestServerWarnings(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, &warnHandler{th})
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+
+	check := func() {
+		client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+		assert.Nil(t, err)
+		defer client.Close()
+
+		_, err = client.FetchAll("warn 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(2), client.WarningCount())
+
+		// The SHOW WARNINGS keeps the list.
+		for i := 0; i < 2; i++ {
+			warnings, err := client.Warnings()
+			assert.Nil(t, err)
+			assert.Equal(t, []Warning{
+				{Level: WarningLevelWarning, Code: 1265, Message: "Data truncated for column 'c0' at row 1"},
+				{Level: WarningLevelWarning, Code: 1265, Message: "Data truncated for column 'c1' at row 1"},
+			}, warnings)
+		}
+		qr, err := client.FetchAll("show warnings limit 1, 5", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "Data truncated for column 'c1' at row 1", qr.Rows[0][2].String())
+		qr, err = client.FetchAll("SHOW COUNT(*) WARNINGS", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "2", qr.Rows[0][0].String())
+		qr, err = client.FetchAll("SHOW ERRORS", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(qr.Rows))
+
+		// The next statement clears the list.
+		assert.Nil(t, client.Exec("SELECT 1"))
+		assert.Equal(t, uint16(0), client.WarningCount())
+		warnings, err := client.Warnings()
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(warnings))
+
+		// The error is kept as the Error level.
+		assert.NotNil(t, client.Exec("SELECT 2"))
+		qr, err = client.FetchAll("SHOW COUNT(*) ERRORS", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "1", qr.Rows[0][0].String())
+		warnings, err = client.Warnings()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(warnings))
+		assert.Equal(t, WarningLevelError, warnings[0].Level)
+
+		// The warnings beyond the max_error_count are counted only.
+		_, err = client.FetchAll("warn 70", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(70), client.WarningCount())
+		warnings, err = client.Warnings()
+		assert.Nil(t, err)
+		assert.Equal(t, maxWarnings, len(warnings))
+	}
+	check()
+
+	cfg := DefaultGreetingConfig()
+	cfg.CapabilityMask = sqldb.CLIENT_DEPRECATE_EOF
+	assert.Nil(t, svr.SetGreetingConfig(cfg))
+	check()
+}
+
+func TestServerSessionFunctions(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("INSERT INTO t1 VALUES(1), (2)", &sqltypes.Result{RowsAffected: 2, InsertID: 7})
+	th.AddQuery("UPDATE t1 SET a=1", &sqltypes.Result{RowsAffected: 3})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+
+	// Left to the handler by default.
+	_, err = client.FetchAll("SELECT LAST_INSERT_ID()", -1)
+	assert.NotNil(t, err)
+	assert.Equal(t, int64(-1), session.RowCount())
+
+	svr.SetSessionFunctions(true)
+	assert.Nil(t, client.Exec("INSERT INTO t1 VALUES(1), (2)"))
+	assert.Equal(t, uint64(7), session.LastInsertID())
+	assert.Equal(t, int64(2), session.RowCount())
+
+	qr, err := client.FetchAll("SELECT LAST_INSERT_ID() AS id, ROW_COUNT()", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "id", qr.Fields[0].Name)
+	assert.Equal(t, querypb.Type_UINT64, qr.Fields[0].Type)
+	assert.Equal(t, "7", qr.Rows[0][0].String())
+	assert.Equal(t, "2", qr.Rows[0][1].String())
+
+	// The SELECT returns a resultset, the insert id is kept.
+	assert.Nil(t, client.Exec("UPDATE t1 SET a=1"))
+	qr, err = client.FetchAll("select row_count(), last_insert_id()", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "3", qr.Rows[0][0].String())
+	assert.Equal(t, "7", qr.Rows[0][1].String())
+	qr, err = client.FetchAll("select row_count()", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "-1", qr.Rows[0][0].String())
+
+	// LAST_INSERT_ID(N) sets the value.
+	qr, err = client.FetchAll("select last_insert_id(42)", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "42", qr.Rows[0][0].String())
+	assert.Equal(t, uint64(42), session.LastInsertID())
+
+	// The other selects go to the handler.
+	_, err = client.FetchAll("SELECT LAST_INSERT_ID() FROM t1", -1)
+	assert.NotNil(t, err)
+	_, err = client.FetchAll("SELECT LAST_INSERT_ID(), 1", -1)
+	assert.NotNil(t, err)
+}
+
+func TestServerUserVariables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SET @a = 1, @B = 'x', @c = 1.5, @d = @a, @e = now()", &sqltypes.Result{})
+	th.AddQuery("SET @a = NULL", &sqltypes.Result{})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+
+	assert.Nil(t, client.Exec("SET @a = 1, @B = 'x', @c = 1.5, @d = @a, @e = now()"))
+	v, ok := session.UserVar("A")
+	assert.True(t, ok)
+	assert.Equal(t, sqltypes.NewInt64(1), v)
+	v, _ = session.UserVar("b")
+	assert.Equal(t, sqltypes.NewVarChar("x"), v)
+	v, _ = session.UserVar("c")
+	assert.Equal(t, querypb.Type_DECIMAL, v.Type())
+	v, _ = session.UserVar("d")
+	assert.Equal(t, "1", v.String())
+	// The expression can't be evaluated, so it's undefined.
+	_, ok = session.UserVar("e")
+	assert.False(t, ok)
+	assert.Equal(t, 4, len(session.UserVars()))
+	assert.Equal(t, "select * from t where a=1 and b='x' and e=NULL", session.SubstituteUserVars("select * from t where a=@a and b=@b and e=@e"))
+
+	// Left to the handler by default.
+	_, err = client.FetchAll("SELECT @a", -1)
+	assert.NotNil(t, err)
+
+	svr.SetUserVariables(true)
+	qr, err := client.FetchAll("SELECT @a, @b AS b, @e", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "@a", qr.Fields[0].Name)
+	assert.Equal(t, "b", qr.Fields[1].Name)
+	assert.Equal(t, "1", qr.Rows[0][0].String())
+	assert.Equal(t, "x", qr.Rows[0][1].String())
+	assert.True(t, qr.Rows[0][2].IsNull())
+	// The session functions are still left to the handler.
+	_, err = client.FetchAll("SELECT @a, ROW_COUNT()", -1)
+	assert.NotNil(t, err)
+	svr.SetSessionFunctions(true)
+	qr, err = client.FetchAll("SELECT @a, ROW_COUNT()", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "-1", qr.Rows[0][1].String())
+
+	// The NULL undefines it.
+	assert.Nil(t, client.Exec("SET @a = NULL"))
+	_, ok = session.UserVar("a")
+	assert.False(t, ok)
+
+	// The handler failure undoes the assignments.
+	err = client.Exec("SET @b = 'y', @f = 2")
+	assert.NotNil(t, err)
+	v, _ = session.UserVar("b")
+	assert.Equal(t, "x", v.String())
+	_, ok = session.UserVar("f")
+	assert.False(t, ok)
+}
+
+func TestServerSystemVariables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	for _, q := range []string{
+		"SET NAMES utf8mb4, wait_timeout = 60, @@session.sql_mode = 'ANSI_QUOTES'",
+		"SET GLOBAL max_allowed_packet = 1024, wait_timeout = off",
+		"SET GLOBAL max_connections = 10, wait_timeout = 'x'",
+		"SET version = 'x'",
+		"SET wait_timeout = DEFAULT, net_write_timeout = 10",
+	} {
+		th.AddQuery(q, &sqltypes.Result{})
+	}
+	th.AddQueryError("SET GLOBAL wait_timeout = 10", sqldb.NewSQLError(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; you need (at least one of) the SUPER privilege(s) for this operation"))
+	svr.GlobalVariables().Set("version_comment", sqltypes.NewVarChar("mock"))
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+
+	// Left to the handler by default.
+	_, err = client.FetchAll("SELECT @@version", -1)
+	assert.NotNil(t, err)
+	_, err = client.FetchAll("SHOW VARIABLES", -1)
+	assert.NotNil(t, err)
+
+	svr.SetSystemVariables(true)
+	qr, err := client.FetchAll("SELECT @@version, @@version_comment, @@session.auto_increment_increment AS inc, @@autocommit, @@tx_isolation, @@GLOBAL.max_allowed_packet", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "@@version", qr.Fields[0].Name)
+	assert.Equal(t, "inc", qr.Fields[2].Name)
+	assert.Equal(t, querypb.Type_INT64, qr.Fields[2].Type)
+	assert.Equal(t, "@@GLOBAL.max_allowed_packet", qr.Fields[5].Name)
+	got := make([]string, 0, len(qr.Rows[0]))
+	for _, v := range qr.Rows[0] {
+		got = append(got, v.String())
+	}
+	assert.Equal(t, []string{proto.DefaultServerVersion, "mock", "1", "1", "REPEATABLE-READ", "4194304"}, got)
+	// The unknown variable is left to the handler.
+	_, err = client.FetchAll("SELECT @@version, @@unknown_variable", -1)
+	assert.NotNil(t, err)
+
+	// SET SESSION keeps the session values.
+	assert.Nil(t, client.Exec("SET NAMES utf8mb4, wait_timeout = 60, @@session.sql_mode = 'ANSI_QUOTES'"))
+	qr, err = client.FetchAll("SELECT @@character_set_client, @@character_set_results, @@wait_timeout, @@global.wait_timeout, @@sql_mode", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "utf8mb4", qr.Rows[0][0].String())
+	assert.Equal(t, "utf8mb4", qr.Rows[0][1].String())
+	assert.Equal(t, "60", qr.Rows[0][2].String())
+	assert.Equal(t, "28800", qr.Rows[0][3].String())
+	assert.Equal(t, "ANSI_QUOTES", qr.Rows[0][4].String())
+	assert.Nil(t, client.Exec("SET wait_timeout = DEFAULT, net_write_timeout = 10"))
+	v, _ := session.SystemVariable("wait_timeout")
+	assert.Equal(t, "28800", v.String())
+	v, _ = session.SystemVariable("NET_WRITE_TIMEOUT")
+	assert.Equal(t, "10", v.String())
+
+	// SET GLOBAL.
+	assert.Nil(t, client.Exec("SET GLOBAL max_allowed_packet = 1024, wait_timeout = off"))
+	v, _ = svr.GlobalVariables().Get("max_allowed_packet")
+	assert.Equal(t, "1024", v.String())
+	v, _ = svr.GlobalVariables().Get("wait_timeout")
+	assert.Equal(t, "0", v.String())
+	// Wrong type undoes the whole statement.
+	err = client.Exec("SET GLOBAL max_connections = 10, wait_timeout = 'x'")
+	assert.Equal(t, "Incorrect argument type to variable 'wait_timeout' (errno 1232) (sqlstate 42000)", err.Error())
+	v, _ = svr.GlobalVariables().Get("max_connections")
+	assert.Equal(t, "151", v.String())
+	// The global rejected by the handler is never set.
+	err = client.Exec("SET GLOBAL wait_timeout = 10")
+	assert.Equal(t, uint16(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR), err.(*sqldb.SQLError).Num)
+	v, _ = svr.GlobalVariables().Get("wait_timeout")
+	assert.Equal(t, "0", v.String())
+	err = client.Exec("SET version = 'x'")
+	assert.Equal(t, "Variable 'version' is a read only variable (errno 1238) (sqlstate HY000)", err.Error())
+
+	// SHOW VARIABLES.
+	qr, err = client.FetchAll("SHOW VARIABLES LIKE 'character\\_set\\_c%'", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "Variable_name", qr.Fields[0].Name)
+	assert.Equal(t, 2, len(qr.Rows))
+	assert.Equal(t, "character_set_client", qr.Rows[0][0].String())
+	assert.Equal(t, "utf8mb4", qr.Rows[0][1].String())
+	assert.Equal(t, "character_set_connection", qr.Rows[1][0].String())
+	qr, err = client.FetchAll("show global variables where Variable_name = 'wait_timeout' or variable_name like 'max_allowed%'", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(qr.Rows))
+	assert.Equal(t, "max_allowed_packet", qr.Rows[0][0].String())
+	assert.Equal(t, "1024", qr.Rows[0][1].String())
+	assert.Equal(t, "wait_timeout", qr.Rows[1][0].String())
+	assert.Equal(t, "0", qr.Rows[1][1].String())
+	qr, err = client.FetchAll("SHOW SESSION VARIABLES", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, len(svr.GlobalVariables().Names()), len(qr.Rows))
+	// The WHERE on the Value is left to the handler.
+	_, err = client.FetchAll("SHOW VARIABLES WHERE Value = '1'", -1)
+	assert.NotNil(t, err)
+
+	// The new session starts with the globals.
+	client2, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client2.Close()
+	qr, err = client2.FetchAll("SELECT @@wait_timeout, @@character_set_client", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "0", qr.Rows[0][0].String())
+	assert.Equal(t, "utf8mb4", qr.Rows[0][1].String())
+}
+
+func TestServerTimeZone(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSystemVariables(true)
+	svr.SetResultTimeZone(time.UTC)
+	for _, q := range []string{"SET time_zone = '+08:00'", "SET time_zone = '+15:00'", "SET time_zone = 'UTC'", "SET @@time_zone = 'Mars/Base'"} {
+		th.AddQuery(q, &sqltypes.Result{})
+	}
+	th.AddQuery("SELECT ts, dt FROM t1", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "ts", Type: querypb.Type_TIMESTAMP}, {Name: "dt", Type: querypb.Type_DATETIME}},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_TIMESTAMP, []byte("2018-01-02 03:04:05")), sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2018-01-02 03:04:05"))},
+			{sqltypes.NULL, sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2018-01-02 03:04:05"))},
+		},
+	})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	assert.Equal(t, time.Local, session.TimeZone())
+
+	assert.Nil(t, client.Exec("SET time_zone = '+08:00'"))
+	_, offset := time.Date(2018, 1, 2, 0, 0, 0, 0, session.TimeZone()).Zone()
+	assert.Equal(t, 8*3600, offset)
+	qr, err := client.FetchAll("SELECT @@time_zone, @@global.time_zone", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "+08:00", qr.Rows[0][0].String())
+	assert.Equal(t, "SYSTEM", qr.Rows[0][1].String())
+
+	// The TIMESTAMP is converted from the UTC to the session zone, the DATETIME is not.
+	for i := 0; i < 2; i++ {
+		qr, err = client.FetchAll("SELECT ts, dt FROM t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "2018-01-02 11:04:05", qr.Rows[0][0].String())
+		assert.Equal(t, "2018-01-02 03:04:05", qr.Rows[0][1].String())
+		assert.True(t, qr.Rows[1][0].IsNull())
+	}
+
+	assert.Nil(t, client.Exec("SET time_zone = 'UTC'"))
+	assert.Equal(t, time.UTC, session.TimeZone())
+	qr, err = client.FetchAll("SELECT ts, dt FROM t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "2018-01-02 03:04:05", qr.Rows[0][0].String())
+
+	// The unknown zone is refused and the zone is kept.
+	for _, q := range []string{"SET time_zone = '+15:00'", "SET @@time_zone = 'Mars/Base'"} {
+		err = client.Exec(q)
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(sqldb.ER_UNKNOWN_TIME_ZONE), err.(*sqldb.SQLError).Num)
+	}
+	assert.Equal(t, time.UTC, session.TimeZone())
+}
+
+func TestServerCharsetConversion(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSystemVariables(true)
+	th.AddQuery("SELECT name, data FROM t1 WHERE name = 'café'", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "name", Type: querypb.Type_VARCHAR}, {Name: "data", Type: querypb.Type_VARBINARY, Charset: sqldb.CharacterSetBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar("café €"), sqltypes.NewVarBinary("café")}},
+	})
+	th.AddQuery("SET NAMES utf8", &sqltypes.Result{})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "latin1")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	qr, err := client.FetchAll("SELECT @@character_set_client, @@character_set_results", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "latin1", qr.Rows[0][0].String())
+	assert.Equal(t, "latin1", qr.Rows[0][1].String())
+
+	// The latin1 query is transcoded to the UTF-8 and the text columns back.
+	qr, err = client.FetchAll("SELECT name, data FROM t1 WHERE name = 'caf\xe9'", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("caf\xe9 \x80"), qr.Rows[0][0].Raw())
+	assert.Equal(t, []byte("café"), qr.Rows[0][1].Raw())
+
+	// The bytes pass through after SET NAMES utf8.
+	assert.Nil(t, client.Exec("SET NAMES utf8"))
+	qr, err = client.FetchAll("SELECT name, data FROM t1 WHERE name = 'café'", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "café €", qr.Rows[0][0].String())
+
+	// The invalid ascii query is refused.
+	client2, err := NewConn("mock", "mock", svr.Addr(), "", "ascii")
+	assert.Nil(t, err)
+	defer client2.Close()
+	_, err = client2.FetchAll("SELECT name, data FROM t1 WHERE name = 'caf\xe9'", -1)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint16(sqldb.ER_INVALID_CHARACTER_STRING), err.(*sqldb.SQLError).Num)
+}
+
+func TestServerCollations(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSystemVariables(true)
+	for _, q := range []string{
+		"SET NAMES latin1",
+		"SET NAMES utf8mb4 COLLATE utf8mb4_unicode_ci",
+		"SET NAMES klingon",
+		"SET collation_connection = 'utf8_klingon_ci'",
+		"SET character_set_results = NULL",
+	} {
+		th.AddQuery(q, &sqltypes.Result{})
+	}
+
+	// The collation name is accepted as the charset of the handshake.
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "utf8mb4_unicode_ci")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	assert.Equal(t, uint8(224), session.Charset())
+
+	qr, err := client.FetchAll("SELECT @@character_set_connection, @@collation_connection, @@wait_timeout", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "utf8mb4", qr.Rows[0][0].String())
+	assert.Equal(t, "utf8mb4_unicode_ci", qr.Rows[0][1].String())
+	// The field metadata carries the collation_connection for the text and the binary for the numbers.
+	assert.Equal(t, uint32(224), qr.Fields[0].Charset)
+	assert.Equal(t, uint32(sqldb.CharacterSetBinary), qr.Fields[2].Charset)
+
+	// SET NAMES picks the default collation of the charset.
+	assert.Nil(t, client.SetNames("LATIN1", ""))
+	v, _ := session.SystemVariable("collation_connection")
+	assert.Equal(t, "latin1_swedish_ci", v.String())
+	assert.Nil(t, client.SetNames("utf8mb4", "utf8mb4_unicode_ci"))
+	v, _ = session.SystemVariable("collation_connection")
+	assert.Equal(t, "utf8mb4_unicode_ci", v.String())
+	v, _ = session.SystemVariable("character_set_results")
+	assert.Equal(t, "utf8mb4", v.String())
+
+	// The unknown names are refused by the server.
+	err = client.Exec("SET NAMES klingon")
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_CHARACTER_SET), err.(*sqldb.SQLError).Num)
+	err = client.Exec("SET collation_connection = 'utf8_klingon_ci'")
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_COLLATION), err.(*sqldb.SQLError).Num)
+	v, _ = session.SystemVariable("character_set_client")
+	assert.Equal(t, "utf8mb4", v.String())
+
+	// And by the client before sent.
+	assert.Equal(t, "driver.set.names.unknown.charset[klingon]", client.SetNames("klingon", "").Error())
+	assert.Equal(t, "driver.set.names.unknown.collation[utf8_klingon_ci]", client.SetNames("utf8", "utf8_klingon_ci").Error())
+	assert.Equal(t, "driver.set.names.collation[latin1_bin].not.valid.for.charset[utf8]", client.SetNames("utf8", "latin1_bin").Error())
+
+	assert.Nil(t, client.Exec("SET character_set_results = NULL"))
+	v, ok := session.SystemVariable("character_set_results")
+	assert.True(t, ok)
+	assert.True(t, v.IsNull())
+}
+
+func TestServerClientFoundRows(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("UPDATE t1 SET a=1", &sqltypes.Result{RowsAffected: 1, RowsMatched: 3})
+	th.AddQuery("INSERT INTO t1 VALUES(1)", &sqltypes.Result{RowsAffected: 1})
+
+	tests := []struct {
+		dsn     string
+		updated uint64
+	}{
+		{"mock:mock@tcp(%s)/", 1},
+		{"mock:mock@tcp(%s)/?clientFoundRows=true", 3},
+	}
+	for _, test := range tests {
+		client, err := NewConnWithDSN(fmt.Sprintf(test.dsn, svr.Addr()))
+		assert.Nil(t, err)
+
+		qr, err := client.FetchAll("UPDATE t1 SET a=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, test.updated, qr.RowsAffected, test.dsn)
+
+		// The handler doesn't report the matched rows.
+		qr, err = client.FetchAll("INSERT INTO t1 VALUES(1)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected, test.dsn)
+		client.Close()
+	}
+}
+
+func TestServerSQLMode(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	for _, query := range []string{
+		"SET sql_mode='ANSI_QUOTES,NO_BACKSLASH_ESCAPES'",
+		"SET SESSION sql_mode = DEFAULT",
+		"SET sql_mode=CONCAT(@@sql_mode, ',ANSI')",
+		"SET sql_mode=4",
+		"SET sql_mode='ANSI_QUOTE'",
+	} {
+		th.AddQuery(query, &sqltypes.Result{})
+	}
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	assert.Equal(t, sqlparser.DefaultSQLMode, session.SQLMode())
+
+	assert.Nil(t, client.Exec("SET sql_mode='ANSI_QUOTES,NO_BACKSLASH_ESCAPES'"))
+	assert.Equal(t, sqlparser.ModeANSIQuotes|sqlparser.ModeNoBackslashEscapes, session.SQLMode())
+	assert.True(t, client.Status()&sqldb.SERVER_STATUS_NO_BACKSLASH_ESCAPES > 0)
+	stmt, err := session.Parse(`SELECT "a" FROM t`)
+	assert.Nil(t, err)
+	assert.Equal(t, "select a from t", sqlparser.String(stmt))
+
+	// The expression is left to the handler.
+	assert.Nil(t, client.Exec("SET sql_mode=CONCAT(@@sql_mode, ',ANSI')"))
+	assert.Equal(t, sqlparser.ModeANSIQuotes|sqlparser.ModeNoBackslashEscapes, session.SQLMode())
+
+	assert.Nil(t, client.Exec("SET SESSION sql_mode = DEFAULT"))
+	assert.Equal(t, sqlparser.DefaultSQLMode, session.SQLMode())
+	assert.Equal(t, uint16(0), client.Status()&sqldb.SERVER_STATUS_NO_BACKSLASH_ESCAPES)
+	assert.Nil(t, client.Exec("SET sql_mode=4"))
+	assert.Equal(t, sqlparser.ModeANSIQuotes, session.SQLMode())
+
+	err = client.Exec("SET sql_mode='ANSI_QUOTE'")
+	assert.Equal(t, uint16(sqldb.ER_WRONG_VALUE_FOR_VAR), err.(*sqldb.SQLError).Num)
+	assert.Equal(t, sqlparser.ModeANSIQuotes, session.SQLMode())
+}
+
+func TestServerCancelOnDisconnect(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+	th.AddQueryDelay("SELECT SLEEP(10)", &sqltypes.Result{}, 10000)
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+
+	// The statements keep working with the watch.
+	for i := 0; i < 3; i++ {
+		_, err = client.FetchAll("SELECT 1", -1)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, context.Background(), session.Context())
+
+	go client.Query("SELECT SLEEP(10)")
+	ctx := session.Context()
+	for i := 0; ctx == context.Background() && i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		ctx = session.Context()
+	}
+	assert.Nil(t, ctx.Err())
+
+	// Drop the socket without the COM_QUIT, like a killed client.
+	client.netConn.Close()
+	select {
+	case <-ctx.Done():
+		assert.Equal(t, context.Canceled, ctx.Err())
+	case <-time.After(2 * time.Second):
+		t.Fatal("the statement context is not canceled after the client closed")
+	}
+}
+
+func TestServerSessionMemoryLimit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSessionMemoryLimit(1024)
+	assert.Equal(t, int64(1024), svr.SessionMemoryLimit())
+
+	fields := []*querypb.Field{{Name: "a", Type: querypb.Type_VARCHAR}}
+	th.AddQuery("SELECT small", &sqltypes.Result{
+		Fields: fields,
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar(strings.Repeat("x", 512))}},
+	})
+	th.AddQuery("SELECT big", &sqltypes.Result{
+		Fields: fields,
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarChar(strings.Repeat("x", 2048))}},
+	})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The result exceeds the limit.
+	{
+		_, err := client.FetchAll("SELECT big", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(sqldb.ER_OUT_OF_RESOURCES), err.(*sqldb.SQLError).Num)
+	}
+
+	// The incoming query exceeds the limit.
+	{
+		_, err := client.FetchAll("SELECT "+strings.Repeat("a", 2048), -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, uint16(sqldb.ER_OUT_OF_RESOURCES), err.(*sqldb.SQLError).Num)
+	}
+
+	// The session keeps working under the limit.
+	{
+		qr, err := client.FetchAll("SELECT small", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+}
+
+func TestServerMaxExecutionTime(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SET max_execution_time = 50", &sqltypes.Result{})
+	th.AddQuery("SET max_execution_time = 0", &sqltypes.Result{})
+	th.AddQueryDelay("SELECT /*+ MAX_EXECUTION_TIME(50) */ SLEEP(10)", &sqltypes.Result{}, 10000)
+	th.AddQueryDelay("SELECT /*+ MAX_EXECUTION_TIME(5000) */ SLEEP(0.2)", &sqltypes.Result{}, 200)
+	th.AddQueryDelay("SELECT SLEEP(10)", &sqltypes.Result{}, 10000)
+	th.AddQueryDelay("SELECT SLEEP(0.2)", &sqltypes.Result{}, 200)
+	th.AddQueryDelay("DO SLEEP(0.2)", &sqltypes.Result{}, 200)
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	timedOut := func(query string) {
+		start := time.Now()
+		_, err := client.FetchAll(query, -1)
+		assert.NotNil(t, err, query)
+		assert.Equal(t, uint16(sqldb.ER_QUERY_TIMEOUT), err.(*sqldb.SQLError).Num, query)
+		assert.True(t, time.Since(start) < 5*time.Second, query)
+	}
+
+	// The hint.
+	timedOut("SELECT /*+ MAX_EXECUTION_TIME(50) */ SLEEP(10)")
+
+	// The session variable, the hint overrides it and the non-SELECT are not limited.
+	_, err = client.FetchAll("SET max_execution_time = 50", -1)
+	assert.Nil(t, err)
+	timedOut("SELECT SLEEP(10)")
+	_, err = client.FetchAll("SELECT /*+ MAX_EXECUTION_TIME(5000) */ SLEEP(0.2)", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("DO SLEEP(0.2)", -1)
+	assert.Nil(t, err)
+
+	_, err = client.FetchAll("SET max_execution_time = 0", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("SELECT SLEEP(0.2)", -1)
+	assert.Nil(t, err)
+}
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestServerTrace(t *testing.T) {
+	out := &lockedBuffer{}
+	log := xlog.NewXLog(out, xlog.Level(xlog.INFO))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetTrace(true)
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	}
+	th.AddQuery("CREATE USER u IDENTIFIED BY 'secret'", &sqltypes.Result{})
+	th.AddQuery("SELECT id FROM t1", result)
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	assert.True(t, session.Tracing())
+
+	_, err = client.FetchAll("create user u identified by 'secret'", -1)
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "packet.trace.write.seq[0]")
+	assert.Contains(t, out.String(), "greeting")
+	assert.Contains(t, out.String(), "packet.trace.read.seq[1]")
+	assert.Contains(t, out.String(), "COM_QUERY")
+	assert.NotContains(t, out.String(), "secret")
+
+	// The trace is off at runtime.
+	session.SetTrace(false)
+	assert.False(t, session.Tracing())
+	_, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.Nil(t, err)
+	assert.NotContains(t, out.String(), "t1")
+}
+
+func TestServerCapture(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	out := &lockedBuffer{}
+	w, err := packet.NewPcapWriter(out)
+	assert.Nil(t, err)
+	svr.SetCapture(w)
+
+	th.AddQuery("SELECT id FROM t1", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))}},
+	})
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("SELECT id FROM t1", -1)
+	assert.Nil(t, err)
+
+	assert.Nil(t, w.Err())
+	assert.Contains(t, out.String(), "SELECT id FROM t1")
+	assert.Contains(t, out.String(), proto.DefaultAuthPluginName)
+}
+
+// panicHandler writes the fields of the 'panic after fields' then panics.
+type panicHandler struct {
+	*TestHandler
+}
+
+func (h *panicHandler) ComQuery(session *Session, query string, callback func(*sqltypes.Result) error) error {
+	if query != "panic after fields" {
+		return h.TestHandler.ComQuery(session, query, callback)
+	}
+	fields := []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}}
+	if err := callback(&sqltypes.Result{Fields: fields, State: sqltypes.RState_Fields}); err != nil {
+		return err
+	}
+	panic("mock.panic.after.fields")
+}
+
+func TestServerPanicAfterResult(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, &panicHandler{th})
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQueryPanic("PANIC")
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Nothing was sent, the panic is answered by the ERR.
+	_, err = client.FetchAll("PANIC", -1)
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_INTERNAL_ERROR), num)
+	assert.Nil(t, client.Ping())
+
+	// The fields were sent, the session is closed instead of the ERR in the middle of the result.
+	_, err = client.FetchAll("panic after fields", -1)
+	assert.NotNil(t, err)
+	num, _ = sqldb.ErrorNum(err)
+	assert.NotEqual(t, uint16(sqldb.ER_INTERNAL_ERROR), num)
+	assert.NotNil(t, client.Ping())
+}
+
+// flakyListener fails the first accepts with the temporary error like the EMFILE.
+type flakyListener struct {
+	net.Listener
+	errs int32
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "accept: too many open files" }
+func (temporaryError) Timeout() bool   { return false }
+func (temporaryError) Temporary() bool { return true }
+
+func (l *flakyListener) Accept() (net.Conn, error) {
+	if atomic.AddInt32(&l.errs, -1) >= 0 {
+		return nil, temporaryError{}
+	}
+	return l.Listener.Accept()
+}
+
+func TestServerAcceptTemporaryError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	var svr *Listener
+	var err error
+	for i := 0; i < 5; i++ {
+		if svr, err = NewListenerWithConfig(&ListenerConfig{Log: log, Address: fmt.Sprintf("127.0.0.1:%d", randomPort(10000, 20000))}, NewTestHandler(log)); err == nil {
+			break
+		}
+	}
+	assert.Nil(t, err)
+	svr.listener = &flakyListener{Listener: svr.listener, errs: 3}
+	done := make(chan struct{})
+	go func() {
+		svr.Accept()
+		close(done)
+	}()
+
+	// The listener goes on after the errors.
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	assert.Nil(t, client.Ping())
+	client.Close()
+
+	svr.Close()
+	<-done
+}
@@ -10,20 +10,28 @@
 package driver
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/XeLabs/go-mysqlstack/packet"
 	"github.com/XeLabs/go-mysqlstack/proto"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
 	"github.com/XeLabs/go-mysqlstack/xlog"
 
 	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 type Session struct {
+	// The commands read and the Com_ counters of the statements, they're first for the 64-bit alignment of the atomics.
+	commands uint64
+	coms     [comCount]uint64
+
 	id       uint32
 	mu       sync.RWMutex
 	log      *xlog.Log
@@ -32,25 +40,114 @@ type Session struct {
 	auth     *proto.Auth
 	packets  *packet.Packets
 	greeting *proto.Greeting
+
+	// The session transaction characteristics set by the SET SESSION TRANSACTION.
+	txIsolation string
+	txReadOnly  bool
+	sqlMode     sqlparser.SQLMode
+
+	// The warnings of the last statement.
+	warnings     []Warning
+	warningCount uint16
+
+	// The user variables by the lowercased names.
+	userVars map[string]sqltypes.Value
+
+	// The LAST_INSERT_ID() and ROW_COUNT().
+	lastInsertID uint64
+	rowCount     int64
+
+	// The global system variables of the listener and the ones set by the SET SESSION.
+	globals *SystemVariables
+	sysVars map[string]sqltypes.Value
+
+	// The location of the time_zone and the zone of the TIMESTAMP values the handler returns.
+	timeZone       *time.Location
+	timeZoneName   string
+	resultTimeZone *time.Location
+
+	// The connection watched while the handler runs, the output the packets are written to
+	// and the context of the running statement, endStmt ends it if the handler panicked.
+	watcher *watchedConn
+	output  *outputConn
+	ctx     context.Context
+	cancel  context.CancelFunc
+	endStmt func() error
+
+	// The memory limit of the session, the bytes accounted to the running statement and the error it was killed by.
+	memLimit int64
+	memUsed  int64
+	memErr   error
+	// longDataMem is the bytes of the COM_STMT_SEND_LONG_DATA kept until the executions, it's accounted to every statement.
+	longDataMem int64
+
+	// The translator of the errors sent.
+	translator ErrorTranslator
+
+	// The result of the running command was written, the progress can't be reported
+	// and the panic of the handler can't be answered by the ERR.
+	resultWritten bool
+
+	// The query attributes of the running command sent by the CLIENT_QUERY_ATTRIBUTES client.
+	queryAttrs []proto.QueryAttribute
+
+	// The prepared statements by the ids and the last id allocated.
+	stmts      map[uint32]*Statement
+	lastStmtID uint32
+
+	// The TLS state if the session switched to TLS by the SSLRequest.
+	tlsState *tls.ConnectionState
+
+	// The user the session acts as and the schemas it can use, set by the AuthDelegate.
+	effectiveUser string
+	schemas       map[string]bool
+
+	// The password of the user is expired, only the statements changing it run.
+	sandbox bool
+
+	// The session was accepted by the retained password of the Credentials.
+	retainedPassword bool
+
+	// The flush policy of the rows and the rows and bytes appended since the last flush.
+	flushPolicy  FlushPolicy
+	pendingRows  int
+	pendingBytes int
 }
 
 func newSession(log *xlog.Log, ID uint32, conn net.Conn) *Session {
+	watcher := newWatchedConn(conn)
+	output := newOutputConn(watcher)
 	return &Session{
 		id:       ID,
 		log:      log,
-		conn:     conn,
+		conn:     watcher,
 		auth:     proto.NewAuth(),
 		greeting: proto.NewGreeting(ID),
-		packets:  packet.NewPackets(conn),
+		packets:  packet.NewPackets(output),
+		sqlMode:  sqlparser.DefaultSQLMode,
+		globals:  NewSystemVariables(),
+		watcher:  watcher,
+		output:   output,
 	}
 }
 
 func (s *Session) writeErrFromError(err error) error {
-	if se, ok := err.(*sqldb.SQLError); ok {
-		return s.packets.WriteERR(se.Num, se.State, "%v", se.Message)
+	se := sessionError(s.translateError(err))
+	return s.packets.WriteERR(se.Num, se.State, "%v", se.Message)
+}
+
+// capabilities returns the capabilities both the client and the server support.
+func (s *Session) capabilities() uint32 {
+	return s.auth.ClientFlags() & s.greeting.Capability
+}
+
+// affectedRows returns the affected rows for the OK packet,
+// the CLIENT_FOUND_ROWS clients get the matched rows if the handler reports them.
+func (s *Session) affectedRows(result *sqltypes.Result) uint64 {
+	if s.capabilities()&sqldb.CLIENT_FOUND_ROWS > 0 && result.RowsMatched > 0 {
+		return result.RowsMatched
 	}
-	unknow := sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "%v", err)
-	return s.packets.WriteERR(unknow.Num, unknow.State, unknow.Message)
+	return result.RowsAffected
 }
 
 func (s *Session) writeFields(result *sqltypes.Result) error {
@@ -59,26 +156,57 @@ func (s *Session) writeFields(result *sqltypes.Result) error {
 		return err
 	}
 
-	if (s.auth.ClientFlags() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
-		if err := s.packets.AppendEOF(); err != nil {
+	if (s.capabilities() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		if err := s.packets.AppendEOFWithStatus(s.Status(), 0); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (s *Session) writeRows(result *sqltypes.Result) error {
+func (s *Session) writeRows(result *sqltypes.Result, binary bool) error {
 	// 2. Append rows.
+	encode := s.resultsEncoder(result.Fields)
+	policy := s.FlushPolicy()
+	if binary {
+		return s.writeBinaryRows(result, policy, encode)
+	}
 	for _, row := range result.Rows {
 		rowBuf := common.NewBuffer(16)
-		for _, val := range row {
-			if val.IsNull() {
+		for i, val := range row {
+			switch {
+			case val.IsNull():
 				rowBuf.WriteLenEncodeNUL()
-			} else {
+			case encode != nil:
+				rowBuf.WriteLenEncodeBytes(encode(i, val.Raw()))
+			default:
 				rowBuf.WriteLenEncodeBytes(val.Raw())
 			}
 		}
-		if err := s.packets.Append(rowBuf.Datas()); err != nil {
+		if err := s.appendRow(policy, rowBuf.Datas()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBinaryRows appends the rows of the binary protocol resultset, the texts are encoded by the encode as the text rows.
+func (s *Session) writeBinaryRows(result *sqltypes.Result, policy FlushPolicy, encode func(i int, val []byte) []byte) error {
+	for _, row := range result.Rows {
+		if encode != nil {
+			encoded := make([]sqltypes.Value, len(row))
+			for i, val := range row {
+				if encoded[i] = val; !val.IsNull() {
+					encoded[i] = sqltypes.MakeTrusted(val.Type(), encode(i, val.Raw()))
+				}
+			}
+			row = encoded
+		}
+		data, err := proto.PackBinaryRow(result.Fields, row)
+		if err != nil {
+			return err
+		}
+		if err := s.appendRow(policy, data); err != nil {
 			return err
 		}
 	}
@@ -87,28 +215,68 @@ func (s *Session) writeRows(result *sqltypes.Result) error {
 
 func (s *Session) writeFinish(result *sqltypes.Result) error {
 	// 3. Write EOF.
-	if (s.auth.ClientFlags() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
-		if err := s.packets.AppendEOF(); err != nil {
+	if (s.capabilities() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		if err := s.packets.AppendEOFWithStatus(s.Status(), s.warningsOf(result)); err != nil {
 			return err
 		}
 	} else {
-		if err := s.packets.AppendOKWithEOFHeader(result.RowsAffected, result.InsertID, s.greeting.Status(), result.Warnings); err != nil {
+		if err := s.packets.AppendOKPacketWithEOFHeader(s.okPacket(result)); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// okPacket returns the OK packet of the result, the session state changes are sent to the CLIENT_SESSION_TRACK clients only.
+func (s *Session) okPacket(result *sqltypes.Result) *proto.OK {
+	ok := &proto.OK{
+		AffectedRows: s.affectedRows(result),
+		LastInsertID: result.InsertID,
+		StatusFlags:  (s.Status() | result.StatusFlags) &^ sqldb.SERVER_SESSION_STATE_CHANGED,
+		Warnings:     s.warningsOf(result),
+		Info:         result.Info,
+	}
+	if len(result.SessionStateChanges) > 0 && s.capabilities()&sqldb.CLIENT_SESSION_TRACK > 0 {
+		ok.StatusFlags |= sqldb.SERVER_SESSION_STATE_CHANGED
+		ok.SessionStateChanges = result.SessionStateChanges
+	}
+	return ok
+}
+
 func (s *Session) flush() error {
 	// 4. Write to stream.
+	s.pendingRows, s.pendingBytes = 0, 0
 	return s.packets.Flush()
 }
 
 func (s *Session) writeResult(result *sqltypes.Result) error {
+	return s.writeResultAs(result, false)
+}
+
+// writeBinaryResult writes the result of the prepared statement, the rows are the binary protocol ones.
+func (s *Session) writeBinaryResult(result *sqltypes.Result) error {
+	return s.writeResultAs(result, true)
+}
+
+func (s *Session) writeResultAs(result *sqltypes.Result, binary bool) error {
+	if err := s.interruptError(); err != nil {
+		return err
+	}
+	size := resultSize(result)
+	if err := s.AllocMemory(size); err != nil {
+		return err
+	}
+	defer s.FreeMemory(size)
+
+	s.mu.Lock()
+	s.resultWritten = true
+	s.mu.Unlock()
+	s.trackResult(result)
+	result = s.convertTimestamps(result)
 	if len(result.Fields) == 0 {
 		if result.State == sqltypes.RState_None {
 			// This is just an INSERT result, send an OK packet.
-			return s.packets.WriteOK(result.RowsAffected, result.InsertID, s.greeting.Status(), result.Warnings)
+			return s.packets.WriteOKPacket(s.okPacket(result))
 		} else {
 			return fmt.Errorf("unexpected: result.without.no.fields.but.has.rows.result:%+v", result)
 		}
@@ -119,7 +287,7 @@ func (s *Session) writeResult(result *sqltypes.Result) error {
 		if err := s.writeFields(result); err != nil {
 			return err
 		}
-		if err := s.writeRows(result); err != nil {
+		if err := s.writeRows(result, binary); err != nil {
 			return err
 		}
 		if err := s.writeFinish(result); err != nil {
@@ -130,7 +298,7 @@ func (s *Session) writeResult(result *sqltypes.Result) error {
 			return err
 		}
 	case sqltypes.RState_Rows:
-		if err := s.writeRows(result); err != nil {
+		if err := s.writeRows(result, binary); err != nil {
 			return err
 		}
 	case sqltypes.RState_Finished:
@@ -156,6 +324,47 @@ func (s *Session) ID() uint32 {
 	return s.id
 }
 
+// SetTrace logs the packets of the session with hex dumps to the session log, the credentials are scrubbed.
+// It can be toggled at any time, like from another session to debug an interop issue.
+func (s *Session) SetTrace(on bool) {
+	if on {
+		s.packets.SetTrace(s.log, packet.DefaultTraceDumpSize)
+		return
+	}
+	s.packets.SetTrace(nil, 0)
+}
+
+// Tracing returns true if the packets of the session are traced.
+func (s *Session) Tracing() bool {
+	return s.packets.Tracing()
+}
+
+// startTLS switches the session to TLS after the SSLRequest, the handshake response is read on it.
+func (s *Session) startTLS(cfg *tls.Config) error {
+	return s.packets.Upgrade(func(conn net.Conn) (net.Conn, error) {
+		tc := tls.Server(conn, cfg)
+		if err := tc.Handshake(); err != nil {
+			return nil, err
+		}
+		state := tc.ConnectionState()
+		s.mu.Lock()
+		s.tlsState = &state
+		s.mu.Unlock()
+		return tc, nil
+	})
+}
+
+// TLSState returns the TLS state of the session, nil if the session isn't encrypted.
+func (s *Session) TLSState() *tls.ConnectionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.tlsState == nil {
+		return nil
+	}
+	state := *s.tlsState
+	return &state
+}
+
 func (s *Session) Addr() string {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -166,6 +375,15 @@ func (s *Session) Addr() string {
 	}
 }
 
+// host returns the host of the client address.
+func (s *Session) host() string {
+	addr := s.Addr()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
 func (s *Session) SetSchema(schema string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -196,8 +414,132 @@ func (s *Session) Scramble() []byte {
 	return s.auth.AuthResponse()
 }
 
+// Status returns the status flags carried by the OK and EOF packets,
+// the initial ones are the GreetingConfig's.
+// The SERVER_STATUS_NO_BACKSLASH_ESCAPES follows the sql_mode, the clients escape the strings by it.
+func (s *Session) Status() uint16 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := s.greeting.Status()
+	if s.sqlMode.Has(sqlparser.ModeNoBackslashEscapes) {
+		status |= sqldb.SERVER_STATUS_NO_BACKSLASH_ESCAPES
+	} else {
+		status &^= sqldb.SERVER_STATUS_NO_BACKSLASH_ESCAPES
+	}
+	return status
+}
+
+// SetInTransaction reports the transaction state of the session by the SERVER_STATUS_IN_TRANS,
+// the clients and the pools rely on it to decide the connection reuse.
+func (s *Session) SetInTransaction(in bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.greeting.Status()
+	if in {
+		status |= sqldb.SERVER_STATUS_IN_TRANS
+	} else {
+		status &^= sqldb.SERVER_STATUS_IN_TRANS
+	}
+	s.greeting.SetStatus(status)
+}
+
+// InTransaction checks the SERVER_STATUS_IN_TRANS of the session.
+func (s *Session) InTransaction() bool {
+	return s.Status()&sqldb.SERVER_STATUS_IN_TRANS > 0
+}
+
+// SetAutocommit reports the autocommit mode of the session by the SERVER_STATUS_AUTOCOMMIT,
+// enabling it commits the open transaction as MySQL does.
+func (s *Session) SetAutocommit(on bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.greeting.Status()
+	if on {
+		status |= sqldb.SERVER_STATUS_AUTOCOMMIT
+		status &^= sqldb.SERVER_STATUS_IN_TRANS
+	} else {
+		status &^= sqldb.SERVER_STATUS_AUTOCOMMIT
+	}
+	s.greeting.SetStatus(status)
+}
+
+// Autocommit checks the SERVER_STATUS_AUTOCOMMIT of the session,
+// the proxies pin the backend connection to the session while it's off.
+func (s *Session) Autocommit() bool {
+	return s.Status()&sqldb.SERVER_STATUS_AUTOCOMMIT > 0
+}
+
+// TransactionIsolation returns the isolation level set by the SET SESSION TRANSACTION ISOLATION LEVEL,
+// like 'READ-COMMITTED', empty if the client never sets it.
+func (s *Session) TransactionIsolation() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.txIsolation
+}
+
+// TransactionReadOnly checks the session transactions are READ ONLY.
+func (s *Session) TransactionReadOnly() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.txReadOnly
+}
+
+// SQLMode returns the sql_mode of the session set by the SET sql_mode,
+// it's sqlparser.DefaultSQLMode initially.
+func (s *Session) SQLMode() sqlparser.SQLMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sqlMode
+}
+
+// SetSQLMode sets the sql_mode of the session.
+func (s *Session) SetSQLMode(mode sqlparser.SQLMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sqlMode = mode
+}
+
+// Parse parses the query by the sql_mode of the session.
+func (s *Session) Parse(query string) (sqlparser.Statement, error) {
+	return sqlparser.ParseWithSQLMode(query, s.SQLMode())
+}
+
 func (s *Session) Charset() uint8 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.auth.Charset()
 }
+
+// ConnectAttrs returns the connection attributes sent by the client in the handshake.
+func (s *Session) ConnectAttrs() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.auth.ConnectAttrs()
+}
+
+// QueryAttributes returns the query attributes the client attached to the running COM_QUERY or COM_STMT_EXECUTE,
+// they are sent by the clients of the CLIENT_QUERY_ATTRIBUTES only.
+func (s *Session) QueryAttributes() []proto.QueryAttribute {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queryAttrs
+}
+
+// QueryAttribute returns the value of the query attribute of the running command by the name,
+// false if the client didn't attach it.
+func (s *Session) QueryAttribute(name string) (sqltypes.Value, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, attr := range s.queryAttrs {
+		if attr.Name == name {
+			return attr.Value, true
+		}
+	}
+	return sqltypes.Value{}, false
+}
+
+func (s *Session) setQueryAttributes(attrs []proto.QueryAttribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queryAttrs = attrs
+}
@@ -10,10 +10,17 @@
 package driver
 
 import (
+	"fmt"
+	"os"
+	"strconv"
 	"testing"
 
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
 	"github.com/XeLabs/go-mysqlstack/xlog"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 func TestSession(t *testing.T) {
@@ -54,9 +61,96 @@ func TestSession(t *testing.T) {
 
 		// charset.
 		{
-			want := uint8(0x21)
+			want := uint8(sqldb.DefaultCollation)
 			got := session1.Charset()
 			assert.Equal(t, want, got)
 		}
 	}
 }
+
+func TestSessionConnectAttrs(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	client, err := NewConnWithDSN(fmt.Sprintf("mock:mock@tcp(%s)/test?connectionAttributes=program_name:tester,tag:t1", address))
+	assert.Nil(t, err)
+	defer client.Close()
+
+	th.mu.Lock()
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	th.mu.Unlock()
+
+	attrs := session.ConnectAttrs()
+	assert.Equal(t, "tester", attrs["program_name"])
+	assert.Equal(t, "t1", attrs["tag"])
+	assert.Equal(t, ClientName, attrs["_client_name"])
+	assert.Equal(t, strconv.Itoa(os.Getpid()), attrs["_pid"])
+}
+
+// attrsHandler records the query attributes the handler sees.
+type attrsHandler struct {
+	*TestHandler
+	attrs []proto.QueryAttribute
+}
+
+func (h *attrsHandler) ComQuery(s *Session, query string, callback func(*sqltypes.Result) error) error {
+	h.attrs = s.QueryAttributes()
+	return h.TestHandler.ComQuery(s, query, callback)
+}
+
+func TestSessionQueryAttributes(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &attrsHandler{TestHandler: NewTestHandler(log)}
+	th.AddQueryPattern("select .*", &sqltypes.Result{})
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	address := svr.Addr()
+
+	attrs := []proto.QueryAttribute{
+		{Name: "trace_id", Value: sqltypes.NewVarChar("abc")},
+		{Name: "shard", Value: sqltypes.NewInt64(3)},
+	}
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{address}, QueryAttributes: true})
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The COM_QUERY.
+	rows, err := client.QueryWithAttributes("select 1;", attrs)
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, attrs, th.attrs)
+	_, err = client.FetchAll("select 2", -1)
+	assert.Nil(t, err)
+	assert.Nil(t, th.attrs)
+
+	// The COM_STMT_EXECUTE.
+	stmt, err := client.Prepare("select ?")
+	assert.Nil(t, err)
+	rows, err = stmt.QueryWithAttributes(attrs, sqltypes.NewInt64(1))
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, attrs, th.attrs)
+	stmt, err = client.Prepare("select 1")
+	assert.Nil(t, err)
+	rows, err = stmt.QueryWithAttributes(attrs[:1])
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	assert.Equal(t, attrs[:1], th.attrs)
+
+	// The attributes are dropped if the client doesn't ask the CLIENT_QUERY_ATTRIBUTES.
+	plain, err := NewConn("mock", "mock", address, "", "")
+	assert.Nil(t, err)
+	defer plain.Close()
+	rows, err = plain.QueryWithAttributes("select 1", attrs)
+	assert.Nil(t, err)
+	assert.Nil(t, rows.Close())
+	assert.Nil(t, th.attrs)
+}
@@ -0,0 +1,130 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+func errWrongValueForVar(v *sqlparser.SetVar) error {
+	return sqldb.NewSQLError(sqldb.ER_WRONG_VALUE_FOR_VAR, "Variable '%s' can't be set to the value of '%s'", v.Name, v.Value)
+}
+
+// trackSetVars applies the session state changes of the SET statement before the handler executes it,
+// so the OK packet carries the new status flags. The returned undo restores the state if the handler fails.
+// The SET GLOBAL assignments are only checked here, the returned apply sets them once the handler succeeded,
+// the other sessions never see a global the handler rejected.
+func (s *Session) trackSetVars(query string) (undo func(), apply func(), err error) {
+	vars, err := sqlparser.ParseSetVars(query)
+	if err != nil {
+		// Leave the statement to the handler.
+		return func() {}, func() {}, nil
+	}
+
+	userVars, sysVars := s.UserVars(), s.systemVariables()
+	var applies []func()
+	s.mu.RLock()
+	status, isolation, readOnly, sqlMode := s.greeting.Status(), s.txIsolation, s.txReadOnly, s.sqlMode
+	s.mu.RUnlock()
+	undo = func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.greeting.SetStatus(status)
+		s.txIsolation, s.txReadOnly, s.sqlMode = isolation, readOnly, sqlMode
+		s.userVars, s.sysVars = userVars, sysVars
+	}
+
+	for _, v := range vars {
+		switch v.Scope {
+		case sqlparser.SetScopeUser:
+			s.setUserVar(v)
+		case sqlparser.SetScopeSession:
+			if err := s.setVar(v); err != nil {
+				undo()
+				return nil, nil, err
+			}
+		case sqlparser.SetScopeGlobal:
+			set, err := s.globalSysVar(v)
+			if err != nil {
+				undo()
+				return nil, nil, err
+			}
+			applies = append(applies, set)
+		}
+	}
+	apply = func() {
+		for _, set := range applies {
+			set()
+		}
+	}
+	return undo, apply, nil
+}
+
+// setVar applies one session assignment, the variables not tracked by the session state go to the session system variables.
+func (s *Session) setVar(v *sqlparser.SetVar) error {
+	switch v.Name {
+	case "autocommit":
+		on, err := v.Bool()
+		if err != nil {
+			return errWrongValueForVar(v)
+		}
+		s.SetAutocommit(on)
+	case "transaction_isolation", "tx_isolation":
+		s.mu.Lock()
+		s.txIsolation = strings.ToUpper(strings.Replace(v.Value, " ", "-", -1))
+		s.mu.Unlock()
+	case "transaction_read_only", "tx_read_only":
+		on, err := v.Bool()
+		if err != nil {
+			return errWrongValueForVar(v)
+		}
+		s.mu.Lock()
+		s.txReadOnly = on
+		s.mu.Unlock()
+	case "sql_mode":
+		mode, ok, err := sqlModeOf(v)
+		if err != nil {
+			return err
+		}
+		if ok {
+			s.SetSQLMode(mode)
+		}
+	default:
+		return s.setSessionSysVar(v)
+	}
+	return nil
+}
+
+// sqlModeOf returns the mode of the assignment, false if it's an expression can't be evaluated here.
+func sqlModeOf(v *sqlparser.SetVar) (sqlparser.SQLMode, bool, error) {
+	switch v.Kind {
+	case sqlparser.SetValueIdent:
+		if strings.EqualFold(v.Value, "default") {
+			return sqlparser.DefaultSQLMode, true, nil
+		}
+	case sqlparser.SetValueNumber:
+		n, err := strconv.ParseUint(v.Value, 10, 64)
+		if err != nil {
+			return 0, false, errWrongValueForVar(v)
+		}
+		return sqlparser.SQLMode(n), true, nil
+	case sqlparser.SetValueExpr:
+		return 0, false, nil
+	}
+	mode, err := sqlparser.ParseSQLMode(v.Value)
+	if err != nil {
+		return 0, false, errWrongValueForVar(v)
+	}
+	return mode, true, nil
+}
@@ -0,0 +1,253 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// PoolStats are the counters of the Pool.
+type PoolStats struct {
+	// Open is the count of the connections got out of the pool and the idle ones.
+	Open int
+
+	// Idle is the count of the idle connections.
+	Idle int
+
+	// InUse is the count of the connections got and not put back yet.
+	InUse int
+
+	// WaitCount is the count of the Gets which waited for a connection put back for the max open ones in use,
+	// WaitDuration is the total time they waited. The dials of the new connections are not waits.
+	WaitCount    uint64
+	WaitDuration time.Duration
+}
+
+// ConnStats are the counters of the client connection, they are kept across the reconnects.
+type ConnStats struct {
+	// Queries is the count of the COM_QUERY and COM_STMT_EXECUTE commands.
+	Queries uint64
+
+	// BytesRead and BytesWritten are the bytes on the wire.
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// Errors are the counts of the command errors by the SQLSTATE class, like '23' of the '23000'.
+	Errors map[string]uint64
+}
+
+// SessionStats are the I/O counters of the server session.
+type SessionStats struct {
+	// BytesRead and BytesWritten are the bytes on the wire, the TLS ones are the encrypted bytes.
+	BytesRead    uint64
+	BytesWritten uint64
+
+	// PacketsRead and PacketsWritten are the packets of the protocol, the handshake ones included.
+	PacketsRead    uint64
+	PacketsWritten uint64
+
+	// Commands is the commands the client sent after the handshake.
+	Commands uint64
+}
+
+// connStats are the counters of the conn, they are safe for the concurrent read by the Stats.
+type connStats struct {
+	queries      uint64
+	bytesRead    uint64
+	bytesWritten uint64
+
+	mu     sync.Mutex
+	errors map[string]uint64
+}
+
+func (s *connStats) addError(err error) {
+	class := "HY"
+	var sqlErr *sqldb.SQLError
+	if errors.As(err, &sqlErr) && len(sqlErr.State) >= 2 {
+		class = sqlErr.State[:2]
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errors == nil {
+		s.errors = make(map[string]uint64)
+	}
+	s.errors[class]++
+}
+
+// merge adds the counters of the other, the other is the one of the connection redialed.
+func (s *connStats) merge(o *connStats) {
+	atomic.AddUint64(&s.queries, atomic.LoadUint64(&o.queries))
+	atomic.AddUint64(&s.bytesRead, atomic.LoadUint64(&o.bytesRead))
+	atomic.AddUint64(&s.bytesWritten, atomic.LoadUint64(&o.bytesWritten))
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for class, n := range o.errors {
+		s.mu.Lock()
+		if s.errors == nil {
+			s.errors = make(map[string]uint64)
+		}
+		s.errors[class] += n
+		s.mu.Unlock()
+	}
+}
+
+func (s *connStats) snapshot() ConnStats {
+	stats := ConnStats{
+		Queries:      atomic.LoadUint64(&s.queries),
+		BytesRead:    atomic.LoadUint64(&s.bytesRead),
+		BytesWritten: atomic.LoadUint64(&s.bytesWritten),
+		Errors:       make(map[string]uint64),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for class, n := range s.errors {
+		stats.Errors[class] = n
+	}
+	return stats
+}
+
+// countingConn counts the bytes read and written of the connection.
+type countingConn struct {
+	net.Conn
+	stats *connStats
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.stats.bytesRead, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.stats.bytesWritten, uint64(n))
+	return n, err
+}
+
+// Stats returns the counters of the connection.
+func (c *conn) Stats() ConnStats {
+	if c.stats == nil {
+		return ConnStats{Errors: make(map[string]uint64)}
+	}
+	return c.stats.snapshot()
+}
+
+// countCommand counts the query commands and the errors of the command.
+func (c *conn) countCommand(command byte, err error) {
+	if c.stats == nil {
+		return
+	}
+	if command == sqldb.COM_QUERY || command == sqldb.COM_STMT_EXECUTE {
+		atomic.AddUint64(&c.stats.queries, 1)
+	}
+	if err != nil {
+		c.stats.addError(err)
+	}
+}
+
+// PublishExpvar publishes the stats returned by the fn as the expvar of the name, it's rendered as JSON by the
+// /debug/vars, like PublishExpvar("pool", func() interface{} { return pool.Stats() }).
+// It panics if the name is already published as the expvar.Publish.
+func PublishExpvar(name string, fn func() interface{}) {
+	expvar.Publish(name, expvar.Func(fn))
+}
+
+// WritePoolMetrics writes the stats in the Prometheus text exposition format, the metrics are prefixed by the namespace.
+func WritePoolMetrics(w io.Writer, namespace string, stats PoolStats) error {
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"pool_open_connections", "gauge", "The count of the open connections.", float64(stats.Open)},
+		{"pool_idle_connections", "gauge", "The count of the idle connections.", float64(stats.Idle)},
+		{"pool_in_use_connections", "gauge", "The count of the connections in use.", float64(stats.InUse)},
+		{"pool_wait_count_total", "counter", "The count of the gets waited for the connections put back.", float64(stats.WaitCount)},
+		{"pool_wait_duration_seconds_total", "counter", "The total time waited for the connections put back.", stats.WaitDuration.Seconds()},
+	}
+	for _, m := range metrics {
+		name := namespace + "_" + m.name
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, m.help, name, m.kind, name, m.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteConnMetrics writes the stats in the Prometheus text exposition format, the metrics are prefixed by the namespace.
+func WriteConnMetrics(w io.Writer, namespace string, stats ConnStats) error {
+	metrics := []struct {
+		name, help string
+		value      uint64
+	}{
+		{"conn_queries_total", "The count of the queries.", stats.Queries},
+		{"conn_read_bytes_total", "The bytes read from the server.", stats.BytesRead},
+		{"conn_written_bytes_total", "The bytes written to the server.", stats.BytesWritten},
+	}
+	for _, m := range metrics {
+		name := namespace + "_" + m.name
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, m.help, name, name, m.value); err != nil {
+			return err
+		}
+	}
+
+	name := namespace + "_conn_errors_total"
+	if _, err := fmt.Fprintf(w, "# HELP %s The count of the errors by the SQLSTATE class.\n# TYPE %s counter\n", name, name); err != nil {
+		return err
+	}
+	classes := make([]string, 0, len(stats.Errors))
+	for class := range stats.Errors {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	for _, class := range classes {
+		if _, err := fmt.Fprintf(w, "%s{class=%q} %d\n", name, class, stats.Errors[class]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteStatusMetrics writes the status counters of the Listener in the Prometheus text exposition format,
+// the metrics are prefixed by the namespace.
+func WriteStatusMetrics(w io.Writer, namespace string, status ServerStatus) error {
+	metrics := []struct {
+		name, kind, help string
+		value            float64
+	}{
+		{"connections_total", "counter", "The count of the sessions accepted.", float64(status.Connections)},
+		{"aborted_connects_total", "counter", "The count of the sessions failed before the auth passed.", float64(status.AbortedConnects)},
+		{"threads_connected", "gauge", "The count of the sessions authenticated and not closed yet.", float64(status.ThreadsConnected)},
+		{"questions_total", "counter", "The count of the commands the sessions sent.", float64(status.Questions)},
+		{"com_select_total", "counter", "The count of the SELECT queries.", float64(status.ComSelect)},
+		{"com_insert_total", "counter", "The count of the INSERT queries.", float64(status.ComInsert)},
+		{"com_update_total", "counter", "The count of the UPDATE queries.", float64(status.ComUpdate)},
+		{"com_delete_total", "counter", "The count of the DELETE queries.", float64(status.ComDelete)},
+		{"received_bytes_total", "counter", "The bytes received from the clients.", float64(status.BytesReceived)},
+		{"sent_bytes_total", "counter", "The bytes sent to the clients.", float64(status.BytesSent)},
+		{"uptime_seconds", "gauge", "The time since the listener was created.", status.Uptime.Seconds()},
+	}
+	for _, m := range metrics {
+		name := namespace + "_" + m.name
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %v\n", name, m.help, name, m.kind, name, m.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestConnStats(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result1 := &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}},
+		Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("10"))}},
+	}
+	th.AddQuery("select 1", result1)
+	th.AddQueryError("insert into t values(1)", sqldb.NewSQLError(sqldb.ER_DUP_ENTRY, "Duplicate entry '%s' for key '%s'", "1", "PRIMARY"))
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The handshake bytes.
+	stats := client.Stats()
+	assert.Equal(t, uint64(0), stats.Queries)
+	assert.True(t, stats.BytesRead > 0)
+	assert.True(t, stats.BytesWritten > 0)
+
+	_, err = client.FetchAll("select 1", -1)
+	assert.Nil(t, err)
+	err = client.Exec("insert into t values(1)")
+	assert.NotNil(t, err)
+	err = client.Exec("select 2")
+	assert.NotNil(t, err)
+	assert.Nil(t, client.Ping())
+
+	stmt, err := client.Prepare("select 1")
+	assert.Nil(t, err)
+	_, err = stmt.Execute()
+	assert.Nil(t, err)
+	assert.Nil(t, stmt.Close())
+
+	got := client.Stats()
+	assert.Equal(t, uint64(4), got.Queries)
+	assert.True(t, got.BytesRead > stats.BytesRead)
+	assert.True(t, got.BytesWritten > stats.BytesWritten)
+	assert.Equal(t, map[string]uint64{"23": 1, "HY": 1}, got.Errors)
+
+	// The counters are kept across the reconnect, the ping of the broken connection is counted as the error.
+	client.SetAutoReconnect(true, nil)
+	client.Cleanup()
+	assert.Nil(t, client.Ping())
+	_, err = client.FetchAll("select 1", -1)
+	assert.Nil(t, err)
+	after := client.Stats()
+	assert.Equal(t, uint64(5), after.Queries)
+	assert.True(t, after.BytesRead > got.BytesRead)
+	assert.Equal(t, map[string]uint64{"23": 1, "HY": 2}, after.Errors)
+}
+
+func TestPoolStats(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	pool, err := NewPool(fmt.Sprintf("mock:mock@tcp(%s)/", svr.Addr()), 1)
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	c1, err := pool.Get()
+	assert.Nil(t, err)
+	c2, err := pool.Get()
+	assert.Nil(t, err)
+	stats := pool.Stats()
+	assert.Equal(t, 2, stats.Open)
+	assert.Equal(t, 0, stats.Idle)
+	assert.Equal(t, 2, stats.InUse)
+	// The dials are not waits.
+	assert.Equal(t, uint64(0), stats.WaitCount)
+	assert.Equal(t, time.Duration(0), stats.WaitDuration)
+
+	// The second one is closed for the maxIdle.
+	assert.Nil(t, pool.Put(c1))
+	assert.Nil(t, pool.Put(c2))
+	stats = pool.Stats()
+	assert.Equal(t, 1, stats.Open)
+	assert.Equal(t, 1, stats.Idle)
+	assert.Equal(t, 0, stats.InUse)
+
+	// The idle one is got without the wait.
+	c, err := pool.Get()
+	assert.Nil(t, err)
+	stats = pool.Stats()
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, uint64(0), stats.WaitCount)
+
+	// The Get waits for the Put once the max open ones are in use.
+	pool.SetMaxOpen(1)
+	got := make(chan Conn)
+	go func() {
+		c, err := pool.Get()
+		assert.Nil(t, err)
+		got <- c
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, uint64(1), pool.Stats().WaitCount)
+	assert.Nil(t, pool.Put(c))
+	c = <-got
+	stats = pool.Stats()
+	assert.Equal(t, 1, stats.InUse)
+	assert.Equal(t, uint64(1), stats.WaitCount)
+	assert.True(t, stats.WaitDuration >= 50*time.Millisecond)
+	assert.Nil(t, pool.Put(c))
+
+	PublishExpvar("driver.pool.stats.test", func() interface{} { return pool.Stats() })
+	assert.Contains(t, expvar.Get("driver.pool.stats.test").String(), `"Idle":1`)
+}
+
+func TestWriteMetrics(t *testing.T) {
+	var buf bytes.Buffer
+	err := WritePoolMetrics(&buf, "mysql", PoolStats{Open: 3, Idle: 1, InUse: 2, WaitCount: 4, WaitDuration: 1500 * time.Millisecond})
+	assert.Nil(t, err)
+	out := buf.String()
+	assert.True(t, strings.Contains(out, "# TYPE mysql_pool_open_connections gauge\nmysql_pool_open_connections 3\n"))
+	assert.True(t, strings.Contains(out, "mysql_pool_wait_count_total 4\n"))
+	assert.True(t, strings.Contains(out, "mysql_pool_wait_duration_seconds_total 1.5\n"))
+
+	buf.Reset()
+	err = WriteConnMetrics(&buf, "mysql", ConnStats{Queries: 7, BytesRead: 10, BytesWritten: 20, Errors: map[string]uint64{"42": 2, "23": 1}})
+	assert.Nil(t, err)
+	out = buf.String()
+	assert.True(t, strings.Contains(out, "# TYPE mysql_conn_queries_total counter\nmysql_conn_queries_total 7\n"))
+	assert.True(t, strings.Contains(out, "mysql_conn_read_bytes_total 10\n"))
+	assert.True(t, strings.Contains(out, "mysql_conn_written_bytes_total 20\n"))
+	assert.True(t, strings.Contains(out, "mysql_conn_errors_total{class=\"23\"} 1\nmysql_conn_errors_total{class=\"42\"} 2\n"))
+
+	buf.Reset()
+	err = WriteStatusMetrics(&buf, "mysql", ServerStatus{Connections: 5, ThreadsConnected: 2, ComSelect: 9, BytesSent: 100, Uptime: 3 * time.Second})
+	assert.Nil(t, err)
+	out = buf.String()
+	assert.True(t, strings.Contains(out, "# TYPE mysql_connections_total counter\nmysql_connections_total 5\n"))
+	assert.True(t, strings.Contains(out, "# TYPE mysql_threads_connected gauge\nmysql_threads_connected 2\n"))
+	assert.True(t, strings.Contains(out, "mysql_com_select_total 9\n"))
+	assert.True(t, strings.Contains(out, "mysql_sent_bytes_total 100\n"))
+	assert.True(t, strings.Contains(out, "mysql_uptime_seconds 3\n"))
+}
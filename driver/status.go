@@ -0,0 +1,237 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var showStatusRegexp = regexp.MustCompile(`(?i)^show\s+(?:(global|session|local)\s+)?status(?:\s+like\s+(?:'([^']*)'|"([^"]*)")|\s+where\s+(.+))?$`)
+
+// ServerStatus is the status counters of the Listener, the FLUSH STATUS resets them.
+type ServerStatus struct {
+	// Connections is the sessions accepted.
+	Connections uint64
+
+	// AbortedConnects is the sessions failed before the auth passed.
+	AbortedConnects uint64
+
+	// Questions is the commands the sessions sent.
+	Questions uint64
+
+	// ThreadsConnected is the sessions authenticated and not closed yet, the FLUSH STATUS doesn't reset it.
+	ThreadsConnected uint64
+
+	// ComSelect, ComInsert, ComUpdate and ComDelete are the queries of the statements.
+	ComSelect uint64
+	ComInsert uint64
+	ComUpdate uint64
+	ComDelete uint64
+
+	// BytesReceived and BytesSent are the bytes on the wire of the sessions.
+	BytesReceived uint64
+	BytesSent     uint64
+
+	// Uptime is the time since the listener was created, the FLUSH STATUS doesn't reset it.
+	Uptime time.Duration
+}
+
+// The statements of the Com_ counters.
+const (
+	comSelect = iota
+	comInsert
+	comUpdate
+	comDelete
+	comCount
+)
+
+// comStatement returns the Com_ counter of the query, -1 if it's not counted.
+func comStatement(query string) int {
+	switch sqlparser.Preview(query) {
+	case sqlparser.StmtSelect:
+		return comSelect
+	case sqlparser.StmtInsert:
+		return comInsert
+	case sqlparser.StmtUpdate:
+		return comUpdate
+	case sqlparser.StmtDelete:
+		return comDelete
+	}
+	return -1
+}
+
+// statusCounters are the atomic counters of the ServerStatus.
+type statusCounters struct {
+	connections     uint64
+	abortedConnects uint64
+	questions       uint64
+	coms            [comCount]uint64
+
+	// The bytes of the closed sessions, the live ones are added by the snapshot.
+	bytesReceived uint64
+	bytesSent     uint64
+}
+
+func (c *statusCounters) connected() {
+	atomic.AddUint64(&c.connections, 1)
+}
+
+func (c *statusCounters) abortedConnect() {
+	atomic.AddUint64(&c.abortedConnects, 1)
+}
+
+func (c *statusCounters) question() {
+	atomic.AddUint64(&c.questions, 1)
+}
+
+func (c *statusCounters) com(stmt int) {
+	atomic.AddUint64(&c.coms[stmt], 1)
+}
+
+// closed adds the bytes of the closed session.
+func (c *statusCounters) closed(stats SessionStats) {
+	atomic.AddUint64(&c.bytesReceived, stats.BytesRead)
+	atomic.AddUint64(&c.bytesSent, stats.BytesWritten)
+}
+
+// snapshot returns the counters with the bytes of the live sessions.
+func (c *statusCounters) snapshot(live SessionStats) ServerStatus {
+	return ServerStatus{
+		Connections:     atomic.LoadUint64(&c.connections),
+		AbortedConnects: atomic.LoadUint64(&c.abortedConnects),
+		Questions:       atomic.LoadUint64(&c.questions),
+		ComSelect:       atomic.LoadUint64(&c.coms[comSelect]),
+		ComInsert:       atomic.LoadUint64(&c.coms[comInsert]),
+		ComUpdate:       atomic.LoadUint64(&c.coms[comUpdate]),
+		ComDelete:       atomic.LoadUint64(&c.coms[comDelete]),
+		BytesReceived:   atomic.LoadUint64(&c.bytesReceived) + live.BytesRead,
+		BytesSent:       atomic.LoadUint64(&c.bytesSent) + live.BytesWritten,
+	}
+}
+
+// reset resets the counters, the bytes of the live sessions so far are taken out of the coming snapshots.
+func (c *statusCounters) reset(live SessionStats) {
+	atomic.StoreUint64(&c.connections, 0)
+	atomic.StoreUint64(&c.abortedConnects, 0)
+	atomic.StoreUint64(&c.questions, 0)
+	for i := range c.coms {
+		atomic.StoreUint64(&c.coms[i], 0)
+	}
+	atomic.StoreUint64(&c.bytesReceived, -live.BytesRead)
+	atomic.StoreUint64(&c.bytesSent, -live.BytesWritten)
+}
+
+// liveSessions returns the count and the summed counters of the authenticated sessions.
+func (l *Listener) liveSessions() (int, SessionStats) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	var sum SessionStats
+	for _, s := range l.sessions {
+		stats := s.Stats()
+		sum.BytesRead += stats.BytesRead
+		sum.BytesWritten += stats.BytesWritten
+	}
+	return len(l.sessions), sum
+}
+
+// Status returns the status counters of the listener.
+func (l *Listener) Status() ServerStatus {
+	n, live := l.liveSessions()
+	status := l.status.snapshot(live)
+	status.ThreadsConnected = uint64(n)
+	status.Uptime = time.Since(l.started)
+	return status
+}
+
+// ResetStatus resets the status counters like the FLUSH STATUS.
+func (l *Listener) ResetStatus() {
+	_, live := l.liveSessions()
+	l.status.reset(live)
+}
+
+// countStatement counts the query to the Com_ counters of the listener and the session.
+func (l *Listener) countStatement(session *Session, query string) {
+	if stmt := comStatement(query); stmt >= 0 {
+		l.status.com(stmt)
+		atomic.AddUint64(&session.coms[stmt], 1)
+	}
+}
+
+// showStatus answers the SHOW [GLOBAL|SESSION] STATUS [LIKE 'pattern'] of the status counters,
+// the SESSION ones of the Questions, the Com_ and the Bytes_ are the ones of the session like MySQL.
+// The WHERE is answered if it only compares the Variable_name, otherwise it's left to the handler.
+func (l *Listener) showStatus(session *Session, query string) (*sqltypes.Result, bool) {
+	query = strings.TrimRight(sqlparser.StripLeadingComments(query), "; \t\r\n")
+	if sqlparser.Preview(query) != sqlparser.StmtShow {
+		return nil, false
+	}
+	m := showStatusRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+	patterns, ok := showPatterns(m[2]+m[3], m[4])
+	if !ok {
+		return nil, false
+	}
+
+	status := l.Status()
+	if !strings.EqualFold(m[1], "global") {
+		stats := session.Stats()
+		status.Questions = stats.Commands
+		status.ComSelect = atomic.LoadUint64(&session.coms[comSelect])
+		status.ComInsert = atomic.LoadUint64(&session.coms[comInsert])
+		status.ComUpdate = atomic.LoadUint64(&session.coms[comUpdate])
+		status.ComDelete = atomic.LoadUint64(&session.coms[comDelete])
+		status.BytesReceived = stats.BytesRead
+		status.BytesSent = stats.BytesWritten
+	}
+	// The names are in the order of MySQL.
+	vars := []struct {
+		name  string
+		value uint64
+	}{
+		{"Aborted_connects", status.AbortedConnects},
+		{"Bytes_received", status.BytesReceived},
+		{"Bytes_sent", status.BytesSent},
+		{"Com_delete", status.ComDelete},
+		{"Com_insert", status.ComInsert},
+		{"Com_select", status.ComSelect},
+		{"Com_update", status.ComUpdate},
+		{"Connections", status.Connections},
+		{"Questions", status.Questions},
+		{"Threads_connected", status.ThreadsConnected},
+		{"Uptime", uint64(status.Uptime / time.Second)},
+	}
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Variable_name", Type: querypb.Type_VARCHAR, Charset: session.fieldCharset(querypb.Type_VARCHAR)},
+			{Name: "Value", Type: querypb.Type_VARCHAR, Charset: session.fieldCharset(querypb.Type_VARCHAR)},
+		},
+	}
+	for _, v := range vars {
+		if !matchAny(patterns, v.name) {
+			continue
+		}
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.NewVarChar(v.name),
+			sqltypes.NewVarChar(strconv.FormatUint(v.value, 10)),
+		})
+	}
+	return qr, true
+}
@@ -0,0 +1,90 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestListenerShowStatus(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQuery("SELECT 1", &sqltypes.Result{})
+	th.AddQuery("INSERT INTO t VALUES (1)", &sqltypes.Result{RowsAffected: 1})
+	th.AddQuery("UPDATE t SET a = 1", &sqltypes.Result{})
+
+	show := func(client Conn, query string) map[string]string {
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		vars := make(map[string]string)
+		for _, row := range qr.Rows {
+			vars[row[0].String()] = row[1].String()
+		}
+		return vars
+	}
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	other, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer other.Close()
+
+	assert.Nil(t, client.Exec("SELECT 1"))
+	assert.Nil(t, client.Exec("select 1"))
+	assert.Nil(t, client.Exec("INSERT INTO t VALUES (1)"))
+	assert.Nil(t, other.Exec("UPDATE t SET a = 1"))
+
+	// The session ones.
+	want := map[string]string{"Com_select": "2", "Com_insert": "1", "Com_update": "0", "Com_delete": "0"}
+	assert.Equal(t, want, show(client, "SHOW STATUS LIKE 'Com\\_%'"))
+	vars := show(client, "show session status where variable_name = 'Questions' or Variable_name like 'Bytes%'")
+	assert.Equal(t, 3, len(vars))
+	assert.Equal(t, "5", vars["Questions"])
+	assert.Equal(t, strconv.FormatUint(client.Stats().BytesWritten, 10), vars["Bytes_received"])
+
+	// The global ones.
+	vars = show(other, "SHOW GLOBAL STATUS")
+	assert.Equal(t, 11, len(vars))
+	assert.Equal(t, "2", vars["Com_select"])
+	assert.Equal(t, "1", vars["Com_update"])
+	assert.Equal(t, "2", vars["Connections"])
+	assert.Equal(t, "2", vars["Threads_connected"])
+	assert.Equal(t, "7", vars["Questions"])
+	assert.NotEqual(t, "", vars["Uptime"])
+
+	status := svr.Status()
+	assert.Equal(t, uint64(2), status.ComSelect)
+	assert.Equal(t, uint64(1), status.ComInsert)
+	assert.True(t, status.BytesReceived >= client.Stats().BytesWritten+other.Stats().BytesWritten)
+
+	// The bytes of the closed sessions are kept, the reset takes them out.
+	other.Close()
+	for svr.Status().ThreadsConnected != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.True(t, svr.Status().BytesSent >= status.BytesSent)
+	svr.ResetStatus()
+	status = svr.Status()
+	assert.Equal(t, uint64(0), status.BytesReceived)
+	assert.Equal(t, uint64(0), status.BytesSent)
+	assert.Equal(t, uint64(0), status.ComSelect)
+	assert.Equal(t, uint64(1), status.ThreadsConnected)
+}
@@ -0,0 +1,358 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Statement is the prepared statement of the session.
+type Statement struct {
+	// ID is the statement id of the session.
+	ID uint32
+
+	// Query is the query of the COM_STMT_PREPARE.
+	Query string
+
+	// ParamCount is the count of the ? placeholders of the query.
+	ParamCount int
+
+	// Fields are the columns of the resultset, nil if they are only known by the execution.
+	Fields []*querypb.Field
+
+	// The offsets of the placeholders and the types of the params bound by the last execution.
+	placeholders []int
+	paramTypes   []querypb.Type
+
+	// mode is the sql_mode the placeholders are found and the params are quoted by.
+	mode sqlparser.SQLMode
+
+	// cursor is the resultset of the last CURSOR_TYPE_READ_ONLY execution not fetched yet.
+	cursor *cursor
+
+	// The params sent by the COM_STMT_SEND_LONG_DATA, their bytes accounted to the session memory and the error,
+	// they are reported and cleared by the next execution.
+	longData     map[uint16][]byte
+	longDataSize int64
+	longDataErr  error
+}
+
+func newStatement(id uint32, query string, mode sqlparser.SQLMode) *Statement {
+	offsets := placeholders(query, mode)
+	return &Statement{ID: id, Query: query, ParamCount: len(offsets), placeholders: offsets, mode: mode}
+}
+
+// setSQLMode makes the statement follow the sql_mode of the execution, the interpolated query is parsed by it.
+func (stmt *Statement) setSQLMode(mode sqlparser.SQLMode) {
+	if mode != stmt.mode {
+		stmt.mode = mode
+		stmt.placeholders = placeholders(stmt.Query, mode)
+	}
+}
+
+// Interpolate returns the query with the placeholders replaced by the SQL literals of the params,
+// the strings are quoted by the sql_mode of the session.
+func (stmt *Statement) Interpolate(params []sqltypes.Value) (string, error) {
+	if len(params) != stmt.ParamCount || len(params) != len(stmt.placeholders) {
+		return "", sqldb.NewSQLError(sqldb.ER_WRONG_ARGUMENTS, "Incorrect arguments to %s", "mysqld_stmt_execute")
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for i, offset := range stmt.placeholders {
+		buf.WriteString(stmt.Query[last:offset])
+		sqlparser.EncodeValue(&buf, params[i], stmt.mode)
+		last = offset + 1
+	}
+	buf.WriteString(stmt.Query[last:])
+	return buf.String(), nil
+}
+
+// placeholders returns the offsets of the ? placeholders of the query,
+// the ones in the quoted strings, the quoted identifiers and the comments are skipped.
+func placeholders(query string, mode sqlparser.SQLMode) []int {
+	var offsets []int
+	for i := 0; i < len(query); i++ {
+		if j := sqlparser.SkipLiteral(query, i, mode); j != i {
+			i = j
+			continue
+		}
+		if query[i] == '?' {
+			offsets = append(offsets, i)
+		}
+	}
+	return offsets
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// statement returns the prepared statement of the id.
+func (s *Session) statement(id uint32) (*Statement, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stmt, ok := s.stmts[id]
+	return stmt, ok
+}
+
+// prepareStatement allocates the statement of the query, it's registered to the session by addStatement.
+func (s *Session) prepareStatement(query string) *Statement {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastStmtID++
+	return newStatement(s.lastStmtID, query, s.sqlMode)
+}
+
+func (s *Session) addStatement(stmt *Statement) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stmts == nil {
+		s.stmts = make(map[uint32]*Statement)
+	}
+	s.stmts[stmt.ID] = stmt
+}
+
+func (s *Session) removeStatement(id uint32) (*Statement, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stmt, ok := s.stmts[id]
+	delete(s.stmts, id)
+	return stmt, ok
+}
+
+// Statements returns the count of the prepared statements of the session.
+func (s *Session) Statements() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.stmts)
+}
+
+// writeStmtPrepareOK writes the COM_STMT_PREPARE_OK with the definitions of the params and the columns.
+// https://dev.mysql.com/doc/internals/en/com-stmt-prepare-response.html
+func (s *Session) writeStmtPrepareOK(stmt *Statement) error {
+	ok := &proto.StmtPrepareOK{
+		StatementID: stmt.ID,
+		ColumnCount: uint16(len(stmt.Fields)),
+		ParamCount:  uint16(stmt.ParamCount),
+		Warnings:    s.WarningCount(),
+	}
+	if err := s.packets.Append(proto.PackStmtPrepareOK(ok)); err != nil {
+		return err
+	}
+	if stmt.ParamCount > 0 {
+		params := make([]*querypb.Field, stmt.ParamCount)
+		for i := range params {
+			params[i] = &querypb.Field{Name: "?", Type: sqltypes.VarBinary, Charset: sqldb.CharacterSetBinary}
+		}
+		if err := s.writeDefinitions(params); err != nil {
+			return err
+		}
+	}
+	if len(stmt.Fields) > 0 {
+		if err := s.writeDefinitions(stmt.Fields); err != nil {
+			return err
+		}
+	}
+	return s.flush()
+}
+
+// writeDefinitions appends the column definitions and the EOF without the column count.
+func (s *Session) writeDefinitions(fields []*querypb.Field) error {
+	for _, field := range fields {
+		if err := s.packets.Append(proto.PackColumn(field)); err != nil {
+			return err
+		}
+	}
+	if (s.capabilities() & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+		return s.packets.AppendEOFWithStatus(s.Status(), 0)
+	}
+	return nil
+}
+
+// handleStmtPrepare handles the COM_STMT_PREPARE, the error returned is the write error.
+func (l *Listener) handleStmtPrepare(session *Session, data []byte) error {
+	query, err := session.decodeQuery(l.parserComQuery(data))
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	if max := session.maxPreparedStmtCount(); max >= 0 && int64(session.Statements()) >= max {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_MAX_PREPARED_STMT_COUNT_REACHED, "Can't create more than max_prepared_stmt_count statements (current value: %d)", max))
+	}
+	// The executions of the statement go to the handler as the queries, their schemas are checked once here.
+	if err = session.checkQuery(query); err != nil {
+		return session.writeErrFromError(err)
+	}
+	stmt := session.prepareStatement(query)
+	if err = l.commands.ComStmtPrepare(session, stmt); err != nil {
+		l.log.Error("server.handle.stmt.prepare.from.session[%v].error:%+v.query[%s]", session.ID(), err, query)
+		return session.writeErrFromError(err)
+	}
+	session.addStatement(stmt)
+	return session.writeStmtPrepareOK(stmt)
+}
+
+// handleStmtExecute handles the COM_STMT_EXECUTE, the error returned is the write error.
+func (l *Listener) handleStmtExecute(session *Session, data []byte) error {
+	id, err := proto.UnPackStmtID(data[1:])
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	stmt, ok := session.statement(id)
+	if !ok {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_UNKNOWN_STMT_HANDLER, "Unknown prepared statement handler (%v) given to %s", id, "mysqld_stmt_execute"))
+	}
+	// The long data stays accounted during the execution.
+	longData, longDataSize, longDataErr := stmt.longData, stmt.longDataSize, stmt.longDataErr
+	stmt.longData, stmt.longDataSize, stmt.longDataErr = nil, 0, nil
+	defer session.freeLongData(longDataSize)
+	if longDataErr != nil {
+		return session.writeErrFromError(longDataErr)
+	}
+	execute, err := proto.UnPackStmtExecuteWithCapability(data[1:], session.capabilities(), stmt.ParamCount, stmt.paramTypes, longData)
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	session.setQueryAttributes(execute.Attributes)
+	stmt.paramTypes = execute.Types
+	stmt.setSQLMode(session.SQLMode())
+
+	// The execution closes the cursor of the last one.
+	stmt.cursor = nil
+	var cur *cursor
+	callback := session.writeBinaryResult
+	if execute.Flags&proto.CURSOR_TYPE_READ_ONLY > 0 {
+		cur = newCursor()
+		callback = func(qr *sqltypes.Result) error {
+			return session.bufferCursor(cur, qr)
+		}
+	}
+
+	session.clearWarnings()
+	end := session.beginStatement(session.executionTimeout(stmt.Query))
+	err = l.commands.ComStmtExecute(session, stmt, execute.Params, callback)
+	if ierr := end(); ierr != nil {
+		err = ierr
+	}
+	if err != nil {
+		session.addError(err)
+		session.setRowCount(-1)
+		l.log.Error("server.handle.stmt.execute.from.session[%v].error:%+v.query[%s]", session.ID(), err, stmt.Query)
+		return session.writeErrFromError(err)
+	}
+	if cur != nil && cur.result.Fields != nil {
+		stmt.cursor = cur
+		return session.writeCursorOpen(cur)
+	}
+	return nil
+}
+
+// handleStmtSendLongData handles the COM_STMT_SEND_LONG_DATA, it has no answer, the data is appended to the param
+// and the errors are reported by the next execution.
+// https://dev.mysql.com/doc/internals/en/com-stmt-send-long-data.html
+// A param can't be longer than the max_allowed_packet and the data is accounted to the session memory until the execution.
+func (l *Listener) handleStmtSendLongData(session *Session, data []byte) {
+	long, err := proto.UnPackStmtSendLongData(data[1:])
+	if err != nil {
+		return
+	}
+	stmt, ok := session.statement(long.StatementID)
+	if !ok || stmt.longDataErr != nil {
+		return
+	}
+	if int(long.ParamID) >= stmt.ParamCount {
+		stmt.longDataErr = sqldb.NewSQLError(sqldb.ER_WRONG_ARGUMENTS, "Incorrect arguments to %s", "mysqld_stmt_send_long_data")
+		return
+	}
+	if max := session.maxAllowedPacket(); max > 0 && uint64(len(stmt.longData[long.ParamID])+len(long.Data)) > max {
+		stmt.longDataErr = sqldb.NewSQLError(sqldb.ER_NET_PACKET_TOO_LARGE, "Parameter of prepared statement which is set through mysql_send_long_data() is longer than 'max_allowed_packet' bytes")
+		session.dropLongData(stmt)
+		return
+	}
+	if err := session.allocLongData(int64(len(long.Data))); err != nil {
+		stmt.longDataErr = err
+		session.dropLongData(stmt)
+		return
+	}
+	if stmt.longData == nil {
+		stmt.longData = make(map[uint16][]byte)
+	}
+	stmt.longData[long.ParamID] = append(stmt.longData[long.ParamID], long.Data...)
+	stmt.longDataSize += int64(len(long.Data))
+}
+
+// dropLongData releases the long data of the statement, the error is kept for the next execution.
+func (s *Session) dropLongData(stmt *Statement) {
+	s.freeLongData(stmt.longDataSize)
+	stmt.longData, stmt.longDataSize = nil, 0
+}
+
+// clearLongData releases the long data of the statement and clears the error.
+func (s *Session) clearLongData(stmt *Statement) {
+	s.dropLongData(stmt)
+	stmt.longDataErr = nil
+}
+
+// maxAllowedPacket returns the max_allowed_packet of the session, zero if it's not a number.
+func (s *Session) maxAllowedPacket() uint64 {
+	if v, ok := s.SystemVariable("max_allowed_packet"); ok {
+		if n, err := v.ParseUint64(); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// maxPreparedStmtCount returns the max_prepared_stmt_count of the session, it limits the statements of the session.
+// It's -1 if it's not a number.
+func (s *Session) maxPreparedStmtCount() int64 {
+	if v, ok := s.SystemVariable("max_prepared_stmt_count"); ok {
+		if n, err := v.ParseInt64(); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// handleStmtReset handles the COM_STMT_RESET, the long data and the cursor are cleared.
+// The error returned is the write error.
+// https://dev.mysql.com/doc/internals/en/com-stmt-reset.html
+func (l *Listener) handleStmtReset(session *Session, data []byte) error {
+	id, err := proto.UnPackStmtID(data[1:])
+	if err != nil {
+		return session.writeErrFromError(err)
+	}
+	stmt, ok := session.statement(id)
+	if !ok {
+		return session.writeErrFromError(sqldb.NewSQLError(sqldb.ER_UNKNOWN_STMT_HANDLER, "Unknown prepared statement handler (%v) given to %s", id, "mysqld_stmt_reset"))
+	}
+	session.clearLongData(stmt)
+	stmt.cursor = nil
+	return session.packets.WriteOK(0, 0, session.Status(), 0)
+}
+
+// handleStmtClose handles the COM_STMT_CLOSE, it has no answer.
+func (l *Listener) handleStmtClose(session *Session, data []byte) {
+	id, err := proto.UnPackStmtID(data[1:])
+	if err != nil {
+		return
+	}
+	if stmt, ok := session.removeStatement(id); ok {
+		session.clearLongData(stmt)
+		l.commands.ComStmtClose(session, stmt)
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"container/list"
+)
+
+// stmtCache is the LRU cache of the prepared statements by the query text,
+// the front of the list is the most recently used one.
+type stmtCache struct {
+	capacity int
+	list     *list.List
+	items    map[string]*list.Element
+}
+
+func newStmtCache(capacity int) *stmtCache {
+	return &stmtCache{
+		capacity: capacity,
+		list:     list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// get returns the statement of the query and marks it as the most recently used.
+func (sc *stmtCache) get(query string) (*Stmt, bool) {
+	elem, ok := sc.items[query]
+	if !ok {
+		return nil, false
+	}
+	sc.list.MoveToFront(elem)
+	return elem.Value.(*Stmt), true
+}
+
+// put adds the statement and returns the least recently used one evicted by it.
+func (sc *stmtCache) put(stmt *Stmt) *Stmt {
+	sc.items[stmt.query] = sc.list.PushFront(stmt)
+	if sc.list.Len() <= sc.capacity {
+		return nil
+	}
+	elem := sc.list.Back()
+	evicted := sc.list.Remove(elem).(*Stmt)
+	delete(sc.items, evicted.query)
+	return evicted
+}
+
+// purge removes all the statements and returns them.
+func (sc *stmtCache) purge() []*Stmt {
+	stmts := make([]*Stmt, 0, sc.list.Len())
+	for elem := sc.list.Front(); elem != nil; elem = elem.Next() {
+		stmts = append(stmts, elem.Value.(*Stmt))
+	}
+	sc.list.Init()
+	sc.items = make(map[string]*list.Element)
+	return stmts
+}
+
+func (sc *stmtCache) len() int {
+	return sc.list.Len()
+}
+
+// SetStmtCacheSize sets the capacity of the LRU cache of the prepared statements, zero disables it.
+// The Prepare of the cached query returns the cached statement whose Close is deferred to the eviction,
+// the statements cached before are closed.
+func (c *conn) SetStmtCacheSize(capacity int) error {
+	if c.stmts != nil {
+		for _, stmt := range c.stmts.purge() {
+			if err := stmt.close(); err != nil {
+				return err
+			}
+		}
+		c.stmts = nil
+	}
+	if capacity > 0 {
+		c.stmts = newStmtCache(capacity)
+	}
+	return nil
+}
+
+// StmtCacheLen returns the count of the cached prepared statements.
+func (c *conn) StmtCacheLen() int {
+	if c.stmts == nil {
+		return 0
+	}
+	return c.stmts.len()
+}
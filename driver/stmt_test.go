@@ -0,0 +1,441 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{query: "select 1", want: nil},
+		{query: "select ?, ?", want: []int{7, 10}},
+		{query: "select '?', \"?\", `?`, 'it\\'s ?', ?", want: []int{33}},
+		{query: "select ? -- ?\n, ? # ?\n, /* ? */ ?", want: []int{7, 16, 32}},
+		{query: "select 1--?", want: []int{10}},
+		{query: "select '?", want: nil},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, placeholders(test.query, 0), test.query)
+	}
+
+	stmt := newStatement(1, "select * from t where a = ? and b = ? and c = '?'", 0)
+	assert.Equal(t, 2, stmt.ParamCount)
+	query, err := stmt.Interpolate([]sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NewVarChar("it's")})
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from t where a = 1 and b = 'it\\'s' and c = '?'", query)
+	query, err = stmt.Interpolate([]sqltypes.Value{sqltypes.NULL, sqltypes.NewFloat64(1.5)})
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from t where a = null and b = 1.5 and c = '?'", query)
+	_, err = stmt.Interpolate(nil)
+	assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+
+	// The NO_BACKSLASH_ESCAPES keeps the '\' in strings, the quotes are doubled instead.
+	assert.Equal(t, []int{13}, placeholders("select 'x\\', ?", sqlparser.ModeNoBackslashEscapes))
+	assert.Equal(t, []int{13}, placeholders("select \"x\\\", ?", sqlparser.ModeANSIQuotes))
+	stmt = newStatement(1, "select * from t where a = ? and b = 'x\\' and c = ?", sqlparser.ModeNoBackslashEscapes)
+	assert.Equal(t, 2, stmt.ParamCount)
+	query, err = stmt.Interpolate([]sqltypes.Value{sqltypes.NewVarChar("\\' or 1=1 -- "), sqltypes.NewVarChar("it's")})
+	assert.Nil(t, err)
+	assert.Equal(t, "select * from t where a = '\\'' or 1=1 -- ' and b = 'x\\' and c = 'it''s'", query)
+
+	// The sql_mode of the execution.
+	stmt.setSQLMode(0)
+	_, err = stmt.Interpolate([]sqltypes.Value{sqltypes.NULL, sqltypes.NULL})
+	assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+	stmt = newStatement(1, "select ?", sqlparser.ModeNoBackslashEscapes)
+	stmt.setSQLMode(0)
+	query, err = stmt.Interpolate([]sqltypes.Value{sqltypes.NewVarChar("\\'")})
+	assert.Nil(t, err)
+	assert.Equal(t, "select '\\\\\\''", query)
+}
+
+func TestServerStmt(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "ts", Type: querypb.Type_DATETIME},
+			{Name: "extra", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("-10")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("it's")),
+				sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2020-01-02 03:04:05")),
+				sqltypes.NULL,
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("20")),
+				sqltypes.NULL,
+				sqltypes.NULL,
+				sqltypes.NULL,
+			},
+		},
+	}
+	th.AddQuery("select * from t1 where id > -100 and name != 'it\\'s'", result)
+	th.AddQuery("insert into t1 values(1, null)", &sqltypes.Result{RowsAffected: 1, InsertID: 7})
+	th.AddQueryError("select * from t1 where id > 0 and name != 'x'", sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", "t1"))
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Select.
+	stmt, err := client.Prepare("SELECT * FROM t1 WHERE id > ? AND name != ?")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, stmt.ParamCount)
+	assert.Equal(t, 0, len(stmt.Fields))
+	for i := 0; i < 2; i++ {
+		qr, err := stmt.Execute(sqltypes.NewInt64(-100), sqltypes.NewVarChar("it's"))
+		assert.Nil(t, err)
+		assert.Equal(t, result.Rows, qr.Rows)
+		assert.Equal(t, 4, len(qr.Fields))
+	}
+
+	// The handler error.
+	_, err = stmt.Execute(sqltypes.NewInt64(0), sqltypes.NewVarChar("x"))
+	assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), err.(*sqldb.SQLError).Num)
+
+	// Wrong arguments.
+	_, err = stmt.Execute(sqltypes.NewInt64(0))
+	assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+
+	// Insert.
+	{
+		stmt, err := client.Prepare("INSERT INTO t1 VALUES(?, ?)")
+		assert.Nil(t, err)
+		qr, err := stmt.Execute(sqltypes.NewInt64(1), sqltypes.NULL)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+		assert.Equal(t, uint64(7), qr.InsertID)
+		assert.Nil(t, stmt.Close())
+	}
+
+	// Closed.
+	assert.Nil(t, stmt.Close())
+	_, err = stmt.Execute(sqltypes.NewInt64(-100), sqltypes.NewVarChar("it's"))
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_STMT_HANDLER), err.(*sqldb.SQLError).Num)
+	assert.Nil(t, client.Ping())
+}
+
+func TestServerStmtLongData(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	blob := strings.Repeat("a", longDataChunkSize+3)
+	th.AddQuery(fmt.Sprintf("insert into t1 values(1, '%s')", blob), &sqltypes.Result{RowsAffected: 1})
+	th.AddQuery("insert into t1 values(2, 'b')", &sqltypes.Result{RowsAffected: 1})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	stmt, err := client.Prepare("INSERT INTO t1 VALUES(?, ?)")
+	assert.Nil(t, err)
+
+	// The chunks are appended.
+	assert.Nil(t, stmt.SendLongData(1, []byte(blob[:10])))
+	assert.Nil(t, stmt.SendLongData(1, []byte(blob[10:])))
+	qr, err := stmt.Execute(sqltypes.NewInt64(1), sqltypes.NULL)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), qr.RowsAffected)
+
+	// The long data are cleared by the execution.
+	qr, err = stmt.Execute(sqltypes.NewInt64(2), sqltypes.NewVarChar("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), qr.RowsAffected)
+
+	// The wrong param is reported by the next execution.
+	err = stmt.SendLongData(2, []byte("x"))
+	assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+	payload := proto.PackStmtSendLongData(&proto.StmtSendLongData{StatementID: stmt.ID, ParamID: 2, Data: []byte("x")})
+	assert.Nil(t, client.WriteCommand(sqldb.COM_STMT_SEND_LONG_DATA, payload))
+	_, err = stmt.Execute(sqltypes.NewInt64(2), sqltypes.NewVarChar("b"))
+	assert.Equal(t, uint16(sqldb.ER_WRONG_ARGUMENTS), err.(*sqldb.SQLError).Num)
+	_, err = stmt.Execute(sqltypes.NewInt64(2), sqltypes.NewVarChar("b"))
+	assert.Nil(t, err)
+
+	// The reset clears the long data and the error.
+	assert.Nil(t, client.WriteCommand(sqldb.COM_STMT_SEND_LONG_DATA, payload))
+	assert.Nil(t, stmt.SendLongData(1, []byte("x")))
+	assert.Nil(t, stmt.Reset())
+	_, err = stmt.Execute(sqltypes.NewInt64(2), sqltypes.NewVarChar("b"))
+	assert.Nil(t, err)
+
+	// The unknown statement is ignored.
+	payload = proto.PackStmtSendLongData(&proto.StmtSendLongData{StatementID: 100, ParamID: 0, Data: []byte("x")})
+	assert.Nil(t, client.WriteCommand(sqldb.COM_STMT_SEND_LONG_DATA, payload))
+	assert.Nil(t, client.Ping())
+
+	assert.Nil(t, stmt.Close())
+	err = stmt.Reset()
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_STMT_HANDLER), err.(*sqldb.SQLError).Num)
+}
+
+func TestServerStmtLongDataLimits(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	svr.SetSessionMemoryLimit(1024)
+	th.AddQuery("insert into t1 values(1, 'b')", &sqltypes.Result{RowsAffected: 1})
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	var session *Session
+	for _, s := range th.ss {
+		session = s.session
+	}
+	session.SetSystemVariable("max_allowed_packet", sqltypes.NewInt64(512))
+	session.SetSystemVariable("max_prepared_stmt_count", sqltypes.NewInt64(1))
+
+	stmt, err := client.Prepare("INSERT INTO t1 VALUES(?, ?)")
+	assert.Nil(t, err)
+
+	// The param longer than the max_allowed_packet.
+	assert.Nil(t, stmt.SendLongData(1, []byte(strings.Repeat("a", 300))))
+	assert.Nil(t, stmt.SendLongData(1, []byte(strings.Repeat("a", 300))))
+	assert.Nil(t, client.Ping())
+	assert.Equal(t, int64(0), session.longDataMem)
+	_, err = stmt.Execute(sqltypes.NewInt64(1), sqltypes.NULL)
+	assert.Equal(t, uint16(sqldb.ER_NET_PACKET_TOO_LARGE), err.(*sqldb.SQLError).Num)
+
+	// The long data of the params are accounted to the session memory until the execution.
+	assert.Nil(t, stmt.SendLongData(0, []byte(strings.Repeat("a", 500))))
+	assert.Nil(t, client.Ping())
+	assert.Equal(t, int64(500), session.longDataMem)
+	assert.Nil(t, stmt.SendLongData(1, []byte(strings.Repeat("a", 500))))
+	assert.Nil(t, client.Ping())
+	assert.Equal(t, int64(0), session.longDataMem)
+	_, err = stmt.Execute(sqltypes.NULL, sqltypes.NULL)
+	assert.Equal(t, uint16(sqldb.ER_OUT_OF_RESOURCES), err.(*sqldb.SQLError).Num)
+	qr, err := stmt.Execute(sqltypes.NewInt64(1), sqltypes.NewVarChar("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), qr.RowsAffected)
+
+	// The statements of the session are limited by the max_prepared_stmt_count.
+	_, err = client.Prepare("SELECT ?")
+	assert.Equal(t, uint16(sqldb.ER_MAX_PREPARED_STMT_COUNT_REACHED), err.(*sqldb.SQLError).Num)
+	assert.Nil(t, stmt.Close())
+	stmt, err = client.Prepare("SELECT ?")
+	assert.Nil(t, err)
+	assert.Nil(t, stmt.Close())
+}
+
+type commandHandler struct {
+	BaseCommandHandler
+	params []sqltypes.Value
+}
+
+func (h *commandHandler) ComPing(session *Session) error {
+	return errors.New("ping.refused")
+}
+
+func (h *commandHandler) ComFieldList(session *Session, table string, wildcard string) ([]*querypb.Field, error) {
+	return []*querypb.Field{{Name: table + wildcard, Type: querypb.Type_INT32}}, nil
+}
+
+func (h *commandHandler) ComStmtPrepare(session *Session, stmt *Statement) error {
+	stmt.Fields = []*querypb.Field{{Name: "a", Type: querypb.Type_INT64}, {Name: "b", Type: querypb.Type_FLOAT64}}
+	return nil
+}
+
+func (h *commandHandler) ComStmtExecute(session *Session, stmt *Statement, params []sqltypes.Value, callback func(*sqltypes.Result) error) error {
+	h.params = params
+	return callback(&sqltypes.Result{Fields: stmt.Fields, Rows: [][]sqltypes.Value{{params[0], params[1]}}})
+}
+
+func (h *commandHandler) ComOther(session *Session, command byte, data []byte) error {
+	if command == sqldb.COM_SLEEP {
+		return nil
+	}
+	return h.BaseCommandHandler.ComOther(session, command, data)
+}
+
+func TestServerCommandHandler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := &commandHandler{BaseCommandHandler: BaseCommandHandler{Handler: NewTestHandler(log)}}
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	err = client.Ping()
+	assert.Equal(t, "ping.refused (errno 1105) (sqlstate HY000)", err.Error())
+	assert.Nil(t, client.Command(sqldb.COM_SLEEP))
+	err = client.Command(sqldb.COM_TIME)
+	assert.Equal(t, "command handling not implemented yet: COM_TIME (errno 1105) (sqlstate HY000)", err.Error())
+
+	fields := fieldList(t, client, "t1", "a%")
+	assert.Equal(t, 1, len(fields))
+	assert.Equal(t, "t1a%", fields[0].Name)
+
+	stmt, err := client.Prepare("SELECT a, b FROM t WHERE a = ? AND b = ?")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(stmt.Fields))
+	assert.Equal(t, "b", stmt.Fields[1].Name)
+	qr, err := stmt.Execute(sqltypes.NewInt64(-1), sqltypes.NewFloat64(2.5))
+	assert.Nil(t, err)
+	assert.Equal(t, []sqltypes.Value{sqltypes.NewInt64(-1), sqltypes.NewFloat64(2.5)}, th.params)
+	assert.Equal(t, [][]sqltypes.Value{{sqltypes.NewInt64(-1), sqltypes.NewFloat64(2.5)}}, qr.Rows)
+}
+
+func fieldList(t *testing.T, client Conn, table, wildcard string) []*querypb.Field {
+	buf := common.NewBuffer(16)
+	buf.WriteString(table)
+	buf.WriteU8(0)
+	buf.WriteString(wildcard)
+	assert.Nil(t, client.WriteCommand(sqldb.COM_FIELD_LIST, buf.Datas()))
+
+	var fields []*querypb.Field
+	for {
+		data, err := client.NextPacket()
+		assert.Nil(t, err)
+		if data[0] == proto.EOF_PACKET {
+			return fields
+		}
+		assert.NotEqual(t, proto.ERR_PACKET, data[0])
+		def, err := proto.UnPackColumnDefinition(data, true)
+		assert.Nil(t, err)
+		assert.Nil(t, def.DefaultValue)
+		field, err := def.Field()
+		assert.Nil(t, err)
+		fields = append(fields, field)
+	}
+}
+
+func TestServerBaseCommandHandler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.AddQuery("select * from t1 limit 0", &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "id", Type: querypb.Type_INT32}, {Name: "name", Type: querypb.Type_VARCHAR}, {Name: "idx", Type: querypb.Type_INT32}},
+	})
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Field list.
+	fields := fieldList(t, client, "t1", "")
+	assert.Equal(t, 3, len(fields))
+	fields = fieldList(t, client, "t1", "ID%")
+	assert.Equal(t, 2, len(fields))
+	assert.Equal(t, "idx", fields[1].Name)
+
+	// Process kill.
+	victim, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer victim.Close()
+	buf := common.NewBuffer(4)
+	buf.WriteU32(victim.ConnectionID())
+	assert.Nil(t, client.WriteCommand(sqldb.COM_PROCESS_KILL, buf.Datas()))
+	data, err := client.NextPacket()
+	assert.Nil(t, err)
+	assert.Equal(t, proto.OK_PACKET, data[0])
+	assert.NotNil(t, victim.Ping())
+}
+
+func TestClientStmtCache(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	svr, err := MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	for _, query := range []string{"select 1", "select 2", "select 3"} {
+		th.AddQuery(query, &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "a", Type: querypb.Type_INT32}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte(query[7:]))}},
+		})
+	}
+
+	pool, err := NewPool(fmt.Sprintf("mock:mock@tcp(%s)/?stmtCacheSize=2", svr.Addr()), 1)
+	assert.Nil(t, err)
+	defer pool.Close()
+	client, err := pool.Get()
+	assert.Nil(t, err)
+
+	s1, err := client.Prepare("SELECT 1")
+	assert.Nil(t, err)
+	again, err := client.Prepare("SELECT 1")
+	assert.Nil(t, err)
+	assert.True(t, s1 == again)
+	// The cached statement is kept by the Close.
+	assert.Nil(t, s1.Close())
+	_, err = s1.Execute()
+	assert.Nil(t, err)
+
+	s2, err := client.Prepare("SELECT 2")
+	assert.Nil(t, err)
+	assert.NotEqual(t, s1.ID, s2.ID)
+	assert.Equal(t, 2, client.StmtCacheLen())
+
+	// The least recently used one is evicted and closed.
+	_, err = client.Prepare("SELECT 1")
+	assert.Nil(t, err)
+	_, err = client.Prepare("SELECT 3")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, client.StmtCacheLen())
+	_, err = s2.Execute()
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_STMT_HANDLER), err.(*sqldb.SQLError).Num)
+	qr, err := s1.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, "1", qr.Rows[0][0].String())
+	s2, err = client.Prepare("SELECT 2")
+	assert.Nil(t, err)
+	qr, err = s2.Execute()
+	assert.Nil(t, err)
+	assert.Equal(t, "2", qr.Rows[0][0].String())
+
+	// Disabled, the cached ones are closed.
+	assert.Nil(t, client.SetStmtCacheSize(0))
+	assert.Equal(t, 0, client.StmtCacheLen())
+	_, err = s2.Execute()
+	assert.Equal(t, uint16(sqldb.ER_UNKNOWN_STMT_HANDLER), err.(*sqldb.SQLError).Num)
+	s3, err := client.Prepare("SELECT 3")
+	assert.Nil(t, err)
+	again, err = client.Prepare("SELECT 3")
+	assert.Nil(t, err)
+	assert.False(t, s3 == again)
+	assert.Nil(t, pool.Put(client))
+}
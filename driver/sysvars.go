@@ -0,0 +1,477 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// systemVariableDefaults are the variables the connectors query at connect time, the values are MySQL 5.7 defaults.
+var systemVariableDefaults = map[string]sqltypes.Value{
+	"auto_increment_increment": sqltypes.NewInt64(1),
+	"auto_increment_offset":    sqltypes.NewInt64(1),
+	"autocommit":               sqltypes.NewInt64(1),
+	"character_set_client":     sqltypes.NewVarChar("utf8mb4"),
+	"character_set_connection": sqltypes.NewVarChar("utf8mb4"),
+	"character_set_database":   sqltypes.NewVarChar("utf8mb4"),
+	"character_set_results":    sqltypes.NewVarChar("utf8mb4"),
+	"character_set_server":     sqltypes.NewVarChar("utf8mb4"),
+	"collation_connection":     sqltypes.NewVarChar("utf8mb4_general_ci"),
+	"collation_database":       sqltypes.NewVarChar("utf8mb4_general_ci"),
+	"collation_server":         sqltypes.NewVarChar("utf8mb4_general_ci"),
+	"init_connect":             sqltypes.NewVarChar(""),
+	"interactive_timeout":      sqltypes.NewInt64(28800),
+	"license":                  sqltypes.NewVarChar("GPL"),
+	"lower_case_table_names":   sqltypes.NewInt64(0),
+	"max_allowed_packet":       sqltypes.NewInt64(4194304),
+	"max_connections":          sqltypes.NewInt64(151),
+	"max_execution_time":       sqltypes.NewInt64(0),
+	"max_prepared_stmt_count":  sqltypes.NewInt64(16382),
+	"net_buffer_length":        sqltypes.NewInt64(16384),
+	"net_read_timeout":         sqltypes.NewInt64(30),
+	"net_write_timeout":        sqltypes.NewInt64(60),
+	"performance_schema":       sqltypes.NewInt64(0),
+	"query_cache_size":         sqltypes.NewInt64(0),
+	"query_cache_type":         sqltypes.NewVarChar("OFF"),
+	"read_only":                sqltypes.NewInt64(0),
+	"sql_mode":                 sqltypes.NewVarChar(sqlparser.DefaultSQLMode.String()),
+	"system_time_zone":         sqltypes.NewVarChar(systemTimeZone()),
+	"time_zone":                sqltypes.NewVarChar("SYSTEM"),
+	"transaction_isolation":    sqltypes.NewVarChar("REPEATABLE-READ"),
+	"transaction_read_only":    sqltypes.NewInt64(0),
+	"version":                  sqltypes.NewVarChar(proto.DefaultServerVersion),
+	"version_comment":          sqltypes.NewVarChar("go-mysqlstack"),
+	"wait_timeout":             sqltypes.NewInt64(28800),
+}
+
+// readOnlySystemVariables can't be set by the clients, the handler sets them by the SystemVariables.Set.
+var readOnlySystemVariables = map[string]bool{
+	"license":                true,
+	"lower_case_table_names": true,
+	"performance_schema":     true,
+	"proxy_user":             true,
+	"system_time_zone":       true,
+	"version":                true,
+	"version_comment":        true,
+}
+
+// systemVariableAliases are the deprecated names of the variables.
+var systemVariableAliases = map[string]string{
+	"tx_isolation": "transaction_isolation",
+	"tx_read_only": "transaction_read_only",
+}
+
+var (
+	showVariablesRegexp = regexp.MustCompile(`(?i)^show\s+(?:(global|session|local)\s+)?variables(?:\s+like\s+(?:'([^']*)'|"([^"]*)")|\s+where\s+(.+))?$`)
+	variableNameRegexp  = regexp.MustCompile(`(?i)^\(?\s*variable_name\s*(=|like)\s*(?:'([^']*)'|"([^"]*)")\s*\)?$`)
+	orRegexp            = regexp.MustCompile(`(?i)\s+or\s+`)
+)
+
+// SystemVariables is the global system variable store of the Listener, the names are lowercased.
+// The sessions start with the global values and keep their own by the SET SESSION.
+type SystemVariables struct {
+	mu   sync.RWMutex
+	vars map[string]sqltypes.Value
+}
+
+// NewSystemVariables creates the store with the defaults.
+func NewSystemVariables() *SystemVariables {
+	vars := make(map[string]sqltypes.Value, len(systemVariableDefaults))
+	for k, v := range systemVariableDefaults {
+		vars[k] = v
+	}
+	return &SystemVariables{vars: vars}
+}
+
+// Get returns the global value of the variable, false if it's unknown.
+func (sv *SystemVariables) Get(name string) (sqltypes.Value, bool) {
+	name = systemVariableName(name)
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+	v, ok := sv.vars[name]
+	return v, ok
+}
+
+// Set sets the global value of the variable, the unknown one is added.
+// The read only variables like version_comment are set by it.
+func (sv *SystemVariables) Set(name string, v sqltypes.Value) {
+	name = systemVariableName(name)
+	sv.mu.Lock()
+	defer sv.mu.Unlock()
+	sv.vars[name] = v
+}
+
+// Names returns the sorted names of the variables.
+func (sv *SystemVariables) Names() []string {
+	sv.mu.RLock()
+	defer sv.mu.RUnlock()
+	names := make([]string, 0, len(sv.vars))
+	for k := range sv.vars {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func systemVariableName(name string) string {
+	name = strings.ToLower(name)
+	if alias, ok := systemVariableAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// setGlobals makes the session start with the global sql_mode and transaction characteristics.
+func (s *Session) setGlobals(globals *SystemVariables) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.globals = globals
+	if v, ok := globals.Get("sql_mode"); ok {
+		if mode, err := sqlparser.ParseSQLMode(v.String()); err == nil {
+			s.sqlMode = mode
+		}
+	}
+	if v, ok := globals.Get("transaction_read_only"); ok {
+		s.txReadOnly = v.String() != "0"
+	}
+}
+
+// SystemVariable returns the session value of the variable, false if it's unknown.
+// The autocommit, sql_mode, proxy_user and the transaction characteristics follow the session state.
+func (s *Session) SystemVariable(name string) (sqltypes.Value, bool) {
+	name = systemVariableName(name)
+	switch name {
+	case "autocommit":
+		return boolValue(s.Autocommit()), true
+	case "sql_mode":
+		return sqltypes.NewVarChar(s.SQLMode().String()), true
+	case "transaction_isolation":
+		if iso := s.TransactionIsolation(); iso != "" {
+			return sqltypes.NewVarChar(iso), true
+		}
+	case "transaction_read_only":
+		return boolValue(s.TransactionReadOnly()), true
+	case "version":
+		return sqltypes.NewVarChar(s.greeting.ServerVersion()), true
+	case "proxy_user":
+		if proxy := s.ProxyUser(); proxy != "" {
+			return sqltypes.NewVarChar(proxy), true
+		}
+		return sqltypes.NULL, true
+	}
+
+	s.mu.RLock()
+	v, ok := s.sysVars[name]
+	globals := s.globals
+	s.mu.RUnlock()
+	if ok {
+		return v, true
+	}
+	return globals.Get(name)
+}
+
+// SetSystemVariable sets the session value of the variable.
+func (s *Session) SetSystemVariable(name string, v sqltypes.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sysVars == nil {
+		s.sysVars = make(map[string]sqltypes.Value)
+	}
+	s.sysVars[systemVariableName(name)] = v
+}
+
+func (s *Session) systemVariables() map[string]sqltypes.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vars := make(map[string]sqltypes.Value, len(s.sysVars))
+	for k, v := range s.sysVars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// setSessionSysVar applies the SET SESSION of the variables not tracked by the session state,
+// the unknown ones are left to the handler.
+func (s *Session) setSessionSysVar(v *sqlparser.SetVar) error {
+	name := systemVariableName(v.Name)
+	switch name {
+	case "names", "charset":
+		for _, n := range []string{"character_set_client", "character_set_connection", "character_set_results"} {
+			if err := s.setSessionSysVar(&sqlparser.SetVar{Scope: v.Scope, Name: n, Value: v.Value, Kind: v.Kind}); err != nil {
+				return err
+			}
+		}
+		// The COLLATE follows as the collation_connection.
+		if c, ok := sqldb.DefaultCollationOf(v.Value, false); ok {
+			s.SetSystemVariable("collation_connection", sqltypes.NewVarChar(c.Name))
+		}
+		return nil
+	case "collation_connection":
+		// The collation decides the character_set_connection.
+		if c, ok := sqldb.LookupCollationByName(v.Value); ok {
+			s.SetSystemVariable("character_set_connection", sqltypes.NewVarChar(c.Charset))
+		}
+	}
+
+	old, ok := s.SystemVariable(name)
+	if !ok {
+		return nil
+	}
+	if v.Kind == sqlparser.SetValueIdent && strings.EqualFold(v.Value, "default") {
+		s.mu.Lock()
+		delete(s.sysVars, name)
+		s.mu.Unlock()
+		return nil
+	}
+	val, ok, err := systemVariableValue(name, v, old)
+	if err != nil || !ok {
+		return err
+	}
+	s.SetSystemVariable(name, val)
+	return nil
+}
+
+// globalSysVar checks the SET GLOBAL, the returned set applies it.
+func (s *Session) globalSysVar(v *sqlparser.SetVar) (set func(), err error) {
+	name := systemVariableName(v.Name)
+	s.mu.RLock()
+	globals := s.globals
+	s.mu.RUnlock()
+	old, ok := globals.Get(name)
+	if !ok {
+		return func() {}, nil
+	}
+
+	val := old
+	if v.Kind == sqlparser.SetValueIdent && strings.EqualFold(v.Value, "default") {
+		if def, ok := systemVariableDefaults[name]; ok {
+			val = def
+		}
+	} else {
+		if val, ok, err = systemVariableValue(name, v, old); err != nil {
+			return nil, err
+		}
+		if !ok {
+			return func() {}, nil
+		}
+	}
+	return func() { globals.Set(name, val) }, nil
+}
+
+// systemVariableValue evaluates the assignment by the type of the old value,
+// returns false if it's an expression can't be evaluated here.
+func systemVariableValue(name string, v *sqlparser.SetVar, old sqltypes.Value) (sqltypes.Value, bool, error) {
+	if readOnlySystemVariables[name] {
+		return sqltypes.Value{}, false, sqldb.NewSQLError(sqldb.ER_INCORRECT_GLOBAL_LOCAL_VAR, "Variable '%s' is a read only variable", name)
+	}
+	if v.Kind == sqlparser.SetValueExpr {
+		return sqltypes.Value{}, false, nil
+	}
+
+	switch name {
+	case "autocommit", "transaction_read_only":
+		on, err := v.Bool()
+		if err != nil {
+			return sqltypes.Value{}, false, errWrongValueForVar(v)
+		}
+		return boolValue(on), true, nil
+	case "sql_mode":
+		mode, ok, err := sqlModeOf(v)
+		if err != nil || !ok {
+			return sqltypes.Value{}, ok, err
+		}
+		return sqltypes.NewVarChar(mode.String()), true, nil
+	case "transaction_isolation":
+		return sqltypes.NewVarChar(strings.ToUpper(strings.Replace(v.Value, " ", "-", -1))), true, nil
+	case "time_zone":
+		if _, err := parseTimeZone(v.Value); err != nil {
+			return sqltypes.Value{}, false, err
+		}
+		return sqltypes.NewVarChar(v.Value), true, nil
+	case "character_set_client", "character_set_connection", "character_set_database", "character_set_results", "character_set_server":
+		// The NULL character_set_results asks the results untranslated.
+		if name == "character_set_results" && v.Kind == sqlparser.SetValueIdent && strings.EqualFold(v.Value, "null") {
+			return sqltypes.NULL, true, nil
+		}
+		cs, ok := sqldb.LookupCharset(v.Value)
+		if !ok {
+			return sqltypes.Value{}, false, sqldb.NewSQLError(sqldb.ER_UNKNOWN_CHARACTER_SET, "Unknown character set: '%s'", v.Value)
+		}
+		return sqltypes.NewVarChar(cs.Name), true, nil
+	case "collation_connection", "collation_database", "collation_server":
+		c, ok := sqldb.LookupCollationByName(v.Value)
+		if !ok {
+			return sqltypes.Value{}, false, sqldb.NewSQLError(sqldb.ER_UNKNOWN_COLLATION, "Unknown collation: '%s'", v.Value)
+		}
+		return sqltypes.NewVarChar(c.Name), true, nil
+	}
+
+	if !sqltypes.IsIntegral(old.Type()) {
+		return sqltypes.NewVarChar(v.Value), true, nil
+	}
+	if v.Kind == sqlparser.SetValueIdent {
+		on, err := v.Bool()
+		if err != nil {
+			return sqltypes.Value{}, false, errWrongValueForVar(v)
+		}
+		return boolValue(on), true, nil
+	}
+	n, err := strconv.ParseInt(v.Value, 10, 64)
+	if err != nil {
+		return sqltypes.Value{}, false, sqldb.NewSQLError(sqldb.ER_WRONG_TYPE_FOR_VAR, "Incorrect argument type to variable '%s'", name)
+	}
+	return sqltypes.NewInt64(n), true, nil
+}
+
+func boolValue(on bool) sqltypes.Value {
+	if on {
+		return sqltypes.NewInt64(1)
+	}
+	return sqltypes.NewInt64(0)
+}
+
+// evalSystemVar evaluates the @@var, @@session.var and @@global.var of the SELECT.
+func evalSystemVar(s *Session, expr sqlparser.Expr) (sqltypes.Value, bool) {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok || !col.Qualifier.Qualifier.IsEmpty() {
+		return sqltypes.Value{}, false
+	}
+	name := col.Name.String()
+	switch strings.ToLower(col.Qualifier.Name.String()) {
+	case "":
+		if !strings.HasPrefix(name, "@@") {
+			return sqltypes.Value{}, false
+		}
+		return s.SystemVariable(name[2:])
+	case "@@session", "@@local":
+		return s.SystemVariable(name)
+	case "@@global":
+		s.mu.RLock()
+		globals := s.globals
+		s.mu.RUnlock()
+		return globals.Get(name)
+	}
+	return sqltypes.Value{}, false
+}
+
+// showVariables answers the SHOW [GLOBAL|SESSION] VARIABLES [LIKE 'pattern'],
+// the WHERE is answered if it only compares the Variable_name, otherwise it's left to the handler.
+func (s *Session) showVariables(query string) (*sqltypes.Result, bool) {
+	query = strings.TrimRight(sqlparser.StripLeadingComments(query), "; \t\r\n")
+	if sqlparser.Preview(query) != sqlparser.StmtShow {
+		return nil, false
+	}
+	m := showVariablesRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+
+	patterns, ok := showPatterns(m[2]+m[3], m[4])
+	if !ok {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	globals := s.globals
+	s.mu.RUnlock()
+	global := strings.EqualFold(m[1], "global")
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Variable_name", Type: querypb.Type_VARCHAR, Charset: s.fieldCharset(querypb.Type_VARCHAR)},
+			{Name: "Value", Type: querypb.Type_VARCHAR, Charset: s.fieldCharset(querypb.Type_VARCHAR)},
+		},
+	}
+	for _, name := range globals.Names() {
+		if !matchAny(patterns, name) {
+			continue
+		}
+		var v sqltypes.Value
+		if global {
+			v, _ = globals.Get(name)
+		} else {
+			v, _ = s.SystemVariable(name)
+		}
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.NewVarChar(name),
+			sqltypes.NewVarChar(v.String()),
+		})
+	}
+	return qr, true
+}
+
+// showPatterns returns the patterns of the names of the LIKE or the WHERE of the SHOW VARIABLES and the SHOW STATUS,
+// it's false if the WHERE compares more than the Variable_name. No patterns match all the names.
+func showPatterns(like, where string) ([]*regexp.Regexp, bool) {
+	var patterns []*regexp.Regexp
+	switch {
+	case like != "":
+		patterns = append(patterns, likeRegexp(like))
+	case where != "":
+		for _, term := range orRegexp.Split(strings.TrimSpace(where), -1) {
+			t := variableNameRegexp.FindStringSubmatch(term)
+			if t == nil {
+				return nil, false
+			}
+			pattern := t[2] + t[3]
+			if t[1] == "=" {
+				pattern = strings.Replace(strings.Replace(pattern, `%`, `\%`, -1), `_`, `\_`, -1)
+			}
+			patterns = append(patterns, likeRegexp(pattern))
+		}
+	}
+	return patterns, true
+}
+
+// likeRegexp converts the LIKE pattern to the case insensitive regexp.
+func likeRegexp(pattern string) *regexp.Regexp {
+	var buf bytes.Buffer
+	buf.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch ch := pattern[i]; ch {
+		case '%':
+			buf.WriteString(".*")
+		case '_':
+			buf.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(ch)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.MustCompile(buf.String())
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if p.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,232 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+const (
+	// DefaultThreadPoolWorkers is the worker goroutines of the ThreadPool if the Workers is zero.
+	DefaultThreadPoolWorkers = 128
+
+	// DefaultThreadPoolQueueSize is the commands the ThreadPool queues if the QueueSize is zero.
+	DefaultThreadPoolQueueSize = 1024
+
+	// threadPoolIdleTimeout is the time an idle worker waits for the commands before it exits.
+	threadPoolIdleTimeout = time.Minute
+)
+
+const (
+	poolTaskQueued int32 = iota
+	poolTaskRunning
+	poolTaskCanceled
+)
+
+// ThreadPool executes the commands of the sessions on a bounded set of the worker goroutines,
+// like the thread pool plugin of MySQL instead of the goroutine per connection executing without limit.
+// The session reads its command and hands it to the queue of the pool, one of the Workers executes it
+// and the session waits for the result before it reads the next one, the idle sessions hold no worker.
+// The commands of all the sessions are multiplexed on the workers, a storm of the connections can't run
+// more than the Workers commands at once.
+// The commands wait in the queue if all the workers are busy, they are rejected with the ER_TOO_MANY_USER_CONNECTIONS
+// once the queue is full or they waited the QueueTimeout, the session stays open.
+// The workers are started on demand and exit once they are idle for a minute.
+// The COM_QUIT, the COM_PROCESS_KILL and the KILL statement, the commands without the response and the binlog streams bypass the pool.
+// The zero values are the defaults, it must not be changed after it's set to the listener.
+type ThreadPool struct {
+	// Workers is the worker goroutines executing the commands.
+	Workers int
+
+	// QueueSize is the commands waiting for the workers.
+	QueueSize int
+
+	// QueueTimeout is the time a command waits for the workers, zero waits until one is free.
+	QueueTimeout time.Duration
+
+	rejected uint64
+	active   int32
+
+	once    sync.Once
+	size    int
+	tasks   chan *poolTask
+	mu      sync.Mutex
+	running int
+}
+
+// ThreadPoolStats is the snapshot of the ThreadPool.
+type ThreadPoolStats struct {
+	// Workers is the size of the pool.
+	Workers int
+
+	// Active is the commands being executed, Queued is the ones waiting for the workers.
+	Active int
+	Queued int
+
+	// Rejected is the commands rejected by the overloaded pool.
+	Rejected uint64
+}
+
+// poolTask is a command handed to the workers.
+type poolTask struct {
+	fn    func()
+	state int32
+	panic interface{}
+	done  chan struct{}
+}
+
+func (p *ThreadPool) init() {
+	p.once.Do(func() {
+		p.size = p.Workers
+		queue := p.QueueSize
+		if p.size <= 0 {
+			p.size = DefaultThreadPoolWorkers
+		}
+		if queue <= 0 {
+			queue = DefaultThreadPoolQueueSize
+		}
+		p.tasks = make(chan *poolTask, queue)
+	})
+}
+
+// execute runs the fn on a worker and waits until it returns, the panic of the fn is raised again here.
+// It returns the ER_TOO_MANY_USER_CONNECTIONS without running the fn if the queue is full or the fn
+// waited the QueueTimeout for a worker.
+func (p *ThreadPool) execute(fn func()) error {
+	p.init()
+	task := &poolTask{fn: fn, done: make(chan struct{})}
+	select {
+	case p.tasks <- task:
+	default:
+		return p.overloaded()
+	}
+	p.spawn()
+
+	if p.QueueTimeout > 0 {
+		timer := time.NewTimer(p.QueueTimeout)
+		defer timer.Stop()
+		select {
+		case <-task.done:
+		case <-timer.C:
+			// The task still in the queue is skipped by the workers, the running one is waited for.
+			if atomic.CompareAndSwapInt32(&task.state, poolTaskQueued, poolTaskCanceled) {
+				return p.overloaded()
+			}
+			<-task.done
+		}
+	} else {
+		<-task.done
+	}
+	if task.panic != nil {
+		panic(task.panic)
+	}
+	return nil
+}
+
+// spawn starts a worker unless all the Workers are running.
+func (p *ThreadPool) spawn() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running < p.size {
+		p.running++
+		go p.work()
+	}
+}
+
+// work executes the queued commands until the worker is idle for the threadPoolIdleTimeout.
+func (p *ThreadPool) work() {
+	timer := time.NewTimer(threadPoolIdleTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case task := <-p.tasks:
+			p.run(task)
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(threadPoolIdleTimeout)
+		case <-timer.C:
+			// The commands queued before the exit is counted are taken by this worker,
+			// the later ones spawn another.
+			p.mu.Lock()
+			if len(p.tasks) > 0 {
+				p.mu.Unlock()
+				timer.Reset(threadPoolIdleTimeout)
+				continue
+			}
+			p.running--
+			p.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (p *ThreadPool) run(task *poolTask) {
+	if !atomic.CompareAndSwapInt32(&task.state, poolTaskQueued, poolTaskRunning) {
+		return
+	}
+	atomic.AddInt32(&p.active, 1)
+	defer func() {
+		task.panic = recover()
+		atomic.AddInt32(&p.active, -1)
+		close(task.done)
+	}()
+	task.fn()
+}
+
+func (p *ThreadPool) overloaded() error {
+	atomic.AddUint64(&p.rejected, 1)
+	return sqldb.NewSQLError(sqldb.ER_TOO_MANY_USER_CONNECTIONS, "Thread pool is overloaded: %d workers busy and %d commands queued", p.size, cap(p.tasks))
+}
+
+// Stats returns the snapshot of the pool.
+func (p *ThreadPool) Stats() ThreadPoolStats {
+	p.init()
+	return ThreadPoolStats{
+		Workers:  p.size,
+		Active:   int(atomic.LoadInt32(&p.active)),
+		Queued:   len(p.tasks),
+		Rejected: atomic.LoadUint64(&p.rejected),
+	}
+}
+
+// pooled checks whether the command executes on the workers of the pool, q is the parsed COM_QUERY.
+// The ones without the response can't be rejected, the KILL must get through the overloaded pool
+// and the binlog streams would hold a worker until the session ends.
+func pooled(command byte, q *comQuery) bool {
+	switch command {
+	case sqldb.COM_QUIT, sqldb.COM_PROCESS_KILL, sqldb.COM_STMT_SEND_LONG_DATA, sqldb.COM_STMT_CLOSE,
+		sqldb.COM_BINLOG_DUMP, sqldb.COM_BINLOG_DUMP_GTID:
+		return false
+	case sqldb.COM_QUERY:
+		// The KILL sent as the COM_QUERY bypasses the pool like the COM_PROCESS_KILL.
+		return q.err != nil || sqlparser.Preview(q.query) != sqlparser.StmtKill
+	}
+	return true
+}
+
+// SetThreadPool executes the commands of the sessions on the workers of the pool, nil executes them without limit.
+// It applies to the commands read after it's set.
+func (l *Listener) SetThreadPool(pool *ThreadPool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.pool = pool
+}
+
+func (l *Listener) threadPool() *ThreadPool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.pool
+}
@@ -0,0 +1,144 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestThreadPool(t *testing.T) {
+	// Timed out in the queue.
+	pool := &ThreadPool{Workers: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond}
+	busy, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- pool.execute(func() {
+			close(busy)
+			<-release
+		})
+	}()
+	<-busy
+	ran := false
+	err := pool.execute(func() { ran = true })
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_TOO_MANY_USER_CONNECTIONS), num)
+	assert.False(t, ran)
+	close(release)
+	assert.Nil(t, <-done)
+
+	// The queue is full.
+	pool = &ThreadPool{Workers: 1, QueueSize: 1}
+	busy, release = make(chan struct{}), make(chan struct{})
+	go func() {
+		done <- pool.execute(func() {
+			close(busy)
+			<-release
+		})
+	}()
+	<-busy
+	queued := make(chan error)
+	go func() {
+		queued <- pool.execute(func() { ran = true })
+	}()
+	for pool.Stats().Queued == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	err = pool.execute(func() {})
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_TOO_MANY_USER_CONNECTIONS), num)
+	assert.Equal(t, ThreadPoolStats{Workers: 1, Active: 1, Queued: 1, Rejected: 1}, pool.Stats())
+	close(release)
+	assert.Nil(t, <-done)
+	assert.Nil(t, <-queued)
+	assert.True(t, ran)
+	assert.Equal(t, ThreadPoolStats{Workers: 1, Rejected: 1}, pool.Stats())
+
+	// The commands are multiplexed on the workers.
+	pool = &ThreadPool{Workers: 2}
+	var max, active int32
+	errs := make(chan error)
+	for i := 0; i < 8; i++ {
+		go func() {
+			errs <- pool.execute(func() {
+				n := atomic.AddInt32(&active, 1)
+				for {
+					m := atomic.LoadInt32(&max)
+					if n <= m || atomic.CompareAndSwapInt32(&max, m, n) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				atomic.AddInt32(&active, -1)
+			})
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		assert.Nil(t, <-errs)
+	}
+	assert.True(t, atomic.LoadInt32(&max) <= 2)
+
+	// The panic is raised to the session.
+	assert.Panics(t, func() { pool.execute(func() { panic("x") }) })
+
+	assert.Equal(t, DefaultThreadPoolWorkers, (&ThreadPool{}).Stats().Workers)
+	assert.False(t, pooled(sqldb.COM_PROCESS_KILL, nil))
+	assert.True(t, pooled(sqldb.COM_PING, nil))
+	assert.True(t, pooled(sqldb.COM_QUERY, &comQuery{query: "select 1"}))
+	assert.False(t, pooled(sqldb.COM_QUERY, &comQuery{query: "kill query 1"}))
+}
+
+func TestListenerThreadPool(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	pool := &ThreadPool{Workers: 1, QueueSize: 1, QueueTimeout: 50 * time.Millisecond}
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, ThreadPool: pool}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	th.AddQueryDelay("select sleep", &sqltypes.Result{}, 300)
+	th.AddQuery("select 1", &sqltypes.Result{})
+
+	slow, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer slow.Close()
+	client, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	done := make(chan error)
+	go func() {
+		_, err := slow.FetchAll("select sleep", -1)
+		done <- err
+	}()
+	for pool.Stats().Active == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The worker is busy, the command is rejected but the session stays open.
+	_, err = client.FetchAll("select 1", -1)
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_TOO_MANY_USER_CONNECTIONS), num)
+
+	// The KILL gets through the busy worker.
+	assert.Nil(t, client.Exec(fmt.Sprintf("kill query %d", slow.ConnectionID())))
+	assert.NotNil(t, <-done)
+	_, err = client.FetchAll("select 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, pool.Stats().Active)
+	assert.Equal(t, uint64(1), pool.Stats().Rejected)
+}
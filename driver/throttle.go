@@ -0,0 +1,381 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// DefaultAuthThrottleMinDelay is the first delay of the AuthThrottle if the MinDelay is zero.
+	DefaultAuthThrottleMinDelay = time.Second
+
+	// DefaultAuthThrottleMaxDelay is the max delay of the AuthThrottle if the MaxDelay is zero.
+	DefaultAuthThrottleMaxDelay = 30 * time.Second
+
+	// DefaultAuthThrottleLockoutDuration is the block time of the AuthThrottle if the LockoutDuration is zero.
+	DefaultAuthThrottleLockoutDuration = 15 * time.Minute
+
+	// DefaultAuthThrottleWindow is the time the failures are kept if the Window is zero.
+	DefaultAuthThrottleWindow = time.Hour
+
+	// DefaultAuthThrottleMaxEntries is the users and the hosts the AuthThrottle keeps if the MaxEntries is zero.
+	DefaultAuthThrottleMaxEntries = 64 * 1024
+
+	// authThrottleHostUsers is the users of a host the failures are counted for.
+	authThrottleHostUsers = 1024
+)
+
+// AuthEventType is the type of the AuthEvent.
+type AuthEventType int
+
+const (
+	// AuthEventFailed is a failed authentication.
+	AuthEventFailed AuthEventType = iota
+
+	// AuthEventDelayed is an authentication delayed by the former failures.
+	AuthEventDelayed
+
+	// AuthEventLocked is the user or the host blocked by the LockoutThreshold failures.
+	AuthEventLocked
+
+	// AuthEventBlocked is an authentication rejected while the user or the host is blocked.
+	AuthEventBlocked
+)
+
+// String returns the name of the type.
+func (t AuthEventType) String() string {
+	switch t {
+	case AuthEventFailed:
+		return "failed"
+	case AuthEventDelayed:
+		return "delayed"
+	case AuthEventLocked:
+		return "locked"
+	case AuthEventBlocked:
+		return "blocked"
+	}
+	return "unknown"
+}
+
+// AuthEvent is the audit event of the AuthThrottle.
+type AuthEvent struct {
+	Type AuthEventType
+	Time time.Time
+	User string
+	Host string
+
+	// Failures is the consecutive failures of the user or the host, the larger one.
+	Failures int
+
+	// Delay is the delay of the AuthEventDelayed and the block time of the AuthEventLocked.
+	Delay time.Duration
+
+	// Err is the error of the AuthEventFailed.
+	Err error
+}
+
+// AuthThrottle delays and blocks the authentications of the users and the hosts failed consecutively,
+// like the connection_control plugin and the FAILED_LOGIN_ATTEMPTS of MySQL.
+// The failures of the user and the host are counted apart, a successful authentication clears the ones of the user
+// and takes the ones of the user out of the host: a valid account can't clear the failures of the others on its host.
+// The delay runs in the handshake, the HandshakeTimeout of the listener should be longer than the MaxDelay.
+// The zero values are the defaults, it must not be changed after it's set to the listener.
+type AuthThrottle struct {
+	// Threshold is the consecutive failures the delays start from, zero disables the delays.
+	Threshold int
+
+	// MinDelay is the delay of the Threshold failures, it doubles on every failure up to the MaxDelay.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// LockoutThreshold is the consecutive failures the user or the host is blocked by, zero disables the blocks.
+	// The blocked one is rejected with the ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK
+	// for the LockoutDuration, then its failures are cleared.
+	LockoutThreshold int
+	LockoutDuration  time.Duration
+
+	// Window is the time the failures are kept from the last one.
+	Window time.Duration
+
+	// MaxEntries is the users and the hosts the failures are kept for, the ones failed least recently are
+	// evicted once it's reached, the failures of a flood of the users or the hosts can't grow without bound.
+	MaxEntries int
+
+	// Audit is called with the events of the throttle, nil disables them.
+	Audit func(event *AuthEvent)
+
+	mu       sync.Mutex
+	failures map[string]*authFailures
+
+	// lru is the entries by the last failure, the least recent first.
+	lru *list.List
+}
+
+type authFailures struct {
+	key    string
+	elem   *list.Element
+	count  int
+	last   time.Time
+	locked time.Time
+
+	// users is the failures of the users on the host, nil for the user entries.
+	users map[string]int
+}
+
+func (t *AuthThrottle) window() time.Duration {
+	if t.Window > 0 {
+		return t.Window
+	}
+	return DefaultAuthThrottleWindow
+}
+
+func (t *AuthThrottle) maxEntries() int {
+	if t.MaxEntries > 0 {
+		return t.MaxEntries
+	}
+	return DefaultAuthThrottleMaxEntries
+}
+
+func (t *AuthThrottle) lockoutDuration() time.Duration {
+	if t.LockoutDuration > 0 {
+		return t.LockoutDuration
+	}
+	return DefaultAuthThrottleLockoutDuration
+}
+
+func (t *AuthThrottle) delay(failures int) time.Duration {
+	if t.Threshold <= 0 || failures < t.Threshold {
+		return 0
+	}
+	min, max := t.MinDelay, t.MaxDelay
+	if min <= 0 {
+		min = DefaultAuthThrottleMinDelay
+	}
+	if max <= 0 {
+		max = DefaultAuthThrottleMaxDelay
+	}
+	delay := min
+	for i := t.Threshold; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+// entry returns the live failures of the key, the expired ones are removed.
+func (t *AuthThrottle) entry(key string, now time.Time) *authFailures {
+	f, ok := t.failures[key]
+	if !ok {
+		return nil
+	}
+	expired := now.Sub(f.last) > t.window()
+	if !f.locked.IsZero() {
+		expired = !now.Before(f.locked)
+	}
+	if expired {
+		t.remove(f)
+		return nil
+	}
+	return f
+}
+
+func (t *AuthThrottle) remove(f *authFailures) {
+	delete(t.failures, f.key)
+	t.lru.Remove(f.elem)
+}
+
+// prune removes the expired entries from the least recent one until a live one,
+// every failure does a bit of it instead of scanning all the entries.
+func (t *AuthThrottle) prune(now time.Time) {
+	for e := t.lru.Front(); e != nil; e = t.lru.Front() {
+		f := e.Value.(*authFailures)
+		if t.entry(f.key, now) != nil {
+			return
+		}
+	}
+}
+
+func (t *AuthThrottle) audit(event *AuthEvent) {
+	if t.Audit != nil {
+		t.Audit(event)
+	}
+}
+
+// wait blocks the authentication of the user and the host by the former failures,
+// it returns the error if one of them is blocked.
+func (t *AuthThrottle) wait(user, host string) error {
+	if t == nil {
+		return nil
+	}
+	now := time.Now()
+	failures := 0
+	blocked := false
+	t.mu.Lock()
+	for _, key := range []string{"user:" + user, "host:" + host} {
+		if f := t.entry(key, now); f != nil {
+			if f.count > failures {
+				failures = f.count
+			}
+			blocked = blocked || !f.locked.IsZero()
+		}
+	}
+	t.mu.Unlock()
+
+	event := &AuthEvent{Time: now, User: user, Host: host, Failures: failures}
+	if blocked {
+		event.Type = AuthEventBlocked
+		t.audit(event)
+		return sqldb.NewSQLError(sqldb.ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK, "Access denied for user '%s'@'%s'. Account is blocked for %v due to %d consecutive failed logins.", user, host, t.lockoutDuration(), failures)
+	}
+	if delay := t.delay(failures); delay > 0 {
+		event.Type = AuthEventDelayed
+		event.Delay = delay
+		t.audit(event)
+		time.Sleep(delay)
+	}
+	return nil
+}
+
+// failed counts the failure of the user and the host.
+func (t *AuthThrottle) failed(user, host string, err error) {
+	if t == nil {
+		return
+	}
+	now := time.Now()
+	failures := 0
+	locked := false
+	t.mu.Lock()
+	if t.failures == nil {
+		t.failures = make(map[string]*authFailures)
+		t.lru = list.New()
+	}
+	t.prune(now)
+	keys := []string{"user:" + user, "host:" + host}
+	t.evict(keys...)
+	for i, key := range keys {
+		f := t.entry(key, now)
+		if f == nil {
+			f = &authFailures{key: key}
+			f.elem = t.lru.PushBack(f)
+			t.failures[key] = f
+		}
+		f.count++
+		f.last = now
+		t.lru.MoveToBack(f.elem)
+		if i == 1 {
+			if f.users == nil {
+				f.users = make(map[string]int)
+			}
+			// The users flooding the host are counted to the host only.
+			if _, ok := f.users[user]; ok || len(f.users) < authThrottleHostUsers {
+				f.users[user]++
+			}
+		}
+		if t.LockoutThreshold > 0 && f.count >= t.LockoutThreshold && f.locked.IsZero() {
+			f.locked = now.Add(t.lockoutDuration())
+			locked = true
+		}
+		if f.count > failures {
+			failures = f.count
+		}
+	}
+	t.mu.Unlock()
+
+	t.audit(&AuthEvent{Type: AuthEventFailed, Time: now, User: user, Host: host, Failures: failures, Err: err})
+	if locked {
+		t.audit(&AuthEvent{Type: AuthEventLocked, Time: now, User: user, Host: host, Failures: failures, Delay: t.lockoutDuration()})
+	}
+}
+
+// evict removes the entries failed least recently until there is the room for the keys, the keys are kept.
+func (t *AuthThrottle) evict(keys ...string) {
+	e := t.lru.Front()
+next:
+	for e != nil && len(t.failures)+len(keys) > t.maxEntries() {
+		f := e.Value.(*authFailures)
+		e = e.Next()
+		for _, key := range keys {
+			if f.key == key {
+				continue next
+			}
+		}
+		t.remove(f)
+	}
+}
+
+// succeeded clears the failures of the user and takes the ones of the user out of the host.
+func (t *AuthThrottle) succeeded(user, host string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if f, ok := t.failures["user:"+user]; ok {
+		t.remove(f)
+	}
+	if f, ok := t.failures["host:"+host]; ok && f.locked.IsZero() {
+		f.count -= f.users[user]
+		delete(f.users, user)
+		if f.count <= 0 {
+			t.remove(f)
+		}
+	}
+}
+
+// inherit takes over the failures and the lockouts of the former throttle, they go on under the settings of the t.
+func (t *AuthThrottle) inherit(former *AuthThrottle) {
+	former.mu.Lock()
+	defer former.mu.Unlock()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if former.lru == nil {
+		return
+	}
+	if t.failures == nil {
+		t.failures = make(map[string]*authFailures)
+		t.lru = list.New()
+	}
+	for e := former.lru.Front(); e != nil; e = e.Next() {
+		f := e.Value.(*authFailures)
+		if _, ok := t.failures[f.key]; ok {
+			continue
+		}
+		inherited := &authFailures{key: f.key, count: f.count, last: f.last, locked: f.locked}
+		if f.users != nil {
+			inherited.users = make(map[string]int, len(f.users))
+			for user, count := range f.users {
+				inherited.users[user] = count
+			}
+		}
+		inherited.elem = t.lru.PushBack(inherited)
+		t.failures[f.key] = inherited
+	}
+	t.evict()
+}
+
+// SetAuthThrottle sets the throttle of the failed authentications of the coming sessions, nil disables it.
+func (l *Listener) SetAuthThrottle(throttle *AuthThrottle) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.throttle = throttle
+}
+
+func (l *Listener) authThrottle() *AuthThrottle {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.throttle
+}
@@ -0,0 +1,143 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthThrottleDelay(t *testing.T) {
+	throttle := &AuthThrottle{Threshold: 3, MinDelay: time.Second, MaxDelay: 5 * time.Second}
+	assert.Equal(t, time.Duration(0), throttle.delay(2))
+	assert.Equal(t, time.Second, throttle.delay(3))
+	assert.Equal(t, 2*time.Second, throttle.delay(4))
+	assert.Equal(t, 4*time.Second, throttle.delay(5))
+	assert.Equal(t, 5*time.Second, throttle.delay(6))
+	assert.Equal(t, 5*time.Second, throttle.delay(100))
+	assert.Equal(t, time.Duration(0), (&AuthThrottle{}).delay(100))
+	assert.Equal(t, DefaultAuthThrottleMinDelay, (&AuthThrottle{Threshold: 1}).delay(1))
+}
+
+func TestAuthThrottleEntries(t *testing.T) {
+	throttle := &AuthThrottle{Threshold: 2, MaxEntries: 4}
+	throttle.failed("a", "h1", nil)
+	throttle.failed("b", "h2", nil)
+	// The least recent ones are evicted.
+	throttle.failed("c", "h3", nil)
+	assert.Equal(t, 4, len(throttle.failures))
+	assert.Nil(t, throttle.failures["user:a"])
+	assert.Nil(t, throttle.failures["host:h1"])
+	assert.NotNil(t, throttle.failures["user:c"])
+	// The failure makes the entry the most recent one.
+	throttle.failed("b", "h2", nil)
+	throttle.failed("d", "h4", nil)
+	assert.NotNil(t, throttle.failures["user:b"])
+	assert.Nil(t, throttle.failures["user:c"])
+	assert.Equal(t, 4, throttle.lru.Len())
+
+	// The expired ones are pruned by the coming failures.
+	throttle = &AuthThrottle{Window: time.Millisecond}
+	throttle.failed("a", "h1", nil)
+	throttle.failed("b", "h2", nil)
+	time.Sleep(2 * time.Millisecond)
+	throttle.failed("c", "h3", nil)
+	assert.Equal(t, 2, len(throttle.failures))
+	assert.Equal(t, 2, throttle.lru.Len())
+
+	// The success takes only the failures of the user out of the host.
+	throttle = &AuthThrottle{Threshold: 2}
+	throttle.failed("victim", "h", nil)
+	throttle.failed("victim", "h", nil)
+	throttle.failed("mock", "h", nil)
+	throttle.succeeded("mock", "h")
+	assert.Equal(t, 2, throttle.failures["host:h"].count)
+	assert.Equal(t, 2, throttle.failures["user:victim"].count)
+	throttle.succeeded("victim", "h")
+	assert.Equal(t, 0, len(throttle.failures))
+}
+
+func TestListenerAuthThrottle(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	var mu sync.Mutex
+	var events []AuthEventType
+	throttle := &AuthThrottle{
+		Threshold:        2,
+		MinDelay:         50 * time.Millisecond,
+		LockoutThreshold: 4,
+		LockoutDuration:  300 * time.Millisecond,
+		Audit: func(event *AuthEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, "127.0.0.1", event.Host)
+			events = append(events, event.Type)
+		},
+	}
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{Log: log, AuthThrottle: throttle}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	connect := func(user string) (time.Duration, error) {
+		start := time.Now()
+		client, err := NewConn(user, "", svr.Addr(), "", "")
+		if err == nil {
+			client.Close()
+		}
+		return time.Since(start), err
+	}
+
+	// The failures are delayed from the threshold.
+	_, err = connect("other")
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+	_, err = connect("other")
+	assert.NotNil(t, err)
+	took, err := connect("other")
+	assert.NotNil(t, err)
+	assert.Truef(t, took >= 50*time.Millisecond, "%v", took)
+	took, err = connect("other")
+	assert.NotNil(t, err)
+	assert.Truef(t, took >= 100*time.Millisecond, "%v", took)
+
+	// Blocked, the host too.
+	_, err = connect("other")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK), num)
+	_, err = connect("mock")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK), num)
+
+	// The block expired, the success clears the failures.
+	time.Sleep(300 * time.Millisecond)
+	_, err = connect("mock")
+	assert.Nil(t, err)
+	took, err = connect("mock")
+	assert.Nil(t, err)
+	assert.Truef(t, took < 50*time.Millisecond, "%v", took)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []AuthEventType{
+		AuthEventFailed,
+		AuthEventFailed,
+		AuthEventDelayed, AuthEventFailed,
+		AuthEventDelayed, AuthEventFailed, AuthEventLocked,
+		AuthEventBlocked,
+		AuthEventBlocked,
+	}
+	assert.Equal(t, want, events)
+	assert.Equal(t, "locked", AuthEventLocked.String())
+}
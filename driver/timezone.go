@@ -0,0 +1,113 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var timeZoneOffsetRegexp = regexp.MustCompile(`^([+-])(\d{1,2}):(\d{2})$`)
+
+// parseTimeZone parses the time_zone value, it's the SYSTEM, the offset like '+08:00' or the named zone like 'Asia/Shanghai'.
+func parseTimeZone(name string) (*time.Location, error) {
+	if strings.EqualFold(name, "system") {
+		return time.Local, nil
+	}
+	if m := timeZoneOffsetRegexp.FindStringSubmatch(name); m != nil {
+		hour, _ := strconv.Atoi(m[2])
+		minute, _ := strconv.Atoi(m[3])
+		offset := hour*3600 + minute*60
+		if minute > 59 || offset > 14*3600 || (m[1] == "-" && offset > 13*3600+59*60) {
+			return nil, errUnknownTimeZone(name)
+		}
+		if m[1] == "-" {
+			offset = -offset
+		}
+		return time.FixedZone(name, offset), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "" || strings.EqualFold(name, "local") {
+		return nil, errUnknownTimeZone(name)
+	}
+	return loc, nil
+}
+
+func errUnknownTimeZone(name string) error {
+	return sqldb.NewSQLError(sqldb.ER_UNKNOWN_TIME_ZONE, "Unknown or incorrect time zone: '%s'", name)
+}
+
+// systemTimeZone returns the zone abbreviation of the process like 'UTC' or 'CST', it's the @@system_time_zone.
+func systemTimeZone() string {
+	name, _ := time.Now().Zone()
+	return name
+}
+
+// TimeZone returns the location of the session time_zone, it's time.Local for the SYSTEM.
+// The handler formats and parses the TIMESTAMP values of the session by it.
+func (s *Session) TimeZone() *time.Location {
+	v, _ := s.SystemVariable("time_zone")
+	name := v.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.timeZone != nil && s.timeZoneName == name {
+		return s.timeZone
+	}
+	loc, err := parseTimeZone(name)
+	if err != nil {
+		loc = time.Local
+	}
+	s.timeZone, s.timeZoneName = loc, name
+	return loc
+}
+
+// convertTimestamps returns the result with the TIMESTAMP values converted from the zone of the handler
+// to the session time_zone, the result of the handler is not modified.
+func (s *Session) convertTimestamps(result *sqltypes.Result) *sqltypes.Result {
+	s.mu.RLock()
+	from := s.resultTimeZone
+	s.mu.RUnlock()
+	if from == nil || len(result.Rows) == 0 {
+		return result
+	}
+
+	var cols []int
+	for i, f := range result.Fields {
+		if f.Type == sqltypes.Timestamp {
+			cols = append(cols, i)
+		}
+	}
+	to := s.TimeZone()
+	if len(cols) == 0 || to.String() == from.String() {
+		return result
+	}
+
+	qr := *result
+	qr.Rows = make([][]sqltypes.Value, len(result.Rows))
+	for i, row := range result.Rows {
+		converted := append([]sqltypes.Value(nil), row...)
+		for _, col := range cols {
+			if col >= len(converted) || converted[col].IsNull() {
+				continue
+			}
+			if v, err := sqltypes.ConvertTimeZone(converted[col], from, to); err == nil {
+				converted[col] = v
+			}
+		}
+		qr.Rows[i] = converted
+	}
+	return &qr
+}
@@ -0,0 +1,185 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+)
+
+// Tx is the transaction handle passed to the Conn.Transaction fn.
+type Tx struct {
+	c *conn
+
+	// savepoints is the stack of the active savepoints.
+	savepoints []string
+	seq        int
+}
+
+// Exec executes the query in the transaction.
+func (tx *Tx) Exec(sql string) error {
+	return tx.c.Exec(sql)
+}
+
+// Query executes the query in the transaction and returns the row cursor.
+func (tx *Tx) Query(sql string) (Rows, error) {
+	return tx.c.Query(sql)
+}
+
+// FetchAll fetchs all results in the transaction.
+func (tx *Tx) FetchAll(sql string, maxrows int) (*sqltypes.Result, error) {
+	return tx.c.FetchAll(sql, maxrows)
+}
+
+// Savepoint sets a named savepoint, a name like sp_1 is generated if the name is empty.
+// Returns the savepoint name.
+func (tx *Tx) Savepoint(name string) (string, error) {
+	if name == "" {
+		tx.seq++
+		name = fmt.Sprintf("sp_%d", tx.seq)
+	}
+	if err := tx.c.Exec("SAVEPOINT " + escape.QuoteIdentifier(name)); err != nil {
+		return "", err
+	}
+	// MySQL replaces the old savepoint with the same name.
+	if i := tx.savepointIndex(name); i >= 0 {
+		tx.savepoints = append(tx.savepoints[:i], tx.savepoints[i+1:]...)
+	}
+	tx.savepoints = append(tx.savepoints, name)
+	return name, nil
+}
+
+// RollbackTo rolls back to the savepoint, the savepoints set after it are removed.
+func (tx *Tx) RollbackTo(name string) error {
+	i := tx.savepointIndex(name)
+	if i < 0 {
+		return fmt.Errorf("driver.tx.savepoint[%s].does.not.exist", name)
+	}
+	if err := tx.c.Exec("ROLLBACK TO SAVEPOINT " + escape.QuoteIdentifier(name)); err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i+1]
+	return nil
+}
+
+// Release releases the savepoint and the savepoints set after it.
+func (tx *Tx) Release(name string) error {
+	i := tx.savepointIndex(name)
+	if i < 0 {
+		return fmt.Errorf("driver.tx.savepoint[%s].does.not.exist", name)
+	}
+	if err := tx.c.Exec("RELEASE SAVEPOINT " + escape.QuoteIdentifier(name)); err != nil {
+		return err
+	}
+	tx.savepoints = tx.savepoints[:i]
+	return nil
+}
+
+// Savepoints returns the active savepoints, the innermost is the last.
+func (tx *Tx) Savepoints() []string {
+	return tx.savepoints
+}
+
+// Transaction runs the fn in a nested transaction over a generated savepoint.
+// If the fn returns error or panics, rolls back to the savepoint, otherwise releases it.
+// The error of the fn is returned like the conn.Transaction, the failed rollback is wrapped in it.
+func (tx *Tx) Transaction(fn func(tx *Tx) error) (err error) {
+	var name string
+	if name, err = tx.Savepoint(""); err != nil {
+		return err
+	}
+
+	defer func() {
+		if x := recover(); x != nil {
+			tx.RollbackTo(name)
+			panic(x)
+		}
+	}()
+
+	if err = fn(tx); err != nil {
+		if rerr := tx.RollbackTo(name); rerr != nil {
+			return fmt.Errorf("%w, driver.tx.rollback.to.savepoint[%s].error:%v", err, name, rerr)
+		}
+		// The savepoint is useless after rollback.
+		tx.Release(name)
+		return err
+	}
+	return tx.Release(name)
+}
+
+func (tx *Tx) savepointIndex(name string) int {
+	for i := len(tx.savepoints) - 1; i >= 0; i-- {
+		if tx.savepoints[i] == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *conn) setStatus(status uint16, warnings uint16) {
+	c.status = status
+	c.warnings = warnings
+}
+
+// Status returns the server status flags from the last OK/EOF packet.
+func (c *conn) Status() uint16 {
+	return c.status
+}
+
+// WarningCount returns the warning count from the last OK/EOF packet.
+func (c *conn) WarningCount() uint16 {
+	return c.warnings
+}
+
+// InTransaction checks the SERVER_STATUS_IN_TRANS flag reported by the server.
+func (c *conn) InTransaction() bool {
+	return (c.status & sqldb.SERVER_STATUS_IN_TRANS) > 0
+}
+
+// Begin starts a transaction.
+func (c *conn) Begin() error {
+	return c.Exec("BEGIN")
+}
+
+// Commit commits the transaction.
+func (c *conn) Commit() error {
+	return c.Exec("COMMIT")
+}
+
+// Rollback rolls back the transaction.
+func (c *conn) Rollback() error {
+	return c.Exec("ROLLBACK")
+}
+
+// Transaction runs the fn in a transaction.
+// If the fn returns error or panics, the transaction is rolled back, otherwise committed.
+func (c *conn) Transaction(fn func(tx *Tx) error) (err error) {
+	if err = c.Begin(); err != nil {
+		return err
+	}
+
+	defer func() {
+		if x := recover(); x != nil {
+			c.Rollback()
+			panic(x)
+		}
+	}()
+
+	if err = fn(&Tx{c: c}); err != nil {
+		if rerr := c.Rollback(); rerr != nil {
+			c.Cleanup()
+		}
+		return err
+	}
+	return c.Commit()
+}
@@ -0,0 +1,276 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// mockTxnServer is a tiny server reports SERVER_STATUS_IN_TRANS like MySQL,
+// the queries are recorded in the order they came.
+type mockTxnServer struct {
+	listener net.Listener
+	queries  chan string
+}
+
+func newMockTxnServer(t *testing.T) *mockTxnServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	s := &mockTxnServer{listener: l, queries: make(chan string, 64)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(c)
+		}
+	}()
+	return s
+}
+
+func (s *mockTxnServer) handle(c net.Conn) {
+	defer c.Close()
+	status := uint16(sqldb.SERVER_STATUS_AUTOCOMMIT)
+	packets := packet.NewPackets(c)
+	if err := packets.Write(proto.NewGreeting(1).Pack()); err != nil {
+		return
+	}
+	if _, err := packets.Next(); err != nil {
+		return
+	}
+	if err := packets.WriteOK(0, 0, status, 0); err != nil {
+		return
+	}
+
+	for {
+		packets.ResetSeq()
+		data, err := packets.Next()
+		if err != nil || data[0] == sqldb.COM_QUIT {
+			return
+		}
+		query := strings.ToLower(string(data[1:]))
+		s.queries <- query
+		switch {
+		case query == "begin":
+			status |= sqldb.SERVER_STATUS_IN_TRANS
+		case query == "commit", query == "rollback":
+			status &^= sqldb.SERVER_STATUS_IN_TRANS
+		case strings.HasPrefix(query, "select"):
+			packets.AppendColumns([]*querypb.Field{{Name: "a", Type: querypb.Type_INT32}})
+			packets.Append([]byte{0x01, '1'})
+			packets.AppendOKWithEOFHeader(0, 0, status, 0)
+			packets.Flush()
+			continue
+		}
+		packets.WriteOK(0, 0, status, 0)
+	}
+}
+
+func (s *mockTxnServer) expect(t *testing.T, queries ...string) {
+	for _, want := range queries {
+		got := <-s.queries
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestClientTransaction(t *testing.T) {
+	svr := newMockTxnServer(t)
+	defer svr.listener.Close()
+	address := svr.listener.Addr().String()
+
+	client, err := NewConn("mock", "mock", address, "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+	assert.False(t, client.InTransaction())
+	assert.Equal(t, uint16(sqldb.SERVER_STATUS_AUTOCOMMIT), client.Status())
+
+	// Begin/Commit.
+	{
+		err := client.Begin()
+		assert.Nil(t, err)
+		assert.True(t, client.InTransaction())
+
+		// Status from the resultset terminator.
+		_, err = client.FetchAll("select a from t", -1)
+		assert.Nil(t, err)
+		assert.True(t, client.InTransaction())
+
+		err = client.Commit()
+		assert.Nil(t, err)
+		assert.False(t, client.InTransaction())
+		svr.expect(t, "begin", "select a from t", "commit")
+	}
+
+	// Transaction commit.
+	{
+		err := client.Transaction(func(tx *Tx) error {
+			assert.True(t, client.InTransaction())
+			return tx.Exec("insert into t values(1)")
+		})
+		assert.Nil(t, err)
+		assert.False(t, client.InTransaction())
+		svr.expect(t, "begin", "insert into t values(1)", "commit")
+	}
+
+	// Transaction rollback.
+	{
+		err := client.Transaction(func(tx *Tx) error {
+			_, err := tx.FetchAll("select a from t", -1)
+			assert.Nil(t, err)
+			return errors.New("mock.error")
+		})
+		assert.Equal(t, "mock.error", err.Error())
+		assert.False(t, client.InTransaction())
+		svr.expect(t, "begin", "select a from t", "rollback")
+	}
+
+	// Transaction panic.
+	{
+		assert.Panics(t, func() {
+			client.Transaction(func(tx *Tx) error {
+				panic("mock.panic")
+			})
+		})
+		assert.False(t, client.InTransaction())
+		svr.expect(t, "begin", "rollback")
+	}
+}
+
+func TestPoolRefuseInTransaction(t *testing.T) {
+	svr := newMockTxnServer(t)
+	defer svr.listener.Close()
+	address := svr.listener.Addr().String()
+
+	pool, err := NewPool("mock:mock@tcp("+address+")/", 1)
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	c1, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, c1.Begin())
+	assert.Equal(t, ErrConnInTransaction, pool.Put(c1))
+
+	assert.Nil(t, c1.Rollback())
+	assert.Nil(t, pool.Put(c1))
+
+	// Reuse the idle one.
+	c2, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Equal(t, c1, c2)
+
+	// Pool is full, the extra one is closed.
+	c3, err := pool.Get()
+	assert.Nil(t, err)
+	assert.Nil(t, pool.Put(c2))
+	assert.Nil(t, pool.Put(c3))
+	assert.True(t, c3.Closed())
+
+	pool.Close()
+	_, err = pool.Get()
+	assert.Equal(t, ErrPoolClosed, err)
+}
+
+func TestClientSavepoint(t *testing.T) {
+	svr := newMockTxnServer(t)
+	defer svr.listener.Close()
+	address := svr.listener.Addr().String()
+
+	client, err := NewConn("mock", "mock", address, "", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Explicit savepoints.
+	{
+		err := client.Transaction(func(tx *Tx) error {
+			sp1, err := tx.Savepoint("")
+			assert.Nil(t, err)
+			assert.Equal(t, "sp_1", sp1)
+
+			_, err = tx.Savepoint("a`b")
+			assert.Nil(t, err)
+			sp3, err := tx.Savepoint("")
+			assert.Nil(t, err)
+			assert.Equal(t, []string{"sp_1", "a`b", "sp_2"}, tx.Savepoints())
+
+			assert.Nil(t, tx.RollbackTo("a`b"))
+			assert.Equal(t, []string{"sp_1", "a`b"}, tx.Savepoints())
+			assert.NotNil(t, tx.RollbackTo(sp3))
+
+			assert.Nil(t, tx.Release(sp1))
+			assert.Equal(t, 0, len(tx.Savepoints()))
+			assert.NotNil(t, tx.Release(sp1))
+			return nil
+		})
+		assert.Nil(t, err)
+		svr.expect(t,
+			"begin",
+			"savepoint `sp_1`",
+			"savepoint `a``b`",
+			"savepoint `sp_2`",
+			"rollback to savepoint `a``b`",
+			"release savepoint `sp_1`",
+			"commit",
+		)
+	}
+
+	// Nested transactions.
+	{
+		err := client.Transaction(func(tx *Tx) error {
+			err := tx.Transaction(func(tx *Tx) error {
+				if err := tx.Exec("insert into t values(1)"); err != nil {
+					return err
+				}
+				return tx.Transaction(func(tx *Tx) error {
+					return errors.New("inner.error")
+				})
+			})
+			assert.Equal(t, "inner.error", err.Error())
+			return tx.Exec("insert into t values(2)")
+		})
+		assert.Nil(t, err)
+		svr.expect(t,
+			"begin",
+			"savepoint `sp_1`",
+			"insert into t values(1)",
+			"savepoint `sp_2`",
+			"rollback to savepoint `sp_2`",
+			"release savepoint `sp_2`",
+			"rollback to savepoint `sp_1`",
+			"release savepoint `sp_1`",
+			"insert into t values(2)",
+			"commit",
+		)
+	}
+
+	// The error of the fn is returned if the rollback failed.
+	{
+		fnErr := errors.New("fn.error")
+		err := client.Transaction(func(tx *Tx) error {
+			return tx.Transaction(func(tx *Tx) error {
+				assert.Nil(t, tx.Release(tx.Savepoints()[0]))
+				return fnErr
+			})
+		})
+		assert.True(t, errors.Is(err, fnErr))
+		assert.Contains(t, err.Error(), "driver.tx.savepoint[sp_1].does.not.exist")
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// UserVar returns the value of the user variable @name, false if it's not defined.
+func (s *Session) UserVar(name string) (sqltypes.Value, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	v, ok := s.userVars[strings.ToLower(name)]
+	return v, ok
+}
+
+// SetUserVar sets the user variable @name, the NULL value undefines it as MySQL does.
+func (s *Session) SetUserVar(name string, v sqltypes.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name = strings.ToLower(name)
+	if v.IsNull() {
+		delete(s.userVars, name)
+		return
+	}
+	if s.userVars == nil {
+		s.userVars = make(map[string]sqltypes.Value)
+	}
+	s.userVars[name] = v
+}
+
+// UserVars returns a copy of the user variables by the lowercased names.
+func (s *Session) UserVars() map[string]sqltypes.Value {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	vars := make(map[string]sqltypes.Value, len(s.userVars))
+	for k, v := range s.userVars {
+		vars[k] = v
+	}
+	return vars
+}
+
+// SubstituteUserVars replaces the @var references of the query by the session values,
+// so the query can be sent to a backend which doesn't have the variables.
+func (s *Session) SubstituteUserVars(query string) string {
+	return sqlparser.SubstituteUserVars(query, s.SQLMode(), s.UserVar)
+}
+
+// setUserVar applies the 'SET @name = value', the value which can't be evaluated here undefines the variable,
+// the substitution must not use a stale one.
+func (s *Session) setUserVar(v *sqlparser.SetVar) {
+	val, ok := s.userVarValue(v)
+	if !ok {
+		val = sqltypes.NULL
+	}
+	s.SetUserVar(v.Name, val)
+}
+
+// userVarValue evaluates the literal, the NULL, the TRUE/FALSE and the other @var.
+func (s *Session) userVarValue(v *sqlparser.SetVar) (sqltypes.Value, bool) {
+	switch v.Kind {
+	case sqlparser.SetValueString:
+		return sqltypes.NewVarChar(v.Value), true
+	case sqlparser.SetValueNumber:
+		if _, err := strconv.ParseInt(v.Value, 10, 64); err == nil {
+			return sqltypes.MakeTrusted(sqltypes.Int64, []byte(v.Value)), true
+		}
+		if _, err := strconv.ParseUint(v.Value, 10, 64); err == nil {
+			return sqltypes.MakeTrusted(sqltypes.Uint64, []byte(v.Value)), true
+		}
+		// The exponent makes a DOUBLE, otherwise a DECIMAL.
+		if strings.ContainsAny(v.Value, "eE") {
+			return sqltypes.MakeTrusted(sqltypes.Float64, []byte(v.Value)), true
+		}
+		return sqltypes.MakeTrusted(sqltypes.Decimal, []byte(v.Value)), true
+	case sqlparser.SetValueIdent:
+		switch strings.ToLower(v.Value) {
+		case "null":
+			return sqltypes.NULL, true
+		case "true":
+			return sqltypes.NewInt64(1), true
+		case "false":
+			return sqltypes.NewInt64(0), true
+		}
+	case sqlparser.SetValueExpr:
+		if name := v.Value[1:]; v.Value[0] == '@' && name != "" && strings.IndexFunc(name, isNotNameChar) < 0 {
+			if val, ok := s.UserVar(name); ok {
+				return val, true
+			}
+			return sqltypes.NULL, true
+		}
+	}
+	return sqltypes.Value{}, false
+}
+
+func isNotNameChar(r rune) bool {
+	return !(r == '_' || r == '$' || r == '.' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r >= 0x80)
+}
+
+// evalUserVar evaluates the @var of the SELECT, the undefined one is NULL.
+func evalUserVar(s *Session, expr sqlparser.Expr) (sqltypes.Value, bool) {
+	col, ok := expr.(*sqlparser.ColName)
+	if !ok || !col.Qualifier.IsEmpty() {
+		return sqltypes.Value{}, false
+	}
+	name := col.Name.String()
+	if !strings.HasPrefix(name, "@") || strings.HasPrefix(name, "@@") {
+		return sqltypes.Value{}, false
+	}
+	if v, ok := s.UserVar(name[1:]); ok {
+		return v, true
+	}
+	return sqltypes.NULL, true
+}
@@ -0,0 +1,195 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// The levels of the Warning.
+const (
+	WarningLevelNote    = "Note"
+	WarningLevelWarning = "Warning"
+	WarningLevelError   = "Error"
+)
+
+// maxWarnings is the MySQL default max_error_count, the warnings beyond are counted but not kept.
+const maxWarnings = 64
+
+// Warning is one row of the SHOW WARNINGS.
+type Warning struct {
+	Level   string
+	Code    uint16
+	Message string
+}
+
+var (
+	showWarningsRegexp = regexp.MustCompile(`(?i)^show\s+(warnings|errors)(?:\s+limit\s+(\d+)(?:\s*,\s*(\d+))?)?$`)
+	showCountRegexp    = regexp.MustCompile(`(?i)^show\s+count\(\s*\*\s*\)\s+(warnings|errors)$`)
+)
+
+// AddWarning attaches a warning to the current statement of the session,
+// the OK or EOF packet of the statement carries the warning count.
+// The warnings are kept until the next statement except the SHOW WARNINGS.
+func (s *Session) AddWarning(level string, code uint16, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.warnings) < maxWarnings {
+		s.warnings = append(s.warnings, Warning{Level: level, Code: code, Message: message})
+	}
+	if s.warningCount < 0xffff {
+		s.warningCount++
+	}
+}
+
+// Warnings returns the warnings of the last statement.
+func (s *Session) Warnings() []Warning {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Warning(nil), s.warnings...)
+}
+
+// WarningCount returns the warning count of the last statement, includes the ones not kept.
+func (s *Session) WarningCount() uint16 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.warningCount
+}
+
+func (s *Session) clearWarnings() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.warnings = nil
+	s.warningCount = 0
+}
+
+// addError keeps the error of the statement as an Error level warning, like MySQL does.
+func (s *Session) addError(err error) {
+	se := sessionError(s.translateError(err))
+	s.AddWarning(WarningLevelError, se.Num, se.Message)
+}
+
+// warningsOf returns the warning count for the OK and EOF packets,
+// the accumulated ones take precedence over the count reported by the result.
+func (s *Session) warningsOf(result *sqltypes.Result) uint16 {
+	if n := s.WarningCount(); n > 0 {
+		return n
+	}
+	return result.Warnings
+}
+
+// showWarnings answers the SHOW WARNINGS, SHOW ERRORS and their COUNT(*) forms from the accumulated list,
+// returns false if the query is not one of them.
+func (s *Session) showWarnings(query string) (*sqltypes.Result, bool) {
+	query = strings.TrimRight(sqlparser.StripLeadingComments(query), "; \t\r\n")
+	if sqlparser.Preview(query) != sqlparser.StmtShow {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	count := int(s.warningCount)
+	warnings := append([]Warning(nil), s.warnings...)
+	s.mu.RUnlock()
+	filter := func(kind string) {
+		if strings.ToLower(kind) != "errors" {
+			return
+		}
+		count = 0
+		errs := warnings[:0]
+		for _, w := range warnings {
+			if w.Level == WarningLevelError {
+				errs = append(errs, w)
+				count++
+			}
+		}
+		warnings = errs
+	}
+
+	if m := showCountRegexp.FindStringSubmatch(query); m != nil {
+		filter(m[1])
+		name := "@@session.warning_count"
+		if strings.ToLower(m[1]) == "errors" {
+			name = "@@session.error_count"
+		}
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: name, Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte(strconv.Itoa(count)))}},
+		}, true
+	}
+
+	m := showWarningsRegexp.FindStringSubmatch(query)
+	if m == nil {
+		return nil, false
+	}
+	filter(m[1])
+	// LIMIT [offset,] row_count
+	if m[2] != "" {
+		offset, limit := 0, 0
+		if m[3] != "" {
+			offset, _ = strconv.Atoi(m[2])
+			limit, _ = strconv.Atoi(m[3])
+		} else {
+			limit, _ = strconv.Atoi(m[2])
+		}
+		if offset > len(warnings) {
+			offset = len(warnings)
+		}
+		warnings = warnings[offset:]
+		if limit < len(warnings) {
+			warnings = warnings[:limit]
+		}
+	}
+
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Level", Type: querypb.Type_VARCHAR},
+			{Name: "Code", Type: querypb.Type_UINT32},
+			{Name: "Message", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, w := range warnings {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(w.Level)),
+			sqltypes.MakeTrusted(querypb.Type_UINT32, []byte(strconv.Itoa(int(w.Code)))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(w.Message)),
+		})
+	}
+	return qr, true
+}
+
+// Warnings fetches the warnings of the last statement by the SHOW WARNINGS.
+func (c *conn) Warnings() ([]Warning, error) {
+	qr, err := c.FetchAll("SHOW WARNINGS", -1)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Fields) < 3 {
+		return nil, fmt.Errorf("driver.warnings.unexpected.fields.count[%d]", len(qr.Fields))
+	}
+
+	warnings := make([]Warning, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		code, err := row[1].ToUint64()
+		if err != nil || code > math.MaxUint16 {
+			return nil, fmt.Errorf("driver.warnings.invalid.code[%s]", row[1].String())
+		}
+		warnings = append(warnings, Warning{Level: row[0].String(), Code: uint16(code), Message: row[2].String()})
+	}
+	return warnings, nil
+}
@@ -0,0 +1,97 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/x509/pkix"
+	"fmt"
+	"strings"
+)
+
+// X509Rule is the client certificate a user must present, like the REQUIRE X509, SUBJECT and ISSUER of MySQL.
+// The certificate must be verified by the ClientCAs of the listener TLS config.
+type X509Rule struct {
+	// Subject is the subject of the certificate like "/C=SE/O=MySQL/CN=client", empty matches any.
+	Subject string
+
+	// Issuer is the issuer of the certificate in the same format, empty matches any.
+	Issuer string
+
+	// SkipPassword accepts the session by the certificate only, the Handler.AuthCheck isn't called.
+	SkipPassword bool
+}
+
+var x509AttributeNames = map[string]string{
+	"2.5.4.3":                    "CN",
+	"2.5.4.5":                    "serialNumber",
+	"2.5.4.6":                    "C",
+	"2.5.4.7":                    "L",
+	"2.5.4.8":                    "ST",
+	"2.5.4.9":                    "street",
+	"2.5.4.10":                   "O",
+	"2.5.4.11":                   "OU",
+	"2.5.4.17":                   "postalCode",
+	"1.2.840.113549.1.9.1":       "emailAddress",
+	"0.9.2342.19200300.100.1.1":  "UID",
+	"0.9.2342.19200300.100.1.25": "DC",
+}
+
+// X509Name formats the name in the order of the certificate like "/C=SE/O=MySQL/CN=client",
+// it's the format of the X509Rule and the ssl_client_cert of MySQL.
+func X509Name(name pkix.Name) string {
+	var buf strings.Builder
+	for _, atv := range name.Names {
+		key, ok := x509AttributeNames[atv.Type.String()]
+		if !ok {
+			key = atv.Type.String()
+		}
+		fmt.Fprintf(&buf, "/%s=%v", key, atv.Value)
+	}
+	return buf.String()
+}
+
+// SetX509Rule sets the client certificate the user must present for the coming sessions, nil removes it.
+func (l *Listener) SetX509Rule(user string, rule *X509Rule) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if rule == nil {
+		delete(l.x509Rules, user)
+		return
+	}
+	if l.x509Rules == nil {
+		l.x509Rules = make(map[string]*X509Rule)
+	}
+	r := *rule
+	l.x509Rules[user] = &r
+}
+
+func (l *Listener) x509Rule(user string) *X509Rule {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.x509Rules[user]
+}
+
+// checkX509 checks the client certificate of the session by the rule of the user,
+// it returns true if the password isn't checked.
+func (l *Listener) checkX509(session *Session) (bool, error) {
+	user := session.User()
+	rule := l.x509Rule(user)
+	if rule == nil {
+		return false, nil
+	}
+	if state := session.TLSState(); state != nil && len(state.VerifiedChains) > 0 {
+		cert := state.VerifiedChains[0][0]
+		if (rule.Subject == "" || rule.Subject == X509Name(cert.Subject)) &&
+			(rule.Issuer == "" || rule.Issuer == X509Name(cert.Issuer)) {
+			return rule.SkipPassword, nil
+		}
+	}
+	return false, session.accessDenied()
+}
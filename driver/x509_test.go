@@ -0,0 +1,132 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestClientCert returns the client certificate of the subject signed by a new CA and the pool of the CA.
+func newTestClientCert(t *testing.T, subject pkix.Name) (tls.Certificate, *x509.CertPool) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Country: []string{"SE"}, Organization: []string{"MySQL"}, CommonName: "ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	assert.Nil(t, err)
+	ca, err = x509.ParseCertificate(caDER)
+	assert.Nil(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	leaf := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      subject,
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, leaf, ca, &key.PublicKey, caKey)
+	assert.Nil(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestX509Name(t *testing.T) {
+	name := pkix.Name{
+		Country:      []string{"SE"},
+		Province:     []string{"Stockholm"},
+		Organization: []string{"MySQL"},
+		CommonName:   "client",
+	}
+	for _, rdn := range name.ToRDNSequence() {
+		name.Names = append(name.Names, rdn...)
+	}
+	assert.Equal(t, "/C=SE/ST=Stockholm/O=MySQL/CN=client", X509Name(name))
+	assert.Equal(t, "", X509Name(pkix.Name{}))
+}
+
+func TestListenerX509(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := NewTestHandler(log)
+	serverTLS, clientTLS := newTestTLSConfig(t)
+	cert, pool := newTestClientCert(t, pkix.Name{Organization: []string{"MySQL"}, CommonName: "mock"})
+	serverTLS.ClientCAs = pool
+	serverTLS.ClientAuth = tls.VerifyClientCertIfGiven
+	svr, err := MockMysqlServerWithConfig(&ListenerConfig{
+		Log: log,
+		TLS: serverTLS,
+		X509Users: map[string]*X509Rule{
+			"mock": {Subject: "/O=MySQL/CN=mock", Issuer: "/C=SE/O=MySQL/CN=ca"},
+		},
+	}, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+	certTLS := clientTLS.Clone()
+	certTLS.Certificates = []tls.Certificate{cert}
+
+	// The certificate and the password.
+	client, err := NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: certTLS})
+	assert.Nil(t, err)
+	assert.Nil(t, client.Ping())
+	client.Close()
+
+	// Without the certificate.
+	_, err = NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: clientTLS})
+	num, _ := sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+	_, err = NewConn("mock", "mock", svr.Addr(), "", "")
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+
+	// The subject doesn't match.
+	svr.SetX509Rule("mock", &X509Rule{Subject: "/O=MySQL/CN=other"})
+	_, err = NewConnWithConfig(&ClientConfig{User: "mock", Passwd: "mock", Addrs: []string{svr.Addr()}, TLS: certTLS})
+	num, _ = sqldb.ErrorNum(err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), num)
+
+	// The certificate only, the handler rejects the user but it isn't asked.
+	svr.SetX509Rule("other", &X509Rule{Subject: "/O=MySQL/CN=mock", SkipPassword: true})
+	client, err = NewConnWithConfig(&ClientConfig{User: "other", Addrs: []string{svr.Addr()}, TLS: certTLS})
+	assert.Nil(t, err)
+	assert.Nil(t, client.Ping())
+	client.Close()
+
+	// The rule removed.
+	svr.SetX509Rule("mock", nil)
+	plain, err := NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	plain.Close()
+
+	// The client certificates must be verified.
+	err = (&ListenerConfig{TLS: &tls.Config{Certificates: serverTLS.Certificates}, X509Users: map[string]*X509Rule{"mock": {}}}).Validate()
+	assert.EqualError(t, err, "driver.listener.config.x509.users.without.client.certificate.verification")
+}
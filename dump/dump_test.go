@@ -0,0 +1,166 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package dump
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func stringResult(names []string, rows ...[]string) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	for _, name := range names {
+		qr.Fields = append(qr.Fields, &querypb.Field{Name: name, Type: querypb.Type_VARCHAR})
+	}
+	for _, row := range rows {
+		var values []sqltypes.Value
+		for _, v := range row {
+			values = append(values, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v)))
+		}
+		qr.Rows = append(qr.Rows, values)
+	}
+	return qr
+}
+
+func TestDumpAndLoad(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.AddQuery("SHOW DATABASES", stringResult([]string{"Database"}, []string{"mysql"}, []string{"db1"}))
+	th.AddQuery("SHOW FULL TABLES FROM `db1`", stringResult([]string{"Tables_in_db1", "Table_type"},
+		[]string{"v1", "VIEW"},
+		[]string{"t1", "BASE TABLE"}))
+	th.AddQuery("SHOW CREATE TABLE `db1`.`t1`", stringResult([]string{"Table", "Create Table"},
+		[]string{"t1", "CREATE TABLE `t1` (\n  `id` int(11) NOT NULL,\n  `name` varchar(20) DEFAULT NULL COMMENT 'a;b',\n  `data` blob\n)"}))
+	th.AddQuery("SHOW CREATE TABLE `db1`.`v1`", stringResult([]string{"View", "Create View"},
+		[]string{"v1", "CREATE VIEW `v1` AS select `t1`.`id` AS `id` from `t1`"}))
+	th.AddQuery("SELECT * FROM `db1`.`t1`", &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+			{Name: "name", Type: querypb.Type_VARCHAR},
+			{Name: "data", Type: querypb.Type_BLOB},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("it's\n")), sqltypes.MakeTrusted(querypb.Type_BLOB, []byte{0x00, 0xff})},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")), sqltypes.NULL, sqltypes.NULL},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("c")), sqltypes.NULL},
+		},
+	})
+
+	conn, err := driver.NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// Dump all the non-system schemas with 2 rows per INSERT.
+	var buf bytes.Buffer
+	dumper := NewDumper(log, conn, &Config{ChunkRows: 2})
+	assert.Nil(t, dumper.Dump(&buf))
+	assert.Equal(t, 2, dumper.Tables())
+	assert.Equal(t, 3, dumper.Rows())
+
+	script := buf.String()
+	lines := strings.SplitN(script, "\n", 2)
+	assert.True(t, strings.HasPrefix(lines[0], "-- go-mysqlstack dump of the server"))
+	want := "SET NAMES utf8mb4;\n" +
+		"SET FOREIGN_KEY_CHECKS=0;\n" +
+		"SET UNIQUE_CHECKS=0;\n" +
+		"\n" +
+		"CREATE DATABASE IF NOT EXISTS `db1`;\n" +
+		"USE `db1`;\n" +
+		"\n" +
+		"DROP TABLE IF EXISTS `t1`;\n" +
+		"CREATE TABLE `t1` (\n  `id` int(11) NOT NULL,\n  `name` varchar(20) DEFAULT NULL COMMENT 'a;b',\n  `data` blob\n);\n" +
+		"INSERT INTO `t1` VALUES (1,'it\\'s\\n',X'00ff'),(2,NULL,NULL);\n" +
+		"INSERT INTO `t1` VALUES (3,'c',NULL);\n" +
+		"\n" +
+		"DROP VIEW IF EXISTS `v1`;\n" +
+		"CREATE VIEW `v1` AS select `t1`.`id` AS `id` from `t1`;\n"
+	assert.Equal(t, want, lines[1])
+
+	// Load the script by 2 workers, the unknown statements fail the mock.
+	statements := []string{
+		"SET NAMES utf8mb4",
+		"SET FOREIGN_KEY_CHECKS=0",
+		"SET UNIQUE_CHECKS=0",
+		"CREATE DATABASE IF NOT EXISTS `db1`",
+		"USE `db1`",
+		"DROP TABLE IF EXISTS `t1`",
+		"CREATE TABLE `t1` (\n  `id` int(11) NOT NULL,\n  `name` varchar(20) DEFAULT NULL COMMENT 'a;b',\n  `data` blob\n)",
+		"INSERT INTO `t1` VALUES (1,'it\\'s\\n',X'00ff'),(2,NULL,NULL)",
+		"INSERT INTO `t1` VALUES (3,'c',NULL)",
+		"DROP VIEW IF EXISTS `v1`",
+		"CREATE VIEW `v1` AS select `t1`.`id` AS `id` from `t1`",
+	}
+	for _, stmt := range statements {
+		th.AddQuery(stmt, &sqltypes.Result{})
+	}
+	pool, err := driver.NewPool("mock:mock@tcp("+svr.Addr()+")/", 4)
+	assert.Nil(t, err)
+	defer pool.Close()
+
+	loader := NewLoader(log, pool, 2)
+	assert.Nil(t, loader.Load(strings.NewReader(script)))
+	assert.Equal(t, len(statements), loader.Statements())
+	// The SET and USE are applied to the workers too.
+	for _, stmt := range statements {
+		assert.True(t, th.GetQueryCalledNum(stmt) >= 1, stmt)
+	}
+	assert.True(t, th.GetQueryCalledNum("USE `db1`") >= 2)
+
+	// The failed INSERT stops the loading.
+	loader = NewLoader(log, pool, 2)
+	err = loader.Load(strings.NewReader(script + "INSERT INTO `t1` VALUES (4,'d',NULL);\nDROP TABLE `t1`;\n"))
+	assert.NotNil(t, err)
+}
+
+func TestDumpTables(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.AddQuery("SHOW FULL TABLES FROM `db1`", stringResult([]string{"Tables_in_db1", "Table_type"},
+		[]string{"t1", "BASE TABLE"},
+		[]string{"t2", "BASE TABLE"}))
+	th.AddQuery("SHOW CREATE TABLE `db1`.`t2`", stringResult([]string{"Table", "Create Table"},
+		[]string{"t2", "CREATE TABLE `t2` (`a` int)"}))
+	th.AddQuery("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ", &sqltypes.Result{})
+	th.AddQuery("START TRANSACTION WITH CONSISTENT SNAPSHOT", &sqltypes.Result{})
+	th.AddQuery("ROLLBACK", &sqltypes.Result{})
+
+	conn, err := driver.NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	// The schema definitions only of the selected table in a snapshot.
+	var buf bytes.Buffer
+	dumper := NewDumper(log, conn, &Config{Tables: []string{"db1.t2"}, NoData: true, Consistent: true})
+	assert.Nil(t, dumper.Dump(&buf))
+	assert.True(t, strings.HasSuffix(buf.String(), "USE `db1`;\n\nDROP TABLE IF EXISTS `t2`;\nCREATE TABLE `t2` (`a` int);\n"))
+	assert.Equal(t, 1, th.GetQueryCalledNum("START TRANSACTION WITH CONSISTENT SNAPSHOT"))
+	assert.Equal(t, 1, th.GetQueryCalledNum("ROLLBACK"))
+
+	// Invalid tables.
+	for _, cfg := range []*Config{{Tables: []string{"t2"}}, {Schemas: []string{"db2"}, Tables: []string{"db1.t2"}}} {
+		assert.NotNil(t, NewDumper(log, conn, cfg).Dump(&buf))
+	}
+}
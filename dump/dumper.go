@@ -0,0 +1,299 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package dump is a logical dump and restore tool built on the driver,
+// the dump is a SQL script of the CREATE statements and the batched INSERTs.
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+const (
+	// DefaultChunkRows is the max rows of one INSERT.
+	DefaultChunkRows = 1000
+
+	// DefaultMaxStatementSize is the max size of one INSERT, under the max_allowed_packet.
+	DefaultMaxStatementSize = 1 << 20
+)
+
+// systemSchemas are not dumped unless named in the config.
+var systemSchemas = map[string]bool{
+	"information_schema": true,
+	"performance_schema": true,
+	"mysql":              true,
+	"sys":                true,
+}
+
+// Config is the dump config.
+type Config struct {
+	// Schemas are the databases to dump, all the non-system ones if both Schemas and Tables are empty.
+	Schemas []string
+
+	// Tables are the tables to dump like 'db1.t1', all the tables of the Schemas if empty.
+	Tables []string
+
+	// NoData dumps the CREATE statements only.
+	NoData bool
+
+	// ChunkRows is the max rows of one INSERT, 0 means DefaultChunkRows.
+	ChunkRows int
+
+	// MaxStatementSize is the max size of one INSERT, 0 means DefaultMaxStatementSize.
+	// The row larger than it is written in its own INSERT.
+	MaxStatementSize int
+
+	// Consistent dumps all the tables in one transaction WITH CONSISTENT SNAPSHOT.
+	Consistent bool
+}
+
+// Dumper dumps the schemas and the rows as the SQL script, the rows are streamed by the row cursor.
+type Dumper struct {
+	log  *xlog.Log
+	conn driver.Conn
+	cfg  *Config
+
+	tables int
+	rows   int
+}
+
+// NewDumper creates the dumper on the conn, the conn must not be used by others during the dump.
+func NewDumper(log *xlog.Log, conn driver.Conn, cfg *Config) *Dumper {
+	return &Dumper{
+		log:  log,
+		conn: conn,
+		cfg:  cfg,
+	}
+}
+
+// Tables returns the number of the tables and views dumped.
+func (d *Dumper) Tables() int {
+	return d.tables
+}
+
+// Rows returns the number of the rows dumped.
+func (d *Dumper) Rows() int {
+	return d.rows
+}
+
+// Dump writes the script to the w.
+func (d *Dumper) Dump(w io.Writer) error {
+	bw := bufio.NewWriterSize(w, 64*1024)
+	if err := d.dump(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+func (d *Dumper) dump(w *bufio.Writer) error {
+	schemas, filter, err := d.schemas()
+	if err != nil {
+		return err
+	}
+
+	if d.cfg.Consistent {
+		if err := d.conn.Exec("SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+			return err
+		}
+		if err := d.conn.Exec("START TRANSACTION WITH CONSISTENT SNAPSHOT"); err != nil {
+			return err
+		}
+		defer d.conn.Rollback()
+	}
+
+	fmt.Fprintf(w, "-- go-mysqlstack dump of the server %s\n", d.conn.ServerVersion().Raw)
+	w.WriteString("SET NAMES utf8mb4;\n")
+	w.WriteString("SET FOREIGN_KEY_CHECKS=0;\n")
+	w.WriteString("SET UNIQUE_CHECKS=0;\n")
+	for _, schema := range schemas {
+		if err := d.dumpSchema(w, schema, filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// schemas returns the schemas to dump, and the table filter if the tables are set.
+func (d *Dumper) schemas() ([]string, map[string]bool, error) {
+	if len(d.cfg.Tables) > 0 {
+		var schemas []string
+		filter := make(map[string]bool)
+		for _, name := range d.cfg.Tables {
+			parts := strings.SplitN(name, ".", 2)
+			if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+				return nil, nil, fmt.Errorf("dump.invalid.table[%s].must.be.schema.table", name)
+			}
+			if !contains(schemas, parts[0]) {
+				schemas = append(schemas, parts[0])
+			}
+			filter[name] = true
+		}
+		for _, schema := range d.cfg.Schemas {
+			if !contains(schemas, schema) {
+				return nil, nil, fmt.Errorf("dump.schema[%s].has.no.table.in.the.tables", schema)
+			}
+		}
+		return schemas, filter, nil
+	}
+
+	if len(d.cfg.Schemas) > 0 {
+		return d.cfg.Schemas, nil, nil
+	}
+	dbs, err := d.conn.Databases()
+	if err != nil {
+		return nil, nil, err
+	}
+	var schemas []string
+	for _, db := range dbs {
+		if !systemSchemas[strings.ToLower(db)] {
+			schemas = append(schemas, db)
+		}
+	}
+	return schemas, nil, nil
+}
+
+func (d *Dumper) dumpSchema(w *bufio.Writer, schema string, filter map[string]bool) error {
+	tables, err := d.conn.Tables(schema)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nCREATE DATABASE IF NOT EXISTS %s;\n", escape.QuoteIdentifier(schema))
+	fmt.Fprintf(w, "USE %s;\n", escape.QuoteIdentifier(schema))
+
+	// The views are created after the tables they select from.
+	var views []string
+	for _, table := range tables {
+		if filter != nil && !filter[schema+"."+table.Name] {
+			continue
+		}
+		switch table.Type {
+		case "BASE TABLE":
+			if err := d.dumpTable(w, schema, table.Name); err != nil {
+				return err
+			}
+		case "VIEW":
+			views = append(views, table.Name)
+		}
+	}
+	for _, view := range views {
+		create, err := d.showCreate(schema, view)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "\nDROP VIEW IF EXISTS %s;\n%s;\n", escape.QuoteIdentifier(view), create)
+		d.tables++
+	}
+	return nil
+}
+
+func (d *Dumper) showCreate(schema string, table string) (string, error) {
+	qr, err := d.conn.FetchAll(fmt.Sprintf("SHOW CREATE TABLE %s.%s", escape.QuoteIdentifier(schema), escape.QuoteIdentifier(table)), -1)
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) < 2 {
+		return "", fmt.Errorf("dump.show.create.table[%s.%s].unexpected.result", schema, table)
+	}
+	return qr.Rows[0][1].String(), nil
+}
+
+func (d *Dumper) dumpTable(w *bufio.Writer, schema string, table string) error {
+	create, err := d.showCreate(schema, table)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\nDROP TABLE IF EXISTS %s;\n%s;\n", escape.QuoteIdentifier(table), create)
+	d.tables++
+	if d.cfg.NoData {
+		return nil
+	}
+
+	rows, err := d.conn.Query(fmt.Sprintf("SELECT * FROM %s.%s", escape.QuoteIdentifier(schema), escape.QuoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	chunkRows := d.cfg.ChunkRows
+	if chunkRows <= 0 {
+		chunkRows = DefaultChunkRows
+	}
+	maxSize := d.cfg.MaxStatementSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxStatementSize
+	}
+
+	prefix := fmt.Sprintf("INSERT INTO %s VALUES ", escape.QuoteIdentifier(table))
+	var values bytes.Buffer
+	pending := 0
+	flush := func() {
+		if pending > 0 {
+			w.WriteString(prefix)
+			w.Write(values.Bytes())
+			w.WriteString(";\n")
+		}
+		values.Reset()
+		pending = 0
+	}
+
+	var row bytes.Buffer
+	for rows.Next() {
+		vals, err := rows.RowValues()
+		if err != nil {
+			return err
+		}
+		row.Reset()
+		row.WriteByte('(')
+		for i, v := range vals {
+			if i > 0 {
+				row.WriteByte(',')
+			}
+			lit, err := escape.Literal(v)
+			if err != nil {
+				return err
+			}
+			row.WriteString(lit)
+		}
+		row.WriteByte(')')
+
+		if pending > 0 && (pending >= chunkRows || len(prefix)+values.Len()+1+row.Len() > maxSize) {
+			flush()
+		}
+		if pending > 0 {
+			values.WriteByte(',')
+		}
+		values.Write(row.Bytes())
+		pending++
+		d.rows++
+	}
+	if err := rows.LastError(); err != nil {
+		return err
+	}
+	flush()
+	d.log.Info("dump.table[%s.%s].done", schema, table)
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
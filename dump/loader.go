@@ -0,0 +1,215 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package dump
+
+import (
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+// job is an INSERT to run by the workers.
+type job struct {
+	schema string
+	// settings are the SET statements before the INSERT.
+	settings []string
+	query    string
+}
+
+// Loader restores the dump script, the INSERTs are run in parallel by the workers,
+// the other statements are run in order by the loader conn.
+// The SET statements are applied to all the conns, and the USE is followed by the workers.
+type Loader struct {
+	log     *xlog.Log
+	pool    *driver.Pool
+	threads int
+
+	mu         sync.Mutex
+	err        error
+	statements int
+}
+
+// NewLoader creates the loader with the threads workers, the conns are from the pool.
+func NewLoader(log *xlog.Log, pool *driver.Pool, threads int) *Loader {
+	if threads < 1 {
+		threads = 1
+	}
+	return &Loader{
+		log:     log,
+		pool:    pool,
+		threads: threads,
+	}
+}
+
+// Statements returns the number of the statements executed.
+func (l *Loader) Statements() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.statements
+}
+
+// Load reads the script from the r and executes it, it stops at the first error.
+func (l *Loader) Load(r io.Reader) error {
+	conn, err := l.pool.Get()
+	if err != nil {
+		return err
+	}
+	defer l.put(conn)
+
+	jobs := make(chan *job, l.threads)
+	quit := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < l.threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.work(jobs, quit)
+		}()
+	}
+
+	var schema string
+	var settings []string
+	reader := NewReader(r)
+	err = func() error {
+		defer close(jobs)
+		for {
+			stmt, err := reader.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			switch keyword(stmt) {
+			case "INSERT", "REPLACE":
+				select {
+				case jobs <- &job{schema: schema, settings: settings, query: stmt}:
+				case <-quit:
+					return nil
+				}
+				continue
+			case "USE":
+				schema = unquote(strings.TrimSpace(stmt[3:]))
+			case "SET":
+				settings = append(settings[:len(settings):len(settings)], stmt)
+			}
+			if err := conn.Exec(stmt); err != nil {
+				return err
+			}
+			l.done()
+		}
+	}()
+	if err != nil {
+		l.fail(err, quit)
+	}
+	wg.Wait()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err
+}
+
+func (l *Loader) work(jobs <-chan *job, quit chan struct{}) {
+	conn, err := l.pool.Get()
+	if err != nil {
+		l.fail(err, quit)
+		for range jobs {
+		}
+		return
+	}
+	defer l.put(conn)
+
+	var schema string
+	applied := 0
+	for j := range jobs {
+		if l.failed() {
+			continue
+		}
+		if err := func() error {
+			for ; applied < len(j.settings); applied++ {
+				if err := conn.Exec(j.settings[applied]); err != nil {
+					return err
+				}
+			}
+			if j.schema != schema {
+				if err := conn.Exec("USE " + escape.QuoteIdentifier(j.schema)); err != nil {
+					return err
+				}
+				schema = j.schema
+			}
+			return conn.Exec(j.query)
+		}(); err != nil {
+			l.fail(err, quit)
+			continue
+		}
+		l.done()
+	}
+}
+
+func (l *Loader) put(conn driver.Conn) {
+	if err := l.pool.Put(conn); err != nil {
+		conn.Close()
+	}
+}
+
+func (l *Loader) done() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.statements++
+}
+
+func (l *Loader) failed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err != nil
+}
+
+// setError keeps the first error.
+func (l *Loader) setError(err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return false
+	}
+	l.err = err
+	return true
+}
+
+// fail sets the error of the workers and stops the reading.
+func (l *Loader) fail(err error, quit chan struct{}) {
+	if l.setError(err) {
+		l.log.Error("dump.loader.error:%+v", err)
+		close(quit)
+	}
+}
+
+// keyword returns the first word of the statement in upper case.
+func keyword(stmt string) string {
+	end := strings.IndexFunc(stmt, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == '\n' || r == '\r' || r == '`'
+	})
+	if end < 0 {
+		end = len(stmt)
+	}
+	return strings.ToUpper(stmt[:end])
+}
+
+// unquote unquotes the backtick quoted identifier.
+func unquote(id string) string {
+	if len(id) >= 2 && id[0] == '`' && id[len(id)-1] == '`' {
+		return strings.Replace(id[1:len(id)-1], "``", "`", -1)
+	}
+	return id
+}
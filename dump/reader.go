@@ -0,0 +1,148 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrUnterminated returned by the Reader if the script ends in a quote or a comment.
+	ErrUnterminated = errors.New("dump.reader.unterminated.quote.or.comment")
+)
+
+// Reader splits the SQL script into the statements by the ';' outside the quotes and the comments.
+// The '-- ' and '#' line comments are dropped, the '/* */' comments are kept for the '/*!' ones.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates the reader of the script.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Next returns the next statement without the ';', io.EOF at the end of the script.
+func (r *Reader) Next() (string, error) {
+	var stmt bytes.Buffer
+	for {
+		ch, err := r.r.ReadByte()
+		if err == io.EOF {
+			if s := bytes.TrimSpace(stmt.Bytes()); len(s) > 0 {
+				return string(s), nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch ch {
+		case ';':
+			if s := bytes.TrimSpace(stmt.Bytes()); len(s) > 0 {
+				return string(s), nil
+			}
+			stmt.Reset()
+			continue
+		case '\'', '"', '`':
+			stmt.WriteByte(ch)
+			if err := r.readQuoted(&stmt, ch); err != nil {
+				return "", err
+			}
+			continue
+		case '#':
+			if err := r.skipLine(); err != nil {
+				return "", err
+			}
+			continue
+		case '-':
+			if next, err := r.r.Peek(2); err == nil && next[0] == '-' && isSpace(next[1]) {
+				if err := r.skipLine(); err != nil {
+					return "", err
+				}
+				continue
+			}
+		case '/':
+			if next, err := r.r.Peek(1); err == nil && next[0] == '*' {
+				stmt.WriteByte(ch)
+				if err := r.readComment(&stmt); err != nil {
+					return "", err
+				}
+				continue
+			}
+		}
+		stmt.WriteByte(ch)
+	}
+}
+
+// readQuoted reads until the closing quote, the backslash escapes in the strings.
+func (r *Reader) readQuoted(stmt *bytes.Buffer, quote byte) error {
+	for {
+		ch, err := r.r.ReadByte()
+		if err == io.EOF {
+			return ErrUnterminated
+		}
+		if err != nil {
+			return err
+		}
+		stmt.WriteByte(ch)
+		switch {
+		case ch == '\\' && quote != '`':
+			next, err := r.r.ReadByte()
+			if err == io.EOF {
+				return ErrUnterminated
+			}
+			if err != nil {
+				return err
+			}
+			stmt.WriteByte(next)
+		case ch == quote:
+			return nil
+		}
+	}
+}
+
+// readComment reads the comment after the '/' until the '*/'.
+func (r *Reader) readComment(stmt *bytes.Buffer) error {
+	var last byte
+	// The '*' opening the comment.
+	ch, _ := r.r.ReadByte()
+	stmt.WriteByte(ch)
+	for {
+		ch, err := r.r.ReadByte()
+		if err == io.EOF {
+			return ErrUnterminated
+		}
+		if err != nil {
+			return err
+		}
+		stmt.WriteByte(ch)
+		if last == '*' && ch == '/' {
+			return nil
+		}
+		last = ch
+	}
+}
+
+// skipLine skips the line comment includes the newline.
+func (r *Reader) skipLine() error {
+	_, err := r.r.ReadString('\n')
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
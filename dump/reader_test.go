@@ -0,0 +1,52 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package dump
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReader(t *testing.T) {
+	script := "-- comment; not a statement\n" +
+		"SET NAMES utf8mb4;\n" +
+		"# another comment\n" +
+		"/*!40101 SET @a=1 */;\n" +
+		"INSERT INTO `t;1` VALUES ('a;b\\';c',\"x;\"),(1);;\n" +
+		"SELECT 1--1;\n" +
+		"SELECT 2"
+
+	r := NewReader(strings.NewReader(script))
+	var got []string
+	for {
+		stmt, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.Nil(t, err)
+		got = append(got, stmt)
+	}
+	want := []string{
+		"SET NAMES utf8mb4",
+		"/*!40101 SET @a=1 */",
+		"INSERT INTO `t;1` VALUES ('a;b\\';c',\"x;\"),(1)",
+		"SELECT 1--1",
+		"SELECT 2",
+	}
+	assert.Equal(t, want, got)
+
+	for _, bad := range []string{"SELECT 'a", "SELECT /* a", "SELECT `a"} {
+		_, err := NewReader(strings.NewReader(bad)).Next()
+		assert.Equal(t, ErrUnterminated, err, bad)
+	}
+}
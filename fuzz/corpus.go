@@ -0,0 +1,150 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package fuzz
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/replication"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// Targets are the fuzz entry points by the names.
+var Targets = map[string]func([]byte) int{
+	"FuzzHandshake":    FuzzHandshake,
+	"FuzzComQuery":     FuzzComQuery,
+	"FuzzResultDecode": FuzzResultDecode,
+	"FuzzBinlogEvent":  FuzzBinlogEvent,
+}
+
+// Seeds returns the corpus seeds of the target, they're the valid inputs packed by the encoders.
+func Seeds(target string) [][]byte {
+	switch target {
+	case "FuzzHandshake":
+		return handshakeSeeds()
+	case "FuzzComQuery":
+		return comQuerySeeds()
+	case "FuzzResultDecode":
+		return resultSeeds()
+	case "FuzzBinlogEvent":
+		return binlogSeeds()
+	}
+	return nil
+}
+
+// WriteCorpus writes the seeds of the targets to the dir/<target>/corpus, it's the go-fuzz workdir layout.
+func WriteCorpus(dir string) error {
+	for target := range Targets {
+		corpus := filepath.Join(dir, target, "corpus")
+		if err := os.MkdirAll(corpus, 0755); err != nil {
+			return err
+		}
+		for i, seed := range Seeds(target) {
+			if err := ioutil.WriteFile(filepath.Join(corpus, fmt.Sprintf("seed-%d", i)), seed, 0644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func handshakeSeeds() [][]byte {
+	greeting := proto.NewGreeting(7)
+	auth := proto.NewAuth()
+	switchReq := &proto.AuthSwitchRequest{PluginName: proto.DefaultAuthPluginName, AuthData: greeting.Salt}
+	return [][]byte{
+		greeting.Pack(),
+		auth.Pack(sqldb.CLIENT_PROTOCOL_41|sqldb.CLIENT_SECURE_CONNECTION|sqldb.CLIENT_PLUGIN_AUTH, sqldb.DefaultCollation, "root", "secret", greeting.Salt, ""),
+		auth.Pack(sqldb.CLIENT_PROTOCOL_41|sqldb.CLIENT_SECURE_CONNECTION|sqldb.CLIENT_PLUGIN_AUTH, sqldb.DefaultCollation, "mock", "", greeting.Salt, "db1"),
+		switchReq.Pack(),
+	}
+}
+
+func comQuerySeeds() [][]byte {
+	queries := []string{
+		"SELECT 1",
+		"select /*+ MAX_EXECUTION_TIME(100) */ a, b from db1.t1 where id in (1, 2) and name = 'x' order by a limit 10",
+		"INSERT INTO t1(a, b) VALUES (1, 'a'), (2, NULL) ON DUPLICATE KEY UPDATE b = VALUES(b)",
+		"UPDATE t1 SET a = a + 1 WHERE b LIKE 'x%'",
+		"DELETE FROM t1 WHERE id = 1",
+		"SET NAMES utf8mb4, @a = 1, SESSION sql_mode = 'ANSI_QUOTES', autocommit = 0",
+		"SHOW VARIABLES LIKE 'version%'",
+		"CREATE TABLE t1 (a INT PRIMARY KEY, b VARCHAR(10)) ENGINE=InnoDB",
+		"BEGIN",
+		"KILL 1",
+		"SELECT @a, @@session.time_zone, LAST_INSERT_ID() /* trailing */",
+	}
+	seeds := make([][]byte, 0, len(queries))
+	for _, query := range queries {
+		seeds = append(seeds, append([]byte{sqldb.COM_QUERY}, query...))
+	}
+	return seeds
+}
+
+// packets frames the payloads with the packet headers.
+func packets(payloads ...[]byte) []byte {
+	buf := common.NewBuffer(256)
+	for i, payload := range payloads {
+		buf.WriteU24(uint32(len(payload)))
+		buf.WriteU8(uint8(i + 1))
+		buf.WriteBytes(payload)
+	}
+	return buf.Datas()
+}
+
+func resultSeeds() [][]byte {
+	count := common.NewBuffer(8)
+	count.WriteLenEncode(2)
+	row := common.NewBuffer(16)
+	row.WriteLenEncodeBytes([]byte("1"))
+	row.WriteLenEncodeNUL()
+	eof := proto.PackEOF(&proto.EOF{StatusFlags: sqldb.SERVER_STATUS_AUTOCOMMIT})
+
+	fields := []*querypb.Field{
+		{Name: "a", OrgName: "a", Table: "t1", OrgTable: "t1", Database: "db1", Type: querypb.Type_INT32, ColumnLength: 11, Charset: sqldb.CharacterSetBinary},
+		{Name: "b", OrgName: "b", Table: "t1", OrgTable: "t1", Database: "db1", Type: querypb.Type_VARCHAR, ColumnLength: 40, Charset: sqldb.DefaultCollation},
+	}
+	return [][]byte{
+		packets(proto.PackOK(&proto.OK{AffectedRows: 3, LastInsertID: 7, StatusFlags: sqldb.SERVER_STATUS_AUTOCOMMIT})),
+		packets(proto.PackERR(&proto.ERR{ErrorCode: sqldb.ER_NO_SUCH_TABLE, SQLState: "42S02", ErrorMessage: "Table 'db1.t1' doesn't exist"})),
+		packets(count.Datas(), proto.PackColumn(fields[0]), proto.PackColumn(fields[1]), eof, row.Datas(), eof),
+	}
+}
+
+func binlogSeeds() [][]byte {
+	var seeds [][]byte
+	for _, alg := range []uint8{replication.BINLOG_CHECKSUM_ALG_OFF, replication.BINLOG_CHECKSUM_ALG_CRC32} {
+		var stream []byte
+		w := replication.NewBinlogWriter(1)
+		events := []replication.Event{
+			replication.NewFormatDescriptionEvent("5.7.20-log", alg),
+			&replication.QueryEvent{SlaveProxyID: 1, Schema: "db1", Query: "BEGIN"},
+			&replication.XIDEvent{XID: 9},
+			&replication.RotateEvent{Position: 4, NextName: "mysql-bin.000002"},
+		}
+		for _, ev := range events {
+			encoded, err := w.Encode(1500000000, ev)
+			if err != nil {
+				panic(err)
+			}
+			seeds = append(seeds, encoded.RawData)
+			stream = append(stream, encoded.RawData...)
+		}
+		seeds = append(seeds, stream)
+	}
+	return seeds
+}
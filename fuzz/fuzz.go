@@ -0,0 +1,161 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package fuzz is the fuzz entry points of the packet and proto decoders, for the go-fuzz and the libFuzzer.
+// The entry points return 1 if the input is decoded, 0 if it's rejected, the decoders shouldn't panic on any input.
+//
+//	go-fuzz-build -func FuzzHandshake github.com/XeLabs/go-mysqlstack/fuzz
+//	go-fuzz -bin fuzz-fuzz.zip -workdir corpus/handshake
+//
+// The go test runs the seeds by the native fuzz tests, 'go test -fuzz FuzzHandshake' fuzzes.
+package fuzz
+
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/replication"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// maxColumns bounds the columns of a fuzzed resultset, the count is from the input.
+const maxColumns = 4096
+
+// FuzzHandshake decodes the data as the handshake packets: the greeting the client reads,
+// the handshake response and the auth switch request.
+func FuzzHandshake(data []byte) int {
+	ok := 0
+	if err := proto.NewGreeting(0).UnPack(data); err == nil {
+		ok = 1
+	}
+	auth := proto.NewAuth()
+	if err := auth.UnPack(data); err == nil {
+		auth.ConnectAttrs()
+		ok = 1
+	}
+	if _, err := proto.UnPackAuthSwitchRequest(data); err == nil {
+		ok = 1
+	}
+	return ok
+}
+
+// FuzzComQuery decodes the data as the COM_QUERY payload, the query is analyzed as the server does.
+func FuzzComQuery(data []byte) int {
+	if len(data) == 0 || data[0] != sqldb.COM_QUERY {
+		return -1
+	}
+	query := string(data[1:])
+
+	ok := 0
+	if typ := sqlparser.Preview(query); typ != sqlparser.StmtOther && typ != sqlparser.StmtUnknown {
+		ok = 1
+	}
+	sqlparser.SplitTrailingComments(query)
+	sqlparser.Fingerprint(query)
+	sqlparser.MaxExecutionTime(query)
+	sqlparser.ParseSetVars(query)
+	sqlparser.SubstituteUserVars(query, sqlparser.DefaultSQLMode, func(name string) (sqltypes.Value, bool) {
+		return sqltypes.NewVarChar(name), true
+	})
+	for _, mode := range []sqlparser.SQLMode{sqlparser.DefaultSQLMode, sqlparser.ModeANSIQuotes | sqlparser.ModeNoBackslashEscapes} {
+		if stmt, err := sqlparser.ParseWithSQLMode(query, mode); err == nil {
+			sqlparser.String(stmt)
+			return 1
+		}
+	}
+	return ok
+}
+
+// FuzzResultDecode decodes the data as the packets of a resultset the client reads: the column count,
+// the column definitions, the EOF and the text rows until the EOF, OK or ERR.
+func FuzzResultDecode(data []byte) int {
+	packets := splitPackets(data)
+	if len(packets) == 0 {
+		return 0
+	}
+
+	first := packets[0]
+	switch {
+	case len(first) > 0 && first[0] == proto.OK_PACKET:
+		if _, err := proto.UnPackOK(first); err != nil {
+			return 0
+		}
+		return 1
+	case len(first) > 0 && first[0] == proto.ERR_PACKET:
+		proto.UnPackERR(first)
+		return 1
+	}
+	count, err := proto.ColumnCount(first)
+	if err != nil || count == 0 || count > maxColumns || uint64(len(packets)) < count+1 {
+		return 0
+	}
+	fields := packets[1 : 1+count]
+	for _, payload := range fields {
+		if _, err := proto.UnpackColumn(payload); err != nil {
+			return 0
+		}
+	}
+
+	for _, payload := range packets[1+count:] {
+		switch {
+		case len(payload) > 0 && payload[0] == proto.EOF_PACKET && len(payload) < 9:
+			if _, err := proto.UnPackEOF(payload); err != nil {
+				return 0
+			}
+		case len(payload) > 0 && payload[0] == proto.ERR_PACKET:
+			proto.UnPackERR(payload)
+			return 1
+		default:
+			buf := common.ReadBuffer(payload)
+			for i := uint64(0); i < count; i++ {
+				if _, err := buf.ReadLenEncodeBytes(); err != nil {
+					return 0
+				}
+			}
+		}
+	}
+	return 1
+}
+
+// FuzzBinlogEvent decodes the data as the binlog events of a stream, the events are split by the event size
+// of the headers so the FORMAT_DESCRIPTION and the TABLE_MAP affect the events after them.
+func FuzzBinlogEvent(data []byte) int {
+	parser := replication.NewBinlogParser()
+	ok := 0
+	for len(data) >= replication.EventHeaderSize {
+		// The event size is at the offset 9 of the header.
+		size := int(uint32(data[9]) | uint32(data[10])<<8 | uint32(data[11])<<16 | uint32(data[12])<<24)
+		if size < replication.EventHeaderSize || size > len(data) {
+			size = len(data)
+		}
+		if _, err := parser.Parse(data[:size]); err != nil {
+			return ok
+		}
+		ok = 1
+		data = data[size:]
+	}
+	return ok
+}
+
+// splitPackets splits the data by the packet headers, the length of the last packet is cut to the data.
+func splitPackets(data []byte) [][]byte {
+	var packets [][]byte
+	for len(data) >= 4 {
+		length := int(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
+		data = data[4:]
+		if length > len(data) {
+			length = len(data)
+		}
+		packets = append(packets, data[:length])
+		data = data[length:]
+	}
+	return packets
+}
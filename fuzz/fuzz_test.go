@@ -0,0 +1,62 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package fuzz_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/fuzz"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeeds(t *testing.T) {
+	for name, target := range fuzz.Targets {
+		seeds := fuzz.Seeds(name)
+		assert.NotEmpty(t, seeds, name)
+		for i, seed := range seeds {
+			assert.Equalf(t, 1, target(seed), "%s.seed[%d]", name, i)
+			// The truncated inputs are rejected without panic.
+			for n := 0; n < len(seed); n++ {
+				target(seed[:n])
+			}
+		}
+	}
+}
+
+func TestWriteCorpus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fuzz")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	assert.Nil(t, fuzz.WriteCorpus(dir))
+	for name := range fuzz.Targets {
+		files, err := ioutil.ReadDir(filepath.Join(dir, name, "corpus"))
+		assert.Nil(t, err)
+		assert.Equal(t, len(fuzz.Seeds(name)), len(files))
+	}
+}
+
+func fuzzTarget(f *testing.F, name string) {
+	for _, seed := range fuzz.Seeds(name) {
+		f.Add(seed)
+	}
+	target := fuzz.Targets[name]
+	f.Fuzz(func(t *testing.T, data []byte) {
+		target(data)
+	})
+}
+
+func FuzzHandshake(f *testing.F)    { fuzzTarget(f, "FuzzHandshake") }
+func FuzzComQuery(f *testing.F)     { fuzzTarget(f, "FuzzComQuery") }
+func FuzzResultDecode(f *testing.F) { fuzzTarget(f, "FuzzResultDecode") }
+func FuzzBinlogEvent(f *testing.F)  { fuzzTarget(f, "FuzzBinlogEvent") }
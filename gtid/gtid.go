@@ -0,0 +1,358 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package gtid
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+)
+
+// SID is the server uuid of the GTID.
+type SID [16]byte
+
+// ParseSID parses the uuid string like '3e11fa47-71ca-11e1-9e33-c80aa9429562'.
+func ParseSID(s string) (SID, error) {
+	var sid SID
+	raw := strings.Replace(strings.TrimSpace(s), "-", "", -1)
+	if len(raw) != 32 {
+		return sid, fmt.Errorf("gtid.invalid.sid[%s]", s)
+	}
+	if _, err := hex.Decode(sid[:], []byte(raw)); err != nil {
+		return sid, fmt.Errorf("gtid.invalid.sid[%s]", s)
+	}
+	return sid, nil
+}
+
+func (sid SID) String() string {
+	h := hex.EncodeToString(sid[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32]
+}
+
+// Interval is the GNO range [Start, Stop).
+type Interval struct {
+	Start int64
+	Stop  int64
+}
+
+func (i Interval) String() string {
+	if i.Stop == i.Start+1 {
+		return strconv.FormatInt(i.Start, 10)
+	}
+	return fmt.Sprintf("%d-%d", i.Start, i.Stop-1)
+}
+
+func parseInterval(s string) (Interval, error) {
+	var err error
+	var i Interval
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	switch len(parts) {
+	case 1:
+		if i.Start, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+			return i, fmt.Errorf("gtid.invalid.interval[%s]", s)
+		}
+		i.Stop = i.Start + 1
+	case 2:
+		if i.Start, err = strconv.ParseInt(parts[0], 10, 64); err != nil {
+			return i, fmt.Errorf("gtid.invalid.interval[%s]", s)
+		}
+		if i.Stop, err = strconv.ParseInt(parts[1], 10, 64); err != nil {
+			return i, fmt.Errorf("gtid.invalid.interval[%s]", s)
+		}
+		i.Stop++
+	default:
+		return i, fmt.Errorf("gtid.invalid.interval[%s]", s)
+	}
+	if i.Start < 1 || i.Stop <= i.Start {
+		return i, fmt.Errorf("gtid.invalid.interval[%s]", s)
+	}
+	return i, nil
+}
+
+// Normalize sorts and merges the overlapping or adjacent intervals, the empty intervals are dropped.
+func Normalize(intervals []Interval) []Interval {
+	valid := intervals[:0]
+	for _, i := range intervals {
+		if i.Stop > i.Start {
+			valid = append(valid, i)
+		}
+	}
+	intervals = valid
+
+	if len(intervals) <= 1 {
+		return intervals
+	}
+	sort.Slice(intervals, func(i, j int) bool {
+		return intervals[i].Start < intervals[j].Start
+	})
+
+	merged := intervals[:1]
+	for _, cur := range intervals[1:] {
+		last := &merged[len(merged)-1]
+		if cur.Start <= last.Stop {
+			if cur.Stop > last.Stop {
+				last.Stop = cur.Stop
+			}
+			continue
+		}
+		merged = append(merged, cur)
+	}
+	return merged
+}
+
+// contains checks the interval i is covered by the normalized intervals.
+func contains(intervals []Interval, i Interval) bool {
+	for _, cur := range intervals {
+		if cur.Start <= i.Start && i.Stop <= cur.Stop {
+			return true
+		}
+	}
+	return false
+}
+
+// subtract returns the normalized intervals a minus b.
+func subtract(a []Interval, b []Interval) []Interval {
+	var res []Interval
+	for _, cur := range a {
+		for _, sub := range b {
+			if sub.Stop <= cur.Start || sub.Start >= cur.Stop {
+				continue
+			}
+			if sub.Start > cur.Start {
+				res = append(res, Interval{Start: cur.Start, Stop: sub.Start})
+			}
+			cur.Start = sub.Stop
+			if cur.Start >= cur.Stop {
+				break
+			}
+		}
+		if cur.Stop > cur.Start {
+			res = append(res, cur)
+		}
+	}
+	return res
+}
+
+// UUIDSet is the GNO intervals of one server uuid.
+type UUIDSet struct {
+	SID       SID
+	Intervals []Interval
+}
+
+func (u *UUIDSet) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(u.SID.String())
+	for _, i := range u.Intervals {
+		buf.WriteByte(':')
+		buf.WriteString(i.String())
+	}
+	return buf.String()
+}
+
+// Set is the GTID set like the @@gtid_executed.
+type Set struct {
+	sets map[SID]*UUIDSet
+}
+
+// NewSet creates an empty set.
+func NewSet() *Set {
+	return &Set{sets: make(map[SID]*UUIDSet)}
+}
+
+// ParseSet parses the set string like '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,...'.
+func ParseSet(s string) (*Set, error) {
+	set := NewSet()
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return set, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		items := strings.Split(strings.TrimSpace(part), ":")
+		if len(items) < 2 {
+			return nil, fmt.Errorf("gtid.invalid.uuid.set[%s]", part)
+		}
+		sid, err := ParseSID(items[0])
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range items[1:] {
+			interval, err := parseInterval(item)
+			if err != nil {
+				return nil, err
+			}
+			set.addInterval(sid, interval)
+		}
+	}
+	return set, nil
+}
+
+func (s *Set) addInterval(sid SID, interval Interval) {
+	u, ok := s.sets[sid]
+	if !ok {
+		u = &UUIDSet{SID: sid}
+		s.sets[sid] = u
+	}
+	u.Intervals = Normalize(append(u.Intervals, interval))
+}
+
+// AddGTID adds the sid:gno to the set.
+func (s *Set) AddGTID(sid SID, gno int64) {
+	s.addInterval(sid, Interval{Start: gno, Stop: gno + 1})
+}
+
+// UUIDSets returns the uuid sets ordered by the sid.
+func (s *Set) UUIDSets() []*UUIDSet {
+	sets := make([]*UUIDSet, 0, len(s.sets))
+	for _, u := range s.sets {
+		sets = append(sets, u)
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		return bytes.Compare(sets[i].SID[:], sets[j].SID[:]) < 0
+	})
+	return sets
+}
+
+// IsEmpty checks the set has no GTID.
+func (s *Set) IsEmpty() bool {
+	return len(s.sets) == 0
+}
+
+// ContainsGTID checks the sid:gno is in the set.
+func (s *Set) ContainsGTID(sid SID, gno int64) bool {
+	u, ok := s.sets[sid]
+	if !ok {
+		return false
+	}
+	return contains(u.Intervals, Interval{Start: gno, Stop: gno + 1})
+}
+
+// Contains checks all the GTIDs of the other are in the set.
+func (s *Set) Contains(other *Set) bool {
+	for sid, o := range other.sets {
+		u, ok := s.sets[sid]
+		if !ok {
+			return false
+		}
+		for _, i := range o.Intervals {
+			if !contains(u.Intervals, i) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Equal checks the two sets have the same GTIDs.
+func (s *Set) Equal(other *Set) bool {
+	return s.Contains(other) && other.Contains(s)
+}
+
+// Union returns a new set with the GTIDs in either set.
+func (s *Set) Union(other *Set) *Set {
+	res := s.Clone()
+	for sid, o := range other.sets {
+		for _, i := range o.Intervals {
+			res.addInterval(sid, i)
+		}
+	}
+	return res
+}
+
+// Subtract returns a new set with the GTIDs in the set but not in the other.
+func (s *Set) Subtract(other *Set) *Set {
+	res := NewSet()
+	for sid, u := range s.sets {
+		intervals := u.Intervals
+		if o, ok := other.sets[sid]; ok {
+			intervals = subtract(intervals, o.Intervals)
+		}
+		if len(intervals) > 0 {
+			res.sets[sid] = &UUIDSet{SID: sid, Intervals: append([]Interval(nil), intervals...)}
+		}
+	}
+	return res
+}
+
+// Clone returns a deep copy.
+func (s *Set) Clone() *Set {
+	c := NewSet()
+	for sid, u := range s.sets {
+		c.sets[sid] = &UUIDSet{SID: sid, Intervals: append([]Interval(nil), u.Intervals...)}
+	}
+	return c
+}
+
+// String returns the set in MySQL format.
+func (s *Set) String() string {
+	var parts []string
+	for _, u := range s.UUIDSets() {
+		parts = append(parts, u.String())
+	}
+	return strings.Join(parts, ",")
+}
+
+// Encode encodes the set in the binary format of COM_BINLOG_DUMP_GTID.
+func (s *Set) Encode() []byte {
+	sets := s.UUIDSets()
+	buf := common.NewBuffer(64)
+
+	// n_sids
+	buf.WriteU64(uint64(len(sets)))
+	for _, u := range sets {
+		buf.WriteBytes(u.SID[:])
+		// n_intervals
+		buf.WriteU64(uint64(len(u.Intervals)))
+		for _, i := range u.Intervals {
+			buf.WriteU64(uint64(i.Start))
+			buf.WriteU64(uint64(i.Stop))
+		}
+	}
+	return buf.Datas()
+}
+
+// DecodeSet decodes the binary format set.
+func DecodeSet(data []byte) (*Set, error) {
+	var err error
+	var n, m, start, stop uint64
+	var sid []byte
+
+	set := NewSet()
+	buf := common.ReadBuffer(data)
+	if n, err = buf.ReadU64(); err != nil {
+		return nil, fmt.Errorf("gtid.invalid.encoded.set:%v", data)
+	}
+	for k := uint64(0); k < n; k++ {
+		if sid, err = buf.ReadBytes(16); err != nil {
+			return nil, fmt.Errorf("gtid.invalid.encoded.set:%v", data)
+		}
+		if m, err = buf.ReadU64(); err != nil {
+			return nil, fmt.Errorf("gtid.invalid.encoded.set:%v", data)
+		}
+		for j := uint64(0); j < m; j++ {
+			if start, err = buf.ReadU64(); err != nil {
+				return nil, fmt.Errorf("gtid.invalid.encoded.set:%v", data)
+			}
+			if stop, err = buf.ReadU64(); err != nil {
+				return nil, fmt.Errorf("gtid.invalid.encoded.set:%v", data)
+			}
+			var s SID
+			copy(s[:], sid)
+			set.addInterval(s, Interval{Start: int64(start), Stop: int64(stop)})
+		}
+	}
+	return set, nil
+}
@@ -0,0 +1,159 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package gtid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSID(t *testing.T) {
+	sid, err := ParseSID("3E11FA47-71CA-11E1-9E33-C80AA9429562")
+	assert.Nil(t, err)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562", sid.String())
+
+	_, err = ParseSID("3e11fa47-71ca")
+	assert.NotNil(t, err)
+	_, err = ParseSID("xe11fa47-71ca-11e1-9e33-c80aa9429562")
+	assert.NotNil(t, err)
+}
+
+func TestParseSet(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:7:1-5:6", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-7"},
+		{"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3:5-9:2-4", "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-9"},
+		{
+			"ff11fa47-71ca-11e1-9e33-c80aa9429562:1,\n3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3:10",
+			"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3:10,ff11fa47-71ca-11e1-9e33-c80aa9429562:1",
+		},
+	}
+	for _, test := range tests {
+		set, err := ParseSet(test.in)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, set.String())
+	}
+
+	bads := []string{
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:0-5",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2-3",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:a",
+		"3e11fa47:1",
+	}
+	for _, bad := range bads {
+		_, err := ParseSet(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestSetAddGTID(t *testing.T) {
+	set, err := ParseSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	assert.Nil(t, err)
+	clone := set.Clone()
+
+	sid, _ := ParseSID("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	set.AddGTID(sid, 6)
+	set.AddGTID(sid, 8)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6:8", set.String())
+	set.AddGTID(sid, 7)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-8", set.String())
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", clone.String())
+}
+
+func TestSetEncode(t *testing.T) {
+	set, err := ParseSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:7,ff11fa47-71ca-11e1-9e33-c80aa9429562:1")
+	assert.Nil(t, err)
+
+	data := set.Encode()
+	// n_sids + 2 * (sid + n_intervals) + 3 intervals.
+	assert.Equal(t, 8+2*(16+8)+3*16, len(data))
+
+	got, err := DecodeSet(data)
+	assert.Nil(t, err)
+	assert.Equal(t, set.String(), got.String())
+
+	_, err = DecodeSet(data[:20])
+	assert.NotNil(t, err)
+}
+
+func TestNormalize(t *testing.T) {
+	got := Normalize([]Interval{{5, 7}, {1, 3}, {3, 4}, {9, 9}, {6, 8}})
+	assert.Equal(t, []Interval{{1, 4}, {5, 8}}, got)
+	assert.Equal(t, 0, len(Normalize([]Interval{{2, 2}})))
+}
+
+func TestSetArithmetic(t *testing.T) {
+	mustParse := func(s string) *Set {
+		set, err := ParseSet(s)
+		assert.Nil(t, err)
+		return set
+	}
+	const a = "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	const b = "ff11fa47-71ca-11e1-9e33-c80aa9429562"
+
+	// Contains.
+	{
+		set := mustParse(a + ":1-10:20-30," + b + ":1-5")
+		assert.True(t, set.Contains(mustParse(a+":2-5:21")))
+		assert.True(t, set.Contains(mustParse("")))
+		assert.True(t, set.Contains(set))
+		assert.False(t, set.Contains(mustParse(a+":9-11")))
+		assert.False(t, set.Contains(mustParse(b+":6")))
+		assert.False(t, set.Contains(mustParse("0e11fa47-71ca-11e1-9e33-c80aa9429562:1")))
+
+		sid, _ := ParseSID(a)
+		assert.True(t, set.ContainsGTID(sid, 20))
+		assert.False(t, set.ContainsGTID(sid, 15))
+	}
+
+	// Union.
+	{
+		x := mustParse(a + ":1-5")
+		y := mustParse(a + ":6-8:10," + b + ":1")
+		got := x.Union(y)
+		assert.Equal(t, a+":1-8:10,"+b+":1", got.String())
+		assert.Equal(t, a+":1-5", x.String())
+		assert.True(t, got.Contains(x))
+		assert.True(t, got.Contains(y))
+	}
+
+	// Subtract.
+	{
+		x := mustParse(a + ":1-10:20-30," + b + ":1-5")
+		tests := []struct {
+			sub  string
+			want string
+		}{
+			{"", a + ":1-10:20-30," + b + ":1-5"},
+			{a + ":1-10", a + ":20-30," + b + ":1-5"},
+			{a + ":3-5:25", a + ":1-2:6-10:20-24:26-30," + b + ":1-5"},
+			{a + ":5-22", a + ":1-4:23-30," + b + ":1-5"},
+			{a + ":1-100," + b + ":1-5", ""},
+		}
+		for _, test := range tests {
+			assert.Equal(t, test.want, x.Subtract(mustParse(test.sub)).String(), test.sub)
+		}
+	}
+
+	// Equal and empty.
+	{
+		assert.True(t, mustParse(a+":1-3").Equal(mustParse(a+":1:2-3")))
+		assert.False(t, mustParse(a+":1-3").Equal(mustParse(a+":1-4")))
+		assert.True(t, mustParse("").IsEmpty())
+		assert.True(t, mustParse(a+":1-3").Subtract(mustParse(a+":1-3")).IsEmpty())
+	}
+}
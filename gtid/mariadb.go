@@ -0,0 +1,126 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package gtid
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MariadbGTID is the MariaDB GTID like '0-1-100', domain-server-sequence.
+type MariadbGTID struct {
+	DomainID       uint32
+	ServerID       uint32
+	SequenceNumber uint64
+}
+
+// ParseMariadbGTID parses the GTID like '0-1-100'.
+func ParseMariadbGTID(s string) (MariadbGTID, error) {
+	var g MariadbGTID
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) != 3 {
+		return g, fmt.Errorf("gtid.invalid.mariadb.gtid[%s]", s)
+	}
+	domain, err := strconv.ParseUint(parts[0], 10, 32)
+	if err != nil {
+		return g, fmt.Errorf("gtid.invalid.mariadb.gtid[%s]", s)
+	}
+	server, err := strconv.ParseUint(parts[1], 10, 32)
+	if err != nil {
+		return g, fmt.Errorf("gtid.invalid.mariadb.gtid[%s]", s)
+	}
+	seq, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return g, fmt.Errorf("gtid.invalid.mariadb.gtid[%s]", s)
+	}
+	g.DomainID = uint32(domain)
+	g.ServerID = uint32(server)
+	g.SequenceNumber = seq
+	return g, nil
+}
+
+func (g MariadbGTID) String() string {
+	return fmt.Sprintf("%d-%d-%d", g.DomainID, g.ServerID, g.SequenceNumber)
+}
+
+// MariadbSet is the MariaDB GTID position like the @@gtid_slave_pos,
+// it keeps the last GTID of each replication domain.
+type MariadbSet struct {
+	domains map[uint32]MariadbGTID
+}
+
+// NewMariadbSet creates an empty set.
+func NewMariadbSet() *MariadbSet {
+	return &MariadbSet{domains: make(map[uint32]MariadbGTID)}
+}
+
+// ParseMariadbSet parses the position like '0-1-100,1-2-50'.
+func ParseMariadbSet(s string) (*MariadbSet, error) {
+	set := NewMariadbSet()
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return set, nil
+	}
+
+	for _, part := range strings.Split(s, ",") {
+		g, err := ParseMariadbGTID(part)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set.domains[g.DomainID]; ok {
+			return nil, fmt.Errorf("gtid.duplicate.mariadb.domain[%s]", s)
+		}
+		set.domains[g.DomainID] = g
+	}
+	return set, nil
+}
+
+// Update sets the GTID as the last one of its domain.
+func (s *MariadbSet) Update(g MariadbGTID) {
+	s.domains[g.DomainID] = g
+}
+
+// Contains checks the GTID is not after the last one of its domain.
+func (s *MariadbSet) Contains(g MariadbGTID) bool {
+	last, ok := s.domains[g.DomainID]
+	return ok && g.SequenceNumber <= last.SequenceNumber
+}
+
+// GTIDs returns the GTIDs ordered by the domain.
+func (s *MariadbSet) GTIDs() []MariadbGTID {
+	gtids := make([]MariadbGTID, 0, len(s.domains))
+	for _, g := range s.domains {
+		gtids = append(gtids, g)
+	}
+	sort.Slice(gtids, func(i, j int) bool {
+		return gtids[i].DomainID < gtids[j].DomainID
+	})
+	return gtids
+}
+
+// Clone returns a copy.
+func (s *MariadbSet) Clone() *MariadbSet {
+	c := NewMariadbSet()
+	for k, v := range s.domains {
+		c.domains[k] = v
+	}
+	return c
+}
+
+// String returns the set in MariaDB format.
+func (s *MariadbSet) String() string {
+	var parts []string
+	for _, g := range s.GTIDs() {
+		parts = append(parts, g.String())
+	}
+	return strings.Join(parts, ",")
+}
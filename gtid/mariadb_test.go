@@ -0,0 +1,44 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package gtid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMariadbSet(t *testing.T) {
+	set, err := ParseMariadbSet(" 1-2-50,0-1-100\n")
+	assert.Nil(t, err)
+	assert.Equal(t, "0-1-100,1-2-50", set.String())
+	clone := set.Clone()
+
+	assert.True(t, set.Contains(MariadbGTID{DomainID: 0, ServerID: 3, SequenceNumber: 99}))
+	assert.False(t, set.Contains(MariadbGTID{DomainID: 0, ServerID: 1, SequenceNumber: 101}))
+	assert.False(t, set.Contains(MariadbGTID{DomainID: 2, ServerID: 1, SequenceNumber: 1}))
+
+	g, err := ParseMariadbGTID("0-3-101")
+	assert.Nil(t, err)
+	set.Update(g)
+	set.Update(MariadbGTID{DomainID: 2, ServerID: 1, SequenceNumber: 1})
+	assert.Equal(t, "0-3-101,1-2-50,2-1-1", set.String())
+	assert.Equal(t, "0-1-100,1-2-50", clone.String())
+
+	empty, err := ParseMariadbSet("")
+	assert.Nil(t, err)
+	assert.Equal(t, "", empty.String())
+
+	bads := []string{"0-1", "a-1-1", "0-1-1,0-2-2", "0-1-x"}
+	for _, bad := range bads {
+		_, err := ParseMariadbSet(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
@@ -0,0 +1,407 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package memdb
+
+import (
+	"bytes"
+	"encoding/hex"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	trueValue  = sqltypes.NewInt64(1)
+	falseValue = sqltypes.NewInt64(0)
+)
+
+func boolValue(b bool) sqltypes.Value {
+	if b {
+		return trueValue
+	}
+	return falseValue
+}
+
+// notSupported returns the ER_NOT_SUPPORTED_YET of the node.
+func notSupported(node sqlparser.SQLNode) error {
+	return sqldb.NewSQLError(sqldb.ER_NOT_SUPPORTED_YET, "This version of MySQL doesn't yet support '%s'", sqlparser.String(node))
+}
+
+// evaluator evaluates the expressions on the rows of the table, the table is nil without the FROM.
+type evaluator struct {
+	table *table
+}
+
+// eval evaluates the expression on the row, the booleans are the 1 and the 0 and the NULL is the unknown.
+func (e *evaluator) eval(expr sqlparser.Expr, row []sqltypes.Value) (sqltypes.Value, error) {
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal:
+		return literal(expr)
+	case *sqlparser.NullVal:
+		return sqltypes.NULL, nil
+	case sqlparser.BoolVal:
+		return boolValue(bool(expr)), nil
+	case *sqlparser.ColName:
+		i, err := e.table.column(expr.Name.String())
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return row[i], nil
+	case *sqlparser.ParenExpr:
+		return e.eval(expr.Expr, row)
+	case *sqlparser.AndExpr:
+		l, err := e.eval(expr.Left, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if !l.IsNull() && !truth(l) {
+			return falseValue, nil
+		}
+		r, err := e.eval(expr.Right, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		switch {
+		case !r.IsNull() && !truth(r):
+			return falseValue, nil
+		case l.IsNull() || r.IsNull():
+			return sqltypes.NULL, nil
+		}
+		return trueValue, nil
+	case *sqlparser.OrExpr:
+		l, err := e.eval(expr.Left, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if !l.IsNull() && truth(l) {
+			return trueValue, nil
+		}
+		r, err := e.eval(expr.Right, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		switch {
+		case !r.IsNull() && truth(r):
+			return trueValue, nil
+		case l.IsNull() || r.IsNull():
+			return sqltypes.NULL, nil
+		}
+		return falseValue, nil
+	case *sqlparser.NotExpr:
+		v, err := e.eval(expr.Expr, row)
+		if err != nil || v.IsNull() {
+			return sqltypes.NULL, err
+		}
+		return boolValue(!truth(v)), nil
+	case *sqlparser.IsExpr:
+		v, err := e.eval(expr.Expr, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		switch expr.Operator {
+		case sqlparser.IsNullStr:
+			return boolValue(v.IsNull()), nil
+		case sqlparser.IsNotNullStr:
+			return boolValue(!v.IsNull()), nil
+		case sqlparser.IsTrueStr:
+			return boolValue(!v.IsNull() && truth(v)), nil
+		case sqlparser.IsNotTrueStr:
+			return boolValue(v.IsNull() || !truth(v)), nil
+		case sqlparser.IsFalseStr:
+			return boolValue(!v.IsNull() && !truth(v)), nil
+		case sqlparser.IsNotFalseStr:
+			return boolValue(v.IsNull() || truth(v)), nil
+		}
+	case *sqlparser.ComparisonExpr:
+		return e.compare(expr, row)
+	case *sqlparser.RangeCond:
+		v, err := e.eval(expr.Left, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		from, err := e.eval(expr.From, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		to, err := e.eval(expr.To, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if v.IsNull() || from.IsNull() || to.IsNull() {
+			return sqltypes.NULL, nil
+		}
+		between := compare(v, from) >= 0 && compare(v, to) <= 0
+		return boolValue(between == (expr.Operator == sqlparser.BetweenStr)), nil
+	case *sqlparser.UnaryExpr:
+		v, err := e.eval(expr.Expr, row)
+		if err != nil || v.IsNull() {
+			return sqltypes.NULL, err
+		}
+		switch expr.Operator {
+		case sqlparser.UPlusStr:
+			return v, nil
+		case sqlparser.UMinusStr:
+			return arithmetic(sqlparser.MinusStr, falseValue, v)
+		}
+	case *sqlparser.BinaryExpr:
+		l, err := e.eval(expr.Left, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		r, err := e.eval(expr.Right, row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if l.IsNull() || r.IsNull() {
+			return sqltypes.NULL, nil
+		}
+		return arithmetic(expr.Operator, l, r)
+	}
+	return sqltypes.NULL, notSupported(expr)
+}
+
+// compare evaluates the comparison, the NULL compared is the unknown except the <=>.
+func (e *evaluator) compare(expr *sqlparser.ComparisonExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	l, err := e.eval(expr.Left, row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	switch expr.Operator {
+	case sqlparser.InStr, sqlparser.NotInStr:
+		tuple, ok := expr.Right.(sqlparser.ValTuple)
+		if !ok {
+			return sqltypes.NULL, notSupported(expr)
+		}
+		if l.IsNull() {
+			return sqltypes.NULL, nil
+		}
+		in, unknown := false, false
+		for _, item := range tuple {
+			r, err := e.eval(item, row)
+			if err != nil {
+				return sqltypes.NULL, err
+			}
+			if r.IsNull() {
+				unknown = true
+			} else if compare(l, r) == 0 {
+				in = true
+			}
+		}
+		if !in && unknown {
+			return sqltypes.NULL, nil
+		}
+		return boolValue(in == (expr.Operator == sqlparser.InStr)), nil
+	}
+
+	r, err := e.eval(expr.Right, row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	if expr.Operator == sqlparser.NullSafeEqualStr {
+		if l.IsNull() || r.IsNull() {
+			return boolValue(l.IsNull() && r.IsNull()), nil
+		}
+		return boolValue(compare(l, r) == 0), nil
+	}
+	if l.IsNull() || r.IsNull() {
+		return sqltypes.NULL, nil
+	}
+	switch expr.Operator {
+	case sqlparser.EqualStr:
+		return boolValue(compare(l, r) == 0), nil
+	case sqlparser.NotEqualStr:
+		return boolValue(compare(l, r) != 0), nil
+	case sqlparser.LessThanStr:
+		return boolValue(compare(l, r) < 0), nil
+	case sqlparser.LessEqualStr:
+		return boolValue(compare(l, r) <= 0), nil
+	case sqlparser.GreaterThanStr:
+		return boolValue(compare(l, r) > 0), nil
+	case sqlparser.GreaterEqualStr:
+		return boolValue(compare(l, r) >= 0), nil
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		if expr.Escape != nil {
+			return sqltypes.NULL, notSupported(expr)
+		}
+		return boolValue(likeRegexp(r.String()).MatchString(l.String()) == (expr.Operator == sqlparser.LikeStr)), nil
+	}
+	return sqltypes.NULL, notSupported(expr)
+}
+
+// literal returns the value of the literal, the integers are the BIGINT and the decimals are the DOUBLE.
+func literal(v *sqlparser.SQLVal) (sqltypes.Value, error) {
+	switch v.Type {
+	case sqlparser.StrVal:
+		return sqltypes.NewVarChar(string(v.Val)), nil
+	case sqlparser.IntVal, sqlparser.HexNum:
+		return sqltypes.BuildIntegral(string(v.Val))
+	case sqlparser.FloatVal:
+		return sqltypes.ValueFromBytes(sqltypes.Float64, v.Val)
+	case sqlparser.HexVal:
+		b, err := hex.DecodeString(string(v.Val))
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.VarBinary, b), nil
+	}
+	return sqltypes.NULL, notSupported(v)
+}
+
+// isNumber checks whether the value is compared and computed as a number.
+func isNumber(v sqltypes.Value) bool {
+	return v.IsIntegral() || v.IsFloat() || v.Type() == sqltypes.Decimal
+}
+
+// toFloat returns the number of the value, the leading number of the string like MySQL.
+func toFloat(v sqltypes.Value) float64 {
+	// The strings are their leading numbers like MySQL, the truncation isn't an error here.
+	f, _ := v.ToFloat64()
+	return f
+}
+
+// truth checks whether the value not NULL is true.
+func truth(v sqltypes.Value) bool {
+	b, _ := v.ToBool()
+	return b
+}
+
+// compare compares the values not NULL, as the numbers if any of them is a number,
+// otherwise the strings case insensitively and the binaries by the bytes.
+func compare(a, b sqltypes.Value) int {
+	if isNumber(a) || isNumber(b) {
+		if a.IsSigned() && b.IsSigned() {
+			x, _ := a.ParseInt64()
+			y, _ := b.ParseInt64()
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+		x, y := toFloat(a), toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	if a.IsBinary() || b.IsBinary() {
+		return bytes.Compare(a.Raw(), b.Raw())
+	}
+	return strings.Compare(strings.ToLower(a.String()), strings.ToLower(b.String()))
+}
+
+// arithmetic computes the values not NULL, the integers stay the BIGINT but the / is the DOUBLE,
+// the division by zero is the NULL.
+func arithmetic(op string, a, b sqltypes.Value) (sqltypes.Value, error) {
+	if a.IsSigned() && b.IsSigned() {
+		x, _ := a.ParseInt64()
+		y, _ := b.ParseInt64()
+		switch op {
+		case sqlparser.PlusStr:
+			return sqltypes.NewInt64(x + y), nil
+		case sqlparser.MinusStr:
+			return sqltypes.NewInt64(x - y), nil
+		case sqlparser.MultStr:
+			return sqltypes.NewInt64(x * y), nil
+		case sqlparser.IntDivStr, sqlparser.ModStr:
+			if y == 0 {
+				return sqltypes.NULL, nil
+			}
+			if op == sqlparser.ModStr {
+				return sqltypes.NewInt64(x % y), nil
+			}
+			return sqltypes.NewInt64(x / y), nil
+		}
+	}
+	x, y := toFloat(a), toFloat(b)
+	switch op {
+	case sqlparser.PlusStr:
+		return sqltypes.NewFloat64(x + y), nil
+	case sqlparser.MinusStr:
+		return sqltypes.NewFloat64(x - y), nil
+	case sqlparser.MultStr:
+		return sqltypes.NewFloat64(x * y), nil
+	case sqlparser.DivStr, sqlparser.IntDivStr, sqlparser.ModStr:
+		if y == 0 {
+			return sqltypes.NULL, nil
+		}
+		switch op {
+		case sqlparser.DivStr:
+			return sqltypes.NewFloat64(x / y), nil
+		case sqlparser.IntDivStr:
+			return sqltypes.NewInt64(int64(x / y)), nil
+		}
+		return sqltypes.NewFloat64(math.Mod(x, y)), nil
+	}
+	return sqltypes.NULL, sqldb.NewSQLError(sqldb.ER_NOT_SUPPORTED_YET, "This version of MySQL doesn't yet support '%s'", op)
+}
+
+// likeRegexp returns the case insensitive regexp of the LIKE pattern, the \ escapes the % and the _.
+func likeRegexp(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?is)^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '%':
+			b.WriteString(".*")
+		case '_':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			} else {
+				b.WriteString(`\\`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// convert converts the value to the type of the column, it's nil if the value doesn't fit.
+func convert(typ querypb.Type, v sqltypes.Value) (sqltypes.Value, bool) {
+	if v.IsNull() {
+		return v, true
+	}
+	val := v.Raw()
+	if sqltypes.IsIntegral(typ) && v.IsFloat() {
+		f, err := v.ParseFloat64()
+		if err != nil || f != math.Trunc(f) {
+			return sqltypes.NULL, false
+		}
+		val = strconv.AppendFloat(nil, f, 'f', -1, 64)
+	}
+	if sqltypes.IsIntegral(typ) || sqltypes.IsFloat(typ) || typ == sqltypes.Decimal {
+		if !isNumber(v) && !v.IsText() {
+			return sqltypes.NULL, false
+		}
+		val = bytes.TrimSpace(val)
+	}
+	c, err := sqltypes.ValueFromBytes(typ, val)
+	if err != nil {
+		return sqltypes.NULL, false
+	}
+	return c, true
+}
@@ -0,0 +1,733 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package memdb is the in-memory tables engine of the driver.Handler, it serves the tests and the demos
+// by a MySQL-compatible server without the backend:
+//
+//	handler := memdb.NewHandler(log)
+//	svr, err := driver.NewListener(log, ":3306", handler)
+//	go svr.Accept()
+//
+// It answers the CREATE TABLE, the DROP TABLE, the TRUNCATE TABLE, the INSERT, the DELETE and the SELECT of a table
+// with the WHERE, the ORDER BY and the LIMIT. The statements are autocommitted, there're no transactions, no joins
+// and no aggregations. The tables are of one namespace, the schemas of the qualifiers and the USE are ignored.
+// The users aren't checked.
+package memdb
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// column is a column of the table.
+type column struct {
+	name          string
+	typ           querypb.Type
+	notNull       bool
+	autoIncrement bool
+	primary       bool
+	unique        bool
+
+	// def is the default value, nil if the column has none.
+	def *sqltypes.Value
+}
+
+// key is the primary or a unique key, the keys aren't indexed and the rows are scanned.
+type key struct {
+	name    string
+	columns []int
+}
+
+// table is the rows of the columns.
+type table struct {
+	name          string
+	columns       []*column
+	keys          []*key
+	rows          [][]sqltypes.Value
+	autoIncrement int64
+}
+
+// column returns the index of the column.
+func (t *table) column(name string) (int, error) {
+	if t != nil {
+		for i, c := range t.columns {
+			if strings.EqualFold(c.name, name) {
+				return i, nil
+			}
+		}
+	}
+	return -1, sqldb.NewSQLError(sqldb.ER_BAD_FIELD_ERROR, "Unknown column '%s' in '%s'", name, "field list")
+}
+
+// field returns the field of the column.
+func (t *table) field(i int, name string) *querypb.Field {
+	c := t.columns[i]
+	f := newField(name, c.typ)
+	f.OrgName, f.Table, f.OrgTable = c.name, t.name, t.name
+	if c.notNull {
+		f.Flags |= uint32(querypb.MySqlFlag_NOT_NULL_FLAG)
+	}
+	if c.primary {
+		f.Flags |= uint32(querypb.MySqlFlag_PRI_KEY_FLAG)
+	}
+	if c.unique {
+		f.Flags |= uint32(querypb.MySqlFlag_UNIQUE_KEY_FLAG)
+	}
+	if c.autoIncrement {
+		f.Flags |= uint32(querypb.MySqlFlag_AUTO_INCREMENT_FLAG)
+	}
+	return f
+}
+
+// newField returns the field of the type, the non-text types are binary.
+func newField(name string, typ querypb.Type) *querypb.Field {
+	_, flags := sqltypes.TypeToMySQL(typ)
+	charset := uint32(sqldb.CharacterSetBinary)
+	if sqltypes.IsText(typ) {
+		charset = sqldb.DefaultCollation
+	} else {
+		flags |= int64(querypb.MySqlFlag_BINARY_FLAG)
+	}
+	return &querypb.Field{Name: name, Type: typ, Charset: charset, Flags: uint32(flags)}
+}
+
+// columnTypes are the types of the columns by the names of the CREATE TABLE.
+var columnTypes = map[string][2]querypb.Type{
+	"tinyint":    {sqltypes.Int8, sqltypes.Uint8},
+	"bool":       {sqltypes.Int8, sqltypes.Uint8},
+	"boolean":    {sqltypes.Int8, sqltypes.Uint8},
+	"smallint":   {sqltypes.Int16, sqltypes.Uint16},
+	"mediumint":  {sqltypes.Int24, sqltypes.Uint24},
+	"int":        {sqltypes.Int32, sqltypes.Uint32},
+	"integer":    {sqltypes.Int32, sqltypes.Uint32},
+	"bigint":     {sqltypes.Int64, sqltypes.Uint64},
+	"float":      {sqltypes.Float32, sqltypes.Float32},
+	"double":     {sqltypes.Float64, sqltypes.Float64},
+	"real":       {sqltypes.Float64, sqltypes.Float64},
+	"decimal":    {sqltypes.Decimal, sqltypes.Decimal},
+	"numeric":    {sqltypes.Decimal, sqltypes.Decimal},
+	"char":       {sqltypes.Char, sqltypes.Char},
+	"varchar":    {sqltypes.VarChar, sqltypes.VarChar},
+	"tinytext":   {sqltypes.Text, sqltypes.Text},
+	"text":       {sqltypes.Text, sqltypes.Text},
+	"mediumtext": {sqltypes.Text, sqltypes.Text},
+	"longtext":   {sqltypes.Text, sqltypes.Text},
+	"binary":     {sqltypes.Binary, sqltypes.Binary},
+	"varbinary":  {sqltypes.VarBinary, sqltypes.VarBinary},
+	"tinyblob":   {sqltypes.Blob, sqltypes.Blob},
+	"blob":       {sqltypes.Blob, sqltypes.Blob},
+	"mediumblob": {sqltypes.Blob, sqltypes.Blob},
+	"longblob":   {sqltypes.Blob, sqltypes.Blob},
+	"date":       {sqltypes.Date, sqltypes.Date},
+	"datetime":   {sqltypes.Datetime, sqltypes.Datetime},
+	"timestamp":  {sqltypes.Timestamp, sqltypes.Timestamp},
+	"time":       {sqltypes.Time, sqltypes.Time},
+	"year":       {sqltypes.Year, sqltypes.Year},
+	"json":       {sqltypes.TypeJSON, sqltypes.TypeJSON},
+}
+
+// Handler is the driver.Handler of the in-memory tables.
+type Handler struct {
+	log *xlog.Log
+
+	mu     sync.RWMutex
+	tables map[string]*table
+}
+
+var _ driver.Handler = &Handler{}
+
+// NewHandler creates the handler without tables.
+func NewHandler(log *xlog.Log) *Handler {
+	return &Handler{log: log, tables: make(map[string]*table)}
+}
+
+// NewSession impl.
+func (h *Handler) NewSession(session *driver.Session) {}
+
+// SessionClosed impl.
+func (h *Handler) SessionClosed(session *driver.Session) {}
+
+// SessionCheck impl.
+func (h *Handler) SessionCheck(session *driver.Session) error {
+	return nil
+}
+
+// AuthCheck impl, all the users are accepted.
+func (h *Handler) AuthCheck(session *driver.Session) error {
+	return nil
+}
+
+// ComInitDB impl, the schemas are ignored.
+func (h *Handler) ComInitDB(session *driver.Session, database string) error {
+	return nil
+}
+
+// ComQuery impl.
+func (h *Handler) ComQuery(session *driver.Session, query string, callback func(*sqltypes.Result) error) error {
+	stmt, err := session.Parse(query)
+	if err != nil {
+		return sqldb.NewSQLError(sqldb.ER_PARSE_ERROR, "You have an error in your SQL syntax; %v", err)
+	}
+	var qr *sqltypes.Result
+	switch stmt := stmt.(type) {
+	case *sqlparser.DDL:
+		qr, err = h.ddl(session, stmt)
+	case *sqlparser.Insert:
+		qr, err = h.insert(session, stmt)
+	case *sqlparser.Delete:
+		qr, err = h.delete(session, stmt)
+	case *sqlparser.Select:
+		qr, err = h.query(session, stmt)
+	case *sqlparser.Set, *sqlparser.Use:
+		// The session variables are tracked by the listener.
+		qr = &sqltypes.Result{}
+	default:
+		err = notSupported(stmt)
+	}
+	if err != nil {
+		return err
+	}
+	return callback(qr)
+}
+
+// Tables returns the names of the tables in the order.
+func (h *Handler) Tables() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	names := make([]string, 0, len(h.tables))
+	for _, t := range h.tables {
+		names = append(names, t.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// noSuchTable returns the ER_NO_SUCH_TABLE of the table in the current schema.
+func noSuchTable(session *driver.Session, name sqlparser.TableName) error {
+	schema := name.Qualifier.String()
+	if schema == "" {
+		schema = session.Schema()
+	}
+	if schema != "" {
+		return sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", schema+"."+name.Name.String())
+	}
+	return sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "Table '%s' doesn't exist", name.Name.String())
+}
+
+// table returns the table of the name, the caller holds the lock.
+func (h *Handler) table(session *driver.Session, name sqlparser.TableName) (*table, error) {
+	if t, ok := h.tables[strings.ToLower(name.Name.String())]; ok {
+		return t, nil
+	}
+	return nil, noSuchTable(session, name)
+}
+
+func (h *Handler) ddl(session *driver.Session, ddl *sqlparser.DDL) (*sqltypes.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	name := strings.ToLower(ddl.Table.Name.String())
+	switch ddl.Action {
+	case sqlparser.CreateTableStr:
+		if _, ok := h.tables[name]; ok {
+			if ddl.IfNotExists {
+				return &sqltypes.Result{}, nil
+			}
+			return nil, sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, "Table '%s' already exists", ddl.Table.Name.String())
+		}
+		if ddl.TableSpec == nil {
+			return nil, notSupported(ddl)
+		}
+		t, err := newTable(ddl.Table.Name.String(), ddl.TableSpec)
+		if err != nil {
+			return nil, err
+		}
+		h.tables[name] = t
+	case sqlparser.DropTableStr:
+		if _, ok := h.tables[name]; !ok {
+			if ddl.IfExists {
+				return &sqltypes.Result{}, nil
+			}
+			return nil, sqldb.NewSQLError(sqldb.ER_BAD_TABLE_ERROR, "Unknown table '%s'", ddl.Table.Name.String())
+		}
+		delete(h.tables, name)
+	case sqlparser.TruncateTableStr:
+		t, err := h.table(session, ddl.Table)
+		if err != nil {
+			return nil, err
+		}
+		t.rows, t.autoIncrement = nil, 0
+	default:
+		return nil, notSupported(ddl)
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// newTable creates the table of the CREATE TABLE.
+func newTable(name string, spec *sqlparser.TableSpec) (*table, error) {
+	t := &table{name: name}
+	for _, def := range spec.Columns {
+		types, ok := columnTypes[strings.ToLower(def.Type.Type)]
+		if !ok {
+			return nil, sqldb.NewSQLError(sqldb.ER_NOT_SUPPORTED_YET, "This version of MySQL doesn't yet support '%s'", def.Type.Type)
+		}
+		c := &column{name: def.Name.String(), typ: types[0], notNull: bool(def.Type.NotNull), autoIncrement: bool(def.Type.Autoincrement)}
+		if def.Type.Unsigned {
+			c.typ = types[1]
+		}
+		if def.Type.Default != nil && def.Type.Default.Type != sqlparser.ValArg {
+			v, err := literal(def.Type.Default)
+			if err != nil {
+				return nil, err
+			}
+			converted, ok := convert(c.typ, v)
+			if !ok {
+				return nil, sqldb.NewSQLError(sqldb.ER_INVALID_DEFAULT, "Invalid default value for '%s'", c.name)
+			}
+			c.def = &converted
+		}
+		t.columns = append(t.columns, c)
+		switch def.Type.KeyOpt {
+		case sqlparser.ColKeyPrimary:
+			t.keys = append(t.keys, &key{name: "PRIMARY", columns: []int{len(t.columns) - 1}})
+		case sqlparser.ColKeyUnique, sqlparser.ColKeyUniqueKey:
+			t.keys = append(t.keys, &key{name: c.name, columns: []int{len(t.columns) - 1}})
+		}
+	}
+	for _, idx := range spec.Indexes {
+		if !idx.Info.Primary && !idx.Info.Unique {
+			continue
+		}
+		k := &key{name: idx.Info.Name.String()}
+		if idx.Info.Primary {
+			k.name = "PRIMARY"
+		}
+		for _, col := range idx.Columns {
+			i, err := t.column(col.Column.String())
+			if err != nil {
+				return nil, sqldb.NewSQLError(sqldb.ER_KEY_COLUMN_DOES_NOT_EXITS, "Key column '%s' doesn't exist in table", col.Column.String())
+			}
+			k.columns = append(k.columns, i)
+		}
+		if k.name == "" {
+			k.name = t.columns[k.columns[0]].name
+		}
+		t.keys = append(t.keys, k)
+	}
+	for _, k := range t.keys {
+		for _, i := range k.columns {
+			if k.name == "PRIMARY" {
+				// The columns of the primary key are NOT NULL.
+				t.columns[i].primary, t.columns[i].notNull = true, true
+			} else if len(k.columns) == 1 {
+				t.columns[i].unique = true
+			}
+		}
+	}
+	return t, nil
+}
+
+// duplicate returns the ER_DUP_ENTRY if the row duplicates a row of the rows by a key, the NULLs aren't duplicates.
+func (t *table) duplicate(rows [][]sqltypes.Value, row []sqltypes.Value) error {
+	for _, k := range t.keys {
+	next:
+		for _, other := range rows {
+			for _, i := range k.columns {
+				if row[i].IsNull() || other[i].IsNull() || compare(row[i], other[i]) != 0 {
+					continue next
+				}
+			}
+			values := make([]string, len(k.columns))
+			for j, i := range k.columns {
+				values[j] = row[i].String()
+			}
+			return sqldb.NewSQLError(sqldb.ER_DUP_ENTRY, "Duplicate entry '%s' for key '%s'", strings.Join(values, "-"), k.name)
+		}
+	}
+	return nil
+}
+
+func (h *Handler) insert(session *driver.Session, ins *sqlparser.Insert) (*sqltypes.Result, error) {
+	values, ok := ins.Rows.(sqlparser.Values)
+	if !ok || ins.Action != sqlparser.InsertStr || ins.Ignore != "" || len(ins.OnDup) > 0 {
+		return nil, notSupported(ins)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, err := h.table(session, ins.Table)
+	if err != nil {
+		return nil, err
+	}
+	columns := make([]int, len(t.columns))
+	for i := range columns {
+		columns[i] = i
+	}
+	if len(ins.Columns) > 0 {
+		columns = columns[:0]
+		for _, col := range ins.Columns {
+			i, err := t.column(col.String())
+			if err != nil {
+				return nil, err
+			}
+			columns = append(columns, i)
+		}
+	}
+
+	// The rows are checked all before any of them is inserted.
+	qr := &sqltypes.Result{}
+	autoIncrement := t.autoIncrement
+	var rows [][]sqltypes.Value
+	e := &evaluator{}
+	for n, tuple := range values {
+		if len(tuple) != len(columns) {
+			return nil, sqldb.NewSQLError(sqldb.ER_WRONG_VALUE_COUNT_ON_ROW, "Column count doesn't match value count at row %d", n+1)
+		}
+		row := make([]sqltypes.Value, len(t.columns))
+		given := make([]bool, len(t.columns))
+		for j, expr := range tuple {
+			i := columns[j]
+			v, err := e.eval(expr, nil)
+			if err != nil {
+				return nil, err
+			}
+			if row[i], ok = convert(t.columns[i].typ, v); !ok {
+				return nil, sqldb.NewSQLError(sqldb.ER_TRUNCATED_WRONG_VALUE_FOR_FIELD, "Incorrect %s value: '%s' for column '%s' at row %d", strings.ToLower(t.columns[i].typ.String()), v.String(), t.columns[i].name, n+1)
+			}
+			given[i] = true
+		}
+		for i, c := range t.columns {
+			switch {
+			case c.autoIncrement && (row[i].IsNull() || row[i].String() == "0"):
+				autoIncrement++
+				row[i], _ = convert(c.typ, sqltypes.NewInt64(autoIncrement))
+				if qr.InsertID == 0 {
+					qr.InsertID = uint64(autoIncrement)
+				}
+			case c.autoIncrement:
+				if id, err := row[i].ParseInt64(); err == nil && id > autoIncrement {
+					autoIncrement = id
+				}
+			case !given[i] && c.def != nil:
+				row[i] = *c.def
+			case !given[i] && c.notNull:
+				return nil, sqldb.NewSQLError(sqldb.ER_NO_DEFAULT_FOR_FIELD, "Field '%s' doesn't have a default value", c.name)
+			case row[i].IsNull() && c.notNull:
+				return nil, sqldb.NewSQLError(sqldb.ER_BAD_NULL_ERROR, "Column '%s' cannot be null", c.name)
+			}
+		}
+		if err := t.duplicate(t.rows, row); err != nil {
+			return nil, err
+		}
+		if err := t.duplicate(rows, row); err != nil {
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	t.rows = append(t.rows, rows...)
+	t.autoIncrement = autoIncrement
+	qr.RowsAffected = uint64(len(rows))
+	return qr, nil
+}
+
+// where returns the rows of the table matching the WHERE, the NULL doesn't match.
+func where(e *evaluator, rows [][]sqltypes.Value, w *sqlparser.Where) ([]int, error) {
+	var matched []int
+	for i, row := range rows {
+		if w != nil {
+			v, err := e.eval(w.Expr, row)
+			if err != nil {
+				return nil, err
+			}
+			if v.IsNull() || !truth(v) {
+				continue
+			}
+		}
+		matched = append(matched, i)
+	}
+	return matched, nil
+}
+
+// limit returns the offset and the count of the LIMIT, the count is -1 if there's no LIMIT.
+func limit(l *sqlparser.Limit) (int, int, error) {
+	if l == nil {
+		return 0, -1, nil
+	}
+	toInt := func(expr sqlparser.Expr) (int, error) {
+		if expr == nil {
+			return 0, nil
+		}
+		if v, ok := expr.(*sqlparser.SQLVal); ok && v.Type == sqlparser.IntVal {
+			if n, err := sqltypes.BuildIntegral(string(v.Val)); err == nil {
+				if i, err := n.ParseInt64(); err == nil {
+					return int(i), nil
+				}
+			}
+		}
+		return 0, notSupported(l)
+	}
+	offset, err := toInt(l.Offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	count, err := toInt(l.Rowcount)
+	return offset, count, err
+}
+
+// sortKeys are the values of the ORDER BY of the rows.
+type sortKeys struct {
+	orders sqlparser.OrderBy
+	keys   [][]sqltypes.Value
+	index  []int
+}
+
+func (s *sortKeys) Len() int { return len(s.index) }
+
+func (s *sortKeys) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.index[i], s.index[j] = s.index[j], s.index[i]
+}
+
+// Less orders the NULLs first as MySQL does.
+func (s *sortKeys) Less(i, j int) bool {
+	for k, order := range s.orders {
+		a, b := s.keys[i][k], s.keys[j][k]
+		c := 0
+		switch {
+		case a.IsNull() && b.IsNull():
+		case a.IsNull():
+			c = -1
+		case b.IsNull():
+			c = 1
+		default:
+			c = compare(a, b)
+		}
+		if c != 0 {
+			return (c < 0) != (order.Direction == sqlparser.DescScr)
+		}
+	}
+	return false
+}
+
+// orderBy sorts the indexes of the rows by the ORDER BY, the key returns the value of the k-th order of the row.
+func orderBy(orders sqlparser.OrderBy, index []int, key func(k int, i int) (sqltypes.Value, error)) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	s := &sortKeys{orders: orders, keys: make([][]sqltypes.Value, len(index)), index: index}
+	for n, i := range index {
+		s.keys[n] = make([]sqltypes.Value, len(orders))
+		for k := range orders {
+			v, err := key(k, i)
+			if err != nil {
+				return err
+			}
+			s.keys[n][k] = v
+		}
+	}
+	sort.Stable(s)
+	return nil
+}
+
+func (h *Handler) delete(session *driver.Session, del *sqlparser.Delete) (*sqltypes.Result, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, err := h.table(session, del.Table)
+	if err != nil {
+		return nil, err
+	}
+	e := &evaluator{table: t}
+	matched, err := where(e, t.rows, del.Where)
+	if err != nil {
+		return nil, err
+	}
+	err = orderBy(del.OrderBy, matched, func(k int, i int) (sqltypes.Value, error) {
+		return e.eval(del.OrderBy[k].Expr, t.rows[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, count, err := limit(del.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if count >= 0 && count < len(matched) {
+		matched = matched[:count]
+	}
+
+	deleted := make(map[int]bool, len(matched))
+	for _, i := range matched {
+		deleted[i] = true
+	}
+	rows := t.rows[:0]
+	for i, row := range t.rows {
+		if !deleted[i] {
+			rows = append(rows, row)
+		}
+	}
+	for i := len(rows); i < len(t.rows); i++ {
+		t.rows[i] = nil
+	}
+	t.rows = rows
+	return &sqltypes.Result{RowsAffected: uint64(len(matched))}, nil
+}
+
+// output is a column of the resultset.
+type output struct {
+	name string
+	expr sqlparser.Expr
+
+	// column is the index of the table column, -1 if it's an expression.
+	column int
+}
+
+func (h *Handler) query(session *driver.Session, sel *sqlparser.Select) (*sqltypes.Result, error) {
+	if len(sel.From) != 1 || sel.Distinct != "" || sel.GroupBy != nil || sel.Having != nil {
+		return nil, notSupported(sel)
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil, notSupported(sel)
+	}
+	name, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return nil, notSupported(sel)
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	var t *table
+	rows := [][]sqltypes.Value{nil}
+	if !name.Qualifier.IsEmpty() || name.Name.String() != "dual" {
+		var err error
+		if t, err = h.table(session, name); err != nil {
+			return nil, err
+		}
+		rows = t.rows
+	}
+
+	// The outputs.
+	var outputs []*output
+	for _, expr := range sel.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			if t == nil {
+				return nil, sqldb.NewSQLError(sqldb.ER_NO_TABLES_USED, "No tables used")
+			}
+			for i, c := range t.columns {
+				outputs = append(outputs, &output{name: c.name, column: i})
+			}
+		case *sqlparser.AliasedExpr:
+			o := &output{name: expr.As.String(), expr: expr.Expr, column: -1}
+			if col, ok := expr.Expr.(*sqlparser.ColName); ok {
+				i, err := t.column(col.Name.String())
+				if err != nil {
+					return nil, err
+				}
+				o.column = i
+				if o.name == "" {
+					o.name = col.Name.String()
+				}
+			}
+			if o.name == "" {
+				o.name = sqlparser.String(expr.Expr)
+			}
+			outputs = append(outputs, o)
+		default:
+			return nil, notSupported(expr)
+		}
+	}
+
+	e := &evaluator{table: t}
+	matched, err := where(e, rows, sel.Where)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[int][]sqltypes.Value, len(matched))
+	for _, i := range matched {
+		out := make([]sqltypes.Value, len(outputs))
+		for j, o := range outputs {
+			if o.column >= 0 {
+				out[j] = rows[i][o.column]
+			} else if out[j], err = e.eval(o.expr, rows[i]); err != nil {
+				return nil, err
+			}
+		}
+		values[i] = out
+	}
+
+	// The ORDER BY of the output names and positions, otherwise the expressions on the rows.
+	err = orderBy(sel.OrderBy, matched, func(k int, i int) (sqltypes.Value, error) {
+		expr := sel.OrderBy[k].Expr
+		switch expr := expr.(type) {
+		case *sqlparser.ColName:
+			if expr.Qualifier.IsEmpty() {
+				for j, o := range outputs {
+					if o.column < 0 && strings.EqualFold(o.name, expr.Name.String()) {
+						return values[i][j], nil
+					}
+				}
+			}
+		case *sqlparser.SQLVal:
+			if expr.Type == sqlparser.IntVal {
+				var pos int
+				if _, err := fmt.Sscan(string(expr.Val), &pos); err != nil || pos < 1 || pos > len(outputs) {
+					return sqltypes.NULL, sqldb.NewSQLError(sqldb.ER_BAD_FIELD_ERROR, "Unknown column '%s' in '%s'", string(expr.Val), "order clause")
+				}
+				return values[i][pos-1], nil
+			}
+		}
+		return e.eval(expr, rows[i])
+	})
+	if err != nil {
+		return nil, err
+	}
+	offset, count, err := limit(sel.Limit)
+	if err != nil {
+		return nil, err
+	}
+	if offset > len(matched) {
+		offset = len(matched)
+	}
+	matched = matched[offset:]
+	if count >= 0 && count < len(matched) {
+		matched = matched[:count]
+	}
+
+	qr := &sqltypes.Result{}
+	for _, i := range matched {
+		qr.Rows = append(qr.Rows, values[i])
+	}
+	for j, o := range outputs {
+		if o.column >= 0 {
+			qr.Fields = append(qr.Fields, t.field(o.column, o.name))
+			continue
+		}
+		// The type of the expression is the one of the first value not NULL.
+		typ := sqltypes.Null
+		for _, row := range qr.Rows {
+			if !row[j].IsNull() {
+				typ = row[j].Type()
+				break
+			}
+		}
+		qr.Fields = append(qr.Fields, newField(o.name, typ))
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
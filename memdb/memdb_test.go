@@ -0,0 +1,167 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package memdb
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestHandler(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	h := NewHandler(log)
+	svr, err := driver.MockMysqlServer(log, h)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	client, err := driver.NewConn("mock", "mock", svr.Addr(), "test", "")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	fetch := func(query string) [][]string {
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		if err != nil {
+			return nil
+		}
+		var rows [][]string
+		for _, row := range qr.Rows {
+			var r []string
+			for _, v := range row {
+				if v.IsNull() {
+					r = append(r, "NULL")
+				} else {
+					r = append(r, v.String())
+				}
+			}
+			rows = append(rows, r)
+		}
+		return rows
+	}
+	errNum := func(query string) uint16 {
+		_, err := client.FetchAll(query, -1)
+		if serr, ok := err.(*sqldb.SQLError); ok {
+			return serr.Num
+		}
+		return 0
+	}
+
+	assert.Nil(t, client.Exec("CREATE TABLE users (id BIGINT NOT NULL AUTO_INCREMENT, name VARCHAR(32) NOT NULL, age INT DEFAULT 18, email VARCHAR(64), PRIMARY KEY (id), UNIQUE KEY uk_name (name))"))
+	assert.Nil(t, client.Exec("create table if not exists users (a int)"))
+	assert.Equal(t, uint16(sqldb.ER_TABLE_EXISTS_ERROR), errNum("CREATE TABLE users (a int)"))
+	assert.Equal(t, []string{"users"}, h.Tables())
+
+	// The auto increment ids and the defaults.
+	qr, err := client.FetchAll("INSERT INTO users (name, age, email) VALUES ('alice', 30, 'a@x.org'), ('bob', 25, NULL)", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), qr.RowsAffected)
+	assert.Equal(t, uint64(1), qr.InsertID)
+	qr, err = client.FetchAll("INSERT INTO users (name) VALUES ('carol')", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), qr.InsertID)
+	assert.Nil(t, client.Exec("INSERT INTO users VALUES (10, 'dave', -1, 'd@x.org')"))
+	qr, err = client.FetchAll("INSERT INTO users (name, age) VALUES ('eve', 40)", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(11), qr.InsertID)
+
+	// The checks, the failed statement inserts none of its rows.
+	assert.Equal(t, uint16(sqldb.ER_DUP_ENTRY), errNum("INSERT INTO users (name) VALUES ('frank'), ('alice')"))
+	assert.Equal(t, uint16(sqldb.ER_DUP_ENTRY), errNum("INSERT INTO users (id, name) VALUES (1, 'grace')"))
+	assert.Equal(t, uint16(sqldb.ER_BAD_NULL_ERROR), errNum("INSERT INTO users (name) VALUES (NULL)"))
+	assert.Equal(t, uint16(sqldb.ER_NO_DEFAULT_FOR_FIELD), errNum("INSERT INTO users (age) VALUES (1)"))
+	assert.Equal(t, uint16(sqldb.ER_WRONG_VALUE_COUNT_ON_ROW), errNum("INSERT INTO users (name, age) VALUES ('x')"))
+	assert.Equal(t, uint16(sqldb.ER_TRUNCATED_WRONG_VALUE_FOR_FIELD), errNum("INSERT INTO users (name, age) VALUES ('x', 'old')"))
+	assert.Equal(t, uint16(sqldb.ER_BAD_FIELD_ERROR), errNum("INSERT INTO users (nick) VALUES ('x')"))
+	assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), errNum("INSERT INTO t1 VALUES (1)"))
+	assert.Equal(t, 5, len(fetch("SELECT * FROM users")))
+
+	// The WHERE, the ORDER BY and the LIMIT.
+	assert.Equal(t, [][]string{{"1", "alice", "30", "a@x.org"}, {"2", "bob", "25", "NULL"}, {"3", "carol", "18", "NULL"}},
+		fetch("SELECT * FROM users WHERE id < 10 ORDER BY id"))
+	assert.Equal(t, [][]string{{"eve", "41"}, {"alice", "31"}},
+		fetch("SELECT name, age + 1 AS older FROM users WHERE age BETWEEN 20 AND 50 AND name != 'bob' ORDER BY older DESC"))
+	assert.Equal(t, [][]string{{"carol"}, {"dave"}},
+		fetch("select name from users where email is null or name like 'D%' and age < 0 order by 1 limit 1, 2"))
+	assert.Equal(t, [][]string{{"dave"}, {"alice"}},
+		fetch("SELECT name FROM test.users WHERE id IN (1, 10) AND NOT (age > 30) ORDER BY age, name DESC"))
+	assert.Equal(t, [][]string{{"3", "x"}}, fetch("SELECT 1 + 2, 'x'"))
+
+	// The fields.
+	qr, err = client.FetchAll("SELECT id AS uid, name, age * 2 FROM users LIMIT 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "uid", qr.Fields[0].Name)
+	assert.Equal(t, "id", qr.Fields[0].OrgName)
+	assert.Equal(t, "users", qr.Fields[0].Table)
+	assert.Equal(t, querypb.Type_INT64, qr.Fields[0].Type)
+	assert.True(t, qr.Fields[0].Flags&uint32(querypb.MySqlFlag_PRI_KEY_FLAG) != 0)
+	assert.Equal(t, querypb.Type_VARCHAR, qr.Fields[1].Type)
+	assert.Equal(t, "age * 2", qr.Fields[2].Name)
+	assert.Equal(t, querypb.Type_INT64, qr.Fields[2].Type)
+
+	// The DELETE.
+	qr, err = client.FetchAll("DELETE FROM users WHERE age < 30 ORDER BY age LIMIT 2", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), qr.RowsAffected)
+	assert.Equal(t, [][]string{{"bob"}, {"alice"}, {"eve"}}, fetch("SELECT name FROM users ORDER BY age"))
+	qr, err = client.FetchAll("DELETE FROM users", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3), qr.RowsAffected)
+	assert.Equal(t, 0, len(fetch("SELECT * FROM users")))
+
+	// The TRUNCATE restarts the auto increment ids.
+	assert.Nil(t, client.Exec("INSERT INTO users (name) VALUES ('x')"))
+	assert.Equal(t, [][]string{{"12"}}, fetch("SELECT id FROM users"))
+	assert.Nil(t, client.Exec("TRUNCATE TABLE users"))
+	qr, err = client.FetchAll("INSERT INTO users (name) VALUES ('x')", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), qr.InsertID)
+
+	// The unsupported ones.
+	assert.Equal(t, uint16(sqldb.ER_NOT_SUPPORTED_YET), errNum("SELECT COUNT(*) FROM users"))
+	assert.Equal(t, uint16(sqldb.ER_NOT_SUPPORTED_YET), errNum("UPDATE users SET age = 1"))
+	assert.Equal(t, uint16(sqldb.ER_PARSE_ERROR), errNum("SELEC 1"))
+	assert.Equal(t, uint16(sqldb.ER_BAD_FIELD_ERROR), errNum("SELECT nick FROM users"))
+
+	assert.Nil(t, client.Exec("DROP TABLE users"))
+	assert.Nil(t, client.Exec("DROP TABLE IF EXISTS users"))
+	assert.Equal(t, uint16(sqldb.ER_BAD_TABLE_ERROR), errNum("DROP TABLE users"))
+	assert.Equal(t, uint16(sqldb.ER_NO_SUCH_TABLE), errNum("SELECT * FROM users"))
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		typ  querypb.Type
+		in   sqltypes.Value
+		want string
+		ok   bool
+	}{
+		{typ: sqltypes.Int32, in: sqltypes.NewVarChar(" 42 "), want: "42", ok: true},
+		{typ: sqltypes.Int32, in: sqltypes.NewFloat64(3), want: "3", ok: true},
+		{typ: sqltypes.Int32, in: sqltypes.NewFloat64(3.5), ok: false},
+		{typ: sqltypes.Uint8, in: sqltypes.NewInt64(-1), ok: false},
+		{typ: sqltypes.Float64, in: sqltypes.NewInt64(7), want: "7", ok: true},
+		{typ: sqltypes.VarChar, in: sqltypes.NewInt64(7), want: "7", ok: true},
+		{typ: sqltypes.Int64, in: sqltypes.NULL, want: "", ok: true},
+	}
+	for _, test := range tests {
+		got, ok := convert(test.typ, test.in)
+		assert.Equalf(t, test.ok, ok, "%v", test.in)
+		if ok {
+			assert.Equal(t, test.want, got.String())
+		}
+	}
+}
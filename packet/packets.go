@@ -12,6 +12,7 @@ package packet
 import (
 	"fmt"
 	"net"
+	"sync/atomic"
 
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/XeLabs/go-mysqlstack/proto"
@@ -30,8 +31,17 @@ type Packet struct {
 }
 
 type Packets struct {
+	// The packets read and written, they are first for the 64-bit alignment of the atomics.
+	reads  uint64
+	writes uint64
+
 	seq    uint8
 	stream *Stream
+	tracer atomic.Value
+	used   uint32
+
+	// The capabilities the OK packets are packed and parsed with.
+	capability uint32
 }
 
 func NewPackets(c net.Conn) *Packets {
@@ -46,6 +56,25 @@ func (p *Packets) Next() ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	p.trace("read", pkt.SequenceID, pkt.Datas)
+	atomic.AddUint64(&p.reads, 1)
+
+	if pkt.SequenceID != p.seq {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "pkt.read.seq[%v]!=pkt.actual.seq[%v]", pkt.SequenceID, p.seq)
+	}
+	p.seq++
+	return pkt.Datas, nil
+}
+
+// NextReuse reads the next packet like the Next into the buffer of the stream,
+// the payload is only valid until the next NextReuse.
+func (p *Packets) NextReuse() ([]byte, error) {
+	pkt, err := p.stream.ReadReuse()
+	if err != nil {
+		return nil, err
+	}
+	p.trace("read", pkt.SequenceID, pkt.Datas)
+	atomic.AddUint64(&p.reads, 1)
 
 	if pkt.SequenceID != p.seq {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "pkt.read.seq[%v]!=pkt.actual.seq[%v]", pkt.SequenceID, p.seq)
@@ -70,9 +99,11 @@ func (p *Packets) Write(payload []byte) error {
 
 	// body
 	pkt.WriteBytes(payload)
+	p.trace("write", p.seq, payload)
 	if err := p.stream.Write(pkt.Datas()); err != nil {
 		return err
 	}
+	atomic.AddUint64(&p.writes, 1)
 	p.seq++
 	return nil
 }
@@ -96,32 +127,59 @@ func (p *Packets) WriteCommand(command byte, payload []byte) error {
 
 	// body
 	pkt.WriteBytes(payload)
+	p.trace("write", p.seq, pkt.Datas()[4:])
 	if err := p.stream.Write(pkt.Datas()); err != nil {
 		return err
 	}
+	atomic.AddUint64(&p.writes, 1)
 	p.seq++
 	return nil
 }
 
+// Upgrade switches the packets to the conn over the current one like the TLS conn, see Stream.Upgrade.
+// The sequence goes on.
+func (p *Packets) Upgrade(upgrade func(net.Conn) (net.Conn, error)) error {
+	return p.stream.Upgrade(upgrade)
+}
+
+// Counts returns the packets read and written, it's safe to call while the packets are in use.
+func (p *Packets) Counts() (reads, writes uint64) {
+	return atomic.LoadUint64(&p.reads), atomic.LoadUint64(&p.writes)
+}
+
 // ResetSeq reset sequence to zero.
 func (p *Packets) ResetSeq() {
 	p.seq = 0
 }
 
+// SetCapability sets the capabilities both sides negotiated, the OK packets are packed and parsed with them.
+func (p *Packets) SetCapability(capability uint32) {
+	p.capability = capability
+}
+
+// Capability returns the capabilities both sides negotiated.
+func (p *Packets) Capability() uint32 {
+	return p.capability
+}
+
 // ParseOK used to parse the OK packet.
 func (p *Packets) ParseOK(data []byte) (*proto.OK, error) {
-	return proto.UnPackOK(data)
+	return proto.UnPackOKWithCapability(data, p.capability)
 }
 
 // WriteOK writes OK packet to the wire.
 func (p *Packets) WriteOK(affectedRows, lastInsertID uint64, flags uint16, warnings uint16) error {
-	ok := &proto.OK{
+	return p.WriteOKPacket(&proto.OK{
 		AffectedRows: affectedRows,
 		LastInsertID: lastInsertID,
 		StatusFlags:  flags,
 		Warnings:     warnings,
-	}
-	return p.Write(proto.PackOK(ok))
+	})
+}
+
+// WriteOKPacket writes the OK packet with the info and the session state changes to the wire.
+func (p *Packets) WriteOKPacket(ok *proto.OK) error {
+	return p.Write(proto.PackOKWithCapability(ok, p.capability))
 }
 
 // ParseERR used to parse the ERR packet.
@@ -153,9 +211,11 @@ func (p *Packets) Append(rawdata []byte) error {
 
 	// body
 	pkt.WriteBytes(rawdata)
+	p.trace("write", p.seq, rawdata)
 	if err := p.stream.Append(pkt.Datas()); err != nil {
 		return err
 	}
+	atomic.AddUint64(&p.writes, 1)
 	p.seq++
 	return nil
 }
@@ -182,18 +242,28 @@ func (p *Packets) AppendEOF() error {
 	return p.Append([]byte{proto.EOF_PACKET})
 }
 
+// AppendEOFWithStatus appends the 4.1 EOF packet carries the status flags and the warnings.
+func (p *Packets) AppendEOFWithStatus(flags uint16, warnings uint16) error {
+	return p.Append(proto.PackEOF(&proto.EOF{Warnings: warnings, StatusFlags: flags}))
+}
+
 // AppendOKWithEOFHeader appends OK packet to the stream buffer with EOF header.
 func (p *Packets) AppendOKWithEOFHeader(affectedRows, lastInsertID uint64, flags uint16, warnings uint16) error {
-	ok := &proto.OK{
+	return p.AppendOKPacketWithEOFHeader(&proto.OK{
 		AffectedRows: affectedRows,
 		LastInsertID: lastInsertID,
 		StatusFlags:  flags,
 		Warnings:     warnings,
-	}
-	buf := common.NewBuffer(64)
-	buf.WriteU8(proto.EOF_PACKET)
-	buf.WriteBytes(proto.PackOK(ok))
-	return p.Append(buf.Datas())
+	})
+}
+
+// AppendOKPacketWithEOFHeader appends the OK packet with the info and the session state changes
+// to the stream buffer with EOF header.
+func (p *Packets) AppendOKPacketWithEOFHeader(ok *proto.OK) error {
+	// Replace the OK header with EOF header.
+	buf := proto.PackOKWithCapability(ok, p.capability)
+	buf[0] = proto.EOF_PACKET
+	return p.Append(buf)
 }
 
 // WriteColumns writes columns packet to the stream buffer.
@@ -237,6 +307,12 @@ func (p *Packets) ReadComQueryResponse() (*proto.OK, int, error, error) {
 	if data, err = p.Next(); err != nil {
 		return nil, 0, nil, err
 	}
+	// The MariaDB progress reports come first.
+	for proto.IsProgress(data) {
+		if data, err = p.Next(); err != nil {
+			return nil, 0, nil, err
+		}
+	}
 
 	ok := &proto.OK{}
 	switch data[0] {
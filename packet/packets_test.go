@@ -137,6 +137,10 @@ func TestPacketsWrite(t *testing.T) {
 		got := conn.Datas()
 		assert.Equal(t, want, got)
 	}
+
+	reads, writes := packets.Counts()
+	assert.Equal(t, uint64(0), reads)
+	assert.Equal(t, uint64(2), writes)
 }
 
 func TestPacketsWriteCommand(t *testing.T) {
@@ -341,6 +345,20 @@ func TestPacketsEOF(t *testing.T) {
 		assert.Nil(t, err)
 	}
 
+	// EOF with the status.
+	{
+		err := wPackets.AppendEOFWithStatus(3, 1)
+		assert.Nil(t, err)
+		wPackets.Flush()
+
+		data, err := rPackets.Next()
+		assert.Nil(t, err)
+		eof, err := proto.UnPackEOF(data)
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(3), eof.StatusFlags)
+		assert.Equal(t, uint16(1), eof.Warnings)
+	}
+
 	// OK with EOF header.
 	{
 		err := wPackets.AppendOKWithEOFHeader(1, 1, 1, 1)
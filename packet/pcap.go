@@ -0,0 +1,254 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package packet
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	pcapMagic        = 0xa1b2c3d4
+	pcapSnapLen      = 65535
+	pcapLinkEthernet = 1
+
+	// pcapSegmentSize bounds the payload of a TCP segment in the capture.
+	pcapSegmentSize = 32 * 1024
+
+	tcpFIN = 0x01
+	tcpSYN = 0x02
+	tcpPSH = 0x08
+	tcpACK = 0x10
+)
+
+// PcapWriter writes the traffic of the connections as the pcap file, the TCP handshake, segments and FIN
+// are synthesized from the bytes read and written so Wireshark decodes the MySQL protocol on them.
+// The bytes are what the wrapped net.Conn carries, wrap the TLS connection to capture the decrypted traffic.
+// It's safe for the concurrent connections.
+type PcapWriter struct {
+	mu  sync.Mutex
+	w   io.Writer
+	err error
+}
+
+// NewPcapWriter creates the PcapWriter and writes the pcap header to the w.
+func NewPcapWriter(w io.Writer) (*PcapWriter, error) {
+	hdr := make([]byte, 24)
+	binary.LittleEndian.PutUint32(hdr[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(hdr[4:], 2)
+	binary.LittleEndian.PutUint16(hdr[6:], 4)
+	binary.LittleEndian.PutUint32(hdr[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(hdr[20:], pcapLinkEthernet)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &PcapWriter{w: w}, nil
+}
+
+// Err returns the first error writing the capture, the capture stops at it.
+func (p *PcapWriter) Err() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.err
+}
+
+// Wrap returns the conn captured by the writer, accepted is true if the remote dialed the conn.
+func (p *PcapWriter) Wrap(conn net.Conn, accepted bool) net.Conn {
+	c := &pcapConn{Conn: conn, w: p}
+	localPort, remotePort := uint16(3306), uint16(50000)
+	if !accepted {
+		localPort, remotePort = remotePort, localPort
+	}
+	c.local = newPcapEndpoint(conn.LocalAddr(), localPort)
+	c.remote = newPcapEndpoint(conn.RemoteAddr(), remotePort)
+	c.local.seq, c.remote.seq = 1000, 2000
+
+	// The handshake, seq of the SYN takes 1.
+	client, server := c.remote, c.local
+	if !accepted {
+		client, server = c.local, c.remote
+	}
+	c.segment(client, server, tcpSYN, nil)
+	client.seq++
+	c.segment(server, client, tcpSYN|tcpACK, nil)
+	server.seq++
+	c.segment(client, server, tcpACK, nil)
+	return c
+}
+
+func (p *PcapWriter) record(frame []byte) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return
+	}
+	now := time.Now()
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint32(hdr[0:], uint32(now.Unix()))
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(now.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(hdr[8:], uint32(len(frame)))
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(len(frame)))
+	if _, p.err = p.w.Write(hdr); p.err != nil {
+		return
+	}
+	_, p.err = p.w.Write(frame)
+}
+
+// pcapEndpoint is a side of the captured connection and the next seq it sends.
+type pcapEndpoint struct {
+	ip   net.IP
+	port uint16
+	seq  uint32
+}
+
+// newPcapEndpoint creates the endpoint of the addr, the loopback and the port are used if it's not a TCP addr.
+func newPcapEndpoint(addr net.Addr, port uint16) *pcapEndpoint {
+	e := &pcapEndpoint{ip: net.IPv4(127, 0, 0, 1).To4(), port: port}
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		if ip4 := tcp.IP.To4(); ip4 != nil {
+			e.ip = ip4
+		} else if tcp.IP.To16() != nil {
+			e.ip = tcp.IP.To16()
+		}
+		e.port = uint16(tcp.Port)
+	}
+	return e
+}
+
+type pcapConn struct {
+	net.Conn
+	w      *PcapWriter
+	mu     sync.Mutex
+	local  *pcapEndpoint
+	remote *pcapEndpoint
+	closed bool
+}
+
+// Read impl, the bytes are sent by the remote.
+func (c *pcapConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.send(c.remote, c.local, b[:n])
+	}
+	return n, err
+}
+
+// Write impl, the bytes are sent by the local.
+func (c *pcapConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.send(c.local, c.remote, b[:n])
+	}
+	return n, err
+}
+
+// Close impl, the local sends the FIN.
+func (c *pcapConn) Close() error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		c.segment(c.local, c.remote, tcpFIN|tcpACK, nil)
+		c.local.seq++
+		c.segment(c.remote, c.local, tcpACK, nil)
+	}
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
+
+func (c *pcapConn) send(from, to *pcapEndpoint, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(data) > 0 {
+		n := len(data)
+		if n > pcapSegmentSize {
+			n = pcapSegmentSize
+		}
+		c.segment(from, to, tcpPSH|tcpACK, data[:n])
+		from.seq += uint32(n)
+		data = data[n:]
+	}
+}
+
+// segment records the Ethernet frame of the TCP segment from the from to the to.
+func (c *pcapConn) segment(from, to *pcapEndpoint, flags byte, payload []byte) {
+	tcp := make([]byte, 20+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], from.port)
+	binary.BigEndian.PutUint16(tcp[2:], to.port)
+	binary.BigEndian.PutUint32(tcp[4:], from.seq)
+	if flags&tcpACK > 0 {
+		binary.BigEndian.PutUint32(tcp[8:], to.seq)
+	}
+	tcp[12] = 5 << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 65535)
+	copy(tcp[20:], payload)
+
+	var ip []byte
+	var etherType uint16
+	if len(from.ip) == net.IPv4len && len(to.ip) == net.IPv4len {
+		etherType = 0x0800
+		ip = make([]byte, 20)
+		ip[0] = 0x45
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+		ip[8] = 64
+		ip[9] = 6
+		copy(ip[12:], from.ip)
+		copy(ip[16:], to.ip)
+		binary.BigEndian.PutUint16(ip[10:], checksum(0, ip))
+		binary.BigEndian.PutUint16(tcp[16:], checksum(pseudoSum(from.ip, to.ip, len(tcp)), tcp))
+	} else {
+		etherType = 0x86dd
+		ip = make([]byte, 40)
+		ip[0] = 0x60
+		binary.BigEndian.PutUint16(ip[4:], uint16(len(tcp)))
+		ip[6] = 6
+		ip[7] = 64
+		copy(ip[8:], from.ip.To16())
+		copy(ip[24:], to.ip.To16())
+		binary.BigEndian.PutUint16(tcp[16:], checksum(pseudoSum(from.ip.To16(), to.ip.To16(), len(tcp)), tcp))
+	}
+
+	// Ethernet: the locally administered MACs.
+	frame := make([]byte, 14, 14+len(ip)+len(tcp))
+	copy(frame[0:], []byte{0x02, 0, 0, 0, 0, 0x02})
+	copy(frame[6:], []byte{0x02, 0, 0, 0, 0, 0x01})
+	binary.BigEndian.PutUint16(frame[12:], etherType)
+	frame = append(frame, ip...)
+	frame = append(frame, tcp...)
+	c.w.record(frame)
+}
+
+// pseudoSum sums the pseudo header of the TCP checksum.
+func pseudoSum(src, dst net.IP, length int) uint32 {
+	var sum uint32
+	for _, ip := range []net.IP{src, dst} {
+		for i := 0; i < len(ip); i += 2 {
+			sum += uint32(ip[i])<<8 | uint32(ip[i+1])
+		}
+	}
+	return sum + 6 + uint32(length)
+}
+
+// checksum is the internet checksum of the data with the initial sum.
+func checksum(sum uint32, data []byte) uint16 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(data[i])<<8 | uint32(data[i+1])
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xffff {
+		sum = sum>>16 + sum&0xffff
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,138 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package packet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type pcapSegment struct {
+	srcPort uint16
+	dstPort uint16
+	seq     uint32
+	flags   byte
+	payload []byte
+}
+
+// readPcap reads the TCP segments of the IPv4 capture, the checksums are verified.
+func readPcap(t *testing.T, data []byte) []pcapSegment {
+	assert.Equal(t, uint32(pcapMagic), binary.LittleEndian.Uint32(data[0:]))
+	assert.Equal(t, uint32(pcapLinkEthernet), binary.LittleEndian.Uint32(data[20:]))
+	data = data[24:]
+
+	var segments []pcapSegment
+	for len(data) > 0 {
+		size := binary.LittleEndian.Uint32(data[8:])
+		frame := data[16 : 16+size]
+		data = data[16+size:]
+
+		assert.Equal(t, uint16(0x0800), binary.BigEndian.Uint16(frame[12:]))
+		ip := frame[14:34]
+		tcp := frame[34:]
+		assert.Equal(t, uint16(0), checksum(0, ip))
+		assert.Equal(t, uint16(0), checksum(pseudoSum(net.IP(ip[12:16]), net.IP(ip[16:20]), len(tcp)), tcp))
+		segments = append(segments, pcapSegment{
+			srcPort: binary.BigEndian.Uint16(tcp[0:]),
+			dstPort: binary.BigEndian.Uint16(tcp[2:]),
+			seq:     binary.BigEndian.Uint32(tcp[4:]),
+			flags:   tcp[13],
+			payload: tcp[20:],
+		})
+	}
+	return segments
+}
+
+func TestPcapWriter(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer l.Close()
+
+	out := &bytes.Buffer{}
+	w, err := NewPcapWriter(out)
+	assert.Nil(t, err)
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	assert.Nil(t, err)
+	defer client.Close()
+	accepted, err := l.Accept()
+	assert.Nil(t, err)
+	conn := w.Wrap(accepted, true)
+
+	big := strings.Repeat("x", pcapSegmentSize+10)
+	go func() {
+		client.Write([]byte("hello"))
+		io.Copy(io.Discard, client)
+	}()
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	assert.Nil(t, err)
+	_, err = conn.Write([]byte(big))
+	assert.Nil(t, err)
+	assert.Nil(t, conn.Close())
+	assert.Nil(t, w.Err())
+
+	serverPort := uint16(accepted.LocalAddr().(*net.TCPAddr).Port)
+	segments := readPcap(t, out.Bytes())
+
+	// The client SYN, the server SYN/ACK and the ACK.
+	assert.Equal(t, byte(tcpSYN), segments[0].flags)
+	assert.Equal(t, serverPort, segments[0].dstPort)
+	assert.Equal(t, byte(tcpSYN|tcpACK), segments[1].flags)
+	assert.Equal(t, serverPort, segments[1].srcPort)
+	assert.Equal(t, byte(tcpACK), segments[2].flags)
+
+	var fromClient, fromServer []byte
+	var next uint32
+	for _, s := range segments[3 : len(segments)-2] {
+		if s.dstPort == serverPort {
+			fromClient = append(fromClient, s.payload...)
+			continue
+		}
+		if next != 0 {
+			assert.Equal(t, next, s.seq)
+		}
+		next = s.seq + uint32(len(s.payload))
+		fromServer = append(fromServer, s.payload...)
+	}
+	assert.Equal(t, "hello", string(fromClient))
+	assert.Equal(t, big, string(fromServer))
+
+	// The server closes.
+	fin := segments[len(segments)-2]
+	assert.Equal(t, byte(tcpFIN|tcpACK), fin.flags)
+	assert.Equal(t, serverPort, fin.srcPort)
+	assert.Equal(t, next, fin.seq)
+}
+
+func TestPcapWriterNonTCP(t *testing.T) {
+	out := &bytes.Buffer{}
+	w, err := NewPcapWriter(out)
+	assert.Nil(t, err)
+
+	a, b := net.Pipe()
+	defer b.Close()
+	conn := w.Wrap(a, false)
+	go b.Write([]byte{0x01})
+	_, err = conn.Read(make([]byte, 1))
+	assert.Nil(t, err)
+	conn.Close()
+
+	segments := readPcap(t, out.Bytes())
+	assert.Equal(t, 6, len(segments))
+	assert.Equal(t, uint16(3306), segments[0].dstPort)
+	assert.Equal(t, []byte{0x01}, segments[3].payload)
+}
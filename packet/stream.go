@@ -25,14 +25,20 @@ const (
 )
 
 type Stream struct {
+	conn       net.Conn
 	pktMaxSize int
 	header     []byte
 	reader     *bufio.Reader
 	writer     *bufio.Writer
+
+	// The packet and the payload buffer reused by the ReadReuse.
+	reused Packet
+	buf    []byte
 }
 
 func NewStream(conn net.Conn, pktMaxSize int) *Stream {
 	return &Stream{
+		conn:       conn,
 		pktMaxSize: pktMaxSize,
 		header:     []byte{0, 0, 0, 0},
 		reader:     bufio.NewReaderSize(conn, PACKET_BUFFER_SIZE),
@@ -79,6 +85,37 @@ func (s *Stream) Read() (*Packet, error) {
 	}
 }
 
+// ReadReuse reads the next packet into the buffer of the stream instead of a fresh one,
+// the packet and its Datas are overwritten by the next ReadReuse.
+func (s *Stream) ReadReuse() (*Packet, error) {
+	s.buf = s.buf[:0]
+	for {
+		if _, err := io.ReadFull(s.reader, s.header); err != nil {
+			return nil, err
+		}
+		length := int(uint32(s.header[0]) | uint32(s.header[1])<<8 | uint32(s.header[2])<<16)
+		n := len(s.buf)
+		if cap(s.buf)-n < length {
+			buf := make([]byte, n, n+length)
+			copy(buf, s.buf)
+			s.buf = buf
+		}
+		s.buf = s.buf[:n+length]
+		if _, err := io.ReadFull(s.reader, s.buf[n:]); err != nil {
+			return nil, err
+		}
+		s.reused.SequenceID = s.header[3]
+		if length < s.pktMaxSize {
+			break
+		}
+	}
+	s.reused.Datas = s.buf
+	if len(s.buf) == 0 {
+		s.reused.Datas = nil
+	}
+	return &s.reused, nil
+}
+
 // Write writes the packet to writer
 func (s *Stream) Write(data []byte) error {
 	if err := s.Append(data); err != nil {
@@ -123,3 +160,37 @@ func (s *Stream) Append(data []byte) error {
 func (s *Stream) Flush() error {
 	return s.writer.Flush()
 }
+
+// Upgrade switches the stream to the conn the upgrade returns over the current one, like the TLS conn.
+// The bytes buffered but not read are replayed to the upgrade first, the peer may send the TLS hello
+// right after the SSLRequest.
+func (s *Stream) Upgrade(upgrade func(net.Conn) (net.Conn, error)) error {
+	conn := s.conn
+	if n := s.reader.Buffered(); n > 0 {
+		buffered, _ := s.reader.Peek(n)
+		conn = &replayConn{Conn: conn, buffered: append([]byte(nil), buffered...)}
+	}
+	upgraded, err := upgrade(conn)
+	if err != nil {
+		return err
+	}
+	s.conn = upgraded
+	s.reader.Reset(upgraded)
+	s.writer.Reset(upgraded)
+	return nil
+}
+
+// replayConn returns the buffered bytes before reading the conn.
+type replayConn struct {
+	net.Conn
+	buffered []byte
+}
+
+func (c *replayConn) Read(b []byte) (int, error) {
+	if len(c.buffered) > 0 {
+		n := copy(b, c.buffered)
+		c.buffered = c.buffered[n:]
+		return n, nil
+	}
+	return c.Conn.Read(b)
+}
@@ -10,9 +10,12 @@
 package packet
 
 import (
+	"errors"
+	"net"
+	"testing"
+
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/stretchr/testify/assert"
-	"testing"
 )
 
 // TEST EFFECTS:
@@ -213,3 +216,97 @@ func TestStreamWriteOverMax(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+// TEST EFFECTS:
+// reads the packets into the reused buffer
+//
+// TEST PROCESSES:
+// 1. write a packet over the pktMaxSize and two small ones
+// 2. read checks, the buffer grows and is reused
+func TestStreamReadReuse(t *testing.T) {
+	rBuf := NewMockConn()
+	defer rBuf.Close()
+
+	wBuf := NewMockConn()
+	defer wBuf.Close()
+
+	pktMaxSize := 63
+	rStream := NewStream(rBuf, pktMaxSize)
+	wStream := NewStream(wBuf, pktMaxSize)
+
+	payload := common.NewBuffer(PACKET_BUFFER_SIZE)
+	for i := 0; i < 100; i++ {
+		payload.WriteU8(byte(i))
+	}
+	packet := common.NewBuffer(PACKET_BUFFER_SIZE)
+	packet.WriteU24(uint32(payload.Length()))
+	packet.WriteU8(1)
+	packet.WriteBytes(payload.Datas())
+	assert.Nil(t, wStream.Write(packet.Datas()))
+	assert.Nil(t, wStream.Write([]byte{0x02, 0x00, 0x00, 0x03, 0xaa, 0xbb}))
+	assert.Nil(t, wStream.Write([]byte{0x00, 0x00, 0x00, 0x04}))
+	rBuf.Write(wBuf.Datas())
+
+	pkt, err := rStream.ReadReuse()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x02), pkt.SequenceID)
+	assert.Equal(t, payload.Datas(), pkt.Datas)
+	first := &pkt.Datas[0]
+
+	pkt, err = rStream.ReadReuse()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x03), pkt.SequenceID)
+	assert.Equal(t, []byte{0xaa, 0xbb}, pkt.Datas)
+	assert.True(t, first == &pkt.Datas[0])
+
+	pkt, err = rStream.ReadReuse()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x04), pkt.SequenceID)
+	assert.Nil(t, pkt.Datas)
+
+	_, err = rStream.ReadReuse()
+	assert.NotNil(t, err)
+}
+
+// TEST EFFECTS:
+// upgrades the conn mid-stream
+//
+// TEST PROCESSES:
+// 1. read the first packet, the second one is buffered
+// 2. upgrade the conn, the buffered bytes are replayed
+// 3. write by the upgraded conn
+func TestStreamUpgrade(t *testing.T) {
+	conn := NewMockConn()
+	defer conn.Close()
+	stream := NewStream(conn, PACKET_MAX_SIZE)
+
+	conn.Write([]byte{0x01, 0x00, 0x00, 0x01, 0xaa})
+	conn.Write([]byte{0x01, 0x00, 0x00, 0x02, 0xbb})
+	pkt, err := stream.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xaa}, pkt.Datas)
+
+	upgraded := NewMockConn()
+	err = stream.Upgrade(func(c net.Conn) (net.Conn, error) {
+		b := make([]byte, 16)
+		n, err := c.Read(b)
+		assert.Nil(t, err)
+		upgraded.Write(b[:n])
+		return upgraded, nil
+	})
+	assert.Nil(t, err)
+	pkt, err = stream.Read()
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0x02), pkt.SequenceID)
+	assert.Equal(t, []byte{0xbb}, pkt.Datas)
+
+	err = stream.Write([]byte{0x01, 0x00, 0x00, 0x03, 0xcc})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x01, 0x00, 0x00, 0x03, 0xcc}, upgraded.Datas())
+
+	// The upgrade error keeps the conn.
+	err = stream.Upgrade(func(c net.Conn) (net.Conn, error) {
+		return nil, errors.New("upgrade.failed")
+	})
+	assert.EqualError(t, err, "upgrade.failed")
+}
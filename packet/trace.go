@@ -0,0 +1,303 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package packet
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+const (
+	// DefaultTraceDumpSize is the bytes of a packet dumped by the trace.
+	DefaultTraceDumpSize = 256
+)
+
+// passwordLiteral matches the password literals of the queries, the group 1 is the literal.
+// The current password of the REPLACE and the hash of the IDENTIFIED BY PASSWORD are the credentials too.
+var passwordLiteral = regexp.MustCompile(`(?i)(?:IDENTIFIED(?:\s+WITH\s+\S+)?\s+(?:BY(?:\s+PASSWORD)?|AS)|PASSWORD\s+FOR\s+\S+\s*=|PASSWORD\s*(?:=|\()|\bREPLACE\s)\s*('(?:[^'\\]|\\.|'')*'|"(?:[^"\\]|\\.|"")*")`)
+
+// passwordStatement matches the statements which may take the passwords by the parameters.
+var passwordStatement = regexp.MustCompile(`(?i)\b(?:IDENTIFIED|PASSWORD)\b`)
+
+// tracer logs the packets with the credentials scrubbed.
+// The credentials are found by the phase of the connection instead of the sequences: the greeting(the first
+// packet of the sequence 0) tells the side of the server, the auth phase lasts from it to the first command
+// and every packet of the client in it is the auth data, except the SSLRequest and the handshake response
+// which are scrubbed after the username. The COM_CHANGE_USER starts the auth phase again.
+type tracer struct {
+	mu  sync.Mutex
+	log *xlog.Log
+	max int
+	// client is the direction of the packets of the client, empty until the greeting or a command is traced.
+	client string
+	// greeted is true once the greeting is traced or the trace is started after it.
+	greeted bool
+	// auth is true in the auth phase.
+	auth bool
+	// responded is true once the handshake response or the COM_CHANGE_USER of the auth phase is traced.
+	responded bool
+	// preparing is true from the COM_STMT_PREPARE of a password statement to its response.
+	preparing bool
+	// secrets are the ids of the prepared password statements, their parameters are scrubbed.
+	secrets map[uint32]bool
+}
+
+// The kinds of the packets deciding the scrub.
+const (
+	packetOther = iota
+	packetSSLRequest
+	packetHandshakeResponse
+	packetChangeUser
+	packetAuthData
+	packetQuery
+	packetStmtParams
+)
+
+// SetTrace logs the packets read and written to the log, a packet is dumped at most maxDump bytes.
+// The auth data, the password literals of the queries and the parameters of the prepared statements
+// setting the passwords are scrubbed, a nil log stops the trace.
+// It's safe to call while the packets are in use, the trace started after the greeting scrubs the
+// packets as the auth data until the next command.
+func (p *Packets) SetTrace(log *xlog.Log, maxDump int) {
+	if log == nil {
+		p.tracer.Store((*tracer)(nil))
+		return
+	}
+	if maxDump <= 0 {
+		maxDump = DefaultTraceDumpSize
+	}
+	greeted := atomic.LoadUint32(&p.used) == 1
+	p.tracer.Store(&tracer{log: log, max: maxDump, greeted: greeted, auth: true})
+}
+
+// Tracing returns true if the packets are traced.
+func (p *Packets) Tracing() bool {
+	t, _ := p.tracer.Load().(*tracer)
+	return t != nil
+}
+
+// trace logs the packet if the trace is on.
+func (p *Packets) trace(direction string, seq uint8, payload []byte) {
+	if atomic.LoadUint32(&p.used) == 0 {
+		atomic.StoreUint32(&p.used, 1)
+	}
+	if t, _ := p.tracer.Load().(*tracer); t != nil {
+		t.trace(direction, seq, payload)
+	}
+}
+
+func (t *tracer) trace(direction string, seq uint8, payload []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	kind, dump := t.next(direction, seq, payload)
+	more := ""
+	if len(dump) > t.max {
+		more = fmt.Sprintf("... %d more bytes", len(dump)-t.max)
+		dump = dump[:t.max]
+	}
+	first := -1
+	if len(payload) > 0 {
+		first = int(payload[0])
+	}
+	t.log.Info("packet.trace.%s.seq[%d].len[%d].cmd[0x%02x %s]:\n%s%s", direction, seq, len(payload), first, kind, hex.Dump(dump), more)
+}
+
+// next moves the phase on by the packet, returns the name of the greeting or the command and the scrubbed payload.
+func (t *tracer) next(direction string, seq uint8, payload []byte) (string, []byte) {
+	name := ""
+	if seq == 0 && len(payload) > 0 {
+		if !t.greeted {
+			t.greeted = true
+			t.client = peerDirection(direction)
+			name = "greeting"
+		} else if t.client == "" || direction == t.client {
+			t.client = direction
+			t.auth = payload[0] == sqldb.COM_CHANGE_USER
+			t.responded = false
+			name = sqldb.CommandString(payload[0])
+		}
+	}
+	return name, t.scrub(t.kind(direction, seq, payload), payload)
+}
+
+// kind returns the kind of the packet by the phase and moves the phase on, it's called once per packet.
+func (t *tracer) kind(direction string, seq uint8, payload []byte) int {
+	switch {
+	case !t.auth:
+		if seq == 0 && direction == t.client && len(payload) > 0 {
+			return t.command(payload)
+		}
+		// The COM_STMT_PREPARE_OK of the password statement: status, statement id.
+		if t.preparing && direction != t.client && seq == 1 && len(payload) >= 5 && payload[0] == 0x00 {
+			if t.secrets == nil {
+				t.secrets = make(map[uint32]bool)
+			}
+			t.secrets[binary.LittleEndian.Uint32(payload[1:])] = true
+		}
+		t.preparing = false
+		return packetOther
+	case t.client == "":
+		// The trace started after the greeting, the sides are unknown until the next command.
+		return packetAuthData
+	case direction != t.client:
+		// The greeting, the auth switch request and the OK of the server.
+		return packetOther
+	case t.responded:
+		// The auth switch response or the more auth data.
+		return packetAuthData
+	case seq == 0:
+		t.responded = true
+		return packetChangeUser
+	case proto.IsSSLRequest(payload):
+		// The handshake response follows over the TLS.
+		return packetSSLRequest
+	}
+	t.responded = true
+	return packetHandshakeResponse
+}
+
+// command returns the kind of the command and tracks the prepared password statements.
+func (t *tracer) command(payload []byte) int {
+	t.preparing = false
+	switch payload[0] {
+	case sqldb.COM_QUERY:
+		return packetQuery
+	case sqldb.COM_STMT_PREPARE:
+		t.preparing = passwordStatement.Match(payload[1:])
+		return packetQuery
+	case sqldb.COM_STMT_EXECUTE, sqldb.COM_STMT_SEND_LONG_DATA:
+		if len(payload) >= 5 && t.secrets[binary.LittleEndian.Uint32(payload[1:])] {
+			return packetStmtParams
+		}
+	case sqldb.COM_STMT_CLOSE:
+		if len(payload) >= 5 {
+			delete(t.secrets, binary.LittleEndian.Uint32(payload[1:]))
+		}
+	}
+	return packetOther
+}
+
+// scrub returns the copy of the payload with the credentials replaced by the '*'.
+func (t *tracer) scrub(kind int, payload []byte) []byte {
+	switch kind {
+	case packetChangeUser:
+		// COM_CHANGE_USER: command, user NUL, the auth data after it.
+		return redact(payload, func(buf *common.Buffer) (int, int, error) {
+			if _, err := buf.ReadU8(); err != nil {
+				return 0, 0, err
+			}
+			return lenAuthData(buf, sqldb.CLIENT_SECURE_CONNECTION)
+		})
+	case packetAuthData:
+		return redact(payload, func(buf *common.Buffer) (int, int, error) { return 0, len(payload), nil })
+	case packetHandshakeResponse:
+		// The handshake response: capabilities, max packet, charset, filler, user NUL, the auth data after it.
+		return redact(payload, func(buf *common.Buffer) (int, int, error) {
+			capabilities, err := buf.ReadU32()
+			if err != nil {
+				return 0, 0, err
+			}
+			if err := buf.ReadZero(4 + 1 + 23); err != nil {
+				return 0, 0, err
+			}
+			return lenAuthData(buf, capabilities)
+		})
+	case packetStmtParams:
+		// COM_STMT_EXECUTE: command, statement id, flags, iteration count, the parameters after them.
+		// COM_STMT_SEND_LONG_DATA: command, statement id, parameter id, the data after them.
+		skip := 1 + 4 + 1 + 4
+		if payload[0] == sqldb.COM_STMT_SEND_LONG_DATA {
+			skip = 1 + 4 + 2
+		}
+		return redact(payload, func(buf *common.Buffer) (int, int, error) {
+			if err := buf.ReadZero(skip); err != nil {
+				return 0, 0, err
+			}
+			return buf.Seek(), len(payload), nil
+		})
+	case packetQuery:
+		dump := payload
+		for i, m := range passwordLiteral.FindAllSubmatchIndex(payload, -1) {
+			if i == 0 {
+				dump = append([]byte{}, payload...)
+			}
+			for j := m[2] + 1; j < m[3]-1; j++ {
+				dump[j] = '*'
+			}
+		}
+		return dump
+	}
+	return payload
+}
+
+// peerDirection returns the direction of the packets of the other side.
+func peerDirection(direction string) string {
+	if direction == "read" {
+		return "write"
+	}
+	return "read"
+}
+
+// lenAuthData skips the user NUL and returns the range of the auth data after it.
+func lenAuthData(buf *common.Buffer, capabilities uint32) (int, int, error) {
+	if _, err := buf.ReadBytesNUL(); err != nil {
+		return 0, 0, err
+	}
+	start := buf.Seek()
+	switch {
+	case capabilities&sqldb.CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA > 0:
+		n, err := buf.ReadLenEncode()
+		if err != nil {
+			return 0, 0, err
+		}
+		return buf.Seek(), buf.Seek() + int(n), nil
+	case capabilities&sqldb.CLIENT_SECURE_CONNECTION > 0:
+		n, err := buf.ReadU8()
+		if err != nil {
+			return 0, 0, err
+		}
+		return buf.Seek(), buf.Seek() + int(n), nil
+	}
+	if _, err := buf.ReadBytesNUL(); err != nil {
+		return 0, 0, err
+	}
+	return start, buf.Seek(), nil
+}
+
+// redact returns the copy of the payload with the bytes of the span replaced,
+// the payload after the bytes parsed is replaced if the span is malformed.
+func redact(payload []byte, span func(buf *common.Buffer) (int, int, error)) []byte {
+	dump := append([]byte{}, payload...)
+	buf := common.ReadBuffer(payload)
+	start, end, err := span(buf)
+	if err != nil {
+		start, end = buf.Seek(), len(dump)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(dump) || end < start {
+		end = len(dump)
+	}
+	for i := start; i < end; i++ {
+		dump[i] = '*'
+	}
+	return dump
+}
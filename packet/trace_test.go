@@ -0,0 +1,210 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package packet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPacketsTrace(t *testing.T) {
+	conn := NewMockConn()
+	defer conn.Close()
+	out := &bytes.Buffer{}
+	log := xlog.NewXLog(out, xlog.Level(xlog.INFO))
+
+	packets := NewPackets(conn)
+	assert.False(t, packets.Tracing())
+	packets.SetTrace(log, 64)
+	assert.True(t, packets.Tracing())
+
+	// The greeting is read and the handshake response is written.
+	greeting := proto.NewGreeting(1)
+	pkt := greeting.Pack()
+	conn.Write(append([]byte{byte(len(pkt)), byte(len(pkt) >> 8), byte(len(pkt) >> 16), 0}, pkt...))
+	_, err := packets.Next()
+	assert.Nil(t, err)
+	assert.Contains(t, out.String(), "packet.trace.read.seq[0]")
+	assert.Contains(t, out.String(), "greeting")
+	assert.Contains(t, out.String(), "more bytes")
+
+	out.Reset()
+	payload := proto.NewAuth().Pack(proto.DefaultClientCapability, sqldb.DefaultCollation, "mock", "secret", greeting.Salt, "")
+	assert.Nil(t, packets.Write(payload))
+	assert.Contains(t, out.String(), "packet.trace.write.seq[1]")
+	assert.Contains(t, out.String(), "|mock..**********|")
+
+	// The command ends the auth phase.
+	out.Reset()
+	packets.SetTrace(log, 1024)
+	assert.Nil(t, packets.WriteCommand(sqldb.COM_QUERY, []byte("SET PASSWORD = 'secret'")))
+	assert.Contains(t, out.String(), "COM_QUERY")
+	assert.Contains(t, out.String(), "'******'")
+	assert.NotContains(t, out.String(), "secret")
+
+	packets.SetTrace(nil, 0)
+	assert.False(t, packets.Tracing())
+	out.Reset()
+	assert.Nil(t, packets.WriteCommand(sqldb.COM_PING, nil))
+	assert.Equal(t, "", out.String())
+}
+
+func TestPacketsTraceScrub(t *testing.T) {
+	greeting := proto.NewGreeting(1)
+	scramble := proto.NativePassword("secret", greeting.Salt)
+	ok := proto.PackOK(&proto.OK{})
+	stars := bytes.Repeat([]byte("*"), len(scramble))
+	scrub := func(tr *tracer, direction string, seq uint8, payload []byte) []byte {
+		_, dump := tr.next(direction, seq, payload)
+		return dump
+	}
+
+	// Handshake response.
+	for _, capability := range []uint32{proto.DefaultClientCapability, proto.DefaultClientCapability &^ sqldb.CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA} {
+		tr := &tracer{auth: true}
+		assert.Equal(t, greeting.Pack(), scrub(tr, "write", 0, greeting.Pack()))
+		payload := proto.NewAuth().Pack(capability, sqldb.DefaultCollation, "mock", "secret", greeting.Salt, "db1")
+		dump := scrub(tr, "read", 1, payload)
+		assert.Equal(t, len(payload), len(dump))
+		assert.True(t, bytes.Contains(payload, scramble))
+		assert.False(t, bytes.Contains(dump, scramble))
+		assert.True(t, bytes.Contains(dump, []byte("mock\x00")))
+		assert.True(t, bytes.Contains(dump, stars))
+		assert.True(t, bytes.Contains(dump, []byte("db1")))
+
+		// The auth switch request of the server is kept, the response is scrubbed.
+		req := (&proto.AuthSwitchRequest{PluginName: proto.DefaultAuthPluginName, AuthData: greeting.Salt}).Pack()
+		assert.Equal(t, req, scrub(tr, "write", 2, req))
+		assert.Equal(t, stars, scrub(tr, "read", 3, scramble))
+		assert.Equal(t, ok, scrub(tr, "write", 4, ok))
+	}
+
+	// The malformed handshake response is scrubbed after the bytes parsed.
+	{
+		tr := &tracer{auth: true}
+		scrub(tr, "read", 0, greeting.Pack())
+		assert.Equal(t, []byte("**"), scrub(tr, "write", 1, []byte{0xff, 0xff}))
+	}
+
+	// The handshake response over the TLS follows the SSLRequest, the auth data of the client are on the even sequences.
+	{
+		tr := &tracer{auth: true}
+		scrub(tr, "write", 0, greeting.Pack())
+		ssl := proto.NewAuth().PackSSLRequest(proto.DefaultClientCapability|sqldb.CLIENT_SSL, sqldb.DefaultCollation)
+		assert.Equal(t, ssl, scrub(tr, "read", 1, ssl))
+		payload := proto.NewAuth().Pack(proto.DefaultClientCapability|sqldb.CLIENT_SSL, sqldb.DefaultCollation, "mock", "secret", greeting.Salt, "db1")
+		dump := scrub(tr, "read", 2, payload)
+		assert.False(t, bytes.Contains(dump, scramble))
+		assert.True(t, bytes.Contains(dump, []byte("mock\x00")))
+		req := (&proto.AuthSwitchRequest{PluginName: proto.DefaultAuthPluginName, AuthData: greeting.Salt}).Pack()
+		assert.Equal(t, req, scrub(tr, "write", 3, req))
+		assert.Equal(t, stars, scrub(tr, "read", 4, scramble))
+		assert.Equal(t, []byte("*****"), scrub(tr, "read", 6, []byte("clear")))
+		assert.Equal(t, ok, scrub(tr, "write", 7, ok))
+
+		// The command ends the auth phase.
+		query := append([]byte{sqldb.COM_QUERY}, "SELECT 1"...)
+		name, dump := tr.next("read", 0, query)
+		assert.Equal(t, "COM_QUERY", name)
+		assert.Equal(t, query, dump)
+	}
+
+	// COM_CHANGE_USER.
+	{
+		tr := &tracer{greeted: true, client: "write"}
+		payload := append([]byte{sqldb.COM_CHANGE_USER}, "mock\x00"...)
+		payload = append(payload, byte(len(scramble)))
+		payload = append(payload, scramble...)
+		payload = append(payload, "db1\x00"...)
+		dump := scrub(tr, "write", 0, payload)
+		assert.True(t, tr.auth)
+		assert.False(t, bytes.Contains(dump, scramble))
+		assert.True(t, bytes.Contains(dump, []byte("db1")))
+		assert.Equal(t, []byte{0xfe}, scrub(tr, "read", 1, []byte{0xfe}))
+		assert.Equal(t, stars, scrub(tr, "write", 2, scramble))
+		assert.Equal(t, stars, scrub(tr, "write", 5, scramble))
+		assert.Equal(t, ok, scrub(tr, "read", 6, ok))
+	}
+
+	// The password literals of the queries.
+	{
+		tr := &tracer{greeted: true, client: "write"}
+		queries := []struct {
+			query string
+			want  string
+		}{
+			{"CREATE USER 'u'@'%' IDENTIFIED BY 'p@ss'", "CREATE USER 'u'@'%' IDENTIFIED BY '****'"},
+			{"ALTER USER u IDENTIFIED WITH mysql_native_password BY \"p'x\"", "ALTER USER u IDENTIFIED WITH mysql_native_password BY \"***\""},
+			{"SET PASSWORD FOR 'u'@'h' = 'a\\'b'", "SET PASSWORD FOR 'u'@'h' = '****'"},
+			{"set password = password('abc')", "set password = password('***')"},
+			{"SELECT 'password'", "SELECT 'password'"},
+			{"ALTER USER u IDENTIFIED BY 'new' REPLACE 'old'", "ALTER USER u IDENTIFIED BY '***' REPLACE '***'"},
+			{"SET PASSWORD = 'new' REPLACE 'old'", "SET PASSWORD = '***' REPLACE '***'"},
+			{"CREATE USER u IDENTIFIED BY PASSWORD '*2470C0C06DEE42FD1618BB99005ADCA2EC9D1E19'", "CREATE USER u IDENTIFIED BY PASSWORD '*****************************************'"},
+			{"SELECT REPLACE('a', 'b', 'c')", "SELECT REPLACE('a', 'b', 'c')"},
+		}
+		for _, q := range queries {
+			payload := append([]byte{sqldb.COM_QUERY}, q.query...)
+			dump := scrub(tr, "write", 0, payload)
+			assert.Equal(t, q.want, string(dump[1:]))
+			assert.Equal(t, q.query, string(payload[1:]))
+		}
+	}
+
+	// The parameters of the prepared password statements.
+	{
+		tr := &tracer{greeted: true, client: "write"}
+		prepareOK := func(id byte) []byte {
+			return []byte{0x00, id, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0}
+		}
+		execute := func(id byte) []byte {
+			return append([]byte{sqldb.COM_STMT_EXECUTE, id, 0, 0, 0, 0, 1, 0, 0, 0, 0x00, 0x01, 0xfe, 0x00, 6}, "secret"...)
+		}
+		scrub(tr, "write", 0, append([]byte{sqldb.COM_STMT_PREPARE}, "ALTER USER u IDENTIFIED BY ?"...))
+		scrub(tr, "read", 1, prepareOK(1))
+		scrub(tr, "write", 0, append([]byte{sqldb.COM_STMT_PREPARE}, "SELECT ?"...))
+		scrub(tr, "read", 1, prepareOK(2))
+
+		dump := scrub(tr, "write", 0, execute(1))
+		assert.Equal(t, execute(1)[:10], dump[:10])
+		assert.False(t, bytes.Contains(dump, []byte("secret")))
+		longData := append([]byte{sqldb.COM_STMT_SEND_LONG_DATA, 1, 0, 0, 0, 0, 0}, "secret"...)
+		assert.Equal(t, append(longData[:7:7], "******"...), scrub(tr, "write", 0, longData))
+		assert.Equal(t, execute(2), scrub(tr, "write", 0, execute(2)))
+
+		// The closed statement id is forgotten.
+		scrub(tr, "write", 0, []byte{sqldb.COM_STMT_CLOSE, 1, 0, 0, 0})
+		assert.Equal(t, execute(1), scrub(tr, "write", 0, execute(1)))
+	}
+}
+
+func TestPacketsTraceStartedAfterGreeting(t *testing.T) {
+	conn := NewMockConn()
+	defer conn.Close()
+	out := &bytes.Buffer{}
+	log := xlog.NewXLog(out, xlog.Level(xlog.INFO))
+
+	packets := NewPackets(conn)
+	assert.Nil(t, packets.Write([]byte{0x0a}))
+
+	// The packets are scrubbed as the auth data until the next command.
+	packets.SetTrace(log, 1024)
+	assert.Nil(t, packets.Write([]byte("secret-auth-data")))
+	assert.Contains(t, out.String(), "packet.trace.write.seq[1]")
+	assert.False(t, strings.Contains(out.String(), "auth-data"))
+	assert.Nil(t, packets.WriteCommand(sqldb.COM_QUERY, []byte("SELECT 1")))
+	assert.Contains(t, out.String(), "SELECT 1")
+}
@@ -12,6 +12,7 @@ package proto
 import (
 	"crypto/sha1"
 	"fmt"
+	"sort"
 
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
@@ -22,10 +23,12 @@ type Auth struct {
 	maxPacketSize   uint32
 	authResponseLen uint8
 	clientFlags     uint32
+	mariadbFlags    uint32
 	authResponse    []byte
 	pluginName      string
 	database        string
 	user            string
+	connectAttrs    map[string]string
 }
 
 func NewAuth() *Auth {
@@ -40,6 +43,17 @@ func (a *Auth) ClientFlags() uint32 {
 	return a.clientFlags
 }
 
+// MariaDBClientFlags returns the MariaDB extended capabilities sent by the client,
+// only valid if the CLIENT_LONG_PASSWORD(CLIENT_MYSQL) is off.
+func (a *Auth) MariaDBClientFlags() uint32 {
+	return a.mariadbFlags
+}
+
+// SetMariaDBClientFlags sets the MariaDB extended capabilities which will be packed.
+func (a *Auth) SetMariaDBClientFlags(flags uint32) {
+	a.mariadbFlags = flags
+}
+
 func (a *Auth) Charset() uint8 {
 	return a.charset
 }
@@ -52,6 +66,28 @@ func (a *Auth) AuthResponse() []byte {
 	return a.authResponse
 }
 
+// PluginName returns the auth plugin of the response, the pre-plugin clients are guessed by the scramble.
+func (a *Auth) PluginName() string {
+	return a.pluginName
+}
+
+// SwitchAuthResponse replaces the response by the one answered to the auth switch request.
+func (a *Auth) SwitchAuthResponse(pluginName string, response []byte) {
+	a.pluginName = pluginName
+	a.authResponse = response
+	a.authResponseLen = uint8(len(response))
+}
+
+// ConnectAttrs returns the connection attributes sent by the client.
+func (a *Auth) ConnectAttrs() map[string]string {
+	return a.connectAttrs
+}
+
+// SetConnectAttrs sets the connection attributes which will be packed.
+func (a *Auth) SetConnectAttrs(attrs map[string]string) {
+	a.connectAttrs = attrs
+}
+
 // To imporve the heap gc cost.
 func (a *Auth) CleanAuthResponse() {
 	a.authResponse = nil
@@ -66,8 +102,8 @@ func (a *Auth) UnPack(payload []byte) error {
 	if a.clientFlags, err = buf.ReadU32(); err != nil {
 		return fmt.Errorf("auth.unpack: can't read client flags")
 	}
-	if a.clientFlags&sqldb.CLIENT_PROTOCOL_41 == 0 {
-		return fmt.Errorf("auth.unpack: only support protocol 4.1")
+	if err = CheckClientCapability(a.clientFlags, RequiredClientCapability); err != nil {
+		return err
 	}
 	if a.maxPacketSize, err = buf.ReadU32(); err != nil {
 		return fmt.Errorf("auth.unpack: can't read maxPacketSize")
@@ -75,9 +111,17 @@ func (a *Auth) UnPack(payload []byte) error {
 	if a.charset, err = buf.ReadU8(); err != nil {
 		return fmt.Errorf("auth.unpack: can't read charset")
 	}
-	if err = buf.ReadZero(23); err != nil {
+	// MariaDB: string[19] filler, 4 extended capabilities if CLIENT_MYSQL off.
+	if err = buf.ReadZero(19); err != nil {
 		return fmt.Errorf("auth.unpack: can't read 23zeros")
 	}
+	var extended uint32
+	if extended, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("auth.unpack: can't read 23zeros")
+	}
+	if a.clientFlags&sqldb.CLIENT_LONG_PASSWORD == 0 {
+		a.mariadbFlags = extended
+	}
 	if a.user, err = buf.ReadStringNUL(); err != nil {
 		return fmt.Errorf("auth.unpack: can't read user")
 	}
@@ -103,8 +147,44 @@ func (a *Auth) UnPack(payload []byte) error {
 			return fmt.Errorf("auth.unpack: can't read pluginName")
 		}
 	}
-	if a.pluginName != DefaultAuthPluginName {
-		return fmt.Errorf("invalid authPluginName, got %v but only support %v", a.pluginName, DefaultAuthPluginName)
+	// The client without the CLIENT_PLUGIN_AUTH: the 4.1 scramble is 20 bytes, the 323 one is 8 bytes.
+	// The empty scramble is the empty password of any of them.
+	if a.pluginName == "" {
+		if len(a.authResponse) == 0 || len(a.authResponse) == 20 {
+			a.pluginName = DefaultAuthPluginName
+		} else {
+			a.pluginName = OldAuthPluginName
+		}
+	}
+	if (a.clientFlags & sqldb.CLIENT_CONNECT_ATTRS) > 0 {
+		if err = a.unpackConnectAttrs(buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Auth) unpackConnectAttrs(buf *common.Buffer) error {
+	var err error
+	var total uint64
+	var key, value string
+
+	if total, err = buf.ReadLenEncode(); err != nil {
+		return fmt.Errorf("auth.unpack: can't read connect attrs length")
+	}
+	end := buf.Seek() + int(total)
+	if end > buf.Length() {
+		return fmt.Errorf("auth.unpack: connect attrs length overflow")
+	}
+	a.connectAttrs = make(map[string]string)
+	for buf.Seek() < end {
+		if key, err = buf.ReadLenEncodeString(); err != nil {
+			return fmt.Errorf("auth.unpack: can't read connect attr key")
+		}
+		if value, err = buf.ReadLenEncodeString(); err != nil {
+			return fmt.Errorf("auth.unpack: can't read connect attr value")
+		}
+		a.connectAttrs[key] = value
 	}
 	return nil
 }
@@ -125,6 +205,11 @@ func (a *Auth) Pack(
 	} else {
 		capabilityFlags &= ^sqldb.CLIENT_CONNECT_WITH_DB
 	}
+	if len(a.connectAttrs) > 0 {
+		capabilityFlags |= sqldb.CLIENT_CONNECT_ATTRS
+	} else {
+		capabilityFlags &= ^sqldb.CLIENT_CONNECT_ATTRS
+	}
 
 	// 4 capability flags, CLIENT_PROTOCOL_41 always set
 	buf.WriteU32(capabilityFlags)
@@ -136,7 +221,13 @@ func (a *Auth) Pack(
 	buf.WriteU8(charset)
 
 	// string[23] reserved (all [0])
-	buf.WriteZero(23)
+	// MariaDB: string[19] filler, 4 extended capabilities if CLIENT_MYSQL off.
+	if capabilityFlags&sqldb.CLIENT_LONG_PASSWORD == 0 {
+		buf.WriteZero(19)
+		buf.WriteU32(a.mariadbFlags)
+	} else {
+		buf.WriteZero(23)
+	}
 
 	// string[NUL] username
 	buf.WriteString(username)
@@ -163,11 +254,60 @@ func (a *Auth) Pack(
 	buf.WriteString(DefaultAuthPluginName)
 	buf.WriteZero(1)
 
-	// CLIENT_CONNECT_ATTRS none
-	//
+	// lenenc-int length of all key-values
+	// lenenc-str key
+	// lenenc-str value
+	if capabilityFlags&sqldb.CLIENT_CONNECT_ATTRS > 0 {
+		keys := make([]string, 0, len(a.connectAttrs))
+		for k := range a.connectAttrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		attrs := common.NewBuffer(256)
+		for _, k := range keys {
+			attrs.WriteLenEncodeString(k)
+			attrs.WriteLenEncodeString(a.connectAttrs[k])
+		}
+		buf.WriteLenEncode(uint64(attrs.Length()))
+		buf.WriteBytes(attrs.Datas())
+	}
+	return buf.Datas()
+}
+
+// SSLRequestLength is the length of the SSLRequest, the handshake response truncated before the username.
+const SSLRequestLength = 32
+
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::SSLRequest
+// PackSSLRequest packs the SSLRequest, the client switches to TLS after it and sends the handshake response.
+func (a *Auth) PackSSLRequest(capabilityFlags uint32, charset uint8) []byte {
+	buf := common.NewBuffer(SSLRequestLength)
+	buf.WriteU32(capabilityFlags | sqldb.CLIENT_SSL)
+	buf.WriteU32(0)
+	buf.WriteU8(charset)
+	if capabilityFlags&sqldb.CLIENT_LONG_PASSWORD == 0 {
+		buf.WriteZero(19)
+		buf.WriteU32(a.mariadbFlags)
+	} else {
+		buf.WriteZero(23)
+	}
 	return buf.Datas()
 }
 
+// IsSSLRequest checks whether the payload is the SSLRequest instead of the handshake response.
+func IsSSLRequest(payload []byte) bool {
+	if len(payload) != SSLRequestLength {
+		return false
+	}
+	flags := uint32(payload[0]) | uint32(payload[1])<<8 | uint32(payload[2])<<16 | uint32(payload[3])<<24
+	return flags&sqldb.CLIENT_SSL > 0
+}
+
+// NativePassword returns the mysql_native_password scramble of the password with the salt,
+// it's shared by the X Protocol MYSQL41 authentication.
+func NativePassword(password string, salt []byte) []byte {
+	return nativePassword(password, salt)
+}
+
 // https://dev.mysql.com/doc/internals/en/secure-password-authentication.html#packet-Authentication::Native41
 // SHA1( password ) XOR SHA1( "20-bytes random data from server" <concat> SHA1( SHA1( password ) ) )
 // Encrypt password using 4.1+ method
@@ -261,3 +261,77 @@ func TestAuthUnPackError(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestAuthWithConnectAttrs(t *testing.T) {
+	attrs := map[string]string{
+		"_client_name": "go-mysqlstack",
+		"program_name": "mock",
+		"tag":          "",
+	}
+
+	want := NewAuth()
+	want.charset = 0x02
+	want.authResponseLen = 20
+	want.clientFlags = DefaultClientCapability | sqldb.CLIENT_CONNECT_ATTRS
+	want.clientFlags |= sqldb.CLIENT_CONNECT_WITH_DB
+	want.authResponse = nativePassword("sbtest", DefaultSalt)
+	want.database = "sbtest"
+	want.user = "sbtest"
+	want.pluginName = DefaultAuthPluginName
+	want.SetConnectAttrs(attrs)
+
+	got := NewAuth()
+	data := want.Pack(
+		DefaultClientCapability,
+		0x02,
+		"sbtest",
+		"sbtest",
+		DefaultSalt,
+		"sbtest",
+	)
+	err := got.UnPack(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, attrs, got.ConnectAttrs())
+
+	// Truncated attrs.
+	{
+		got := NewAuth()
+		err := got.UnPack(data[:len(data)-3])
+		assert.NotNil(t, err)
+	}
+}
+
+func TestAuthMariaDBFlags(t *testing.T) {
+	want := NewAuth()
+	want.SetMariaDBClientFlags(sqldb.MARIADB_CLIENT_PROGRESS)
+
+	got := NewAuth()
+	flags := DefaultClientCapability &^ sqldb.CLIENT_LONG_PASSWORD
+	err := got.UnPack(want.Pack(flags, 0x02, "sbtest", "sbtest", DefaultSalt, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, sqldb.MARIADB_CLIENT_PROGRESS, got.MariaDBClientFlags())
+
+	got = NewAuth()
+	err = got.UnPack(want.Pack(DefaultClientCapability, 0x02, "sbtest", "sbtest", DefaultSalt, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), got.MariaDBClientFlags())
+}
+
+func TestAuthSSLRequest(t *testing.T) {
+	auth := NewAuth()
+	data := auth.PackSSLRequest(DefaultClientCapability, 0x21)
+	assert.Equal(t, SSLRequestLength, len(data))
+	assert.True(t, IsSSLRequest(data))
+
+	// The handshake response isn't.
+	assert.False(t, IsSSLRequest(auth.Pack(DefaultClientCapability|sqldb.CLIENT_SSL, 0x21, "sbtest", "sbtest", DefaultSalt, "")))
+	assert.False(t, IsSSLRequest(data[:SSLRequestLength-1]))
+
+	// The flags and the charset are the ones of the handshake response.
+	buf := common.ReadBuffer(data)
+	flags, err := buf.ReadU32()
+	assert.Nil(t, err)
+	assert.Equal(t, DefaultClientCapability|sqldb.CLIENT_SSL, flags)
+	assert.Equal(t, uint8(0x21), data[8])
+}
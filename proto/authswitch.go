@@ -0,0 +1,138 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// AUTH_SWITCH_PACKET is the header of the auth switch request, same as the EOF.
+	AUTH_SWITCH_PACKET byte = 0xfe
+)
+
+// AuthSwitchRequest is the request to answer the auth by another plugin.
+// The old servers send the header only to ask the 323 scramble of the greeting salt.
+type AuthSwitchRequest struct {
+	PluginName string
+	AuthData   []byte
+}
+
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::AuthSwitchRequest
+// UnPackAuthSwitchRequest parses the request, the AuthData is nil for the old one.
+func UnPackAuthSwitchRequest(payload []byte) (*AuthSwitchRequest, error) {
+	var err error
+	buf := common.ReadBuffer(payload)
+	r := &AuthSwitchRequest{}
+
+	var header byte
+	if header, err = buf.ReadU8(); err != nil || header != AUTH_SWITCH_PACKET {
+		return nil, fmt.Errorf("auth.switch.unpack: invalid header:%v", payload)
+	}
+	// Protocol::OldAuthSwitchRequest.
+	if buf.Length() == 1 {
+		r.PluginName = OldAuthPluginName
+		return r, nil
+	}
+	if r.PluginName, err = buf.ReadStringNUL(); err != nil {
+		return nil, fmt.Errorf("auth.switch.unpack: can't read plugin name")
+	}
+	// The data is NUL terminated by the native and old plugins.
+	r.AuthData = bytes.TrimRight(payload[buf.Seek():], "\x00")
+	return r, nil
+}
+
+// Pack packs the request, the data is NUL terminated.
+func (r *AuthSwitchRequest) Pack() []byte {
+	buf := common.NewBuffer(64)
+	buf.WriteU8(AUTH_SWITCH_PACKET)
+	buf.WriteString(r.PluginName)
+	buf.WriteZero(1)
+	buf.WriteBytes(r.AuthData)
+	buf.WriteZero(1)
+	return buf.Datas()
+}
+
+// Response returns the scramble of the password answered to the request, the salt is the one
+// of the greeting if the request carries none.
+func (r *AuthSwitchRequest) Response(password string, salt []byte) ([]byte, error) {
+	if len(r.AuthData) > 0 {
+		salt = r.AuthData
+	}
+	switch r.PluginName {
+	case DefaultAuthPluginName:
+		return nativePassword(password, salt), nil
+	case OldAuthPluginName:
+		return append(oldPassword(password, salt), 0x00), nil
+	}
+	return nil, sqldb.NewSQLError(sqldb.CR_AUTH_PLUGIN_CANNOT_LOAD, "Authentication plugin '%s' cannot be loaded", r.PluginName)
+}
+
+// oldRand is the pseudo random generator of the 323 scramble.
+type oldRand struct {
+	seed1 uint32
+	seed2 uint32
+}
+
+const oldRandMax = 0x3fffffff
+
+func (r *oldRand) next() byte {
+	r.seed1 = (r.seed1*3 + r.seed2) % oldRandMax
+	r.seed2 = (r.seed1 + r.seed2 + 33) % oldRandMax
+	return byte(uint64(r.seed1) * 31 / oldRandMax)
+}
+
+// oldHash is the hash_password of the 323 scramble, the spaces and tabs are skipped.
+func oldHash(data []byte) [2]uint32 {
+	var add uint32 = 7
+	nr := uint32(1345345333)
+	nr2 := uint32(0x12345671)
+	for _, c := range data {
+		if c == ' ' || c == '\t' {
+			continue
+		}
+		tmp := uint32(c)
+		nr ^= (((nr & 63) + add) * tmp) + (nr << 8)
+		nr2 += (nr2 << 8) ^ nr
+		add += tmp
+	}
+	return [2]uint32{nr & (1<<31 - 1), nr2 & (1<<31 - 1)}
+}
+
+// oldPassword returns the pre-4.1 scramble of the password with the first 8 bytes of the salt.
+// https://dev.mysql.com/doc/internals/en/old-password-authentication.html
+func oldPassword(password string, salt []byte) []byte {
+	if len(password) == 0 {
+		return nil
+	}
+	if len(salt) > 8 {
+		salt = salt[:8]
+	}
+
+	hashPassword := oldHash([]byte(password))
+	hashSalt := oldHash(salt)
+	r := &oldRand{
+		seed1: (hashPassword[0] ^ hashSalt[0]) % oldRandMax,
+		seed2: (hashPassword[1] ^ hashSalt[1]) % oldRandMax,
+	}
+	scramble := make([]byte, 8)
+	for i := range scramble {
+		scramble[i] = r.next() + 64
+	}
+	extra := r.next()
+	for i := range scramble {
+		scramble[i] ^= extra
+	}
+	return scramble
+}
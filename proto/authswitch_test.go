@@ -0,0 +1,115 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthSwitchRequest(t *testing.T) {
+	want := &AuthSwitchRequest{PluginName: DefaultAuthPluginName, AuthData: DefaultSalt}
+	got, err := UnPackAuthSwitchRequest(want.Pack())
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	resp, err := got.Response("sbtest", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, nativePassword("sbtest", DefaultSalt), resp)
+
+	// The old request uses the greeting salt.
+	got, err = UnPackAuthSwitchRequest([]byte{AUTH_SWITCH_PACKET})
+	assert.Nil(t, err)
+	assert.Equal(t, OldAuthPluginName, got.PluginName)
+	assert.Nil(t, got.AuthData)
+	resp, err = got.Response("sbtest", DefaultSalt)
+	assert.Nil(t, err)
+	assert.Equal(t, append(oldPassword("sbtest", DefaultSalt), 0x00), resp)
+	resp, err = got.Response("", DefaultSalt)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00}, resp)
+
+	// Unsupported plugin.
+	_, err = (&AuthSwitchRequest{PluginName: "caching_sha2_password"}).Response("sbtest", DefaultSalt)
+	assert.Equal(t, uint16(sqldb.CR_AUTH_PLUGIN_CANNOT_LOAD), err.(*sqldb.SQLError).Num)
+
+	// Bad packets.
+	_, err = UnPackAuthSwitchRequest([]byte{0x00})
+	assert.NotNil(t, err)
+	_, err = UnPackAuthSwitchRequest([]byte{AUTH_SWITCH_PACKET, 'a', 'b'})
+	assert.NotNil(t, err)
+}
+
+func TestOldPassword(t *testing.T) {
+	salt := []byte{9, 8, 7, 6, 5, 4, 3, 2}
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{" pass", "47575c5a435b4251"},
+		{"pass ", "47575c5a435b4251"},
+		{"123\t456", "575c47505b5b5559"},
+		{"C0mpl!ca ted#PASS123", "5d5d554849584a45"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, hex.EncodeToString(oldPassword(test.password, salt)), test.password)
+	}
+	// Only the first 8 bytes of the salt are used.
+	assert.Equal(t, oldPassword("sbtest", DefaultSalt[:8]), oldPassword("sbtest", DefaultSalt))
+	assert.Nil(t, oldPassword("", salt))
+}
+
+func TestAuthPluginGuess(t *testing.T) {
+	pack := func(flags uint32, response []byte) []byte {
+		buf := common.NewBuffer(64)
+		buf.WriteU32(flags)
+		buf.WriteU32(0)
+		buf.WriteU8(0x21)
+		buf.WriteZero(23)
+		buf.WriteString("sbtest")
+		buf.WriteZero(1)
+		if flags&sqldb.CLIENT_SECURE_CONNECTION > 0 {
+			buf.WriteU8(uint8(len(response)))
+			buf.WriteBytes(response)
+		} else {
+			buf.WriteBytes(response)
+			buf.WriteZero(1)
+		}
+		return buf.Datas()
+	}
+
+	flags := uint32(sqldb.CLIENT_PROTOCOL_41 | sqldb.CLIENT_LONG_PASSWORD)
+	tests := []struct {
+		flags    uint32
+		response []byte
+		want     string
+	}{
+		{flags, oldPassword("sbtest", DefaultSalt), OldAuthPluginName},
+		{flags, nil, DefaultAuthPluginName},
+		{flags | sqldb.CLIENT_SECURE_CONNECTION, nativePassword("sbtest", DefaultSalt), DefaultAuthPluginName},
+	}
+	for _, test := range tests {
+		auth := NewAuth()
+		assert.Nil(t, auth.UnPack(pack(test.flags, test.response)))
+		assert.Equal(t, test.want, auth.PluginName())
+	}
+
+	// The plugin sent by the client is kept.
+	auth := NewAuth()
+	data := auth.Pack(DefaultClientCapability, 0x21, "sbtest", "sbtest", DefaultSalt, "")
+	assert.Nil(t, auth.UnPack(data))
+	assert.Equal(t, DefaultAuthPluginName, auth.PluginName())
+	auth.SwitchAuthResponse(DefaultAuthPluginName, []byte{0x01})
+	assert.Equal(t, []byte{0x01}, auth.AuthResponse())
+}
@@ -0,0 +1,413 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// PackBinaryRow packs the row of the binary protocol resultset, the values are the text ones of the fields types.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-resultset-row.html
+func PackBinaryRow(fields []*querypb.Field, row []sqltypes.Value) ([]byte, error) {
+	if len(row) != len(fields) {
+		return nil, fmt.Errorf("proto.binary.row.values[%d].fields[%d].mismatch", len(row), len(fields))
+	}
+	buf := common.NewBuffer(64)
+
+	// packet header [00]
+	buf.WriteU8(OK_PACKET)
+
+	// NULL-bitmap, the offset is 2
+	bitmap := make([]byte, (len(fields)+7+2)/8)
+	for i, v := range row {
+		if v.IsNull() {
+			bitmap[(i+2)/8] |= 1 << uint((i+2)%8)
+		}
+	}
+	buf.WriteBytes(bitmap)
+
+	for i, v := range row {
+		if v.IsNull() {
+			continue
+		}
+		if err := writeBinaryValue(buf, fields[i].Type, v.Raw()); err != nil {
+			return nil, fmt.Errorf("proto.binary.row.field[%s].error:%v", fields[i].Name, err)
+		}
+	}
+	return buf.Datas(), nil
+}
+
+// UnPackBinaryRow parses the row of the binary protocol resultset to the text values of the fields types.
+func UnPackBinaryRow(fields []*querypb.Field, data []byte) ([]sqltypes.Value, error) {
+	buf := common.ReadBuffer(data)
+	if header, err := buf.ReadU8(); err != nil || header != OK_PACKET {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binary row packet header: %v", data)
+	}
+	bitmap, err := buf.ReadBytes((len(fields) + 7 + 2) / 8)
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binary row packet null bitmap: %v", data)
+	}
+
+	row := make([]sqltypes.Value, len(fields))
+	for i, field := range fields {
+		if bitmap[(i+2)/8]&(1<<uint((i+2)%8)) > 0 {
+			continue
+		}
+		if row[i], err = readBinaryValue(buf, field.Type, int(field.Decimals)); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binary row packet field[%s]: %v", field.Name, err)
+		}
+	}
+	return row, nil
+}
+
+// writeBinaryValue writes the text value of the type in the binary protocol.
+// https://dev.mysql.com/doc/internals/en/binary-protocol-value.html
+func writeBinaryValue(buf *common.Buffer, typ querypb.Type, val []byte) error {
+	s := string(val)
+	switch typ {
+	case sqltypes.Null:
+	case sqltypes.Int8, sqltypes.Uint8, sqltypes.Int16, sqltypes.Uint16, sqltypes.Year,
+		sqltypes.Int24, sqltypes.Uint24, sqltypes.Int32, sqltypes.Uint32, sqltypes.Int64, sqltypes.Uint64:
+		var n uint64
+		if sqltypes.IsSigned(typ) {
+			i, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			n = uint64(i)
+		} else {
+			u, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			n = u
+		}
+		switch binaryIntSize(typ) {
+		case 1:
+			buf.WriteU8(uint8(n))
+		case 2:
+			buf.WriteU16(uint16(n))
+		case 4:
+			buf.WriteU32(uint32(n))
+		default:
+			buf.WriteU64(n)
+		}
+	case sqltypes.Float32:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return err
+		}
+		buf.WriteU32(math.Float32bits(float32(f)))
+	case sqltypes.Float64:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		buf.WriteU64(math.Float64bits(f))
+	case sqltypes.Date, sqltypes.Datetime, sqltypes.Timestamp:
+		return writeBinaryDatetime(buf, s)
+	case sqltypes.Time:
+		return writeBinaryTime(buf, s)
+	default:
+		buf.WriteLenEncodeBytes(val)
+	}
+	return nil
+}
+
+// readBinaryValue reads the value of the type in the binary protocol as the text one,
+// the temporal values have the fractional digits of the decimals 1 to 6, or 6 digits if they have the microseconds.
+func readBinaryValue(buf *common.Buffer, typ querypb.Type, decimals int) (sqltypes.Value, error) {
+	switch typ {
+	case sqltypes.Null:
+		return sqltypes.NULL, nil
+	case sqltypes.Int8, sqltypes.Uint8, sqltypes.Int16, sqltypes.Uint16, sqltypes.Year,
+		sqltypes.Int24, sqltypes.Uint24, sqltypes.Int32, sqltypes.Uint32, sqltypes.Int64, sqltypes.Uint64:
+		var n uint64
+		var err error
+		signed := sqltypes.IsSigned(typ)
+		switch binaryIntSize(typ) {
+		case 1:
+			var v uint8
+			v, err = buf.ReadU8()
+			if n = uint64(v); signed {
+				n = uint64(int8(v))
+			}
+		case 2:
+			var v uint16
+			v, err = buf.ReadU16()
+			if n = uint64(v); signed {
+				n = uint64(int16(v))
+			}
+		case 4:
+			var v uint32
+			v, err = buf.ReadU32()
+			if n = uint64(v); signed {
+				n = uint64(int32(v))
+			}
+		default:
+			n, err = buf.ReadU64()
+		}
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if signed {
+			return sqltypes.MakeTrusted(typ, strconv.AppendInt(nil, int64(n), 10)), nil
+		}
+		return sqltypes.MakeTrusted(typ, strconv.AppendUint(nil, n, 10)), nil
+	case sqltypes.Float32:
+		v, err := buf.ReadU32()
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return sqltypes.MakeTrusted(typ, strconv.AppendFloat(nil, float64(math.Float32frombits(v)), 'g', -1, 32)), nil
+	case sqltypes.Float64:
+		v, err := buf.ReadU64()
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return sqltypes.MakeTrusted(typ, strconv.AppendFloat(nil, math.Float64frombits(v), 'g', -1, 64)), nil
+	case sqltypes.Date, sqltypes.Datetime, sqltypes.Timestamp:
+		s, err := readBinaryDatetime(buf, typ == sqltypes.Date, decimals)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return sqltypes.MakeTrusted(typ, []byte(s)), nil
+	case sqltypes.Time:
+		s, err := readBinaryTime(buf, decimals)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		return sqltypes.MakeTrusted(typ, []byte(s)), nil
+	}
+	v, err := buf.ReadLenEncodeBytes()
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	return sqltypes.MakeTrusted(typ, v), nil
+}
+
+// binaryIntSize returns the bytes of the integer type in the binary protocol.
+func binaryIntSize(typ querypb.Type) int {
+	switch typ {
+	case sqltypes.Int8, sqltypes.Uint8:
+		return 1
+	case sqltypes.Int16, sqltypes.Uint16, sqltypes.Year:
+		return 2
+	case sqltypes.Int24, sqltypes.Uint24, sqltypes.Int32, sqltypes.Uint32:
+		return 4
+	}
+	return 8
+}
+
+// writeBinaryDatetime writes the 'YYYY-MM-DD[ hh:mm:ss[.ffffff]]' in the shortest of the 0, 4, 7 and 11 bytes.
+func writeBinaryDatetime(buf *common.Buffer, s string) error {
+	var year, month, day, hour, minute, second, micro int
+	date, clock := s, ""
+	if i := strings.IndexAny(s, " T"); i >= 0 {
+		date, clock = s[:i], s[i+1:]
+	}
+	if _, err := fmt.Sscanf(date, "%d-%d-%d", &year, &month, &day); err != nil {
+		return fmt.Errorf("invalid datetime[%s]", s)
+	}
+	if clock != "" {
+		var err error
+		if hour, minute, second, micro, err = parseClock(clock); err != nil {
+			return fmt.Errorf("invalid datetime[%s]", s)
+		}
+	}
+
+	length := uint8(11)
+	switch {
+	case micro != 0:
+	case hour != 0 || minute != 0 || second != 0:
+		length = 7
+	case year != 0 || month != 0 || day != 0:
+		length = 4
+	default:
+		length = 0
+	}
+	buf.WriteU8(length)
+	if length >= 4 {
+		buf.WriteU16(uint16(year))
+		buf.WriteU8(uint8(month))
+		buf.WriteU8(uint8(day))
+	}
+	if length >= 7 {
+		buf.WriteU8(uint8(hour))
+		buf.WriteU8(uint8(minute))
+		buf.WriteU8(uint8(second))
+	}
+	if length >= 11 {
+		buf.WriteU32(uint32(micro))
+	}
+	return nil
+}
+
+func readBinaryDatetime(buf *common.Buffer, dateOnly bool, decimals int) (string, error) {
+	var year uint16
+	var month, day, hour, minute, second uint8
+	var micro uint32
+
+	length, err := buf.ReadU8()
+	if err != nil {
+		return "", err
+	}
+	if length >= 4 {
+		if year, err = buf.ReadU16(); err != nil {
+			return "", err
+		}
+		if month, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if day, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+	}
+	if length >= 7 {
+		if hour, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if minute, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if second, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+	}
+	if length >= 11 {
+		if micro, err = buf.ReadU32(); err != nil {
+			return "", err
+		}
+	}
+	if dateOnly {
+		return fmt.Sprintf("%04d-%02d-%02d", year, month, day), nil
+	}
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", year, month, day, hour, minute, second)
+	return s + formatMicro(micro, decimals), nil
+}
+
+// writeBinaryTime writes the '[-]hhh:mm:ss[.ffffff]' in the shortest of the 0, 8 and 12 bytes.
+func writeBinaryTime(buf *common.Buffer, s string) error {
+	neg := strings.HasPrefix(s, "-")
+	hours, minute, second, micro, err := parseClock(strings.TrimPrefix(s, "-"))
+	if err != nil {
+		return fmt.Errorf("invalid time[%s]", s)
+	}
+
+	length := uint8(12)
+	switch {
+	case micro != 0:
+	case hours != 0 || minute != 0 || second != 0:
+		length = 8
+	default:
+		length = 0
+	}
+	buf.WriteU8(length)
+	if length >= 8 {
+		if neg {
+			buf.WriteU8(1)
+		} else {
+			buf.WriteU8(0)
+		}
+		buf.WriteU32(uint32(hours / 24))
+		buf.WriteU8(uint8(hours % 24))
+		buf.WriteU8(uint8(minute))
+		buf.WriteU8(uint8(second))
+	}
+	if length >= 12 {
+		buf.WriteU32(uint32(micro))
+	}
+	return nil
+}
+
+func readBinaryTime(buf *common.Buffer, decimals int) (string, error) {
+	var neg, hour, minute, second uint8
+	var days, micro uint32
+
+	length, err := buf.ReadU8()
+	if err != nil {
+		return "", err
+	}
+	if length >= 8 {
+		if neg, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if days, err = buf.ReadU32(); err != nil {
+			return "", err
+		}
+		if hour, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if minute, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+		if second, err = buf.ReadU8(); err != nil {
+			return "", err
+		}
+	}
+	if length >= 12 {
+		if micro, err = buf.ReadU32(); err != nil {
+			return "", err
+		}
+	}
+	sign := ""
+	if neg == 1 {
+		sign = "-"
+	}
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, days*24+uint32(hour), minute, second)
+	return s + formatMicro(micro, decimals), nil
+}
+
+// formatMicro formats the fraction of the microseconds with the decimals digits.
+func formatMicro(micro uint32, decimals int) string {
+	if decimals < 1 || decimals > 6 {
+		if micro == 0 {
+			return ""
+		}
+		decimals = 6
+	}
+	return fmt.Sprintf(".%06d", micro)[:decimals+1]
+}
+
+// parseClock parses the 'hh:mm:ss[.ffffff]', the fraction is padded to the microseconds.
+func parseClock(s string) (hour, minute, second, micro int, err error) {
+	frac := ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		s, frac = s[:i], s[i+1:]
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 || len(frac) > 6 {
+		return 0, 0, 0, 0, fmt.Errorf("invalid clock[%s]", s)
+	}
+	if hour, err = strconv.Atoi(parts[0]); err != nil {
+		return
+	}
+	if minute, err = strconv.Atoi(parts[1]); err != nil {
+		return
+	}
+	if second, err = strconv.Atoi(parts[2]); err != nil {
+		return
+	}
+	if frac != "" {
+		if micro, err = strconv.Atoi(frac + strings.Repeat("0", 6-len(frac))); err != nil {
+			return
+		}
+	}
+	return
+}
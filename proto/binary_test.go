@@ -0,0 +1,91 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinaryRow(t *testing.T) {
+	tests := []struct {
+		typ      querypb.Type
+		decimals uint32
+		val      string
+		size     int
+	}{
+		{typ: sqltypes.Int8, val: "-1", size: 1},
+		{typ: sqltypes.Uint8, val: "255", size: 1},
+		{typ: sqltypes.Int16, val: "-32768", size: 2},
+		{typ: sqltypes.Year, val: "2020", size: 2},
+		{typ: sqltypes.Int24, val: "-8388608", size: 4},
+		{typ: sqltypes.Uint32, val: "4294967295", size: 4},
+		{typ: sqltypes.Int64, val: "-9223372036854775808", size: 8},
+		{typ: sqltypes.Uint64, val: "18446744073709551615", size: 8},
+		{typ: sqltypes.Float32, val: "1.5", size: 4},
+		{typ: sqltypes.Float64, val: "3.14159", size: 8},
+		{typ: sqltypes.Decimal, val: "12.34", size: 6},
+		{typ: sqltypes.VarChar, val: "abc", size: 4},
+		{typ: sqltypes.Blob, val: "\x00\x01", size: 3},
+		{typ: sqltypes.Date, val: "2020-01-02", size: 5},
+		{typ: sqltypes.Date, val: "0000-00-00", size: 1},
+		{typ: sqltypes.Datetime, val: "2020-01-02 00:00:00", size: 5},
+		{typ: sqltypes.Datetime, val: "2020-01-02 03:04:05", size: 8},
+		{typ: sqltypes.Datetime, decimals: 6, val: "2020-01-02 03:04:05.123456", size: 12},
+		{typ: sqltypes.Timestamp, decimals: 3, val: "2020-01-02 03:04:05.120", size: 12},
+		{typ: sqltypes.Timestamp, val: "0000-00-00 00:00:00", size: 1},
+		{typ: sqltypes.Time, val: "-838:59:59", size: 9},
+		{typ: sqltypes.Time, val: "00:00:00", size: 1},
+		{typ: sqltypes.Time, decimals: 6, val: "01:02:03.500000", size: 13},
+	}
+
+	for _, test := range tests {
+		fields := []*querypb.Field{{Name: "a", Type: test.typ, Decimals: test.decimals}, {Name: "b", Type: sqltypes.VarChar}}
+		row := []sqltypes.Value{sqltypes.MakeTrusted(test.typ, []byte(test.val)), sqltypes.NULL}
+		data, err := PackBinaryRow(fields, row)
+		assert.Nil(t, err)
+		// The header, the bitmap and the value.
+		assert.Equal(t, 2+test.size, len(data), test.val)
+		assert.Equal(t, byte(0x08), data[1], test.val)
+
+		got, err := UnPackBinaryRow(fields, data)
+		assert.Nil(t, err)
+		assert.Equal(t, row, got, test.val)
+	}
+}
+
+func TestBinaryRowError(t *testing.T) {
+	fields := []*querypb.Field{{Name: "a", Type: sqltypes.Int32}}
+
+	// Pack.
+	{
+		_, err := PackBinaryRow(fields, []sqltypes.Value{sqltypes.NewVarChar("x")})
+		assert.NotNil(t, err)
+		_, err = PackBinaryRow(fields, nil)
+		assert.NotNil(t, err)
+		_, err = PackBinaryRow([]*querypb.Field{{Name: "a", Type: sqltypes.Datetime}}, []sqltypes.Value{sqltypes.NewVarChar("x")})
+		assert.NotNil(t, err)
+		_, err = PackBinaryRow([]*querypb.Field{{Name: "a", Type: sqltypes.Time}}, []sqltypes.Value{sqltypes.NewVarChar("1:2")})
+		assert.NotNil(t, err)
+	}
+
+	// UnPack.
+	{
+		data, err := PackBinaryRow(fields, []sqltypes.Value{sqltypes.NewInt32(1)})
+		assert.Nil(t, err)
+		for i := 0; i < len(data); i++ {
+			_, err := UnPackBinaryRow(fields, data[:i])
+			assert.NotNil(t, err)
+		}
+	}
+}
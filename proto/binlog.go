@@ -0,0 +1,262 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// BINLOG_DUMP_NON_BLOCK tells the master to send EOF instead of blocking at the end of the binlog.
+	BINLOG_DUMP_NON_BLOCK uint16 = 0x01
+
+	// BINLOG_THROUGH_GTID tells the master the COM_BINLOG_DUMP_GTID has the gtid set data.
+	BINLOG_THROUGH_GTID uint16 = 0x04
+
+	// SEMI_SYNC_INDICATOR is the magic byte of the semi-sync event header and the ACK.
+	SEMI_SYNC_INDICATOR byte = 0xef
+
+	// SEMI_SYNC_ACK_REQUIRED is the semi-sync event header flag asking the slave to reply the ACK.
+	SEMI_SYNC_ACK_REQUIRED byte = 0x01
+)
+
+// BinlogDump is the COM_BINLOG_DUMP payload.
+type BinlogDump struct {
+	Position uint32
+	Flags    uint16
+	ServerID uint32
+	Filename string
+}
+
+// PackBinlogDump packs the COM_BINLOG_DUMP payload without the command byte.
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump.html
+func PackBinlogDump(d *BinlogDump) []byte {
+	buf := common.NewBuffer(64)
+
+	// binlog-pos
+	buf.WriteU32(d.Position)
+
+	// flags
+	buf.WriteU16(d.Flags)
+
+	// server-id
+	buf.WriteU32(d.ServerID)
+
+	// binlog-filename
+	buf.WriteString(d.Filename)
+	return buf.Datas()
+}
+
+// UnPackBinlogDump parses the COM_BINLOG_DUMP payload without the command byte.
+func UnPackBinlogDump(data []byte) (*BinlogDump, error) {
+	var err error
+	d := &BinlogDump{}
+	buf := common.ReadBuffer(data)
+
+	if d.Position, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump packet position: %v", data)
+	}
+	if d.Flags, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump packet flags: %v", data)
+	}
+	if d.ServerID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump packet serverid: %v", data)
+	}
+	if d.Filename, err = buf.ReadString(buf.Length() - buf.Seek()); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump packet filename: %v", data)
+	}
+	return d, nil
+}
+
+// BinlogDumpGTID is the COM_BINLOG_DUMP_GTID payload.
+type BinlogDumpGTID struct {
+	Flags    uint16
+	ServerID uint32
+	Filename string
+	Position uint64
+	// SIDData is the encoded gtid set, it's sent if the flags has BINLOG_THROUGH_GTID.
+	SIDData []byte
+}
+
+// PackBinlogDumpGTID packs the COM_BINLOG_DUMP_GTID payload without the command byte.
+// https://dev.mysql.com/doc/internals/en/com-binlog-dump-gtid.html
+func PackBinlogDumpGTID(d *BinlogDumpGTID) []byte {
+	buf := common.NewBuffer(64)
+
+	// flags
+	buf.WriteU16(d.Flags)
+
+	// server-id
+	buf.WriteU32(d.ServerID)
+
+	// binlog-filename
+	buf.WriteU32(uint32(len(d.Filename)))
+	buf.WriteString(d.Filename)
+
+	// binlog-pos
+	buf.WriteU64(d.Position)
+
+	// data
+	if d.Flags&BINLOG_THROUGH_GTID > 0 {
+		buf.WriteU32(uint32(len(d.SIDData)))
+		buf.WriteBytes(d.SIDData)
+	}
+	return buf.Datas()
+}
+
+// UnPackBinlogDumpGTID parses the COM_BINLOG_DUMP_GTID payload without the command byte.
+func UnPackBinlogDumpGTID(data []byte) (*BinlogDumpGTID, error) {
+	var err error
+	var n uint32
+	d := &BinlogDumpGTID{}
+	buf := common.ReadBuffer(data)
+
+	if d.Flags, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet flags: %v", data)
+	}
+	if d.ServerID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet serverid: %v", data)
+	}
+	if n, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet filename: %v", data)
+	}
+	if d.Filename, err = buf.ReadString(int(n)); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet filename: %v", data)
+	}
+	if d.Position, err = buf.ReadU64(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet position: %v", data)
+	}
+	if d.Flags&BINLOG_THROUGH_GTID > 0 {
+		if n, err = buf.ReadU32(); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet data: %v", data)
+		}
+		if d.SIDData, err = buf.ReadBytes(int(n)); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid binlog dump gtid packet data: %v", data)
+		}
+	}
+	return d, nil
+}
+
+// RegisterSlave is the COM_REGISTER_SLAVE payload.
+type RegisterSlave struct {
+	ServerID uint32
+	Host     string
+	User     string
+	Password string
+	Port     uint16
+	Rank     uint32
+	MasterID uint32
+}
+
+// PackRegisterSlave packs the COM_REGISTER_SLAVE payload without the command byte.
+// https://dev.mysql.com/doc/internals/en/com-register-slave.html
+func PackRegisterSlave(r *RegisterSlave) []byte {
+	buf := common.NewBuffer(64)
+
+	// server-id
+	buf.WriteU32(r.ServerID)
+
+	// slaves hostname
+	buf.WriteU8(uint8(len(r.Host)))
+	buf.WriteString(r.Host)
+
+	// slaves user
+	buf.WriteU8(uint8(len(r.User)))
+	buf.WriteString(r.User)
+
+	// slaves password
+	buf.WriteU8(uint8(len(r.Password)))
+	buf.WriteString(r.Password)
+
+	// slaves mysql-port
+	buf.WriteU16(r.Port)
+
+	// replication rank
+	buf.WriteU32(r.Rank)
+
+	// master-id
+	buf.WriteU32(r.MasterID)
+	return buf.Datas()
+}
+
+// UnPackRegisterSlave parses the COM_REGISTER_SLAVE payload without the command byte.
+func UnPackRegisterSlave(data []byte) (*RegisterSlave, error) {
+	var err error
+	var n uint8
+	r := &RegisterSlave{}
+	buf := common.ReadBuffer(data)
+
+	if r.ServerID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet serverid: %v", data)
+	}
+	if n, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet hostname: %v", data)
+	}
+	if r.Host, err = buf.ReadString(int(n)); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet hostname: %v", data)
+	}
+	if n, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet user: %v", data)
+	}
+	if r.User, err = buf.ReadString(int(n)); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet user: %v", data)
+	}
+	if n, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet password: %v", data)
+	}
+	if r.Password, err = buf.ReadString(int(n)); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet password: %v", data)
+	}
+	if r.Port, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet port: %v", data)
+	}
+	if r.Rank, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet rank: %v", data)
+	}
+	if r.MasterID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid register slave packet masterid: %v", data)
+	}
+	return r, nil
+}
+
+// SemiSyncAck is the semi-sync ACK payload, the binlog position the slave received.
+type SemiSyncAck struct {
+	Position uint64
+	Filename string
+}
+
+// PackSemiSyncAck packs the ACK payload without the SEMI_SYNC_INDICATOR byte.
+// https://dev.mysql.com/doc/internals/en/semi-sync-ack-packet.html
+func PackSemiSyncAck(a *SemiSyncAck) []byte {
+	buf := common.NewBuffer(64)
+
+	// binlog-pos
+	buf.WriteU64(a.Position)
+
+	// binlog-filename
+	buf.WriteString(a.Filename)
+	return buf.Datas()
+}
+
+// UnPackSemiSyncAck parses the ACK payload without the SEMI_SYNC_INDICATOR byte.
+func UnPackSemiSyncAck(data []byte) (*SemiSyncAck, error) {
+	var err error
+	a := &SemiSyncAck{}
+	buf := common.ReadBuffer(data)
+
+	if a.Position, err = buf.ReadU64(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid semi sync ack packet position: %v", data)
+	}
+	if a.Filename, err = buf.ReadString(buf.Length() - buf.Seek()); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid semi sync ack packet filename: %v", data)
+	}
+	return a, nil
+}
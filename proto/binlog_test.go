@@ -0,0 +1,95 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBinlogDump(t *testing.T) {
+	want := &BinlogDump{
+		Position: 4,
+		Flags:    BINLOG_DUMP_NON_BLOCK,
+		ServerID: 100,
+		Filename: "mysql-bin.000001",
+	}
+	got, err := UnPackBinlogDump(PackBinlogDump(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	// Empty filename.
+	want.Filename = ""
+	got, err = UnPackBinlogDump(PackBinlogDump(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = UnPackBinlogDump([]byte{0x01, 0x02})
+	assert.NotNil(t, err)
+}
+
+func TestBinlogDumpGTID(t *testing.T) {
+	want := &BinlogDumpGTID{
+		Flags:    BINLOG_THROUGH_GTID,
+		ServerID: 100,
+		Filename: "mysql-bin.000001",
+		Position: 4,
+		SIDData:  []byte{0x01, 0x02, 0x03},
+	}
+	got, err := UnPackBinlogDumpGTID(PackBinlogDumpGTID(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	// Without the gtid data.
+	want = &BinlogDumpGTID{ServerID: 100, Position: 4}
+	got, err = UnPackBinlogDumpGTID(PackBinlogDumpGTID(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = UnPackBinlogDumpGTID([]byte{0x04, 0x00, 0x01})
+	assert.NotNil(t, err)
+}
+
+func TestSemiSyncAck(t *testing.T) {
+	want := &SemiSyncAck{Position: 1024, Filename: "mysql-bin.000003"}
+	got, err := UnPackSemiSyncAck(PackSemiSyncAck(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = UnPackSemiSyncAck([]byte{0x01, 0x02})
+	assert.NotNil(t, err)
+}
+
+func TestRegisterSlave(t *testing.T) {
+	want := &RegisterSlave{
+		ServerID: 100,
+		Host:     "192.168.0.2",
+		User:     "repl",
+		Password: "secret",
+		Port:     3306,
+		MasterID: 1,
+	}
+	data := PackRegisterSlave(want)
+	got, err := UnPackRegisterSlave(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	// Empty strings.
+	want = &RegisterSlave{ServerID: 101}
+	got, err = UnPackRegisterSlave(PackRegisterSlave(want))
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	for i := 0; i < len(data); i++ {
+		_, err = UnPackRegisterSlave(data[:i])
+		assert.NotNil(t, err)
+	}
+}
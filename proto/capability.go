@@ -0,0 +1,50 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// CapabilityMissing is the reason of the required capabilities the client doesn't set.
+	CapabilityMissing = "missing"
+
+	// CapabilityUnexpected is the reason of the capabilities the client can't set,
+	// like the CLIENT_SSL of the handshake response not sent over the TLS.
+	CapabilityUnexpected = "unexpected"
+
+	// RequiredClientCapability are the capabilities every client must set, the client is 4.1+ only.
+	RequiredClientCapability = sqldb.CLIENT_PROTOCOL_41
+)
+
+// CapabilityError is the error of the capabilities of the handshake response which can't be negotiated.
+type CapabilityError struct {
+	// Reason is the CapabilityMissing or the CapabilityUnexpected.
+	Reason string
+
+	// Flags are the capabilities at fault.
+	Flags uint32
+}
+
+func (e *CapabilityError) Error() string {
+	return fmt.Sprintf("capability.%s[%s]", e.Reason, sqldb.CapabilityString(e.Flags))
+}
+
+// CheckClientCapability checks the client sets all the required capabilities,
+// the error is the *CapabilityError of the missing ones.
+func CheckClientCapability(client, required uint32) error {
+	if missing := required &^ client; missing != 0 {
+		return &CapabilityError{Reason: CapabilityMissing, Flags: missing}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilityString(t *testing.T) {
+	assert.Equal(t, "", sqldb.CapabilityString(0))
+	assert.Equal(t, "CLIENT_LONG_PASSWORD|CLIENT_PROTOCOL_41|CLIENT_QUERY_ATTRIBUTES|0x10000000|CLIENT_REMEMBER_OPTIONS",
+		sqldb.CapabilityString(sqldb.CLIENT_LONG_PASSWORD|sqldb.CLIENT_PROTOCOL_41|sqldb.CLIENT_QUERY_ATTRIBUTES|1<<28|1<<31))
+	assert.Equal(t, uint32(1<<28), sqldb.UnknownCapability(DefaultServerCapability|1<<28|1<<31))
+	assert.Equal(t, uint32(0), sqldb.UnknownCapability(DefaultServerCapability|DefaultClientCapability))
+}
+
+func TestCheckClientCapability(t *testing.T) {
+	assert.Nil(t, CheckClientCapability(DefaultClientCapability, RequiredClientCapability))
+
+	err := CheckClientCapability(sqldb.CLIENT_LONG_PASSWORD, RequiredClientCapability|sqldb.CLIENT_SECURE_CONNECTION)
+	assert.Equal(t, &CapabilityError{Reason: CapabilityMissing, Flags: sqldb.CLIENT_PROTOCOL_41 | sqldb.CLIENT_SECURE_CONNECTION}, err)
+	assert.Equal(t, "capability.missing[CLIENT_PROTOCOL_41|CLIENT_SECURE_CONNECTION]", err.Error())
+
+	// The handshake response of the 4.0 client.
+	buf := common.NewBuffer(8)
+	buf.WriteU32(sqldb.CLIENT_LONG_PASSWORD)
+	err = NewAuth().UnPack(buf.Datas())
+	_, ok := err.(*CapabilityError)
+	assert.True(t, ok)
+}
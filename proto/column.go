@@ -25,133 +25,218 @@ func ColumnCount(payload []byte) (count uint64, err error) {
 	return
 }
 
+// DefaultCatalog is the catalog of the column definitions, it's always 'def'.
+const DefaultCatalog = "def"
+
+// ColumnDefinition is the Protocol::ColumnDefinition41 with every field of the packet.
 // http://dev.mysql.com/doc/internals/en/com-query-response.html#packet-Protocol::ColumnDefinition41
-func UnpackColumn(payload []byte) (*querypb.Field, error) {
+type ColumnDefinition struct {
+	Catalog  string
+	Schema   string
+	Table    string
+	OrgTable string
+	Name     string
+	OrgName  string
+
+	// Charset is the collation id, the binary(63) for the binary and the numeric columns.
+	Charset      uint16
+	ColumnLength uint32
+
+	// Type is the MySQL type like the MYSQL_TYPE_LONG, the UNSIGNED_FLAG and the BINARY_FLAG of the Flags refine it.
+	Type     byte
+	Flags    uint16
+	Decimals uint8
+
+	// FieldList is the definition of the COM_FIELD_LIST, the DefaultValue follows the filler.
+	FieldList bool
+
+	// DefaultValue is the default of the column, nil is NULL.
+	DefaultValue []byte
+}
+
+// NewColumnDefinition returns the definition of the field, the flags of the type are always set
+// so the type is parsed back the same.
+func NewColumnDefinition(field *querypb.Field) *ColumnDefinition {
+	typ, flags := sqltypes.TypeToMySQL(field.Type)
+	return &ColumnDefinition{
+		Catalog:      DefaultCatalog,
+		Schema:       field.Database,
+		Table:        field.Table,
+		OrgTable:     field.OrgTable,
+		Name:         field.Name,
+		OrgName:      field.OrgName,
+		Charset:      uint16(field.Charset),
+		ColumnLength: field.ColumnLength,
+		Type:         byte(typ),
+		Flags:        uint16(field.Flags) | uint16(flags),
+		Decimals:     uint8(field.Decimals),
+	}
+}
+
+// Field returns the field of the definition, the catalog and the default value are dropped.
+func (def *ColumnDefinition) Field() (*querypb.Field, error) {
+	typ, err := sqltypes.MySQLToType(int64(def.Type), int64(def.Flags))
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "MySQLToType(%v,%v) failed: %v", def.Type, def.Flags, err)
+	}
+	return &querypb.Field{
+		Database:     def.Schema,
+		Table:        def.Table,
+		OrgTable:     def.OrgTable,
+		Name:         def.Name,
+		OrgName:      def.OrgName,
+		Charset:      uint32(def.Charset),
+		ColumnLength: def.ColumnLength,
+		Type:         typ,
+		Flags:        uint32(def.Flags),
+		Decimals:     uint32(def.Decimals),
+	}, nil
+}
+
+// UnPackColumnDefinition parses the column definition, the default value is parsed if it's of the COM_FIELD_LIST.
+func UnPackColumnDefinition(payload []byte, fieldList bool) (*ColumnDefinition, error) {
 	var err error
-	field := &querypb.Field{}
+	def := &ColumnDefinition{FieldList: fieldList}
 	buff := common.ReadBuffer(payload)
-	// Catalog is ignored, always set to "def"
-	if _, err = buff.ReadLenEncodeString(); err != nil {
-		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "skipping col catalog failed")
+
+	// lenenc_str Catalog
+	if def.Catalog, err = buff.ReadLenEncodeString(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col catalog failed")
 	}
 
 	// lenenc_str Schema
-	if field.Database, err = buff.ReadLenEncodeString(); err != nil {
+	if def.Schema, err = buff.ReadLenEncodeString(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col schema failed")
 	}
 
 	// lenenc_str Table
-	if field.Table, err = buff.ReadLenEncodeString(); err != nil {
+	if def.Table, err = buff.ReadLenEncodeString(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col table failed")
 	}
 
 	// lenenc_str Org_Table
-	if field.OrgTable, err = buff.ReadLenEncodeString(); err != nil {
+	if def.OrgTable, err = buff.ReadLenEncodeString(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col org_table failed")
 	}
 
 	// lenenc_str Name
-	if field.Name, err = buff.ReadLenEncodeString(); err != nil {
+	if def.Name, err = buff.ReadLenEncodeString(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col name failed")
 	}
 
 	// lenenc_str Org_Name
-	if field.OrgName, err = buff.ReadLenEncodeString(); err != nil {
+	if def.OrgName, err = buff.ReadLenEncodeString(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col org_name failed")
 	}
 
-	// lenenc_int length of fixed-length fields [0c], skip
+	// lenenc_int length of fixed-length fields [0c]
 	if _, err = buff.ReadLenEncode(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col 0c failed")
 	}
 
 	// 2 character set
-	charset, err := buff.ReadU16()
-	if err != nil {
+	if def.Charset, err = buff.ReadU16(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col charset failed")
 	}
-	field.Charset = uint32(charset)
 
 	// 4 column length
-	if field.ColumnLength, err = buff.ReadU32(); err != nil {
+	if def.ColumnLength, err = buff.ReadU32(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col columnlength failed")
 	}
 
 	// 1 type
-	t, err := buff.ReadU8()
-	if err != nil {
+	if def.Type, err = buff.ReadU8(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col type failed")
 	}
 
 	// 2 flags
-	flags, err := buff.ReadU16()
-	if err != nil {
+	if def.Flags, err = buff.ReadU16(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col flags failed")
 	}
-	field.Flags = uint32(flags)
-
-	// Convert MySQL type
-	if field.Type, err = sqltypes.MySQLToType(int64(t), int64(field.Flags)); err != nil {
-		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "MySQLToType(%v,%v) failed: %v", t, field.Flags, err)
-	}
 
 	// 1 Decimals
-	decimals, err := buff.ReadU8()
-	if err != nil {
-		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col type failed")
+	if def.Decimals, err = buff.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col decimals failed")
 	}
-	field.Decimals = uint32(decimals)
 
-	// 2 Filler and Default Values is ignored
-	//
-	return field, nil
-}
+	// 2 filler [00] [00], it's absent from the resultset definitions of some old servers.
+	if err = buff.ReadZero(2); err != nil && fieldList {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col filler failed")
+	}
 
-func PackColumn(field *querypb.Field) []byte {
-	typ, flags := sqltypes.TypeToMySQL(field.Type)
-	if field.Flags != 0 {
-		flags = int64(field.Flags)
+	// lenenc_str Default Values of the COM_FIELD_LIST, the NULL is nil.
+	if fieldList {
+		if def.DefaultValue, err = buff.ReadLenEncodeBytes(); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting col default failed")
+		}
 	}
+	return def, nil
+}
 
+// PackColumnDefinition packs the column definition, the default value is packed if it's of the COM_FIELD_LIST.
+func PackColumnDefinition(def *ColumnDefinition) []byte {
 	buf := common.NewBuffer(256)
 
-	// lenenc_str Catalog, always 'def'
-	buf.WriteLenEncodeString("def")
+	// lenenc_str Catalog
+	buf.WriteLenEncodeString(def.Catalog)
 
 	// lenenc_str Schema
-	buf.WriteLenEncodeString(field.Database)
+	buf.WriteLenEncodeString(def.Schema)
 
 	// lenenc_str Table
-	buf.WriteLenEncodeString(field.Table)
+	buf.WriteLenEncodeString(def.Table)
 
 	// lenenc_str Org_Table
-	buf.WriteLenEncodeString(field.OrgTable)
+	buf.WriteLenEncodeString(def.OrgTable)
 
 	// lenenc_str Name
-	buf.WriteLenEncodeString(field.Name)
+	buf.WriteLenEncodeString(def.Name)
 
 	// lenenc_str Org_Name
-	buf.WriteLenEncodeString(field.OrgName)
+	buf.WriteLenEncodeString(def.OrgName)
 
 	// lenenc_int length of fixed-length fields [0c]
 	buf.WriteLenEncode(uint64(0x0c))
 
 	// 2 character set
-	buf.WriteU16(uint16(field.Charset))
+	buf.WriteU16(def.Charset)
 
 	// 4 column length
-	buf.WriteU32(field.ColumnLength)
+	buf.WriteU32(def.ColumnLength)
 
 	// 1 type
-	buf.WriteU8(byte(typ))
+	buf.WriteU8(def.Type)
 
 	// 2 flags
-	buf.WriteU16(uint16(flags))
+	buf.WriteU16(def.Flags)
 
-	//1 Decimals
-	buf.WriteU8(uint8(field.Decimals))
+	// 1 Decimals
+	buf.WriteU8(def.Decimals)
 
 	// 2 filler [00] [00]
 	buf.WriteU16(uint16(0))
+
+	// lenenc_str Default Values of the COM_FIELD_LIST
+	if def.FieldList {
+		if def.DefaultValue == nil {
+			buf.WriteLenEncodeNUL()
+		} else {
+			buf.WriteLenEncodeBytes(def.DefaultValue)
+		}
+	}
 	return buf.Datas()
 }
+
+// UnpackColumn parses the column definition of the resultset to the field.
+func UnpackColumn(payload []byte) (*querypb.Field, error) {
+	def, err := UnPackColumnDefinition(payload, false)
+	if err != nil {
+		return nil, err
+	}
+	return def.Field()
+}
+
+// PackColumn packs the column definition of the resultset of the field.
+func PackColumn(field *querypb.Field) []byte {
+	return PackColumnDefinition(NewColumnDefinition(field))
+}
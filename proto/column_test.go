@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
 	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/stretchr/testify/assert"
 
@@ -49,6 +50,89 @@ func TestColumn(t *testing.T) {
 	assert.Equal(t, want, got)
 }
 
+func TestColumnDefinition(t *testing.T) {
+	defs := []*ColumnDefinition{
+		{
+			Catalog:      "def",
+			Schema:       "test",
+			Table:        "t",
+			OrgTable:     "t1",
+			Name:         "a",
+			OrgName:      "id",
+			Charset:      63,
+			ColumnLength: 20,
+			Type:         8,
+			Flags:        1 | 2 | 32 | 512,
+		},
+		{
+			Catalog:      "std",
+			Name:         "price",
+			Charset:      sqldb.CharacterSetUtf8,
+			ColumnLength: 0xffffffff,
+			Type:         246,
+			Flags:        0xffff,
+			Decimals:     0x1f,
+		},
+		{Catalog: "def", Name: "c", Charset: 45, ColumnLength: 40, Type: 253, FieldList: true},
+		{Catalog: "def", Name: "d", Type: 3, FieldList: true, DefaultValue: []byte{}},
+		{Catalog: "def", Name: "e", Type: 254, FieldList: true, DefaultValue: []byte("x'y")},
+	}
+	for _, want := range defs {
+		data := PackColumnDefinition(want)
+		got, err := UnPackColumnDefinition(data, want.FieldList)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+		for i := 0; i < len(data); i++ {
+			_, err := UnPackColumnDefinition(data[:i], true)
+			assert.NotNil(t, err)
+		}
+	}
+
+	// The filler is optional for the resultset definitions of the old servers.
+	data := PackColumnDefinition(defs[0])
+	got, err := UnPackColumnDefinition(data[:len(data)-2], false)
+	assert.Nil(t, err)
+	assert.Equal(t, defs[0], got)
+
+	// The unknown types can't be fields.
+	_, err = (&ColumnDefinition{Type: 0xf0}).Field()
+	assert.NotNil(t, err)
+}
+
+func TestColumnFields(t *testing.T) {
+	types := []querypb.Type{
+		sqltypes.Int8, sqltypes.Uint8, sqltypes.Int16, sqltypes.Uint16, sqltypes.Int24, sqltypes.Uint24,
+		sqltypes.Int32, sqltypes.Uint32, sqltypes.Int64, sqltypes.Uint64, sqltypes.Float32, sqltypes.Float64,
+		sqltypes.Timestamp, sqltypes.Date, sqltypes.Time, sqltypes.Datetime, sqltypes.Year, sqltypes.Decimal,
+		sqltypes.Text, sqltypes.Blob, sqltypes.VarChar, sqltypes.VarBinary, sqltypes.Char, sqltypes.Binary,
+		sqltypes.Bit, sqltypes.Enum, sqltypes.Set, sqltypes.Geometry, sqltypes.TypeJSON, sqltypes.Null,
+	}
+	for _, typ := range types {
+		// The flags of the type are kept with the ones of the field.
+		_, typFlags := sqltypes.TypeToMySQL(typ)
+		want := &querypb.Field{
+			Database:     "db",
+			Table:        "t",
+			OrgTable:     "t1",
+			Name:         "a",
+			OrgName:      "b",
+			Charset:      33,
+			ColumnLength: 255,
+			Decimals:     2,
+			Type:         typ,
+			Flags:        uint32(typFlags) | 1,
+		}
+		def := NewColumnDefinition(&querypb.Field{
+			Database: want.Database, Table: want.Table, OrgTable: want.OrgTable, Name: want.Name, OrgName: want.OrgName,
+			Charset: want.Charset, ColumnLength: want.ColumnLength, Decimals: want.Decimals, Type: typ, Flags: 1,
+		})
+		assert.Equal(t, DefaultCatalog, def.Catalog)
+		got, err := UnpackColumn(PackColumnDefinition(def))
+		assert.Nil(t, err)
+		assert.Equal(t, want, got, typ.String())
+	}
+}
+
 func TestColumnUnPackError(t *testing.T) {
 	// NULL
 	f0 := func(buff *common.Buffer) {
@@ -14,16 +14,27 @@ import (
 )
 
 const (
+	// DefaultServerVersion is the version string of the greeting.
+	DefaultServerVersion = "Radon 5.7"
+
 	DefaultAuthPluginName = "mysql_native_password"
 
+	// OldAuthPluginName is the pre-4.1 scramble, the server rejects it.
+	OldAuthPluginName = "mysql_old_password"
+
 	DefaultServerCapability = sqldb.CLIENT_LONG_PASSWORD |
+		sqldb.CLIENT_FOUND_ROWS |
 		sqldb.CLIENT_LONG_FLAG |
 		sqldb.CLIENT_CONNECT_WITH_DB |
 		sqldb.CLIENT_PROTOCOL_41 |
 		sqldb.CLIENT_TRANSACTIONS |
 		sqldb.CLIENT_MULTI_STATEMENTS |
 		sqldb.CLIENT_PLUGIN_AUTH |
+		sqldb.CLIENT_CONNECT_ATTRS |
 		sqldb.CLIENT_DEPRECATE_EOF |
+		sqldb.CLIENT_SESSION_TRACK |
+		sqldb.CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS |
+		sqldb.CLIENT_QUERY_ATTRIBUTES |
 		sqldb.CLIENT_SECURE_CONNECTION
 
 	DefaultClientCapability = sqldb.CLIENT_LONG_PASSWORD |
@@ -33,6 +44,7 @@ const (
 		sqldb.CLIENT_MULTI_STATEMENTS |
 		sqldb.CLIENT_PLUGIN_AUTH |
 		sqldb.CLIENT_DEPRECATE_EOF |
+		sqldb.CLIENT_SESSION_TRACK |
 		sqldb.CLIENT_SECURE_CONNECTION
 )
 
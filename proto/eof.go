@@ -9,6 +9,68 @@
 
 package proto
 
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
 const (
 	EOF_PACKET byte = 0xfe
 )
+
+type EOF struct {
+	Header      byte // 0xfe
+	Warnings    uint16
+	StatusFlags uint16
+}
+
+// UnPackEOF parses the EOF packet, or the OK packet with EOF header if it's
+// longer than a classic EOF packet(CLIENT_DEPRECATE_EOF).
+// https://dev.mysql.com/doc/internals/en/packet-EOF_Packet.html
+func UnPackEOF(data []byte) (*EOF, error) {
+	var err error
+	e := &EOF{}
+	buf := common.ReadBuffer(data)
+
+	if e.Header, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid eof packet header: %v", data)
+	}
+	if e.Header != EOF_PACKET {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid eof packet header: %v", e.Header)
+	}
+
+	// OK packet with EOF header.
+	if len(data) > 5 {
+		okdata := make([]byte, len(data))
+		copy(okdata, data)
+		okdata[0] = OK_PACKET
+		ok, err := UnPackOK(okdata)
+		if err != nil {
+			return nil, err
+		}
+		e.Warnings = ok.Warnings
+		e.StatusFlags = ok.StatusFlags
+		return e, nil
+	}
+
+	// Classic EOF packet, the pre-4.1 EOF has no payload.
+	if len(data) == 1 {
+		return e, nil
+	}
+	if e.Warnings, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid eof packet warnings: %v", data)
+	}
+	if e.StatusFlags, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid eof packet statusflags: %v", data)
+	}
+	return e, nil
+}
+
+// PackEOF packs the classic EOF packet with the warnings and the status flags.
+func PackEOF(e *EOF) []byte {
+	buf := common.NewBuffer(5)
+	buf.WriteU8(EOF_PACKET)
+	buf.WriteU16(e.Warnings)
+	buf.WriteU16(e.StatusFlags)
+	return buf.Datas()
+}
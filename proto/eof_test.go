@@ -0,0 +1,66 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEOF(t *testing.T) {
+	// Classic EOF.
+	{
+		buff := common.NewBuffer(32)
+		buff.WriteU8(0xfe)
+		buff.WriteU16(0x01)
+		buff.WriteU16(0x03)
+
+		want := &EOF{Header: 0xfe, Warnings: 1, StatusFlags: 3}
+		got, err := UnPackEOF(buff.Datas())
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+		assert.Equal(t, buff.Datas(), PackEOF(want))
+	}
+
+	// EOF without payload.
+	{
+		want := &EOF{Header: 0xfe}
+		got, err := UnPackEOF([]byte{0xfe})
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// OK with EOF header.
+	{
+		data := PackOK(&OK{AffectedRows: 1, LastInsertID: 2, StatusFlags: 3, Warnings: 4})
+		data[0] = EOF_PACKET
+
+		want := &EOF{Header: 0xfe, Warnings: 4, StatusFlags: 3}
+		got, err := UnPackEOF(data)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestEOFUnPackError(t *testing.T) {
+	datas := [][]byte{
+		{},
+		{0x00, 0x00, 0x00, 0x00, 0x00},
+		{0xfe, 0x00},
+		{0xfe, 0x00, 0x00, 0x00},
+		{0xfe, 0xfc, 0x00, 0x00, 0x00, 0x00},
+	}
+	for _, data := range datas {
+		_, err := UnPackEOF(data)
+		assert.NotNil(t, err)
+	}
+}
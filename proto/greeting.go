@@ -11,6 +11,7 @@ package proto
 
 import (
 	"crypto/rand"
+	"strings"
 
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
@@ -29,20 +30,24 @@ type Greeting struct {
 	// is using.  It is the features that are both supported by
 	// the client and the server, and currently in use.
 	// It is set after the initial handshake.
-	Capability     uint32
-	ConnectionID   uint32
-	serverVersion  string
-	authPluginName string
-	Salt           []byte
+	Capability uint32
+
+	// MariaDBCapability is the MariaDB extended capabilities,
+	// only valid if the CLIENT_LONG_PASSWORD(CLIENT_MYSQL) is off.
+	MariaDBCapability uint32
+	ConnectionID      uint32
+	serverVersion     string
+	authPluginName    string
+	Salt              []byte
 }
 
 func NewGreeting(connectionID uint32) *Greeting {
 	greeting := &Greeting{
 		protocolVersion: 10,
-		serverVersion:   "Radon 5.7",
+		serverVersion:   DefaultServerVersion,
 		ConnectionID:    connectionID,
 		Capability:      DefaultServerCapability,
-		Charset:         sqldb.CharacterSetUtf8,
+		Charset:         sqldb.DefaultCollation,
 		status:          sqldb.SERVER_STATUS_AUTOCOMMIT,
 		Salt:            make([]byte, 20),
 	}
@@ -59,6 +64,26 @@ func (g *Greeting) Status() uint16 {
 	return g.status
 }
 
+// SetStatus sets the status flags sent to the client.
+func (g *Greeting) SetStatus(status uint16) {
+	g.status = status
+}
+
+// ServerVersion returns the version string of the server.
+func (g *Greeting) ServerVersion() string {
+	return g.serverVersion
+}
+
+// SetServerVersion sets the version string sent to the client.
+func (g *Greeting) SetServerVersion(version string) {
+	g.serverVersion = version
+}
+
+// IsMariaDB returns true if the server is MariaDB.
+func (g *Greeting) IsMariaDB() bool {
+	return strings.Contains(strings.ToLower(g.serverVersion), "mariadb")
+}
+
 // https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeV10
 func (g *Greeting) Pack() []byte {
 	// greeting buffer
@@ -86,7 +111,7 @@ func (g *Greeting) Pack() []byte {
 	buf.WriteU16(capLower)
 
 	// 1: character set
-	buf.WriteU8(sqldb.CharacterSetUtf8)
+	buf.WriteU8(g.Charset)
 
 	// 2: status flags
 	buf.WriteU16(g.status)
@@ -99,7 +124,13 @@ func (g *Greeting) Pack() []byte {
 	buf.WriteU8(21)
 
 	// string[10]: reserved (all [00])
-	buf.WriteZero(10)
+	// MariaDB: string[6] filler, 4 extended capabilities if CLIENT_MYSQL off.
+	if g.Capability&sqldb.CLIENT_LONG_PASSWORD == 0 {
+		buf.WriteZero(6)
+		buf.WriteU32(g.MariaDBCapability)
+	} else {
+		buf.WriteZero(10)
+	}
 
 	// string[$len]: auth-plugin-data-part-2 ($len=MAX(13, length of auth-plugin-data - 8))
 	buf.WriteBytes(g.Salt[8:])
@@ -179,14 +210,22 @@ func (g *Greeting) UnPack(payload []byte) error {
 	}
 
 	// string[10]: reserved (all [00])
-	if err = buf.ReadZero(10); err != nil {
+	// MariaDB: string[6] filler, 4 extended capabilities if CLIENT_MYSQL off.
+	if err = buf.ReadZero(6); err != nil {
 		return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting greeting reserved failed")
 	}
+	var extended uint32
+	if extended, err = buf.ReadU32(); err != nil {
+		return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "extracting greeting reserved failed")
+	}
+	if g.Capability&sqldb.CLIENT_LONG_PASSWORD == 0 {
+		g.MariaDBCapability = extended
+	}
 
 	// string[$len]: auth-plugin-data-part-2 ($len=MAX(13, length of auth-plugin-data - 8))
 	if (g.Capability & sqldb.CLIENT_SECURE_CONNECTION) > 0 {
 		read := int(SLEN) - 8
-		if read < 0 || read > 13 {
+		if read <= 0 || read > 13 {
 			read = 13
 		}
 		var salt2 []byte
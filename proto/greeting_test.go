@@ -146,3 +146,39 @@ func TestGreetingUnPackError(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestGreetingMariaDB(t *testing.T) {
+	want := NewGreeting(4)
+	want.SetServerVersion("5.5.5-10.3.8-MariaDB-log")
+	want.Capability &^= sqldb.CLIENT_LONG_PASSWORD
+	want.MariaDBCapability = sqldb.MARIADB_CLIENT_PROGRESS | sqldb.MARIADB_CLIENT_STMT_BULK_OPERATIONS
+	want.authPluginName = "mysql_native_password"
+
+	got := NewGreeting(4)
+	err := got.UnPack(want.Pack())
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+	assert.True(t, got.IsMariaDB())
+	assert.Equal(t, "5.5.5-10.3.8-MariaDB-log", got.ServerVersion())
+
+	// The extended capabilities are ignored if CLIENT_MYSQL is set.
+	want.Capability |= sqldb.CLIENT_LONG_PASSWORD
+	got = NewGreeting(4)
+	err = got.UnPack(want.Pack())
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), got.MariaDBCapability)
+	assert.False(t, NewGreeting(4).IsMariaDB())
+}
+
+func TestGreetingCharsetStatus(t *testing.T) {
+	want := NewGreeting(4)
+	want.Charset = 45
+	want.SetStatus(sqldb.SERVER_STATUS_AUTOCOMMIT | sqldb.SERVER_STATUS_IN_TRANS)
+	want.authPluginName = "mysql_native_password"
+
+	got := NewGreeting(4)
+	err := got.UnPack(want.Pack())
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, uint8(45), got.Charset)
+}
@@ -12,6 +12,7 @@ package proto
 import (
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 const (
@@ -24,10 +25,23 @@ type OK struct {
 	LastInsertID uint64
 	StatusFlags  uint16
 	Warnings     uint16
+
+	// Info is the human readable info.
+	Info string
+
+	// SessionStateChanges are sent with the SERVER_SESSION_STATE_CHANGED to the CLIENT_SESSION_TRACK clients.
+	SessionStateChanges []sqltypes.SessionStateChange
 }
 
-// https://dev.mysql.com/doc/internals/en/packet-OK_Packet.html
+// UnPackOK parses the OK packet of the client without the CLIENT_SESSION_TRACK.
 func UnPackOK(data []byte) (*OK, error) {
+	return UnPackOKWithCapability(data, 0)
+}
+
+// UnPackOKWithCapability parses the OK packet with the capabilities of the connection,
+// the info and the session state changes are framed as the CLIENT_SESSION_TRACK says.
+// https://dev.mysql.com/doc/internals/en/packet-OK_Packet.html
+func UnPackOKWithCapability(data []byte, capability uint32) (*OK, error) {
 	var err error
 	o := &OK{}
 	buf := common.ReadBuffer(data)
@@ -59,10 +73,53 @@ func UnPackOK(data []byte) (*OK, error) {
 	if o.Warnings, err = buf.ReadU16(); err != nil {
 		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid ok packet warnings: %v", data)
 	}
+
+	// Info
+	rest := buf.Length() - buf.Seek()
+	if capability&sqldb.CLIENT_SESSION_TRACK == 0 {
+		if rest > 0 {
+			info, _ := buf.ReadBytes(rest)
+			o.Info = string(info)
+		}
+		return o, nil
+	}
+	if rest == 0 {
+		return o, nil
+	}
+	if o.Info, err = buf.ReadLenEncodeString(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid ok packet info: %v", data)
+	}
+
+	// Session state changes
+	if o.StatusFlags&sqldb.SERVER_SESSION_STATE_CHANGED == 0 {
+		return o, nil
+	}
+	state, err := buf.ReadLenEncodeBytes()
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid ok packet session state: %v", data)
+	}
+	changes := common.ReadBuffer(state)
+	for changes.Seek() < changes.Length() {
+		var change sqltypes.SessionStateChange
+		if change.Type, err = changes.ReadU8(); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid ok packet session state type: %v", data)
+		}
+		if change.Data, err = changes.ReadLenEncodeBytes(); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid ok packet session state data: %v", data)
+		}
+		o.SessionStateChanges = append(o.SessionStateChanges, change)
+	}
 	return o, nil
 }
 
+// PackOK packs the OK packet for the client without the CLIENT_SESSION_TRACK.
 func PackOK(o *OK) []byte {
+	return PackOKWithCapability(o, 0)
+}
+
+// PackOKWithCapability packs the OK packet with the capabilities of the connection,
+// the session state changes are dropped if the CLIENT_SESSION_TRACK is not set.
+func PackOKWithCapability(o *OK, capability uint32) []byte {
 	buf := common.NewBuffer(64)
 
 	// OK
@@ -79,5 +136,38 @@ func PackOK(o *OK) []byte {
 
 	// warnings
 	buf.WriteU16(o.Warnings)
+
+	// info and session state changes
+	if capability&sqldb.CLIENT_SESSION_TRACK == 0 {
+		buf.WriteString(o.Info)
+		return buf.Datas()
+	}
+	if o.Info == "" && len(o.SessionStateChanges) == 0 {
+		return buf.Datas()
+	}
+	buf.WriteLenEncodeString(o.Info)
+	if len(o.SessionStateChanges) > 0 {
+		state := common.NewBuffer(64)
+		for _, change := range o.SessionStateChanges {
+			state.WriteU8(change.Type)
+			state.WriteLenEncodeBytes(change.Data)
+		}
+		buf.WriteLenEncodeBytes(state.Datas())
+	}
 	return buf.Datas()
 }
+
+// SessionTrackSystemVariable returns the SESSION_TRACK_SYSTEM_VARIABLES change of the variable.
+func SessionTrackSystemVariable(name, value string) sqltypes.SessionStateChange {
+	buf := common.NewBuffer(len(name) + len(value) + 2)
+	buf.WriteLenEncodeString(name)
+	buf.WriteLenEncodeString(value)
+	return sqltypes.SessionStateChange{Type: sqldb.SESSION_TRACK_SYSTEM_VARIABLES, Data: buf.Datas()}
+}
+
+// SessionTrackSchema returns the SESSION_TRACK_SCHEMA change of the current schema.
+func SessionTrackSchema(schema string) sqltypes.SessionStateChange {
+	buf := common.NewBuffer(len(schema) + 1)
+	buf.WriteLenEncodeString(schema)
+	return sqltypes.SessionStateChange{Type: sqldb.SESSION_TRACK_SCHEMA, Data: buf.Datas()}
+}
@@ -12,6 +12,9 @@ package proto
 import (
 	"testing"
 
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
 	"github.com/XeLabs/go-mysqlstack/common"
 	"github.com/stretchr/testify/assert"
 )
@@ -57,6 +60,64 @@ func TestOK(t *testing.T) {
 	}
 }
 
+func TestOKWithCapability(t *testing.T) {
+	// The info is the rest of the packet without the CLIENT_SESSION_TRACK.
+	{
+		want := &OK{AffectedRows: 1, StatusFlags: 2, Info: "Rows matched: 1  Changed: 1  Warnings: 0"}
+		datas := PackOK(want)
+		assert.Equal(t, want.Info, string(datas[7:]))
+		got, err := UnPackOK(datas)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// The session state changes are dropped without the CLIENT_SESSION_TRACK.
+	{
+		ok := &OK{StatusFlags: sqldb.SERVER_SESSION_STATE_CHANGED, SessionStateChanges: []sqltypes.SessionStateChange{SessionTrackSchema("db")}}
+		got, err := UnPackOK(PackOK(ok))
+		assert.Nil(t, err)
+		assert.Equal(t, &OK{StatusFlags: sqldb.SERVER_SESSION_STATE_CHANGED}, got)
+	}
+
+	capability := uint32(sqldb.CLIENT_PROTOCOL_41 | sqldb.CLIENT_SESSION_TRACK)
+	tests := []*OK{
+		{AffectedRows: 1, StatusFlags: 2},
+		{AffectedRows: 1, StatusFlags: 2, Info: "Records: 3  Duplicates: 0  Warnings: 0"},
+		{
+			StatusFlags: 2 | sqldb.SERVER_SESSION_STATE_CHANGED,
+			SessionStateChanges: []sqltypes.SessionStateChange{
+				SessionTrackSystemVariable("autocommit", "OFF"),
+				SessionTrackSchema("db"),
+				{Type: sqldb.SESSION_TRACK_STATE_CHANGE, Data: []byte("1")},
+			},
+		},
+	}
+	for _, want := range tests {
+		datas := PackOKWithCapability(want, capability)
+		got, err := UnPackOKWithCapability(datas, capability)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+
+	// The encodings of the changes.
+	{
+		assert.Equal(t, []byte("\x0aautocommit\x03OFF"), SessionTrackSystemVariable("autocommit", "OFF").Data)
+		assert.Equal(t, []byte("\x02db"), SessionTrackSchema("db").Data)
+	}
+
+	// The malformed session state.
+	{
+		datas := PackOKWithCapability(tests[2], capability)
+		for _, n := range []int{len(datas) - 1, 9} {
+			_, err := UnPackOKWithCapability(datas[:n], capability)
+			assert.NotNil(t, err)
+		}
+		// The info is missing.
+		_, err := UnPackOKWithCapability(append(PackOK(&OK{}), 0x05), capability)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestOKUnPackError(t *testing.T) {
 	// header error
 	{
@@ -0,0 +1,102 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// PROGRESS_ERROR_CODE is the error code of the MariaDB progress packet,
+	// it's sent to the MARIADB_CLIENT_PROGRESS clients before the result of the statement.
+	PROGRESS_ERROR_CODE uint16 = 0xffff
+
+	// ProgressMax is the progress of the finished stage.
+	ProgressMax = 100000
+)
+
+// Progress is the MariaDB progress report.
+// https://mariadb.com/kb/en/progress-reporting/
+type Progress struct {
+	// Stage starts from 1, the MaxStage is at least the Stage.
+	Stage    uint8
+	MaxStage uint8
+
+	// Progress of the stage is in the thousandths of the percent, 0 to ProgressMax.
+	Progress uint32
+
+	// Info is the state like 'copy to tmp table'.
+	Info string
+}
+
+// Percent returns the progress of the stage in percent.
+func (p *Progress) Percent() float64 {
+	return float64(p.Progress) / 1000
+}
+
+// IsProgress checks whether the packet is a progress packet.
+func IsProgress(data []byte) bool {
+	return len(data) >= 3 && data[0] == ERR_PACKET && uint16(data[1])|uint16(data[2])<<8 == PROGRESS_ERROR_CODE
+}
+
+// UnPackProgress parses the progress packet.
+func UnPackProgress(data []byte) (*Progress, error) {
+	var err error
+	var strings uint8
+	p := &Progress{}
+
+	if !IsProgress(data) {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet header: %v", data)
+	}
+	buf := common.ReadBuffer(data[3:])
+	if strings, err = buf.ReadU8(); err != nil || strings < 1 {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet strings: %v", data)
+	}
+	if p.Stage, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet stage: %v", data)
+	}
+	if p.MaxStage, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet max stage: %v", data)
+	}
+	if p.Progress, err = buf.ReadU24(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet progress: %v", data)
+	}
+	if p.Info, err = buf.ReadLenEncodeString(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid progress packet info: %v", data)
+	}
+	return p, nil
+}
+
+// PackProgress packs the progress packet, the MaxStage is raised to the Stage and the Progress is capped.
+func PackProgress(p *Progress) []byte {
+	buf := common.NewBuffer(16 + len(p.Info))
+
+	// The ERR header.
+	buf.WriteU8(ERR_PACKET)
+	buf.WriteU16(PROGRESS_ERROR_CODE)
+
+	// The number of the strings, always 1.
+	buf.WriteU8(1)
+
+	maxStage := p.MaxStage
+	if maxStage < p.Stage {
+		maxStage = p.Stage
+	}
+	progress := p.Progress
+	if progress > ProgressMax {
+		progress = ProgressMax
+	}
+	buf.WriteU8(p.Stage)
+	buf.WriteU8(maxStage)
+	buf.WriteU24(progress)
+	buf.WriteLenEncodeString(p.Info)
+	return buf.Datas()
+}
@@ -0,0 +1,49 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProgress(t *testing.T) {
+	want := &Progress{Stage: 1, MaxStage: 2, Progress: 45500, Info: "copy to tmp table"}
+	data := PackProgress(want)
+	assert.True(t, IsProgress(data))
+	assert.Equal(t, []byte{0xff, 0xff, 0xff, 0x01, 0x01, 0x02, 0xbc, 0xb1, 0x00, 0x11}, data[:10])
+
+	got, err := UnPackProgress(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+	assert.Equal(t, 45.5, got.Percent())
+
+	// The max stage is raised and the progress is capped.
+	got, err = UnPackProgress(PackProgress(&Progress{Stage: 3, Progress: ProgressMax + 1}))
+	assert.Nil(t, err)
+	assert.Equal(t, &Progress{Stage: 3, MaxStage: 3, Progress: ProgressMax}, got)
+
+	// The ERR packet is not a progress.
+	assert.False(t, IsProgress(PackERR(&ERR{ErrorCode: 1105, ErrorMessage: "x"})))
+	assert.False(t, IsProgress([]byte{0xff}))
+}
+
+func TestProgressUnPackError(t *testing.T) {
+	data := PackProgress(&Progress{Stage: 1, MaxStage: 1, Info: "stage"})
+	for i := 0; i < len(data)-1; i++ {
+		_, err := UnPackProgress(data[:i])
+		assert.NotNil(t, err, i)
+	}
+
+	// No strings.
+	_, err := UnPackProgress([]byte{0xff, 0xff, 0xff, 0x00})
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,206 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// QueryAttribute is the named value the client attaches to the COM_QUERY or the COM_STMT_EXECUTE
+// by the CLIENT_QUERY_ATTRIBUTES, like a trace id or a routing hint.
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_protocol_com_query.html
+type QueryAttribute struct {
+	Name  string
+	Value sqltypes.Value
+}
+
+// ComQuery is the COM_QUERY payload.
+type ComQuery struct {
+	Query string
+
+	// Attributes are sent only if the CLIENT_QUERY_ATTRIBUTES is negotiated.
+	Attributes []QueryAttribute
+}
+
+// PackComQuery packs the COM_QUERY payload without the command byte,
+// the attributes prefix the query if the capability has the CLIENT_QUERY_ATTRIBUTES, they are dropped otherwise.
+func PackComQuery(q *ComQuery, capability uint32) ([]byte, error) {
+	if capability&sqldb.CLIENT_QUERY_ATTRIBUTES == 0 {
+		return common.StringToBytes(q.Query), nil
+	}
+	buf := common.NewBuffer(16 + len(q.Query))
+
+	// parameter_count
+	buf.WriteLenEncode(uint64(len(q.Attributes)))
+
+	// parameter_set_count, always 1
+	buf.WriteLenEncode(1)
+
+	if len(q.Attributes) > 0 {
+		values := make([]sqltypes.Value, len(q.Attributes))
+		names := make([]string, len(q.Attributes))
+		for i, attr := range q.Attributes {
+			values[i], names[i] = attr.Value, attr.Name
+		}
+		if err := writeParams(buf, values, names, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	// query
+	buf.WriteString(q.Query)
+	return buf.Datas(), nil
+}
+
+// UnPackComQuery parses the COM_QUERY payload without the command byte,
+// the attributes are parsed if the capability has the CLIENT_QUERY_ATTRIBUTES.
+// The Query shares the bytes of the data.
+func UnPackComQuery(data []byte, capability uint32) (*ComQuery, error) {
+	var err error
+	q := &ComQuery{}
+	if capability&sqldb.CLIENT_QUERY_ATTRIBUTES == 0 {
+		q.Query = common.BytesToString(data)
+		return q, nil
+	}
+	buf := common.ReadBuffer(data)
+
+	var count, sets uint64
+	if count, err = buf.ReadLenEncode(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid query packet parameter_count: %v", data)
+	}
+	if sets, err = buf.ReadLenEncode(); err != nil || sets != 1 {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid query packet parameter_set_count: %v", data)
+	}
+	if count > 0 {
+		if count > uint64(buf.Length()*8) {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid query packet parameter_count: %v", data)
+		}
+		var bitmap []byte
+		var bound byte
+		if bitmap, err = buf.ReadBytes((int(count) + 7) / 8); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid query packet null_bitmap: %v", data)
+		}
+		if bound, err = buf.ReadU8(); err != nil || bound != 1 {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid query packet new_params_bind_flag: %v", data)
+		}
+		var types []querypb.Type
+		var names []string
+		if types, names, err = readParamTypes(buf, int(count), true); err != nil {
+			return nil, err
+		}
+		var values []sqltypes.Value
+		if values, err = readParamValues(buf, bitmap, types, nil); err != nil {
+			return nil, err
+		}
+		q.Attributes = make([]QueryAttribute, count)
+		for i := range q.Attributes {
+			q.Attributes[i] = QueryAttribute{Name: names[i], Value: values[i]}
+		}
+	}
+	q.Query = common.BytesToString(data[buf.Seek():])
+	return q, nil
+}
+
+// writeParams writes the NULL-bitmap, the new-params-bound-flag, the types and the values of the params,
+// the names follow the types if they are not nil, the params in the longData are sent by the COM_STMT_SEND_LONG_DATA.
+func writeParams(buf *common.Buffer, params []sqltypes.Value, names []string, longData map[uint16]bool) error {
+	// NULL-bitmap
+	bitmap := make([]byte, (len(params)+7)/8)
+	for i, v := range params {
+		if v.IsNull() && !longData[uint16(i)] {
+			bitmap[i/8] |= 1 << uint(i%8)
+		}
+	}
+	buf.WriteBytes(bitmap)
+
+	// new-params-bound-flag
+	buf.WriteU8(1)
+
+	// type and name of each parameter
+	for i, v := range params {
+		typ, flags := sqltypes.TypeToMySQL(v.Type())
+		var unsigned byte
+		if flags&mysqlUnsigned > 0 {
+			unsigned = paramUnsigned
+		}
+		buf.WriteU8(uint8(typ))
+		buf.WriteU8(unsigned)
+		if names != nil {
+			buf.WriteLenEncodeString(names[i])
+		}
+	}
+
+	// value of each parameter
+	for i, v := range params {
+		if v.IsNull() || longData[uint16(i)] {
+			continue
+		}
+		if err := writeBinaryValue(buf, v.Type(), v.Raw()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readParamTypes reads the types of the count params, the names follow them if withNames.
+func readParamTypes(buf *common.Buffer, count int, withNames bool) ([]querypb.Type, []string, error) {
+	var err error
+	var names []string
+	types := make([]querypb.Type, count)
+	if withNames {
+		names = make([]string, count)
+	}
+	for i := range types {
+		var typ, flags byte
+		if typ, err = buf.ReadU8(); err != nil {
+			return nil, nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid packet param type: %v", buf.Datas())
+		}
+		if flags, err = buf.ReadU8(); err != nil {
+			return nil, nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid packet param flags: %v", buf.Datas())
+		}
+		var mysqlFlags int64
+		if flags&paramUnsigned > 0 {
+			mysqlFlags = mysqlUnsigned
+		}
+		if types[i], err = sqltypes.MySQLToType(int64(typ), mysqlFlags); err != nil {
+			return nil, nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid packet param type: %v", err)
+		}
+		if withNames {
+			if names[i], err = buf.ReadLenEncodeString(); err != nil {
+				return nil, nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid packet param name: %v", buf.Datas())
+			}
+		}
+	}
+	return types, names, nil
+}
+
+// readParamValues reads the values of the params of the types, the NULL ones are in the bitmap
+// and the ones in the longData are sent by the COM_STMT_SEND_LONG_DATA.
+func readParamValues(buf *common.Buffer, bitmap []byte, types []querypb.Type, longData map[uint16][]byte) ([]sqltypes.Value, error) {
+	var err error
+	values := make([]sqltypes.Value, len(types))
+	for i := range values {
+		if bitmap[i/8]&(1<<uint(i%8)) > 0 {
+			continue
+		}
+		if long, ok := longData[uint16(i)]; ok {
+			values[i] = sqltypes.MakeTrusted(types[i], long)
+			continue
+		}
+		if values[i], err = readBinaryValue(buf, types[i], 0); err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid packet param[%d]: %v", i, err)
+		}
+	}
+	return values, nil
+}
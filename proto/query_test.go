@@ -0,0 +1,64 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestComQuery(t *testing.T) {
+	want := &ComQuery{
+		Query: "select 1",
+		Attributes: []QueryAttribute{
+			{Name: "trace_id", Value: sqltypes.NewVarChar("abc")},
+			{Name: "empty", Value: sqltypes.NULL},
+			{Name: "n", Value: sqltypes.NewInt64(-7)},
+		},
+	}
+	data, err := PackComQuery(want, sqldb.CLIENT_QUERY_ATTRIBUTES)
+	assert.Nil(t, err)
+	got, err := UnPackComQuery(data, sqldb.CLIENT_QUERY_ATTRIBUTES)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	for i := 0; i < len(data)-len(want.Query); i++ {
+		_, err := UnPackComQuery(data[:i], sqldb.CLIENT_QUERY_ATTRIBUTES)
+		assert.NotNil(t, err)
+	}
+
+	// No attributes.
+	{
+		data, err := PackComQuery(&ComQuery{Query: "select 1"}, sqldb.CLIENT_QUERY_ATTRIBUTES)
+		assert.Nil(t, err)
+		assert.Equal(t, append([]byte{0, 1}, "select 1"...), data)
+		got, err := UnPackComQuery(data, sqldb.CLIENT_QUERY_ATTRIBUTES)
+		assert.Nil(t, err)
+		assert.Equal(t, &ComQuery{Query: "select 1"}, got)
+	}
+
+	// The attributes are dropped without the CLIENT_QUERY_ATTRIBUTES.
+	{
+		data, err := PackComQuery(want, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("select 1"), data)
+		got, err := UnPackComQuery(data, 0)
+		assert.Nil(t, err)
+		assert.Equal(t, &ComQuery{Query: "select 1"}, got)
+	}
+
+	// The parameter_count can't exceed the payload.
+	_, err = UnPackComQuery([]byte{0xfc, 0xff, 0xff, 1, 0}, sqldb.CLIENT_QUERY_ATTRIBUTES)
+	assert.NotNil(t, err)
+}
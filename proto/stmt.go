@@ -0,0 +1,339 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// CURSOR_TYPE_NO_CURSOR is the COM_STMT_EXECUTE flag of the resultset sent at once.
+	CURSOR_TYPE_NO_CURSOR byte = 0x00
+
+	// CURSOR_TYPE_READ_ONLY is the COM_STMT_EXECUTE flag of the resultset fetched by the COM_STMT_FETCH.
+	CURSOR_TYPE_READ_ONLY byte = 0x01
+
+	// CURSOR_TYPE_FOR_UPDATE is the COM_STMT_EXECUTE flag of the cursor for the update.
+	CURSOR_TYPE_FOR_UPDATE byte = 0x02
+
+	// CURSOR_TYPE_SCROLLABLE is the COM_STMT_EXECUTE flag of the scrollable cursor.
+	CURSOR_TYPE_SCROLLABLE byte = 0x04
+
+	// PARAMETER_COUNT_AVAILABLE is the COM_STMT_EXECUTE flag of the parameter_count sent by the CLIENT_QUERY_ATTRIBUTES
+	// clients even if the statement has no params.
+	PARAMETER_COUNT_AVAILABLE byte = 0x08
+
+	// paramUnsigned is the flag of the unsigned parameter type.
+	paramUnsigned byte = 0x80
+
+	// mysqlUnsigned is the UNSIGNED_FLAG of the column flags.
+	mysqlUnsigned = 32
+)
+
+// StmtPrepareOK is the COM_STMT_PREPARE_OK, it's followed by the definitions of the params and the columns.
+// https://dev.mysql.com/doc/internals/en/com-stmt-prepare-response.html
+type StmtPrepareOK struct {
+	StatementID uint32
+	ColumnCount uint16
+	ParamCount  uint16
+	Warnings    uint16
+}
+
+// PackStmtPrepareOK packs the COM_STMT_PREPARE_OK.
+func PackStmtPrepareOK(ok *StmtPrepareOK) []byte {
+	buf := common.NewBuffer(12)
+
+	// status [00]
+	buf.WriteU8(OK_PACKET)
+
+	// statement_id
+	buf.WriteU32(ok.StatementID)
+
+	// num_columns
+	buf.WriteU16(ok.ColumnCount)
+
+	// num_params
+	buf.WriteU16(ok.ParamCount)
+
+	// reserved_1 [00]
+	buf.WriteU8(0)
+
+	// warning_count
+	buf.WriteU16(ok.Warnings)
+	return buf.Datas()
+}
+
+// UnPackStmtPrepareOK parses the COM_STMT_PREPARE_OK.
+func UnPackStmtPrepareOK(data []byte) (*StmtPrepareOK, error) {
+	var err error
+	var status byte
+	ok := &StmtPrepareOK{}
+	buf := common.ReadBuffer(data)
+
+	if status, err = buf.ReadU8(); err != nil || status != OK_PACKET {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt prepare ok packet status: %v", data)
+	}
+	if ok.StatementID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt prepare ok packet statement_id: %v", data)
+	}
+	if ok.ColumnCount, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt prepare ok packet num_columns: %v", data)
+	}
+	if ok.ParamCount, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt prepare ok packet num_params: %v", data)
+	}
+	// The reserved_1 and warning_count are absent from the old servers.
+	if buf.ReadZero(1) == nil {
+		ok.Warnings, _ = buf.ReadU16()
+	}
+	return ok, nil
+}
+
+// StmtExecute is the COM_STMT_EXECUTE payload.
+// https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+type StmtExecute struct {
+	StatementID uint32
+
+	// Flags is the cursor type like the CURSOR_TYPE_READ_ONLY.
+	Flags      byte
+	Iterations uint32
+
+	// Types are the types of the params, they are the ones of the last execution if the client didn't send them.
+	Types  []querypb.Type
+	Params []sqltypes.Value
+
+	// LongData are the params sent by the COM_STMT_SEND_LONG_DATA, their values are not in the payload.
+	LongData map[uint16]bool
+
+	// Attributes follow the params if the CLIENT_QUERY_ATTRIBUTES is negotiated.
+	Attributes []QueryAttribute
+}
+
+// PackStmtExecute packs the COM_STMT_EXECUTE payload without the command byte, the types are the ones of the params.
+func PackStmtExecute(e *StmtExecute) ([]byte, error) {
+	return PackStmtExecuteWithCapability(e, 0)
+}
+
+// PackStmtExecuteWithCapability packs the COM_STMT_EXECUTE payload without the command byte,
+// the params are named and followed by the attributes if the capability has the CLIENT_QUERY_ATTRIBUTES,
+// the attributes are dropped otherwise.
+func PackStmtExecuteWithCapability(e *StmtExecute, capability uint32) ([]byte, error) {
+	buf := common.NewBuffer(64)
+	attributes := capability&sqldb.CLIENT_QUERY_ATTRIBUTES > 0
+
+	// stmt-id
+	buf.WriteU32(e.StatementID)
+
+	// flags
+	flags := e.Flags
+	if attributes && len(e.Attributes) > 0 {
+		flags |= PARAMETER_COUNT_AVAILABLE
+	}
+	buf.WriteU8(flags)
+
+	// iteration-count, always 1
+	buf.WriteU32(1)
+
+	params, names := e.Params, []string(nil)
+	if attributes {
+		if len(params) == 0 && flags&PARAMETER_COUNT_AVAILABLE == 0 {
+			return buf.Datas(), nil
+		}
+		params = append(params[:len(params):len(params)], make([]sqltypes.Value, len(e.Attributes))...)
+		names = make([]string, len(params))
+		for i, attr := range e.Attributes {
+			params[len(e.Params)+i], names[len(e.Params)+i] = attr.Value, attr.Name
+		}
+
+		// parameter_count
+		buf.WriteLenEncode(uint64(len(params)))
+	}
+	if len(params) > 0 {
+		if err := writeParams(buf, params, names, e.LongData); err != nil {
+			return nil, err
+		}
+	}
+	return buf.Datas(), nil
+}
+
+// PackStmtID packs the COM_STMT_CLOSE or COM_STMT_RESET payload without the command byte.
+func PackStmtID(id uint32) []byte {
+	buf := common.NewBuffer(4)
+
+	// statement-id
+	buf.WriteU32(id)
+	return buf.Datas()
+}
+
+// UnPackStmtID parses the statement id of the COM_STMT_EXECUTE, COM_STMT_CLOSE or COM_STMT_RESET payload without the command byte.
+func UnPackStmtID(data []byte) (uint32, error) {
+	id, err := common.ReadBuffer(data).ReadU32()
+	if err != nil {
+		return 0, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt packet statement_id: %v", data)
+	}
+	return id, nil
+}
+
+// UnPackStmtExecute parses the COM_STMT_EXECUTE payload without the command byte of the statement with the paramCount params,
+// the types are the ones of the last execution used if the client doesn't bind the new ones,
+// the params in the longData are the ones sent by the COM_STMT_SEND_LONG_DATA.
+func UnPackStmtExecute(data []byte, paramCount int, types []querypb.Type, longData map[uint16][]byte) (*StmtExecute, error) {
+	return UnPackStmtExecuteWithCapability(data, 0, paramCount, types, longData)
+}
+
+// UnPackStmtExecuteWithCapability parses the COM_STMT_EXECUTE payload as the UnPackStmtExecute,
+// the attributes following the params are parsed if the capability has the CLIENT_QUERY_ATTRIBUTES.
+func UnPackStmtExecuteWithCapability(data []byte, capability uint32, paramCount int, types []querypb.Type, longData map[uint16][]byte) (*StmtExecute, error) {
+	var err error
+	e := &StmtExecute{}
+	buf := common.ReadBuffer(data)
+	attributes := capability&sqldb.CLIENT_QUERY_ATTRIBUTES > 0
+
+	if e.StatementID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet statement_id: %v", data)
+	}
+	if e.Flags, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet flags: %v", data)
+	}
+	if e.Iterations, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet iteration_count: %v", data)
+	}
+	if paramCount == 0 && !(attributes && e.Flags&PARAMETER_COUNT_AVAILABLE > 0) {
+		return e, nil
+	}
+
+	// The attributes are counted with the params.
+	count := paramCount
+	if attributes {
+		var n uint64
+		if n, err = buf.ReadLenEncode(); err != nil || n < uint64(paramCount) || n > uint64(buf.Length()*8) {
+			return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet parameter_count: %v", data)
+		}
+		count = int(n)
+	}
+	if count == 0 {
+		return e, nil
+	}
+
+	var bitmap []byte
+	var bound byte
+	if bitmap, err = buf.ReadBytes((count + 7) / 8); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet null_bitmap: %v", data)
+	}
+	if bound, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet new_params_bound_flag: %v", data)
+	}
+	var names []string
+	if bound == 1 {
+		if types, names, err = readParamTypes(buf, count, attributes); err != nil {
+			return nil, err
+		}
+	}
+	// The attributes are never kept from the last execution.
+	if len(types) != count {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt execute packet params not bound: %v", data)
+	}
+	e.Types = types[:paramCount]
+
+	values, err := readParamValues(buf, bitmap, types, longData)
+	if err != nil {
+		return nil, err
+	}
+	e.Params = values[:paramCount]
+	for i := range e.Params {
+		if _, ok := longData[uint16(i)]; ok && bitmap[i/8]&(1<<uint(i%8)) == 0 {
+			if e.LongData == nil {
+				e.LongData = make(map[uint16]bool)
+			}
+			e.LongData[uint16(i)] = true
+		}
+	}
+	for i := paramCount; i < count; i++ {
+		e.Attributes = append(e.Attributes, QueryAttribute{Name: names[i], Value: values[i]})
+	}
+	return e, nil
+}
+
+// StmtFetch is the COM_STMT_FETCH payload, it fetches the rows of the cursor opened by the COM_STMT_EXECUTE.
+// https://dev.mysql.com/doc/internals/en/com-stmt-fetch.html
+type StmtFetch struct {
+	StatementID uint32
+	NumRows     uint32
+}
+
+// PackStmtFetch packs the COM_STMT_FETCH payload without the command byte.
+func PackStmtFetch(f *StmtFetch) []byte {
+	buf := common.NewBuffer(8)
+
+	// stmt-id
+	buf.WriteU32(f.StatementID)
+
+	// num rows
+	buf.WriteU32(f.NumRows)
+	return buf.Datas()
+}
+
+// UnPackStmtFetch parses the COM_STMT_FETCH payload without the command byte.
+func UnPackStmtFetch(data []byte) (*StmtFetch, error) {
+	var err error
+	f := &StmtFetch{}
+	buf := common.ReadBuffer(data)
+
+	if f.StatementID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt fetch packet statement_id: %v", data)
+	}
+	if f.NumRows, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt fetch packet num_rows: %v", data)
+	}
+	return f, nil
+}
+
+// StmtSendLongData is the COM_STMT_SEND_LONG_DATA payload, the data is appended to the param of the next execution.
+// https://dev.mysql.com/doc/internals/en/com-stmt-send-long-data.html
+type StmtSendLongData struct {
+	StatementID uint32
+	ParamID     uint16
+	Data        []byte
+}
+
+// PackStmtSendLongData packs the COM_STMT_SEND_LONG_DATA payload without the command byte.
+func PackStmtSendLongData(l *StmtSendLongData) []byte {
+	buf := common.NewBuffer(6 + len(l.Data))
+
+	// statement-id
+	buf.WriteU32(l.StatementID)
+
+	// param-id
+	buf.WriteU16(l.ParamID)
+
+	// data
+	buf.WriteBytes(l.Data)
+	return buf.Datas()
+}
+
+// UnPackStmtSendLongData parses the COM_STMT_SEND_LONG_DATA payload without the command byte.
+func UnPackStmtSendLongData(data []byte) (*StmtSendLongData, error) {
+	var err error
+	l := &StmtSendLongData{}
+	buf := common.ReadBuffer(data)
+
+	if l.StatementID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt send long data packet statement_id: %v", data)
+	}
+	if l.ParamID, err = buf.ReadU16(); err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid stmt send long data packet param_id: %v", data)
+	}
+	l.Data, _ = buf.ReadBytes(buf.Length() - buf.Seek())
+	return l, nil
+}
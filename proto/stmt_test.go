@@ -0,0 +1,192 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStmtPrepareOK(t *testing.T) {
+	want := &StmtPrepareOK{StatementID: 7, ColumnCount: 2, ParamCount: 3, Warnings: 1}
+	data := PackStmtPrepareOK(want)
+	assert.Equal(t, 12, len(data))
+	got, err := UnPackStmtPrepareOK(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	// The old servers don't send the warnings.
+	got, err = UnPackStmtPrepareOK(data[:9])
+	assert.Nil(t, err)
+	assert.Equal(t, &StmtPrepareOK{StatementID: 7, ColumnCount: 2, ParamCount: 3}, got)
+
+	for i := 0; i < 9; i++ {
+		_, err := UnPackStmtPrepareOK(data[:i])
+		assert.NotNil(t, err)
+	}
+}
+
+func TestStmtExecute(t *testing.T) {
+	params := []sqltypes.Value{
+		sqltypes.NewInt64(-1),
+		sqltypes.NULL,
+		sqltypes.NewUint64(18446744073709551615),
+		sqltypes.NewVarChar("abc"),
+		sqltypes.MakeTrusted(sqltypes.Datetime, []byte("2020-01-02 03:04:05")),
+	}
+	data, err := PackStmtExecute(&StmtExecute{StatementID: 3, Flags: CURSOR_TYPE_READ_ONLY, Params: params})
+	assert.Nil(t, err)
+
+	id, err := UnPackStmtID(data)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), id)
+
+	got, err := UnPackStmtExecute(data, len(params), nil, nil)
+	assert.Nil(t, err)
+	want := &StmtExecute{
+		StatementID: 3,
+		Flags:       CURSOR_TYPE_READ_ONLY,
+		Iterations:  1,
+		Types:       []querypb.Type{sqltypes.Int64, sqltypes.Null, sqltypes.Uint64, sqltypes.VarChar, sqltypes.Datetime},
+		Params:      params,
+	}
+	assert.Equal(t, want, got)
+
+	// The types of the last execution are used if they are not bound again.
+	{
+		data := []byte{3, 0, 0, 0, 0, 1, 0, 0, 0, 0x00, 0, 2, 0, 0, 0, 0, 0, 0, 0}
+		got, err := UnPackStmtExecute(data, 1, []querypb.Type{sqltypes.Int64}, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, []sqltypes.Value{sqltypes.NewInt64(2)}, got.Params)
+
+		_, err = UnPackStmtExecute(data, 1, nil, nil)
+		assert.NotNil(t, err)
+	}
+
+	// No params.
+	{
+		data, err := PackStmtExecute(&StmtExecute{StatementID: 1})
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{1, 0, 0, 0, 0, 1, 0, 0, 0}, data)
+		got, err := UnPackStmtExecute(data, 0, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, &StmtExecute{StatementID: 1, Iterations: 1}, got)
+	}
+
+	for i := 0; i < len(data); i++ {
+		_, err := UnPackStmtExecute(data[:i], len(params), nil, nil)
+		assert.NotNil(t, err)
+	}
+	_, err = UnPackStmtID(nil)
+	assert.NotNil(t, err)
+	stmtID, err := UnPackStmtID(PackStmtID(9))
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(9), stmtID)
+}
+
+func TestStmtFetch(t *testing.T) {
+	want := &StmtFetch{StatementID: 7, NumRows: 100}
+	data := PackStmtFetch(want)
+	assert.Equal(t, []byte{7, 0, 0, 0, 100, 0, 0, 0}, data)
+
+	got, err := UnPackStmtFetch(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	for i := 0; i < len(data); i++ {
+		_, err := UnPackStmtFetch(data[:i])
+		assert.NotNil(t, err)
+	}
+}
+
+func TestStmtSendLongData(t *testing.T) {
+	want := &StmtSendLongData{StatementID: 3, ParamID: 1, Data: []byte("blob")}
+	data := PackStmtSendLongData(want)
+	assert.Equal(t, []byte{3, 0, 0, 0, 1, 0, 'b', 'l', 'o', 'b'}, data)
+
+	got, err := UnPackStmtSendLongData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, want, got)
+
+	for i := 0; i < 6; i++ {
+		_, err := UnPackStmtSendLongData(data[:i])
+		assert.NotNil(t, err)
+	}
+
+	// The params of the long data are not in the execute payload, the BLOB type is the TEXT one without the column flags.
+	execute := &StmtExecute{
+		StatementID: 3,
+		Params:      []sqltypes.Value{sqltypes.NewInt64(1), sqltypes.MakeTrusted(sqltypes.Blob, nil)},
+		LongData:    map[uint16]bool{1: true},
+	}
+	data, err = PackStmtExecute(execute)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3, 0, 0, 0, 0, 1, 0, 0, 0, 0x00, 1, 8, 0, 252, 0, 1, 0, 0, 0, 0, 0, 0, 0}, data)
+
+	e, err := UnPackStmtExecute(data, 2, nil, map[uint16][]byte{1: []byte("blob")})
+	assert.Nil(t, err)
+	assert.Equal(t, []sqltypes.Value{sqltypes.NewInt64(1), sqltypes.MakeTrusted(sqltypes.Text, []byte("blob"))}, e.Params)
+	assert.Equal(t, map[uint16]bool{1: true}, e.LongData)
+}
+
+func TestStmtExecuteAttributes(t *testing.T) {
+	params := []sqltypes.Value{sqltypes.NewInt64(1), sqltypes.NULL}
+	attrs := []QueryAttribute{{Name: "trace_id", Value: sqltypes.NewVarChar("abc")}}
+	execute := &StmtExecute{StatementID: 2, Params: params, Attributes: attrs}
+	data, err := PackStmtExecuteWithCapability(execute, sqldb.CLIENT_QUERY_ATTRIBUTES)
+	assert.Nil(t, err)
+
+	got, err := UnPackStmtExecuteWithCapability(data, sqldb.CLIENT_QUERY_ATTRIBUTES, len(params), nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, CURSOR_TYPE_NO_CURSOR|PARAMETER_COUNT_AVAILABLE, got.Flags)
+	assert.Equal(t, []querypb.Type{sqltypes.Int64, sqltypes.Null}, got.Types)
+	assert.Equal(t, params, got.Params)
+	assert.Equal(t, attrs, got.Attributes)
+	for i := 0; i < len(data); i++ {
+		_, err := UnPackStmtExecuteWithCapability(data[:i], sqldb.CLIENT_QUERY_ATTRIBUTES, len(params), nil, nil)
+		assert.NotNil(t, err)
+	}
+
+	// The statement without params carries the attributes by the PARAMETER_COUNT_AVAILABLE.
+	{
+		data, err := PackStmtExecuteWithCapability(&StmtExecute{StatementID: 2, Attributes: attrs}, sqldb.CLIENT_QUERY_ATTRIBUTES)
+		assert.Nil(t, err)
+		got, err := UnPackStmtExecuteWithCapability(data, sqldb.CLIENT_QUERY_ATTRIBUTES, 0, nil, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(got.Params))
+		assert.Equal(t, attrs, got.Attributes)
+
+		data, err = PackStmtExecuteWithCapability(&StmtExecute{StatementID: 2}, sqldb.CLIENT_QUERY_ATTRIBUTES)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte{2, 0, 0, 0, 0, 1, 0, 0, 0}, data)
+	}
+
+	// The parameter_count can't be less than the params of the statement.
+	{
+		data := []byte{2, 0, 0, 0, PARAMETER_COUNT_AVAILABLE, 1, 0, 0, 0, 0}
+		_, err := UnPackStmtExecuteWithCapability(data, sqldb.CLIENT_QUERY_ATTRIBUTES, 1, nil, nil)
+		assert.NotNil(t, err)
+	}
+
+	// The attributes are dropped without the CLIENT_QUERY_ATTRIBUTES.
+	{
+		data, err := PackStmtExecuteWithCapability(execute, 0)
+		assert.Nil(t, err)
+		got, err := UnPackStmtExecute(data, len(params), nil, nil)
+		assert.Nil(t, err)
+		assert.Nil(t, got.Attributes)
+		assert.Equal(t, params, got.Params)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// FlavorMySQL is the MySQL or the compatible server.
+	FlavorMySQL = "mysql"
+
+	// FlavorMariaDB is the MariaDB server.
+	FlavorMariaDB = "mariadb"
+
+	// mariadbVersionHack is the prefix MariaDB 10+ sends to make the old clients happy.
+	mariadbVersionHack = "5.5.5-"
+)
+
+// ServerVersion is the parsed server version of the greeting.
+type ServerVersion struct {
+	Flavor string
+	Major  int
+	Minor  int
+	Patch  int
+
+	// Raw is the version string sent by the server.
+	Raw string
+}
+
+// ParseServerVersion parses the version like '5.7.20-log' or '5.5.5-10.3.8-MariaDB-log'.
+func ParseServerVersion(s string) (*ServerVersion, error) {
+	v := &ServerVersion{Flavor: FlavorMySQL, Raw: s}
+	if strings.Contains(strings.ToLower(s), "mariadb") {
+		v.Flavor = FlavorMariaDB
+		s = strings.TrimPrefix(s, mariadbVersionHack)
+	}
+
+	// Strip the suffix like '-log'.
+	if i := strings.IndexAny(s, "-+ "); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("proto.invalid.server.version[%s]", v.Raw)
+	}
+	nums := []*int{&v.Major, &v.Minor, &v.Patch}
+	for i, part := range parts {
+		if i >= len(nums) {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("proto.invalid.server.version[%s]", v.Raw)
+		}
+		*nums[i] = n
+	}
+	return v, nil
+}
+
+// IsMariaDB returns true if the server is MariaDB.
+func (v *ServerVersion) IsMariaDB() bool {
+	return v.Flavor == FlavorMariaDB
+}
+
+// AtLeast checks the version is not less than major.minor.patch.
+func (v *ServerVersion) AtLeast(major, minor, patch int) bool {
+	if v.Major != major {
+		return v.Major > major
+	}
+	if v.Minor != minor {
+		return v.Minor > minor
+	}
+	return v.Patch >= patch
+}
+
+func (v *ServerVersion) String() string {
+	return fmt.Sprintf("%s %d.%d.%d", v.Flavor, v.Major, v.Minor, v.Patch)
+}
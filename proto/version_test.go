@@ -0,0 +1,47 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package proto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		in      string
+		flavor  string
+		version [3]int
+	}{
+		{"5.7.20-log", FlavorMySQL, [3]int{5, 7, 20}},
+		{"8.0.11", FlavorMySQL, [3]int{8, 0, 11}},
+		{"5.7", FlavorMySQL, [3]int{5, 7, 0}},
+		{"5.5.5-10.3.8-MariaDB-log", FlavorMariaDB, [3]int{10, 3, 8}},
+		{"10.4.12-MariaDB-1:10.4.12+maria~bionic", FlavorMariaDB, [3]int{10, 4, 12}},
+	}
+	for _, test := range tests {
+		v, err := ParseServerVersion(test.in)
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.flavor, v.Flavor, test.in)
+		assert.Equal(t, test.version, [3]int{v.Major, v.Minor, v.Patch}, test.in)
+	}
+
+	v, _ := ParseServerVersion("5.5.5-10.3.8-MariaDB-log")
+	assert.True(t, v.IsMariaDB())
+	assert.True(t, v.AtLeast(10, 3, 0))
+	assert.False(t, v.AtLeast(10, 4, 0))
+	assert.Equal(t, "mariadb 10.3.8", v.String())
+
+	for _, bad := range []string{"", "abc", "5.x.1"} {
+		_, err := ParseServerVersion(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
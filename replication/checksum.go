@@ -0,0 +1,81 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+	"hash/crc32"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/proto"
+)
+
+// Binlog checksum algorithms.
+// https://dev.mysql.com/doc/refman/5.7/en/replication-options-binary-log.html#sysvar_binlog_checksum
+const (
+	BINLOG_CHECKSUM_ALG_OFF   uint8 = 0
+	BINLOG_CHECKSUM_ALG_CRC32 uint8 = 1
+	// BINLOG_CHECKSUM_ALG_UNDEF is the algorithm not known yet.
+	BINLOG_CHECKSUM_ALG_UNDEF uint8 = 255
+
+	// BinlogChecksumLength is the CRC32 trailer size of the event.
+	BinlogChecksumLength = 4
+)
+
+// ChecksumError returned by the parser if the event CRC32 mismatched.
+type ChecksumError struct {
+	EventType EventType
+	LogPos    uint32
+	Expected  uint32
+	Actual    uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("replication.event[%v].logpos[%d].checksum.mismatch.expected[%08x].actual[%08x]", e.EventType, e.LogPos, e.Expected, e.Actual)
+}
+
+// ParseChecksumAlgorithm parses the @@binlog_checksum value.
+func ParseChecksumAlgorithm(s string) uint8 {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "NONE", "OFF":
+		return BINLOG_CHECKSUM_ALG_OFF
+	case "CRC32":
+		return BINLOG_CHECKSUM_ALG_CRC32
+	}
+	return BINLOG_CHECKSUM_ALG_UNDEF
+}
+
+// hasChecksumAlgorithm checks the FORMAT_DESCRIPTION written by the server version ends with the checksum algorithm,
+// which are MySQL 5.6.1 and MariaDB 5.3 onwards.
+func hasChecksumAlgorithm(serverVersion string) bool {
+	v, err := proto.ParseServerVersion(serverVersion)
+	if err != nil {
+		return false
+	}
+	if v.IsMariaDB() {
+		return v.AtLeast(5, 3, 0)
+	}
+	return v.AtLeast(5, 6, 1)
+}
+
+// verifyChecksum checks the CRC32 trailer of the whole event.
+func verifyChecksum(header *EventHeader, data []byte) error {
+	if len(data) < EventHeaderSize+BinlogChecksumLength {
+		return fmt.Errorf("replication.event[%v].too.short.for.checksum:%v", header.EventType, data)
+	}
+	n := len(data) - BinlogChecksumLength
+	expected, _ := common.ReadBuffer(data[n:]).ReadU32()
+	actual := crc32.ChecksumIEEE(data[:n])
+	if expected != actual {
+		return &ChecksumError{EventType: header.EventType, LogPos: header.LogPos, Expected: expected, Actual: actual}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// withChecksum appends the CRC32 trailer to the event and fixes the event size.
+func withChecksum(ev []byte) []byte {
+	out := append([]byte(nil), ev...)
+	binary.LittleEndian.PutUint32(out[9:], uint32(len(out)+BinlogChecksumLength))
+	buf := common.NewBuffer(BinlogChecksumLength)
+	buf.WriteU32(crc32.ChecksumIEEE(out))
+	return append(out, buf.Datas()...)
+}
+
+func TestParseChecksumAlgorithm(t *testing.T) {
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_CRC32, ParseChecksumAlgorithm("crc32"))
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_OFF, ParseChecksumAlgorithm("NONE"))
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_UNDEF, ParseChecksumAlgorithm("SHA1"))
+
+	assert.True(t, hasChecksumAlgorithm("5.6.1"))
+	assert.False(t, hasChecksumAlgorithm("5.6.0-log"))
+	assert.True(t, hasChecksumAlgorithm("10.3.8-MariaDB-log"))
+	assert.False(t, hasChecksumAlgorithm("5.1.73"))
+}
+
+func TestParserChecksum(t *testing.T) {
+	parser := NewBinlogParser()
+
+	ev, err := parser.Parse(makeFormatDescriptionWith("5.7.20-log", BINLOG_CHECKSUM_ALG_CRC32))
+	assert.Nil(t, err)
+	fde := ev.Event.(*FormatDescriptionEvent)
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_CRC32, fde.ChecksumAlgorithm)
+	assert.Equal(t, int(PREVIOUS_GTIDS_EVENT), len(fde.EventTypeHeaderLengths))
+
+	// Stripped.
+	ev, err = parser.Parse(withChecksum(makeQuery(200, "db1", "BEGIN")))
+	assert.Nil(t, err)
+	assert.Equal(t, "BEGIN", ev.Event.(*QueryEvent).Query)
+	ev, err = parser.Parse(withChecksum(makeXID(300, 7)))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), ev.Event.(*XIDEvent).XID)
+
+	// Corrupted.
+	data := withChecksum(makeXID(400, 8))
+	data[EventHeaderSize] ^= 0xff
+	_, err = parser.Parse(data)
+	cerr, ok := err.(*ChecksumError)
+	assert.True(t, ok)
+	assert.Equal(t, XID_EVENT, cerr.EventType)
+	assert.Equal(t, uint32(400), cerr.LogPos)
+
+	// Stripped without the verification.
+	parser.SetVerifyChecksum(false)
+	ev, err = parser.Parse(data)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(8)^0xff, ev.Event.(*XIDEvent).XID)
+
+	// The corrupted FORMAT_DESCRIPTION.
+	parser = NewBinlogParser()
+	data = makeFormatDescriptionWith("5.7.20-log", BINLOG_CHECKSUM_ALG_CRC32)
+	data[len(data)-1] ^= 0xff
+	_, err = parser.Parse(data)
+	assert.NotNil(t, err)
+
+	// The checksum is off.
+	parser = NewBinlogParser()
+	ev, err = parser.Parse(makeFormatDescriptionWith("5.7.20-log", BINLOG_CHECKSUM_ALG_OFF))
+	assert.Nil(t, err)
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_OFF, ev.Event.(*FormatDescriptionEvent).ChecksumAlgorithm)
+	ev, err = parser.Parse(makeXID(300, 7))
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(7), ev.Event.(*XIDEvent).XID)
+
+	// The old server without the checksum algorithm.
+	parser = NewBinlogParser()
+	ev, err = parser.Parse(makeFormatDescriptionWith("5.5.40-log", BINLOG_CHECKSUM_ALG_OFF))
+	assert.Nil(t, err)
+	fde = ev.Event.(*FormatDescriptionEvent)
+	assert.Equal(t, BINLOG_CHECKSUM_ALG_OFF, fde.ChecksumAlgorithm)
+	assert.Equal(t, int(PREVIOUS_GTIDS_EVENT), len(fde.EventTypeHeaderLengths))
+}
+
+func TestSyncerChecksum(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	master := newMockMasterWith(t, "5.7.20-log", map[string]string{"binlog_checksum": "CRC32"}, func(packets *packet.Packets, dump interface{}) {
+		writeEvents(packets,
+			withChecksum(makeRotate(0, "mysql-bin.000001", 4)),
+			makeFormatDescriptionWith("5.7.20-log", BINLOG_CHECKSUM_ALG_CRC32),
+			withChecksum(makeQuery(200, "db1", "BEGIN")),
+		)
+		packets.Write([]byte{proto.EOF_PACKET})
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl", NonBlock: true})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	assert.Equal(t, "SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'", <-master.queries)
+	assert.Equal(t, "SET @master_binlog_checksum='CRC32'", <-master.queries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ev, err := streamer.GetEvent(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "mysql-bin.000001", ev.Event.(*RotateEvent).NextName)
+	_, err = streamer.GetEvent(ctx)
+	assert.Nil(t, err)
+	ev, err = streamer.GetEvent(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "BEGIN", ev.Event.(*QueryEvent).Query)
+}
@@ -0,0 +1,292 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// postHeaderLengths57 is the post-header lengths of the event types written by MySQL 5.7.
+var postHeaderLengths57 = []byte{
+	56, 13, 0, 8, 0, 18, 0, 4, 4, 4, 4, 18, 0, 0, 95, 0, 4, 26, 8, 0,
+	0, 0, 8, 8, 8, 2, 0, 0, 0, 10, 10, 10, 42, 42, 0, 18, 52, 0,
+}
+
+// NewFormatDescriptionEvent creates the FORMAT_DESCRIPTION of the binlog v4 with the MySQL 5.7 post-header lengths,
+// the checksum algorithm is only written if the server version has it.
+func NewFormatDescriptionEvent(serverVersion string, checksum uint8) *FormatDescriptionEvent {
+	if !hasChecksumAlgorithm(serverVersion) {
+		checksum = BINLOG_CHECKSUM_ALG_OFF
+	}
+	return &FormatDescriptionEvent{
+		Version:                4,
+		ServerVersion:          serverVersion,
+		EventHeaderLength:      EventHeaderSize,
+		EventTypeHeaderLengths: append([]byte(nil), postHeaderLengths57...),
+		ChecksumAlgorithm:      checksum,
+	}
+}
+
+// NewRowsEvent creates the v2 rows event of the table map, the typ is WRITE/UPDATE/DELETE_ROWS v1 or v2.
+// UPDATE has the before image followed by the after image for every row.
+func NewRowsEvent(typ EventType, table *TableMapEvent, rows [][]sqltypes.Value) *RowsEvent {
+	version := 2
+	if typ < WRITE_ROWS_EVENTv2 {
+		version = 1
+	}
+	return &RowsEvent{
+		eventType:   typ,
+		Version:     version,
+		TableID:     table.TableID,
+		Table:       table,
+		ColumnCount: uint64(len(table.ColumnTypes)),
+		Rows:        rows,
+	}
+}
+
+// Encode encodes the event body, the checksum algorithm is written if the server version has it.
+func (e *FormatDescriptionEvent) Encode() ([]byte, error) {
+	buf := common.NewBuffer(128)
+	buf.WriteU16(e.Version)
+	version := make([]byte, 50)
+	copy(version, e.ServerVersion)
+	buf.WriteBytes(version)
+	buf.WriteU32(e.CreateTimestamp)
+	buf.WriteU8(e.EventHeaderLength)
+	buf.WriteBytes(e.EventTypeHeaderLengths)
+	if hasChecksumAlgorithm(e.ServerVersion) {
+		buf.WriteU8(e.ChecksumAlgorithm)
+	}
+	return buf.Datas(), nil
+}
+
+// Encode encodes the event body.
+func (e *XIDEvent) Encode() ([]byte, error) {
+	buf := common.NewBuffer(8)
+	buf.WriteU64(e.XID)
+	return buf.Datas(), nil
+}
+
+// Encode encodes the event body.
+func (e *RotateEvent) Encode() ([]byte, error) {
+	buf := common.NewBuffer(8 + len(e.NextName))
+	buf.WriteU64(e.Position)
+	buf.WriteString(e.NextName)
+	return buf.Datas(), nil
+}
+
+// Encode encodes the event body.
+func (e *QueryEvent) Encode() ([]byte, error) {
+	if len(e.Schema) > 255 || len(e.StatusVars) > 65535 {
+		return nil, fmt.Errorf("replication.query.event.schema[%s].or.status.vars.too.long", e.Schema)
+	}
+	buf := common.NewBuffer(64)
+	buf.WriteU32(e.SlaveProxyID)
+	buf.WriteU32(e.ExecutionTime)
+	buf.WriteU8(uint8(len(e.Schema)))
+	buf.WriteU16(e.ErrorCode)
+	buf.WriteU16(uint16(len(e.StatusVars)))
+	buf.WriteBytes(e.StatusVars)
+	buf.WriteString(e.Schema)
+	buf.WriteU8(0)
+	buf.WriteString(e.Query)
+	return buf.Datas(), nil
+}
+
+// Encode encodes the event body with the logical timestamps.
+func (e *GTIDEvent) Encode() ([]byte, error) {
+	buf := common.NewBuffer(42)
+	buf.WriteU8(e.CommitFlag)
+	buf.WriteBytes(e.SID[:])
+	buf.WriteU64(uint64(e.GNO))
+	buf.WriteU8(2)
+	buf.WriteU64(uint64(e.LastCommitted))
+	buf.WriteU64(uint64(e.SequenceNumber))
+	return buf.Datas(), nil
+}
+
+// Encode encodes the event body with the 6 bytes table id.
+func (e *TableMapEvent) Encode() ([]byte, error) {
+	if len(e.Schema) > 255 || len(e.Table) > 255 {
+		return nil, fmt.Errorf("replication.table.map.event.name[%s.%s].too.long", e.Schema, e.Table)
+	}
+	if len(e.ColumnMeta) != len(e.ColumnTypes) {
+		return nil, fmt.Errorf("replication.table.map.event.column.types[%d].column.meta[%d].mismatch", len(e.ColumnTypes), len(e.ColumnMeta))
+	}
+
+	buf := common.NewBuffer(128)
+	buf.WriteBytes(writeLittleEndian(e.TableID, 6))
+	buf.WriteU16(e.Flags)
+	buf.WriteU8(uint8(len(e.Schema)))
+	buf.WriteString(e.Schema)
+	buf.WriteU8(0)
+	buf.WriteU8(uint8(len(e.Table)))
+	buf.WriteString(e.Table)
+	buf.WriteU8(0)
+	buf.WriteLenEncode(uint64(len(e.ColumnTypes)))
+	buf.WriteBytes(e.ColumnTypes)
+	buf.WriteLenEncodeBytes(e.encodeMeta())
+	nulls := make([]byte, (len(e.ColumnTypes)+7)/8)
+	copy(nulls, e.NullBitmap)
+	buf.WriteBytes(nulls)
+	return buf.Datas(), nil
+}
+
+func (e *TableMapEvent) encodeMeta() []byte {
+	var data []byte
+	for i, typ := range e.ColumnTypes {
+		meta := e.ColumnMeta[i]
+		switch typ {
+		case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON,
+			MYSQL_TYPE_TIMESTAMP2, MYSQL_TYPE_DATETIME2, MYSQL_TYPE_TIME2:
+			data = append(data, byte(meta))
+		case MYSQL_TYPE_VARCHAR:
+			data = append(data, byte(meta), byte(meta>>8))
+		case MYSQL_TYPE_BIT, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING,
+			MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+			data = append(data, byte(meta>>8), byte(meta))
+		}
+	}
+	return data
+}
+
+// Encode encodes the event body with the 6 bytes table id, all the columns are present in the images.
+func (e *RowsEvent) Encode() ([]byte, error) {
+	if e.Table == nil {
+		return nil, fmt.Errorf("replication.rows.event.table.id[%d].has.no.table.map", e.TableID)
+	}
+	count := len(e.Table.ColumnTypes)
+
+	buf := common.NewBuffer(256)
+	buf.WriteBytes(writeLittleEndian(e.TableID, 6))
+	buf.WriteU16(e.Flags)
+	if e.Version == 2 {
+		// The length includes itself.
+		buf.WriteU16(uint16(len(e.ExtraData) + 2))
+		buf.WriteBytes(e.ExtraData)
+	}
+	buf.WriteLenEncode(uint64(count))
+	present := make([]byte, (count+7)/8)
+	for i := 0; i < count; i++ {
+		present[i/8] |= 1 << uint(i%8)
+	}
+	buf.WriteBytes(present)
+	if e.IsUpdate() {
+		buf.WriteBytes(present)
+	}
+
+	for _, row := range e.Rows {
+		if len(row) != count {
+			return nil, fmt.Errorf("replication.rows.event.%s.%s.row.columns[%d].table.map.column.count[%d]", e.Table.Schema, e.Table.Table, len(row), count)
+		}
+		nulls := make([]byte, (count+7)/8)
+		var values []byte
+		for i, v := range row {
+			if v.IsNull() {
+				nulls[i/8] |= 1 << uint(i%8)
+				continue
+			}
+			data, err := encodeValue(v, e.Table.ColumnTypes[i], e.Table.ColumnMeta[i])
+			if err != nil {
+				return nil, fmt.Errorf("replication.rows.event.%s.%s.column[%d]:%v", e.Table.Schema, e.Table.Table, i, err)
+			}
+			values = append(values, data...)
+		}
+		buf.WriteBytes(nulls)
+		buf.WriteBytes(values)
+	}
+	return buf.Datas(), nil
+}
+
+// BinlogWriter encodes the events of the binlog files with the header and the checksum,
+// the log pos of the header is tracked from the file start.
+// The checksum algorithm is set by the FORMAT_DESCRIPTION, and the ROTATE switches to the next file.
+type BinlogWriter struct {
+	serverID uint32
+	checksum uint8
+	pos      uint32
+}
+
+// NewBinlogWriter creates the writer of the server id, the first event is at the position 4.
+func NewBinlogWriter(serverID uint32) *BinlogWriter {
+	return &BinlogWriter{
+		serverID: serverID,
+		checksum: BINLOG_CHECKSUM_ALG_OFF,
+		pos:      4,
+	}
+}
+
+// Position returns the log pos of the next event.
+func (w *BinlogWriter) Position() uint32 {
+	return w.pos
+}
+
+// Encode encodes the event, the event is the encodable one like *QueryEvent or *RowsEvent.
+// The returned BinlogEvent can be appended to the BinlogServer.
+func (w *BinlogWriter) Encode(timestamp uint32, ev Event) (*BinlogEvent, error) {
+	var typ EventType
+	var body []byte
+	var err error
+
+	switch e := ev.(type) {
+	case *FormatDescriptionEvent:
+		typ = FORMAT_DESCRIPTION_EVENT
+		body, err = e.Encode()
+		// The FORMAT_DESCRIPTION of the version has the checksum even the algorithm is off.
+		if err == nil && hasChecksumAlgorithm(e.ServerVersion) && e.ChecksumAlgorithm != BINLOG_CHECKSUM_ALG_CRC32 {
+			body = append(body, make([]byte, BinlogChecksumLength)...)
+		}
+	case *QueryEvent:
+		typ = QUERY_EVENT
+		body, err = e.Encode()
+	case *XIDEvent:
+		typ = XID_EVENT
+		body, err = e.Encode()
+	case *GTIDEvent:
+		typ = GTID_EVENT
+		body, err = e.Encode()
+	case *RotateEvent:
+		typ = ROTATE_EVENT
+		body, err = e.Encode()
+	case *TableMapEvent:
+		typ = TABLE_MAP_EVENT
+		body, err = e.Encode()
+	case *RowsEvent:
+		typ = e.eventType
+		body, err = e.Encode()
+	default:
+		return nil, fmt.Errorf("replication.binlog.writer.unsupported.event[%T]", ev)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checksum := w.checksum
+	if fde, ok := ev.(*FormatDescriptionEvent); ok {
+		checksum = fde.ChecksumAlgorithm
+	}
+	header := &EventHeader{Timestamp: timestamp, EventType: typ, ServerID: w.serverID}
+	size := EventHeaderSize + len(body)
+	if checksum == BINLOG_CHECKSUM_ALG_CRC32 {
+		size += BinlogChecksumLength
+	}
+	header.LogPos = w.pos + uint32(size)
+	data := packEvent(header, body, checksum)
+
+	w.pos = header.LogPos
+	w.checksum = checksum
+	if e, ok := ev.(*RotateEvent); ok {
+		w.pos = uint32(e.Position)
+	}
+	return &BinlogEvent{Header: header, RawData: data, Event: ev}, nil
+}
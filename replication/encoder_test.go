@@ -0,0 +1,109 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestBinlogWriter(t *testing.T) {
+	sid, err := gtid.ParseSID("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	assert.Nil(t, err)
+	table := &TableMapEvent{
+		TableID:     0x0102030405,
+		Flags:       1,
+		Schema:      "db1",
+		Table:       "t1",
+		ColumnCount: 4,
+		ColumnTypes: []byte{MYSQL_TYPE_LONG, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_DATETIME2},
+		ColumnMeta:  []uint16{0, 20, 10<<8 | 2, 0},
+		NullBitmap:  []byte{0x0e},
+	}
+
+	for _, alg := range []uint8{BINLOG_CHECKSUM_ALG_OFF, BINLOG_CHECKSUM_ALG_CRC32} {
+		events := []Event{
+			NewFormatDescriptionEvent("5.7.20-log", alg),
+			&GTIDEvent{CommitFlag: 1, SID: sid, GNO: 7, LastCommitted: 6, SequenceNumber: 7},
+			&QueryEvent{SlaveProxyID: 1, Schema: "db1", Query: "BEGIN"},
+			table,
+			NewRowsEvent(WRITE_ROWS_EVENTv2, table, [][]sqltypes.Value{makeRow("1", "a"), makeRow("2", "")}),
+			&RowsEvent{eventType: UPDATE_ROWS_EVENTv1, Version: 1, TableID: table.TableID, Table: table, ColumnCount: 4, Flags: 1,
+				Rows: [][]sqltypes.Value{makeRow("1", "a"), makeRow("1", "b")}},
+			&XIDEvent{XID: 9},
+			&RotateEvent{Position: 4, NextName: "mysql-bin.000002"},
+		}
+
+		w := NewBinlogWriter(100)
+		parser := NewBinlogParser()
+		pos := uint32(4)
+		for _, ev := range events {
+			encoded, err := w.Encode(1500000000, ev)
+			assert.Nil(t, err)
+			got, err := parser.Parse(encoded.RawData)
+			assert.Nil(t, err)
+			assert.Equal(t, encoded.Header, got.Header)
+			assert.Equal(t, uint32(100), got.Header.ServerID)
+			assert.Equal(t, pos+got.Header.EventSize, got.Header.LogPos)
+			pos = got.Header.LogPos
+
+			switch e := got.Event.(type) {
+			case *TableMapEvent:
+				assert.Equal(t, table.TableID, e.TableID)
+				assert.Equal(t, table.ColumnTypes, e.ColumnTypes)
+				assert.Equal(t, table.ColumnMeta, e.ColumnMeta)
+				assert.Equal(t, table.NullBitmap, e.NullBitmap)
+			case *RowsEvent:
+				want := ev.(*RowsEvent)
+				assert.Equal(t, want.Version, e.Version)
+				assert.Equal(t, want.Rows, e.Rows)
+			default:
+				assert.Equal(t, ev, got.Event)
+			}
+		}
+		// The ROTATE switches to the next file.
+		assert.Equal(t, uint32(4), w.Position())
+	}
+
+	// The unsupported event and the invalid value.
+	w := NewBinlogWriter(100)
+	_, err = w.Encode(0, &HeartbeatEvent{})
+	assert.NotNil(t, err)
+	bad := []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x")), sqltypes.NULL, sqltypes.NULL, sqltypes.NULL}
+	_, err = w.Encode(0, NewRowsEvent(WRITE_ROWS_EVENTv2, table, [][]sqltypes.Value{bad}))
+	assert.NotNil(t, err)
+	_, err = w.Encode(0, &RowsEvent{eventType: WRITE_ROWS_EVENTv2, Version: 2})
+	assert.NotNil(t, err)
+	assert.Equal(t, uint32(4), w.Position())
+}
+
+func TestBinlogWriterServe(t *testing.T) {
+	bs, svr := newMockBinlogServer(t, &BinlogServerConfig{ServerID: 1})
+	defer svr.Close()
+	defer bs.Close()
+
+	// Synthesizes the next file.
+	w := NewBinlogWriter(1)
+	for _, ev := range []Event{
+		&RotateEvent{Position: 4, NextName: "mysql-bin.000003"},
+		NewFormatDescriptionEvent("5.7.20-log", BINLOG_CHECKSUM_ALG_OFF),
+		&QueryEvent{Schema: "db1", Query: "CREATE TABLE t2(a INT)"},
+	} {
+		encoded, err := w.Encode(1, ev)
+		assert.Nil(t, err)
+		assert.Nil(t, bs.Append(encoded))
+	}
+	assert.Equal(t, Position{Name: "mysql-bin.000003", Pos: w.Position()}, bs.Position())
+}
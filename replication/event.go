@@ -0,0 +1,414 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+)
+
+// EventType is the binlog event type code.
+type EventType byte
+
+// https://dev.mysql.com/doc/internals/en/binlog-event-type.html
+const (
+	UNKNOWN_EVENT EventType = iota
+	START_EVENT_V3
+	QUERY_EVENT
+	STOP_EVENT
+	ROTATE_EVENT
+	INTVAR_EVENT
+	LOAD_EVENT
+	SLAVE_EVENT
+	CREATE_FILE_EVENT
+	APPEND_BLOCK_EVENT
+	EXEC_LOAD_EVENT
+	DELETE_FILE_EVENT
+	NEW_LOAD_EVENT
+	RAND_EVENT
+	USER_VAR_EVENT
+	FORMAT_DESCRIPTION_EVENT
+	XID_EVENT
+	BEGIN_LOAD_QUERY_EVENT
+	EXECUTE_LOAD_QUERY_EVENT
+	TABLE_MAP_EVENT
+	WRITE_ROWS_EVENTv0
+	UPDATE_ROWS_EVENTv0
+	DELETE_ROWS_EVENTv0
+	WRITE_ROWS_EVENTv1
+	UPDATE_ROWS_EVENTv1
+	DELETE_ROWS_EVENTv1
+	INCIDENT_EVENT
+	HEARTBEAT_EVENT
+	IGNORABLE_EVENT
+	ROWS_QUERY_EVENT
+	WRITE_ROWS_EVENTv2
+	UPDATE_ROWS_EVENTv2
+	DELETE_ROWS_EVENTv2
+	GTID_EVENT
+	ANONYMOUS_GTID_EVENT
+	PREVIOUS_GTIDS_EVENT
+)
+
+var eventTypeNames = map[EventType]string{
+	UNKNOWN_EVENT:            "UnknownEvent",
+	START_EVENT_V3:           "StartEventV3",
+	QUERY_EVENT:              "QueryEvent",
+	STOP_EVENT:               "StopEvent",
+	ROTATE_EVENT:             "RotateEvent",
+	INTVAR_EVENT:             "IntVarEvent",
+	LOAD_EVENT:               "LoadEvent",
+	SLAVE_EVENT:              "SlaveEvent",
+	CREATE_FILE_EVENT:        "CreateFileEvent",
+	APPEND_BLOCK_EVENT:       "AppendBlockEvent",
+	EXEC_LOAD_EVENT:          "ExecLoadEvent",
+	DELETE_FILE_EVENT:        "DeleteFileEvent",
+	NEW_LOAD_EVENT:           "NewLoadEvent",
+	RAND_EVENT:               "RandEvent",
+	USER_VAR_EVENT:           "UserVarEvent",
+	FORMAT_DESCRIPTION_EVENT: "FormatDescriptionEvent",
+	XID_EVENT:                "XIDEvent",
+	BEGIN_LOAD_QUERY_EVENT:   "BeginLoadQueryEvent",
+	EXECUTE_LOAD_QUERY_EVENT: "ExecuteLoadQueryEvent",
+	TABLE_MAP_EVENT:          "TableMapEvent",
+	WRITE_ROWS_EVENTv0:       "WriteRowsEventV0",
+	UPDATE_ROWS_EVENTv0:      "UpdateRowsEventV0",
+	DELETE_ROWS_EVENTv0:      "DeleteRowsEventV0",
+	WRITE_ROWS_EVENTv1:       "WriteRowsEventV1",
+	UPDATE_ROWS_EVENTv1:      "UpdateRowsEventV1",
+	DELETE_ROWS_EVENTv1:      "DeleteRowsEventV1",
+	INCIDENT_EVENT:           "IncidentEvent",
+	HEARTBEAT_EVENT:          "HeartbeatEvent",
+	IGNORABLE_EVENT:          "IgnorableEvent",
+	ROWS_QUERY_EVENT:         "RowsQueryEvent",
+	WRITE_ROWS_EVENTv2:       "WriteRowsEventV2",
+	UPDATE_ROWS_EVENTv2:      "UpdateRowsEventV2",
+	DELETE_ROWS_EVENTv2:      "DeleteRowsEventV2",
+	GTID_EVENT:               "GTIDEvent",
+	ANONYMOUS_GTID_EVENT:     "AnonymousGTIDEvent",
+	PREVIOUS_GTIDS_EVENT:     "PreviousGTIDsEvent",
+
+	MARIADB_ANNOTATE_ROWS_EVENT:     "MariadbAnnotateRowsEvent",
+	MARIADB_BINLOG_CHECKPOINT_EVENT: "MariadbBinlogCheckpointEvent",
+	MARIADB_GTID_EVENT:              "MariadbGTIDEvent",
+	MARIADB_GTID_LIST_EVENT:         "MariadbGTIDListEvent",
+	MARIADB_START_ENCRYPTION_EVENT:  "MariadbStartEncryptionEvent",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("UnknownEvent(%d)", byte(t))
+}
+
+const (
+	// EventHeaderSize is the v4 event header size.
+	EventHeaderSize = 19
+
+	// LOG_EVENT_ARTIFICIAL_F is the header flag of the events generated by the master, which are not in the binlog file.
+	LOG_EVENT_ARTIFICIAL_F = 0x0020
+)
+
+// EventHeader is the v4 binlog event header.
+// https://dev.mysql.com/doc/internals/en/binlog-event-header.html
+type EventHeader struct {
+	Timestamp uint32
+	EventType EventType
+	ServerID  uint32
+	EventSize uint32
+	// LogPos is the position of the next event.
+	LogPos uint32
+	Flags  uint16
+}
+
+// UnPackEventHeader parses the event header.
+func UnPackEventHeader(data []byte) (*EventHeader, error) {
+	var err error
+	var typ uint8
+	h := &EventHeader{}
+	buf := common.ReadBuffer(data)
+
+	if h.Timestamp, err = buf.ReadU32(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.timestamp:%v", data)
+	}
+	if typ, err = buf.ReadU8(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.type:%v", data)
+	}
+	h.EventType = EventType(typ)
+	if h.ServerID, err = buf.ReadU32(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.serverid:%v", data)
+	}
+	if h.EventSize, err = buf.ReadU32(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.eventsize:%v", data)
+	}
+	if h.LogPos, err = buf.ReadU32(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.logpos:%v", data)
+	}
+	if h.Flags, err = buf.ReadU16(); err != nil {
+		return nil, fmt.Errorf("replication.invalid.event.header.flags:%v", data)
+	}
+	if int(h.EventSize) != len(data) {
+		return nil, fmt.Errorf("replication.invalid.event.size[%d].datas.size[%d]", h.EventSize, len(data))
+	}
+	return h, nil
+}
+
+// Pack packs the event header.
+func (h *EventHeader) Pack() []byte {
+	buf := common.NewBuffer(EventHeaderSize)
+	buf.WriteU32(h.Timestamp)
+	buf.WriteU8(uint8(h.EventType))
+	buf.WriteU32(h.ServerID)
+	buf.WriteU32(h.EventSize)
+	buf.WriteU32(h.LogPos)
+	buf.WriteU16(h.Flags)
+	return buf.Datas()
+}
+
+// Event is the decoded event body.
+type Event interface {
+	// Decode decodes the event body after the header.
+	Decode(data []byte) error
+}
+
+// BinlogEvent is an event from the binlog stream.
+type BinlogEvent struct {
+	Header *EventHeader
+
+	// RawData is the whole event datas includes the header.
+	RawData []byte
+
+	// Event is the decoded body, it's a *RawEvent if the type is unsupported.
+	Event Event
+}
+
+// RawEvent is the event we don't decode.
+type RawEvent struct {
+	Data []byte
+}
+
+// Decode implements the Event interface.
+func (e *RawEvent) Decode(data []byte) error {
+	e.Data = data
+	return nil
+}
+
+// FormatDescriptionEvent is the first event of the binlog file.
+// https://dev.mysql.com/doc/internals/en/format-description-event.html
+type FormatDescriptionEvent struct {
+	Version           uint16
+	ServerVersion     string
+	CreateTimestamp   uint32
+	EventHeaderLength uint8
+	// EventTypeHeaderLengths is the post-header length of the event types, indexed by type-1.
+	EventTypeHeaderLengths []byte
+	// ChecksumAlgorithm is the checksum of the events in the binlog file,
+	// BINLOG_CHECKSUM_ALG_OFF if the server is too old to have it.
+	ChecksumAlgorithm uint8
+}
+
+// Decode implements the Event interface.
+func (e *FormatDescriptionEvent) Decode(data []byte) error {
+	var err error
+	var version string
+	buf := common.ReadBuffer(data)
+
+	if e.Version, err = buf.ReadU16(); err != nil {
+		return fmt.Errorf("replication.invalid.format.description.event.version:%v", data)
+	}
+	if version, err = buf.ReadString(50); err != nil {
+		return fmt.Errorf("replication.invalid.format.description.event.server.version:%v", data)
+	}
+	if i := strings.IndexByte(version, 0x00); i >= 0 {
+		version = version[:i]
+	}
+	e.ServerVersion = version
+	if e.CreateTimestamp, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.format.description.event.create.timestamp:%v", data)
+	}
+	if e.EventHeaderLength, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.format.description.event.header.length:%v", data)
+	}
+
+	// The checksum algorithm and the checksum of the event itself are at the end.
+	end := len(data)
+	e.ChecksumAlgorithm = BINLOG_CHECKSUM_ALG_OFF
+	if hasChecksumAlgorithm(e.ServerVersion) {
+		end -= 1 + BinlogChecksumLength
+		if end < buf.Seek() {
+			return fmt.Errorf("replication.invalid.format.description.event.checksum.algorithm:%v", data)
+		}
+		e.ChecksumAlgorithm = data[end]
+	}
+	e.EventTypeHeaderLengths = append([]byte(nil), data[buf.Seek():end]...)
+	return nil
+}
+
+// postHeaderLength returns the post-header length of the event type, the def if unknown.
+func (e *FormatDescriptionEvent) postHeaderLength(typ EventType, def int) int {
+	if e == nil || typ == 0 || int(typ) > len(e.EventTypeHeaderLengths) {
+		return def
+	}
+	return int(e.EventTypeHeaderLengths[typ-1])
+}
+
+// XIDEvent is the commit of the transaction.
+// https://dev.mysql.com/doc/internals/en/xid-event.html
+type XIDEvent struct {
+	XID uint64
+}
+
+// Decode implements the Event interface.
+func (e *XIDEvent) Decode(data []byte) error {
+	var err error
+	buf := common.ReadBuffer(data)
+	if e.XID, err = buf.ReadU64(); err != nil {
+		return fmt.Errorf("replication.invalid.xid.event:%v", data)
+	}
+	return nil
+}
+
+// RotateEvent tells the next binlog file.
+// https://dev.mysql.com/doc/internals/en/rotate-event.html
+type RotateEvent struct {
+	Position uint64
+	NextName string
+}
+
+// Decode implements the Event interface.
+func (e *RotateEvent) Decode(data []byte) error {
+	var err error
+	buf := common.ReadBuffer(data)
+	if e.Position, err = buf.ReadU64(); err != nil {
+		return fmt.Errorf("replication.invalid.rotate.event:%v", data)
+	}
+	e.NextName = string(data[8:])
+	return nil
+}
+
+// HeartbeatEvent is sent by the master if the binlog is idle for the heartbeat period,
+// the header log pos is the master position in the binlog file.
+// https://dev.mysql.com/doc/internals/en/heartbeat-event.html
+type HeartbeatEvent struct {
+	Filename string
+}
+
+// Decode implements the Event interface.
+func (e *HeartbeatEvent) Decode(data []byte) error {
+	e.Filename = string(data)
+	return nil
+}
+
+// QueryEvent is written for the statements like BEGIN and DDL, or the statement based DML.
+// https://dev.mysql.com/doc/internals/en/query-event.html
+type QueryEvent struct {
+	SlaveProxyID  uint32
+	ExecutionTime uint32
+	ErrorCode     uint16
+	StatusVars    []byte
+	Schema        string
+	Query         string
+}
+
+// Decode implements the Event interface.
+func (e *QueryEvent) Decode(data []byte) error {
+	var err error
+	var schemaLen uint8
+	var statusLen uint16
+	buf := common.ReadBuffer(data)
+
+	if e.SlaveProxyID, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.slave.proxy.id:%v", data)
+	}
+	if e.ExecutionTime, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.execution.time:%v", data)
+	}
+	if schemaLen, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.schema.length:%v", data)
+	}
+	if e.ErrorCode, err = buf.ReadU16(); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.error.code:%v", data)
+	}
+	if statusLen, err = buf.ReadU16(); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.status.vars.length:%v", data)
+	}
+	if e.StatusVars, err = buf.ReadBytes(int(statusLen)); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.status.vars:%v", data)
+	}
+	if e.Schema, err = buf.ReadString(int(schemaLen)); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.schema:%v", data)
+	}
+	if err = buf.ReadZero(1); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.schema:%v", data)
+	}
+	if e.Query, err = buf.ReadString(buf.Length() - buf.Seek()); err != nil {
+		return fmt.Errorf("replication.invalid.query.event.query:%v", data)
+	}
+	return nil
+}
+
+// GTIDEvent is written before every transaction with GTID_MODE=ON.
+type GTIDEvent struct {
+	CommitFlag uint8
+	SID        gtid.SID
+	GNO        int64
+
+	// LastCommitted and SequenceNumber are the logical clock of MySQL 5.7.
+	LastCommitted  int64
+	SequenceNumber int64
+}
+
+// Decode implements the Event interface.
+func (e *GTIDEvent) Decode(data []byte) error {
+	var err error
+	var sid []byte
+	var gno uint64
+	buf := common.ReadBuffer(data)
+
+	if e.CommitFlag, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.gtid.event.commit.flag:%v", data)
+	}
+	if sid, err = buf.ReadBytes(16); err != nil {
+		return fmt.Errorf("replication.invalid.gtid.event.sid:%v", data)
+	}
+	copy(e.SID[:], sid)
+	if gno, err = buf.ReadU64(); err != nil {
+		return fmt.Errorf("replication.invalid.gtid.event.gno:%v", data)
+	}
+	e.GNO = int64(gno)
+
+	// Logical timestamps since 5.7.
+	if lt, err := buf.ReadU8(); err == nil && lt == 2 {
+		var v uint64
+		if v, err = buf.ReadU64(); err != nil {
+			return fmt.Errorf("replication.invalid.gtid.event.last.committed:%v", data)
+		}
+		e.LastCommitted = int64(v)
+		if v, err = buf.ReadU64(); err != nil {
+			return fmt.Errorf("replication.invalid.gtid.event.sequence.number:%v", data)
+		}
+		e.SequenceNumber = int64(v)
+	}
+	return nil
+}
+
+// Position is the binlog file and the offset.
+type Position struct {
+	Name string
+	Pos  uint32
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("(%s, %d)", p.Name, p.Pos)
+}
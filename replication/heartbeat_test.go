@@ -0,0 +1,81 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSyncerHeartbeat(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	calls := 0
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		calls++
+		switch calls {
+		case 1:
+			writeEvents(packets,
+				makeRotate(0, "mysql-bin.000001", 4),
+				makeEvent(HEARTBEAT_EVENT, 100, []byte("mysql-bin.000001")),
+			)
+			// The master is dead without closing the connection.
+			time.Sleep(2 * time.Second)
+		case 2:
+			writeEvents(packets, makeXID(200, 1))
+			packets.Write([]byte{proto.EOF_PACKET})
+		}
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{
+		ServerID:         100,
+		Addr:             master.addr(),
+		User:             "repl",
+		NonBlock:         true,
+		ReconnectBackoff: time.Millisecond,
+		HeartbeatPeriod:  50 * time.Millisecond,
+	})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	for q := range master.queries {
+		if q == "SET @master_heartbeat_period=50000000" {
+			break
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var events []*BinlogEvent
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		events = append(events, ev)
+	}
+	assert.Equal(t, 3, len(events))
+	assert.Equal(t, &HeartbeatEvent{Filename: "mysql-bin.000001"}, events[1].Event)
+
+	// The heartbeat doesn't move the position.
+	<-master.dumps
+	dump := (<-master.dumps).(*proto.BinlogDump)
+	assert.Equal(t, uint32(4), dump.Position)
+	assert.Equal(t, Position{Name: "mysql-bin.000001", Pos: 200}, syncer.Position())
+}
@@ -0,0 +1,142 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+)
+
+// MariaDB event types.
+// https://mariadb.com/kb/en/library/2-binlog-event-header/
+const (
+	MARIADB_ANNOTATE_ROWS_EVENT EventType = 160 + iota
+	MARIADB_BINLOG_CHECKPOINT_EVENT
+	MARIADB_GTID_EVENT
+	MARIADB_GTID_LIST_EVENT
+	MARIADB_START_ENCRYPTION_EVENT
+)
+
+const (
+	// MARIADB_SLAVE_CAPABILITY_GTID is the @mariadb_slave_capability the master sends the GTID events to.
+	MARIADB_SLAVE_CAPABILITY_GTID = 4
+)
+
+// MariaDB GTID event flags.
+const (
+	// MARIADB_FL_STANDALONE is set if the event group is not in BEGIN/COMMIT, like the DDL.
+	MARIADB_FL_STANDALONE uint8 = 1
+
+	// MARIADB_FL_GROUP_COMMIT_ID is set if the commit id follows.
+	MARIADB_FL_GROUP_COMMIT_ID uint8 = 2
+)
+
+// MariadbGTIDEvent starts the event group, it replaces the BEGIN query.
+// https://mariadb.com/kb/en/library/gtid_event/
+type MariadbGTIDEvent struct {
+	// GTID.ServerID is set from the event header.
+	GTID     gtid.MariadbGTID
+	Flags    uint8
+	CommitID uint64
+}
+
+// Decode implements the Event interface.
+func (e *MariadbGTIDEvent) Decode(data []byte) error {
+	var err error
+	buf := common.ReadBuffer(data)
+
+	if e.GTID.SequenceNumber, err = buf.ReadU64(); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.gtid.event.sequence:%v", data)
+	}
+	if e.GTID.DomainID, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.gtid.event.domain:%v", data)
+	}
+	if e.Flags, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.gtid.event.flags:%v", data)
+	}
+	if e.Flags&MARIADB_FL_GROUP_COMMIT_ID > 0 {
+		if e.CommitID, err = buf.ReadU64(); err != nil {
+			return fmt.Errorf("replication.invalid.mariadb.gtid.event.commit.id:%v", data)
+		}
+	}
+	return nil
+}
+
+// Standalone returns true if the group is a single statement without COMMIT.
+func (e *MariadbGTIDEvent) Standalone() bool {
+	return e.Flags&MARIADB_FL_STANDALONE > 0
+}
+
+// MariadbGTIDListEvent is the GTID state at the beginning of the binlog file.
+// https://mariadb.com/kb/en/library/gtid_list_event/
+type MariadbGTIDListEvent struct {
+	GTIDs []gtid.MariadbGTID
+}
+
+// Decode implements the Event interface.
+func (e *MariadbGTIDListEvent) Decode(data []byte) error {
+	var err error
+	var n uint32
+	buf := common.ReadBuffer(data)
+
+	if n, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.gtid.list.event.count:%v", data)
+	}
+	// The upper 4 bits are the flags.
+	n &= 0x0fffffff
+	e.GTIDs = make([]gtid.MariadbGTID, 0, n)
+	for i := uint32(0); i < n; i++ {
+		var g gtid.MariadbGTID
+		if g.DomainID, err = buf.ReadU32(); err != nil {
+			return fmt.Errorf("replication.invalid.mariadb.gtid.list.event.domain:%v", data)
+		}
+		if g.ServerID, err = buf.ReadU32(); err != nil {
+			return fmt.Errorf("replication.invalid.mariadb.gtid.list.event.server:%v", data)
+		}
+		if g.SequenceNumber, err = buf.ReadU64(); err != nil {
+			return fmt.Errorf("replication.invalid.mariadb.gtid.list.event.sequence:%v", data)
+		}
+		e.GTIDs = append(e.GTIDs, g)
+	}
+	return nil
+}
+
+// MariadbAnnotateRowsEvent carries the query of the following rows events.
+type MariadbAnnotateRowsEvent struct {
+	Query string
+}
+
+// Decode implements the Event interface.
+func (e *MariadbAnnotateRowsEvent) Decode(data []byte) error {
+	e.Query = string(data)
+	return nil
+}
+
+// MariadbBinlogCheckpointEvent is the oldest binlog file needed by the crash recovery.
+type MariadbBinlogCheckpointEvent struct {
+	Filename string
+}
+
+// Decode implements the Event interface.
+func (e *MariadbBinlogCheckpointEvent) Decode(data []byte) error {
+	var err error
+	var n uint32
+	buf := common.ReadBuffer(data)
+
+	if n, err = buf.ReadU32(); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.binlog.checkpoint.event:%v", data)
+	}
+	if e.Filename, err = buf.ReadString(int(n)); err != nil {
+		return fmt.Errorf("replication.invalid.mariadb.binlog.checkpoint.event:%v", data)
+	}
+	return nil
+}
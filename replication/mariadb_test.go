@@ -0,0 +1,179 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+const mariadbVersion = "5.5.5-10.3.8-MariaDB-log"
+
+func makeMariadbGTID(logPos uint32, serverID uint32, domain uint32, seq uint64, flags uint8) []byte {
+	buf := common.NewBuffer(32)
+	buf.WriteU64(seq)
+	buf.WriteU32(domain)
+	buf.WriteU8(flags)
+	if flags&MARIADB_FL_GROUP_COMMIT_ID > 0 {
+		buf.WriteU64(seq + 1000)
+	} else {
+		buf.WriteZero(6)
+	}
+	ev := makeEvent(MARIADB_GTID_EVENT, logPos, buf.Datas())
+	// The server id in the header.
+	ev[5] = byte(serverID)
+	return ev
+}
+
+func TestParserMariadbEvents(t *testing.T) {
+	parser := NewBinlogParser()
+
+	// GTID.
+	{
+		ev, err := parser.Parse(makeMariadbGTID(100, 2, 1, 50, MARIADB_FL_GROUP_COMMIT_ID))
+		assert.Nil(t, err)
+		e := ev.Event.(*MariadbGTIDEvent)
+		assert.Equal(t, gtid.MariadbGTID{DomainID: 1, ServerID: 2, SequenceNumber: 50}, e.GTID)
+		assert.Equal(t, uint64(1050), e.CommitID)
+		assert.False(t, e.Standalone())
+		assert.Equal(t, "MariadbGTIDEvent", ev.Header.EventType.String())
+	}
+
+	// GTID list.
+	{
+		buf := common.NewBuffer(64)
+		buf.WriteU32(2)
+		for _, g := range []gtid.MariadbGTID{{DomainID: 0, ServerID: 1, SequenceNumber: 100}, {DomainID: 1, ServerID: 2, SequenceNumber: 49}} {
+			buf.WriteU32(g.DomainID)
+			buf.WriteU32(g.ServerID)
+			buf.WriteU64(g.SequenceNumber)
+		}
+		ev, err := parser.Parse(makeEvent(MARIADB_GTID_LIST_EVENT, 200, buf.Datas()))
+		assert.Nil(t, err)
+		e := ev.Event.(*MariadbGTIDListEvent)
+		assert.Equal(t, 2, len(e.GTIDs))
+		assert.Equal(t, "1-2-49", e.GTIDs[1].String())
+
+		_, err = parser.Parse(makeEvent(MARIADB_GTID_LIST_EVENT, 200, buf.Datas()[:20]))
+		assert.NotNil(t, err)
+	}
+
+	// Annotate rows and binlog checkpoint.
+	{
+		ev, err := parser.Parse(makeEvent(MARIADB_ANNOTATE_ROWS_EVENT, 300, []byte("INSERT INTO t1 VALUES(1)")))
+		assert.Nil(t, err)
+		assert.Equal(t, "INSERT INTO t1 VALUES(1)", ev.Event.(*MariadbAnnotateRowsEvent).Query)
+
+		buf := common.NewBuffer(32)
+		buf.WriteU32(16)
+		buf.WriteString("mysql-bin.000001")
+		ev, err = parser.Parse(makeEvent(MARIADB_BINLOG_CHECKPOINT_EVENT, 400, buf.Datas()))
+		assert.Nil(t, err)
+		assert.Equal(t, "mysql-bin.000001", ev.Event.(*MariadbBinlogCheckpointEvent).Filename)
+	}
+}
+
+func TestSyncerMariadbGTID(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	calls := 0
+	master := newMockMasterWith(t, mariadbVersion, nil, func(packets *packet.Packets, dump interface{}) {
+		calls++
+		switch calls {
+		case 1:
+			writeEvents(packets,
+				makeRotate(0, "mysql-bin.000001", 4),
+				makeMariadbGTID(100, 1, 0, 101, 0),
+				makeQuery(200, "db1", "INSERT INTO t1 VALUES(1)"),
+				makeQuery(300, "db1", "COMMIT"),
+				makeMariadbGTID(400, 1, 0, 102, 0),
+				makeQuery(500, "db1", "INSERT INTO t1 VALUES(2)"),
+			)
+			// Connection broken in the group.
+		case 2:
+			writeEvents(packets,
+				makeMariadbGTID(400, 1, 0, 102, 0),
+				makeQuery(500, "db1", "INSERT INTO t1 VALUES(2)"),
+				makeXID(600, 1),
+				makeMariadbGTID(700, 3, 1, 1, MARIADB_FL_STANDALONE),
+				makeQuery(800, "db1", "CREATE TABLE t2(a INT)"),
+			)
+			packets.Write([]byte{proto.EOF_PACKET})
+		}
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{
+		ServerID:         100,
+		Addr:             master.addr(),
+		User:             "repl",
+		NonBlock:         true,
+		ReconnectBackoff: time.Millisecond,
+	})
+	defer syncer.Close()
+
+	set, err := gtid.ParseMariadbSet("0-1-100")
+	assert.Nil(t, err)
+	streamer, err := syncer.StartSyncMariadbGTID(set)
+	assert.Nil(t, err)
+
+	assert.Equal(t, "SET @mariadb_slave_capability=4", <-master.queries)
+	assert.Equal(t, "SET @slave_connect_state='0-1-100'", <-master.queries)
+	dump := (<-master.dumps).(*proto.BinlogDump)
+	assert.Equal(t, uint32(4), dump.Position)
+	assert.Equal(t, "", dump.Filename)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		if _, err := streamer.GetEvent(ctx); err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+	}
+
+	// Resumed with the committed groups only.
+	for q := range master.queries {
+		if q == "SET @slave_connect_state='0-1-101'" {
+			break
+		}
+	}
+	<-master.dumps
+	assert.Equal(t, "0-1-102,1-3-1", syncer.MariadbGTIDSet().String())
+	assert.Nil(t, syncer.GTIDSet())
+	assert.Equal(t, "0-1-100", set.String())
+}
+
+func TestSyncerFlavorMismatch(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	noop := func(packets *packet.Packets, dump interface{}) {}
+
+	mysql := newMockMaster(t, noop)
+	defer mysql.listener.Close()
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: mysql.addr(), User: "repl"})
+	_, err := syncer.StartSyncMariadbGTID(gtid.NewMariadbSet())
+	assert.Equal(t, "replication.master[5.7.20-log].is.not.mariadb", err.Error())
+	syncer.Close()
+
+	mariadb := newMockMasterWith(t, mariadbVersion, nil, noop)
+	defer mariadb.listener.Close()
+	syncer = NewBinlogSyncer(log, &Config{ServerID: 100, Addr: mariadb.addr(), User: "repl"})
+	_, err = syncer.StartSyncGTID(gtid.NewSet())
+	assert.Equal(t, "replication.mariadb.master[5.5.5-10.3.8-MariaDB-log].does.not.support.mysql.gtid", err.Error())
+	syncer.Close()
+}
@@ -0,0 +1,176 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+)
+
+// BinlogParser decodes the binlog events of a stream,
+// it keeps the FORMAT_DESCRIPTION and the TABLE_MAPs for the following events.
+type BinlogParser struct {
+	format *FormatDescriptionEvent
+	tables *TableMapCache
+
+	// checksum is the algorithm used before the FORMAT_DESCRIPTION.
+	checksum uint8
+	verify   bool
+}
+
+// NewBinlogParser creates the parser.
+func NewBinlogParser() *BinlogParser {
+	return &BinlogParser{
+		tables:   NewTableMapCache(nil),
+		checksum: BINLOG_CHECKSUM_ALG_UNDEF,
+		verify:   true,
+	}
+}
+
+// SetChecksumAlgorithm sets the checksum algorithm of the events before the FORMAT_DESCRIPTION,
+// like the fake ROTATE sent by the master at the dump start.
+func (p *BinlogParser) SetChecksumAlgorithm(alg uint8) {
+	p.checksum = alg
+}
+
+// SetVerifyChecksum enables or disables the CRC32 verification, the checksum is stripped anyway.
+// It's enabled by default.
+func (p *BinlogParser) SetVerifyChecksum(verify bool) {
+	p.verify = verify
+}
+
+// checksumAlgorithm returns the algorithm of the current binlog file.
+func (p *BinlogParser) checksumAlgorithm() uint8 {
+	if p.format != nil {
+		return p.format.ChecksumAlgorithm
+	}
+	return p.checksum
+}
+
+// SetSchemaFetcher sets the fetcher to correlate the rows events with the column names.
+func (p *BinlogParser) SetSchemaFetcher(fetcher SchemaFetcher) {
+	p.tables = NewTableMapCache(fetcher)
+}
+
+// TableMapCache returns the table map cache of the stream.
+func (p *BinlogParser) TableMapCache() *TableMapCache {
+	return p.tables
+}
+
+// Parse decodes the event includes the header.
+func (p *BinlogParser) Parse(data []byte) (*BinlogEvent, error) {
+	header, err := UnPackEventHeader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body := data[EventHeaderSize:]
+	event := p.newEvent(header)
+
+	// The FORMAT_DESCRIPTION strips its checksum by itself.
+	if fde, ok := event.(*FormatDescriptionEvent); ok {
+		if err := fde.Decode(body); err != nil {
+			return nil, err
+		}
+		if fde.ChecksumAlgorithm == BINLOG_CHECKSUM_ALG_CRC32 && p.verify {
+			if err := verifyChecksum(header, data); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if p.checksumAlgorithm() == BINLOG_CHECKSUM_ALG_CRC32 {
+			if p.verify {
+				if err := verifyChecksum(header, data); err != nil {
+					return nil, err
+				}
+			}
+			if len(body) < BinlogChecksumLength {
+				return nil, fmt.Errorf("replication.event[%v].too.short.for.checksum:%v", header.EventType, data)
+			}
+			body = body[:len(body)-BinlogChecksumLength]
+		}
+		if err := event.Decode(body); err != nil {
+			return nil, err
+		}
+	}
+
+	switch e := event.(type) {
+	case *FormatDescriptionEvent:
+		p.format = e
+	case *TableMapEvent:
+		p.tables.Add(e)
+	case *RowsEvent:
+		if err := p.tables.correlate(e); err != nil {
+			return nil, err
+		}
+		// The STMT_END_F flag tells the table maps are released.
+		if e.Flags&0x0001 != 0 {
+			p.tables.Release()
+		}
+	case *MariadbGTIDEvent:
+		e.GTID.ServerID = header.ServerID
+	case *QueryEvent:
+		if sqlparser.Preview(e.Query) == sqlparser.StmtDDL {
+			p.tables.Invalidate("", "")
+		}
+	}
+	return &BinlogEvent{Header: header, RawData: data, Event: event}, nil
+}
+
+func (p *BinlogParser) newEvent(header *EventHeader) Event {
+	switch header.EventType {
+	case FORMAT_DESCRIPTION_EVENT:
+		return &FormatDescriptionEvent{}
+	case ROTATE_EVENT:
+		return &RotateEvent{}
+	case HEARTBEAT_EVENT:
+		return &HeartbeatEvent{}
+	case QUERY_EVENT:
+		return &QueryEvent{}
+	case XID_EVENT:
+		return &XIDEvent{}
+	case GTID_EVENT:
+		return &GTIDEvent{}
+	case MARIADB_GTID_EVENT:
+		return &MariadbGTIDEvent{}
+	case MARIADB_GTID_LIST_EVENT:
+		return &MariadbGTIDListEvent{}
+	case MARIADB_ANNOTATE_ROWS_EVENT:
+		return &MariadbAnnotateRowsEvent{}
+	case MARIADB_BINLOG_CHECKPOINT_EVENT:
+		return &MariadbBinlogCheckpointEvent{}
+	case TABLE_MAP_EVENT:
+		return &TableMapEvent{tableIDSize: p.tableIDSize(TABLE_MAP_EVENT)}
+	case WRITE_ROWS_EVENTv1, UPDATE_ROWS_EVENTv1, DELETE_ROWS_EVENTv1:
+		return &RowsEvent{
+			eventType:   header.EventType,
+			tableIDSize: p.tableIDSize(header.EventType),
+			tables:      p.tables,
+			Version:     1,
+		}
+	case WRITE_ROWS_EVENTv2, UPDATE_ROWS_EVENTv2, DELETE_ROWS_EVENTv2:
+		return &RowsEvent{
+			eventType:   header.EventType,
+			tableIDSize: p.tableIDSize(header.EventType),
+			tables:      p.tables,
+			Version:     2,
+		}
+	}
+	return &RawEvent{}
+}
+
+// tableIDSize is 4 if the post-header length is 6, otherwise 6.
+func (p *BinlogParser) tableIDSize(typ EventType) int {
+	if p.format.postHeaderLength(typ, 8) == 6 {
+		return 4
+	}
+	return 6
+}
@@ -0,0 +1,222 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func makeFormatDescription() []byte {
+	return makeFormatDescriptionWith("5.7.20-log", BINLOG_CHECKSUM_ALG_OFF)
+}
+
+// makeFormatDescriptionWith makes the FORMAT_DESCRIPTION with the checksum algorithm if the version has it.
+func makeFormatDescriptionWith(serverVersion string, alg uint8) []byte {
+	buf := common.NewBuffer(128)
+	buf.WriteU16(4)
+	version := make([]byte, 50)
+	copy(version, serverVersion)
+	buf.WriteBytes(version)
+	buf.WriteU32(0)
+	buf.WriteU8(EventHeaderSize)
+	lengths := make([]byte, PREVIOUS_GTIDS_EVENT)
+	lengths[TABLE_MAP_EVENT-1] = 8
+	lengths[WRITE_ROWS_EVENTv1-1] = 8
+	lengths[UPDATE_ROWS_EVENTv1-1] = 8
+	lengths[DELETE_ROWS_EVENTv1-1] = 8
+	lengths[WRITE_ROWS_EVENTv2-1] = 10
+	lengths[UPDATE_ROWS_EVENTv2-1] = 10
+	lengths[DELETE_ROWS_EVENTv2-1] = 10
+	buf.WriteBytes(lengths)
+	if !hasChecksumAlgorithm(serverVersion) {
+		return makeEvent(FORMAT_DESCRIPTION_EVENT, 120, buf.Datas())
+	}
+	buf.WriteU8(alg)
+	if alg != BINLOG_CHECKSUM_ALG_CRC32 {
+		buf.WriteZero(BinlogChecksumLength)
+		return makeEvent(FORMAT_DESCRIPTION_EVENT, 120, buf.Datas())
+	}
+	return withChecksum(makeEvent(FORMAT_DESCRIPTION_EVENT, 120, buf.Datas()))
+}
+
+// makeTableMap makes the table map of t1(id INT, name VARCHAR(20), price DECIMAL(10,2), ts DATETIME).
+func makeTableMap(tableID uint64) []byte {
+	buf := common.NewBuffer(128)
+	buf.WriteU32(uint32(tableID))
+	buf.WriteU16(uint16(tableID >> 32))
+	buf.WriteU16(1)
+	buf.WriteU8(3)
+	buf.WriteString("db1")
+	buf.WriteU8(0)
+	buf.WriteU8(2)
+	buf.WriteString("t1")
+	buf.WriteU8(0)
+	buf.WriteLenEncode(4)
+	buf.WriteBytes([]byte{MYSQL_TYPE_LONG, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_DATETIME2})
+	buf.WriteLenEncodeBytes([]byte{0x14, 0x00, 0x0a, 0x02, 0x00})
+	buf.WriteU8(0x0e)
+	return makeEvent(TABLE_MAP_EVENT, 200, buf.Datas())
+}
+
+func makeRows(typ EventType, tableID uint64, flags uint16, images ...[]byte) []byte {
+	buf := common.NewBuffer(128)
+	buf.WriteU32(uint32(tableID))
+	buf.WriteU16(uint16(tableID >> 32))
+	buf.WriteU16(flags)
+	if typ >= WRITE_ROWS_EVENTv2 {
+		buf.WriteU16(2)
+	}
+	buf.WriteLenEncode(4)
+	buf.WriteU8(0x0f)
+	if typ == UPDATE_ROWS_EVENTv1 || typ == UPDATE_ROWS_EVENTv2 {
+		buf.WriteU8(0x0f)
+	}
+	for _, image := range images {
+		buf.WriteBytes(image)
+	}
+	return makeEvent(typ, 300, buf.Datas())
+}
+
+func makeImage(id uint32, name string, null bool) []byte {
+	buf := common.NewBuffer(64)
+	if null {
+		buf.WriteU8(0x02)
+	} else {
+		buf.WriteU8(0x00)
+	}
+	buf.WriteU32(id)
+	if !null {
+		buf.WriteU8(uint8(len(name)))
+		buf.WriteString(name)
+	}
+	// 1234.56
+	buf.WriteBytes([]byte{0x80, 0x00, 0x04, 0xd2, 0x38})
+	// 2017-01-02 03:04:05
+	buf.WriteBytes([]byte{0x99, 0x9b, 0x84, 0x31, 0x05})
+	return buf.Datas()
+}
+
+func makeRow(id string, name string) []sqltypes.Value {
+	row := []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id)),
+		sqltypes.NULL,
+		sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("1234.56")),
+		sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2017-01-02 03:04:05")),
+	}
+	if name != "" {
+		row[1] = sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name))
+	}
+	return row
+}
+
+func TestParserRowsEvent(t *testing.T) {
+	parser := NewBinlogParser()
+
+	// Format description.
+	{
+		ev, err := parser.Parse(makeFormatDescription())
+		assert.Nil(t, err)
+		fde := ev.Event.(*FormatDescriptionEvent)
+		assert.Equal(t, uint16(4), fde.Version)
+		assert.Equal(t, "5.7.20-log", fde.ServerVersion)
+		assert.Equal(t, uint8(EventHeaderSize), fde.EventHeaderLength)
+	}
+
+	// Table map.
+	{
+		ev, err := parser.Parse(makeTableMap(0x010000000002))
+		assert.Nil(t, err)
+		tm := ev.Event.(*TableMapEvent)
+		assert.Equal(t, uint64(0x010000000002), tm.TableID)
+		assert.Equal(t, "db1", tm.Schema)
+		assert.Equal(t, "t1", tm.Table)
+		assert.Equal(t, []uint16{0, 20, 0x0a02, 0}, tm.ColumnMeta)
+		assert.False(t, tm.Nullable(0))
+		assert.True(t, tm.Nullable(1))
+	}
+
+	// Write rows v2.
+	{
+		ev, err := parser.Parse(makeRows(WRITE_ROWS_EVENTv2, 0x010000000002, 0, makeImage(1, "a", false), makeImage(2, "", true)))
+		assert.Nil(t, err)
+		rows := ev.Event.(*RowsEvent)
+		assert.True(t, rows.IsWrite())
+		assert.Equal(t, 2, rows.Version)
+		assert.Equal(t, "t1", rows.Table.Table)
+		assert.Equal(t, [][]sqltypes.Value{makeRow("1", "a"), makeRow("2", "")}, rows.Rows)
+	}
+
+	// Update rows v1 with the statement end flag.
+	{
+		ev, err := parser.Parse(makeRows(UPDATE_ROWS_EVENTv1, 0x010000000002, 1, makeImage(1, "a", false), makeImage(1, "b", false)))
+		assert.Nil(t, err)
+		rows := ev.Event.(*RowsEvent)
+		assert.True(t, rows.IsUpdate())
+		assert.Equal(t, [][]sqltypes.Value{makeRow("1", "a"), makeRow("1", "b")}, rows.Rows)
+	}
+
+	// The table maps are released after the statement end.
+	{
+		_, err := parser.Parse(makeRows(DELETE_ROWS_EVENTv2, 0x010000000002, 1, makeImage(1, "b", false)))
+		assert.NotNil(t, err)
+
+		_, err = parser.Parse(makeTableMap(0x010000000002))
+		assert.Nil(t, err)
+		ev, err := parser.Parse(makeRows(DELETE_ROWS_EVENTv2, 0x010000000002, 1, makeImage(1, "b", false)))
+		assert.Nil(t, err)
+		rows := ev.Event.(*RowsEvent)
+		assert.True(t, rows.IsDelete())
+		assert.Equal(t, [][]sqltypes.Value{makeRow("1", "b")}, rows.Rows)
+	}
+
+	// Truncated row.
+	{
+		_, err := parser.Parse(makeTableMap(3))
+		assert.Nil(t, err)
+		image := makeImage(1, "a", false)
+		_, err = parser.Parse(makeRows(WRITE_ROWS_EVENTv2, 3, 0, image[:len(image)-2]))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestParserEvents(t *testing.T) {
+	parser := NewBinlogParser()
+
+	ev, err := parser.Parse(makeXID(100, 99))
+	assert.Nil(t, err)
+	assert.Equal(t, &XIDEvent{XID: 99}, ev.Event)
+
+	ev, err = parser.Parse(makeQuery(200, "db1", "CREATE TABLE t1(a INT)"))
+	assert.Nil(t, err)
+	query := ev.Event.(*QueryEvent)
+	assert.Equal(t, "db1", query.Schema)
+	assert.Equal(t, "CREATE TABLE t1(a INT)", query.Query)
+
+	ev, err = parser.Parse(makeRotate(0, "mysql-bin.000002", 4))
+	assert.Nil(t, err)
+	assert.Equal(t, &RotateEvent{Position: 4, NextName: "mysql-bin.000002"}, ev.Event)
+
+	ev, err = parser.Parse(makeEvent(INTVAR_EVENT, 300, []byte{0x01}))
+	assert.Nil(t, err)
+	assert.Equal(t, &RawEvent{Data: []byte{0x01}}, ev.Event)
+
+	// Bad size.
+	data := makeXID(100, 99)
+	_, err = parser.Parse(data[:len(data)-1])
+	assert.NotNil(t, err)
+	_, err = parser.Parse(makeEvent(XID_EVENT, 100, []byte{0x01}))
+	assert.NotNil(t, err)
+}
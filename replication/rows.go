@@ -0,0 +1,279 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// TableMapEvent describes the table of the following rows events.
+// https://dev.mysql.com/doc/internals/en/table-map-event.html
+type TableMapEvent struct {
+	tableIDSize int
+
+	TableID     uint64
+	Flags       uint16
+	Schema      string
+	Table       string
+	ColumnCount uint64
+	ColumnTypes []byte
+	ColumnMeta  []uint16
+	NullBitmap  []byte
+}
+
+// Decode implements the Event interface.
+func (e *TableMapEvent) Decode(data []byte) error {
+	var err error
+	var n uint8
+	var raw []byte
+	buf := common.ReadBuffer(data)
+
+	if e.TableID, err = readTableID(buf, e.tableIDSize); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.table.id:%v", data)
+	}
+	if e.Flags, err = buf.ReadU16(); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.flags:%v", data)
+	}
+	if n, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.schema:%v", data)
+	}
+	if e.Schema, err = buf.ReadString(int(n)); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.schema:%v", data)
+	}
+	if err = buf.ReadZero(1); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.schema:%v", data)
+	}
+	if n, err = buf.ReadU8(); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.table:%v", data)
+	}
+	if e.Table, err = buf.ReadString(int(n)); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.table:%v", data)
+	}
+	if err = buf.ReadZero(1); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.table:%v", data)
+	}
+	if e.ColumnCount, err = buf.ReadLenEncode(); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.column.count:%v", data)
+	}
+	if raw, err = buf.ReadBytes(int(e.ColumnCount)); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.column.types:%v", data)
+	}
+	e.ColumnTypes = append([]byte(nil), raw...)
+	if raw, err = buf.ReadLenEncodeBytes(); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.column.meta:%v", data)
+	}
+	if e.ColumnMeta, err = e.decodeMeta(raw); err != nil {
+		return err
+	}
+	if raw, err = buf.ReadBytes(int(e.ColumnCount+7) / 8); err != nil {
+		return fmt.Errorf("replication.invalid.table.map.event.null.bitmap:%v", data)
+	}
+	e.NullBitmap = append([]byte(nil), raw...)
+	return nil
+}
+
+func (e *TableMapEvent) decodeMeta(data []byte) ([]uint16, error) {
+	pos := 0
+	meta := make([]uint16, e.ColumnCount)
+	for i, typ := range e.ColumnTypes {
+		size := 0
+		switch typ {
+		case MYSQL_TYPE_FLOAT, MYSQL_TYPE_DOUBLE, MYSQL_TYPE_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON,
+			MYSQL_TYPE_TIMESTAMP2, MYSQL_TYPE_DATETIME2, MYSQL_TYPE_TIME2:
+			size = 1
+		case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_BIT, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_STRING, MYSQL_TYPE_VAR_STRING,
+			MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+			size = 2
+		}
+		if pos+size > len(data) {
+			return nil, fmt.Errorf("replication.invalid.table.map.event.column[%d].meta:%v", i, data)
+		}
+
+		switch {
+		case size == 1:
+			meta[i] = uint16(data[pos])
+		case typ == MYSQL_TYPE_VARCHAR:
+			meta[i] = uint16(data[pos]) | uint16(data[pos+1])<<8
+		case size == 2:
+			// The NEWDECIMAL is (precision, scale), the STRING is (real type, length).
+			meta[i] = uint16(data[pos])<<8 | uint16(data[pos+1])
+		}
+		pos += size
+	}
+	return meta, nil
+}
+
+// Nullable checks the column is nullable.
+func (e *TableMapEvent) Nullable(i int) bool {
+	return bitSet(e.NullBitmap, i)
+}
+
+// RowsEvent is the WRITE/UPDATE/DELETE_ROWS event v1 or v2.
+// https://dev.mysql.com/doc/internals/en/rows-event.html
+type RowsEvent struct {
+	eventType   EventType
+	tableIDSize int
+	tables      *TableMapCache
+
+	// Version is 1 or 2.
+	Version   int
+	TableID   uint64
+	Flags     uint16
+	ExtraData []byte
+
+	// Table is the TABLE_MAP of this event.
+	Table       *TableMapEvent
+	ColumnCount uint64
+
+	// Rows is the row images in the order of the event,
+	// UPDATE has the before image followed by the after image for every row.
+	// The columns absent from the image are NULL.
+	Rows [][]sqltypes.Value
+
+	// Columns are the correlated columns of the table, nil if the parser has no schema fetcher.
+	Columns []*SchemaColumn
+}
+
+// Decode implements the Event interface.
+func (e *RowsEvent) Decode(data []byte) error {
+	var err error
+	var n uint16
+	buf := common.ReadBuffer(data)
+
+	if e.TableID, err = readTableID(buf, e.tableIDSize); err != nil {
+		return fmt.Errorf("replication.invalid.rows.event.table.id:%v", data)
+	}
+	if e.Flags, err = buf.ReadU16(); err != nil {
+		return fmt.Errorf("replication.invalid.rows.event.flags:%v", data)
+	}
+	if e.Version == 2 {
+		// The length includes itself.
+		if n, err = buf.ReadU16(); err != nil || n < 2 {
+			return fmt.Errorf("replication.invalid.rows.event.extra.data:%v", data)
+		}
+		if e.ExtraData, err = buf.ReadBytes(int(n - 2)); err != nil {
+			return fmt.Errorf("replication.invalid.rows.event.extra.data:%v", data)
+		}
+	}
+
+	table, ok := e.tables.Get(e.TableID)
+	if !ok {
+		return fmt.Errorf("replication.rows.event.table.id[%d].has.no.table.map", e.TableID)
+	}
+	e.Table = table
+
+	if e.ColumnCount, err = buf.ReadLenEncode(); err != nil {
+		return fmt.Errorf("replication.invalid.rows.event.column.count:%v", data)
+	}
+	if e.ColumnCount > uint64(len(table.ColumnTypes)) {
+		return fmt.Errorf("replication.rows.event.column.count[%d].table.map.column.count[%d]", e.ColumnCount, len(table.ColumnTypes))
+	}
+	bitmapSize := int(e.ColumnCount+7) / 8
+	present1, err := buf.ReadBytes(bitmapSize)
+	if err != nil {
+		return fmt.Errorf("replication.invalid.rows.event.columns.bitmap:%v", data)
+	}
+	present2 := present1
+	if e.IsUpdate() {
+		if present2, err = buf.ReadBytes(bitmapSize); err != nil {
+			return fmt.Errorf("replication.invalid.rows.event.columns.bitmap:%v", data)
+		}
+	}
+
+	rows := data[buf.Seek():]
+	for len(rows) > 0 {
+		row, size, err := e.decodeRow(rows, present1)
+		if err != nil {
+			return err
+		}
+		e.Rows = append(e.Rows, row)
+		rows = rows[size:]
+
+		if e.IsUpdate() {
+			if row, size, err = e.decodeRow(rows, present2); err != nil {
+				return err
+			}
+			e.Rows = append(e.Rows, row)
+			rows = rows[size:]
+		}
+	}
+	return nil
+}
+
+// IsWrite checks the event is WRITE_ROWS.
+func (e *RowsEvent) IsWrite() bool {
+	return e.eventType == WRITE_ROWS_EVENTv1 || e.eventType == WRITE_ROWS_EVENTv2
+}
+
+// IsDelete checks the event is DELETE_ROWS.
+func (e *RowsEvent) IsDelete() bool {
+	return e.eventType == DELETE_ROWS_EVENTv1 || e.eventType == DELETE_ROWS_EVENTv2
+}
+
+// IsUpdate checks the event is UPDATE_ROWS.
+func (e *RowsEvent) IsUpdate() bool {
+	return e.eventType == UPDATE_ROWS_EVENTv1 || e.eventType == UPDATE_ROWS_EVENTv2
+}
+
+func (e *RowsEvent) decodeRow(data []byte, present []byte) ([]sqltypes.Value, int, error) {
+	count := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if bitSet(present, i) {
+			count++
+		}
+	}
+
+	nullSize := (count + 7) / 8
+	if len(data) < nullSize {
+		return nil, 0, fmt.Errorf("replication.invalid.rows.event.null.bitmap:%v", data)
+	}
+	nulls := data[:nullSize]
+	pos := nullSize
+
+	row := make([]sqltypes.Value, e.ColumnCount)
+	k := 0
+	for i := 0; i < int(e.ColumnCount); i++ {
+		if !bitSet(present, i) {
+			continue
+		}
+		if bitSet(nulls, k) {
+			k++
+			continue
+		}
+		k++
+
+		v, n, err := decodeValue(data[pos:], e.Table.ColumnTypes[i], e.Table.ColumnMeta[i])
+		if err != nil {
+			return nil, 0, fmt.Errorf("replication.rows.event.%s.%s.column[%d]:%v", e.Table.Schema, e.Table.Table, i, err)
+		}
+		row[i] = v
+		pos += n
+	}
+	return row, pos, nil
+}
+
+func readTableID(buf *common.Buffer, size int) (uint64, error) {
+	if size == 4 {
+		v, err := buf.ReadU32()
+		return uint64(v), err
+	}
+	raw, err := buf.ReadBytes(6)
+	if err != nil {
+		return 0, err
+	}
+	return readLittleEndian(raw), nil
+}
+
+func bitSet(bitmap []byte, i int) bool {
+	return bitmap[i/8]&(1<<uint(i%8)) != 0
+}
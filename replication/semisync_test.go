@@ -0,0 +1,101 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSemiSyncEvent(packets *packet.Packets, ev []byte, needAck bool) {
+	var flag byte
+	if needAck {
+		flag = proto.SEMI_SYNC_ACK_REQUIRED
+	}
+	packets.Write(append([]byte{proto.OK_PACKET, proto.SEMI_SYNC_INDICATOR, flag}, ev...))
+}
+
+func TestSyncerSemiSync(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	acks := make(chan *proto.SemiSyncAck, 4)
+	vars := map[string]string{"rpl_semi_sync_master_enabled": "ON"}
+	master := newMockMasterWith(t, "5.7.20-log", vars, func(packets *packet.Packets, dump interface{}) {
+		writeSemiSyncEvent(packets, makeRotate(0, "mysql-bin.000001", 4), false)
+		writeSemiSyncEvent(packets, makeQuery(200, "db1", "BEGIN"), false)
+		writeSemiSyncEvent(packets, makeXID(300, 1), true)
+
+		// The ACK starts a new sequence.
+		packets.ResetSeq()
+		data, err := packets.Next()
+		if err != nil || data[0] != proto.SEMI_SYNC_INDICATOR {
+			return
+		}
+		ack, err := proto.UnPackSemiSyncAck(data[1:])
+		if err != nil {
+			return
+		}
+		acks <- ack
+		writeSemiSyncEvent(packets, makeQuery(400, "db1", "BEGIN"), false)
+		packets.Write([]byte{proto.EOF_PACKET})
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl", NonBlock: true, SemiSync: true})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	var queries []string
+	for len(queries) < 2 {
+		if q := <-master.queries; q != "SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'" {
+			queries = append(queries, q)
+		}
+	}
+	assert.Equal(t, []string{"SHOW VARIABLES LIKE 'rpl_semi_sync_master_enabled'", "SET @rpl_semi_sync_slave=1"}, queries)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var types []EventType
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		types = append(types, ev.Header.EventType)
+	}
+	assert.Equal(t, []EventType{ROTATE_EVENT, QUERY_EVENT, XID_EVENT, QUERY_EVENT}, types)
+	assert.Equal(t, &proto.SemiSyncAck{Position: 300, Filename: "mysql-bin.000001"}, <-acks)
+}
+
+func TestSyncerSemiSyncNotEnabled(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		writeEvents(packets, makeXID(300, 1))
+		packets.Write([]byte{proto.EOF_PACKET})
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl", NonBlock: true, SemiSync: true})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	ev, err := streamer.GetEvent(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), ev.Event.(*XIDEvent).XID)
+}
@@ -0,0 +1,374 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"errors"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+var (
+	// ErrBinlogServerClosed returned by the binlog server after closed.
+	ErrBinlogServerClosed = errors.New("replication.binlog.server.closed")
+
+	// ErrNoBinlogFile returned by Append if the event comes before the first ROTATE.
+	ErrNoBinlogFile = errors.New("replication.binlog.server.no.binlog.file")
+)
+
+// BinlogServerConfig is the binlog server config.
+type BinlogServerConfig struct {
+	// ServerID is written to the events generated by the server, like the fake ROTATE and the HEARTBEAT.
+	ServerID uint32
+
+	// HeartbeatPeriod is the idle period to send the HEARTBEAT_EVENT to the slaves, 0 disables it.
+	HeartbeatPeriod time.Duration
+}
+
+// binlogFile is the events of a binlog file, the positions are the ones in the events header.
+type binlogFile struct {
+	name     string
+	checksum uint8
+	format   *BinlogEvent
+	events   []*BinlogEvent
+	// end is the log pos of the last event.
+	end uint32
+}
+
+// BinlogServer keeps the binlog events supplied by the application, and serves the binlog dumps of the slaves,
+// the events are usually relayed from an upstream by the BinlogSyncer.
+// It implements the driver.BinlogDumpHandler, the application handler embeds it to act as a master.
+// The events are kept in memory until purged.
+type BinlogServer struct {
+	log  *xlog.Log
+	cfg  *BinlogServerConfig
+	quit chan struct{}
+
+	mu     sync.Mutex
+	files  []*binlogFile
+	closed bool
+	// notify is closed and renewed once events appended, to wake up the waiting dumps.
+	notify chan struct{}
+}
+
+// NewBinlogServer creates the binlog server.
+func NewBinlogServer(log *xlog.Log, cfg *BinlogServerConfig) *BinlogServer {
+	return &BinlogServer{
+		log:    log,
+		cfg:    cfg,
+		quit:   make(chan struct{}),
+		notify: make(chan struct{}),
+	}
+}
+
+// Append appends the event to the current binlog file, the event RawData must be the whole event includes the checksum.
+// The ROTATE starts a new binlog file, the artificial one only sets the current file.
+// The HEARTBEAT and the artificial FORMAT_DESCRIPTION are dropped.
+func (s *BinlogServer) Append(ev *BinlogEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrBinlogServerClosed
+	}
+
+	artificial := ev.Header.LogPos == 0 || ev.Header.Flags&LOG_EVENT_ARTIFICIAL_F != 0
+	switch e := ev.Event.(type) {
+	case *HeartbeatEvent:
+		return nil
+	case *RotateEvent:
+		if !artificial {
+			if err := s.append(ev); err != nil {
+				return err
+			}
+		}
+		s.rotate(e.NextName)
+	case *FormatDescriptionEvent:
+		if artificial {
+			return nil
+		}
+		if err := s.append(ev); err != nil {
+			return err
+		}
+		file := s.files[len(s.files)-1]
+		file.format = ev
+		file.checksum = e.ChecksumAlgorithm
+	default:
+		if err := s.append(ev); err != nil {
+			return err
+		}
+	}
+
+	close(s.notify)
+	s.notify = make(chan struct{})
+	return nil
+}
+
+func (s *BinlogServer) append(ev *BinlogEvent) error {
+	if len(s.files) == 0 {
+		return ErrNoBinlogFile
+	}
+	file := s.files[len(s.files)-1]
+	file.events = append(file.events, ev)
+	file.end = ev.Header.LogPos
+	return nil
+}
+
+// rotate sets the current binlog file, the file is created if it's not the current one.
+func (s *BinlogServer) rotate(name string) {
+	if n := len(s.files); n > 0 && s.files[n-1].name == name {
+		return
+	}
+	s.files = append(s.files, &binlogFile{name: name, checksum: BINLOG_CHECKSUM_ALG_OFF, end: 4})
+}
+
+// Position returns the end of the binlog.
+func (s *BinlogServer) Position() Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.files) == 0 {
+		return Position{}
+	}
+	file := s.files[len(s.files)-1]
+	return Position{Name: file.name, Pos: file.end}
+}
+
+// Purge drops the binlog files before the named one, the current file is always kept.
+func (s *BinlogServer) Purge(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, file := range s.files {
+		if file.name == name {
+			s.files = s.files[i:]
+			return
+		}
+	}
+}
+
+// Close closes the server, the dumps in progress are ended with the error.
+func (s *BinlogServer) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	close(s.quit)
+}
+
+// ComBinlogDump implements the driver.BinlogDumpHandler.
+func (s *BinlogServer) ComBinlogDump(session *driver.Session, dump *proto.BinlogDump, stream *driver.BinlogStream) error {
+	s.log.Info("replication.binlog.server.slave[%v].dump.from(%s, %d)", dump.ServerID, dump.Filename, dump.Position)
+	return s.serve(stream, dump.Filename, dump.Position, dump.Flags, nil)
+}
+
+// ComBinlogDumpGTID implements the driver.BinlogDumpHandler, the transactions in the slave gtid set are skipped.
+func (s *BinlogServer) ComBinlogDumpGTID(session *driver.Session, dump *proto.BinlogDumpGTID, stream *driver.BinlogStream) error {
+	executed := gtid.NewSet()
+	if dump.Flags&proto.BINLOG_THROUGH_GTID != 0 {
+		var err error
+		if executed, err = gtid.DecodeSet(dump.SIDData); err != nil {
+			return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "%v", err)
+		}
+	}
+	s.log.Info("replication.binlog.server.slave[%v].dump.gtid[%v]", dump.ServerID, executed)
+	pos := uint32(dump.Position)
+	if pos < 4 {
+		pos = 4
+	}
+	return s.serve(stream, dump.Filename, pos, dump.Flags, executed)
+}
+
+// cursor is the events snapshot of a binlog file.
+type cursor struct {
+	events []*BinlogEvent
+	// next is the next file, nil if it's the current file.
+	next   *binlogFile
+	notify chan struct{}
+}
+
+// end returns the log pos of the last event in the snapshot.
+func (c *cursor) end() uint32 {
+	if n := len(c.events); n > 0 {
+		return c.events[n-1].Header.LogPos
+	}
+	return 4
+}
+
+// snapshot returns the cursor of the file, ok is false if the file is purged.
+func (s *BinlogServer) snapshot(file *binlogFile) (*cursor, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.files {
+		if f != file {
+			continue
+		}
+		c := &cursor{events: f.events, notify: s.notify}
+		if i+1 < len(s.files) {
+			c.next = s.files[i+1]
+		}
+		return c, true
+	}
+	return nil, false
+}
+
+// find returns the file by the name, the first file if the name is empty.
+func (s *BinlogServer) find(name string) *binlogFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.files {
+		if name == "" || f.name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+// serve streams the events from the position, the transactions in the executed are skipped if it's not nil.
+func (s *BinlogServer) serve(stream *driver.BinlogStream, name string, pos uint32, flags uint16, executed *gtid.Set) error {
+	file := s.find(name)
+	if file == nil {
+		return sqldb.NewSQLError1(sqldb.ER_MASTER_FATAL_ERROR_READING_BINLOG, "HY000", "Could not find first log file name in binary log index file")
+	}
+
+	var heartbeat <-chan time.Time
+	if s.cfg.HeartbeatPeriod > 0 {
+		ticker := time.NewTicker(s.cfg.HeartbeatPeriod)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	sent, skipping, lastType := 0, false, UNKNOWN_EVENT
+	if err := s.writeFileStart(stream, file, pos); err != nil {
+		return err
+	}
+	for {
+		c, ok := s.snapshot(file)
+		if !ok {
+			return sqldb.NewSQLError1(sqldb.ER_MASTER_FATAL_ERROR_READING_BINLOG, "HY000", "binlog file '%s' is purged", file.name)
+		}
+
+		for _, ev := range c.events[sent:] {
+			sent++
+			if ev.Header.LogPos-ev.Header.EventSize < pos {
+				continue
+			}
+			if executed != nil {
+				if e, ok := ev.Event.(*GTIDEvent); ok {
+					skipping = executed.ContainsGTID(e.SID, e.GNO)
+				}
+				if skipping && !isFileEvent(ev.Header.EventType) {
+					continue
+				}
+			}
+			if err := stream.WriteEvent(ev.RawData); err != nil {
+				return err
+			}
+			lastType = ev.Header.EventType
+		}
+		if sent < len(c.events) {
+			continue
+		}
+
+		if next := c.next; next != nil {
+			// The file is switched without a real ROTATE, tells the slave by the fake one.
+			if lastType != ROTATE_EVENT {
+				if err := s.writeFileStart(stream, next, 4); err != nil {
+					return err
+				}
+			}
+			file, pos, sent, lastType = next, 4, 0, UNKNOWN_EVENT
+			continue
+		}
+
+		if flags&proto.BINLOG_DUMP_NON_BLOCK != 0 {
+			return nil
+		}
+		select {
+		case <-c.notify:
+		case <-heartbeat:
+			if err := stream.WriteEvent(s.makeHeartbeat(file.name, file.checksum, c.end())); err != nil {
+				return err
+			}
+		case <-s.quit:
+			return ErrBinlogServerClosed
+		}
+	}
+}
+
+// isFileEvent checks the event describes the binlog file, which is sent even the transaction is skipped.
+func isFileEvent(typ EventType) bool {
+	switch typ {
+	case ROTATE_EVENT, FORMAT_DESCRIPTION_EVENT, PREVIOUS_GTIDS_EVENT, STOP_EVENT:
+		return true
+	}
+	return false
+}
+
+// writeFileStart writes the fake ROTATE to tell the slave the file and position,
+// and the FORMAT_DESCRIPTION with log pos 0 if the position is in the middle of the file.
+func (s *BinlogServer) writeFileStart(stream *driver.BinlogStream, file *binlogFile, pos uint32) error {
+	s.mu.Lock()
+	format, checksum := file.format, file.checksum
+	s.mu.Unlock()
+
+	buf := common.NewBuffer(64)
+	buf.WriteU64(uint64(pos))
+	buf.WriteString(file.name)
+	header := &EventHeader{EventType: ROTATE_EVENT, ServerID: s.cfg.ServerID, Flags: LOG_EVENT_ARTIFICIAL_F}
+	if err := stream.WriteEvent(packEvent(header, buf.Datas(), checksum)); err != nil {
+		return err
+	}
+
+	if pos <= 4 || format == nil {
+		return nil
+	}
+	data := make([]byte, len(format.RawData))
+	copy(data, format.RawData)
+	// Clears the log pos to tell the slave not to update its position.
+	copy(data[13:17], []byte{0, 0, 0, 0})
+	if checksum == BINLOG_CHECKSUM_ALG_CRC32 {
+		n := len(data) - BinlogChecksumLength
+		crc := common.NewBuffer(BinlogChecksumLength)
+		crc.WriteU32(crc32.ChecksumIEEE(data[:n]))
+		copy(data[n:], crc.Datas())
+	}
+	return stream.WriteEvent(data)
+}
+
+// makeHeartbeat makes the HEARTBEAT_EVENT with the master position.
+func (s *BinlogServer) makeHeartbeat(name string, checksum uint8, pos uint32) []byte {
+	header := &EventHeader{EventType: HEARTBEAT_EVENT, ServerID: s.cfg.ServerID, LogPos: pos, Flags: LOG_EVENT_ARTIFICIAL_F}
+	return packEvent(header, []byte(name), checksum)
+}
+
+// packEvent packs the event with the header size and the checksum filled.
+func packEvent(header *EventHeader, body []byte, checksum uint8) []byte {
+	size := EventHeaderSize + len(body)
+	if checksum == BINLOG_CHECKSUM_ALG_CRC32 {
+		size += BinlogChecksumLength
+	}
+	header.EventSize = uint32(size)
+
+	buf := common.NewBuffer(size)
+	buf.WriteBytes(header.Pack())
+	buf.WriteBytes(body)
+	if checksum == BINLOG_CHECKSUM_ALG_CRC32 {
+		buf.WriteU32(crc32.ChecksumIEEE(buf.Datas()))
+	}
+	return buf.Datas()
+}
@@ -0,0 +1,202 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+)
+
+// newMockBinlogServer creates the binlog server with two binlog files, and the listener serves it.
+func newMockBinlogServer(t *testing.T, cfg *BinlogServerConfig) (*BinlogServer, *driver.Listener) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	sid, err := gtid.ParseSID("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	assert.Nil(t, err)
+
+	bs := NewBinlogServer(log, cfg)
+	parser := NewBinlogParser()
+	for _, data := range [][]byte{
+		makeRotate(0, "mysql-bin.000001", 4),
+		makeFormatDescription(),
+		makeGTID(200, sid, 1),
+		makeQuery(300, "db1", "BEGIN"),
+		makeXID(400, 1),
+		makeGTID(500, sid, 2),
+		makeQuery(600, "db1", "BEGIN"),
+		makeXID(700, 2),
+		makeRotate(750, "mysql-bin.000002", 4),
+		makeFormatDescription(),
+		makeEvent(HEARTBEAT_EVENT, 120, []byte("mysql-bin.000002")),
+		makeGTID(200, sid, 3),
+		makeQuery(300, "db1", "BEGIN"),
+		makeXID(400, 3),
+	} {
+		ev, err := parser.Parse(data)
+		assert.Nil(t, err)
+		assert.Nil(t, bs.Append(ev))
+	}
+
+	th := driver.NewTestHandler(log)
+	th.AddQuery("SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'", &sqltypes.Result{})
+	th.AddQuery("SET @master_heartbeat_period=20000000", &sqltypes.Result{})
+	svr, err := driver.MockMysqlServer(log, struct {
+		*driver.TestHandler
+		*BinlogServer
+	}{th, bs})
+	assert.Nil(t, err)
+	return bs, svr
+}
+
+// syncAll returns the event types until the stream ended.
+func syncAll(t *testing.T, streamer *BinlogStreamer) ([]EventType, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var types []EventType
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			return types, err
+		}
+		types = append(types, ev.Header.EventType)
+	}
+}
+
+func TestBinlogServerDump(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	bs, svr := newMockBinlogServer(t, &BinlogServerConfig{ServerID: 1})
+	defer svr.Close()
+	defer bs.Close()
+	assert.Equal(t, Position{Name: "mysql-bin.000002", Pos: 400}, bs.Position())
+
+	// The server ids are different as the slaves are registered until the sessions closed.
+	newSyncer := func(serverID uint32) *BinlogSyncer {
+		return NewBinlogSyncer(log, &Config{ServerID: serverID, Addr: svr.Addr(), User: "mock", NonBlock: true, MaxReconnectAttempts: -1})
+	}
+
+	// Position mode from the middle of the first file.
+	{
+		syncer := newSyncer(100)
+		defer syncer.Close()
+		streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 400})
+		assert.Nil(t, err)
+		types, err := syncAll(t, streamer)
+		assert.Equal(t, io.EOF, err)
+		want := []EventType{
+			ROTATE_EVENT, FORMAT_DESCRIPTION_EVENT, GTID_EVENT, QUERY_EVENT, XID_EVENT, ROTATE_EVENT,
+			FORMAT_DESCRIPTION_EVENT, GTID_EVENT, QUERY_EVENT, XID_EVENT,
+		}
+		assert.Equal(t, want, types)
+		assert.Equal(t, Position{Name: "mysql-bin.000002", Pos: 400}, syncer.Position())
+	}
+
+	// GTID mode skips the executed transactions.
+	{
+		syncer := newSyncer(101)
+		defer syncer.Close()
+		set, err := gtid.ParseSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-2")
+		assert.Nil(t, err)
+		streamer, err := syncer.StartSyncGTID(set)
+		assert.Nil(t, err)
+		types, err := syncAll(t, streamer)
+		assert.Equal(t, io.EOF, err)
+		want := []EventType{
+			ROTATE_EVENT, FORMAT_DESCRIPTION_EVENT, ROTATE_EVENT,
+			FORMAT_DESCRIPTION_EVENT, GTID_EVENT, QUERY_EVENT, XID_EVENT,
+		}
+		assert.Equal(t, want, types)
+		assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-3", syncer.GTIDSet().String())
+	}
+
+	// Unknown file.
+	{
+		syncer := newSyncer(102)
+		defer syncer.Close()
+		streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000009", Pos: 4})
+		assert.Nil(t, err)
+		_, err = syncAll(t, streamer)
+		sqlErr, ok := err.(*sqldb.SQLError)
+		assert.True(t, ok)
+		assert.Equal(t, uint16(sqldb.ER_MASTER_FATAL_ERROR_READING_BINLOG), sqlErr.Num)
+	}
+}
+
+func TestBinlogServerFollow(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	bs, svr := newMockBinlogServer(t, &BinlogServerConfig{ServerID: 1, HeartbeatPeriod: 20 * time.Millisecond})
+	defer svr.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{
+		ServerID:             100,
+		Addr:                 svr.Addr(),
+		User:                 "mock",
+		MaxReconnectAttempts: -1,
+		HeartbeatPeriod:      20 * time.Millisecond,
+	})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(bs.Position())
+	assert.Nil(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	next := func() *BinlogEvent {
+		ev, err := streamer.GetEvent(ctx)
+		assert.Nil(t, err)
+		return ev
+	}
+
+	// The fake ROTATE, the FORMAT_DESCRIPTION of the file, and the heartbeats if idle.
+	assert.Equal(t, &RotateEvent{Position: 400, NextName: "mysql-bin.000002"}, next().Event)
+	assert.Equal(t, uint32(0), next().Header.LogPos)
+	ev := next()
+	assert.Equal(t, &HeartbeatEvent{Filename: "mysql-bin.000002"}, ev.Event)
+	assert.Equal(t, uint32(400), ev.Header.LogPos)
+
+	// The appended events are streamed.
+	parser := NewBinlogParser()
+	for _, data := range [][]byte{makeQuery(500, "db1", "CREATE TABLE t1(a INT)"), makeRotate(550, "mysql-bin.000003", 4)} {
+		ev, err := parser.Parse(data)
+		assert.Nil(t, err)
+		assert.Nil(t, bs.Append(ev))
+	}
+	for {
+		ev := next()
+		if ev.Header.EventType == ROTATE_EVENT {
+			break
+		}
+	}
+	assert.Equal(t, Position{Name: "mysql-bin.000003", Pos: 4}, syncer.Position())
+
+	// The dumps are ended by the server close.
+	bs.Close()
+	for {
+		if _, err := streamer.GetEvent(ctx); err != nil {
+			assert.NotNil(t, err)
+			break
+		}
+	}
+	assert.Equal(t, ErrBinlogServerClosed, bs.Append(&BinlogEvent{}))
+}
+
+func TestBinlogServerNoFile(t *testing.T) {
+	bs := NewBinlogServer(xlog.NewStdLog(xlog.Level(xlog.ERROR)), &BinlogServerConfig{})
+	ev, err := NewBinlogParser().Parse(makeXID(100, 1))
+	assert.Nil(t, err)
+	assert.Equal(t, ErrNoBinlogFile, bs.Append(ev))
+	assert.Equal(t, Position{}, bs.Position())
+}
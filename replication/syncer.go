@@ -0,0 +1,625 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+)
+
+var (
+	// ErrSyncerClosed returned by the streamer after the syncer closed.
+	ErrSyncerClosed = errors.New("replication.syncer.closed")
+
+	// ErrSyncerRunning returned if StartSync called twice.
+	ErrSyncerRunning = errors.New("replication.syncer.already.running")
+)
+
+// Config is the replication client config.
+type Config struct {
+	// ServerID is the slave server id, it must be unique in the replication topology.
+	ServerID uint32
+	Addr     string
+	User     string
+	Password string
+
+	// ReportHost and ReportPort are shown in SHOW SLAVE HOSTS.
+	ReportHost string
+	ReportPort uint16
+
+	// NonBlock asks the master to send EOF at the end of the binlog instead of waiting.
+	NonBlock bool
+
+	// MaxReconnectAttempts is the redial attempts after the stream broken,
+	// 0 means driver.DefaultReconnectAttempts, negative disables the reconnect.
+	MaxReconnectAttempts int
+	ReconnectBackoff     time.Duration
+
+	// SchemaFetcher correlates the rows events with the column names if set.
+	SchemaFetcher SchemaFetcher
+
+	// SemiSync registers as a semi-sync slave and replies the ACKs if the master enabled it.
+	SemiSync bool
+
+	// HeartbeatPeriod asks the master to send the HEARTBEAT_EVENT if the binlog is idle for the period,
+	// the stream is treated as broken and reconnected if nothing received for two periods.
+	// 0 means the master default.
+	HeartbeatPeriod time.Duration
+}
+
+// BinlogSyncer registers as a slave and dumps the binlog events from the master.
+type BinlogSyncer struct {
+	// lastRecv is the unix nano of the last packet received, for the heartbeat watchdog.
+	// It's the first for the 64-bit alignment of the atomic.
+	lastRecv int64
+
+	log  *xlog.Log
+	cfg  *Config
+	quit chan struct{}
+	wg   sync.WaitGroup
+
+	// parser and semiSync are only used by the prepare and the run goroutine.
+	parser *BinlogParser
+	// semiSync is true if the events of the current dump have the semi-sync header.
+	semiSync bool
+
+	mu      sync.Mutex
+	conn    driver.Conn
+	pos     Position
+	running bool
+	closed  bool
+
+	// executed is the executed gtid set in the GTID mode, nil in the position mode.
+	executed *gtid.Set
+	// pending is the GTID of the transaction in progress.
+	pending *GTIDEvent
+
+	// mariadbExecuted is the GTID position in the MariaDB GTID mode.
+	mariadbExecuted *gtid.MariadbSet
+	mariadbPending  *MariadbGTIDEvent
+}
+
+// NewBinlogSyncer creates the syncer.
+func NewBinlogSyncer(log *xlog.Log, cfg *Config) *BinlogSyncer {
+	return &BinlogSyncer{
+		log:  log,
+		cfg:  cfg,
+		quit: make(chan struct{}),
+	}
+}
+
+// StartSync dumps the binlog from the position, the events are read from the streamer.
+// The syncer reconnects and resumes from the last position if the stream broken.
+func (s *BinlogSyncer) StartSync(pos Position) (*BinlogStreamer, error) {
+	return s.start(pos, nil, nil)
+}
+
+// StartSyncGTID dumps the binlog with COM_BINLOG_DUMP_GTID, the master sends the transactions not in the set.
+// The syncer resumes from the executed set if the stream broken.
+func (s *BinlogSyncer) StartSyncGTID(set *gtid.Set) (*BinlogStreamer, error) {
+	return s.start(Position{}, set.Clone(), nil)
+}
+
+// StartSyncMariadbGTID dumps the binlog from the MariaDB GTID position by the @slave_connect_state.
+// The syncer resumes from the last committed GTIDs if the stream broken.
+func (s *BinlogSyncer) StartSyncMariadbGTID(set *gtid.MariadbSet) (*BinlogStreamer, error) {
+	return s.start(Position{}, nil, set.Clone())
+}
+
+func (s *BinlogSyncer) start(pos Position, executed *gtid.Set, mariadbExecuted *gtid.MariadbSet) (*BinlogStreamer, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, ErrSyncerClosed
+	}
+	if s.running {
+		s.mu.Unlock()
+		return nil, ErrSyncerRunning
+	}
+	s.running = true
+	s.pos = pos
+	s.executed = executed
+	s.mariadbExecuted = mariadbExecuted
+	s.parser = NewBinlogParser()
+	if s.cfg.SchemaFetcher != nil {
+		s.parser.SetSchemaFetcher(s.cfg.SchemaFetcher)
+	}
+	s.mu.Unlock()
+
+	if err := s.prepare(); err != nil {
+		s.mu.Lock()
+		s.running = false
+		s.mu.Unlock()
+		return nil, err
+	}
+
+	streamer := newBinlogStreamer()
+	done := make(chan struct{})
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer close(done)
+		streamer.close(s.run(streamer))
+	}()
+	if s.cfg.HeartbeatPeriod > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.watchdog(2*s.cfg.HeartbeatPeriod, done)
+		}()
+	}
+	return streamer, nil
+}
+
+// Position returns the position of the next event, it's safe for checkpointing after the event consumed.
+func (s *BinlogSyncer) Position() Position {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pos
+}
+
+// GTIDSet returns a copy of the executed gtid set in the GTID mode, the transactions are added after they committed.
+// Returns nil in the position mode.
+func (s *BinlogSyncer) GTIDSet() *gtid.Set {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.executed == nil {
+		return nil
+	}
+	return s.executed.Clone()
+}
+
+// MariadbGTIDSet returns a copy of the GTID position in the MariaDB GTID mode, nil in the other modes.
+func (s *BinlogSyncer) MariadbGTIDSet() *gtid.MariadbSet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.mariadbExecuted == nil {
+		return nil
+	}
+	return s.mariadbExecuted.Clone()
+}
+
+// Close stops the syncer.
+func (s *BinlogSyncer) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	close(s.quit)
+	if s.conn != nil {
+		s.conn.Cleanup()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// prepare dials the master, registers as a slave and sends the dump command.
+func (s *BinlogSyncer) prepare() error {
+	conn, err := driver.NewConn(s.cfg.User, s.cfg.Password, s.cfg.Addr, "", "")
+	if err != nil {
+		return err
+	}
+	if err = s.prepareFlavor(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	if err = s.prepareChecksum(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	if err = s.prepareSemiSync(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	if err = s.prepareHeartbeat(conn); err != nil {
+		conn.Close()
+		return err
+	}
+	if err = s.registerSlave(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	if err = s.writeDump(conn); err != nil {
+		conn.Close()
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		conn.Close()
+		return ErrSyncerClosed
+	}
+	s.conn = conn
+	atomic.StoreInt64(&s.lastRecv, time.Now().UnixNano())
+	return nil
+}
+
+// prepareHeartbeat sets the heartbeat period in nanoseconds.
+func (s *BinlogSyncer) prepareHeartbeat(conn driver.Conn) error {
+	if s.cfg.HeartbeatPeriod <= 0 {
+		return nil
+	}
+	return conn.Exec(fmt.Sprintf("SET @master_heartbeat_period=%d", s.cfg.HeartbeatPeriod.Nanoseconds()))
+}
+
+// watchdog breaks the connection if nothing received for the timeout, the run goroutine reconnects then.
+func (s *BinlogSyncer) watchdog(timeout time.Duration, done chan struct{}) {
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			last := time.Unix(0, atomic.LoadInt64(&s.lastRecv))
+			if time.Since(last) < timeout {
+				continue
+			}
+			s.log.Warning("replication.syncer.nothing.received.since[%v].from%v", last, s.Position())
+			atomic.StoreInt64(&s.lastRecv, time.Now().UnixNano())
+			s.mu.Lock()
+			if s.conn != nil {
+				s.conn.Cleanup()
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// prepareFlavor checks the mode is supported by the master, and tells the MariaDB master to send the GTID events.
+func (s *BinlogSyncer) prepareFlavor(conn driver.Conn) error {
+	version := conn.ServerVersion()
+	executed := s.GTIDSet()
+	mariadbExecuted := s.MariadbGTIDSet()
+
+	if !version.IsMariaDB() {
+		if mariadbExecuted != nil {
+			return fmt.Errorf("replication.master[%s].is.not.mariadb", version.Raw)
+		}
+		return nil
+	}
+	if executed != nil {
+		return fmt.Errorf("replication.mariadb.master[%s].does.not.support.mysql.gtid", version.Raw)
+	}
+
+	queries := []string{fmt.Sprintf("SET @mariadb_slave_capability=%d", MARIADB_SLAVE_CAPABILITY_GTID)}
+	if mariadbExecuted != nil {
+		queries = append(queries,
+			fmt.Sprintf("SET @slave_connect_state=%s", escape.QuoteString(mariadbExecuted.String())),
+			"SET @slave_gtid_strict_mode=0",
+			"SET @slave_gtid_ignore_duplicates=0",
+		)
+	}
+	for _, query := range queries {
+		if err := conn.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareChecksum tells the master we can handle the checksum it's configured to log,
+// otherwise the master refuses to send the events.
+func (s *BinlogSyncer) prepareChecksum(conn driver.Conn) error {
+	qr, err := conn.FetchAll("SHOW GLOBAL VARIABLES LIKE 'BINLOG_CHECKSUM'", -1)
+	if err != nil {
+		return err
+	}
+	// The server is too old to have the checksum.
+	if len(qr.Rows) == 0 || len(qr.Rows[0]) < 2 {
+		return nil
+	}
+
+	checksum := qr.Rows[0][1].String()
+	if checksum == "" {
+		return nil
+	}
+	if err = conn.Exec(fmt.Sprintf("SET @master_binlog_checksum=%s", escape.QuoteString(checksum))); err != nil {
+		return err
+	}
+	s.parser.SetChecksumAlgorithm(ParseChecksumAlgorithm(checksum))
+	return nil
+}
+
+// prepareSemiSync tells the master to send the semi-sync headers if it's a semi-sync master.
+func (s *BinlogSyncer) prepareSemiSync(conn driver.Conn) error {
+	s.semiSync = false
+	if !s.cfg.SemiSync {
+		return nil
+	}
+
+	qr, err := conn.FetchAll("SHOW VARIABLES LIKE 'rpl_semi_sync_master_enabled'", -1)
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) == 0 || len(qr.Rows[0]) < 2 || !strings.EqualFold(qr.Rows[0][1].String(), "ON") {
+		s.log.Warning("replication.syncer.master[%s].semi.sync.is.not.enabled", conn.Addr())
+		return nil
+	}
+	if err = conn.Exec("SET @rpl_semi_sync_slave=1"); err != nil {
+		return err
+	}
+	s.semiSync = true
+	return nil
+}
+
+func (s *BinlogSyncer) writeDump(conn driver.Conn) error {
+	var flags uint16
+	if s.cfg.NonBlock {
+		flags |= proto.BINLOG_DUMP_NON_BLOCK
+	}
+
+	pos := s.Position()
+	executed := s.GTIDSet()
+	if executed != nil {
+		dump := &proto.BinlogDumpGTID{
+			Flags:    flags | proto.BINLOG_THROUGH_GTID,
+			ServerID: s.cfg.ServerID,
+			Position: 4,
+			SIDData:  executed.Encode(),
+		}
+		return conn.WriteCommand(sqldb.COM_BINLOG_DUMP_GTID, proto.PackBinlogDumpGTID(dump))
+	}
+
+	// The MariaDB master starts from the @slave_connect_state.
+	if s.MariadbGTIDSet() != nil {
+		pos = Position{Pos: 4}
+	}
+	dump := &proto.BinlogDump{
+		Position: pos.Pos,
+		Flags:    flags,
+		ServerID: s.cfg.ServerID,
+		Filename: pos.Name,
+	}
+	return conn.WriteCommand(sqldb.COM_BINLOG_DUMP, proto.PackBinlogDump(dump))
+}
+
+func (s *BinlogSyncer) registerSlave(conn driver.Conn) error {
+	register := &proto.RegisterSlave{
+		ServerID: s.cfg.ServerID,
+		Host:     s.cfg.ReportHost,
+		User:     s.cfg.User,
+		Password: s.cfg.Password,
+		Port:     s.cfg.ReportPort,
+	}
+	if err := conn.WriteCommand(sqldb.COM_REGISTER_SLAVE, proto.PackRegisterSlave(register)); err != nil {
+		return err
+	}
+	data, err := conn.NextPacket()
+	if err != nil {
+		return err
+	}
+	if data[0] == proto.ERR_PACKET {
+		return proto.UnPackERR(data)
+	}
+	_, err = proto.UnPackOK(data)
+	return err
+}
+
+func (s *BinlogSyncer) run(streamer *BinlogStreamer) error {
+	// The syncer can be started again once the stream ended.
+	defer func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.conn != nil {
+			s.conn.Cleanup()
+			s.conn = nil
+		}
+		s.running = false
+	}()
+	for {
+		s.mu.Lock()
+		conn := s.conn
+		s.mu.Unlock()
+
+		data, err := conn.NextPacket()
+		if err != nil {
+			if err = s.reconnect(err); err != nil {
+				return err
+			}
+			continue
+		}
+		atomic.StoreInt64(&s.lastRecv, time.Now().UnixNano())
+		if len(data) == 0 {
+			return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid.binlog.packet.empty")
+		}
+
+		switch data[0] {
+		case proto.OK_PACKET:
+			data = data[1:]
+			needAck := false
+			if s.semiSync {
+				if len(data) < 2 || data[0] != proto.SEMI_SYNC_INDICATOR {
+					return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid.semi.sync.event.header[%v]", data)
+				}
+				needAck = data[1]&proto.SEMI_SYNC_ACK_REQUIRED > 0
+				data = data[2:]
+			}
+
+			ev, err := s.parser.Parse(data)
+			if err != nil {
+				return err
+			}
+			s.updatePosition(ev)
+			select {
+			case streamer.ch <- ev:
+			case <-s.quit:
+				return ErrSyncerClosed
+			}
+
+			if needAck {
+				if err = s.replyAck(conn); err != nil {
+					if err = s.reconnect(err); err != nil {
+						return err
+					}
+				}
+			}
+		case proto.ERR_PACKET:
+			return proto.UnPackERR(data)
+		case proto.EOF_PACKET:
+			// The master reached the end with the BINLOG_DUMP_NON_BLOCK flag.
+			return io.EOF
+		default:
+			return sqldb.NewSQLError(sqldb.ER_MALFORMED_PACKET, "invalid.binlog.packet[%v]", data)
+		}
+	}
+}
+
+// replyAck tells the master the position received.
+func (s *BinlogSyncer) replyAck(conn driver.Conn) error {
+	pos := s.Position()
+	ack := &proto.SemiSyncAck{Position: uint64(pos.Pos), Filename: pos.Name}
+	return conn.WriteCommand(proto.SEMI_SYNC_INDICATOR, proto.PackSemiSyncAck(ack))
+}
+
+func (s *BinlogSyncer) updatePosition(ev *BinlogEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch e := ev.Event.(type) {
+	case *RotateEvent:
+		s.pos = Position{Name: e.NextName, Pos: uint32(e.Position)}
+		return
+	case *HeartbeatEvent:
+		// The heartbeat is not in the binlog.
+		return
+	case *GTIDEvent:
+		s.pending = e
+	case *MariadbGTIDEvent:
+		s.mariadbPending = e
+	case *QueryEvent:
+		// DDL is committed by itself.
+		if e.Query != "BEGIN" && s.committedByQuery(e) {
+			s.commitPending()
+		}
+	case *XIDEvent:
+		s.commitPending()
+	}
+
+	// The artificial events have zero log pos.
+	if ev.Header.LogPos > 0 {
+		s.pos.Pos = ev.Header.LogPos
+	}
+}
+
+// committedByQuery checks the query ends the MariaDB event group,
+// the group is ended by the COMMIT query unless it's standalone.
+func (s *BinlogSyncer) committedByQuery(e *QueryEvent) bool {
+	if s.mariadbPending == nil || s.mariadbPending.Standalone() {
+		return true
+	}
+	return e.Query == "COMMIT"
+}
+
+func (s *BinlogSyncer) commitPending() {
+	if s.pending != nil && s.executed != nil {
+		s.executed.AddGTID(s.pending.SID, s.pending.GNO)
+	}
+	if s.mariadbPending != nil && s.mariadbExecuted != nil {
+		s.mariadbExecuted.Update(s.mariadbPending.GTID)
+	}
+	s.pending = nil
+	s.mariadbPending = nil
+}
+
+// reconnect redials the master and resumes from the current position with backoff.
+func (s *BinlogSyncer) reconnect(cause error) error {
+	attempts := s.cfg.MaxReconnectAttempts
+	if attempts == 0 {
+		attempts = driver.DefaultReconnectAttempts
+	}
+	backoff := s.cfg.ReconnectBackoff
+	if backoff == 0 {
+		backoff = driver.DefaultReconnectBackoff
+	}
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Cleanup()
+	}
+	s.mu.Unlock()
+
+	for i := 1; i <= attempts; i++ {
+		select {
+		case <-s.quit:
+			return ErrSyncerClosed
+		case <-time.After(backoff):
+		}
+
+		s.log.Warning("replication.syncer.reconnect[%d].from%v.cause:%v", i, s.Position(), cause)
+		err := s.prepare()
+		if err == nil {
+			return nil
+		}
+		if err == ErrSyncerClosed {
+			return err
+		}
+		cause = err
+		if backoff *= 2; backoff > driver.DefaultReconnectMaxBackoff {
+			backoff = driver.DefaultReconnectMaxBackoff
+		}
+	}
+
+	select {
+	case <-s.quit:
+		return ErrSyncerClosed
+	default:
+	}
+	return cause
+}
+
+// BinlogStreamer delivers the binlog events.
+type BinlogStreamer struct {
+	ch  chan *BinlogEvent
+	err error
+}
+
+func newBinlogStreamer() *BinlogStreamer {
+	return &BinlogStreamer{
+		ch: make(chan *BinlogEvent, 1024),
+	}
+}
+
+// GetEvent returns the next event, or the error which ended the stream.
+func (s *BinlogStreamer) GetEvent(ctx context.Context) (*BinlogEvent, error) {
+	select {
+	case ev, ok := <-s.ch:
+		if !ok {
+			return nil, s.err
+		}
+		return ev, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// close ends the stream with the error, the buffered events are still readable.
+func (s *BinlogStreamer) close(err error) {
+	s.err = err
+	close(s.ch)
+}
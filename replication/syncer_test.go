@@ -0,0 +1,400 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/common"
+	"github.com/XeLabs/go-mysqlstack/gtid"
+	"github.com/XeLabs/go-mysqlstack/packet"
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// mockMaster is a tiny master, the dump is served by the fn.
+type mockMaster struct {
+	listener net.Listener
+	version  string
+	vars     map[string]string
+	dumps    chan interface{}
+	queries  chan string
+	fn       func(packets *packet.Packets, dump interface{})
+}
+
+// newMockMaster creates the master, the dump is *proto.BinlogDump or *proto.BinlogDumpGTID.
+func newMockMaster(t *testing.T, fn func(packets *packet.Packets, dump interface{})) *mockMaster {
+	return newMockMasterWith(t, "5.7.20-log", nil, fn)
+}
+
+// newMockMasterWith creates the master with the server version and the variables for SHOW VARIABLES LIKE, the queries are recorded.
+func newMockMasterWith(t *testing.T, version string, vars map[string]string, fn func(packets *packet.Packets, dump interface{})) *mockMaster {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	m := &mockMaster{listener: l, version: version, vars: vars, dumps: make(chan interface{}, 16), queries: make(chan string, 64), fn: fn}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go m.handle(c)
+		}
+	}()
+	return m
+}
+
+func (m *mockMaster) handle(c net.Conn) {
+	defer c.Close()
+	packets := packet.NewPackets(c)
+	greeting := proto.NewGreeting(1)
+	greeting.SetServerVersion(m.version)
+	if err := packets.Write(greeting.Pack()); err != nil {
+		return
+	}
+	if _, err := packets.Next(); err != nil {
+		return
+	}
+	if err := packets.WriteOK(0, 0, sqldb.SERVER_STATUS_AUTOCOMMIT, 0); err != nil {
+		return
+	}
+
+	for {
+		packets.ResetSeq()
+		data, err := packets.Next()
+		if err != nil || data[0] == sqldb.COM_QUIT {
+			return
+		}
+		switch data[0] {
+		case sqldb.COM_BINLOG_DUMP:
+			dump, err := proto.UnPackBinlogDump(data[1:])
+			if err != nil {
+				return
+			}
+			m.dumps <- dump
+			m.fn(packets, dump)
+			return
+		case sqldb.COM_BINLOG_DUMP_GTID:
+			dump, err := proto.UnPackBinlogDumpGTID(data[1:])
+			if err != nil {
+				return
+			}
+			m.dumps <- dump
+			m.fn(packets, dump)
+			return
+		case sqldb.COM_QUERY:
+			query := string(data[1:])
+			m.queries <- query
+			if i := strings.Index(query, "VARIABLES LIKE '"); i >= 0 {
+				name := strings.ToLower(strings.Trim(query[i+len("VARIABLES LIKE "):], "'"))
+				if value, ok := m.vars[name]; ok {
+					m.writeVariable(packets, name, value)
+					continue
+				}
+			}
+			packets.WriteOK(0, 0, sqldb.SERVER_STATUS_AUTOCOMMIT, 0)
+		default:
+			packets.WriteOK(0, 0, sqldb.SERVER_STATUS_AUTOCOMMIT, 0)
+		}
+	}
+}
+
+func (m *mockMaster) writeVariable(packets *packet.Packets, name string, value string) {
+	packets.AppendColumns([]*querypb.Field{
+		{Name: "Variable_name", Type: querypb.Type_VARCHAR},
+		{Name: "Value", Type: querypb.Type_VARCHAR},
+	})
+	row := common.NewBuffer(64)
+	row.WriteLenEncodeString(name)
+	row.WriteLenEncodeString(value)
+	packets.Append(row.Datas())
+	packets.AppendOKWithEOFHeader(0, 0, sqldb.SERVER_STATUS_AUTOCOMMIT, 0)
+	packets.Flush()
+}
+
+func (m *mockMaster) addr() string {
+	return m.listener.Addr().String()
+}
+
+func makeEvent(typ EventType, logPos uint32, body []byte) []byte {
+	h := &EventHeader{
+		Timestamp: 1,
+		EventType: typ,
+		ServerID:  1,
+		EventSize: uint32(EventHeaderSize + len(body)),
+		LogPos:    logPos,
+	}
+	return append(h.Pack(), body...)
+}
+
+func makeRotate(logPos uint32, next string, pos uint64) []byte {
+	buf := common.NewBuffer(32)
+	buf.WriteU64(pos)
+	buf.WriteString(next)
+	return makeEvent(ROTATE_EVENT, logPos, buf.Datas())
+}
+
+func makeQuery(logPos uint32, schema string, query string) []byte {
+	buf := common.NewBuffer(64)
+	buf.WriteU32(1)
+	buf.WriteU32(0)
+	buf.WriteU8(uint8(len(schema)))
+	buf.WriteU16(0)
+	buf.WriteU16(0)
+	buf.WriteString(schema)
+	buf.WriteU8(0)
+	buf.WriteString(query)
+	return makeEvent(QUERY_EVENT, logPos, buf.Datas())
+}
+
+func makeXID(logPos uint32, xid uint64) []byte {
+	buf := common.NewBuffer(8)
+	buf.WriteU64(xid)
+	return makeEvent(XID_EVENT, logPos, buf.Datas())
+}
+
+func makeGTID(logPos uint32, sid gtid.SID, gno int64) []byte {
+	buf := common.NewBuffer(64)
+	buf.WriteU8(1)
+	buf.WriteBytes(sid[:])
+	buf.WriteU64(uint64(gno))
+	buf.WriteU8(2)
+	buf.WriteU64(uint64(gno - 1))
+	buf.WriteU64(uint64(gno))
+	return makeEvent(GTID_EVENT, logPos, buf.Datas())
+}
+
+func writeEvents(packets *packet.Packets, events ...[]byte) {
+	for _, ev := range events {
+		packets.Write(append([]byte{proto.OK_PACKET}, ev...))
+	}
+}
+
+func TestSyncerResume(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	calls := 0
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		calls++
+		switch calls {
+		case 1:
+			writeEvents(packets,
+				makeRotate(0, "mysql-bin.000001", 4),
+				makeQuery(100, "db1", "BEGIN"),
+				makeXID(200, 1),
+			)
+			// Connection broken.
+		case 2:
+			writeEvents(packets, makeRotate(300, "mysql-bin.000002", 4))
+			packets.Write([]byte{proto.EOF_PACKET})
+		}
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{
+		ServerID:         100,
+		Addr:             master.addr(),
+		User:             "repl",
+		NonBlock:         true,
+		ReconnectBackoff: time.Millisecond,
+	})
+	defer syncer.Close()
+
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	_, err = syncer.StartSync(Position{})
+	assert.Equal(t, ErrSyncerRunning, err)
+
+	dump := <-master.dumps
+	assert.Equal(t, &proto.BinlogDump{Position: 4, Flags: proto.BINLOG_DUMP_NON_BLOCK, ServerID: 100, Filename: "mysql-bin.000001"}, dump)
+
+	var types []EventType
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		types = append(types, ev.Header.EventType)
+	}
+	assert.Equal(t, []EventType{ROTATE_EVENT, QUERY_EVENT, XID_EVENT, ROTATE_EVENT}, types)
+
+	// Resumed from the last event.
+	dump = <-master.dumps
+	assert.Equal(t, uint32(200), dump.(*proto.BinlogDump).Position)
+	assert.Equal(t, "mysql-bin.000001", dump.(*proto.BinlogDump).Filename)
+	assert.Equal(t, Position{Name: "mysql-bin.000002", Pos: 4}, syncer.Position())
+}
+
+func TestSyncerClose(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		writeEvents(packets, makeEvent(STOP_EVENT, 100, []byte("x1")))
+		// Block until the slave gone.
+		packets.Next()
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl"})
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+
+	ctx := context.Background()
+	ev, err := streamer.GetEvent(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, &RawEvent{Data: []byte("x1")}, ev.Event)
+
+	syncer.Close()
+	_, err = streamer.GetEvent(ctx)
+	assert.Equal(t, ErrSyncerClosed, err)
+
+	_, err = syncer.StartSync(Position{})
+	assert.Equal(t, ErrSyncerClosed, err)
+}
+
+func TestSyncerMasterError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		packets.WriteERR(1236, "HY000", "Could not find first log file name in binary log index file")
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl"})
+	defer syncer.Close()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000009", Pos: 4})
+	assert.Nil(t, err)
+
+	_, err = streamer.GetEvent(context.Background())
+	assert.Equal(t, "Could not find first log file name in binary log index file (errno 1236) (sqlstate HY000)", err.Error())
+}
+
+func TestSyncerGTID(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	sid, err := gtid.ParseSID("3e11fa47-71ca-11e1-9e33-c80aa9429562")
+	assert.Nil(t, err)
+
+	calls := 0
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		calls++
+		switch calls {
+		case 1:
+			writeEvents(packets,
+				makeGTID(100, sid, 6),
+				makeQuery(200, "db1", "BEGIN"),
+				makeXID(300, 1),
+				makeGTID(400, sid, 7),
+				makeQuery(500, "db1", "BEGIN"),
+			)
+			// Connection broken in the transaction.
+		case 2:
+			writeEvents(packets,
+				makeGTID(400, sid, 7),
+				makeQuery(500, "db1", "BEGIN"),
+				makeXID(600, 2),
+				makeGTID(700, sid, 8),
+				makeQuery(800, "db1", "CREATE TABLE t1(a INT)"),
+			)
+			packets.Write([]byte{proto.EOF_PACKET})
+		}
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{
+		ServerID:         100,
+		Addr:             master.addr(),
+		User:             "repl",
+		NonBlock:         true,
+		ReconnectBackoff: time.Millisecond,
+	})
+	defer syncer.Close()
+
+	set, err := gtid.ParseSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	assert.Nil(t, err)
+	streamer, err := syncer.StartSyncGTID(set)
+	assert.Nil(t, err)
+
+	dump := (<-master.dumps).(*proto.BinlogDumpGTID)
+	assert.Equal(t, proto.BINLOG_THROUGH_GTID|proto.BINLOG_DUMP_NON_BLOCK, dump.Flags)
+	got, err := gtid.DecodeSet(dump.SIDData)
+	assert.Nil(t, err)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", got.String())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		ev, err := streamer.GetEvent(ctx)
+		if err != nil {
+			assert.Equal(t, io.EOF, err)
+			break
+		}
+		if e, ok := ev.Event.(*GTIDEvent); ok {
+			assert.Equal(t, sid, e.SID)
+			assert.Equal(t, e.GNO, e.SequenceNumber)
+		}
+	}
+
+	// Resumed with the committed transactions only.
+	dump = (<-master.dumps).(*proto.BinlogDumpGTID)
+	got, err = gtid.DecodeSet(dump.SIDData)
+	assert.Nil(t, err)
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-6", got.String())
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-8", syncer.GTIDSet().String())
+
+	// The caller's set is untouched.
+	assert.Equal(t, "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", set.String())
+}
+
+func TestSyncerRestart(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	calls := 0
+	master := newMockMaster(t, func(packets *packet.Packets, dump interface{}) {
+		calls++
+		switch calls {
+		case 1:
+			// The empty packet.
+			packets.Write([]byte{})
+			packets.Next()
+		case 2:
+			writeEvents(packets, makeRotate(0, "mysql-bin.000002", 4))
+			packets.Write([]byte{proto.EOF_PACKET})
+		}
+	})
+	defer master.listener.Close()
+
+	syncer := NewBinlogSyncer(log, &Config{ServerID: 100, Addr: master.addr(), User: "repl", NonBlock: true})
+	defer syncer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	streamer, err := syncer.StartSync(Position{Name: "mysql-bin.000001", Pos: 4})
+	assert.Nil(t, err)
+	_, err = streamer.GetEvent(ctx)
+	assert.Equal(t, "invalid.binlog.packet.empty (errno 1835) (sqlstate HY000)", err.Error())
+
+	// The syncer is started again after the stream ended.
+	streamer, err = syncer.StartSync(syncer.Position())
+	assert.Nil(t, err)
+	ev, err := streamer.GetEvent(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, ROTATE_EVENT, ev.Header.EventType)
+	_, err = streamer.GetEvent(ctx)
+	assert.Equal(t, io.EOF, err)
+}
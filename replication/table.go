@@ -0,0 +1,189 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes/escape"
+)
+
+var (
+	// ErrNoColumnNames returned by RowMaps if the columns are not correlated.
+	ErrNoColumnNames = errors.New("replication.rows.event.no.column.names")
+)
+
+// SchemaColumn is the column info the TABLE_MAP doesn't carry.
+type SchemaColumn struct {
+	Name     string
+	Unsigned bool
+}
+
+// SchemaFetcher fetches the columns of the table in the ordinal order.
+type SchemaFetcher func(schema string, table string) ([]*SchemaColumn, error)
+
+// InformationSchemaFetcher fetches the columns from information_schema by the conn,
+// the conn must not be the one dumping the binlog.
+func InformationSchemaFetcher(conn driver.Conn) SchemaFetcher {
+	return func(schema string, table string) ([]*SchemaColumn, error) {
+		query := fmt.Sprintf("SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA=%s AND TABLE_NAME=%s ORDER BY ORDINAL_POSITION",
+			escape.QuoteString(schema), escape.QuoteString(table))
+		qr, err := conn.FetchAll(query, -1)
+		if err != nil {
+			return nil, err
+		}
+		columns := make([]*SchemaColumn, 0, len(qr.Rows))
+		for _, row := range qr.Rows {
+			columns = append(columns, &SchemaColumn{
+				Name:     row[0].String(),
+				Unsigned: strings.Contains(strings.ToLower(row[1].String()), "unsigned"),
+			})
+		}
+		return columns, nil
+	}
+}
+
+// TableMapCache keeps the TABLE_MAPs of the stream by the table id,
+// and the columns of the tables by the name if the fetcher is set.
+type TableMapCache struct {
+	fetcher SchemaFetcher
+	maps    map[uint64]*TableMapEvent
+
+	mu      sync.Mutex
+	columns map[string][]*SchemaColumn
+}
+
+// NewTableMapCache creates the cache, the fetcher can be nil.
+func NewTableMapCache(fetcher SchemaFetcher) *TableMapCache {
+	return &TableMapCache{
+		fetcher: fetcher,
+		maps:    make(map[uint64]*TableMapEvent),
+		columns: make(map[string][]*SchemaColumn),
+	}
+}
+
+// Add adds the table map.
+func (c *TableMapCache) Add(tm *TableMapEvent) {
+	c.maps[tm.TableID] = tm
+}
+
+// Get returns the table map by the id.
+func (c *TableMapCache) Get(tableID uint64) (*TableMapEvent, bool) {
+	tm, ok := c.maps[tableID]
+	return tm, ok
+}
+
+// Release releases the table maps at the statement end, the columns are kept.
+func (c *TableMapCache) Release() {
+	c.maps = make(map[uint64]*TableMapEvent)
+}
+
+// Columns returns the columns of the table, nil if the fetcher is not set.
+// The columns are fetched once and kept until Invalidate.
+func (c *TableMapCache) Columns(tm *TableMapEvent) ([]*SchemaColumn, error) {
+	if c.fetcher == nil {
+		return nil, nil
+	}
+
+	key := tm.Schema + "." + tm.Table
+	c.mu.Lock()
+	columns, ok := c.columns[key]
+	c.mu.Unlock()
+	if ok {
+		return columns, nil
+	}
+
+	columns, err := c.fetcher(tm.Schema, tm.Table)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.columns[key] = columns
+	c.mu.Unlock()
+	return columns, nil
+}
+
+// Invalidate drops the columns of the table, all the tables if the table is empty.
+func (c *TableMapCache) Invalidate(schema string, table string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if table == "" {
+		c.columns = make(map[string][]*SchemaColumn)
+		return
+	}
+	delete(c.columns, schema+"."+table)
+}
+
+// correlate sets the column names to the rows event and fixes the unsigned integers.
+func (c *TableMapCache) correlate(e *RowsEvent) error {
+	columns, err := c.Columns(e.Table)
+	if err != nil {
+		return err
+	}
+	// The table is altered after the event written.
+	if columns == nil || len(columns) != int(e.ColumnCount) {
+		return nil
+	}
+
+	e.Columns = columns
+	for _, row := range e.Rows {
+		for i, v := range row {
+			if columns[i].Unsigned && sqltypes.IsSigned(v.Type()) {
+				row[i] = toUnsigned(v)
+			}
+		}
+	}
+	return nil
+}
+
+// toUnsigned reinterprets the signed integer as the unsigned one.
+func toUnsigned(v sqltypes.Value) sqltypes.Value {
+	n, err := strconv.ParseInt(v.String(), 10, 64)
+	if err != nil {
+		return v
+	}
+	switch v.Type() {
+	case sqltypes.Int8:
+		return sqltypes.MakeTrusted(sqltypes.Uint8, strconv.AppendUint(nil, uint64(uint8(n)), 10))
+	case sqltypes.Int16:
+		return sqltypes.MakeTrusted(sqltypes.Uint16, strconv.AppendUint(nil, uint64(uint16(n)), 10))
+	case sqltypes.Int24:
+		return sqltypes.MakeTrusted(sqltypes.Uint24, strconv.AppendUint(nil, uint64(n)&0xffffff, 10))
+	case sqltypes.Int32:
+		return sqltypes.MakeTrusted(sqltypes.Uint32, strconv.AppendUint(nil, uint64(uint32(n)), 10))
+	case sqltypes.Int64:
+		return sqltypes.MakeTrusted(sqltypes.Uint64, strconv.AppendUint(nil, uint64(n), 10))
+	}
+	return v
+}
+
+// RowMaps returns the rows as the column name to value maps.
+// Returns ErrNoColumnNames if the columns are not correlated.
+func (e *RowsEvent) RowMaps() ([]map[string]sqltypes.Value, error) {
+	if e.Columns == nil {
+		return nil, ErrNoColumnNames
+	}
+
+	maps := make([]map[string]sqltypes.Value, 0, len(e.Rows))
+	for _, row := range e.Rows {
+		m := make(map[string]sqltypes.Value, len(row))
+		for i, v := range row {
+			m[e.Columns[i].Name] = v
+		}
+		maps = append(maps, m)
+	}
+	return maps, nil
+}
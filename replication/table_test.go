@@ -0,0 +1,114 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/driver"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestTableMapCacheCorrelate(t *testing.T) {
+	fetched := 0
+	columns := []*SchemaColumn{
+		{Name: "id", Unsigned: true},
+		{Name: "name"},
+		{Name: "price"},
+		{Name: "ts"},
+	}
+	parser := NewBinlogParser()
+	parser.SetSchemaFetcher(func(schema string, table string) ([]*SchemaColumn, error) {
+		assert.Equal(t, "db1", schema)
+		assert.Equal(t, "t1", table)
+		fetched++
+		return columns, nil
+	})
+
+	parse := func() *RowsEvent {
+		_, err := parser.Parse(makeTableMap(1))
+		assert.Nil(t, err)
+		ev, err := parser.Parse(makeRows(WRITE_ROWS_EVENTv2, 1, 1, makeImage(0xffffffff, "a", false)))
+		assert.Nil(t, err)
+		return ev.Event.(*RowsEvent)
+	}
+
+	rows := parse()
+	maps, err := rows.RowMaps()
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(maps))
+	assert.Equal(t, sqltypes.MakeTrusted(querypb.Type_UINT32, []byte("4294967295")), maps[0]["id"])
+	assert.Equal(t, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("a")), maps[0]["name"])
+
+	// Cached.
+	parse()
+	assert.Equal(t, 1, fetched)
+
+	// DDL invalidates the columns.
+	_, err = parser.Parse(makeQuery(400, "db1", "ALTER TABLE t1 ADD COLUMN c INT"))
+	assert.Nil(t, err)
+	parse()
+	assert.Equal(t, 2, fetched)
+
+	// The columns mismatch the table map.
+	columns = columns[:3]
+	parser.TableMapCache().Invalidate("db1", "t1")
+	rows = parse()
+	assert.Equal(t, 3, fetched)
+	_, err = rows.RowMaps()
+	assert.Equal(t, ErrNoColumnNames, err)
+	assert.Equal(t, sqltypes.MakeTrusted(querypb.Type_INT32, []byte("-1")), rows.Rows[0][0])
+}
+
+func TestTableMapCacheWithoutFetcher(t *testing.T) {
+	parser := NewBinlogParser()
+	_, err := parser.Parse(makeTableMap(1))
+	assert.Nil(t, err)
+	ev, err := parser.Parse(makeRows(WRITE_ROWS_EVENTv2, 1, 0, makeImage(1, "a", false)))
+	assert.Nil(t, err)
+	_, err = ev.Event.(*RowsEvent).RowMaps()
+	assert.Equal(t, ErrNoColumnNames, err)
+
+	tm, ok := parser.TableMapCache().Get(1)
+	assert.True(t, ok)
+	assert.Equal(t, "t1", tm.Table)
+}
+
+func TestInformationSchemaFetcher(t *testing.T) {
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "COLUMN_NAME", Type: querypb.Type_VARCHAR},
+			{Name: "COLUMN_TYPE", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(10) unsigned"))},
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name")), sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("varchar(20)"))},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	th := driver.NewTestHandler(log)
+	svr, err := driver.MockMysqlServer(log, th)
+	assert.Nil(t, err)
+	defer svr.Close()
+
+	th.AddQuery("SELECT COLUMN_NAME, COLUMN_TYPE FROM information_schema.COLUMNS WHERE TABLE_SCHEMA='db1' AND TABLE_NAME='t1' ORDER BY ORDINAL_POSITION", result)
+	conn, err := driver.NewConn("mock", "mock", svr.Addr(), "", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	columns, err := InformationSchemaFetcher(conn)("db1", "t1")
+	assert.Nil(t, err)
+	assert.Equal(t, []*SchemaColumn{{Name: "id", Unsigned: true}, {Name: "name"}}, columns)
+}
@@ -0,0 +1,772 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// The column types in the TABLE_MAP event.
+// include/mysql_com.h enum_field_types
+const (
+	MYSQL_TYPE_DECIMAL     byte = 0
+	MYSQL_TYPE_TINY        byte = 1
+	MYSQL_TYPE_SHORT       byte = 2
+	MYSQL_TYPE_LONG        byte = 3
+	MYSQL_TYPE_FLOAT       byte = 4
+	MYSQL_TYPE_DOUBLE      byte = 5
+	MYSQL_TYPE_NULL        byte = 6
+	MYSQL_TYPE_TIMESTAMP   byte = 7
+	MYSQL_TYPE_LONGLONG    byte = 8
+	MYSQL_TYPE_INT24       byte = 9
+	MYSQL_TYPE_DATE        byte = 10
+	MYSQL_TYPE_TIME        byte = 11
+	MYSQL_TYPE_DATETIME    byte = 12
+	MYSQL_TYPE_YEAR        byte = 13
+	MYSQL_TYPE_NEWDATE     byte = 14
+	MYSQL_TYPE_VARCHAR     byte = 15
+	MYSQL_TYPE_BIT         byte = 16
+	MYSQL_TYPE_TIMESTAMP2  byte = 17
+	MYSQL_TYPE_DATETIME2   byte = 18
+	MYSQL_TYPE_TIME2       byte = 19
+	MYSQL_TYPE_JSON        byte = 245
+	MYSQL_TYPE_NEWDECIMAL  byte = 246
+	MYSQL_TYPE_ENUM        byte = 247
+	MYSQL_TYPE_SET         byte = 248
+	MYSQL_TYPE_TINY_BLOB   byte = 249
+	MYSQL_TYPE_MEDIUM_BLOB byte = 250
+	MYSQL_TYPE_LONG_BLOB   byte = 251
+	MYSQL_TYPE_BLOB        byte = 252
+	MYSQL_TYPE_VAR_STRING  byte = 253
+	MYSQL_TYPE_STRING      byte = 254
+	MYSQL_TYPE_GEOMETRY    byte = 255
+)
+
+const (
+	timefIntOfs     = 0x800000
+	timefOfs        = 0x800000000000
+	datetimefIntOfs = 0x8000000000
+)
+
+var dig2bytes = []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 4}
+
+// decodeValue decodes one column value of the row image.
+// Returns the value and the bytes consumed.
+// The integers are signed since the TABLE_MAP has no signedness,
+// ENUM and SET are the index and the bitmap, JSON is the MySQL binary format.
+func decodeValue(data []byte, typ byte, meta uint16) (sqltypes.Value, int, error) {
+	var n int
+	var length int
+
+	// The real type of the STRING.
+	if typ == MYSQL_TYPE_STRING && meta >= 256 {
+		b0 := byte(meta >> 8)
+		b1 := byte(meta & 0xff)
+		if b0&0x30 != 0x30 {
+			length = int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+			b0 = b0 | 0x30
+		} else {
+			length = int(meta & 0xff)
+		}
+		typ = b0
+	} else if typ == MYSQL_TYPE_STRING {
+		length = int(meta)
+	}
+
+	need := func(size int) error {
+		if len(data) < size {
+			return fmt.Errorf("replication.rows.value.type[%d].need[%d].got[%d]", typ, size, len(data))
+		}
+		return nil
+	}
+	makeInt := func(t querypb.Type, v int64) sqltypes.Value {
+		return sqltypes.MakeTrusted(t, strconv.AppendInt(nil, v, 10))
+	}
+
+	switch typ {
+	case MYSQL_TYPE_NULL:
+		return sqltypes.NULL, 0, nil
+	case MYSQL_TYPE_TINY:
+		if err := need(1); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return makeInt(sqltypes.Int8, int64(int8(data[0]))), 1, nil
+	case MYSQL_TYPE_SHORT:
+		if err := need(2); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return makeInt(sqltypes.Int16, int64(int16(binary.LittleEndian.Uint16(data)))), 2, nil
+	case MYSQL_TYPE_INT24:
+		if err := need(3); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := int32(uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16)
+		if v&0x800000 != 0 {
+			v -= 0x1000000
+		}
+		return makeInt(sqltypes.Int24, int64(v)), 3, nil
+	case MYSQL_TYPE_LONG:
+		if err := need(4); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return makeInt(sqltypes.Int32, int64(int32(binary.LittleEndian.Uint32(data)))), 4, nil
+	case MYSQL_TYPE_LONGLONG:
+		if err := need(8); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return makeInt(sqltypes.Int64, int64(binary.LittleEndian.Uint64(data))), 8, nil
+	case MYSQL_TYPE_FLOAT:
+		if err := need(4); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := math.Float32frombits(binary.LittleEndian.Uint32(data))
+		return sqltypes.MakeTrusted(sqltypes.Float32, strconv.AppendFloat(nil, float64(v), 'g', -1, 32)), 4, nil
+	case MYSQL_TYPE_DOUBLE:
+		if err := need(8); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := math.Float64frombits(binary.LittleEndian.Uint64(data))
+		return sqltypes.MakeTrusted(sqltypes.Float64, strconv.AppendFloat(nil, v, 'g', -1, 64)), 8, nil
+	case MYSQL_TYPE_NEWDECIMAL:
+		precision := int(meta >> 8)
+		scale := int(meta & 0xff)
+		s, size, err := decodeDecimal(data, precision, scale)
+		if err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.Decimal, []byte(s)), size, nil
+	case MYSQL_TYPE_YEAR:
+		if err := need(1); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		year := 0
+		if data[0] != 0 {
+			year = int(data[0]) + 1900
+		}
+		return sqltypes.MakeTrusted(sqltypes.Year, []byte(fmt.Sprintf("%04d", year))), 1, nil
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE:
+		if err := need(3); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		s := fmt.Sprintf("%04d-%02d-%02d", v>>9, (v>>5)&15, v&31)
+		return sqltypes.MakeTrusted(sqltypes.Date, []byte(s)), 3, nil
+	case MYSQL_TYPE_TIME:
+		if err := need(3); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := uint32(data[0]) | uint32(data[1])<<8 | uint32(data[2])<<16
+		s := fmt.Sprintf("%02d:%02d:%02d", v/10000, (v%10000)/100, v%100)
+		return sqltypes.MakeTrusted(sqltypes.Time, []byte(s)), 3, nil
+	case MYSQL_TYPE_TIME2:
+		s, size, err := decodeTime2(data, int(meta))
+		if err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.Time, []byte(s)), size, nil
+	case MYSQL_TYPE_DATETIME:
+		if err := need(8); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := binary.LittleEndian.Uint64(data)
+		d, t := v/1000000, v%1000000
+		s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", d/10000, (d%10000)/100, d%100, t/10000, (t%10000)/100, t%100)
+		return sqltypes.MakeTrusted(sqltypes.Datetime, []byte(s)), 8, nil
+	case MYSQL_TYPE_DATETIME2:
+		s, size, err := decodeDatetime2(data, int(meta))
+		if err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.Datetime, []byte(s)), size, nil
+	case MYSQL_TYPE_TIMESTAMP:
+		if err := need(4); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		s := formatTimestamp(int64(binary.LittleEndian.Uint32(data)), 0, 0)
+		return sqltypes.MakeTrusted(sqltypes.Timestamp, []byte(s)), 4, nil
+	case MYSQL_TYPE_TIMESTAMP2:
+		fsp := int(meta)
+		size := 4 + (fsp+1)/2
+		if err := need(size); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		sec := int64(binary.BigEndian.Uint32(data))
+		s := formatTimestamp(sec, readFrac(data[4:], fsp), fsp)
+		return sqltypes.MakeTrusted(sqltypes.Timestamp, []byte(s)), size, nil
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING:
+		length = int(meta)
+		fallthrough
+	case MYSQL_TYPE_STRING:
+		if length < 256 {
+			if err := need(1); err != nil {
+				return sqltypes.NULL, 0, err
+			}
+			n = int(data[0])
+			if err := need(1 + n); err != nil {
+				return sqltypes.NULL, 0, err
+			}
+			return sqltypes.MakeTrusted(sqltypes.VarChar, data[1:1+n]), 1 + n, nil
+		}
+		if err := need(2); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		n = int(binary.LittleEndian.Uint16(data))
+		if err := need(2 + n); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.VarChar, data[2:2+n]), 2 + n, nil
+	case MYSQL_TYPE_ENUM:
+		size := int(meta & 0xff)
+		if size == 0 {
+			size = length
+		}
+		if err := need(size); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := readLittleEndian(data[:size])
+		return sqltypes.MakeTrusted(sqltypes.Uint16, strconv.AppendUint(nil, v, 10)), size, nil
+	case MYSQL_TYPE_SET:
+		size := int(meta & 0xff)
+		if size == 0 {
+			size = length
+		}
+		if err := need(size); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		v := readLittleEndian(data[:size])
+		return sqltypes.MakeTrusted(sqltypes.Uint64, strconv.AppendUint(nil, v, 10)), size, nil
+	case MYSQL_TYPE_BIT:
+		nbits := int(meta>>8)*8 + int(meta&0xff)
+		size := (nbits + 7) / 8
+		if err := need(size); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		return sqltypes.MakeTrusted(sqltypes.Bit, data[:size]), size, nil
+	case MYSQL_TYPE_BLOB, MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
+		size := int(meta)
+		if size < 1 || size > 4 {
+			return sqltypes.NULL, 0, fmt.Errorf("replication.rows.value.type[%d].invalid.meta[%d]", typ, meta)
+		}
+		if err := need(size); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		n = int(readLittleEndian(data[:size]))
+		if err := need(size + n); err != nil {
+			return sqltypes.NULL, 0, err
+		}
+		t := sqltypes.Blob
+		if typ == MYSQL_TYPE_GEOMETRY {
+			t = sqltypes.Geometry
+		}
+		return sqltypes.MakeTrusted(t, data[size:size+n]), size + n, nil
+	}
+	return sqltypes.NULL, 0, fmt.Errorf("replication.rows.value.unsupported.type[%d]", typ)
+}
+
+func readLittleEndian(data []byte) uint64 {
+	var v uint64
+	for i := len(data) - 1; i >= 0; i-- {
+		v = v<<8 | uint64(data[i])
+	}
+	return v
+}
+
+func readBigEndian(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// readFrac reads the fractional seconds part in microseconds.
+func readFrac(data []byte, fsp int) int64 {
+	switch fsp {
+	case 1, 2:
+		return int64(data[0]) * 10000
+	case 3, 4:
+		return int64(readBigEndian(data[:2])) * 100
+	case 5, 6:
+		return int64(readBigEndian(data[:3]))
+	}
+	return 0
+}
+
+func formatFrac(usec int64, fsp int) string {
+	if fsp == 0 {
+		return ""
+	}
+	s := fmt.Sprintf(".%06d", usec)
+	return s[:1+fsp]
+}
+
+func formatTimestamp(sec int64, usec int64, fsp int) string {
+	if sec == 0 && usec == 0 {
+		return "0000-00-00 00:00:00" + formatFrac(0, fsp)
+	}
+	return time.Unix(sec, 0).UTC().Format("2006-01-02 15:04:05") + formatFrac(usec, fsp)
+}
+
+// decodeDatetime2 decodes the DATETIME(fsp) value of MySQL 5.6.4+.
+func decodeDatetime2(data []byte, fsp int) (string, int, error) {
+	size := 5 + (fsp+1)/2
+	if len(data) < size {
+		return "", 0, fmt.Errorf("replication.rows.value.datetime2.need[%d].got[%d]", size, len(data))
+	}
+
+	intPart := int64(readBigEndian(data[:5])) - datetimefIntOfs
+	usec := readFrac(data[5:], fsp)
+	if intPart == 0 && usec == 0 {
+		return "0000-00-00 00:00:00" + formatFrac(0, fsp), size, nil
+	}
+	if intPart < 0 {
+		intPart = -intPart
+	}
+
+	ymd := intPart >> 17
+	ym := ymd >> 5
+	hms := intPart % (1 << 17)
+	s := fmt.Sprintf("%04d-%02d-%02d %02d:%02d:%02d", ym/13, ym%13, ymd%(1<<5), hms>>12, (hms>>6)%(1<<6), hms%(1<<6))
+	return s + formatFrac(usec, fsp), size, nil
+}
+
+// decodeTime2 decodes the TIME(fsp) value of MySQL 5.6.4+.
+func decodeTime2(data []byte, fsp int) (string, int, error) {
+	size := 3 + (fsp+1)/2
+	if len(data) < size {
+		return "", 0, fmt.Errorf("replication.rows.value.time2.need[%d].got[%d]", size, len(data))
+	}
+
+	var tmp int64
+	switch fsp {
+	case 1, 2:
+		intPart := int64(readBigEndian(data[:3])) - timefIntOfs
+		frac := int64(data[3])
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x100
+		}
+		tmp = intPart<<24 + frac*10000
+	case 3, 4:
+		intPart := int64(readBigEndian(data[:3])) - timefIntOfs
+		frac := int64(readBigEndian(data[3:5]))
+		if intPart < 0 && frac > 0 {
+			intPart++
+			frac -= 0x10000
+		}
+		tmp = intPart<<24 + frac*100
+	case 5, 6:
+		tmp = int64(readBigEndian(data[:6])) - timefOfs
+	default:
+		tmp = (int64(readBigEndian(data[:3])) - timefIntOfs) << 24
+	}
+
+	sign := ""
+	if tmp < 0 {
+		tmp = -tmp
+		sign = "-"
+	}
+	hms := tmp >> 24
+	usec := tmp % (1 << 24)
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, (hms>>12)%(1<<10), (hms>>6)%(1<<6), hms%(1<<6))
+	return s + formatFrac(usec, fsp), size, nil
+}
+
+// decodeDecimal decodes the binary DECIMAL(precision, scale).
+// strings/decimal.c bin2decimal
+func decodeDecimal(data []byte, precision int, scale int) (string, int, error) {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + dig2bytes[intg0x] + frac0*4 + dig2bytes[frac0x]
+	if len(data) < size || size == 0 {
+		return "", 0, fmt.Errorf("replication.rows.value.decimal(%d,%d).need[%d].got[%d]", precision, scale, size, len(data))
+	}
+
+	buf := make([]byte, size)
+	copy(buf, data[:size])
+	negative := buf[0]&0x80 == 0
+	buf[0] ^= 0x80
+	if negative {
+		for i := range buf {
+			buf[i] ^= 0xff
+		}
+	}
+
+	var res bytes.Buffer
+	if negative {
+		res.WriteByte('-')
+	}
+
+	pos := 0
+	var integer bytes.Buffer
+	if intg0x > 0 {
+		n := dig2bytes[intg0x]
+		integer.WriteString(strconv.FormatUint(readBigEndian(buf[pos:pos+n]), 10))
+		pos += n
+	}
+	for i := 0; i < intg0; i++ {
+		v := readBigEndian(buf[pos : pos+4])
+		if integer.Len() == 0 {
+			integer.WriteString(strconv.FormatUint(v, 10))
+		} else {
+			fmt.Fprintf(&integer, "%09d", v)
+		}
+		pos += 4
+	}
+	s := integer.String()
+	for len(s) > 1 && s[0] == '0' {
+		s = s[1:]
+	}
+	if s == "" {
+		s = "0"
+	}
+	res.WriteString(s)
+
+	if scale > 0 {
+		res.WriteByte('.')
+		for i := 0; i < frac0; i++ {
+			fmt.Fprintf(&res, "%09d", readBigEndian(buf[pos:pos+4]))
+			pos += 4
+		}
+		if frac0x > 0 {
+			n := dig2bytes[frac0x]
+			fmt.Fprintf(&res, "%0*d", frac0x, readBigEndian(buf[pos:pos+n]))
+			pos += n
+		}
+	}
+	return res.String(), size, nil
+}
+
+// encodeValue encodes one column value of the row image, the counterpart of the decodeValue.
+// The ENUM and SET are the index and the bitmap, the BIT and JSON are the raw bytes.
+func encodeValue(v sqltypes.Value, typ byte, meta uint16) ([]byte, error) {
+	var length int
+
+	// The real type of the STRING.
+	if typ == MYSQL_TYPE_STRING && meta >= 256 {
+		b0 := byte(meta >> 8)
+		b1 := byte(meta & 0xff)
+		if b0&0x30 != 0x30 {
+			length = int(uint16(b1) | (uint16((b0&0x30)^0x30) << 4))
+			b0 = b0 | 0x30
+		} else {
+			length = int(meta & 0xff)
+		}
+		typ = b0
+	} else if typ == MYSQL_TYPE_STRING {
+		length = int(meta)
+	}
+
+	s := v.String()
+	invalid := func() error {
+		return fmt.Errorf("replication.rows.value.type[%d].invalid.value[%s]", typ, s)
+	}
+	parseInt := func() (uint64, error) {
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return uint64(n), nil
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, invalid()
+		}
+		return n, nil
+	}
+
+	switch typ {
+	case MYSQL_TYPE_NULL:
+		return nil, nil
+	case MYSQL_TYPE_TINY, MYSQL_TYPE_SHORT, MYSQL_TYPE_INT24, MYSQL_TYPE_LONG, MYSQL_TYPE_LONGLONG:
+		n, err := parseInt()
+		if err != nil {
+			return nil, err
+		}
+		size := map[byte]int{MYSQL_TYPE_TINY: 1, MYSQL_TYPE_SHORT: 2, MYSQL_TYPE_INT24: 3, MYSQL_TYPE_LONG: 4, MYSQL_TYPE_LONGLONG: 8}[typ]
+		return writeLittleEndian(n, size), nil
+	case MYSQL_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(s, 32)
+		if err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian(uint64(math.Float32bits(float32(f))), 4), nil
+	case MYSQL_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian(math.Float64bits(f), 8), nil
+	case MYSQL_TYPE_NEWDECIMAL:
+		return encodeDecimal(s, int(meta>>8), int(meta&0xff))
+	case MYSQL_TYPE_YEAR:
+		year, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, invalid()
+		}
+		if year != 0 {
+			year -= 1900
+		}
+		return []byte{byte(year)}, nil
+	case MYSQL_TYPE_DATE, MYSQL_TYPE_NEWDATE:
+		var y, m, d uint64
+		if _, err := fmt.Sscanf(s, "%d-%d-%d", &y, &m, &d); err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian(y<<9|m<<5|d, 3), nil
+	case MYSQL_TYPE_TIME:
+		var h, m, sec uint64
+		if _, err := fmt.Sscanf(s, "%d:%d:%d", &h, &m, &sec); err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian(h*10000+m*100+sec, 3), nil
+	case MYSQL_TYPE_TIME2:
+		return encodeTime2(s, int(meta))
+	case MYSQL_TYPE_DATETIME:
+		var y, mon, d, h, m, sec uint64
+		if _, err := fmt.Sscanf(s, "%d-%d-%d %d:%d:%d", &y, &mon, &d, &h, &m, &sec); err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian((y*10000+mon*100+d)*1000000+h*10000+m*100+sec, 8), nil
+	case MYSQL_TYPE_DATETIME2:
+		return encodeDatetime2(s, int(meta))
+	case MYSQL_TYPE_TIMESTAMP, MYSQL_TYPE_TIMESTAMP2:
+		fsp := 0
+		if typ == MYSQL_TYPE_TIMESTAMP2 {
+			fsp = int(meta)
+		}
+		sec, usec, err := parseTimestamp(s)
+		if err != nil {
+			return nil, invalid()
+		}
+		if typ == MYSQL_TYPE_TIMESTAMP {
+			return writeLittleEndian(uint64(sec), 4), nil
+		}
+		return append(writeBigEndian(uint64(sec), 4), writeFrac(usec, fsp)...), nil
+	case MYSQL_TYPE_VARCHAR, MYSQL_TYPE_VAR_STRING:
+		length = int(meta)
+		fallthrough
+	case MYSQL_TYPE_STRING:
+		raw := v.Raw()
+		if length < 256 {
+			if len(raw) > 255 {
+				return nil, invalid()
+			}
+			return append([]byte{byte(len(raw))}, raw...), nil
+		}
+		if len(raw) > 65535 {
+			return nil, invalid()
+		}
+		return append(writeLittleEndian(uint64(len(raw)), 2), raw...), nil
+	case MYSQL_TYPE_ENUM, MYSQL_TYPE_SET:
+		size := int(meta & 0xff)
+		if size == 0 {
+			size = length
+		}
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return nil, invalid()
+		}
+		return writeLittleEndian(n, size), nil
+	case MYSQL_TYPE_BIT:
+		nbits := int(meta>>8)*8 + int(meta&0xff)
+		size := (nbits + 7) / 8
+		raw := v.Raw()
+		if len(raw) > size {
+			return nil, invalid()
+		}
+		return append(make([]byte, size-len(raw)), raw...), nil
+	case MYSQL_TYPE_BLOB, MYSQL_TYPE_TINY_BLOB, MYSQL_TYPE_MEDIUM_BLOB, MYSQL_TYPE_LONG_BLOB, MYSQL_TYPE_GEOMETRY, MYSQL_TYPE_JSON:
+		size := int(meta)
+		if size < 1 || size > 4 {
+			return nil, fmt.Errorf("replication.rows.value.type[%d].invalid.meta[%d]", typ, meta)
+		}
+		raw := v.Raw()
+		if uint64(len(raw)) >= uint64(1)<<uint(8*size) {
+			return nil, invalid()
+		}
+		return append(writeLittleEndian(uint64(len(raw)), size), raw...), nil
+	}
+	return nil, fmt.Errorf("replication.rows.value.unsupported.type[%d]", typ)
+}
+
+func writeLittleEndian(v uint64, size int) []byte {
+	data := make([]byte, size)
+	for i := 0; i < size; i++ {
+		data[i] = byte(v >> uint(8*i))
+	}
+	return data
+}
+
+func writeBigEndian(v uint64, size int) []byte {
+	data := make([]byte, size)
+	for i := size - 1; i >= 0; i-- {
+		data[i] = byte(v)
+		v >>= 8
+	}
+	return data
+}
+
+// writeFrac writes the fractional seconds part in microseconds.
+func writeFrac(usec int64, fsp int) []byte {
+	switch fsp {
+	case 1, 2:
+		return []byte{byte(usec / 10000)}
+	case 3, 4:
+		return writeBigEndian(uint64(usec/100), 2)
+	case 5, 6:
+		return writeBigEndian(uint64(usec), 3)
+	}
+	return nil
+}
+
+// splitFrac splits the value like '03:04:05.123' to the seconds and the microseconds.
+func splitFrac(s string) (string, int64, error) {
+	i := strings.IndexByte(s, '.')
+	if i < 0 {
+		return s, 0, nil
+	}
+	frac := (s[i+1:] + "000000")[:6]
+	usec, err := strconv.ParseInt(frac, 10, 64)
+	if err != nil {
+		return "", 0, err
+	}
+	return s[:i], usec, nil
+}
+
+func parseTimestamp(s string) (int64, int64, error) {
+	s, usec, err := splitFrac(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if s == "0000-00-00 00:00:00" {
+		return 0, usec, nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", s)
+	if err != nil {
+		return 0, 0, err
+	}
+	return t.Unix(), usec, nil
+}
+
+// encodeDatetime2 encodes the DATETIME(fsp) value of MySQL 5.6.4+.
+func encodeDatetime2(s string, fsp int) ([]byte, error) {
+	var y, mon, d, h, m, sec int64
+	date, usec, err := splitFrac(s)
+	if err != nil {
+		return nil, fmt.Errorf("replication.rows.value.invalid.datetime[%s]", s)
+	}
+	if _, err := fmt.Sscanf(date, "%d-%d-%d %d:%d:%d", &y, &mon, &d, &h, &m, &sec); err != nil {
+		return nil, fmt.Errorf("replication.rows.value.invalid.datetime[%s]", s)
+	}
+	intPart := ((y*13+mon)<<5|d)<<17 | h<<12 | m<<6 | sec
+	return append(writeBigEndian(uint64(intPart+datetimefIntOfs), 5), writeFrac(usec, fsp)...), nil
+}
+
+// encodeTime2 encodes the TIME(fsp) value of MySQL 5.6.4+.
+// sql-common/my_time.c my_time_packed_to_binary
+func encodeTime2(s string, fsp int) ([]byte, error) {
+	var h, m, sec int64
+	negative := strings.HasPrefix(s, "-")
+	hms, usec, err := splitFrac(strings.TrimPrefix(s, "-"))
+	if err != nil {
+		return nil, fmt.Errorf("replication.rows.value.invalid.time[%s]", s)
+	}
+	if _, err := fmt.Sscanf(hms, "%d:%d:%d", &h, &m, &sec); err != nil {
+		return nil, fmt.Errorf("replication.rows.value.invalid.time[%s]", s)
+	}
+
+	// Truncated to the fsp as the decoder does.
+	if fsp < 6 {
+		unit := int64(math.Pow10(6 - fsp))
+		usec = usec / unit * unit
+	}
+	packed := (h<<12|m<<6|sec)<<24 + usec
+	if negative {
+		packed = -packed
+	}
+	intPart, frac := packed>>24, packed%(1<<24)
+
+	switch fsp {
+	case 1, 2:
+		return append(writeBigEndian(uint64(intPart+timefIntOfs), 3), byte(int8(frac/10000))), nil
+	case 3, 4:
+		return append(writeBigEndian(uint64(intPart+timefIntOfs), 3), writeBigEndian(uint64(frac/100), 2)...), nil
+	case 5, 6:
+		return writeBigEndian(uint64(packed+timefOfs), 6), nil
+	}
+	return writeBigEndian(uint64(intPart+timefIntOfs), 3), nil
+}
+
+// encodeDecimal encodes the DECIMAL(precision, scale) in binary.
+// strings/decimal.c decimal2bin
+func encodeDecimal(s string, precision int, scale int) ([]byte, error) {
+	intg := precision - scale
+	intg0, intg0x := intg/9, intg%9
+	frac0, frac0x := scale/9, scale%9
+	size := intg0*4 + dig2bytes[intg0x] + frac0*4 + dig2bytes[frac0x]
+	if size == 0 {
+		return nil, fmt.Errorf("replication.rows.value.invalid.decimal(%d,%d)", precision, scale)
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	digits := strings.TrimLeft(strings.TrimPrefix(s, "-"), "+")
+	integer, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+	integer = strings.TrimLeft(integer, "0")
+	if len(integer) > intg || strings.Trim(integer+fraction, "0123456789") != "" {
+		return nil, fmt.Errorf("replication.rows.value.decimal(%d,%d).invalid.value[%s]", precision, scale, s)
+	}
+	integer = strings.Repeat("0", intg-len(integer)) + integer
+	if len(fraction) > scale {
+		fraction = fraction[:scale]
+	}
+	fraction += strings.Repeat("0", scale-len(fraction))
+
+	var data []byte
+	group := func(digits string) {
+		v, _ := strconv.ParseUint(digits, 10, 64)
+		n := 4
+		if len(digits) < 9 {
+			n = dig2bytes[len(digits)]
+		}
+		data = append(data, writeBigEndian(v, n)...)
+	}
+	if intg0x > 0 {
+		group(integer[:intg0x])
+	}
+	for i := 0; i < intg0; i++ {
+		group(integer[intg0x+i*9 : intg0x+i*9+9])
+	}
+	for i := 0; i < frac0; i++ {
+		group(fraction[i*9 : i*9+9])
+	}
+	if frac0x > 0 {
+		group(fraction[frac0*9:])
+	}
+
+	if negative {
+		for i := range data {
+			data[i] ^= 0xff
+		}
+	}
+	data[0] ^= 0x80
+	return data, nil
+}
@@ -0,0 +1,130 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package replication
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// valueTests are the row image values of the column types.
+var valueTests = []struct {
+	typ  byte
+	meta uint16
+	data string
+	want sqltypes.Value
+	size int
+}{
+	{MYSQL_TYPE_TINY, 0, "ff", sqltypes.MakeTrusted(querypb.Type_INT8, []byte("-1")), 1},
+	{MYSQL_TYPE_SHORT, 0, "3930", sqltypes.MakeTrusted(querypb.Type_INT16, []byte("12345")), 2},
+	{MYSQL_TYPE_INT24, 0, "ffffff", sqltypes.MakeTrusted(querypb.Type_INT24, []byte("-1")), 3},
+	{MYSQL_TYPE_LONG, 0, "0a000000", sqltypes.MakeTrusted(querypb.Type_INT32, []byte("10")), 4},
+	{MYSQL_TYPE_LONGLONG, 0, "feffffffffffffff", sqltypes.MakeTrusted(querypb.Type_INT64, []byte("-2")), 8},
+	{MYSQL_TYPE_FLOAT, 4, "0000c03f", sqltypes.MakeTrusted(querypb.Type_FLOAT32, []byte("1.5")), 4},
+	{MYSQL_TYPE_DOUBLE, 8, "000000000000f83f", sqltypes.MakeTrusted(querypb.Type_FLOAT64, []byte("1.5")), 8},
+	{MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, "800004d238", sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("1234.56")), 5},
+	{MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, "7ffffb2dc7", sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("-1234.56")), 5},
+	{MYSQL_TYPE_NEWDECIMAL, 10<<8 | 2, "8000000005", sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("0.05")), 5},
+	{MYSQL_TYPE_YEAR, 0, "75", sqltypes.MakeTrusted(querypb.Type_YEAR, []byte("2017")), 1},
+	{MYSQL_TYPE_DATE, 0, "22c20f", sqltypes.MakeTrusted(querypb.Type_DATE, []byte("2017-01-02")), 3},
+	{MYSQL_TYPE_DATETIME2, 0, "999b843105", sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2017-01-02 03:04:05")), 5},
+	{MYSQL_TYPE_DATETIME2, 6, "999b84310501e240", sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2017-01-02 03:04:05.123456")), 8},
+	{MYSQL_TYPE_DATETIME2, 0, "8000000000", sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("0000-00-00 00:00:00")), 5},
+	{MYSQL_TYPE_TIME2, 3, "80c8b81ed2", sqltypes.MakeTrusted(querypb.Type_TIME, []byte("12:34:56.789")), 5},
+	{MYSQL_TYPE_TIME2, 0, "80c8b8", sqltypes.MakeTrusted(querypb.Type_TIME, []byte("12:34:56")), 3},
+	{MYSQL_TYPE_TIMESTAMP2, 0, "59682f00", sqltypes.MakeTrusted(querypb.Type_TIMESTAMP, []byte("2017-07-14 02:40:00")), 4},
+	{MYSQL_TYPE_TIMESTAMP, 0, "002f6859", sqltypes.MakeTrusted(querypb.Type_TIMESTAMP, []byte("2017-07-14 02:40:00")), 4},
+	{MYSQL_TYPE_VARCHAR, 20, "03616263", sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("abc")), 4},
+	{MYSQL_TYPE_VARCHAR, 300, "0300616263", sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("abc")), 5},
+	// CHAR(10), real type STRING.
+	{MYSQL_TYPE_STRING, 0xfe0a, "026162", sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("ab")), 3},
+	// ENUM with 1 byte.
+	{MYSQL_TYPE_STRING, 0xf701, "02", sqltypes.MakeTrusted(querypb.Type_UINT16, []byte("2")), 1},
+	// SET with 1 byte.
+	{MYSQL_TYPE_STRING, 0xf801, "05", sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("5")), 1},
+	// BIT(10).
+	{MYSQL_TYPE_BIT, 1<<8 | 2, "0301", sqltypes.MakeTrusted(querypb.Type_BIT, []byte{0x03, 0x01}), 2},
+	// BLOB with 2 bytes length.
+	{MYSQL_TYPE_BLOB, 2, "0200ffee", sqltypes.MakeTrusted(querypb.Type_BLOB, []byte{0xff, 0xee}), 4},
+}
+
+func TestDecodeValue(t *testing.T) {
+	for _, test := range valueTests {
+		data, err := hex.DecodeString(test.data)
+		assert.Nil(t, err)
+		got, size, err := decodeValue(data, test.typ, test.meta)
+		assert.Nil(t, err, test.data)
+		assert.Equal(t, test.want, got, test.data)
+		assert.Equal(t, test.size, size, test.data)
+	}
+
+	// Short data.
+	for _, typ := range []byte{MYSQL_TYPE_LONG, MYSQL_TYPE_VARCHAR, MYSQL_TYPE_NEWDECIMAL, MYSQL_TYPE_DATETIME2, MYSQL_TYPE_BLOB} {
+		_, _, err := decodeValue([]byte{0x01}, typ, 0x0a02)
+		assert.NotNil(t, err)
+	}
+	_, _, err := decodeValue([]byte{0x01}, 0x20, 0)
+	assert.NotNil(t, err)
+}
+
+func TestEncodeValue(t *testing.T) {
+	for _, test := range valueTests {
+		got, err := encodeValue(test.want, test.typ, test.meta)
+		assert.Nil(t, err, test.data)
+		assert.Equal(t, test.data, hex.EncodeToString(got))
+	}
+
+	// Round trip.
+	tests := []struct {
+		typ  byte
+		meta uint16
+		want sqltypes.Value
+	}{
+		{MYSQL_TYPE_TIME2, 0, sqltypes.MakeTrusted(querypb.Type_TIME, []byte("-12:34:56"))},
+		{MYSQL_TYPE_TIME2, 1, sqltypes.MakeTrusted(querypb.Type_TIME, []byte("-00:00:01.5"))},
+		{MYSQL_TYPE_TIME2, 4, sqltypes.MakeTrusted(querypb.Type_TIME, []byte("-838:59:59.1234"))},
+		{MYSQL_TYPE_TIME2, 6, sqltypes.MakeTrusted(querypb.Type_TIME, []byte("-01:02:03.000004"))},
+		{MYSQL_TYPE_TIMESTAMP2, 3, sqltypes.MakeTrusted(querypb.Type_TIMESTAMP, []byte("2017-07-14 02:40:00.120"))},
+		{MYSQL_TYPE_DATETIME, 0, sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2017-01-02 03:04:05"))},
+		{MYSQL_TYPE_NEWDECIMAL, 30<<8 | 12, sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("-123456789012345678.123456789012"))},
+		{MYSQL_TYPE_NEWDECIMAL, 5<<8 | 5, sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("0.00012"))},
+		{MYSQL_TYPE_BLOB, 3, sqltypes.MakeTrusted(querypb.Type_BLOB, []byte("hello"))},
+	}
+	for _, test := range tests {
+		data, err := encodeValue(test.want, test.typ, test.meta)
+		assert.Nil(t, err, test.want.String())
+		got, size, err := decodeValue(data, test.typ, test.meta)
+		assert.Nil(t, err, test.want.String())
+		assert.Equal(t, test.want, got)
+		assert.Equal(t, len(data), size)
+	}
+
+	// Invalid values.
+	bads := []struct {
+		typ   byte
+		meta  uint16
+		value string
+	}{
+		{MYSQL_TYPE_LONG, 0, "abc"},
+		{MYSQL_TYPE_NEWDECIMAL, 4<<8 | 2, "123.45"},
+		{MYSQL_TYPE_DATETIME2, 0, "2017-01"},
+		{MYSQL_TYPE_BIT, 1, "ab"},
+		{0x20, 0, "1"},
+	}
+	for _, bad := range bads {
+		_, err := encodeValue(sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(bad.value)), bad.typ, bad.meta)
+		assert.NotNil(t, err, bad.value)
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"strings"
+)
+
+// Charset is one row of the SHOW CHARACTER SET.
+type Charset struct {
+	Name             string
+	DefaultCollation string
+	Description      string
+
+	// MaxLen is the max bytes of one character.
+	MaxLen int
+}
+
+// Collation is one row of the SHOW COLLATION.
+type Collation struct {
+	ID      uint16
+	Name    string
+	Charset string
+}
+
+var (
+	collationsByID   = make(map[uint16]*Collation, len(collationCatalog))
+	collationsByName = make(map[string]*Collation, len(collationCatalog))
+	charsetsByName   = make(map[string]*Charset, len(charsetCatalog))
+)
+
+func init() {
+	for i := range collationCatalog {
+		c := &collationCatalog[i]
+		collationsByID[c.ID] = c
+		collationsByName[c.Name] = c
+	}
+	for i := range charsetCatalog {
+		cs := &charsetCatalog[i]
+		charsetsByName[cs.Name] = cs
+	}
+}
+
+func characterSetMap() map[string]uint8 {
+	m := make(map[string]uint8, len(charsetCatalog))
+	for _, cs := range charsetCatalog {
+		for _, c := range collationCatalog {
+			if c.Name == cs.DefaultCollation && c.ID <= 0xff {
+				m[cs.Name] = uint8(c.ID)
+			}
+		}
+	}
+	return m
+}
+
+// IsDefault checks the collation is the MySQL 5.7 default of its charset.
+func (c *Collation) IsDefault() bool {
+	cs, ok := charsetsByName[c.Charset]
+	return ok && cs.DefaultCollation == c.Name
+}
+
+// MaxLen returns the max bytes of one character, it's the column length of a char in the field metadata.
+func (c *Collation) MaxLen() int {
+	if cs, ok := charsetsByName[c.Charset]; ok {
+		return cs.MaxLen
+	}
+	return 1
+}
+
+// LookupCollation returns the collation of the id.
+func LookupCollation(id uint16) (*Collation, bool) {
+	c, ok := collationsByID[id]
+	return c, ok
+}
+
+// LookupCollationByName returns the collation of the name, case insensitive.
+func LookupCollationByName(name string) (*Collation, bool) {
+	c, ok := collationsByName[strings.ToLower(name)]
+	return c, ok
+}
+
+// LookupCharset returns the charset of the name, case insensitive.
+// The utf8mb3 is the alias of the utf8.
+func LookupCharset(name string) (*Charset, bool) {
+	name = strings.ToLower(name)
+	if name == "utf8mb3" {
+		name = "utf8"
+	}
+	cs, ok := charsetsByName[name]
+	return cs, ok
+}
+
+// DefaultCollationOf returns the default collation of the charset,
+// the mysql80 picks the utf8mb4_0900_ai_ci for the utf8mb4.
+func DefaultCollationOf(charset string, mysql80 bool) (*Collation, bool) {
+	cs, ok := LookupCharset(charset)
+	if !ok {
+		return nil, false
+	}
+	if mysql80 && cs.Name == "utf8mb4" {
+		return LookupCollation(CollationUtf8mb40900AICI)
+	}
+	return LookupCollationByName(cs.DefaultCollation)
+}
+
+// Collations returns the collations ordered by the id.
+func Collations() []Collation {
+	return append([]Collation(nil), collationCatalog...)
+}
+
+// Charsets returns the charsets ordered by the name.
+func Charsets() []Charset {
+	return append([]Charset(nil), charsetCatalog...)
+}
+
+// CharacterSetName returns the charset name of the collation id, empty if it's unknown.
+func CharacterSetName(id uint8) string {
+	if c, ok := LookupCollation(uint16(id)); ok {
+		return c.Charset
+	}
+	return ""
+}
@@ -0,0 +1,333 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+// The catalog is generated from the INFORMATION_SCHEMA.CHARACTER_SETS and COLLATIONS of the MySQL 5.7 and 8.0.
+
+// charsetCatalog are the charsets with the MySQL 5.7 default collations.
+var charsetCatalog = []Charset{
+	{Name: "armscii8", DefaultCollation: "armscii8_general_ci", Description: "ARMSCII-8 Armenian", MaxLen: 1},
+	{Name: "ascii", DefaultCollation: "ascii_general_ci", Description: "US ASCII", MaxLen: 1},
+	{Name: "big5", DefaultCollation: "big5_chinese_ci", Description: "Big5 Traditional Chinese", MaxLen: 2},
+	{Name: "binary", DefaultCollation: "binary", Description: "Binary pseudo charset", MaxLen: 1},
+	{Name: "cp1250", DefaultCollation: "cp1250_general_ci", Description: "Windows Central European", MaxLen: 1},
+	{Name: "cp1251", DefaultCollation: "cp1251_general_ci", Description: "Windows Cyrillic", MaxLen: 1},
+	{Name: "cp1256", DefaultCollation: "cp1256_general_ci", Description: "Windows Arabic", MaxLen: 1},
+	{Name: "cp1257", DefaultCollation: "cp1257_general_ci", Description: "Windows Baltic", MaxLen: 1},
+	{Name: "cp850", DefaultCollation: "cp850_general_ci", Description: "DOS West European", MaxLen: 1},
+	{Name: "cp852", DefaultCollation: "cp852_general_ci", Description: "DOS Central European", MaxLen: 1},
+	{Name: "cp866", DefaultCollation: "cp866_general_ci", Description: "DOS Russian", MaxLen: 1},
+	{Name: "cp932", DefaultCollation: "cp932_japanese_ci", Description: "SJIS for Windows Japanese", MaxLen: 2},
+	{Name: "dec8", DefaultCollation: "dec8_swedish_ci", Description: "DEC West European", MaxLen: 1},
+	{Name: "eucjpms", DefaultCollation: "eucjpms_japanese_ci", Description: "UJIS for Windows Japanese", MaxLen: 3},
+	{Name: "euckr", DefaultCollation: "euckr_korean_ci", Description: "EUC-KR Korean", MaxLen: 2},
+	{Name: "gb18030", DefaultCollation: "gb18030_chinese_ci", Description: "China National Standard GB18030", MaxLen: 4},
+	{Name: "gb2312", DefaultCollation: "gb2312_chinese_ci", Description: "GB2312 Simplified Chinese", MaxLen: 2},
+	{Name: "gbk", DefaultCollation: "gbk_chinese_ci", Description: "GBK Simplified Chinese", MaxLen: 2},
+	{Name: "geostd8", DefaultCollation: "geostd8_general_ci", Description: "GEOSTD8 Georgian", MaxLen: 1},
+	{Name: "greek", DefaultCollation: "greek_general_ci", Description: "ISO 8859-7 Greek", MaxLen: 1},
+	{Name: "hebrew", DefaultCollation: "hebrew_general_ci", Description: "ISO 8859-8 Hebrew", MaxLen: 1},
+	{Name: "hp8", DefaultCollation: "hp8_english_ci", Description: "HP West European", MaxLen: 1},
+	{Name: "keybcs2", DefaultCollation: "keybcs2_general_ci", Description: "DOS Kamenicky Czech-Slovak", MaxLen: 1},
+	{Name: "koi8r", DefaultCollation: "koi8r_general_ci", Description: "KOI8-R Relcom Russian", MaxLen: 1},
+	{Name: "koi8u", DefaultCollation: "koi8u_general_ci", Description: "KOI8-U Ukrainian", MaxLen: 1},
+	{Name: "latin1", DefaultCollation: "latin1_swedish_ci", Description: "cp1252 West European", MaxLen: 1},
+	{Name: "latin2", DefaultCollation: "latin2_general_ci", Description: "ISO 8859-2 Central European", MaxLen: 1},
+	{Name: "latin5", DefaultCollation: "latin5_turkish_ci", Description: "ISO 8859-9 Turkish", MaxLen: 1},
+	{Name: "latin7", DefaultCollation: "latin7_general_ci", Description: "ISO 8859-13 Baltic", MaxLen: 1},
+	{Name: "macce", DefaultCollation: "macce_general_ci", Description: "Mac Central European", MaxLen: 1},
+	{Name: "macroman", DefaultCollation: "macroman_general_ci", Description: "Mac West European", MaxLen: 1},
+	{Name: "sjis", DefaultCollation: "sjis_japanese_ci", Description: "Shift-JIS Japanese", MaxLen: 2},
+	{Name: "swe7", DefaultCollation: "swe7_swedish_ci", Description: "7bit Swedish", MaxLen: 1},
+	{Name: "tis620", DefaultCollation: "tis620_thai_ci", Description: "TIS620 Thai", MaxLen: 1},
+	{Name: "ucs2", DefaultCollation: "ucs2_general_ci", Description: "UCS-2 Unicode", MaxLen: 2},
+	{Name: "ujis", DefaultCollation: "ujis_japanese_ci", Description: "EUC-JP Japanese", MaxLen: 3},
+	{Name: "utf16", DefaultCollation: "utf16_general_ci", Description: "UTF-16 Unicode", MaxLen: 4},
+	{Name: "utf16le", DefaultCollation: "utf16le_general_ci", Description: "UTF-16LE Unicode", MaxLen: 4},
+	{Name: "utf32", DefaultCollation: "utf32_general_ci", Description: "UTF-32 Unicode", MaxLen: 4},
+	{Name: "utf8", DefaultCollation: "utf8_general_ci", Description: "UTF-8 Unicode", MaxLen: 3},
+	{Name: "utf8mb4", DefaultCollation: "utf8mb4_general_ci", Description: "UTF-8 Unicode", MaxLen: 4},
+}
+
+// collationCatalog are the collations ordered by the id.
+var collationCatalog = []Collation{
+	{ID: 1, Name: "big5_chinese_ci", Charset: "big5"},
+	{ID: 2, Name: "latin2_czech_cs", Charset: "latin2"},
+	{ID: 3, Name: "dec8_swedish_ci", Charset: "dec8"},
+	{ID: 4, Name: "cp850_general_ci", Charset: "cp850"},
+	{ID: 5, Name: "latin1_german1_ci", Charset: "latin1"},
+	{ID: 6, Name: "hp8_english_ci", Charset: "hp8"},
+	{ID: 7, Name: "koi8r_general_ci", Charset: "koi8r"},
+	{ID: 8, Name: "latin1_swedish_ci", Charset: "latin1"},
+	{ID: 9, Name: "latin2_general_ci", Charset: "latin2"},
+	{ID: 10, Name: "swe7_swedish_ci", Charset: "swe7"},
+	{ID: 11, Name: "ascii_general_ci", Charset: "ascii"},
+	{ID: 12, Name: "ujis_japanese_ci", Charset: "ujis"},
+	{ID: 13, Name: "sjis_japanese_ci", Charset: "sjis"},
+	{ID: 14, Name: "cp1251_bulgarian_ci", Charset: "cp1251"},
+	{ID: 15, Name: "latin1_danish_ci", Charset: "latin1"},
+	{ID: 16, Name: "hebrew_general_ci", Charset: "hebrew"},
+	{ID: 18, Name: "tis620_thai_ci", Charset: "tis620"},
+	{ID: 19, Name: "euckr_korean_ci", Charset: "euckr"},
+	{ID: 20, Name: "latin7_estonian_cs", Charset: "latin7"},
+	{ID: 21, Name: "latin2_hungarian_ci", Charset: "latin2"},
+	{ID: 22, Name: "koi8u_general_ci", Charset: "koi8u"},
+	{ID: 23, Name: "cp1251_ukrainian_ci", Charset: "cp1251"},
+	{ID: 24, Name: "gb2312_chinese_ci", Charset: "gb2312"},
+	{ID: 25, Name: "greek_general_ci", Charset: "greek"},
+	{ID: 26, Name: "cp1250_general_ci", Charset: "cp1250"},
+	{ID: 27, Name: "latin2_croatian_ci", Charset: "latin2"},
+	{ID: 28, Name: "gbk_chinese_ci", Charset: "gbk"},
+	{ID: 29, Name: "cp1257_lithuanian_ci", Charset: "cp1257"},
+	{ID: 30, Name: "latin5_turkish_ci", Charset: "latin5"},
+	{ID: 31, Name: "latin1_german2_ci", Charset: "latin1"},
+	{ID: 32, Name: "armscii8_general_ci", Charset: "armscii8"},
+	{ID: 33, Name: "utf8_general_ci", Charset: "utf8"},
+	{ID: 34, Name: "cp1250_czech_cs", Charset: "cp1250"},
+	{ID: 35, Name: "ucs2_general_ci", Charset: "ucs2"},
+	{ID: 36, Name: "cp866_general_ci", Charset: "cp866"},
+	{ID: 37, Name: "keybcs2_general_ci", Charset: "keybcs2"},
+	{ID: 38, Name: "macce_general_ci", Charset: "macce"},
+	{ID: 39, Name: "macroman_general_ci", Charset: "macroman"},
+	{ID: 40, Name: "cp852_general_ci", Charset: "cp852"},
+	{ID: 41, Name: "latin7_general_ci", Charset: "latin7"},
+	{ID: 42, Name: "latin7_general_cs", Charset: "latin7"},
+	{ID: 43, Name: "macce_bin", Charset: "macce"},
+	{ID: 44, Name: "cp1250_croatian_ci", Charset: "cp1250"},
+	{ID: 45, Name: "utf8mb4_general_ci", Charset: "utf8mb4"},
+	{ID: 46, Name: "utf8mb4_bin", Charset: "utf8mb4"},
+	{ID: 47, Name: "latin1_bin", Charset: "latin1"},
+	{ID: 48, Name: "latin1_general_ci", Charset: "latin1"},
+	{ID: 49, Name: "latin1_general_cs", Charset: "latin1"},
+	{ID: 50, Name: "cp1251_bin", Charset: "cp1251"},
+	{ID: 51, Name: "cp1251_general_ci", Charset: "cp1251"},
+	{ID: 52, Name: "cp1251_general_cs", Charset: "cp1251"},
+	{ID: 53, Name: "macroman_bin", Charset: "macroman"},
+	{ID: 54, Name: "utf16_general_ci", Charset: "utf16"},
+	{ID: 55, Name: "utf16_bin", Charset: "utf16"},
+	{ID: 56, Name: "utf16le_general_ci", Charset: "utf16le"},
+	{ID: 57, Name: "cp1256_general_ci", Charset: "cp1256"},
+	{ID: 58, Name: "cp1257_bin", Charset: "cp1257"},
+	{ID: 59, Name: "cp1257_general_ci", Charset: "cp1257"},
+	{ID: 60, Name: "utf32_general_ci", Charset: "utf32"},
+	{ID: 61, Name: "utf32_bin", Charset: "utf32"},
+	{ID: 62, Name: "utf16le_bin", Charset: "utf16le"},
+	{ID: 63, Name: "binary", Charset: "binary"},
+	{ID: 64, Name: "armscii8_bin", Charset: "armscii8"},
+	{ID: 65, Name: "ascii_bin", Charset: "ascii"},
+	{ID: 66, Name: "cp1250_bin", Charset: "cp1250"},
+	{ID: 67, Name: "cp1256_bin", Charset: "cp1256"},
+	{ID: 68, Name: "cp866_bin", Charset: "cp866"},
+	{ID: 69, Name: "dec8_bin", Charset: "dec8"},
+	{ID: 70, Name: "greek_bin", Charset: "greek"},
+	{ID: 71, Name: "hebrew_bin", Charset: "hebrew"},
+	{ID: 72, Name: "hp8_bin", Charset: "hp8"},
+	{ID: 73, Name: "keybcs2_bin", Charset: "keybcs2"},
+	{ID: 74, Name: "koi8r_bin", Charset: "koi8r"},
+	{ID: 75, Name: "koi8u_bin", Charset: "koi8u"},
+	{ID: 76, Name: "utf8_tolower_ci", Charset: "utf8"},
+	{ID: 77, Name: "latin2_bin", Charset: "latin2"},
+	{ID: 78, Name: "latin5_bin", Charset: "latin5"},
+	{ID: 79, Name: "latin7_bin", Charset: "latin7"},
+	{ID: 80, Name: "cp850_bin", Charset: "cp850"},
+	{ID: 81, Name: "cp852_bin", Charset: "cp852"},
+	{ID: 82, Name: "swe7_bin", Charset: "swe7"},
+	{ID: 83, Name: "utf8_bin", Charset: "utf8"},
+	{ID: 84, Name: "big5_bin", Charset: "big5"},
+	{ID: 85, Name: "euckr_bin", Charset: "euckr"},
+	{ID: 86, Name: "gb2312_bin", Charset: "gb2312"},
+	{ID: 87, Name: "gbk_bin", Charset: "gbk"},
+	{ID: 88, Name: "sjis_bin", Charset: "sjis"},
+	{ID: 89, Name: "tis620_bin", Charset: "tis620"},
+	{ID: 90, Name: "ucs2_bin", Charset: "ucs2"},
+	{ID: 91, Name: "ujis_bin", Charset: "ujis"},
+	{ID: 92, Name: "geostd8_general_ci", Charset: "geostd8"},
+	{ID: 93, Name: "geostd8_bin", Charset: "geostd8"},
+	{ID: 94, Name: "latin1_spanish_ci", Charset: "latin1"},
+	{ID: 95, Name: "cp932_japanese_ci", Charset: "cp932"},
+	{ID: 96, Name: "cp932_bin", Charset: "cp932"},
+	{ID: 97, Name: "eucjpms_japanese_ci", Charset: "eucjpms"},
+	{ID: 98, Name: "eucjpms_bin", Charset: "eucjpms"},
+	{ID: 99, Name: "cp1250_polish_ci", Charset: "cp1250"},
+	{ID: 101, Name: "utf16_unicode_ci", Charset: "utf16"},
+	{ID: 102, Name: "utf16_icelandic_ci", Charset: "utf16"},
+	{ID: 103, Name: "utf16_latvian_ci", Charset: "utf16"},
+	{ID: 104, Name: "utf16_romanian_ci", Charset: "utf16"},
+	{ID: 105, Name: "utf16_slovenian_ci", Charset: "utf16"},
+	{ID: 106, Name: "utf16_polish_ci", Charset: "utf16"},
+	{ID: 107, Name: "utf16_estonian_ci", Charset: "utf16"},
+	{ID: 108, Name: "utf16_spanish_ci", Charset: "utf16"},
+	{ID: 109, Name: "utf16_swedish_ci", Charset: "utf16"},
+	{ID: 110, Name: "utf16_turkish_ci", Charset: "utf16"},
+	{ID: 111, Name: "utf16_czech_ci", Charset: "utf16"},
+	{ID: 112, Name: "utf16_danish_ci", Charset: "utf16"},
+	{ID: 113, Name: "utf16_lithuanian_ci", Charset: "utf16"},
+	{ID: 114, Name: "utf16_slovak_ci", Charset: "utf16"},
+	{ID: 115, Name: "utf16_spanish2_ci", Charset: "utf16"},
+	{ID: 116, Name: "utf16_roman_ci", Charset: "utf16"},
+	{ID: 117, Name: "utf16_persian_ci", Charset: "utf16"},
+	{ID: 118, Name: "utf16_esperanto_ci", Charset: "utf16"},
+	{ID: 119, Name: "utf16_hungarian_ci", Charset: "utf16"},
+	{ID: 120, Name: "utf16_sinhala_ci", Charset: "utf16"},
+	{ID: 121, Name: "utf16_german2_ci", Charset: "utf16"},
+	{ID: 122, Name: "utf16_croatian_ci", Charset: "utf16"},
+	{ID: 123, Name: "utf16_unicode_520_ci", Charset: "utf16"},
+	{ID: 124, Name: "utf16_vietnamese_ci", Charset: "utf16"},
+	{ID: 128, Name: "ucs2_unicode_ci", Charset: "ucs2"},
+	{ID: 129, Name: "ucs2_icelandic_ci", Charset: "ucs2"},
+	{ID: 130, Name: "ucs2_latvian_ci", Charset: "ucs2"},
+	{ID: 131, Name: "ucs2_romanian_ci", Charset: "ucs2"},
+	{ID: 132, Name: "ucs2_slovenian_ci", Charset: "ucs2"},
+	{ID: 133, Name: "ucs2_polish_ci", Charset: "ucs2"},
+	{ID: 134, Name: "ucs2_estonian_ci", Charset: "ucs2"},
+	{ID: 135, Name: "ucs2_spanish_ci", Charset: "ucs2"},
+	{ID: 136, Name: "ucs2_swedish_ci", Charset: "ucs2"},
+	{ID: 137, Name: "ucs2_turkish_ci", Charset: "ucs2"},
+	{ID: 138, Name: "ucs2_czech_ci", Charset: "ucs2"},
+	{ID: 139, Name: "ucs2_danish_ci", Charset: "ucs2"},
+	{ID: 140, Name: "ucs2_lithuanian_ci", Charset: "ucs2"},
+	{ID: 141, Name: "ucs2_slovak_ci", Charset: "ucs2"},
+	{ID: 142, Name: "ucs2_spanish2_ci", Charset: "ucs2"},
+	{ID: 143, Name: "ucs2_roman_ci", Charset: "ucs2"},
+	{ID: 144, Name: "ucs2_persian_ci", Charset: "ucs2"},
+	{ID: 145, Name: "ucs2_esperanto_ci", Charset: "ucs2"},
+	{ID: 146, Name: "ucs2_hungarian_ci", Charset: "ucs2"},
+	{ID: 147, Name: "ucs2_sinhala_ci", Charset: "ucs2"},
+	{ID: 148, Name: "ucs2_german2_ci", Charset: "ucs2"},
+	{ID: 149, Name: "ucs2_croatian_ci", Charset: "ucs2"},
+	{ID: 150, Name: "ucs2_unicode_520_ci", Charset: "ucs2"},
+	{ID: 151, Name: "ucs2_vietnamese_ci", Charset: "ucs2"},
+	{ID: 159, Name: "ucs2_general_mysql500_ci", Charset: "ucs2"},
+	{ID: 160, Name: "utf32_unicode_ci", Charset: "utf32"},
+	{ID: 161, Name: "utf32_icelandic_ci", Charset: "utf32"},
+	{ID: 162, Name: "utf32_latvian_ci", Charset: "utf32"},
+	{ID: 163, Name: "utf32_romanian_ci", Charset: "utf32"},
+	{ID: 164, Name: "utf32_slovenian_ci", Charset: "utf32"},
+	{ID: 165, Name: "utf32_polish_ci", Charset: "utf32"},
+	{ID: 166, Name: "utf32_estonian_ci", Charset: "utf32"},
+	{ID: 167, Name: "utf32_spanish_ci", Charset: "utf32"},
+	{ID: 168, Name: "utf32_swedish_ci", Charset: "utf32"},
+	{ID: 169, Name: "utf32_turkish_ci", Charset: "utf32"},
+	{ID: 170, Name: "utf32_czech_ci", Charset: "utf32"},
+	{ID: 171, Name: "utf32_danish_ci", Charset: "utf32"},
+	{ID: 172, Name: "utf32_lithuanian_ci", Charset: "utf32"},
+	{ID: 173, Name: "utf32_slovak_ci", Charset: "utf32"},
+	{ID: 174, Name: "utf32_spanish2_ci", Charset: "utf32"},
+	{ID: 175, Name: "utf32_roman_ci", Charset: "utf32"},
+	{ID: 176, Name: "utf32_persian_ci", Charset: "utf32"},
+	{ID: 177, Name: "utf32_esperanto_ci", Charset: "utf32"},
+	{ID: 178, Name: "utf32_hungarian_ci", Charset: "utf32"},
+	{ID: 179, Name: "utf32_sinhala_ci", Charset: "utf32"},
+	{ID: 180, Name: "utf32_german2_ci", Charset: "utf32"},
+	{ID: 181, Name: "utf32_croatian_ci", Charset: "utf32"},
+	{ID: 182, Name: "utf32_unicode_520_ci", Charset: "utf32"},
+	{ID: 183, Name: "utf32_vietnamese_ci", Charset: "utf32"},
+	{ID: 192, Name: "utf8_unicode_ci", Charset: "utf8"},
+	{ID: 193, Name: "utf8_icelandic_ci", Charset: "utf8"},
+	{ID: 194, Name: "utf8_latvian_ci", Charset: "utf8"},
+	{ID: 195, Name: "utf8_romanian_ci", Charset: "utf8"},
+	{ID: 196, Name: "utf8_slovenian_ci", Charset: "utf8"},
+	{ID: 197, Name: "utf8_polish_ci", Charset: "utf8"},
+	{ID: 198, Name: "utf8_estonian_ci", Charset: "utf8"},
+	{ID: 199, Name: "utf8_spanish_ci", Charset: "utf8"},
+	{ID: 200, Name: "utf8_swedish_ci", Charset: "utf8"},
+	{ID: 201, Name: "utf8_turkish_ci", Charset: "utf8"},
+	{ID: 202, Name: "utf8_czech_ci", Charset: "utf8"},
+	{ID: 203, Name: "utf8_danish_ci", Charset: "utf8"},
+	{ID: 204, Name: "utf8_lithuanian_ci", Charset: "utf8"},
+	{ID: 205, Name: "utf8_slovak_ci", Charset: "utf8"},
+	{ID: 206, Name: "utf8_spanish2_ci", Charset: "utf8"},
+	{ID: 207, Name: "utf8_roman_ci", Charset: "utf8"},
+	{ID: 208, Name: "utf8_persian_ci", Charset: "utf8"},
+	{ID: 209, Name: "utf8_esperanto_ci", Charset: "utf8"},
+	{ID: 210, Name: "utf8_hungarian_ci", Charset: "utf8"},
+	{ID: 211, Name: "utf8_sinhala_ci", Charset: "utf8"},
+	{ID: 212, Name: "utf8_german2_ci", Charset: "utf8"},
+	{ID: 213, Name: "utf8_croatian_ci", Charset: "utf8"},
+	{ID: 214, Name: "utf8_unicode_520_ci", Charset: "utf8"},
+	{ID: 215, Name: "utf8_vietnamese_ci", Charset: "utf8"},
+	{ID: 223, Name: "utf8_general_mysql500_ci", Charset: "utf8"},
+	{ID: 224, Name: "utf8mb4_unicode_ci", Charset: "utf8mb4"},
+	{ID: 225, Name: "utf8mb4_icelandic_ci", Charset: "utf8mb4"},
+	{ID: 226, Name: "utf8mb4_latvian_ci", Charset: "utf8mb4"},
+	{ID: 227, Name: "utf8mb4_romanian_ci", Charset: "utf8mb4"},
+	{ID: 228, Name: "utf8mb4_slovenian_ci", Charset: "utf8mb4"},
+	{ID: 229, Name: "utf8mb4_polish_ci", Charset: "utf8mb4"},
+	{ID: 230, Name: "utf8mb4_estonian_ci", Charset: "utf8mb4"},
+	{ID: 231, Name: "utf8mb4_spanish_ci", Charset: "utf8mb4"},
+	{ID: 232, Name: "utf8mb4_swedish_ci", Charset: "utf8mb4"},
+	{ID: 233, Name: "utf8mb4_turkish_ci", Charset: "utf8mb4"},
+	{ID: 234, Name: "utf8mb4_czech_ci", Charset: "utf8mb4"},
+	{ID: 235, Name: "utf8mb4_danish_ci", Charset: "utf8mb4"},
+	{ID: 236, Name: "utf8mb4_lithuanian_ci", Charset: "utf8mb4"},
+	{ID: 237, Name: "utf8mb4_slovak_ci", Charset: "utf8mb4"},
+	{ID: 238, Name: "utf8mb4_spanish2_ci", Charset: "utf8mb4"},
+	{ID: 239, Name: "utf8mb4_roman_ci", Charset: "utf8mb4"},
+	{ID: 240, Name: "utf8mb4_persian_ci", Charset: "utf8mb4"},
+	{ID: 241, Name: "utf8mb4_esperanto_ci", Charset: "utf8mb4"},
+	{ID: 242, Name: "utf8mb4_hungarian_ci", Charset: "utf8mb4"},
+	{ID: 243, Name: "utf8mb4_sinhala_ci", Charset: "utf8mb4"},
+	{ID: 244, Name: "utf8mb4_german2_ci", Charset: "utf8mb4"},
+	{ID: 245, Name: "utf8mb4_croatian_ci", Charset: "utf8mb4"},
+	{ID: 246, Name: "utf8mb4_unicode_520_ci", Charset: "utf8mb4"},
+	{ID: 247, Name: "utf8mb4_vietnamese_ci", Charset: "utf8mb4"},
+	{ID: 248, Name: "gb18030_chinese_ci", Charset: "gb18030"},
+	{ID: 249, Name: "gb18030_bin", Charset: "gb18030"},
+	{ID: 250, Name: "gb18030_unicode_520_ci", Charset: "gb18030"},
+	{ID: 255, Name: "utf8mb4_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 256, Name: "utf8mb4_de_pb_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 257, Name: "utf8mb4_is_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 258, Name: "utf8mb4_lv_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 259, Name: "utf8mb4_ro_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 260, Name: "utf8mb4_sl_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 261, Name: "utf8mb4_pl_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 262, Name: "utf8mb4_et_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 263, Name: "utf8mb4_es_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 264, Name: "utf8mb4_sv_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 265, Name: "utf8mb4_tr_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 266, Name: "utf8mb4_cs_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 267, Name: "utf8mb4_da_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 268, Name: "utf8mb4_lt_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 269, Name: "utf8mb4_sk_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 270, Name: "utf8mb4_es_trad_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 271, Name: "utf8mb4_la_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 273, Name: "utf8mb4_eo_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 274, Name: "utf8mb4_hu_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 275, Name: "utf8mb4_hr_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 277, Name: "utf8mb4_vi_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 278, Name: "utf8mb4_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 279, Name: "utf8mb4_de_pb_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 280, Name: "utf8mb4_is_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 281, Name: "utf8mb4_lv_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 282, Name: "utf8mb4_ro_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 283, Name: "utf8mb4_sl_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 284, Name: "utf8mb4_pl_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 285, Name: "utf8mb4_et_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 286, Name: "utf8mb4_es_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 287, Name: "utf8mb4_sv_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 288, Name: "utf8mb4_tr_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 289, Name: "utf8mb4_cs_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 290, Name: "utf8mb4_da_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 291, Name: "utf8mb4_lt_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 292, Name: "utf8mb4_sk_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 293, Name: "utf8mb4_es_trad_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 294, Name: "utf8mb4_la_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 296, Name: "utf8mb4_eo_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 297, Name: "utf8mb4_hu_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 298, Name: "utf8mb4_hr_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 300, Name: "utf8mb4_vi_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 303, Name: "utf8mb4_ja_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 304, Name: "utf8mb4_ja_0900_as_cs_ks", Charset: "utf8mb4"},
+	{ID: 305, Name: "utf8mb4_0900_as_ci", Charset: "utf8mb4"},
+	{ID: 306, Name: "utf8mb4_ru_0900_ai_ci", Charset: "utf8mb4"},
+	{ID: 307, Name: "utf8mb4_ru_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 308, Name: "utf8mb4_zh_0900_as_cs", Charset: "utf8mb4"},
+	{ID: 309, Name: "utf8mb4_0900_bin", Charset: "utf8mb4"},
+}
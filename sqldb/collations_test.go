@@ -0,0 +1,96 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollationCatalog(t *testing.T) {
+	// Every charset has its default collation in the catalog.
+	for _, cs := range Charsets() {
+		c, ok := LookupCollationByName(cs.DefaultCollation)
+		assert.True(t, ok, cs.Name)
+		assert.Equal(t, cs.Name, c.Charset)
+		assert.True(t, c.IsDefault())
+	}
+
+	// The ids are unique and ordered.
+	prev := uint16(0)
+	for _, c := range Collations() {
+		assert.True(t, c.ID > prev, c.Name)
+		prev = c.ID
+		_, ok := LookupCharset(c.Charset)
+		assert.True(t, ok, c.Name)
+	}
+}
+
+func TestLookupCollation(t *testing.T) {
+	c, ok := LookupCollation(CollationUtf8mb4GeneralCI)
+	assert.True(t, ok)
+	assert.Equal(t, "utf8mb4_general_ci", c.Name)
+	assert.Equal(t, "utf8mb4", c.Charset)
+	assert.Equal(t, 4, c.MaxLen())
+	assert.True(t, c.IsDefault())
+
+	c, ok = LookupCollationByName("UTF8MB4_0900_AI_CI")
+	assert.True(t, ok)
+	assert.Equal(t, uint16(CollationUtf8mb40900AICI), c.ID)
+	assert.False(t, c.IsDefault())
+
+	c, ok = LookupCollation(309)
+	assert.True(t, ok)
+	assert.Equal(t, "utf8mb4_0900_bin", c.Name)
+
+	_, ok = LookupCollation(17)
+	assert.False(t, ok)
+	_, ok = LookupCollationByName("utf8_klingon_ci")
+	assert.False(t, ok)
+}
+
+func TestLookupCharset(t *testing.T) {
+	cs, ok := LookupCharset("GBK")
+	assert.True(t, ok)
+	assert.Equal(t, "gbk_chinese_ci", cs.DefaultCollation)
+	assert.Equal(t, 2, cs.MaxLen)
+	cs, ok = LookupCharset("utf8mb3")
+	assert.True(t, ok)
+	assert.Equal(t, "utf8", cs.Name)
+	_, ok = LookupCharset("klingon")
+	assert.False(t, ok)
+
+	c, ok := DefaultCollationOf("utf8mb4", false)
+	assert.True(t, ok)
+	assert.Equal(t, "utf8mb4_general_ci", c.Name)
+	c, ok = DefaultCollationOf("utf8mb4", true)
+	assert.True(t, ok)
+	assert.Equal(t, "utf8mb4_0900_ai_ci", c.Name)
+	c, ok = DefaultCollationOf("latin1", true)
+	assert.True(t, ok)
+	assert.Equal(t, "latin1_swedish_ci", c.Name)
+	_, ok = DefaultCollationOf("klingon", false)
+	assert.False(t, ok)
+}
+
+func TestCharacterSetName(t *testing.T) {
+	assert.Equal(t, "utf8", CharacterSetName(CharacterSetUtf8))
+	assert.Equal(t, "latin1", CharacterSetName(8))
+	assert.Equal(t, "gbk", CharacterSetName(28))
+	assert.Equal(t, "utf8mb4", CharacterSetName(224))
+	assert.Equal(t, "utf8mb4", CharacterSetName(CollationUtf8mb40900AICI))
+	assert.Equal(t, "", CharacterSetName(17))
+
+	assert.Equal(t, uint8(CharacterSetUtf8), CharacterSetMap["utf8"])
+	assert.Equal(t, uint8(CollationUtf8mb4GeneralCI), CharacterSetMap["utf8mb4"])
+	assert.Equal(t, uint8(248), CharacterSetMap["gb18030"])
+	assert.Equal(t, uint8(CharacterSetBinary), CharacterSetMap["binary"])
+}
@@ -11,6 +11,11 @@
 
 package sqldb
 
+import (
+	"fmt"
+	"strings"
+)
+
 /***************************************************/
 // https://dev.mysql.com/doc/internals/en/command-phase.html
 // include/my_command.h
@@ -119,6 +124,54 @@ func CommandString(cmd byte) string {
 	return "UNKNOWN"
 }
 
+// The flags of the COM_REFRESH, include/mysql_com.h.
+const (
+	// REFRESH_GRANT reloads the grant tables, the FLUSH PRIVILEGES.
+	REFRESH_GRANT byte = 1
+
+	// REFRESH_LOG reopens the log files, the FLUSH LOGS.
+	REFRESH_LOG byte = 2
+
+	// REFRESH_TABLES closes the open tables, the FLUSH TABLES.
+	REFRESH_TABLES byte = 4
+
+	// REFRESH_HOSTS flushes the host cache, the FLUSH HOSTS.
+	REFRESH_HOSTS byte = 8
+
+	// REFRESH_STATUS resets the status counters, the FLUSH STATUS.
+	REFRESH_STATUS byte = 16
+
+	// REFRESH_THREADS flushes the thread cache.
+	REFRESH_THREADS byte = 32
+
+	// REFRESH_SLAVE resets the slave info, the RESET SLAVE.
+	REFRESH_SLAVE byte = 64
+
+	// REFRESH_MASTER removes the binlogs, the RESET MASTER.
+	REFRESH_MASTER byte = 128
+)
+
+// The levels of the COM_SHUTDOWN, include/mysql_com.h mysql_enum_shutdown_level.
+const (
+	// SHUTDOWN_DEFAULT is the level the clients send.
+	SHUTDOWN_DEFAULT byte = 0
+
+	// SHUTDOWN_WAIT_CONNECTIONS waits for the existing connections to finish.
+	SHUTDOWN_WAIT_CONNECTIONS byte = 1
+
+	// SHUTDOWN_WAIT_TRANSACTIONS waits for the existing transactions to finish.
+	SHUTDOWN_WAIT_TRANSACTIONS byte = 2
+
+	// SHUTDOWN_WAIT_UPDATES waits for the existing updates to finish.
+	SHUTDOWN_WAIT_UPDATES byte = 8
+
+	// SHUTDOWN_WAIT_ALL_BUFFERS flushes the buffers before the shutdown.
+	SHUTDOWN_WAIT_ALL_BUFFERS byte = 16
+
+	// SHUTDOWN_WAIT_CRITICAL_BUFFERS flushes the critical buffers before the shutdown.
+	SHUTDOWN_WAIT_CRITICAL_BUFFERS byte = 17
+)
+
 // https://dev.mysql.com/doc/internals/en/capability-flags.html
 // include/mysql_com.h
 const (
@@ -197,6 +250,92 @@ const (
 
 	//Client no longer needs EOF packet
 	CLIENT_DEPRECATE_EOF = uint32(1 << 24)
+
+	// Can send the optional part of the query attributes in the COM_QUERY and COM_STMT_EXECUTE.
+	CLIENT_QUERY_ATTRIBUTES = uint32(1 << 27)
+)
+
+// capabilityNames are the names of the capability flags by the bits, the empty ones are unknown.
+var capabilityNames = [32]string{
+	"CLIENT_LONG_PASSWORD",
+	"CLIENT_FOUND_ROWS",
+	"CLIENT_LONG_FLAG",
+	"CLIENT_CONNECT_WITH_DB",
+	"CLIENT_NO_SCHEMA",
+	"CLIENT_COMPRESS",
+	"CLIENT_ODBC",
+	"CLIENT_LOCAL_FILES",
+	"CLIENT_IGNORE_SPACE",
+	"CLIENT_PROTOCOL_41",
+	"CLIENT_INTERACTIVE",
+	"CLIENT_SSL",
+	"CLIENT_IGNORE_SIGPIPE",
+	"CLIENT_TRANSACTIONS",
+	"CLIENT_RESERVED",
+	"CLIENT_SECURE_CONNECTION",
+	"CLIENT_MULTI_STATEMENTS",
+	"CLIENT_MULTI_RESULTS",
+	"CLIENT_PS_MULTI_RESULTS",
+	"CLIENT_PLUGIN_AUTH",
+	"CLIENT_CONNECT_ATTRS",
+	"CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA",
+	"CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS",
+	"CLIENT_SESSION_TRACK",
+	"CLIENT_DEPRECATE_EOF",
+	"CLIENT_OPTIONAL_RESULTSET_METADATA",
+	"CLIENT_ZSTD_COMPRESSION_ALGORITHM",
+	"CLIENT_QUERY_ATTRIBUTES",
+	"",
+	"CLIENT_CAPABILITY_EXTENSION",
+	"CLIENT_SSL_VERIFY_SERVER_CERT",
+	"CLIENT_REMEMBER_OPTIONS",
+}
+
+// UnknownCapability returns the bits of the flags which are not MySQL capabilities.
+func UnknownCapability(flags uint32) uint32 {
+	var unknown uint32
+	for i, name := range capabilityNames {
+		if name == "" {
+			unknown |= flags & (1 << uint(i))
+		}
+	}
+	return unknown
+}
+
+// CapabilityString returns the names of the capability flags joined by the '|', the unknown bits are in hex.
+func CapabilityString(flags uint32) string {
+	var names []string
+	for i, name := range capabilityNames {
+		bit := uint32(1) << uint(i)
+		if flags&bit == 0 {
+			continue
+		}
+		if name == "" {
+			name = fmt.Sprintf("0x%08x", bit)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, "|")
+}
+
+// MariaDB extended capability flags, they are the upper 32 bits of the MariaDB capabilities.
+// A MariaDB server clears the CLIENT_LONG_PASSWORD(CLIENT_MYSQL) flag and sends them in
+// the last 4 bytes of the greeting reserved filler, the client in the handshake response filler.
+const (
+	// Client supports progress indicator.
+	MARIADB_CLIENT_PROGRESS = uint32(1 << 0)
+
+	// Permit COM_MULTI protocol.
+	MARIADB_CLIENT_COM_MULTI = uint32(1 << 1)
+
+	// Permit bulk insert.
+	MARIADB_CLIENT_STMT_BULK_OPERATIONS = uint32(1 << 2)
+
+	// Add extended metadata information.
+	MARIADB_CLIENT_EXTENDED_TYPE_INFO = uint32(1 << 3)
+
+	// Permit skipping metadata.
+	MARIADB_CLIENT_CACHE_METADATA = uint32(1 << 4)
 )
 
 const (
@@ -207,79 +346,148 @@ const (
 // Originally found in include/mysql/mysql_com.h
 // See http://dev.mysql.com/doc/internals/en/status-flags.html
 const (
+	// A transaction is active.
+	SERVER_STATUS_IN_TRANS = 0x0001
+
+	// Auto-commit is enabled.
 	SERVER_STATUS_AUTOCOMMIT = 0x0002
+
+	// More results exist for the multi-statement.
+	SERVER_MORE_RESULTS_EXISTS = 0x0008
+
+	SERVER_STATUS_NO_GOOD_INDEX_USED = 0x0010
+	SERVER_STATUS_NO_INDEX_USED      = 0x0020
+
+	// Used by Binary Protocol Resultset to signal that COM_STMT_FETCH must be used to fetch the row-data.
+	SERVER_STATUS_CURSOR_EXISTS = 0x0040
+	SERVER_STATUS_LAST_ROW_SENT = 0x0080
+
+	SERVER_STATUS_DB_DROPPED           = 0x0100
+	SERVER_STATUS_NO_BACKSLASH_ESCAPES = 0x0200
+	SERVER_STATUS_METADATA_CHANGED     = 0x0400
+	SERVER_QUERY_WAS_SLOW              = 0x0800
+	SERVER_PS_OUT_PARAMS               = 0x1000
+
+	// In a read-only transaction.
+	SERVER_STATUS_IN_TRANS_READONLY = 0x2000
+
+	// Connection state information has changed.
+	SERVER_SESSION_STATE_CHANGED = 0x4000
+)
+
+// The types of the session state changes of the OK packet, see CLIENT_SESSION_TRACK.
+// Originally found in include/mysql_com.h
+const (
+	SESSION_TRACK_SYSTEM_VARIABLES            byte = 0x00
+	SESSION_TRACK_SCHEMA                           = 0x01
+	SESSION_TRACK_STATE_CHANGE                     = 0x02
+	SESSION_TRACK_GTIDS                            = 0x03
+	SESSION_TRACK_TRANSACTION_CHARACTERISTICS      = 0x04
+	SESSION_TRACK_TRANSACTION_STATE                = 0x05
 )
 
 // A few interesting character set values.
 // See http://dev.mysql.com/doc/internals/en/character-set.html#packet-Protocol::CharacterSet
 const (
-	// CharacterSetUtf8 is for UTF8. We use this by default.
+	// CharacterSetUtf8 is for UTF8, the utf8_general_ci.
 	CharacterSetUtf8 = 33
 
 	// CharacterSetBinary is for binary. Use by integer fields for instance.
 	CharacterSetBinary = 63
+
+	// CollationUtf8mb4GeneralCI is the utf8mb4 default of the MySQL 5.7, it's the default of the handshake.
+	CollationUtf8mb4GeneralCI = 45
+
+	// CollationUtf8mb40900AICI is the utf8mb4 default of the MySQL 8.0.
+	CollationUtf8mb40900AICI = 255
+
+	// DefaultCollation is the collation used if the client or the server doesn't choose one.
+	DefaultCollation = CollationUtf8mb4GeneralCI
 )
 
 // CharacterSetMap maps the charset name (used in ConnParams) to the
-// integer value.  Interesting ones have their own constant above.
-var CharacterSetMap = map[string]uint8{
-	"big5":     1,
-	"dec8":     3,
-	"cp850":    4,
-	"hp8":      6,
-	"koi8r":    7,
-	"latin1":   8,
-	"latin2":   9,
-	"swe7":     10,
-	"ascii":    11,
-	"ujis":     12,
-	"sjis":     13,
-	"hebrew":   16,
-	"tis620":   18,
-	"euckr":    19,
-	"koi8u":    22,
-	"gb2312":   24,
-	"greek":    25,
-	"cp1250":   26,
-	"gbk":      28,
-	"latin5":   30,
-	"armscii8": 32,
-	"utf8":     CharacterSetUtf8,
-	"ucs2":     35,
-	"cp866":    36,
-	"keybcs2":  37,
-	"macce":    38,
-	"macroman": 39,
-	"cp852":    40,
-	"latin7":   41,
-	"utf8mb4":  45,
-	"cp1251":   51,
-	"utf16":    54,
-	"utf16le":  56,
-	"cp1256":   57,
-	"cp1257":   59,
-	"utf32":    60,
-	"binary":   CharacterSetBinary,
-	"geostd8":  92,
-	"cp932":    95,
-	"eucjpms":  97,
-}
+// id of its default collation, it's built from the collation catalog.
+var CharacterSetMap = characterSetMap()
 
 const (
 	// Error codes for server-side errors.
 	// Originally found in include/mysql/mysqld_error.h
-	ER_ERROR_FIRST                  uint16 = 1000
-	ER_CON_COUNT_ERROR                     = 1040
-	ER_ACCESS_DENIED_ERROR                 = 1045
-	ER_NO_DB_ERROR                         = 1046
-	ER_BAD_DB_ERROR                        = 1049
-	ER_UNKNOWN_ERROR                       = 1105
-	ER_HOST_NOT_PRIVILEGED                 = 1130
-	ER_NO_SUCH_TABLE                       = 1146
-	ER_SYNTAX_ERROR                        = 1149
-	ER_SPECIFIC_ACCESS_DENIED_ERROR        = 1227
-	ER_OPTION_PREVENTS_STATEMENT           = 1290
-	ER_MALFORMED_PACKET                    = 1835
+	ER_ERROR_FIRST                       uint16 = 1000
+	ER_CON_COUNT_ERROR                          = 1040
+	ER_OUT_OF_RESOURCES                         = 1041
+	ER_HANDSHAKE_ERROR                          = 1043
+	ER_DBACCESS_DENIED_ERROR                    = 1044
+	ER_ACCESS_DENIED_ERROR                      = 1045
+	ER_NO_DB_ERROR                              = 1046
+	ER_BAD_DB_ERROR                             = 1049
+	ER_DUP_ENTRY                                = 1062
+	ER_PARSE_ERROR                              = 1064
+	ER_UNKNOWN_ERROR                            = 1105
+	ER_UNKNOWN_CHARACTER_SET                    = 1115
+	ER_HOST_NOT_PRIVILEGED                      = 1130
+	ER_NO_SUCH_TABLE                            = 1146
+	ER_NET_PACKET_TOO_LARGE                     = 1153
+	ER_SYNTAX_ERROR                             = 1149
+	ER_SPECIFIC_ACCESS_DENIED_ERROR             = 1227
+	ER_WRONG_VALUE_FOR_VAR                      = 1231
+	ER_WRONG_TYPE_FOR_VAR                       = 1232
+	ER_INCORRECT_GLOBAL_LOCAL_VAR               = 1238
+	ER_UNKNOWN_COLLATION                        = 1273
+	ER_UNKNOWN_TIME_ZONE                        = 1298
+	ER_INVALID_CHARACTER_STRING                 = 1300
+	ER_NOT_SUPPORTED_AUTH_MODE                  = 1251
+	ER_MASTER_FATAL_ERROR_READING_BINLOG        = 1236
+	ER_MAX_PREPARED_STMT_COUNT_REACHED          = 1461
+	ER_OPTION_PREVENTS_STATEMENT                = 1290
+	ER_QUERY_INTERRUPTED                        = 1317
+	ER_MALFORMED_PACKET                         = 1835
+	ER_QUERY_TIMEOUT                            = 3024
+
+	// The duplicate key and the read only errors, see IsDupEntry and IsReadOnly.
+	ER_DUP_KEY                               = 1022
+	ER_DUP_UNIQUE                            = 1169
+	ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION = 1792
+	ER_READ_ONLY_MODE                        = 1836
+
+	// The generic error of the masked internal errors.
+	ER_INTERNAL_ERROR = 1815
+
+	// The commands are rejected by the overloaded thread pool.
+	ER_TOO_MANY_USER_CONNECTIONS = 1203
+
+	// The errors of the prepared statements.
+	ER_WRONG_ARGUMENTS      = 1210
+	ER_UNKNOWN_STMT_HANDLER = 1243
+
+	// The errors of the cursors.
+	ER_STMT_HAS_NO_OPEN_CURSOR = 1421
+
+	// The password of the user is expired, the session is in the sandbox mode or rejected at login.
+	ER_MUST_CHANGE_PASSWORD       = 1820
+	ER_MUST_CHANGE_PASSWORD_LOGIN = 1862
+
+	// The connection didn't switch to TLS but the user or the server requires it.
+	ER_SECURE_TRANSPORT_REQUIRED = 3159
+
+	// The account or the host is blocked by the consecutive failed logins.
+	ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK = 3955
+
+	// The errors of the tables, the columns and the rows.
+	ER_BAD_NULL_ERROR                  = 1048
+	ER_TABLE_EXISTS_ERROR              = 1050
+	ER_BAD_TABLE_ERROR                 = 1051
+	ER_BAD_FIELD_ERROR                 = 1054
+	ER_INVALID_DEFAULT                 = 1067
+	ER_KEY_COLUMN_DOES_NOT_EXITS       = 1072
+	ER_NO_TABLES_USED                  = 1096
+	ER_WRONG_VALUE_COUNT_ON_ROW        = 1136
+	ER_NOT_SUPPORTED_YET               = 1235
+	ER_NO_DEFAULT_FOR_FIELD            = 1364
+	ER_TRUNCATED_WRONG_VALUE_FOR_FIELD = 1366
+
+	// The errors of the locks, the statement is rolled back.
+	ER_LOCK_WAIT_TIMEOUT = 1205
+	ER_LOCK_DEADLOCK     = 1213
 
 	// Error codes for client-side errors.
 	// Originally found in include/mysql/errmsg.h
@@ -288,21 +496,70 @@ const (
 	// - the client cannot read an initial auth packet.
 	// - the client cannot read a response from the server.
 	CR_SERVER_LOST = 2013
+	// The connection errors, see IsConnErr.
+	CR_UNKNOWN_ERROR        = 2000
+	CR_CONNECTION_ERROR     = 2002
+	CR_CONN_HOST_ERROR      = 2003
+	CR_SERVER_GONE_ERROR    = 2006
+	CR_COMMANDS_OUT_OF_SYNC = 2014
+	// The TLS handshake failed or the server doesn't support it.
+	CR_SSL_CONNECTION_ERROR = 2026
+	// The packet from the server can't be parsed.
+	CR_MALFORMED_PACKET = 2027
+	// The range of the client errors, see IsClientError.
+	CR_ERROR_FIRST = 2000
+	CR_ERROR_LAST  = 2999
 	// This is returned if the server versions don't match what we support.
 	CR_VERSION_ERROR = 2007
+	// This is returned if the server asks for an auth plugin the client doesn't support.
+	CR_AUTH_PLUGIN_CANNOT_LOAD = 2059
 )
 
 var SQLErrors = map[uint16]*SQLError{
-	ER_CON_COUNT_ERROR:              &SQLError{Num: ER_CON_COUNT_ERROR, State: "08004", Message: "Too many connections"},
-	ER_ACCESS_DENIED_ERROR:          &SQLError{Num: ER_ACCESS_DENIED_ERROR, State: "28000", Message: "Access denied for user '%-.48s'@'%-.64s' (using password: %s)"},
-	ER_NO_DB_ERROR:                  &SQLError{Num: ER_NO_DB_ERROR, State: "3D000", Message: "No database selected"},
-	ER_BAD_DB_ERROR:                 &SQLError{Num: ER_BAD_DB_ERROR, State: "42000", Message: "Unknown database '%-.192s'"},
-	ER_UNKNOWN_ERROR:                &SQLError{Num: ER_UNKNOWN_ERROR, State: "HY000", Message: ""},
-	ER_HOST_NOT_PRIVILEGED:          &SQLError{Num: ER_HOST_NOT_PRIVILEGED, State: "HY000", Message: "Host '%-.64s' is not allowed to connect to this MySQL server"},
-	ER_NO_SUCH_TABLE:                &SQLError{Num: ER_NO_SUCH_TABLE, State: "42S02", Message: "Table '%s' doesn't exist"},
-	ER_SYNTAX_ERROR:                 &SQLError{Num: ER_SYNTAX_ERROR, State: "42000", Message: "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, %s"},
-	ER_SPECIFIC_ACCESS_DENIED_ERROR: &SQLError{Num: ER_SPECIFIC_ACCESS_DENIED_ERROR, State: "42000", Message: "Access denied; you need (at least one of) the %-.128s privilege(s) for this operation"},
-	ER_OPTION_PREVENTS_STATEMENT:    &SQLError{Num: ER_OPTION_PREVENTS_STATEMENT, State: "42000", Message: "The MySQL server is running with the %s option so it cannot execute this statement"},
-	ER_MALFORMED_PACKET:             &SQLError{Num: ER_MALFORMED_PACKET, State: "HY000", Message: "Malformed communication packet."},
-	CR_SERVER_LOST:                  &SQLError{Num: CR_SERVER_LOST, State: "HY000", Message: ""},
+	ER_CON_COUNT_ERROR:                   &SQLError{Num: ER_CON_COUNT_ERROR, State: "08004", Message: "Too many connections"},
+	ER_OUT_OF_RESOURCES:                  &SQLError{Num: ER_OUT_OF_RESOURCES, State: "HY000", Message: "Out of memory; check if mysqld or some other process uses all available memory; if not, you may have to use 'ulimit' to allow mysqld to use more memory or you can add more swap space"},
+	ER_HANDSHAKE_ERROR:                   &SQLError{Num: ER_HANDSHAKE_ERROR, State: "08S01", Message: "Bad handshake"},
+	ER_ACCESS_DENIED_ERROR:               &SQLError{Num: ER_ACCESS_DENIED_ERROR, State: "28000", Message: "Access denied for user '%-.48s'@'%-.64s' (using password: %s)"},
+	ER_DBACCESS_DENIED_ERROR:             &SQLError{Num: ER_DBACCESS_DENIED_ERROR, State: "42000", Message: "Access denied for user '%-.48s'@'%-.64s' to database '%-.192s'"},
+	ER_NO_DB_ERROR:                       &SQLError{Num: ER_NO_DB_ERROR, State: "3D000", Message: "No database selected"},
+	ER_BAD_DB_ERROR:                      &SQLError{Num: ER_BAD_DB_ERROR, State: "42000", Message: "Unknown database '%-.192s'"},
+	ER_DUP_ENTRY:                         &SQLError{Num: ER_DUP_ENTRY, State: "23000", Message: "Duplicate entry '%-.192s' for key '%-.192s'"},
+	ER_PARSE_ERROR:                       &SQLError{Num: ER_PARSE_ERROR, State: "42000", Message: "%s near '%-.80s' at line %d"},
+	ER_UNKNOWN_ERROR:                     &SQLError{Num: ER_UNKNOWN_ERROR, State: "HY000", Message: ""},
+	ER_UNKNOWN_CHARACTER_SET:             &SQLError{Num: ER_UNKNOWN_CHARACTER_SET, State: "42000", Message: "Unknown character set: '%-.64s'"},
+	ER_HOST_NOT_PRIVILEGED:               &SQLError{Num: ER_HOST_NOT_PRIVILEGED, State: "HY000", Message: "Host '%-.64s' is not allowed to connect to this MySQL server"},
+	ER_NO_SUCH_TABLE:                     &SQLError{Num: ER_NO_SUCH_TABLE, State: "42S02", Message: "Table '%s' doesn't exist"},
+	ER_SYNTAX_ERROR:                      &SQLError{Num: ER_SYNTAX_ERROR, State: "42000", Message: "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, %s"},
+	ER_SPECIFIC_ACCESS_DENIED_ERROR:      &SQLError{Num: ER_SPECIFIC_ACCESS_DENIED_ERROR, State: "42000", Message: "Access denied; you need (at least one of) the %-.128s privilege(s) for this operation"},
+	ER_WRONG_VALUE_FOR_VAR:               &SQLError{Num: ER_WRONG_VALUE_FOR_VAR, State: "42000", Message: "Variable '%-.64s' can't be set to the value of '%-.200s'"},
+	ER_WRONG_TYPE_FOR_VAR:                &SQLError{Num: ER_WRONG_TYPE_FOR_VAR, State: "42000", Message: "Incorrect argument type to variable '%-.64s'"},
+	ER_INCORRECT_GLOBAL_LOCAL_VAR:        &SQLError{Num: ER_INCORRECT_GLOBAL_LOCAL_VAR, State: "HY000", Message: "Variable '%-.64s' is a %s variable"},
+	ER_UNKNOWN_COLLATION:                 &SQLError{Num: ER_UNKNOWN_COLLATION, State: "HY000", Message: "Unknown collation: '%-.64s'"},
+	ER_UNKNOWN_TIME_ZONE:                 &SQLError{Num: ER_UNKNOWN_TIME_ZONE, State: "HY000", Message: "Unknown or incorrect time zone: '%-.64s'"},
+	ER_INVALID_CHARACTER_STRING:          &SQLError{Num: ER_INVALID_CHARACTER_STRING, State: "HY000", Message: "Invalid %s character string: '%.64s'"},
+	ER_NOT_SUPPORTED_AUTH_MODE:           &SQLError{Num: ER_NOT_SUPPORTED_AUTH_MODE, State: "08004", Message: "Client does not support authentication protocol requested by server; consider upgrading MySQL client"},
+	ER_MASTER_FATAL_ERROR_READING_BINLOG: &SQLError{Num: ER_MASTER_FATAL_ERROR_READING_BINLOG, State: "HY000", Message: "Got fatal error %d from master when reading data from binary log: '%-.512s'"},
+	ER_OPTION_PREVENTS_STATEMENT:         &SQLError{Num: ER_OPTION_PREVENTS_STATEMENT, State: "42000", Message: "The MySQL server is running with the %s option so it cannot execute this statement"},
+	ER_MALFORMED_PACKET:                  &SQLError{Num: ER_MALFORMED_PACKET, State: "HY000", Message: "Malformed communication packet."},
+	ER_QUERY_TIMEOUT:                     &SQLError{Num: ER_QUERY_TIMEOUT, State: "HY000", Message: "Query execution was interrupted, maximum statement execution time exceeded"},
+	ER_INTERNAL_ERROR:                    &SQLError{Num: ER_INTERNAL_ERROR, State: "HY000", Message: "Internal error: %s"},
+	ER_WRONG_ARGUMENTS:                   &SQLError{Num: ER_WRONG_ARGUMENTS, State: "HY000", Message: "Incorrect arguments to %s"},
+	ER_UNKNOWN_STMT_HANDLER:              &SQLError{Num: ER_UNKNOWN_STMT_HANDLER, State: "HY000", Message: "Unknown prepared statement handler (%v) given to %s"},
+	ER_STMT_HAS_NO_OPEN_CURSOR:           &SQLError{Num: ER_STMT_HAS_NO_OPEN_CURSOR, State: "HY000", Message: "The statement (%v) has no open cursor."},
+	ER_MUST_CHANGE_PASSWORD:              &SQLError{Num: ER_MUST_CHANGE_PASSWORD, State: "HY000", Message: "You must reset your password using ALTER USER statement before executing this statement."},
+	ER_MUST_CHANGE_PASSWORD_LOGIN:        &SQLError{Num: ER_MUST_CHANGE_PASSWORD_LOGIN, State: "HY000", Message: "Your password has expired. To log in you must change it using a client that supports expired passwords."},
+	ER_SECURE_TRANSPORT_REQUIRED:         &SQLError{Num: ER_SECURE_TRANSPORT_REQUIRED, State: "HY000", Message: "Connections using insecure transport are prohibited while --require_secure_transport=ON."},
+	ER_LOCK_WAIT_TIMEOUT:                 &SQLError{Num: ER_LOCK_WAIT_TIMEOUT, State: "HY000", Message: "Lock wait timeout exceeded; try restarting transaction"},
+	ER_LOCK_DEADLOCK:                     &SQLError{Num: ER_LOCK_DEADLOCK, State: "40001", Message: "Deadlock found when trying to get lock; try restarting transaction"},
+	CR_SERVER_LOST:                       &SQLError{Num: CR_SERVER_LOST, State: "HY000", Message: ""},
+	CR_UNKNOWN_ERROR:                     &SQLError{Num: CR_UNKNOWN_ERROR, State: "HY000", Message: "Unknown MySQL error"},
+	CR_CONNECTION_ERROR:                  &SQLError{Num: CR_CONNECTION_ERROR, State: "HY000", Message: "Can't connect to local MySQL server through socket '%-.100s' (%d)"},
+	CR_CONN_HOST_ERROR:                   &SQLError{Num: CR_CONN_HOST_ERROR, State: "HY000", Message: "Can't connect to MySQL server on '%-.100s' (%d)"},
+	CR_SERVER_GONE_ERROR:                 &SQLError{Num: CR_SERVER_GONE_ERROR, State: "HY000", Message: "MySQL server has gone away"},
+	CR_COMMANDS_OUT_OF_SYNC:              &SQLError{Num: CR_COMMANDS_OUT_OF_SYNC, State: "HY000", Message: "Commands out of sync; you can't run this command now"},
+	CR_SSL_CONNECTION_ERROR:              &SQLError{Num: CR_SSL_CONNECTION_ERROR, State: "HY000", Message: "SSL connection error: %-.100s"},
+	CR_MALFORMED_PACKET:                  &SQLError{Num: CR_MALFORMED_PACKET, State: "HY000", Message: "Malformed packet"},
+	CR_AUTH_PLUGIN_CANNOT_LOAD:           &SQLError{Num: CR_AUTH_PLUGIN_CANNOT_LOAD, State: "HY000", Message: "Authentication plugin '%s' cannot be loaded"},
+
+	ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK: &SQLError{Num: ER_USER_ACCESS_DENIED_FOR_USER_ACCOUNT_BLOCKED_BY_PASSWORD_LOCK, State: "HY000", Message: "Access denied for user '%-.48s'@'%-.64s'. Account is blocked for %s due to %d consecutive failed logins."},
 }
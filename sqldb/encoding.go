@@ -0,0 +1,129 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Encoding transcodes the strings between a charset and the UTF-8.
+type Encoding interface {
+	// Decode converts the bytes of the charset to the UTF-8.
+	Decode(src []byte) ([]byte, error)
+
+	// Encode converts the UTF-8 bytes to the charset,
+	// the characters can't be represented are replaced by the '?' as MySQL does.
+	Encode(src []byte) []byte
+}
+
+type singleByteEncoding struct {
+	name    string
+	decode  [256]rune
+	encoder map[rune]byte
+}
+
+// NewSingleByteEncoding creates the Encoding of a single byte charset by the code points of the 256 bytes,
+// the utf8.RuneError marks the byte not defined by the charset.
+func NewSingleByteEncoding(name string, table [256]rune) Encoding {
+	e := &singleByteEncoding{name: name, decode: table, encoder: make(map[rune]byte, 256)}
+	for b, r := range table {
+		if r != utf8.RuneError {
+			e.encoder[r] = byte(b)
+		}
+	}
+	return e
+}
+
+func (e *singleByteEncoding) Decode(src []byte) ([]byte, error) {
+	dst := make([]byte, 0, len(src))
+	for i, b := range src {
+		r := e.decode[b]
+		if r == utf8.RuneError {
+			return nil, fmt.Errorf("encoding.%s.invalid.byte[0x%02x].at[%d]", e.name, b, i)
+		}
+		if r < utf8.RuneSelf {
+			dst = append(dst, byte(r))
+			continue
+		}
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		dst = append(dst, buf[:n]...)
+	}
+	return dst, nil
+}
+
+func (e *singleByteEncoding) Encode(src []byte) []byte {
+	dst := make([]byte, 0, len(src))
+	for len(src) > 0 {
+		r, n := utf8.DecodeRune(src)
+		src = src[n:]
+		if b, ok := e.encoder[r]; ok {
+			dst = append(dst, b)
+		} else {
+			dst = append(dst, '?')
+		}
+	}
+	return dst
+}
+
+// latin1Table is the MySQL latin1, it's the cp1252 with the 5 undefined bytes mapped to the C1 controls.
+var latin1Table = func() (t [256]rune) {
+	for i := range t {
+		t[i] = rune(i)
+	}
+	cp1252 := []rune{
+		0x20ac, 0x0081, 0x201a, 0x0192, 0x201e, 0x2026, 0x2020, 0x2021, 0x02c6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008d, 0x017d, 0x008f,
+		0x0090, 0x2018, 0x2019, 0x201c, 0x201d, 0x2022, 0x2013, 0x2014, 0x02dc, 0x2122, 0x0161, 0x203a, 0x0153, 0x009d, 0x017e, 0x0178,
+	}
+	copy(t[0x80:], cp1252)
+	return
+}()
+
+var asciiTable = func() (t [256]rune) {
+	for i := range t {
+		t[i] = utf8.RuneError
+		if i < utf8.RuneSelf {
+			t[i] = rune(i)
+		}
+	}
+	return
+}()
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]Encoding{
+		"latin1": NewSingleByteEncoding("latin1", latin1Table),
+		"ascii":  NewSingleByteEncoding("ascii", asciiTable),
+	}
+)
+
+// RegisterEncoding registers the Encoding of the charset, it replaces the registered one.
+// The multi-byte charsets like gbk are plugged by it, the golang.org/x/text encodings fit with an adapter.
+func RegisterEncoding(charset string, enc Encoding) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	encodings[strings.ToLower(charset)] = enc
+}
+
+// LookupEncoding returns the Encoding of the charset, nil if the bytes pass through untranslated,
+// it's the case of the utf8, utf8mb4, binary and the charsets not registered.
+func LookupEncoding(charset string) Encoding {
+	charset = strings.ToLower(charset)
+	switch charset {
+	case "", "utf8", "utf8mb3", "utf8mb4", "binary":
+		return nil
+	}
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	return encodings[charset]
+}
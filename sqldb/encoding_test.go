@@ -0,0 +1,62 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodingLatin1(t *testing.T) {
+	enc := LookupEncoding("LATIN1")
+	assert.NotNil(t, enc)
+
+	// 'café €' in latin1, the 0x80 is the euro sign of the cp1252.
+	latin1 := []byte{'c', 'a', 'f', 0xe9, ' ', 0x80, 0x81}
+	utf8, err := enc.Decode(latin1)
+	assert.Nil(t, err)
+	assert.Equal(t, "café €\u0081", string(utf8))
+	assert.Equal(t, latin1, enc.Encode(utf8))
+
+	// The characters not in the latin1 and the invalid UTF-8 are replaced.
+	assert.Equal(t, []byte("a??b?"), enc.Encode([]byte("a中文b\xff")))
+}
+
+func TestEncodingASCII(t *testing.T) {
+	enc := LookupEncoding("ascii")
+	utf8, err := enc.Decode([]byte("abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(utf8))
+	_, err = enc.Decode([]byte{'a', 0xe9})
+	assert.Equal(t, "encoding.ascii.invalid.byte[0xe9].at[1]", err.Error())
+	assert.Equal(t, []byte("caf?"), enc.Encode([]byte("café")))
+}
+
+type fakeEncoding struct{}
+
+func (fakeEncoding) Decode(src []byte) ([]byte, error) { return src, nil }
+func (fakeEncoding) Encode(src []byte) []byte          { return []byte("x") }
+
+func TestRegisterEncoding(t *testing.T) {
+	for _, charset := range []string{"", "utf8", "utf8mb4", "binary", "gbk"} {
+		assert.Nil(t, LookupEncoding(charset), charset)
+	}
+
+	RegisterEncoding("GBK", fakeEncoding{})
+	defer func() {
+		encodingsMu.Lock()
+		delete(encodings, "gbk")
+		encodingsMu.Unlock()
+	}()
+	enc := LookupEncoding("gbk")
+	assert.NotNil(t, enc)
+	assert.Equal(t, []byte("x"), enc.Encode([]byte("abc")))
+}
@@ -0,0 +1,92 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"bytes"
+	"fmt"
+)
+
+//go:generate go run ./generr -in generr/messages_excerpt.txt -out errors_catalog.go
+
+// ErrorInfo is a server error of the catalog, the Message is the template of the MySQL source.
+type ErrorInfo struct {
+	Num     uint16
+	Name    string
+	State   string
+	Message string
+}
+
+var (
+	errorsByNum  = make(map[uint16]*ErrorInfo, len(errorCatalog))
+	errorsByName = make(map[string]*ErrorInfo, len(errorCatalog))
+)
+
+func init() {
+	for i := range errorCatalog {
+		e := &errorCatalog[i]
+		errorsByNum[e.Num] = e
+		errorsByName[e.Name] = e
+	}
+}
+
+// LookupError returns the error of the code from the catalog.
+func LookupError(num uint16) (*ErrorInfo, bool) {
+	e, ok := errorsByNum[num]
+	return e, ok
+}
+
+// LookupErrorByName returns the error of the name like ER_DUP_ENTRY from the catalog.
+func LookupErrorByName(name string) (*ErrorInfo, bool) {
+	e, ok := errorsByName[name]
+	return e, ok
+}
+
+// Format fills the placeholders of the template by the args.
+func (e *ErrorInfo) Format(args ...interface{}) string {
+	return fmt.Sprintf(FormatTemplate(e.Message), args...)
+}
+
+// New creates the SQLError of the error with the message formatted by the args.
+func (e *ErrorInfo) New(args ...interface{}) *SQLError {
+	return &SQLError{Num: e.Num, State: e.State, Message: e.Format(args...)}
+}
+
+// FormatTemplate converts the printf placeholders of the MySQL templates to the Go ones,
+// the length modifiers like the %lu and %zu are dropped and the %u is the %d.
+func FormatTemplate(template string) string {
+	buf := bytes.NewBuffer(make([]byte, 0, len(template)))
+	for i := 0; i < len(template); i++ {
+		c := template[i]
+		buf.WriteByte(c)
+		if c != '%' {
+			continue
+		}
+
+		// Flags, width and precision.
+		i++
+		for ; i < len(template) && bytes.IndexByte([]byte("-+ #0123456789.*"), template[i]) >= 0; i++ {
+			buf.WriteByte(template[i])
+		}
+		// Length modifiers.
+		for ; i < len(template) && bytes.IndexByte([]byte("hlLqjzt"), template[i]) >= 0; i++ {
+		}
+		if i == len(template) {
+			break
+		}
+		switch template[i] {
+		case 'u', 'i':
+			buf.WriteByte('d')
+		default:
+			buf.WriteByte(template[i])
+		}
+	}
+	return buf.String()
+}
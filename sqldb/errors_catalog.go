@@ -0,0 +1,261 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Code generated by generr from the messages_excerpt.txt; DO NOT EDIT.
+
+package sqldb
+
+// errorCatalog are the server errors by the codes.
+var errorCatalog = []ErrorInfo{
+	{Num: 1000, Name: "ER_HASHCHK", State: "HY000", Message: "hashchk"},
+	{Num: 1001, Name: "ER_NISAMCHK", State: "HY000", Message: "isamchk"},
+	{Num: 1002, Name: "ER_NO", State: "HY000", Message: "NO"},
+	{Num: 1003, Name: "ER_YES", State: "HY000", Message: "YES"},
+	{Num: 1004, Name: "ER_CANT_CREATE_FILE", State: "HY000", Message: "Can't create file '%-.200s' (errno: %d - %s)"},
+	{Num: 1005, Name: "ER_CANT_CREATE_TABLE", State: "HY000", Message: "Can't create table '%-.200s' (errno: %d - %s)"},
+	{Num: 1006, Name: "ER_CANT_CREATE_DB", State: "HY000", Message: "Can't create database '%-.192s' (errno: %d - %s)"},
+	{Num: 1007, Name: "ER_DB_CREATE_EXISTS", State: "HY000", Message: "Can't create database '%-.192s'; database exists"},
+	{Num: 1008, Name: "ER_DB_DROP_EXISTS", State: "HY000", Message: "Can't drop database '%-.192s'; database doesn't exist"},
+	{Num: 1009, Name: "ER_DB_DROP_DELETE", State: "HY000", Message: "Error dropping database (can't delete '%-.192s', errno: %d - %s)"},
+	{Num: 1010, Name: "ER_DB_DROP_RMDIR", State: "HY000", Message: "Error dropping database (can't rmdir '%-.192s', errno: %d - %s)"},
+	{Num: 1011, Name: "ER_CANT_DELETE_FILE", State: "HY000", Message: "Error on delete of '%-.192s' (errno: %d - %s)"},
+	{Num: 1012, Name: "ER_CANT_FIND_SYSTEM_REC", State: "HY000", Message: "Can't read record in system table"},
+	{Num: 1013, Name: "ER_CANT_GET_STAT", State: "HY000", Message: "Can't get status of '%-.200s' (errno: %d - %s)"},
+	{Num: 1014, Name: "ER_CANT_GET_WD", State: "HY000", Message: "Can't get working directory (errno: %d - %s)"},
+	{Num: 1015, Name: "ER_CANT_LOCK", State: "HY000", Message: "Can't lock file (errno: %d - %s)"},
+	{Num: 1016, Name: "ER_CANT_OPEN_FILE", State: "HY000", Message: "Can't open file: '%-.200s' (errno: %d - %s)"},
+	{Num: 1017, Name: "ER_FILE_NOT_FOUND", State: "HY000", Message: "Can't find file: '%-.200s' (errno: %d - %s)"},
+	{Num: 1018, Name: "ER_CANT_READ_DIR", State: "HY000", Message: "Can't read dir of '%-.192s' (errno: %d - %s)"},
+	{Num: 1019, Name: "ER_CANT_SET_WD", State: "HY000", Message: "Can't change dir to '%-.192s' (errno: %d - %s)"},
+	{Num: 1020, Name: "ER_CHECKREAD", State: "HY000", Message: "Record has changed since last read in table '%-.192s'"},
+	{Num: 1021, Name: "ER_DISK_FULL", State: "HY000", Message: "Disk full (%s); waiting for someone to free some space... (errno: %d - %s)"},
+	{Num: 1022, Name: "ER_DUP_KEY", State: "23000", Message: "Can't write; duplicate key in table '%-.192s'"},
+	{Num: 1023, Name: "ER_ERROR_ON_CLOSE", State: "HY000", Message: "Error on close of '%-.192s' (errno: %d - %s)"},
+	{Num: 1024, Name: "ER_ERROR_ON_READ", State: "HY000", Message: "Error reading file '%-.200s' (errno: %d - %s)"},
+	{Num: 1025, Name: "ER_ERROR_ON_RENAME", State: "HY000", Message: "Error on rename of '%-.210s' to '%-.210s' (errno: %d - %s)"},
+	{Num: 1026, Name: "ER_ERROR_ON_WRITE", State: "HY000", Message: "Error writing file '%-.200s' (errno: %d - %s)"},
+	{Num: 1027, Name: "ER_FILE_USED", State: "HY000", Message: "'%-.192s' is locked against change"},
+	{Num: 1028, Name: "ER_FILSORT_ABORT", State: "HY000", Message: "Sort aborted"},
+	{Num: 1029, Name: "ER_FORM_NOT_FOUND", State: "HY000", Message: "View '%-.192s' doesn't exist for '%-.192s'"},
+	{Num: 1030, Name: "ER_GET_ERRNO", State: "HY000", Message: "Got error %d - '%-.192s' from storage engine"},
+	{Num: 1031, Name: "ER_ILLEGAL_HA", State: "HY000", Message: "Table storage engine for '%-.192s' doesn't have this option"},
+	{Num: 1032, Name: "ER_KEY_NOT_FOUND", State: "HY000", Message: "Can't find record in '%-.192s'"},
+	{Num: 1033, Name: "ER_NOT_FORM_FILE", State: "HY000", Message: "Incorrect information in file: '%-.200s'"},
+	{Num: 1034, Name: "ER_NOT_KEYFILE", State: "HY000", Message: "Incorrect key file for table '%-.200s'; try to repair it"},
+	{Num: 1035, Name: "ER_OLD_KEYFILE", State: "HY000", Message: "Old key file for table '%-.192s'; repair it!"},
+	{Num: 1036, Name: "ER_OPEN_AS_READONLY", State: "HY000", Message: "Table '%-.192s' is read only"},
+	{Num: 1037, Name: "ER_OUTOFMEMORY", State: "HY001", Message: "Out of memory; restart server and try again (needed %d bytes)"},
+	{Num: 1038, Name: "ER_OUT_OF_SORTMEMORY", State: "HY001", Message: "Out of sort memory, consider increasing server sort buffer size"},
+	{Num: 1039, Name: "ER_UNEXPECTED_EOF", State: "HY000", Message: "Unexpected EOF found when reading file '%-.192s' (errno: %d - %s)"},
+	{Num: 1040, Name: "ER_CON_COUNT_ERROR", State: "08004", Message: "Too many connections"},
+	{Num: 1041, Name: "ER_OUT_OF_RESOURCES", State: "HY000", Message: "Out of memory; check if mysqld or some other process uses all available memory; if not, you may have to use 'ulimit' to allow mysqld to use more memory or you can add more swap space"},
+	{Num: 1042, Name: "ER_BAD_HOST_ERROR", State: "08S01", Message: "Can't get hostname for your address"},
+	{Num: 1043, Name: "ER_HANDSHAKE_ERROR", State: "08S01", Message: "Bad handshake"},
+	{Num: 1044, Name: "ER_DBACCESS_DENIED_ERROR", State: "42000", Message: "Access denied for user '%-.48s'@'%-.64s' to database '%-.192s'"},
+	{Num: 1045, Name: "ER_ACCESS_DENIED_ERROR", State: "28000", Message: "Access denied for user '%-.48s'@'%-.64s' (using password: %s)"},
+	{Num: 1046, Name: "ER_NO_DB_ERROR", State: "3D000", Message: "No database selected"},
+	{Num: 1047, Name: "ER_UNKNOWN_COM_ERROR", State: "08S01", Message: "Unknown command"},
+	{Num: 1048, Name: "ER_BAD_NULL_ERROR", State: "23000", Message: "Column '%-.192s' cannot be null"},
+	{Num: 1049, Name: "ER_BAD_DB_ERROR", State: "42000", Message: "Unknown database '%-.192s'"},
+	{Num: 1050, Name: "ER_TABLE_EXISTS_ERROR", State: "42S01", Message: "Table '%-.192s' already exists"},
+	{Num: 1051, Name: "ER_BAD_TABLE_ERROR", State: "42S02", Message: "Unknown table '%-.129s'"},
+	{Num: 1052, Name: "ER_NON_UNIQ_ERROR", State: "23000", Message: "Column '%-.192s' in %-.192s is ambiguous"},
+	{Num: 1053, Name: "ER_SERVER_SHUTDOWN", State: "08S01", Message: "Server shutdown in progress"},
+	{Num: 1054, Name: "ER_BAD_FIELD_ERROR", State: "42S22", Message: "Unknown column '%-.192s' in '%-.192s'"},
+	{Num: 1055, Name: "ER_WRONG_FIELD_WITH_GROUP", State: "42000", Message: "'%-.192s' isn't in GROUP BY"},
+	{Num: 1056, Name: "ER_WRONG_GROUP_FIELD", State: "42000", Message: "Can't group on '%-.192s'"},
+	{Num: 1057, Name: "ER_WRONG_SUM_SELECT", State: "42000", Message: "Statement has sum functions and columns in same statement"},
+	{Num: 1058, Name: "ER_WRONG_VALUE_COUNT", State: "21S01", Message: "Column count doesn't match value count"},
+	{Num: 1059, Name: "ER_TOO_LONG_IDENT", State: "42000", Message: "Identifier name '%-.100s' is too long"},
+	{Num: 1060, Name: "ER_DUP_FIELDNAME", State: "42S21", Message: "Duplicate column name '%-.192s'"},
+	{Num: 1061, Name: "ER_DUP_KEYNAME", State: "42000", Message: "Duplicate key name '%-.192s'"},
+	{Num: 1062, Name: "ER_DUP_ENTRY", State: "23000", Message: "Duplicate entry '%-.192s' for key '%-.192s'"},
+	{Num: 1063, Name: "ER_WRONG_FIELD_SPEC", State: "42000", Message: "Incorrect column specifier for column '%-.192s'"},
+	{Num: 1064, Name: "ER_PARSE_ERROR", State: "42000", Message: "%s near '%-.80s' at line %d"},
+	{Num: 1065, Name: "ER_EMPTY_QUERY", State: "42000", Message: "Query was empty"},
+	{Num: 1066, Name: "ER_NONUNIQ_TABLE", State: "42000", Message: "Not unique table/alias: '%-.192s'"},
+	{Num: 1067, Name: "ER_INVALID_DEFAULT", State: "42000", Message: "Invalid default value for '%-.192s'"},
+	{Num: 1068, Name: "ER_MULTIPLE_PRI_KEY", State: "42000", Message: "Multiple primary key defined"},
+	{Num: 1069, Name: "ER_TOO_MANY_KEYS", State: "42000", Message: "Too many keys specified; max %d keys allowed"},
+	{Num: 1070, Name: "ER_TOO_MANY_KEY_PARTS", State: "42000", Message: "Too many key parts specified; max %d parts allowed"},
+	{Num: 1071, Name: "ER_TOO_LONG_KEY", State: "42000", Message: "Specified key was too long; max key length is %d bytes"},
+	{Num: 1072, Name: "ER_KEY_COLUMN_DOES_NOT_EXITS", State: "42000", Message: "Key column '%-.192s' doesn't exist in table"},
+	{Num: 1073, Name: "ER_BLOB_USED_AS_KEY", State: "42000", Message: "BLOB column '%-.192s' can't be used in key specification with the used table type"},
+	{Num: 1074, Name: "ER_TOO_BIG_FIELDLENGTH", State: "42000", Message: "Column length too big for column '%-.192s' (max = %lu); use BLOB or TEXT instead"},
+	{Num: 1075, Name: "ER_WRONG_AUTO_KEY", State: "42000", Message: "Incorrect table definition; there can be only one auto column and it must be defined as a key"},
+	{Num: 1081, Name: "ER_IPSOCK_ERROR", State: "08S01", Message: "Can't create IP socket"},
+	{Num: 1082, Name: "ER_NO_SUCH_INDEX", State: "42S12", Message: "Table '%-.192s' has no index like the one used in CREATE INDEX; recreate the table"},
+	{Num: 1083, Name: "ER_WRONG_FIELD_TERMINATORS", State: "42000", Message: "Field separator argument is not what is expected; check the manual"},
+	{Num: 1084, Name: "ER_BLOBS_AND_NO_TERMINATED", State: "42000", Message: "You can't use fixed rowlength with BLOBs; please use 'fields terminated by'"},
+	{Num: 1085, Name: "ER_TEXTFILE_NOT_READABLE", State: "HY000", Message: "The file '%-.128s' must be in the database directory or be readable by all"},
+	{Num: 1086, Name: "ER_FILE_EXISTS_ERROR", State: "HY000", Message: "File '%-.200s' already exists"},
+	{Num: 1087, Name: "ER_LOAD_INFO", State: "HY000", Message: "Records: %ld  Deleted: %ld  Skipped: %ld  Warnings: %ld"},
+	{Num: 1088, Name: "ER_ALTER_INFO", State: "HY000", Message: "Records: %ld  Duplicates: %ld"},
+	{Num: 1089, Name: "ER_WRONG_SUB_KEY", State: "HY000", Message: "Incorrect prefix key; the used key part isn't a string, the used length is longer than the key part, or the storage engine doesn't support unique prefix keys"},
+	{Num: 1090, Name: "ER_CANT_REMOVE_ALL_FIELDS", State: "42000", Message: "You can't delete all columns with ALTER TABLE; use DROP TABLE instead"},
+	{Num: 1091, Name: "ER_CANT_DROP_FIELD_OR_KEY", State: "42000", Message: "Can't DROP '%-.192s'; check that column/key exists"},
+	{Num: 1092, Name: "ER_INSERT_INFO", State: "HY000", Message: "Records: %ld  Duplicates: %ld  Warnings: %ld"},
+	{Num: 1093, Name: "ER_UPDATE_TABLE_USED", State: "HY000", Message: "You can't specify target table '%-.192s' for update in FROM clause"},
+	{Num: 1094, Name: "ER_NO_SUCH_THREAD", State: "HY000", Message: "Unknown thread id: %lu"},
+	{Num: 1095, Name: "ER_KILL_DENIED_ERROR", State: "HY000", Message: "You are not owner of thread %lu"},
+	{Num: 1096, Name: "ER_NO_TABLES_USED", State: "HY000", Message: "No tables used"},
+	{Num: 1097, Name: "ER_TOO_BIG_SET", State: "HY000", Message: "Too many strings for column %-.192s and SET"},
+	{Num: 1098, Name: "ER_NO_UNIQUE_LOGFILE", State: "HY000", Message: "Can't generate a unique log-filename %-.200s.(1-999)"},
+	{Num: 1099, Name: "ER_TABLE_NOT_LOCKED_FOR_WRITE", State: "HY000", Message: "Table '%-.192s' was locked with a READ lock and can't be updated"},
+	{Num: 1100, Name: "ER_TABLE_NOT_LOCKED", State: "HY000", Message: "Table '%-.192s' was not locked with LOCK TABLES"},
+	{Num: 1101, Name: "ER_BLOB_CANT_HAVE_DEFAULT", State: "42000", Message: "BLOB, TEXT, GEOMETRY or JSON column '%-.192s' can't have a default value"},
+	{Num: 1102, Name: "ER_WRONG_DB_NAME", State: "42000", Message: "Incorrect database name '%-.100s'"},
+	{Num: 1103, Name: "ER_WRONG_TABLE_NAME", State: "42000", Message: "Incorrect table name '%-.100s'"},
+	{Num: 1104, Name: "ER_TOO_BIG_SELECT", State: "42000", Message: "The SELECT would examine more than MAX_JOIN_SIZE rows; check your WHERE and use SET SQL_BIG_SELECTS=1 or SET MAX_JOIN_SIZE=# if the SELECT is okay"},
+	{Num: 1105, Name: "ER_UNKNOWN_ERROR", State: "HY000", Message: "Unknown error"},
+	{Num: 1106, Name: "ER_UNKNOWN_PROCEDURE", State: "42000", Message: "Unknown procedure '%-.192s'"},
+	{Num: 1107, Name: "ER_WRONG_PARAMCOUNT_TO_PROCEDURE", State: "42000", Message: "Incorrect parameter count to procedure '%-.192s'"},
+	{Num: 1108, Name: "ER_WRONG_PARAMETERS_TO_PROCEDURE", State: "HY000", Message: "Incorrect parameters to procedure '%-.192s'"},
+	{Num: 1109, Name: "ER_UNKNOWN_TABLE", State: "42S02", Message: "Unknown table '%-.192s' in %-.32s"},
+	{Num: 1110, Name: "ER_FIELD_SPECIFIED_TWICE", State: "42000", Message: "Column '%-.192s' specified twice"},
+	{Num: 1111, Name: "ER_INVALID_GROUP_FUNC_USE", State: "HY000", Message: "Invalid use of group function"},
+	{Num: 1112, Name: "ER_UNSUPPORTED_EXTENSION", State: "42000", Message: "Table '%-.192s' uses an extension that doesn't exist in this MySQL version"},
+	{Num: 1113, Name: "ER_TABLE_MUST_HAVE_COLUMNS", State: "42000", Message: "A table must have at least 1 column"},
+	{Num: 1114, Name: "ER_RECORD_FILE_FULL", State: "HY000", Message: "The table '%-.192s' is full"},
+	{Num: 1115, Name: "ER_UNKNOWN_CHARACTER_SET", State: "42000", Message: "Unknown character set: '%-.64s'"},
+	{Num: 1116, Name: "ER_TOO_MANY_TABLES", State: "HY000", Message: "Too many tables; MySQL can only use %d tables in a join"},
+	{Num: 1117, Name: "ER_TOO_MANY_FIELDS", State: "HY000", Message: "Too many columns"},
+	{Num: 1118, Name: "ER_TOO_BIG_ROWSIZE", State: "42000", Message: "Row size too large. The maximum row size for the used table type, not counting BLOBs, is %ld. This includes storage overhead, check the manual. You have to change some columns to TEXT or BLOBs"},
+	{Num: 1119, Name: "ER_STACK_OVERRUN", State: "HY000", Message: "Thread stack overrun:  Used: %ld of a %ld stack.  Use 'mysqld --thread_stack=#' to specify a bigger stack if needed"},
+	{Num: 1120, Name: "ER_WRONG_OUTER_JOIN", State: "42000", Message: "Cross dependency found in OUTER JOIN; examine your ON conditions"},
+	{Num: 1121, Name: "ER_NULL_COLUMN_IN_INDEX", State: "42000", Message: "Table handler doesn't support NULL in given index. Please change column '%-.192s' to be NOT NULL or use another handler"},
+	{Num: 1122, Name: "ER_CANT_FIND_UDF", State: "HY000", Message: "Can't load function '%-.192s'"},
+	{Num: 1123, Name: "ER_CANT_INITIALIZE_UDF", State: "HY000", Message: "Can't initialize function '%-.192s'; %-.80s"},
+	{Num: 1124, Name: "ER_UDF_NO_PATHS", State: "HY000", Message: "No paths allowed for shared library"},
+	{Num: 1125, Name: "ER_UDF_EXISTS", State: "HY000", Message: "Function '%-.192s' already exists"},
+	{Num: 1126, Name: "ER_CANT_OPEN_LIBRARY", State: "HY000", Message: "Can't open shared library '%-.192s' (errno: %d %-.128s)"},
+	{Num: 1127, Name: "ER_CANT_FIND_DL_ENTRY", State: "HY000", Message: "Can't find symbol '%-.128s' in library"},
+	{Num: 1128, Name: "ER_FUNCTION_NOT_DEFINED", State: "HY000", Message: "Function '%-.192s' is not defined"},
+	{Num: 1129, Name: "ER_HOST_IS_BLOCKED", State: "HY000", Message: "Host '%-.64s' is blocked because of many connection errors; unblock with 'mysqladmin flush-hosts'"},
+	{Num: 1130, Name: "ER_HOST_NOT_PRIVILEGED", State: "HY000", Message: "Host '%-.64s' is not allowed to connect to this MySQL server"},
+	{Num: 1131, Name: "ER_PASSWORD_ANONYMOUS_USER", State: "42000", Message: "You are using MySQL as an anonymous user and anonymous users are not allowed to change passwords"},
+	{Num: 1132, Name: "ER_PASSWORD_NOT_ALLOWED", State: "42000", Message: "You must have privileges to update tables in the mysql database to be able to change passwords for others"},
+	{Num: 1133, Name: "ER_PASSWORD_NO_MATCH", State: "42000", Message: "Can't find any matching row in the user table"},
+	{Num: 1134, Name: "ER_UPDATE_INFO", State: "HY000", Message: "Rows matched: %ld  Changed: %ld  Warnings: %ld"},
+	{Num: 1135, Name: "ER_CANT_CREATE_THREAD", State: "HY000", Message: "Can't create a new thread (errno %d); if you are not out of available memory, you can consult the manual for a possible OS-dependent bug"},
+	{Num: 1136, Name: "ER_WRONG_VALUE_COUNT_ON_ROW", State: "21S01", Message: "Column count doesn't match value count at row %ld"},
+	{Num: 1137, Name: "ER_CANT_REOPEN_TABLE", State: "HY000", Message: "Can't reopen table: '%-.192s'"},
+	{Num: 1138, Name: "ER_INVALID_USE_OF_NULL", State: "22004", Message: "Invalid use of NULL value"},
+	{Num: 1139, Name: "ER_REGEXP_ERROR", State: "42000", Message: "Got error '%-.64s' from regexp"},
+	{Num: 1140, Name: "ER_MIX_OF_GROUP_FUNC_AND_FIELDS", State: "42000", Message: "Mixing of GROUP columns (MIN(),MAX(),COUNT(),...) with no GROUP columns is illegal if there is no GROUP BY clause"},
+	{Num: 1141, Name: "ER_NONEXISTING_GRANT", State: "42000", Message: "There is no such grant defined for user '%-.48s' on host '%-.64s'"},
+	{Num: 1142, Name: "ER_TABLEACCESS_DENIED_ERROR", State: "42000", Message: "%-.128s command denied to user '%-.48s'@'%-.64s' for table '%-.64s'"},
+	{Num: 1143, Name: "ER_COLUMNACCESS_DENIED_ERROR", State: "42000", Message: "%-.16s command denied to user '%-.48s'@'%-.64s' for column '%-.192s' in table '%-.192s'"},
+	{Num: 1144, Name: "ER_ILLEGAL_GRANT_FOR_TABLE", State: "42000", Message: "Illegal GRANT/REVOKE command; please consult the manual to see which privileges can be used"},
+	{Num: 1145, Name: "ER_GRANT_WRONG_HOST_OR_USER", State: "42000", Message: "The host or user argument to GRANT is too long"},
+	{Num: 1146, Name: "ER_NO_SUCH_TABLE", State: "42S02", Message: "Table '%-.192s.%-.192s' doesn't exist"},
+	{Num: 1147, Name: "ER_NONEXISTING_TABLE_GRANT", State: "42000", Message: "There is no such grant defined for user '%-.48s' on host '%-.64s' on table '%-.192s'"},
+	{Num: 1148, Name: "ER_NOT_ALLOWED_COMMAND", State: "42000", Message: "The used command is not allowed with this MySQL version"},
+	{Num: 1149, Name: "ER_SYNTAX_ERROR", State: "42000", Message: "You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use"},
+	{Num: 1152, Name: "ER_ABORTING_CONNECTION", State: "08S01", Message: "Aborted connection %u to db: '%-.192s' user: '%-.48s' (%-.64s)"},
+	{Num: 1153, Name: "ER_NET_PACKET_TOO_LARGE", State: "08S01", Message: "Got a packet bigger than 'max_allowed_packet' bytes"},
+	{Num: 1154, Name: "ER_NET_READ_ERROR_FROM_PIPE", State: "08S01", Message: "Got a read error from the connection pipe"},
+	{Num: 1155, Name: "ER_NET_FCNTL_ERROR", State: "08S01", Message: "Got an error from fcntl()"},
+	{Num: 1156, Name: "ER_NET_PACKETS_OUT_OF_ORDER", State: "08S01", Message: "Got packets out of order"},
+	{Num: 1157, Name: "ER_NET_UNCOMPRESS_ERROR", State: "08S01", Message: "Couldn't uncompress communication packet"},
+	{Num: 1158, Name: "ER_NET_READ_ERROR", State: "08S01", Message: "Got an error reading communication packets"},
+	{Num: 1159, Name: "ER_NET_READ_INTERRUPTED", State: "08S01", Message: "Got timeout reading communication packets"},
+	{Num: 1160, Name: "ER_NET_ERROR_ON_WRITE", State: "08S01", Message: "Got an error writing communication packets"},
+	{Num: 1161, Name: "ER_NET_WRITE_INTERRUPTED", State: "08S01", Message: "Got timeout writing communication packets"},
+	{Num: 1162, Name: "ER_TOO_LONG_STRING", State: "42000", Message: "Result string is longer than 'max_allowed_packet' bytes"},
+	{Num: 1163, Name: "ER_TABLE_CANT_HANDLE_BLOB", State: "42000", Message: "The used table type doesn't support BLOB/TEXT columns"},
+	{Num: 1164, Name: "ER_TABLE_CANT_HANDLE_AUTO_INCREMENT", State: "42000", Message: "The used table type doesn't support AUTO_INCREMENT columns"},
+	{Num: 1166, Name: "ER_WRONG_COLUMN_NAME", State: "42000", Message: "Incorrect column name '%-.100s'"},
+	{Num: 1167, Name: "ER_WRONG_KEY_COLUMN", State: "42000", Message: "The used storage engine can't index column '%-.192s'"},
+	{Num: 1169, Name: "ER_DUP_UNIQUE", State: "23000", Message: "Can't write, because of unique constraint, to table '%-.192s'"},
+	{Num: 1170, Name: "ER_BLOB_KEY_WITHOUT_LENGTH", State: "42000", Message: "BLOB/TEXT column '%-.192s' used in key specification without a key length"},
+	{Num: 1171, Name: "ER_PRIMARY_CANT_HAVE_NULL", State: "42000", Message: "All parts of a PRIMARY KEY must be NOT NULL; if you need NULL in a key, use UNIQUE instead"},
+	{Num: 1172, Name: "ER_TOO_MANY_ROWS", State: "42000", Message: "Result consisted of more than one row"},
+	{Num: 1173, Name: "ER_REQUIRES_PRIMARY_KEY", State: "42000", Message: "This table type requires a primary key"},
+	{Num: 1175, Name: "ER_UPDATE_WITHOUT_KEY_IN_SAFE_MODE", State: "HY000", Message: "You are using safe update mode and you tried to update a table without a WHERE that uses a KEY column. %s"},
+	{Num: 1176, Name: "ER_KEY_DOES_NOT_EXITS", State: "42000", Message: "Key '%-.192s' doesn't exist in table '%-.192s'"},
+	{Num: 1177, Name: "ER_CHECK_NO_SUCH_TABLE", State: "42000", Message: "Can't open table"},
+	{Num: 1178, Name: "ER_CHECK_NOT_IMPLEMENTED", State: "42000", Message: "The storage engine for the table doesn't support %s"},
+	{Num: 1179, Name: "ER_CANT_DO_THIS_DURING_AN_TRANSACTION", State: "25000", Message: "You are not allowed to execute this command in a transaction"},
+	{Num: 1180, Name: "ER_ERROR_DURING_COMMIT", State: "HY000", Message: "Got error %d - '%-.192s' during COMMIT"},
+	{Num: 1181, Name: "ER_ERROR_DURING_ROLLBACK", State: "HY000", Message: "Got error %d - '%-.192s' during ROLLBACK"},
+	{Num: 1182, Name: "ER_ERROR_DURING_FLUSH_LOGS", State: "HY000", Message: "Got error %d during FLUSH_LOGS"},
+	{Num: 1183, Name: "ER_ERROR_DURING_CHECKPOINT", State: "HY000", Message: "Got error %d during CHECKPOINT"},
+	{Num: 1184, Name: "ER_NEW_ABORTING_CONNECTION", State: "08S01", Message: "Aborted connection %u to db: '%-.192s' user: '%-.48s' host: '%-.64s' (%-.64s)"},
+	{Num: 1192, Name: "ER_LOCK_OR_ACTIVE_TRANSACTION", State: "HY000", Message: "Can't execute the given command because you have active locked tables or an active transaction"},
+	{Num: 1193, Name: "ER_UNKNOWN_SYSTEM_VARIABLE", State: "HY000", Message: "Unknown system variable '%-.64s'"},
+	{Num: 1194, Name: "ER_CRASHED_ON_USAGE", State: "HY000", Message: "Table '%-.192s' is marked as crashed and should be repaired"},
+	{Num: 1195, Name: "ER_CRASHED_ON_REPAIR", State: "HY000", Message: "Table '%-.192s' is marked as crashed and last (automatic?) repair failed"},
+	{Num: 1196, Name: "ER_WARNING_NOT_COMPLETE_ROLLBACK", State: "HY000", Message: "Some non-transactional changed tables couldn't be rolled back"},
+	{Num: 1197, Name: "ER_TRANS_CACHE_FULL", State: "HY000", Message: "Multi-statement transaction required more than 'max_binlog_cache_size' bytes of storage; increase this mysqld variable and try again"},
+	{Num: 1203, Name: "ER_TOO_MANY_USER_CONNECTIONS", State: "42000", Message: "User %-.64s already has more than 'max_user_connections' active connections"},
+	{Num: 1204, Name: "ER_SET_CONSTANTS_ONLY", State: "HY000", Message: "You may only use constant expressions in this statement"},
+	{Num: 1205, Name: "ER_LOCK_WAIT_TIMEOUT", State: "HY000", Message: "Lock wait timeout exceeded; try restarting transaction"},
+	{Num: 1206, Name: "ER_LOCK_TABLE_FULL", State: "HY000", Message: "The total number of locks exceeds the lock table size"},
+	{Num: 1207, Name: "ER_READ_ONLY_TRANSACTION", State: "25000", Message: "Update locks cannot be acquired during a READ UNCOMMITTED transaction"},
+	{Num: 1210, Name: "ER_WRONG_ARGUMENTS", State: "HY000", Message: "Incorrect arguments to %s"},
+	{Num: 1211, Name: "ER_NO_PERMISSION_TO_CREATE_USER", State: "42000", Message: "'%-.48s'@'%-.64s' is not allowed to create new users"},
+	{Num: 1213, Name: "ER_LOCK_DEADLOCK", State: "40001", Message: "Deadlock found when trying to get lock; try restarting transaction"},
+	{Num: 1216, Name: "ER_NO_REFERENCED_ROW", State: "23000", Message: "Cannot add or update a child row: a foreign key constraint fails"},
+	{Num: 1217, Name: "ER_ROW_IS_REFERENCED", State: "23000", Message: "Cannot delete or update a parent row: a foreign key constraint fails"},
+	{Num: 1226, Name: "ER_USER_LIMIT_REACHED", State: "42000", Message: "User '%-.64s' has exceeded the '%s' resource (current value: %ld)"},
+	{Num: 1227, Name: "ER_SPECIFIC_ACCESS_DENIED_ERROR", State: "42000", Message: "Access denied; you need (at least one of) the %-.128s privilege(s) for this operation"},
+	{Num: 1228, Name: "ER_LOCAL_VARIABLE", State: "HY000", Message: "Variable '%-.64s' is a SESSION variable and can't be used with SET GLOBAL"},
+	{Num: 1229, Name: "ER_GLOBAL_VARIABLE", State: "HY000", Message: "Variable '%-.64s' is a GLOBAL variable and should be set with SET GLOBAL"},
+	{Num: 1230, Name: "ER_NO_DEFAULT", State: "42000", Message: "Variable '%-.64s' doesn't have a default value"},
+	{Num: 1231, Name: "ER_WRONG_VALUE_FOR_VAR", State: "42000", Message: "Variable '%-.64s' can't be set to the value of '%-.200s'"},
+	{Num: 1232, Name: "ER_WRONG_TYPE_FOR_VAR", State: "42000", Message: "Incorrect argument type to variable '%-.64s'"},
+	{Num: 1233, Name: "ER_VAR_CANT_BE_READ", State: "HY000", Message: "Variable '%-.64s' can only be set, not read"},
+	{Num: 1234, Name: "ER_WRONG_USAGE", State: "HY000", Message: "Incorrect usage of %s and %s"},
+	{Num: 1235, Name: "ER_NOT_SUPPORTED_YET", State: "42000", Message: "This version of MySQL doesn't yet support '%s'"},
+	{Num: 1236, Name: "ER_MASTER_FATAL_ERROR_READING_BINLOG", State: "HY000", Message: "Got fatal error %d from master when reading data from binary log: '%-.512s'"},
+	{Num: 1238, Name: "ER_INCORRECT_GLOBAL_LOCAL_VAR", State: "HY000", Message: "Variable '%-.192s' is a %s variable"},
+	{Num: 1241, Name: "ER_OPERAND_COLUMNS", State: "21000", Message: "Operand should contain %d column(s)"},
+	{Num: 1242, Name: "ER_SUBQUERY_NO_1_ROW", State: "21000", Message: "Subquery returns more than 1 row"},
+	{Num: 1243, Name: "ER_UNKNOWN_STMT_HANDLER", State: "HY000", Message: "Unknown prepared statement handler (%.*s) given to %s"},
+	{Num: 1248, Name: "ER_DERIVED_MUST_HAVE_ALIAS", State: "42000", Message: "Every derived table must have its own alias"},
+	{Num: 1251, Name: "ER_NOT_SUPPORTED_AUTH_MODE", State: "08004", Message: "Client does not support authentication protocol requested by server; consider upgrading MySQL client"},
+	{Num: 1264, Name: "ER_WARN_DATA_OUT_OF_RANGE", State: "22003", Message: "Out of range value for column '%s' at row %ld"},
+	{Num: 1265, Name: "WARN_DATA_TRUNCATED", State: "01000", Message: "Data truncated for column '%s' at row %ld"},
+	{Num: 1267, Name: "ER_CANT_AGGREGATE_2COLLATIONS", State: "HY000", Message: "Illegal mix of collations (%s,%s) and (%s,%s) for operation '%s'"},
+	{Num: 1273, Name: "ER_UNKNOWN_COLLATION", State: "HY000", Message: "Unknown collation: '%-.64s'"},
+	{Num: 1290, Name: "ER_OPTION_PREVENTS_STATEMENT", State: "HY000", Message: "The MySQL server is running with the %s option so it cannot execute this statement"},
+	{Num: 1292, Name: "ER_TRUNCATED_WRONG_VALUE", State: "22007", Message: "Truncated incorrect %-.32s value: '%-.128s'"},
+	{Num: 1295, Name: "ER_UNSUPPORTED_PS", State: "HY000", Message: "This command is not supported in the prepared statement protocol yet"},
+	{Num: 1298, Name: "ER_UNKNOWN_TIME_ZONE", State: "HY000", Message: "Unknown or incorrect time zone: '%-.64s'"},
+	{Num: 1300, Name: "ER_INVALID_CHARACTER_STRING", State: "HY000", Message: "Invalid %s character string: '%.64s'"},
+	{Num: 1305, Name: "ER_SP_DOES_NOT_EXIST", State: "42000", Message: "%s %s does not exist"},
+	{Num: 1317, Name: "ER_QUERY_INTERRUPTED", State: "70100", Message: "Query execution was interrupted"},
+	{Num: 1329, Name: "ER_SP_FETCH_NO_DATA", State: "02000", Message: "No data - zero rows fetched, selected, or processed"},
+	{Num: 1364, Name: "ER_NO_DEFAULT_FOR_FIELD", State: "HY000", Message: "Field '%-.192s' doesn't have a default value"},
+	{Num: 1365, Name: "ER_DIVISION_BY_ZERO", State: "22012", Message: "Division by 0"},
+	{Num: 1366, Name: "ER_TRUNCATED_WRONG_VALUE_FOR_FIELD", State: "HY000", Message: "Incorrect %-.32s value: '%-.128s' for column '%.192s' at row %ld"},
+	{Num: 1396, Name: "ER_CANNOT_USER", State: "HY000", Message: "Operation %s failed for %.256s"},
+	{Num: 1406, Name: "ER_DATA_TOO_LONG", State: "22001", Message: "Data too long for column '%s' at row %ld"},
+	{Num: 1410, Name: "ER_CANT_CREATE_USER_WITH_GRANT", State: "42000", Message: "You are not allowed to create a user with GRANT"},
+	{Num: 1449, Name: "ER_NO_SUCH_USER", State: "HY000", Message: "The user specified as a definer ('%-.64s'@'%-.64s') does not exist"},
+	{Num: 1451, Name: "ER_ROW_IS_REFERENCED_2", State: "23000", Message: "Cannot delete or update a parent row: a foreign key constraint fails (%.192s)"},
+	{Num: 1452, Name: "ER_NO_REFERENCED_ROW_2", State: "23000", Message: "Cannot add or update a child row: a foreign key constraint fails (%.192s)"},
+	{Num: 1461, Name: "ER_MAX_PREPARED_STMT_COUNT_REACHED", State: "42000", Message: "Can't create more than max_prepared_stmt_count statements (current value: %lu)"},
+	{Num: 1568, Name: "ER_CANT_CHANGE_TX_CHARACTERISTICS", State: "25001", Message: "Transaction characteristics can't be changed while a transaction is in progress"},
+	{Num: 1613, Name: "ER_XA_RBTIMEOUT", State: "XA106", Message: "XA_RBTIMEOUT: Transaction branch was rolled back: took too long"},
+	{Num: 1614, Name: "ER_XA_RBDEADLOCK", State: "XA102", Message: "XA_RBDEADLOCK: Transaction branch was rolled back: deadlock was detected"},
+	{Num: 1615, Name: "ER_NEED_REPREPARE", State: "HY000", Message: "Prepared statement needs to be re-prepared"},
+	{Num: 1792, Name: "ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION", State: "25006", Message: "Cannot execute statement in a READ ONLY transaction."},
+	{Num: 1820, Name: "ER_MUST_CHANGE_PASSWORD", State: "HY000", Message: "You must reset your password using ALTER USER statement before executing this statement."},
+	{Num: 1835, Name: "ER_MALFORMED_PACKET", State: "HY000", Message: "Malformed communication packet."},
+	{Num: 1836, Name: "ER_READ_ONLY_MODE", State: "HY000", Message: "Running in read-only mode"},
+	{Num: 1862, Name: "ER_MUST_CHANGE_PASSWORD_LOGIN", State: "HY000", Message: "Your password has expired. To log in you must change it using a client that supports expired passwords."},
+	{Num: 3024, Name: "ER_QUERY_TIMEOUT", State: "HY000", Message: "Query execution was interrupted, maximum statement execution time exceeded"},
+	{Num: 3101, Name: "ER_TRANSACTION_ROLLBACK_DURING_COMMIT", State: "40000", Message: "Plugin instructed the server to rollback the current transaction."},
+	{Num: 3118, Name: "ER_ACCOUNT_HAS_BEEN_LOCKED", State: "HY000", Message: "Access denied for user '%-.48s'@'%-.64s'. Account is locked."},
+	{Num: 3159, Name: "ER_SECURE_TRANSPORT_REQUIRED", State: "HY000", Message: "Connections using insecure transport are prohibited while --require_secure_transport=ON."},
+	{Num: 3572, Name: "ER_LOCK_NOWAIT", State: "HY000", Message: "Statement aborted because lock(s) could not be acquired immediately and NOWAIT is set."},
+	{Num: 4031, Name: "ER_CLIENT_INTERACTION_TIMEOUT", State: "HY000", Message: "The client was disconnected by the server because of inactivity. See wait_timeout and interactive_timeout for configuring this behavior."},
+}
@@ -0,0 +1,104 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqldb
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupError(t *testing.T) {
+	{
+		e, ok := LookupError(ER_DUP_ENTRY)
+		assert.True(t, ok)
+		assert.Equal(t, "ER_DUP_ENTRY", e.Name)
+		assert.Equal(t, "23000", e.State)
+	}
+
+	{
+		e, ok := LookupErrorByName("ER_NO_SUCH_TABLE")
+		assert.True(t, ok)
+		assert.Equal(t, uint16(ER_NO_SUCH_TABLE), e.Num)
+		assert.Equal(t, "42S02", e.State)
+	}
+
+	{
+		_, ok := LookupError(1)
+		assert.False(t, ok)
+		_, ok = LookupErrorByName("ER_NOT_AN_ERROR")
+		assert.False(t, ok)
+	}
+}
+
+func TestErrorCatalogConstants(t *testing.T) {
+	// The SQLErrors overrides of the catalog.
+	overrides := map[uint16]bool{
+		ER_OPTION_PREVENTS_STATEMENT: true,
+	}
+	for num, sqlErr := range SQLErrors {
+		e, ok := LookupError(num)
+		if !ok || overrides[num] {
+			continue
+		}
+		assert.Equal(t, sqlErr.State, e.State, e.Name)
+	}
+
+	last := uint16(0)
+	for _, e := range errorCatalog {
+		assert.True(t, e.Num > last, e.Name)
+		last = e.Num
+	}
+}
+
+func TestErrorFormat(t *testing.T) {
+	{
+		e, _ := LookupError(ER_NO_SUCH_TABLE)
+		assert.Equal(t, "Table 'db.t1' doesn't exist", e.Format("db", "t1"))
+	}
+
+	{
+		sqlErr := NewSQLError(ER_DUP_ENTRY, "")
+		assert.Equal(t, "23000", sqlErr.State)
+		e, _ := LookupError(ER_DUP_ENTRY)
+		sqlErr = e.New("1", "PRIMARY")
+		assert.Equal(t, "Duplicate entry '1' for key 'PRIMARY' (errno 1062) (sqlstate 23000)", sqlErr.Error())
+	}
+
+	// The late codes of the 5.7 and the 8.0.
+	{
+		e, ok := LookupError(ER_QUERY_TIMEOUT)
+		assert.True(t, ok)
+		assert.Equal(t, "ER_QUERY_TIMEOUT", e.Name)
+		assert.Equal(t, "Query execution was interrupted, maximum statement execution time exceeded (errno 3024) (sqlstate HY000)", e.New().Error())
+		e, ok = LookupErrorByName("ER_ACCOUNT_HAS_BEEN_LOCKED")
+		assert.True(t, ok)
+		assert.Equal(t, "Access denied for user 'mock'@'localhost'. Account is locked.", e.Format("mock", "localhost"))
+		e, ok = LookupError(4031)
+		assert.True(t, ok)
+		assert.Equal(t, "ER_CLIENT_INTERACTION_TIMEOUT", e.Name)
+		assert.Equal(t, uint16(4031), NewSQLError(4031, "").Num)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{"%-.192s", "%-.192s"},
+		{"%lu rows", "%d rows"},
+		{"%ld, %llu, %zu, %i", "%d, %d, %d, %d"},
+		{"%d%%", "%d%%"},
+		{"%.*s", "%.*s"},
+		{"tail %", "tail %"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, FormatTemplate(test.template))
+	}
+}
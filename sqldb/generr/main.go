@@ -0,0 +1,194 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// generr generates the error catalog of the sqldb from the share/messages_to_clients.txt of the MySQL source,
+// the errmsg-utf8.txt of the MySQL 5.7 has the same format.
+//
+//	go run ./generr -in messages_to_clients.txt -out errors_catalog.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Error is an error of the listing.
+type Error struct {
+	Num     int
+	Name    string
+	State   string
+	Message string
+}
+
+// Parse parses the errors of the listing, the messages are the ones of the default language.
+// The OBSOLETE_ errors take their codes but are not returned.
+func Parse(r io.Reader) ([]*Error, error) {
+	var errs []*Error
+	var cur *Error
+	lang := "eng"
+	next := -1
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.Fields(trimmed)
+
+		// The message lines are indented.
+		if text[0] == ' ' || text[0] == '\t' {
+			if cur == nil || fields[0] != lang || cur.Message != "" {
+				continue
+			}
+			msg, err := unquote(strings.TrimSpace(trimmed[len(fields[0]):]))
+			if err != nil {
+				return nil, fmt.Errorf("generr.line[%d].message.error:%v", line, err)
+			}
+			cur.Message = msg
+			continue
+		}
+
+		switch fields[0] {
+		case "languages":
+		case "default-language":
+			if len(fields) > 1 {
+				lang = fields[1]
+			}
+		case "start-error-number":
+			if len(fields) < 2 {
+				return nil, fmt.Errorf("generr.line[%d].start-error-number.missing", line)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("generr.line[%d].start-error-number.error:%v", line, err)
+			}
+			next = n
+		case "reserved-error-section":
+		default:
+			if next < 0 {
+				return nil, fmt.Errorf("generr.line[%d].error[%s].before.start-error-number", line, fields[0])
+			}
+			cur = &Error{Num: next, Name: fields[0], State: "HY000"}
+			if len(fields) > 1 {
+				cur.State = fields[1]
+			}
+			next++
+			if !strings.HasPrefix(cur.Name, "OBSOLETE_") {
+				errs = append(errs, cur)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
+
+// unquote unquotes the C string of the message.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("message[%s].not.quoted", s)
+	}
+	s = s[1 : len(s)-1]
+	buf := &bytes.Buffer{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			buf.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			buf.WriteByte('\n')
+		case 't':
+			buf.WriteByte('\t')
+		default:
+			buf.WriteByte(s[i])
+		}
+	}
+	return buf.String(), nil
+}
+
+// Generate writes the catalog of the errors as the source of the sqldb.
+func Generate(w io.Writer, source string, errs []*Error) error {
+	sorted := append([]*Error{}, errs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Num < sorted[j].Num })
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, `/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Code generated by generr from the %s; DO NOT EDIT.
+
+package sqldb
+
+// errorCatalog are the server errors by the codes.
+var errorCatalog = []ErrorInfo{
+`, source)
+	for _, e := range sorted {
+		if e.Num > 0xffff {
+			return fmt.Errorf("generr.error[%s].code[%d].overflow", e.Name, e.Num)
+		}
+		fmt.Fprintf(buf, "\t{Num: %d, Name: %q, State: %q, Message: %q},\n", e.Num, e.Name, e.State, e.Message)
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+func main() {
+	in := flag.String("in", "messages_to_clients.txt", "the error listing of the MySQL source")
+	out := flag.String("out", "errors_catalog.go", "the generated catalog")
+	flag.Parse()
+
+	f, err := os.Open(*in)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer f.Close()
+	errs, err := Parse(f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Generate(buf, filepath.Base(*in), errs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := ioutil.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
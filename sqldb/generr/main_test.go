@@ -0,0 +1,78 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const listing = `# The comment.
+languages eng=English, ger=German;
+default-language eng
+
+start-error-number 1000
+ER_HASHCHK
+  eng "hashchk"
+OBSOLETE_ER_NISAMCHK
+  eng "isamchk"
+ER_DUP_ENTRY 23000 S1009
+  ger "Doppelter Eintrag"
+  eng "Duplicate entry '%-.192s' for key %d"
+  eng "The second one"
+
+start-error-number 3000
+ER_QUOTED
+  eng "Say \"hi\"\n"
+`
+
+func TestParse(t *testing.T) {
+	errs, err := Parse(strings.NewReader(listing))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(errs))
+
+	assert.Equal(t, &Error{Num: 1000, Name: "ER_HASHCHK", State: "HY000", Message: "hashchk"}, errs[0])
+	assert.Equal(t, &Error{Num: 1002, Name: "ER_DUP_ENTRY", State: "23000", Message: "Duplicate entry '%-.192s' for key %d"}, errs[1])
+	assert.Equal(t, &Error{Num: 3000, Name: "ER_QUOTED", State: "HY000", Message: "Say \"hi\"\n"}, errs[2])
+}
+
+func TestParseError(t *testing.T) {
+	{
+		_, err := Parse(strings.NewReader("ER_HASHCHK\n"))
+		assert.NotNil(t, err)
+	}
+
+	{
+		_, err := Parse(strings.NewReader("start-error-number x\n"))
+		assert.NotNil(t, err)
+	}
+
+	{
+		_, err := Parse(strings.NewReader("start-error-number 1000\nER_X\n  eng unquoted\n"))
+		assert.NotNil(t, err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	errs, err := Parse(strings.NewReader(listing))
+	assert.Nil(t, err)
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, Generate(buf, "listing.txt", []*Error{errs[2], errs[0], errs[1]}))
+	src := buf.String()
+	assert.Contains(t, src, "// Code generated by generr from the listing.txt; DO NOT EDIT.")
+	assert.Contains(t, src, `{Num: 3000, Name: "ER_QUOTED", State: "HY000", Message: "Say \"hi\"\n"},`)
+	assert.True(t, strings.Index(src, "ER_HASHCHK") < strings.Index(src, "ER_QUOTED"))
+
+	assert.NotNil(t, Generate(buf, "listing.txt", []*Error{{Num: 70000, Name: "ER_BIG"}}))
+}
@@ -6,9 +6,13 @@ package sqldb
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"regexp"
 	"strconv"
+	"syscall"
 )
 
 const (
@@ -22,28 +26,46 @@ type SQLError struct {
 	State   string
 	Message string
 	Query   string
+
+	// cause is the error the SQLError wraps, see WrapSQLError.
+	cause error
 }
 
+// NewSQLError creates the SQLError of the number, the state and the message template are the ones of
+// the SQLErrors or the error catalog if the format is empty, the unknown number is the ER_UNKNOWN_ERROR.
 func NewSQLError(number uint16, format string, args ...interface{}) *SQLError {
 	sqlErr := &SQLError{}
-	err, ok := SQLErrors[number]
-	if !ok {
+	template := ""
+	if err, ok := SQLErrors[number]; ok {
+		sqlErr.Num = err.Num
+		sqlErr.State = err.State
+		template = err.Message
+	} else if info, ok := LookupError(number); ok {
+		sqlErr.Num = info.Num
+		sqlErr.State = info.State
+		template = FormatTemplate(info.Message)
+	} else {
 		unknow := SQLErrors[ER_UNKNOWN_ERROR]
 		sqlErr.Num = unknow.Num
 		sqlErr.State = unknow.State
-	} else {
-		sqlErr.Num = err.Num
-		sqlErr.State = err.State
+		template = unknow.Message
 	}
 
 	if format != "" {
 		sqlErr.Message = fmt.Sprintf(format, args...)
 	} else {
-		sqlErr.Message = fmt.Sprintf(err.Message, args...)
+		sqlErr.Message = fmt.Sprintf(template, args...)
 	}
 	return sqlErr
 }
 
+// WrapSQLError creates the SQLError like the NewSQLError, the cause is kept for the errors.Is and errors.As.
+func WrapSQLError(cause error, number uint16, format string, args ...interface{}) *SQLError {
+	sqlErr := NewSQLError(number, format, args...)
+	sqlErr.cause = cause
+	return sqlErr
+}
+
 func NewSQLError1(number uint16, state string, format string, args ...interface{}) *SQLError {
 	return &SQLError{
 		Num:     number,
@@ -69,6 +91,76 @@ func (se *SQLError) Error() string {
 	return buf.String()
 }
 
+// Unwrap returns the cause of the error.
+func (se *SQLError) Unwrap() error {
+	return se.cause
+}
+
+// Is reports whether the target is a SQLError of the same number,
+// so errors.Is(err, SQLErrors[ER_DUP_ENTRY]) matches any ER_DUP_ENTRY.
+func (se *SQLError) Is(target error) bool {
+	t, ok := target.(*SQLError)
+	return ok && t.Num == se.Num
+}
+
+// ErrorNum returns the number of the SQLError in the chain of the err.
+func ErrorNum(err error) (uint16, bool) {
+	var se *SQLError
+	if errors.As(err, &se) {
+		return se.Num, true
+	}
+	return 0, false
+}
+
+// IsErrorNum checks whether the chain of the err has a SQLError of one of the numbers.
+func IsErrorNum(err error, nums ...uint16) bool {
+	num, ok := ErrorNum(err)
+	if !ok {
+		return false
+	}
+	for _, n := range nums {
+		if num == n {
+			return true
+		}
+	}
+	return false
+}
+
+// IsConnErr checks whether the err is a connection error, the client errors of the codes below
+// the CR_COMMANDS_OUT_OF_SYNC, the CR_SERVER_LOST or the network errors. The connection is unusable after it.
+func IsConnErr(err error) bool {
+	if num, ok := ErrorNum(err); ok {
+		return (num >= CR_UNKNOWN_ERROR && num < CR_COMMANDS_OUT_OF_SYNC) || num == CR_SERVER_LOST
+	}
+	var netErr net.Error
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.As(err, &netErr)
+}
+
+// IsClientError checks whether the err is a client error(the CR_ codes), the client failed not the server.
+func IsClientError(err error) bool {
+	num, ok := ErrorNum(err)
+	return ok && num >= CR_ERROR_FIRST && num <= CR_ERROR_LAST
+}
+
+// IsDupEntry checks whether the err is a duplicate key error.
+func IsDupEntry(err error) bool {
+	return IsErrorNum(err, ER_DUP_ENTRY, ER_DUP_KEY, ER_DUP_UNIQUE)
+}
+
+// IsPasswordExpired checks whether the err is rejected by the expired password, in the sandbox mode or at login.
+func IsPasswordExpired(err error) bool {
+	return IsErrorNum(err, ER_MUST_CHANGE_PASSWORD, ER_MUST_CHANGE_PASSWORD_LOGIN)
+}
+
+// IsReadOnly checks whether the err is rejected by the read_only, super_read_only or a READ ONLY transaction.
+func IsReadOnly(err error) bool {
+	return IsErrorNum(err, ER_OPTION_PREVENTS_STATEMENT, ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION, ER_READ_ONLY_MODE)
+}
+
 var errExtract = regexp.MustCompile(`.*\(errno ([0-9]*)\) \(sqlstate ([0-9a-zA-Z]{5})\).*`)
 
 // NewSQLErrorFromError returns a *SQLError from the provided error.
@@ -78,7 +170,8 @@ func NewSQLErrorFromError(err error) error {
 		return nil
 	}
 
-	if serr, ok := err.(*SQLError); ok {
+	var serr *SQLError
+	if errors.As(err, &serr) {
 		return serr
 	}
 
@@ -96,23 +189,26 @@ func NewSQLErrorFromError(err error) error {
 			Num:     unknow.Num,
 			State:   unknow.State,
 			Message: msg,
+			cause:   err,
 		}
 	}
 
-	num, err := strconv.Atoi(match[1])
-	if err != nil {
+	num, perr := strconv.Atoi(match[1])
+	if perr != nil {
 		unknow := SQLErrors[ER_UNKNOWN_ERROR]
 		return &SQLError{
 			Num:     unknow.Num,
 			State:   unknow.State,
 			Message: msg,
+			cause:   err,
 		}
 	}
 
-	serr := &SQLError{
+	serr = &SQLError{
 		Num:     uint16(num),
 		State:   match[2],
 		Message: msg,
+		cause:   err,
 	}
 	return serr
 }
@@ -13,6 +13,11 @@ import (
 	"testing"
 
 	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -48,7 +53,7 @@ func TestSqlErrorFromErr(t *testing.T) {
 
 	{
 		err := errors.New("No database selected (errno 1046) (sqlstate 3D000)")
-		want := &SQLError{Num: 1046, State: "3D000", Message: "No database selected (errno 1046) (sqlstate 3D000)"}
+		want := &SQLError{Num: 1046, State: "3D000", Message: "No database selected (errno 1046) (sqlstate 3D000)", cause: err}
 		got := NewSQLErrorFromError(err)
 		assert.Equal(t, want, got)
 	}
@@ -60,3 +65,70 @@ func TestSqlErrorFromErr(t *testing.T) {
 		assert.Equal(t, want, got)
 	}
 }
+
+func TestSqlErrorWrap(t *testing.T) {
+	cause := errors.New("disk.full")
+	sqlerr := WrapSQLError(cause, ER_OUT_OF_RESOURCES, "insert.failed")
+	assert.Equal(t, "insert.failed (errno 1041) (sqlstate HY000)", sqlerr.Error())
+	assert.True(t, errors.Is(sqlerr, cause))
+
+	// Wrapped by the caller.
+	err := fmt.Errorf("bulk.insert:%w", sqlerr)
+	var got *SQLError
+	assert.True(t, errors.As(err, &got))
+	assert.Equal(t, sqlerr, got)
+	assert.True(t, errors.Is(err, SQLErrors[ER_OUT_OF_RESOURCES]))
+	assert.False(t, errors.Is(err, SQLErrors[ER_DUP_ENTRY]))
+	assert.Equal(t, sqlerr, NewSQLErrorFromError(err))
+
+	// The string error is the cause of the extracted one.
+	serr := errors.New("No database selected (errno 1046) (sqlstate 3D000)")
+	assert.True(t, errors.Is(NewSQLErrorFromError(serr), serr))
+
+	num, ok := ErrorNum(err)
+	assert.True(t, ok)
+	assert.Equal(t, uint16(ER_OUT_OF_RESOURCES), num)
+	_, ok = ErrorNum(cause)
+	assert.False(t, ok)
+}
+
+func TestSqlErrorPredicates(t *testing.T) {
+	tests := []struct {
+		err      error
+		conn     bool
+		dup      bool
+		readOnly bool
+		expired  bool
+	}{
+		{err: NewSQLError(CR_SERVER_LOST, "lost"), conn: true},
+		{err: NewSQLError(CR_SERVER_GONE_ERROR, ""), conn: true},
+		{err: NewSQLError(CR_CONN_HOST_ERROR, "connect.refused"), conn: true},
+		{err: NewSQLError(CR_COMMANDS_OUT_OF_SYNC, "")},
+		{err: io.EOF, conn: true},
+		{err: fmt.Errorf("read:%w", io.ErrUnexpectedEOF), conn: true},
+		{err: &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, conn: true},
+		{err: NewSQLError(ER_DUP_ENTRY, "dup.entry[%s]", "1"), dup: true},
+		{err: fmt.Errorf("exec:%w", NewSQLError1(ER_DUP_KEY, "23000", "dup")), dup: true},
+		{err: NewSQLError(ER_OPTION_PREVENTS_STATEMENT, "read.only"), readOnly: true},
+		{err: NewSQLError(ER_CANT_EXECUTE_IN_READ_ONLY_TRANSACTION, ""), readOnly: true},
+		{err: NewSQLError(ER_READ_ONLY_MODE, ""), readOnly: true},
+		{err: NewSQLError(ER_MUST_CHANGE_PASSWORD, ""), expired: true},
+		{err: fmt.Errorf("connect:%w", NewSQLError(ER_MUST_CHANGE_PASSWORD_LOGIN, "")), expired: true},
+		{err: NewSQLError(ER_NO_SUCH_TABLE, "no.such.table")},
+		{err: errors.New("errorman")},
+		{err: nil},
+	}
+	for _, test := range tests {
+		assert.Equalf(t, test.conn, IsConnErr(test.err), "%v", test.err)
+		assert.Equalf(t, test.dup, IsDupEntry(test.err), "%v", test.err)
+		assert.Equalf(t, test.readOnly, IsReadOnly(test.err), "%v", test.err)
+		assert.Equalf(t, test.expired, IsPasswordExpired(test.err), "%v", test.err)
+	}
+}
+
+func TestSqlErrorClient(t *testing.T) {
+	assert.True(t, IsClientError(NewSQLError(CR_MALFORMED_PACKET, "")))
+	assert.True(t, IsClientError(fmt.Errorf("query:%w", WrapSQLError(io.EOF, CR_SERVER_LOST, "lost"))))
+	assert.False(t, IsClientError(NewSQLError(ER_MALFORMED_PACKET, "malformed")))
+	assert.False(t, IsClientError(io.EOF))
+}
@@ -42,6 +42,7 @@ const (
 	StmtSet
 	StmtShow
 	StmtUse
+	StmtKill
 	StmtOther
 	StmtUnknown
 )
@@ -87,6 +88,8 @@ func Preview(sql string) int {
 		return StmtShow
 	case "use":
 		return StmtUse
+	case "kill":
+		return StmtKill
 	case "analyze", "describe", "desc", "explain", "repair", "optimize", "truncate":
 		return StmtOther
 	}
@@ -55,6 +55,8 @@ func TestPreview(t *testing.T) {
 		{"set", StmtSet},
 		{"show", StmtShow},
 		{"use", StmtUse},
+		{"kill", StmtKill},
+		{"KILL QUERY 1", StmtKill},
 		{"analyze", StmtOther},
 		{"describe", StmtOther},
 		{"desc", StmtOther},
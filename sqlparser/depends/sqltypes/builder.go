@@ -0,0 +1,319 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// integralBits are the bits of the integral types narrower than 64.
+var integralBits = map[querypb.Type]int{
+	Int8:   8,
+	Uint8:  8,
+	Int16:  16,
+	Uint16: 16,
+	Int24:  24,
+	Uint24: 24,
+	Int32:  32,
+	Uint32: 32,
+	Year:   16,
+}
+
+// ResultBuilder builds the Result of the fields from the native Go values,
+// the values are converted to the types of the fields and checked as the rows are appended.
+type ResultBuilder struct {
+	result *Result
+	loc    *time.Location
+}
+
+// NewResultBuilder creates the builder of the fields.
+func NewResultBuilder(fields ...*querypb.Field) *ResultBuilder {
+	return &ResultBuilder{result: &Result{Fields: fields}}
+}
+
+// SetLocation sets the zone the time.Time values are formatted in, nil keeps their own zones.
+func (b *ResultBuilder) SetLocation(loc *time.Location) *ResultBuilder {
+	b.loc = loc
+	return b
+}
+
+// AppendRow converts the values to the types of the fields and appends the row,
+// the count of the values must be the count of the fields.
+func (b *ResultBuilder) AppendRow(values ...interface{}) error {
+	fields := b.result.Fields
+	if len(values) != len(fields) {
+		return fmt.Errorf("sqltypes.builder.row.values[%d].fields[%d].mismatch", len(values), len(fields))
+	}
+	row := make([]Value, len(values))
+	for i, goval := range values {
+		v, err := b.convert(fields[i], goval)
+		if err != nil {
+			return err
+		}
+		row[i] = v
+	}
+	b.result.Rows = append(b.result.Rows, row)
+	return nil
+}
+
+// NewRow returns the builder of a row appended by its Finish.
+func (b *ResultBuilder) NewRow() *RowBuilder {
+	return &RowBuilder{builder: b, row: make([]Value, 0, len(b.result.Fields))}
+}
+
+// Result returns the result built, the RowsAffected is the count of the rows.
+func (b *ResultBuilder) Result() *Result {
+	b.result.RowsAffected = uint64(len(b.result.Rows))
+	return b.result
+}
+
+// convert converts the goval to the value of the field.
+func (b *ResultBuilder) convert(field *querypb.Field, goval interface{}) (Value, error) {
+	v, err := ConvertValue(field, goval, b.loc)
+	if err != nil {
+		return NULL, fmt.Errorf("sqltypes.builder.field[%s]:%v", field.Name, err)
+	}
+	return v, nil
+}
+
+// RowBuilder builds a row value by value, the first error is kept and returned by the Finish.
+type RowBuilder struct {
+	builder *ResultBuilder
+	row     []Value
+	err     error
+}
+
+// Add converts the goval to the type of the next field.
+func (r *RowBuilder) Add(goval interface{}) *RowBuilder {
+	if r.err != nil {
+		return r
+	}
+	fields := r.builder.result.Fields
+	if len(r.row) >= len(fields) {
+		r.err = fmt.Errorf("sqltypes.builder.row.values[%d].fields[%d].mismatch", len(r.row)+1, len(fields))
+		return r
+	}
+	v, err := r.builder.convert(fields[len(r.row)], goval)
+	if err != nil {
+		r.err = err
+		return r
+	}
+	r.row = append(r.row, v)
+	return r
+}
+
+// Finish appends the row to the result, it fails if a value failed or the row isn't complete.
+func (r *RowBuilder) Finish() error {
+	if r.err != nil {
+		return r.err
+	}
+	if fields := r.builder.result.Fields; len(r.row) != len(fields) {
+		return fmt.Errorf("sqltypes.builder.row.values[%d].fields[%d].mismatch", len(r.row), len(fields))
+	}
+	r.builder.result.Rows = append(r.builder.result.Rows, r.row)
+	return nil
+}
+
+// ConvertValue converts the native Go value to the value of the field type:
+//   - nil is the NULL, it fails if the field is NOT NULL.
+//   - the integers, the floats, the *big.Int, *big.Rat and *big.Float to the numeric types in range,
+//     the decimals of the DECIMAL and the floats keep the Decimals of the field.
+//   - the string and the []byte to any type, the numbers are checked.
+//   - the time.Time to the DATE, DATETIME and TIMESTAMP in the loc and the time.Duration to the TIME.
+//   - the JSON marshals the values other than the string and the []byte.
+//   - the Value of the field type as it is.
+func ConvertValue(field *querypb.Field, goval interface{}, loc *time.Location) (Value, error) {
+	typ := field.Type
+	switch goval := goval.(type) {
+	case nil:
+		if field.Flags&uint32(querypb.MySqlFlag_NOT_NULL_FLAG) != 0 {
+			return NULL, fmt.Errorf("sqltypes.convert.null.to.not.null")
+		}
+		return NULL, nil
+	case Value:
+		if goval.IsNull() || goval.Type() == typ {
+			return convertNull(field, goval)
+		}
+		return ValueFromBytes(typ, goval.Raw())
+	case string:
+		return ValueFromBytes(typ, []byte(goval))
+	case []byte:
+		return ValueFromBytes(typ, goval)
+	}
+
+	switch {
+	case IsIntegral(typ):
+		return convertIntegral(typ, goval)
+	case IsFloat(typ), typ == Decimal:
+		return convertNumber(field, goval)
+	}
+	switch typ {
+	case Date, Datetime, Timestamp:
+		if t, ok := goval.(time.Time); ok {
+			if loc == nil {
+				loc = t.Location()
+			}
+			return NewTemporal(typ, t, loc, int(field.Decimals))
+		}
+	case Time:
+		if d, ok := goval.(time.Duration); ok {
+			return MakeTrusted(Time, []byte(formatDuration(d, int(field.Decimals)))), nil
+		}
+	case TypeJSON:
+		data, err := json.Marshal(goval)
+		if err != nil {
+			return NULL, err
+		}
+		return MakeTrusted(TypeJSON, data), nil
+	}
+	return NULL, fmt.Errorf("sqltypes.convert.type[%T].to[%v].unsupported", goval, typ)
+}
+
+func convertNull(field *querypb.Field, v Value) (Value, error) {
+	if v.IsNull() {
+		return ConvertValue(field, nil, nil)
+	}
+	return v, nil
+}
+
+// convertIntegral converts the Go integral to the integral type, it fails if it's out of the range.
+func convertIntegral(typ querypb.Type, goval interface{}) (Value, error) {
+	var signed int64
+	var unsigned uint64
+	negative := false
+	switch goval := goval.(type) {
+	case int:
+		signed = int64(goval)
+	case int8:
+		signed = int64(goval)
+	case int16:
+		signed = int64(goval)
+	case int32:
+		signed = int64(goval)
+	case int64:
+		signed = goval
+	case uint:
+		unsigned = uint64(goval)
+	case uint8:
+		unsigned = uint64(goval)
+	case uint16:
+		unsigned = uint64(goval)
+	case uint32:
+		unsigned = uint64(goval)
+	case uint64:
+		unsigned = goval
+	case bool:
+		if goval {
+			unsigned = 1
+		}
+	case *big.Int:
+		switch {
+		case goval.IsInt64():
+			signed = goval.Int64()
+		case goval.IsUint64():
+			unsigned = goval.Uint64()
+		default:
+			return NULL, fmt.Errorf("sqltypes.convert.value[%v].out.of.range[%v]", goval, typ)
+		}
+	default:
+		return NULL, fmt.Errorf("sqltypes.convert.type[%T].to[%v].unsupported", goval, typ)
+	}
+	if signed < 0 {
+		negative = true
+	} else if signed > 0 {
+		unsigned = uint64(signed)
+	}
+
+	bits, ok := integralBits[typ]
+	if !ok {
+		bits = 64
+	}
+	if IsUnsigned(typ) {
+		if negative || (bits < 64 && unsigned >= 1<<uint(bits)) {
+			return NULL, fmt.Errorf("sqltypes.convert.value[%v].out.of.range[%v]", goval, typ)
+		}
+		return MakeTrusted(typ, strconv.AppendUint(nil, unsigned, 10)), nil
+	}
+	if negative {
+		if bits < 64 && signed < -(1<<uint(bits-1)) {
+			return NULL, fmt.Errorf("sqltypes.convert.value[%v].out.of.range[%v]", goval, typ)
+		}
+		return MakeTrusted(typ, strconv.AppendInt(nil, signed, 10)), nil
+	}
+	if unsigned > 1<<uint(bits-1)-1 {
+		return NULL, fmt.Errorf("sqltypes.convert.value[%v].out.of.range[%v]", goval, typ)
+	}
+	return MakeTrusted(typ, strconv.AppendUint(nil, unsigned, 10)), nil
+}
+
+// convertNumber converts the Go number to the float or the DECIMAL, the Decimals of the field is the scale.
+func convertNumber(field *querypb.Field, goval interface{}) (Value, error) {
+	typ := field.Type
+	scale := -1
+	if typ == Decimal || field.Decimals < 31 {
+		scale = int(field.Decimals)
+	}
+	var s string
+	switch goval := goval.(type) {
+	case float64:
+		s = strconv.FormatFloat(goval, 'f', scale, 64)
+	case float32:
+		s = strconv.FormatFloat(float64(goval), 'f', scale, 32)
+	case *big.Float:
+		if scale < 0 {
+			s = goval.Text('f', -1)
+		} else {
+			s = goval.Text('f', scale)
+		}
+	case *big.Rat:
+		if scale < 0 {
+			scale = 6
+		}
+		s = goval.FloatString(scale)
+	case *big.Int:
+		s = goval.String()
+	default:
+		v, err := convertIntegral(Int64, goval)
+		if err != nil {
+			if v, err = convertIntegral(Uint64, goval); err != nil {
+				return NULL, fmt.Errorf("sqltypes.convert.type[%T].to[%v].unsupported", goval, typ)
+			}
+		}
+		s = v.String()
+	}
+	return MakeTrusted(typ, []byte(s)), nil
+}
+
+// formatDuration formats the duration as the TIME like '-838:59:59.000000' with the fsp fractional digits.
+func formatDuration(d time.Duration, fsp int) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	hours := d / time.Hour
+	minutes := (d % time.Hour) / time.Minute
+	seconds := (d % time.Minute) / time.Second
+	s := fmt.Sprintf("%s%02d:%02d:%02d", sign, hours, minutes, seconds)
+	if fsp > 0 {
+		if fsp > 6 {
+			fsp = 6
+		}
+		frac := fmt.Sprintf("%06d", (d%time.Second)/time.Microsecond)
+		s += "." + frac[:fsp]
+	}
+	return s
+}
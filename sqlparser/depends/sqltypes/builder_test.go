@@ -0,0 +1,87 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestResultBuilder(t *testing.T) {
+	id := NewInt64Field("id")
+	id.Flags |= uint32(querypb.MySqlFlag_NOT_NULL_FLAG)
+	b := NewResultBuilder(id, NewVarCharField("name", 0, 32), NewDecimalField("price", 10, 2), NewDatetimeField("created", 0), NewBlobField("data"))
+	b.SetLocation(time.UTC)
+	created := time.Date(2018, 1, 2, 3, 4, 5, 0, time.FixedZone("CST", 8*3600))
+
+	assert.Nil(t, b.AppendRow(1, "apple", big.NewRat(314, 100), created, []byte{0x01}))
+	assert.Nil(t, b.AppendRow(int32(2), []byte("pear"), "2.50", nil, nil))
+	assert.Nil(t, b.NewRow().Add(uint8(3)).Add(NewVarChar("plum")).Add(3.14159).Add("2018-01-01 00:00:00").Add(nil).Finish())
+
+	qr := b.Result()
+	assert.Equal(t, uint64(3), qr.RowsAffected)
+	want := [][]Value{
+		{NewInt64(1), NewVarChar("apple"), MakeTrusted(Decimal, []byte("3.14")), MakeTrusted(Datetime, []byte("2018-01-01 19:04:05")), MakeTrusted(Blob, []byte{0x01})},
+		{NewInt64(2), NewVarChar("pear"), MakeTrusted(Decimal, []byte("2.50")), NULL, NULL},
+		{NewInt64(3), NewVarChar("plum"), MakeTrusted(Decimal, []byte("3.14")), MakeTrusted(Datetime, []byte("2018-01-01 00:00:00")), NULL},
+	}
+	assert.Equal(t, want, qr.Rows)
+
+	// The arity and the types are checked.
+	assert.EqualError(t, b.AppendRow(1, "a"), "sqltypes.builder.row.values[2].fields[5].mismatch")
+	assert.EqualError(t, b.AppendRow(nil, "a", 1, nil, nil), "sqltypes.builder.field[id]:sqltypes.convert.null.to.not.null")
+	assert.EqualError(t, b.AppendRow(1, 2, 1, nil, nil), "sqltypes.builder.field[name]:sqltypes.convert.type[int].to[VARCHAR].unsupported")
+	assert.NotNil(t, b.AppendRow("x", "a", 1, nil, nil))
+	assert.EqualError(t, b.NewRow().Add(1).Finish(), "sqltypes.builder.row.values[1].fields[5].mismatch")
+	assert.EqualError(t, b.NewRow().Add(1).Add("a").Add(1).Add(nil).Add(nil).Add(nil).Finish(), "sqltypes.builder.row.values[6].fields[5].mismatch")
+	assert.Equal(t, 3, len(b.Result().Rows))
+}
+
+func TestConvertValue(t *testing.T) {
+	tests := []struct {
+		field *querypb.Field
+		goval interface{}
+		want  Value
+		err   string
+	}{
+		{NewInt32Field("a"), int64(-2147483648), NewInt32(-2147483648), ""},
+		{NewInt32Field("a"), int64(2147483648), NULL, "sqltypes.convert.value[2147483648].out.of.range[INT32]"},
+		{NewUint32Field("a"), -1, NULL, "sqltypes.convert.value[-1].out.of.range[UINT32]"},
+		{NewUint64Field("a"), uint64(18446744073709551615), NewUint64(18446744073709551615), ""},
+		{NewInt64Field("a"), uint64(18446744073709551615), NULL, "sqltypes.convert.value[18446744073709551615].out.of.range[INT64]"},
+		{NewInt64Field("a"), true, NewInt64(1), ""},
+		{NewInt64Field("a"), new(big.Int).SetInt64(-7), NewInt64(-7), ""},
+		{NewFloat64Field("a"), 1.5, NewFloat64(1.5), ""},
+		{NewFloat64Field("a"), 2, MakeTrusted(Float64, []byte("2")), ""},
+		{NewDecimalField("a", 20, 3), big.NewFloat(1.25), MakeTrusted(Decimal, []byte("1.250")), ""},
+		{NewDecimalField("a", 30, 0), new(big.Int).Lsh(big.NewInt(1), 70), MakeTrusted(Decimal, []byte("1180591620717411303424")), ""},
+		{NewDateField("a"), time.Date(2018, 1, 2, 3, 4, 5, 0, time.UTC), MakeTrusted(Date, []byte("2018-01-02")), ""},
+		{NewTimestampField("a", 3), time.Date(2018, 1, 2, 3, 4, 5, 120000000, time.UTC), MakeTrusted(Timestamp, []byte("2018-01-02 03:04:05.120")), ""},
+		{NewTimeField("a", 0), -(25*time.Hour + 2*time.Second), MakeTrusted(Time, []byte("-25:00:02")), ""},
+		{NewTimeField("a", 2), 1500 * time.Millisecond, MakeTrusted(Time, []byte("00:00:01.50")), ""},
+		{NewJSONField("a"), map[string]int{"a": 1}, MakeTrusted(TypeJSON, []byte(`{"a":1}`)), ""},
+		{NewJSONField("a"), `[1]`, MakeTrusted(TypeJSON, []byte(`[1]`)), ""},
+		{NewVarCharField("a", 0, 8), time.Time{}, NULL, "sqltypes.convert.type[time.Time].to[VARCHAR].unsupported"},
+	}
+	for _, test := range tests {
+		v, err := ConvertValue(test.field, test.goval, nil)
+		if test.err != "" {
+			assert.EqualError(t, err, test.err)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equalf(t, test.want, v, "%v", test.goval)
+	}
+}
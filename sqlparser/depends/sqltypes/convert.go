@@ -0,0 +1,286 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The errors of the conversions, they're wrapped with the value and checked by the errors.Is.
+var (
+	// ErrNull is returned by the conversions of the NULL, the result is the zero one.
+	ErrNull = errors.New("sqltypes.value.is.null")
+
+	// ErrTruncated is returned if only the leading number of the string is converted like the
+	// 'Truncated incorrect value' warning of MySQL, the result is the one of the leading number, 0 if none.
+	ErrTruncated = errors.New("sqltypes.value.truncated")
+
+	// ErrOutOfRange is returned if the value doesn't fit, the result is clipped to the range like the
+	// non-strict sql_mode of MySQL.
+	ErrOutOfRange = errors.New("sqltypes.value.out.of.range")
+)
+
+func convertError(v Value, to string, err error) error {
+	return fmt.Errorf("sqltypes.value[%v:%s].to.%s:%w", v.typ, v.val, to, err)
+}
+
+// isString checks whether the value is converted as a string, like the text, the binaries and the enums.
+func (v Value) isString() bool {
+	return v.IsText() || v.IsBinary() || v.typ == Enum || v.typ == Set || v.typ == TypeJSON
+}
+
+// isTemporal checks whether the value is a DATE, TIME, DATETIME or TIMESTAMP.
+func (v Value) isTemporal() bool {
+	switch v.typ {
+	case Date, Time, Datetime, Timestamp:
+		return true
+	}
+	return false
+}
+
+// number returns the text of the number the value converts to and whether it's only a leading part of the value.
+// The strings are converted by their leading numbers and the temporals like MySQL, '2018-01-02 03:04:05' is 20180102030405.
+func (v Value) number(float bool) (string, bool, error) {
+	s := string(v.val)
+	switch {
+	case v.typ == Null:
+		return "", false, ErrNull
+	case v.isTemporal():
+		var b strings.Builder
+		for i, c := range s {
+			switch {
+			case c >= '0' && c <= '9', c == '.':
+				b.WriteRune(c)
+			case c == '-' && i == 0 && v.typ == Time:
+				b.WriteRune(c)
+			}
+		}
+		return b.String(), false, nil
+	case v.isString():
+		prefix, rest := numberPrefix(s, float)
+		if prefix == "" {
+			return "0", true, nil
+		}
+		return prefix, strings.TrimSpace(rest) != "", nil
+	}
+	return s, false, nil
+}
+
+// numberPrefix splits the leading number of the s after the spaces, it's an integer unless the float.
+func numberPrefix(s string, float bool) (string, string) {
+	s = strings.TrimLeft(s, " \t\r\n\f\v")
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := 0
+	for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+		digits++
+	}
+	if float {
+		if i < len(s) && s[i] == '.' {
+			i++
+			for ; i < len(s) && s[i] >= '0' && s[i] <= '9'; i++ {
+				digits++
+			}
+		}
+		if digits > 0 && i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+			j := i + 1
+			if j < len(s) && (s[j] == '+' || s[j] == '-') {
+				j++
+			}
+			if j < len(s) && s[j] >= '0' && s[j] <= '9' {
+				for j < len(s) && s[j] >= '0' && s[j] <= '9' {
+					j++
+				}
+				i = j
+			}
+		}
+	}
+	if digits == 0 {
+		return "", s
+	}
+	return s[:i], s[i:]
+}
+
+// bits returns the unsigned integer of the BIT value, the bytes are big endian.
+func (v Value) bits() (uint64, error) {
+	if len(v.val) > 8 {
+		return math.MaxUint64, convertError(v, "uint64", ErrOutOfRange)
+	}
+	var n uint64
+	for _, c := range v.val {
+		n = n<<8 | uint64(c)
+	}
+	return n, nil
+}
+
+// roundDecimal rounds the decimal text to the integer text half away from zero like MySQL, 2.5 is 3 and -2.5 is -3.
+func roundDecimal(s string) string {
+	dot := strings.IndexByte(s, '.')
+	if dot < 0 {
+		return s
+	}
+	integer, fraction := s[:dot], s[dot+1:]
+	if integer == "" || integer == "-" || integer == "+" {
+		integer += "0"
+	}
+	if fraction == "" || fraction[0] < '5' {
+		return integer
+	}
+	// Add one to the magnitude of the integer part.
+	digits := []byte(integer)
+	i := len(digits) - 1
+	for ; i >= 0 && digits[i] >= '0' && digits[i] <= '9'; i-- {
+		if digits[i] < '9' {
+			digits[i]++
+			return string(digits)
+		}
+		digits[i] = '0'
+	}
+	return string(digits[:i+1]) + "1" + string(digits[i+1:])
+}
+
+// ToInt64 converts the value to the int64 like the CAST(v AS SIGNED) of MySQL.
+// The floats and the decimals are rounded, the strings converted by their leading integers return the ErrTruncated,
+// the values out of the range are clipped and return the ErrOutOfRange, the NULL returns the ErrNull.
+func (v Value) ToInt64() (int64, error) {
+	if v.typ == Bit {
+		n, err := v.bits()
+		if err == nil && n > math.MaxInt64 {
+			err = convertError(v, "int64", ErrOutOfRange)
+		}
+		if err != nil {
+			return math.MaxInt64, err
+		}
+		return int64(n), nil
+	}
+	if v.IsFloat() {
+		f, err := v.ToFloat64()
+		if err != nil {
+			return 0, err
+		}
+		f = math.Round(f)
+		switch {
+		case f >= math.MaxInt64:
+			return math.MaxInt64, convertError(v, "int64", ErrOutOfRange)
+		case f < math.MinInt64:
+			return math.MinInt64, convertError(v, "int64", ErrOutOfRange)
+		}
+		return int64(f), nil
+	}
+
+	s, truncated, err := v.number(false)
+	if err != nil {
+		return 0, convertError(v, "int64", err)
+	}
+	s = roundDecimal(s)
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
+			return n, convertError(v, "int64", ErrOutOfRange)
+		}
+		return 0, convertError(v, "int64", ErrTruncated)
+	}
+	if truncated {
+		return n, convertError(v, "int64", ErrTruncated)
+	}
+	return n, nil
+}
+
+// ToUint64 converts the value to the uint64 like the ToInt64, the negatives are clipped to 0 and return the ErrOutOfRange.
+func (v Value) ToUint64() (uint64, error) {
+	if v.typ == Bit {
+		return v.bits()
+	}
+	if v.IsFloat() {
+		f, err := v.ToFloat64()
+		if err != nil {
+			return 0, err
+		}
+		f = math.Round(f)
+		switch {
+		case f >= math.MaxUint64:
+			return math.MaxUint64, convertError(v, "uint64", ErrOutOfRange)
+		case f < 0:
+			return 0, convertError(v, "uint64", ErrOutOfRange)
+		}
+		return uint64(f), nil
+	}
+
+	s, truncated, err := v.number(false)
+	if err != nil {
+		return 0, convertError(v, "uint64", err)
+	}
+	s = roundDecimal(s)
+	if strings.HasPrefix(s, "-") {
+		if strings.Trim(s, "-0") == "" {
+			return 0, nil
+		}
+		return 0, convertError(v, "uint64", ErrOutOfRange)
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, 64)
+	if err != nil {
+		if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
+			return n, convertError(v, "uint64", ErrOutOfRange)
+		}
+		return 0, convertError(v, "uint64", ErrTruncated)
+	}
+	if truncated {
+		return n, convertError(v, "uint64", ErrTruncated)
+	}
+	return n, nil
+}
+
+// ToFloat64 converts the value to the float64 like the numeric context of MySQL, the strings are converted
+// by their leading numbers like the ToInt64 and the values out of the range are the infinities.
+func (v Value) ToFloat64() (float64, error) {
+	if v.typ == Bit {
+		n, err := v.bits()
+		return float64(n), err
+	}
+	s, truncated, err := v.number(true)
+	if err != nil {
+		return 0, convertError(v, "float64", err)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		if nerr, ok := err.(*strconv.NumError); ok && nerr.Err == strconv.ErrRange {
+			return f, convertError(v, "float64", ErrOutOfRange)
+		}
+		return 0, convertError(v, "float64", ErrTruncated)
+	}
+	if truncated {
+		return f, convertError(v, "float64", ErrTruncated)
+	}
+	return f, nil
+}
+
+// ToBool converts the value to the truth of MySQL, the value is true if its number isn't 0.
+// The strings are true by their leading numbers, 'true' is false and returns the ErrTruncated like MySQL.
+func (v Value) ToBool() (bool, error) {
+	switch {
+	case v.IsSigned():
+		n, err := v.ToInt64()
+		return n != 0, err
+	case v.IsUnsigned(), v.typ == Bit:
+		n, err := v.ToUint64()
+		return n != 0, err
+	}
+	f, err := v.ToFloat64()
+	if errors.Is(err, ErrOutOfRange) {
+		return true, err
+	}
+	return f != 0, err
+}
@@ -0,0 +1,146 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestToInt64(t *testing.T) {
+	testcases := []struct {
+		in   Value
+		want int64
+		err  error
+	}{
+		{NewInt64(-12), -12, nil},
+		{NewUint64(12), 12, nil},
+		{NewUint64(math.MaxUint64), math.MaxInt64, ErrOutOfRange},
+		{NewFloat64(2.5), 3, nil},
+		{NewFloat64(-2.5), -3, nil},
+		{NewFloat64(1e30), math.MaxInt64, ErrOutOfRange},
+		{testVal(Decimal, "12.49"), 12, nil},
+		{testVal(Decimal, "-9.5"), -10, nil},
+		{testVal(Decimal, "99.99"), 100, nil},
+		{NewVarChar("  42  "), 42, nil},
+		{NewVarChar("12abc"), 12, ErrTruncated},
+		{NewVarChar("1.9"), 1, ErrTruncated},
+		{NewVarChar("-7e3"), -7, ErrTruncated},
+		{NewVarChar("abc"), 0, ErrTruncated},
+		{NewVarChar(""), 0, ErrTruncated},
+		{NewVarChar("99999999999999999999"), math.MaxInt64, ErrOutOfRange},
+		{NewVarChar("-99999999999999999999"), math.MinInt64, ErrOutOfRange},
+		{testVal(Bit, "\x01\x00"), 256, nil},
+		{testVal(Date, "2018-01-02"), 20180102, nil},
+		{testVal(Datetime, "2018-01-02 03:04:05"), 20180102030405, nil},
+		{testVal(Time, "-01:02:03"), -10203, nil},
+		{NULL, 0, ErrNull},
+	}
+	for _, tcase := range testcases {
+		got, err := tcase.in.ToInt64()
+		if !errors.Is(err, tcase.err) {
+			t.Errorf("%v.ToInt64() error: %v, want %v", tcase.in, err, tcase.err)
+		}
+		if got != tcase.want {
+			t.Errorf("%v.ToInt64(): %d, want %d", tcase.in, got, tcase.want)
+		}
+	}
+}
+
+func TestToUint64(t *testing.T) {
+	testcases := []struct {
+		in   Value
+		want uint64
+		err  error
+	}{
+		{NewUint64(math.MaxUint64), math.MaxUint64, nil},
+		{NewInt64(-1), 0, ErrOutOfRange},
+		{NewInt64(12), 12, nil},
+		{NewFloat64(-0.4), 0, nil},
+		{NewFloat64(-1), 0, ErrOutOfRange},
+		{testVal(Decimal, "-0.4"), 0, nil},
+		{testVal(Decimal, "7.5"), 8, nil},
+		{NewVarChar("+15 apples"), 15, ErrTruncated},
+		{NewVarChar("-3"), 0, ErrOutOfRange},
+		{NewVarChar("99999999999999999999"), math.MaxUint64, ErrOutOfRange},
+		{testVal(Bit, "\xff\xff\xff\xff\xff\xff\xff\xff"), math.MaxUint64, nil},
+		{testVal(Bit, "\x01\x00\x00\x00\x00\x00\x00\x00\x00"), math.MaxUint64, ErrOutOfRange},
+		{NULL, 0, ErrNull},
+	}
+	for _, tcase := range testcases {
+		got, err := tcase.in.ToUint64()
+		if !errors.Is(err, tcase.err) {
+			t.Errorf("%v.ToUint64() error: %v, want %v", tcase.in, err, tcase.err)
+		}
+		if got != tcase.want {
+			t.Errorf("%v.ToUint64(): %d, want %d", tcase.in, got, tcase.want)
+		}
+	}
+}
+
+func TestToFloat64(t *testing.T) {
+	testcases := []struct {
+		in   Value
+		want float64
+		err  error
+	}{
+		{NewFloat64(1.25), 1.25, nil},
+		{NewInt64(-3), -3, nil},
+		{testVal(Decimal, "12.5"), 12.5, nil},
+		{NewVarChar(" 1.5e3xyz"), 1500, ErrTruncated},
+		{NewVarChar(".5"), 0.5, nil},
+		{NewVarChar("-2."), -2, nil},
+		{NewVarChar("3e"), 3, ErrTruncated},
+		{NewVarChar("e3"), 0, ErrTruncated},
+		{NewVarChar("1e400"), math.Inf(1), ErrOutOfRange},
+		{testVal(Bit, "\x02"), 2, nil},
+		{testVal(Datetime, "2018-01-02 03:04:05.5"), 20180102030405.5, nil},
+		{NULL, 0, ErrNull},
+	}
+	for _, tcase := range testcases {
+		got, err := tcase.in.ToFloat64()
+		if !errors.Is(err, tcase.err) {
+			t.Errorf("%v.ToFloat64() error: %v, want %v", tcase.in, err, tcase.err)
+		}
+		if got != tcase.want {
+			t.Errorf("%v.ToFloat64(): %v, want %v", tcase.in, got, tcase.want)
+		}
+	}
+}
+
+func TestToBool(t *testing.T) {
+	testcases := []struct {
+		in   Value
+		want bool
+		err  error
+	}{
+		{NewInt64(1), true, nil},
+		{NewInt64(0), false, nil},
+		{NewUint64(math.MaxUint64), true, nil},
+		{NewFloat64(0.1), true, nil},
+		{testVal(Decimal, "0.000"), false, nil},
+		{NewVarChar("0.0"), false, nil},
+		{NewVarChar("2 cats"), true, ErrTruncated},
+		{NewVarChar("true"), false, ErrTruncated},
+		{NewVarChar("1e400"), true, ErrOutOfRange},
+		{testVal(Bit, "\x00"), false, nil},
+		{NULL, false, ErrNull},
+	}
+	for _, tcase := range testcases {
+		got, err := tcase.in.ToBool()
+		if !errors.Is(err, tcase.err) {
+			t.Errorf("%v.ToBool() error: %v, want %v", tcase.in, err, tcase.err)
+		}
+		if got != tcase.want {
+			t.Errorf("%v.ToBool(): %v, want %v", tcase.in, got, tcase.want)
+		}
+	}
+}
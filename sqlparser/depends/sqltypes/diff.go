@@ -0,0 +1,227 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DiffOptions are the options of the DiffResults.
+type DiffOptions struct {
+	// Unordered compares the rows as a multiset, for the queries without the ORDER BY.
+	Unordered bool
+
+	// IgnoreTypes compares the fields by the names and the values by the bytes only,
+	// like the INT32 and the INT64 of the same column on two backends, the NULL is still not the ''.
+	IgnoreTypes bool
+
+	// MaxDiffs limits the lines of the differences, 0 is unlimited.
+	MaxDiffs int
+}
+
+// ResultsEqual checks whether the results have the same fields, counts and rows in the same order.
+func ResultsEqual(a, b *Result) bool {
+	return DiffResults(a, b, nil) == ""
+}
+
+// DiffResults returns the human readable differences of the results line by line, empty if they are equal.
+// The a is the left and the b is the right, the values are printed as `left != right`:
+//
+//	fields: 2 != 3
+//	field[1]: name != user_name
+//	rows_affected: 2 != 1
+//	row[0].name: 'ann' != NULL
+//
+// The rows only in one side of the unordered comparison are printed as `-(1, 'ann')` of the left
+// and `+(1, 'bob')` of the right.
+func DiffResults(a, b *Result, opts *DiffOptions) string {
+	if opts == nil {
+		opts = &DiffOptions{}
+	}
+	d := &differ{opts: opts}
+	switch {
+	case a == nil && b == nil:
+		return ""
+	case a == nil || b == nil:
+		d.addf("result: %s != %s", nilString(a == nil), nilString(b == nil))
+		return d.String()
+	}
+
+	d.diffFields(a, b)
+	if a.RowsAffected != b.RowsAffected {
+		d.addf("rows_affected: %d != %d", a.RowsAffected, b.RowsAffected)
+	}
+	if a.InsertID != b.InsertID {
+		d.addf("insert_id: %d != %d", a.InsertID, b.InsertID)
+	}
+	if len(a.Rows) != len(b.Rows) {
+		d.addf("rows: %d != %d", len(a.Rows), len(b.Rows))
+	}
+	if opts.Unordered {
+		d.diffRowsUnordered(a, b)
+	} else {
+		d.diffRows(a, b)
+	}
+	return d.String()
+}
+
+type differ struct {
+	opts  *DiffOptions
+	lines []string
+	more  int
+}
+
+func (d *differ) addf(format string, args ...interface{}) {
+	if d.opts.MaxDiffs > 0 && len(d.lines) >= d.opts.MaxDiffs {
+		d.more++
+		return
+	}
+	d.lines = append(d.lines, fmt.Sprintf(format, args...))
+}
+
+func (d *differ) String() string {
+	if d.more > 0 {
+		d.lines = append(d.lines, fmt.Sprintf("... %d more differences", d.more))
+	}
+	return strings.Join(d.lines, "\n")
+}
+
+func (d *differ) diffFields(a, b *Result) {
+	if len(a.Fields) != len(b.Fields) {
+		d.addf("fields: %d != %d", len(a.Fields), len(b.Fields))
+	}
+	for i := 0; i < len(a.Fields) && i < len(b.Fields); i++ {
+		fa, fb := a.Fields[i], b.Fields[i]
+		if fa.Name != fb.Name {
+			d.addf("field[%d]: %s != %s", i, fa.Name, fb.Name)
+		}
+		if !d.opts.IgnoreTypes && fa.Type != fb.Type {
+			d.addf("field[%d].%s.type: %v != %v", i, fa.Name, fa.Type, fb.Type)
+		}
+	}
+}
+
+// column returns the name of the i-th column of the left, or the index if there is no field.
+func column(qr *Result, i int) string {
+	if i < len(qr.Fields) && qr.Fields[i].Name != "" {
+		return qr.Fields[i].Name
+	}
+	return fmt.Sprintf("col[%d]", i)
+}
+
+func (d *differ) diffRows(a, b *Result) {
+	for i := 0; i < len(a.Rows) || i < len(b.Rows); i++ {
+		switch {
+		case i >= len(b.Rows):
+			d.addf("row[%d]: %s != <missing>", i, formatRow(a.Rows[i]))
+		case i >= len(a.Rows):
+			d.addf("row[%d]: <missing> != %s", i, formatRow(b.Rows[i]))
+		default:
+			ra, rb := a.Rows[i], b.Rows[i]
+			for j := 0; j < len(ra) || j < len(rb); j++ {
+				switch {
+				case j >= len(rb):
+					d.addf("row[%d].%s: %s != <missing>", i, column(a, j), formatValue(ra[j]))
+				case j >= len(ra):
+					d.addf("row[%d].%s: <missing> != %s", i, column(b, j), formatValue(rb[j]))
+				case !d.valueEqual(ra[j], rb[j]):
+					d.addf("row[%d].%s: %s != %s", i, column(a, j), formatValue(ra[j]), formatValue(rb[j]))
+				}
+			}
+		}
+	}
+}
+
+func (d *differ) diffRowsUnordered(a, b *Result) {
+	for _, row := range d.subtract(a.Rows, b.Rows) {
+		d.addf("-%s", formatRow(row))
+	}
+	for _, row := range d.subtract(b.Rows, a.Rows) {
+		d.addf("+%s", formatRow(row))
+	}
+}
+
+// subtract returns the rows of the x not in the y in order, the duplicates are counted.
+func (d *differ) subtract(x, y [][]Value) [][]Value {
+	counts := make(map[string]int, len(y))
+	for _, row := range y {
+		counts[d.rowKey(row)]++
+	}
+	var out [][]Value
+	for _, row := range x {
+		key := d.rowKey(row)
+		if counts[key] > 0 {
+			counts[key]--
+			continue
+		}
+		out = append(out, row)
+	}
+	return out
+}
+
+func (d *differ) rowKey(row []Value) string {
+	var buf bytes.Buffer
+	for _, v := range row {
+		if v.IsNull() {
+			buf.WriteString("N;")
+			continue
+		}
+		if !d.opts.IgnoreTypes {
+			buf.WriteString(strconv.Itoa(int(v.typ)))
+		}
+		buf.WriteByte(':')
+		buf.WriteString(strconv.Itoa(len(v.val)))
+		buf.WriteByte(':')
+		buf.Write(v.val)
+	}
+	return buf.String()
+}
+
+func (d *differ) valueEqual(x, y Value) bool {
+	if x.IsNull() || y.IsNull() {
+		return x.IsNull() && y.IsNull()
+	}
+	if !d.opts.IgnoreTypes && x.typ != y.typ {
+		return false
+	}
+	return bytes.Equal(x.val, y.val)
+}
+
+// formatRow formats the row like (1, 'ann', NULL).
+func formatRow(row []Value) string {
+	vals := make([]string, len(row))
+	for i, v := range row {
+		vals[i] = formatValue(v)
+	}
+	return "(" + strings.Join(vals, ", ") + ")"
+}
+
+// formatValue formats the value as the SQL literal, the quoted types are escaped and the NULL is NULL.
+func formatValue(v Value) string {
+	switch {
+	case v.IsNull():
+		return "NULL"
+	case v.IsQuoted():
+		var buf bytes.Buffer
+		encodeBytesSQL(v.val, &buf)
+		return buf.String()
+	}
+	return string(v.val)
+}
+
+func nilString(isNil bool) string {
+	if isNil {
+		return "nil"
+	}
+	return "result"
+}
@@ -0,0 +1,62 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newDiffResult(rows ...[]interface{}) *Result {
+	b := NewResultBuilder(NewInt64Field("id"), NewVarCharField("name", 0, 32))
+	for _, row := range rows {
+		b.AppendRow(row...)
+	}
+	return b.Result()
+}
+
+func TestResultsEqual(t *testing.T) {
+	a := newDiffResult([]interface{}{1, "ann"}, []interface{}{2, nil})
+	assert.True(t, ResultsEqual(a, a.Copy()))
+	assert.True(t, ResultsEqual(nil, nil))
+	assert.False(t, ResultsEqual(a, nil))
+	assert.False(t, ResultsEqual(a, newDiffResult([]interface{}{1, "ann"}, []interface{}{2, ""})))
+	assert.False(t, ResultsEqual(a, newDiffResult([]interface{}{2, nil}, []interface{}{1, "ann"})))
+}
+
+func TestDiffResults(t *testing.T) {
+	a := newDiffResult([]interface{}{1, "ann"}, []interface{}{2, nil}, []interface{}{3, "it's"})
+	b := newDiffResult([]interface{}{1, "bob"}, []interface{}{2, nil})
+	b.Fields[1].Name = "user_name"
+	want := "field[1]: name != user_name\n" +
+		"rows_affected: 3 != 2\n" +
+		"rows: 3 != 2\n" +
+		"row[0].name: 'ann' != 'bob'\n" +
+		"row[2]: (3, 'it\\'s') != <missing>"
+	assert.Equal(t, want, DiffResults(a, b, nil))
+	assert.Equal(t, "result: result != nil", DiffResults(a, nil, nil))
+
+	// The types.
+	c := a.Copy()
+	c.Fields[0] = NewInt32Field("id")
+	c.Rows[0][0] = NewInt32(1)
+	assert.Equal(t, "field[0].id.type: INT64 != INT32\nrow[0].id: 1 != 1", DiffResults(a, c, nil))
+	assert.Equal(t, "", DiffResults(a, c, &DiffOptions{IgnoreTypes: true}))
+
+	// The unordered rows with the duplicates.
+	x := newDiffResult([]interface{}{1, "ann"}, []interface{}{1, "ann"}, []interface{}{2, nil})
+	y := newDiffResult([]interface{}{2, nil}, []interface{}{1, "ann"}, []interface{}{2, ""})
+	assert.Equal(t, "-(1, 'ann')\n+(2, '')", DiffResults(x, y, &DiffOptions{Unordered: true}))
+	assert.Equal(t, "", DiffResults(x, x.Copy(), &DiffOptions{Unordered: true}))
+
+	// The limit.
+	assert.Equal(t, "field[1]: name != user_name\n... 4 more differences", DiffResults(a, b, &DiffOptions{MaxDiffs: 1}))
+}
@@ -0,0 +1,226 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package escape renders Go values as MySQL literals.
+// https://dev.mysql.com/doc/refman/5.7/en/literals.html
+package escape
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// TimeFormat is the layout for DATETIME/TIMESTAMP literals.
+	TimeFormat = "2006-01-02 15:04:05.999999"
+
+	nullstr = "NULL"
+)
+
+// EscapeString escapes the string with backslash, the result isn't quoted.
+func EscapeString(s string) string {
+	buf := bytes.NewBuffer(make([]byte, 0, len(s)+8))
+	escapeBytes(buf, []byte(s))
+	return buf.String()
+}
+
+// QuoteString escapes and single-quotes the string.
+func QuoteString(s string) string {
+	buf := bytes.NewBuffer(make([]byte, 0, len(s)+8))
+	buf.WriteByte('\'')
+	escapeBytes(buf, []byte(s))
+	buf.WriteByte('\'')
+	return buf.String()
+}
+
+// QuoteIdentifier quotes the identifier with backticks, the inner backticks are doubled.
+func QuoteIdentifier(id string) string {
+	return "`" + strings.Replace(id, "`", "``", -1) + "`"
+}
+
+func escapeBytes(buf *bytes.Buffer, val []byte) {
+	for _, ch := range val {
+		if encoded := sqltypes.SQLEncodeMap[ch]; encoded == sqltypes.DontEscape {
+			buf.WriteByte(ch)
+		} else {
+			buf.WriteByte('\\')
+			buf.WriteByte(encoded)
+		}
+	}
+}
+
+// Literal renders the Go value as a MySQL literal.
+// Supported types: nil, bool, integers, floats, string, []byte, time.Time and sqltypes.Value.
+func Literal(arg interface{}) (string, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 32))
+	if err := writeLiteral(buf, arg, 0); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func writeLiteral(buf *bytes.Buffer, arg interface{}, mode sqlparser.SQLMode) error {
+	switch v := arg.(type) {
+	case nil:
+		buf.WriteString(nullstr)
+	case bool:
+		if v {
+			buf.WriteByte('1')
+		} else {
+			buf.WriteByte('0')
+		}
+	case int:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int8:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int16:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int32:
+		buf.WriteString(strconv.FormatInt(int64(v), 10))
+	case int64:
+		buf.WriteString(strconv.FormatInt(v, 10))
+	case uint:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint8:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint16:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint32:
+		buf.WriteString(strconv.FormatUint(uint64(v), 10))
+	case uint64:
+		buf.WriteString(strconv.FormatUint(v, 10))
+	case float32:
+		if err := checkFloat(float64(v)); err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	case float64:
+		if err := checkFloat(v); err != nil {
+			return err
+		}
+		buf.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+	case string:
+		quoteBytes(buf, []byte(v), mode)
+	case []byte:
+		if v == nil {
+			buf.WriteString(nullstr)
+			return nil
+		}
+		writeHex(buf, v)
+	case time.Time:
+		if v.IsZero() {
+			buf.WriteString("'0000-00-00'")
+			return nil
+		}
+		buf.WriteByte('\'')
+		buf.WriteString(v.Format(TimeFormat))
+		buf.WriteByte('\'')
+	case sqltypes.Value:
+		writeValue(buf, v, mode)
+	default:
+		return fmt.Errorf("escape.unsupported.type[%T]", arg)
+	}
+	return nil
+}
+
+// checkFloat rejects the NaN and the infinities, MySQL has no literals of them.
+func checkFloat(v float64) error {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return fmt.Errorf("escape.unsupported.float[%v]", v)
+	}
+	return nil
+}
+
+// writeValue renders the sqltypes.Value according to its type.
+func writeValue(buf *bytes.Buffer, v sqltypes.Value, mode sqlparser.SQLMode) {
+	switch {
+	case v.IsNull():
+		buf.WriteString(nullstr)
+	case v.IsIntegral(), v.IsFloat(), v.Type() == sqltypes.Decimal:
+		buf.Write(v.Raw())
+	case v.IsBinary(), v.Type() == sqltypes.Bit, v.Type() == sqltypes.Geometry:
+		writeHex(buf, v.Raw())
+	default:
+		// Text and temporal types.
+		quoteBytes(buf, v.Raw(), mode)
+	}
+}
+
+// quoteBytes single-quotes the string, it's escaped by the backslashes
+// or by doubling the quotes if the NO_BACKSLASH_ESCAPES is set.
+func quoteBytes(buf *bytes.Buffer, v []byte, mode sqlparser.SQLMode) {
+	buf.WriteByte('\'')
+	if mode&sqlparser.ModeNoBackslashEscapes != 0 {
+		buf.Write(bytes.Replace(v, []byte("'"), []byte("''"), -1))
+	} else {
+		escapeBytes(buf, v)
+	}
+	buf.WriteByte('\'')
+}
+
+func writeHex(buf *bytes.Buffer, v []byte) {
+	if len(v) == 0 {
+		buf.WriteString("''")
+		return
+	}
+	buf.WriteString("X'")
+	buf.WriteString(hex.EncodeToString(v))
+	buf.WriteByte('\'')
+}
+
+// Interpolate replaces the '?' placeholders in the query with the literals of args.
+// The '?' inside quoted strings, identifiers and the /* */, -- and # comments is untouched.
+func Interpolate(query string, args ...interface{}) (string, error) {
+	return InterpolateMode(query, 0, args...)
+}
+
+// InterpolateMode is the Interpolate by the sql_mode of the session, the NO_BACKSLASH_ESCAPES keeps the '\'
+// in the strings of the query and quotes the string literals of args by doubling the quotes,
+// the ANSI_QUOTES makes the '"' quote the identifiers.
+func InterpolateMode(query string, mode sqlparser.SQLMode, args ...interface{}) (string, error) {
+	var idx int
+
+	buf := bytes.NewBuffer(make([]byte, 0, len(query)+len(args)*8))
+	for i := 0; i < len(query); i++ {
+		if j := sqlparser.SkipLiteral(query, i, mode); j != i {
+			if j == len(query) {
+				if ch := query[i]; ch == '\'' || ch == '"' || ch == '`' {
+					return "", fmt.Errorf("escape.interpolate.unterminated.quote:query[%s]", query)
+				}
+				j--
+			}
+			buf.WriteString(query[i : j+1])
+			i = j
+			continue
+		}
+		if query[i] != '?' {
+			buf.WriteByte(query[i])
+			continue
+		}
+		if idx >= len(args) {
+			return "", fmt.Errorf("escape.interpolate.args.not.enough:query[%s].args[%d]", query, len(args))
+		}
+		if err := writeLiteral(buf, args[idx], mode); err != nil {
+			return "", err
+		}
+		idx++
+	}
+	if idx != len(args) {
+		return "", fmt.Errorf("escape.interpolate.args.too.many:query[%s].placeholders[%d].args[%d]", query, idx, len(args))
+	}
+	return buf.String(), nil
+}
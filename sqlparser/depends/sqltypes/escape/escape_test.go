@@ -0,0 +1,153 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package escape
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestEscapeString(t *testing.T) {
+	assert.Equal(t, `abc`, EscapeString("abc"))
+	assert.Equal(t, `a\'b\"c\\d\0e\nf\rg\Z`, EscapeString("a'b\"c\\d\x00e\nf\rg\x1a"))
+	assert.Equal(t, `'it\'s'`, QuoteString("it's"))
+}
+
+func TestQuoteIdentifier(t *testing.T) {
+	assert.Equal(t, "`t1`", QuoteIdentifier("t1"))
+	assert.Equal(t, "`a``b`", QuoteIdentifier("a`b"))
+}
+
+func TestLiteral(t *testing.T) {
+	ts := time.Date(2017, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	tests := []struct {
+		arg  interface{}
+		want string
+	}{
+		{nil, "NULL"},
+		{true, "1"},
+		{false, "0"},
+		{int(-1), "-1"},
+		{int8(-8), "-8"},
+		{int16(16), "16"},
+		{int32(32), "32"},
+		{int64(-64), "-64"},
+		{uint(1), "1"},
+		{uint8(8), "8"},
+		{uint16(16), "16"},
+		{uint32(32), "32"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{float32(1.5), "1.5"},
+		{float64(-0.25), "-0.25"},
+		{"x'y", `'x\'y'`},
+		{[]byte{0x00, 0xff}, "X'00ff'"},
+		{[]byte{}, "''"},
+		{[]byte(nil), "NULL"},
+		{ts, "'2017-01-02 03:04:05.123'"},
+		{time.Time{}, "'0000-00-00'"},
+		{sqltypes.NULL, "NULL"},
+		{sqltypes.NewInt64(10), "10"},
+		{sqltypes.NewFloat64(1.25), "1.25"},
+		{sqltypes.MakeTrusted(sqltypes.Decimal, []byte("1.10")), "1.10"},
+		{sqltypes.NewVarChar("a'b"), `'a\'b'`},
+		{sqltypes.NewVarBinary("ab"), "X'6162'"},
+		{sqltypes.MakeTrusted(sqltypes.Datetime, []byte("2017-01-02 03:04:05")), "'2017-01-02 03:04:05'"},
+	}
+	for _, test := range tests {
+		got, err := Literal(test.arg)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, got)
+	}
+
+	_, err := Literal(struct{}{})
+	assert.NotNil(t, err)
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		query string
+		args  []interface{}
+		want  string
+	}{
+		{
+			query: "select * from t where a=? and b=?",
+			args:  []interface{}{1, "x"},
+			want:  "select * from t where a=1 and b='x'",
+		},
+		{
+			query: "select '?', `?`, \"a\\\"?\" /* ? */ from t where a=?",
+			args:  []interface{}{nil},
+			want:  "select '?', `?`, \"a\\\"?\" /* ? */ from t where a=NULL",
+		},
+		{
+			query: "insert into t values(?, ?)",
+			args:  []interface{}{[]byte("\x01"), "it's"},
+			want:  "insert into t values(X'01', 'it\\'s')",
+		},
+		{
+			query: "select 1 /* unterminated ?",
+			want:  "select 1 /* unterminated ?",
+		},
+		{
+			query: "select ? -- the a?\nfrom t # the t?\nwhere b=?-- ?",
+			args:  []interface{}{1, 2},
+			want:  "select 1 -- the a?\nfrom t # the t?\nwhere b=2-- ?",
+		},
+		{
+			query: "select 1--?",
+			args:  []interface{}{1},
+			want:  "select 1--1",
+		},
+	}
+	for _, test := range tests {
+		got, err := Interpolate(test.query, test.args...)
+		assert.Nil(t, err)
+		assert.Equal(t, test.want, got)
+	}
+
+	// Errors.
+	{
+		_, err := Interpolate("select ?")
+		assert.NotNil(t, err)
+		_, err = Interpolate("select ?", 1, 2)
+		assert.NotNil(t, err)
+		_, err = Interpolate("select '?", 1)
+		assert.NotNil(t, err)
+		_, err = Interpolate("select ?", struct{}{})
+		assert.NotNil(t, err)
+		_, err = Interpolate("select ?", math.NaN())
+		assert.EqualError(t, err, "escape.unsupported.float[NaN]")
+		_, err = Interpolate("select ?", float32(math.Inf(-1)))
+		assert.EqualError(t, err, "escape.unsupported.float[-Inf]")
+	}
+}
+
+func TestInterpolateMode(t *testing.T) {
+	mode := sqlparser.ModeNoBackslashEscapes | sqlparser.ModeANSIQuotes
+
+	// The '\' doesn't escape the quote, the '?' after the string is a placeholder.
+	got, err := InterpolateMode(`select 'a\', "b\" from t where a=?`, mode, "it's")
+	assert.Nil(t, err)
+	assert.Equal(t, `select 'a\', "b\" from t where a='it''s'`, got)
+
+	got, err = InterpolateMode("select ?", mode, sqltypes.NewVarChar(`a\'b`))
+	assert.Nil(t, err)
+	assert.Equal(t, `select 'a\''b'`, got)
+
+	// The same query by the default mode has no placeholder.
+	_, err = Interpolate(`select 'a\', "b\" from t where a=?`, "x")
+	assert.NotNil(t, err)
+}
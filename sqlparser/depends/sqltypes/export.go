@@ -0,0 +1,226 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// RowEncoder streams the rows of a resultset, the fields are written before the rows.
+type RowEncoder interface {
+	WriteFields(fields []*querypb.Field) error
+	WriteRow(row []Value) error
+
+	// Flush writes the buffered data to the writer.
+	Flush() error
+}
+
+// EncodeResult writes the fields and the rows of the result to the encoder and flushes it.
+func EncodeResult(enc RowEncoder, qr *Result) error {
+	if err := enc.WriteFields(qr.Fields); err != nil {
+		return err
+	}
+	for _, row := range qr.Rows {
+		if err := enc.WriteRow(row); err != nil {
+			return err
+		}
+	}
+	return enc.Flush()
+}
+
+// isBinaryValue checks whether the value is the bytes can't be written as the text.
+func isBinaryValue(v Value) bool {
+	return v.IsBinary() || v.Type() == Geometry
+}
+
+// CSVEncoder writes the rows as the RFC 4180 CSV, the numbers are never quoted.
+type CSVEncoder struct {
+	w *bufio.Writer
+
+	// Comma is the field delimiter, ',' if it's zero.
+	Comma byte
+
+	// Header writes the names of the fields as the first record.
+	Header bool
+
+	// Null is the text of the NULL, empty is `\N` like the SELECT INTO OUTFILE.
+	Null string
+
+	// QuoteText quotes all the text and temporal values, the others are quoted only if they need.
+	QuoteText bool
+
+	// HexBinary writes the binary values as the hex like 0x0102, otherwise they are written as they are.
+	HexBinary bool
+
+	fields []*querypb.Field
+}
+
+// NewCSVEncoder creates the CSV encoder of the writer.
+func NewCSVEncoder(w io.Writer) *CSVEncoder {
+	return &CSVEncoder{w: bufio.NewWriter(w)}
+}
+
+func (e *CSVEncoder) comma() byte {
+	if e.Comma == 0 {
+		return ','
+	}
+	return e.Comma
+}
+
+// WriteFields sets the fields of the rows, the header is written if it's enabled.
+func (e *CSVEncoder) WriteFields(fields []*querypb.Field) error {
+	e.fields = fields
+	if !e.Header {
+		return nil
+	}
+	for i, f := range fields {
+		if i > 0 {
+			e.w.WriteByte(e.comma())
+		}
+		e.writeField([]byte(f.Name), e.QuoteText)
+	}
+	_, err := e.w.WriteString("\r\n")
+	return err
+}
+
+// WriteRow writes the row as a record.
+func (e *CSVEncoder) WriteRow(row []Value) error {
+	if e.fields != nil && len(row) != len(e.fields) {
+		return fmt.Errorf("sqltypes.csv.row.values[%d].fields[%d].mismatch", len(row), len(e.fields))
+	}
+	for i, v := range row {
+		if i > 0 {
+			e.w.WriteByte(e.comma())
+		}
+		switch {
+		case v.IsNull():
+			null := e.Null
+			if null == "" {
+				null = `\N`
+			}
+			e.w.WriteString(null)
+		case v.IsIntegral() || v.IsFloat() || v.Type() == Decimal:
+			e.w.Write(v.Raw())
+		case isBinaryValue(v) && e.HexBinary:
+			e.w.WriteString("0x")
+			e.w.WriteString(hex.EncodeToString(v.Raw()))
+		default:
+			e.writeField(v.Raw(), e.QuoteText && !isBinaryValue(v))
+		}
+	}
+	_, err := e.w.WriteString("\r\n")
+	return err
+}
+
+// writeField writes the field quoted if it has the comma, the quote, the line breaks or the quote is forced.
+func (e *CSVEncoder) writeField(field []byte, quote bool) {
+	if !quote {
+		quote = bytes.IndexByte(field, e.comma()) >= 0 || bytes.ContainsAny(field, "\"\r\n") ||
+			(len(field) > 0 && (field[0] == ' ' || field[0] == '\t'))
+	}
+	if !quote {
+		e.w.Write(field)
+		return
+	}
+	e.w.WriteByte('"')
+	for _, c := range field {
+		if c == '"' {
+			e.w.WriteByte('"')
+		}
+		e.w.WriteByte(c)
+	}
+	e.w.WriteByte('"')
+}
+
+// Flush writes the buffered data to the writer.
+func (e *CSVEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// JSONEncoder writes the rows as the JSON lines, one object of the field names per row.
+// The numbers are the JSON numbers, the NULL is the null, the JSON values are embedded,
+// the binary values are the base64 strings and the others are the strings.
+type JSONEncoder struct {
+	w      *bufio.Writer
+	fields []*querypb.Field
+	names  [][]byte
+	buf    bytes.Buffer
+}
+
+// NewJSONEncoder creates the JSON lines encoder of the writer.
+func NewJSONEncoder(w io.Writer) *JSONEncoder {
+	return &JSONEncoder{w: bufio.NewWriter(w)}
+}
+
+// WriteFields sets the fields of the rows, the names are the keys of the objects.
+func (e *JSONEncoder) WriteFields(fields []*querypb.Field) error {
+	e.fields = fields
+	e.names = make([][]byte, len(fields))
+	for i, f := range fields {
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return err
+		}
+		e.names[i] = name
+	}
+	return nil
+}
+
+// WriteRow writes the row as a JSON object line.
+func (e *JSONEncoder) WriteRow(row []Value) error {
+	if len(row) != len(e.fields) {
+		return fmt.Errorf("sqltypes.json.row.values[%d].fields[%d].mismatch", len(row), len(e.fields))
+	}
+	e.w.WriteByte('{')
+	for i, v := range row {
+		if i > 0 {
+			e.w.WriteByte(',')
+		}
+		e.w.Write(e.names[i])
+		e.w.WriteByte(':')
+		switch {
+		case v.IsNull():
+			e.w.WriteString("null")
+		case (v.IsIntegral() || v.IsFloat() || v.Type() == Decimal) && json.Valid(v.Raw()):
+			e.w.Write(v.Raw())
+		case v.Type() == TypeJSON && json.Valid(v.Raw()):
+			e.buf.Reset()
+			if err := json.Compact(&e.buf, v.Raw()); err != nil {
+				return err
+			}
+			e.w.Write(e.buf.Bytes())
+		case isBinaryValue(v):
+			e.w.WriteByte('"')
+			e.w.WriteString(base64.StdEncoding.EncodeToString(v.Raw()))
+			e.w.WriteByte('"')
+		default:
+			s, err := json.Marshal(string(v.Raw()))
+			if err != nil {
+				return err
+			}
+			e.w.Write(s)
+		}
+	}
+	_, err := e.w.WriteString("}\n")
+	return err
+}
+
+// Flush writes the buffered data to the writer.
+func (e *JSONEncoder) Flush() error {
+	return e.w.Flush()
+}
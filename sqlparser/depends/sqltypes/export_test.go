@@ -0,0 +1,63 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newExportResult() *Result {
+	b := NewResultBuilder(NewInt64Field("id"), NewVarCharField("name", 0, 32), NewDecimalField("price", 10, 2), NewBlobField("data"), NewJSONField("doc"))
+	b.AppendRow(1, "apple", "1.50", []byte{0x01, 0x02}, `{"a": [1, 2]}`)
+	b.AppendRow(2, `say "hi", bob`, nil, nil, nil)
+	b.AppendRow(3, "line\nbreak", "0.00", []byte("raw"), "null")
+	return b.Result()
+}
+
+func TestCSVEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewCSVEncoder(&buf)
+	enc.Header = true
+	assert.Nil(t, EncodeResult(enc, newExportResult()))
+	want := "id,name,price,data,doc\r\n" +
+		"1,apple,1.50,\x01\x02,\"{\"\"a\"\": [1, 2]}\"\r\n" +
+		"2,\"say \"\"hi\"\", bob\",\\N,\\N,\\N\r\n" +
+		"3,\"line\nbreak\",0.00,raw,null\r\n"
+	assert.Equal(t, want, buf.String())
+
+	// The options.
+	buf.Reset()
+	enc = NewCSVEncoder(&buf)
+	enc.Comma = '\t'
+	enc.Null = "NULL"
+	enc.QuoteText = true
+	enc.HexBinary = true
+	assert.Nil(t, EncodeResult(enc, newExportResult()))
+	want = "1\t\"apple\"\t1.50\t0x0102\t\"{\"\"a\"\": [1, 2]}\"\r\n" +
+		"2\t\"say \"\"hi\"\", bob\"\tNULL\tNULL\tNULL\r\n" +
+		"3\t\"line\nbreak\"\t0.00\t0x726177\t\"null\"\r\n"
+	assert.Equal(t, want, buf.String())
+
+	assert.EqualError(t, enc.WriteRow([]Value{NULL}), "sqltypes.csv.row.values[1].fields[5].mismatch")
+}
+
+func TestJSONEncoder(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewJSONEncoder(&buf)
+	assert.Nil(t, EncodeResult(enc, newExportResult()))
+	want := `{"id":1,"name":"apple","price":1.50,"data":"AQI=","doc":{"a":[1,2]}}` + "\n" +
+		`{"id":2,"name":"say \"hi\", bob","price":null,"data":null,"doc":null}` + "\n" +
+		`{"id":3,"name":"line\nbreak","price":0.00,"data":"cmF3","doc":null}` + "\n"
+	assert.Equal(t, want, buf.String())
+	assert.EqualError(t, enc.WriteRow([]Value{NULL}), "sqltypes.json.row.values[1].fields[5].mismatch")
+}
@@ -0,0 +1,163 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// The column lengths MySQL sends for the types.
+const (
+	int32FieldLength    = 11
+	uint32FieldLength   = 10
+	int64FieldLength    = 20
+	float64FieldLength  = 22
+	dateFieldLength     = 10
+	datetimeFieldLength = 19
+	timeFieldLength     = 10
+	blobFieldLength     = 65535
+	jsonFieldLength     = 4294967295
+
+	// notFixedDecimals is the decimals of the FLOAT and DOUBLE without the scale.
+	notFixedDecimals = 31
+)
+
+// newField returns the field of the type, the flags of the type like the UNSIGNED_FLAG are merged,
+// the non-text types are binary.
+func newField(name string, typ querypb.Type, flags querypb.MySqlFlag, length uint32) *querypb.Field {
+	_, typeFlags := TypeToMySQL(typ)
+	charset := uint32(sqldb.CharacterSetBinary)
+	if IsText(typ) {
+		charset = sqldb.DefaultCollation
+	} else {
+		flags |= querypb.MySqlFlag_BINARY_FLAG
+	}
+	return &querypb.Field{
+		Name:         name,
+		Type:         typ,
+		Charset:      charset,
+		ColumnLength: length,
+		Flags:        uint32(typeFlags) | uint32(flags),
+	}
+}
+
+// newTextField returns the text field of the collation, the length is in the characters.
+func newTextField(name string, typ querypb.Type, flags querypb.MySqlFlag, collation uint32, length uint32) *querypb.Field {
+	if collation == 0 {
+		collation = sqldb.DefaultCollation
+	}
+	maxLen := 1
+	if c, ok := sqldb.LookupCollation(uint16(collation)); ok {
+		maxLen = c.MaxLen()
+	}
+	field := newField(name, typ, flags, length*uint32(maxLen))
+	field.Charset = collation
+	return field
+}
+
+// temporalLength returns the length of the temporal type with the fractional seconds precision.
+func temporalLength(length uint32, fsp uint32) uint32 {
+	if fsp > 0 {
+		return length + 1 + fsp
+	}
+	return length
+}
+
+// NewInt32Field returns the INT field.
+func NewInt32Field(name string) *querypb.Field {
+	return newField(name, Int32, 0, int32FieldLength)
+}
+
+// NewUint32Field returns the INT UNSIGNED field.
+func NewUint32Field(name string) *querypb.Field {
+	return newField(name, Uint32, 0, uint32FieldLength)
+}
+
+// NewInt64Field returns the BIGINT field.
+func NewInt64Field(name string) *querypb.Field {
+	return newField(name, Int64, 0, int64FieldLength)
+}
+
+// NewUint64Field returns the BIGINT UNSIGNED field.
+func NewUint64Field(name string) *querypb.Field {
+	return newField(name, Uint64, 0, int64FieldLength)
+}
+
+// NewFloat64Field returns the DOUBLE field.
+func NewFloat64Field(name string) *querypb.Field {
+	field := newField(name, Float64, 0, float64FieldLength)
+	field.Decimals = notFixedDecimals
+	return field
+}
+
+// NewDecimalField returns the DECIMAL(precision, scale) field, the length counts the sign and the point.
+func NewDecimalField(name string, precision, scale uint32) *querypb.Field {
+	length := precision + 1
+	if scale > 0 {
+		length++
+	}
+	field := newField(name, Decimal, 0, length)
+	field.Decimals = scale
+	return field
+}
+
+// NewVarCharField returns the VARCHAR(length) field of the collation, zero is the sqldb.DefaultCollation.
+// The column length is the length in characters times the max bytes of a character.
+func NewVarCharField(name string, collation uint32, length uint32) *querypb.Field {
+	return newTextField(name, VarChar, 0, collation, length)
+}
+
+// NewVarBinaryField returns the VARBINARY(length) field.
+func NewVarBinaryField(name string, length uint32) *querypb.Field {
+	return newField(name, VarBinary, 0, length)
+}
+
+// NewTextField returns the TEXT field of the collation, zero is the sqldb.DefaultCollation.
+func NewTextField(name string, collation uint32) *querypb.Field {
+	return newTextField(name, Text, querypb.MySqlFlag_BLOB_FLAG, collation, blobFieldLength)
+}
+
+// NewBlobField returns the BLOB field.
+func NewBlobField(name string) *querypb.Field {
+	return newField(name, Blob, querypb.MySqlFlag_BLOB_FLAG, blobFieldLength)
+}
+
+// NewDateField returns the DATE field.
+func NewDateField(name string) *querypb.Field {
+	return newField(name, Date, 0, dateFieldLength)
+}
+
+// NewDatetimeField returns the DATETIME(fsp) field.
+func NewDatetimeField(name string, fsp uint32) *querypb.Field {
+	field := newField(name, Datetime, 0, temporalLength(datetimeFieldLength, fsp))
+	field.Decimals = fsp
+	return field
+}
+
+// NewTimestampField returns the TIMESTAMP(fsp) field.
+func NewTimestampField(name string, fsp uint32) *querypb.Field {
+	field := newField(name, Timestamp, 0, temporalLength(datetimeFieldLength, fsp))
+	field.Decimals = fsp
+	return field
+}
+
+// NewTimeField returns the TIME(fsp) field.
+func NewTimeField(name string, fsp uint32) *querypb.Field {
+	field := newField(name, Time, 0, temporalLength(timeFieldLength, fsp))
+	field.Decimals = fsp
+	return field
+}
+
+// NewJSONField returns the JSON field.
+func NewJSONField(name string) *querypb.Field {
+	return newField(name, TypeJSON, querypb.MySqlFlag_BLOB_FLAG, jsonFieldLength)
+}
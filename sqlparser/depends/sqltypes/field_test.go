@@ -0,0 +1,67 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestFieldBuilders(t *testing.T) {
+	binary := uint32(querypb.MySqlFlag_BINARY_FLAG)
+	unsigned := uint32(querypb.MySqlFlag_UNSIGNED_FLAG)
+	blob := uint32(querypb.MySqlFlag_BLOB_FLAG)
+	tests := []struct {
+		field    *querypb.Field
+		typ      querypb.Type
+		charset  uint32
+		length   uint32
+		decimals uint32
+		flags    uint32
+	}{
+		{NewInt32Field("a"), Int32, sqldb.CharacterSetBinary, 11, 0, binary},
+		{NewUint32Field("a"), Uint32, sqldb.CharacterSetBinary, 10, 0, binary | unsigned},
+		{NewInt64Field("a"), Int64, sqldb.CharacterSetBinary, 20, 0, binary},
+		{NewUint64Field("a"), Uint64, sqldb.CharacterSetBinary, 20, 0, binary | unsigned},
+		{NewFloat64Field("a"), Float64, sqldb.CharacterSetBinary, 22, 31, binary},
+		{NewDecimalField("a", 10, 2), Decimal, sqldb.CharacterSetBinary, 12, 2, binary},
+		{NewDecimalField("a", 10, 0), Decimal, sqldb.CharacterSetBinary, 11, 0, binary},
+		{NewVarCharField("a", 0, 64), VarChar, sqldb.DefaultCollation, 256, 0, 0},
+		{NewVarCharField("a", sqldb.CharacterSetUtf8, 64), VarChar, sqldb.CharacterSetUtf8, 192, 0, 0},
+		{NewVarBinaryField("a", 64), VarBinary, sqldb.CharacterSetBinary, 64, 0, binary},
+		{NewTextField("a", 0), Text, sqldb.DefaultCollation, 262140, 0, blob},
+		{NewBlobField("a"), Blob, sqldb.CharacterSetBinary, 65535, 0, binary | blob},
+		{NewDateField("a"), Date, sqldb.CharacterSetBinary, 10, 0, binary},
+		{NewDatetimeField("a", 0), Datetime, sqldb.CharacterSetBinary, 19, 0, binary},
+		{NewDatetimeField("a", 6), Datetime, sqldb.CharacterSetBinary, 26, 6, binary},
+		{NewTimestampField("a", 3), Timestamp, sqldb.CharacterSetBinary, 23, 3, binary},
+		{NewTimeField("a", 0), Time, sqldb.CharacterSetBinary, 10, 0, binary},
+		{NewJSONField("a"), TypeJSON, sqldb.CharacterSetBinary, 4294967295, 0, binary | blob},
+	}
+	for _, test := range tests {
+		f := test.field
+		assert.Equal(t, "a", f.Name)
+		assert.Equalf(t, test.typ, f.Type, "%v", test.typ)
+		assert.Equalf(t, test.charset, f.Charset, "%v", test.typ)
+		assert.Equalf(t, test.length, f.ColumnLength, "%v", test.typ)
+		assert.Equalf(t, test.decimals, f.Decimals, "%v", test.typ)
+		assert.Equalf(t, test.flags, f.Flags, "%v", test.typ)
+
+		// The type is recovered from the wire type and the flags.
+		mysqlType, _ := TypeToMySQL(f.Type)
+		typ, err := MySQLToType(mysqlType, int64(f.Flags))
+		assert.Nil(t, err)
+		assert.Equal(t, test.typ, typ)
+	}
+}
@@ -27,6 +27,25 @@ type Result struct {
 	Extras       *querypb.ResultExtras `json:"extras"`
 	sorters      []*sorter
 	State        ResultState
+
+	// RowsMatched is the rows found by the UPDATE include the unchanged ones,
+	// it's reported as the affected rows to the CLIENT_FOUND_ROWS clients if set.
+	RowsMatched uint64 `json:"rows_matched"`
+
+	// Info is the human readable info of the OK packet, like "Rows matched: 1  Changed: 1  Warnings: 0".
+	Info string `json:"info"`
+
+	// StatusFlags are the server status flags of the result, the server adds them to the session ones.
+	StatusFlags uint16 `json:"status_flags"`
+
+	// SessionStateChanges are the session state changes of the OK packet for the CLIENT_SESSION_TRACK clients.
+	SessionStateChanges []SessionStateChange `json:"session_state_changes"`
+}
+
+// SessionStateChange is a session state change of the OK packet, the Data is encoded as the Type says.
+type SessionStateChange struct {
+	Type byte   `json:"type"`
+	Data []byte `json:"data"`
 }
 
 // ResultStream is an interface for receiving Result. It is used for
@@ -55,6 +74,15 @@ func (result *Result) Copy() *Result {
 	out := &Result{
 		InsertID:     result.InsertID,
 		RowsAffected: result.RowsAffected,
+		RowsMatched:  result.RowsMatched,
+		Info:         result.Info,
+		StatusFlags:  result.StatusFlags,
+	}
+	if result.SessionStateChanges != nil {
+		out.SessionStateChanges = make([]SessionStateChange, len(result.SessionStateChanges))
+		for i, c := range result.SessionStateChanges {
+			out.SessionStateChanges[i] = SessionStateChange{Type: c.Type, Data: append([]byte(nil), c.Data...)}
+		}
 	}
 	if result.Fields != nil {
 		fieldsp := make([]*querypb.Field, len(result.Fields))
@@ -68,17 +96,7 @@ func (result *Result) Copy() *Result {
 	if result.Rows != nil {
 		rows := make([][]Value, len(result.Rows))
 		for i, r := range result.Rows {
-			rows[i] = make([]Value, len(r))
-			totalLen := 0
-			for _, c := range r {
-				totalLen += len(c.val)
-			}
-			arena := make([]byte, 0, totalLen)
-			for j, c := range r {
-				start := len(arena)
-				arena = append(arena, c.val...)
-				rows[i][j] = MakeTrusted(c.typ, arena[start:start+len(c.val)])
-			}
+			rows[i] = Row(r).DeepCopy()
 		}
 		out.Rows = rows
 	}
@@ -124,16 +142,22 @@ func (result *Result) StripFieldNames() *Result {
 // to another result.Note currently it doesn't handle cases like
 // if two results have different fields.We will enhance this function.
 func (result *Result) AppendResult(src *Result) {
-	if src.RowsAffected == 0 && len(src.Fields) == 0 {
+	if src.RowsAffected == 0 && src.RowsMatched == 0 && len(src.Fields) == 0 {
 		return
 	}
 	if result.Fields == nil {
 		result.Fields = src.Fields
 	}
 	result.RowsAffected += src.RowsAffected
+	result.RowsMatched += src.RowsMatched
 	if src.InsertID != 0 {
 		result.InsertID = src.InsertID
 	}
+	if src.Info != "" {
+		result.Info = src.Info
+	}
+	result.StatusFlags |= src.StatusFlags
+	result.SessionStateChanges = append(result.SessionStateChanges, src.SessionStateChanges...)
 	if len(src.Rows) != 0 {
 		result.Rows = append(result.Rows, src.Rows...)
 	}
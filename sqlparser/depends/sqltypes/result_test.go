@@ -44,6 +44,12 @@ func TestCopy(t *testing.T) {
 		}},
 		InsertID:     1,
 		RowsAffected: 2,
+		RowsMatched:  3,
+		Info:         "Rows matched: 3  Changed: 2  Warnings: 0",
+		StatusFlags:  0x0800,
+		SessionStateChanges: []SessionStateChange{
+			{Type: 0x01, Data: []byte("\x02db")},
+		},
 		Rows: [][]Value{
 			{testVal(Int64, "1"), MakeTrusted(Null, nil)},
 			{testVal(Int64, "2"), MakeTrusted(VarChar, nil)},
@@ -58,6 +64,12 @@ func TestCopy(t *testing.T) {
 		}},
 		InsertID:     1,
 		RowsAffected: 2,
+		RowsMatched:  3,
+		Info:         "Rows matched: 3  Changed: 2  Warnings: 0",
+		StatusFlags:  0x0800,
+		SessionStateChanges: []SessionStateChange{
+			{Type: 0x01, Data: []byte("\x02db")},
+		},
 		Rows: [][]Value{
 			{testVal(Int64, "1"), MakeTrusted(Null, nil)},
 			{testVal(Int64, "2"), testVal(VarChar, "")},
@@ -68,6 +80,7 @@ func TestCopy(t *testing.T) {
 	// Change in so we're sure out got actually copied
 	in.Fields[0].Type = VarChar
 	in.Rows[0][0] = testVal(VarChar, "aa")
+	in.SessionStateChanges[0].Data[1] = 'x'
 	if !reflect.DeepEqual(out, want) {
 		t.Errorf("Copy:\n%#v, want\n%#v", out, want)
 	}
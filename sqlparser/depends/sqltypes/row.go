@@ -17,3 +17,19 @@ func (r Row) Copy() []Value {
 	}
 	return ret
 }
+
+// DeepCopy copies the row and the bytes of its values, the bytes share one allocation.
+func (r Row) DeepCopy() []Value {
+	ret := make([]Value, len(r))
+	totalLen := 0
+	for _, c := range r {
+		totalLen += len(c.val)
+	}
+	arena := make([]byte, 0, totalLen)
+	for j, c := range r {
+		start := len(arena)
+		arena = append(arena, c.val...)
+		ret[j] = MakeTrusted(c.typ, arena[start:start+len(c.val)])
+	}
+	return ret
+}
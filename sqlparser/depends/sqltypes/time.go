@@ -0,0 +1,153 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// The text layouts of the temporal values.
+const (
+	DateLayout     = "2006-01-02"
+	DatetimeLayout = "2006-01-02 15:04:05"
+)
+
+// NewTemporal returns the DATE, DATETIME or TIMESTAMP value of the t in the loc,
+// the fsp is the fractional seconds precision 0..6.
+func NewTemporal(typ querypb.Type, t time.Time, loc *time.Location, fsp int) (Value, error) {
+	if fsp < 0 || fsp > 6 {
+		return NULL, fmt.Errorf("sqltypes.temporal.invalid.fsp[%d]", fsp)
+	}
+	t = t.In(loc)
+	switch typ {
+	case Date:
+		return MakeTrusted(typ, []byte(t.Format(DateLayout))), nil
+	case Datetime, Timestamp:
+		layout := DatetimeLayout
+		if fsp > 0 {
+			layout += "." + strings.Repeat("0", fsp)
+		}
+		return MakeTrusted(typ, []byte(t.Format(layout))), nil
+	}
+	return NULL, fmt.Errorf("sqltypes.temporal.unsupported.type[%v]", typ)
+}
+
+// ToTime parses the value as the time in the loc like the datetime context of MySQL:
+//   - the DATE, DATETIME, TIMESTAMP and the strings like '2018-01-02 03:04:05.123', '2018-01-02T03:04:05' or '2018-01-02'.
+//   - the integers and the strings of the digits like 20180102030405 or 180102, the years 70..99 are 19xx and 00..69 20xx.
+//   - the zero value like '0000-00-00 00:00:00' returns the zero time.Time and the NULL the ErrNull.
+func (v Value) ToTime(loc *time.Location) (time.Time, error) {
+	s := string(v.val)
+	switch {
+	case v.typ == Null:
+		return time.Time{}, convertError(v, "time", ErrNull)
+	case v.typ == Date, v.typ == Datetime, v.typ == Timestamp:
+	case v.IsIntegral(), v.IsFloat(), v.typ == Decimal:
+		// The fraction of the numbers is the one of the seconds.
+		if v.IsFloat() && strings.ContainsAny(s, "eE") {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("sqltypes.temporal.invalid.value[%s]", s)
+			}
+			s = strconv.FormatFloat(f, 'f', -1, 64)
+		}
+		digits, fraction := s, ""
+		if dot := strings.IndexByte(s, '.'); dot >= 0 {
+			digits, fraction = s[:dot], s[dot:]
+		}
+		return parseDigits(digits, fraction, loc)
+	case v.isString():
+		s = strings.TrimSpace(s)
+		if s != "" && strings.Trim(s, "0123456789") == "" {
+			return parseDigits(s, "", loc)
+		}
+		s = strings.Replace(s, "T", " ", 1)
+	default:
+		return time.Time{}, fmt.Errorf("sqltypes.temporal.unsupported.type[%v]", v.typ)
+	}
+	if isZeroTemporal(s) {
+		return time.Time{}, nil
+	}
+	layout := DateLayout
+	if len(s) > len(DateLayout) {
+		layout = DatetimeLayout
+		if dot := strings.IndexByte(s, '.'); dot >= 0 {
+			layout += "." + strings.Repeat("0", len(s)-dot-1)
+		}
+	}
+	t, err := time.ParseInLocation(layout, s, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("sqltypes.temporal.invalid.value[%s]", s)
+	}
+	return t, nil
+}
+
+// parseDigits parses the digits of the YYMMDD, YYYYMMDD, YYMMDDhhmmss or YYYYMMDDhhmmss with the fraction like '.123'.
+func parseDigits(digits, fraction string, loc *time.Location) (time.Time, error) {
+	if strings.Trim(digits, "0") == "" && strings.Trim(fraction, "0.") == "" {
+		return time.Time{}, nil
+	}
+	switch len(digits) {
+	case 6, 12:
+		// The 2-digit years like MySQL.
+		if digits[0] < '7' {
+			digits = "20" + digits
+		} else {
+			digits = "19" + digits
+		}
+	case 8, 14:
+	default:
+		return time.Time{}, fmt.Errorf("sqltypes.temporal.invalid.value[%s%s]", digits, fraction)
+	}
+	layout := "20060102"
+	if len(digits) == 14 {
+		layout = "20060102150405"
+		if len(fraction) > 1 {
+			layout += "." + strings.Repeat("0", len(fraction)-1)
+		} else {
+			fraction = ""
+		}
+	} else if strings.Trim(fraction, "0.") != "" {
+		return time.Time{}, fmt.Errorf("sqltypes.temporal.invalid.value[%s%s]", digits, fraction)
+	} else {
+		fraction = ""
+	}
+	t, err := time.ParseInLocation(layout, digits+fraction, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("sqltypes.temporal.invalid.value[%s%s]", digits, fraction)
+	}
+	return t, nil
+}
+
+// ConvertTimeZone converts the TIMESTAMP value in the zone from to the zone to, keeps the fractional digits.
+// The other values and the zero TIMESTAMP are returned as they are, MySQL only converts the TIMESTAMP.
+func ConvertTimeZone(v Value, from, to *time.Location) (Value, error) {
+	if v.typ != Timestamp || isZeroTemporal(string(v.val)) {
+		return v, nil
+	}
+	t, err := v.ToTime(from)
+	if err != nil {
+		return NULL, err
+	}
+	fsp := 0
+	if dot := strings.IndexByte(string(v.val), '.'); dot >= 0 {
+		fsp = len(v.val) - dot - 1
+	}
+	return NewTemporal(Timestamp, t, to, fsp)
+}
+
+func isZeroTemporal(s string) bool {
+	return strings.Trim(s, "0-:. ") == ""
+}
@@ -0,0 +1,115 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqltypes
+
+import (
+	"testing"
+	"time"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestNewTemporal(t *testing.T) {
+	shanghai := time.FixedZone("+08:00", 8*3600)
+	tm := time.Date(2018, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	testcases := []struct {
+		typ  querypb.Type
+		loc  *time.Location
+		fsp  int
+		want string
+	}{
+		{Timestamp, shanghai, 3, "2018-01-02 11:04:05.123"},
+		{Datetime, time.UTC, 0, "2018-01-02 03:04:05"},
+		{Date, shanghai, 0, "2018-01-02"},
+	}
+	for _, tcase := range testcases {
+		v, err := NewTemporal(tcase.typ, tm, tcase.loc, tcase.fsp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != tcase.want || v.Type() != tcase.typ {
+			t.Errorf("NewTemporal(%v): %v, want %s", tcase.typ, v, tcase.want)
+		}
+	}
+
+	if _, err := NewTemporal(Timestamp, tm, time.UTC, 7); err == nil {
+		t.Errorf("NewTemporal(fsp 7): nil, want error")
+	}
+	if _, err := NewTemporal(Int64, tm, time.UTC, 0); err == nil {
+		t.Errorf("NewTemporal(Int64): nil, want error")
+	}
+}
+
+func TestToTime(t *testing.T) {
+	shanghai := time.FixedZone("+08:00", 8*3600)
+	testcases := []struct {
+		in   Value
+		loc  *time.Location
+		want time.Time
+	}{
+		{MakeTrusted(Timestamp, []byte("2018-01-02 11:04:05.123")), shanghai, time.Date(2018, 1, 2, 3, 4, 5, 123000000, time.UTC)},
+		{MakeTrusted(Date, []byte("2018-01-02")), time.UTC, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{MakeTrusted(Timestamp, []byte("0000-00-00 00:00:00")), time.UTC, time.Time{}},
+		// The strings and the numbers like MySQL.
+		{NewVarChar("2018-01-02"), time.UTC, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{NewVarChar(" 2018-01-02T03:04:05.5 "), time.UTC, time.Date(2018, 1, 2, 3, 4, 5, 500000000, time.UTC)},
+		{NewVarChar("180102"), time.UTC, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{NewVarChar("700102030405"), time.UTC, time.Date(1970, 1, 2, 3, 4, 5, 0, time.UTC)},
+		{NewInt64(20180102030405), shanghai, time.Date(2018, 1, 1, 19, 4, 5, 0, time.UTC)},
+		{NewUint64(20180102), time.UTC, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{testVal(Float64, "20180102030405.25"), time.UTC, time.Date(2018, 1, 2, 3, 4, 5, 250000000, time.UTC)},
+		{NewFloat64(20180102), time.UTC, time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{NewInt64(0), time.UTC, time.Time{}},
+		{NewVarChar("0000-00-00"), time.UTC, time.Time{}},
+	}
+	for _, tcase := range testcases {
+		got, err := tcase.in.ToTime(tcase.loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !got.Equal(tcase.want) {
+			t.Errorf("ToTime(%v): %v, want %v", tcase.in, got, tcase.want)
+		}
+	}
+
+	for _, in := range []Value{MakeTrusted(Timestamp, []byte("2018-13-02 00:00:00")), NewVarChar("2018-01-02 x"), NewInt64(7), NewVarChar("201801021"), MakeTrusted(Time, []byte("03:04:05")), NULL} {
+		if _, err := in.ToTime(time.UTC); err == nil {
+			t.Errorf("ToTime(%v): nil, want error", in)
+		}
+	}
+}
+
+func TestConvertTimeZone(t *testing.T) {
+	shanghai := time.FixedZone("+08:00", 8*3600)
+	testcases := []struct {
+		in   Value
+		want string
+	}{
+		{MakeTrusted(Timestamp, []byte("2018-01-02 03:04:05")), "2018-01-02 11:04:05"},
+		{MakeTrusted(Timestamp, []byte("2018-01-01 20:00:00.500000")), "2018-01-02 04:00:00.500000"},
+		{MakeTrusted(Timestamp, []byte("0000-00-00 00:00:00")), "0000-00-00 00:00:00"},
+		// Only the TIMESTAMP is converted.
+		{MakeTrusted(Datetime, []byte("2018-01-02 03:04:05")), "2018-01-02 03:04:05"},
+		{NewVarChar("2018-01-02 03:04:05"), "2018-01-02 03:04:05"},
+	}
+	for _, tcase := range testcases {
+		v, err := ConvertTimeZone(tcase.in, time.UTC, shanghai)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != tcase.want || v.Type() != tcase.in.Type() {
+			t.Errorf("ConvertTimeZone(%v): %v, want %s", tcase.in, v, tcase.want)
+		}
+	}
+
+	if _, err := ConvertTimeZone(MakeTrusted(Timestamp, []byte("x")), time.UTC, shanghai); err == nil {
+		t.Errorf("ConvertTimeZone(x): nil, want error")
+	}
+}
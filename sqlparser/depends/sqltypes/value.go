@@ -236,6 +236,15 @@ func (v Value) Len() int {
 	return len(v.val)
 }
 
+// Copy returns the value with its own copy of the bytes,
+// the values referencing a buffer reused like the ones of the lazy rows are copied to be retained.
+func (v Value) Copy() Value {
+	if v.val == nil {
+		return v
+	}
+	return Value{typ: v.typ, val: append(make([]byte, 0, len(v.val)), v.val...)}
+}
+
 type Values []Value
 
 func (vs Values) Len() int {
@@ -625,6 +625,27 @@ func TestEncode(t *testing.T) {
 	}
 }
 
+func TestValueCopy(t *testing.T) {
+	buf := []byte("abcd")
+	v := MakeTrusted(VarChar, buf)
+	c := v.Copy()
+	copy(buf, "wxyz")
+	if got := string(c.Raw()); got != "abcd" {
+		t.Errorf("Copy() = %q, want abcd", got)
+	}
+	if got := NULL.Copy(); !reflect.DeepEqual(got, NULL) {
+		t.Errorf("NULL.Copy() = %v, want null", makePretty(got))
+	}
+
+	row := Row{MakeTrusted(Int64, buf[:1]), NULL, MakeTrusted(VarChar, buf[1:])}
+	rc := row.DeepCopy()
+	copy(buf, "abcd")
+	want := []Value{testVal(Int64, "w"), NULL, testVal(VarChar, "xyz")}
+	if !reflect.DeepEqual(rc, want) {
+		t.Errorf("DeepCopy() = %v, want %v", rc, want)
+	}
+}
+
 // TestEncodeMap ensures DontEscape is not escaped
 func TestEncodeMap(t *testing.T) {
 	if SQLEncodeMap[DontEscape] != DontEscape {
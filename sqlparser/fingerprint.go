@@ -0,0 +1,53 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Fingerprint returns the query with the literals replaced by the '?', the comments removed,
+// the keywords and names lowercased and the blanks collapsed, the queries differ in the values only share it.
+func Fingerprint(sql string) string {
+	var buf bytes.Buffer
+	tkn := NewStringTokenizer(sql)
+	prev := 0
+	for {
+		typ, _ := tkn.Scan()
+		// The tokenizer looks one char ahead, the token ends before it.
+		end := tkn.Position - 1
+		if end > len(sql) {
+			end = len(sql)
+		}
+		text := strings.TrimSpace(sql[prev:end])
+		prev = end
+
+		switch typ {
+		case 0:
+			return buf.String()
+		case COMMENT:
+			continue
+		case LEX_ERROR:
+			text = strings.TrimSpace(sql[end-len(text):])
+		case STRING, INTEGRAL, FLOAT, HEX, HEXNUM, VALUE_ARG:
+			text = "?"
+		default:
+			text = strings.ToLower(text)
+		}
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(text)
+		if typ == LEX_ERROR {
+			return buf.String()
+		}
+	}
+}
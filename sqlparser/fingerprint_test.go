@@ -0,0 +1,36 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"select 1", "select ?"},
+		{"SELECT  a,b FROM t1\n WHERE id = 10 AND name = 'x''y'", "select a , b from t1 where id = ? and name = ?"},
+		{"select a from t1 where id=-1.5e3 or b = 0x1f or c = x'ab'", "select a from t1 where id = - ? or b = ? or c = ?"},
+		{"/* leading */ select `A` from t1 where b in (1, 2) -- trailing\n", "select `a` from t1 where b in ( ? , ? )"},
+		{"insert into t1(a, b) values (?, :name)", "insert into t1 ( a , b ) values ( ? , ? )"},
+		{"select a from t1 where b >= 1 and c != \"s\" and d <=> null", "select a from t1 where b >= ? and c != ? and d <=> null"},
+		{"select 'unterminated", "select 'unterminated"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, Fingerprint(test.sql), test.sql)
+	}
+	assert.Equal(t, Fingerprint("SELECT * FROM t1 WHERE id = 1"), Fingerprint("select *   from t1 where id=2"))
+}
@@ -0,0 +1,46 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var maxExecutionTimeRegexp = regexp.MustCompile(`(?i)(^|[^a-z0-9_])max_execution_time\s*\(\s*(\d+)\s*\)`)
+
+// MaxExecutionTime returns the milliseconds of the /*+ MAX_EXECUTION_TIME(n) */ optimizer hint,
+// it's recognized in the hint comments right after the SELECT keyword only, as MySQL does.
+func MaxExecutionTime(sql string) (uint64, bool) {
+	sql = StripLeadingComments(sql)
+	if len(sql) < 7 || !strings.EqualFold(sql[:6], "select") || !(unicode.IsSpace(rune(sql[6])) || sql[6] == '/') {
+		return 0, false
+	}
+
+	rest := strings.TrimLeftFunc(sql[6:], unicode.IsSpace)
+	for strings.HasPrefix(rest, "/*") {
+		end := strings.Index(rest[2:], "*/")
+		if end == -1 {
+			return 0, false
+		}
+		comment := rest[2 : 2+end]
+		if strings.HasPrefix(comment, "+") {
+			if m := maxExecutionTimeRegexp.FindStringSubmatch(comment); m != nil {
+				if ms, err := strconv.ParseUint(m[2], 10, 64); err == nil {
+					return ms, true
+				}
+			}
+		}
+		rest = strings.TrimLeftFunc(rest[2+end+2:], unicode.IsSpace)
+	}
+	return 0, false
+}
@@ -0,0 +1,41 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxExecutionTime(t *testing.T) {
+	tests := []struct {
+		sql string
+		ms  uint64
+		ok  bool
+	}{
+		{"select /*+ MAX_EXECUTION_TIME(1000) */ * from t1", 1000, true},
+		{"SELECT/*+ max_execution_time ( 50 ) */ a FROM t1", 50, true},
+		{"/* leading */ SELECT /*+ BKA(t1) MAX_EXECUTION_TIME(20) */ a FROM t1", 20, true},
+		{"select /* note */ /*+ MAX_EXECUTION_TIME(30) */ 1", 30, true},
+		{"select /* MAX_EXECUTION_TIME(30) */ 1", 0, false},
+		{"select a from t1 where b = '/*+ MAX_EXECUTION_TIME(30) */'", 0, false},
+		{"select /*+ NO_MAX_EXECUTION_TIME(30) */ 1", 0, false},
+		{"update t1 set a = 1 /*+ MAX_EXECUTION_TIME(30) */", 0, false},
+		{"selectx /*+ MAX_EXECUTION_TIME(30) */ 1", 0, false},
+		{"select /*+ MAX_EXECUTION_TIME(30) 1", 0, false},
+		{"select 1", 0, false},
+	}
+	for _, test := range tests {
+		ms, ok := MaxExecutionTime(test.sql)
+		assert.Equal(t, test.ok, ok, test.sql)
+		assert.Equal(t, test.ms, ms, test.sql)
+	}
+}
@@ -0,0 +1,46 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+// SkipLiteral returns the offset of the last byte of the quoted string, the quoted identifier or the comment
+// starts at the i of the sql, it's the i if there is none of them and the len(sql) if it's not terminated.
+// The doubled quotes are kept in the literal, the '\' escapes in the strings unless the NO_BACKSLASH_ESCAPES
+// is set and the ANSI_QUOTES makes the '"' quote the identifiers which have no escapes.
+// The '-- ' comment must be followed by a space or a control character like the MySQL lexer.
+func SkipLiteral(sql string, i int, mode SQLMode) int {
+	switch c := sql[i]; {
+	case c == '\'' || c == '"' || c == '`':
+		escapes := c != '`' && !(c == '"' && mode&ModeANSIQuotes != 0) && mode&ModeNoBackslashEscapes == 0
+		for i++; i < len(sql); i++ {
+			if sql[i] == '\\' && escapes {
+				i++
+			} else if sql[i] == c {
+				if i+1 < len(sql) && sql[i+1] == c {
+					i++
+					continue
+				}
+				return i
+			}
+		}
+		return len(sql)
+	case c == '#' || (c == '-' && i+1 < len(sql) && sql[i+1] == '-' && (i+2 == len(sql) || sql[i+2] <= ' ')):
+		for ; i < len(sql) && sql[i] != '\n'; i++ {
+		}
+		return i
+	case c == '/' && i+1 < len(sql) && sql[i+1] == '*':
+		for i += 2; i+1 < len(sql); i++ {
+			if sql[i] == '*' && sql[i+1] == '/' {
+				return i + 1
+			}
+		}
+		return len(sql)
+	}
+	return i
+}
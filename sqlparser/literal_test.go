@@ -0,0 +1,43 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipLiteral(t *testing.T) {
+	tests := []struct {
+		sql  string
+		mode SQLMode
+		want int
+	}{
+		{"a", 0, 0},
+		{"'abc' x", 0, 4},
+		{"'a''b' x", 0, 5},
+		{`'a\'b' x`, 0, 5},
+		{`'a\'b' x`, ModeNoBackslashEscapes, 3},
+		{`"a\"b" x`, ModeANSIQuotes, 3},
+		{"`a\\`b", 0, 3},
+		{"'abc", 0, 4},
+		{"# c\nx", 0, 3},
+		{"-- c\nx", 0, 4},
+		{"--\tc\nx", 0, 4},
+		{"--c", 0, 0},
+		{"/* c */ x", 0, 6},
+		{"/* c", 0, 4},
+		{"/*", 0, 2},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, SkipLiteral(test.sql, 0, test.mode), test.sql)
+	}
+}
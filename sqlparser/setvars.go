@@ -0,0 +1,449 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The scopes of the SetVar.
+const (
+	SetScopeSession = "session"
+	SetScopeGlobal  = "global"
+	SetScopeUser    = "user"
+
+	// SetScopeNextTransaction is the SET TRANSACTION without the SESSION or GLOBAL,
+	// it applies to the next transaction only.
+	SetScopeNextTransaction = "next_transaction"
+)
+
+// The kinds of the SetVar value.
+const (
+	SetValueString = iota
+	SetValueNumber
+	SetValueIdent
+	SetValueExpr
+)
+
+// SetVar is one assignment of the SET statement.
+type SetVar struct {
+	Scope string
+	// Name is lowercased, the SET NAMES is 'names' and the SET CHARACTER SET is 'charset'.
+	Name string
+	// Value is the unquoted string, the number, the identifier or the raw expression text.
+	Value string
+	Kind  int
+}
+
+// Bool returns the value of the boolean variable like autocommit.
+func (v *SetVar) Bool() (bool, error) {
+	switch strings.ToLower(v.Value) {
+	case "1", "on", "true":
+		return true, nil
+	case "0", "off", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("setvars.variable[%s].can't.be.set.to.the.value.of[%s]", v.Name, v.Value)
+}
+
+// isolationLevels maps the SET TRANSACTION ISOLATION LEVEL to the transaction_isolation values.
+var isolationLevels = map[string]string{
+	"repeatable read":  "REPEATABLE-READ",
+	"read committed":   "READ-COMMITTED",
+	"read uncommitted": "READ-UNCOMMITTED",
+	"serializable":     "SERIALIZABLE",
+}
+
+// ParseSetVars parses the SET statement into the assignments,
+// includes the @@ system variables, the @ user variables, the NAMES, the CHARACTER SET and the TRANSACTION.
+// The scope keyword applies to the following assignments until another one, as MySQL does.
+func ParseSetVars(sql string) ([]*SetVar, error) {
+	s := &setScanner{sql: strings.TrimSpace(StripLeadingComments(sql))}
+	s.sql = strings.TrimRight(s.sql, "; \t\r\n")
+	if !strings.EqualFold(s.word(), "set") {
+		return nil, fmt.Errorf("setvars.not.a.set.statement[%s]", sql)
+	}
+
+	var vars []*SetVar
+	scope := SetScopeSession
+	for {
+		parsed, err := s.assignment(&scope)
+		if err != nil {
+			return nil, fmt.Errorf("setvars.syntax.error[%s]:%v", sql, err)
+		}
+		vars = append(vars, parsed...)
+
+		s.skipBlank()
+		if s.eof() {
+			return vars, nil
+		}
+		if !s.consume(',') {
+			return nil, fmt.Errorf("setvars.syntax.error[%s]:unexpected.at.position.%d", sql, s.pos)
+		}
+	}
+}
+
+type setScanner struct {
+	sql string
+	pos int
+}
+
+func (s *setScanner) eof() bool {
+	return s.pos >= len(s.sql)
+}
+
+func (s *setScanner) peek() byte {
+	if s.eof() {
+		return 0
+	}
+	return s.sql[s.pos]
+}
+
+func (s *setScanner) skipBlank() {
+	for !s.eof() {
+		switch s.sql[s.pos] {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+		default:
+			return
+		}
+	}
+}
+
+func (s *setScanner) consume(ch byte) bool {
+	s.skipBlank()
+	if s.peek() == ch {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+func isWordChar(ch byte) bool {
+	return ch == '_' || ch == '$' || (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || (ch >= '0' && ch <= '9') || ch >= 0x80
+}
+
+// word scans the identifier, the backquoted one is unquoted.
+func (s *setScanner) word() string {
+	s.skipBlank()
+	if s.peek() == '`' {
+		if v, err := s.quoted('`'); err == nil {
+			return v
+		}
+		return ""
+	}
+	start := s.pos
+	for !s.eof() && isWordChar(s.sql[s.pos]) {
+		s.pos++
+	}
+	return s.sql[start:s.pos]
+}
+
+// keyword scans the next word if it equals the kw.
+func (s *setScanner) keyword(kw string) bool {
+	pos := s.pos
+	if strings.EqualFold(s.word(), kw) {
+		return true
+	}
+	s.pos = pos
+	return false
+}
+
+// quoted scans the string by the quote, the doubled quote and the backslash escapes are unescaped.
+func (s *setScanner) quoted(quote byte) (string, error) {
+	var buf []byte
+	s.pos++
+	for !s.eof() {
+		ch := s.sql[s.pos]
+		s.pos++
+		switch {
+		case ch == quote:
+			if s.peek() == quote {
+				buf = append(buf, quote)
+				s.pos++
+				continue
+			}
+			return string(buf), nil
+		case ch == '\\' && quote != '`' && !s.eof():
+			next := s.sql[s.pos]
+			s.pos++
+			switch next {
+			case 'n':
+				buf = append(buf, '\n')
+			case 't':
+				buf = append(buf, '\t')
+			case 'r':
+				buf = append(buf, '\r')
+			case '0':
+				buf = append(buf, 0)
+			default:
+				buf = append(buf, next)
+			}
+		default:
+			buf = append(buf, ch)
+		}
+	}
+	return "", fmt.Errorf("unterminated.quoted.string")
+}
+
+// assignment scans one assignment, the SET TRANSACTION may yield more than one.
+func (s *setScanner) assignment(scope *string) ([]*SetVar, error) {
+	s.skipBlank()
+	switch {
+	case strings.HasPrefix(s.sql[s.pos:], "@@"):
+		s.pos += 2
+		// The @@ scope doesn't apply to the following assignments.
+		varScope := SetScopeSession
+		name := s.word()
+		if s.peek() == '.' {
+			switch strings.ToLower(name) {
+			case "session", "local":
+			case "global", "persist", "persist_only":
+				varScope = SetScopeGlobal
+			default:
+				return nil, fmt.Errorf("unknown.scope[%s]", name)
+			}
+			s.pos++
+			name = s.word()
+		}
+		return s.value(varScope, name)
+	case s.peek() == '@':
+		s.pos++
+		var name string
+		switch s.peek() {
+		case '\'', '"':
+			v, err := s.quoted(s.peek())
+			if err != nil {
+				return nil, err
+			}
+			name = v
+		default:
+			name = s.word()
+		}
+		return s.value(SetScopeUser, name)
+	}
+
+	pos := s.pos
+	switch strings.ToLower(s.word()) {
+	case "session", "local":
+		*scope = SetScopeSession
+		if s.keyword("transaction") {
+			return s.transaction(SetScopeSession)
+		}
+	case "global", "persist", "persist_only":
+		*scope = SetScopeGlobal
+		if s.keyword("transaction") {
+			return s.transaction(SetScopeGlobal)
+		}
+	case "transaction":
+		return s.transaction(SetScopeNextTransaction)
+	case "names":
+		return s.names()
+	case "charset":
+		return s.charset()
+	case "character":
+		if s.keyword("set") {
+			return s.charset()
+		}
+		s.pos = pos
+	default:
+		s.pos = pos
+	}
+	return s.value(*scope, s.word())
+}
+
+// value scans the '= expr' or ':= expr' of the variable.
+func (s *setScanner) value(scope string, name string) ([]*SetVar, error) {
+	if name == "" {
+		return nil, fmt.Errorf("missing.variable.name.at.position.%d", s.pos)
+	}
+	s.skipBlank()
+	if strings.HasPrefix(s.sql[s.pos:], ":=") {
+		s.pos += 2
+	} else if !s.consume('=') {
+		return nil, fmt.Errorf("missing.'='.after.variable[%s]", name)
+	}
+	v, err := s.expr()
+	if err != nil {
+		return nil, err
+	}
+	v.Scope = scope
+	v.Name = strings.ToLower(name)
+	return []*SetVar{v}, nil
+}
+
+// expr scans the value until the ',' out of the parentheses.
+func (s *setScanner) expr() (*SetVar, error) {
+	s.skipBlank()
+	start := s.pos
+	depth := 0
+	tokens := 0
+	var str string
+	var quoted bool
+
+	for !s.eof() {
+		ch := s.peek()
+		if ch == ',' && depth == 0 {
+			break
+		}
+		switch ch {
+		case ' ', '\t', '\r', '\n':
+			s.pos++
+			continue
+		case '\'', '"':
+			v, err := s.quoted(ch)
+			if err != nil {
+				return nil, err
+			}
+			str, quoted = v, true
+		case '`':
+			if _, err := s.quoted(ch); err != nil {
+				return nil, err
+			}
+		case '(':
+			depth++
+			s.pos++
+		case ')':
+			if depth == 0 {
+				return nil, fmt.Errorf("unbalanced.parentheses")
+			}
+			depth--
+			s.pos++
+		default:
+			if isWordChar(ch) {
+				s.word()
+			} else {
+				s.pos++
+			}
+		}
+		tokens++
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced.parentheses")
+	}
+
+	raw := strings.TrimSpace(s.sql[start:s.pos])
+	if raw == "" {
+		return nil, fmt.Errorf("missing.value.at.position.%d", s.pos)
+	}
+	switch {
+	case tokens == 1 && quoted:
+		return &SetVar{Value: str, Kind: SetValueString}, nil
+	case isNumber(raw):
+		return &SetVar{Value: raw, Kind: SetValueNumber}, nil
+	case tokens == 1 && isWordChar(raw[0]):
+		return &SetVar{Value: raw, Kind: SetValueIdent}, nil
+	}
+	return &SetVar{Value: raw, Kind: SetValueExpr}, nil
+}
+
+func isNumber(raw string) bool {
+	if _, err := strconv.ParseFloat(raw, 64); err != nil {
+		return false
+	}
+	// ParseFloat accepts the 'inf' and 'nan'.
+	ch := raw[len(raw)-1]
+	return ch >= '0' && ch <= '9' || ch == '.'
+}
+
+// names scans the 'NAMES {charset [COLLATE collation] | DEFAULT}'.
+func (s *setScanner) names() ([]*SetVar, error) {
+	v, err := s.charsetValue()
+	if err != nil {
+		return nil, err
+	}
+	vars := []*SetVar{{Scope: SetScopeSession, Name: "names", Value: v.Value, Kind: v.Kind}}
+	if s.keyword("collate") {
+		c, err := s.charsetValue()
+		if err != nil {
+			return nil, err
+		}
+		vars = append(vars, &SetVar{Scope: SetScopeSession, Name: "collation_connection", Value: c.Value, Kind: c.Kind})
+	}
+	return vars, nil
+}
+
+// charset scans the '{CHARACTER SET | CHARSET} {charset | DEFAULT}'.
+func (s *setScanner) charset() ([]*SetVar, error) {
+	v, err := s.charsetValue()
+	if err != nil {
+		return nil, err
+	}
+	return []*SetVar{{Scope: SetScopeSession, Name: "charset", Value: v.Value, Kind: v.Kind}}, nil
+}
+
+// charsetValue scans the charset or the collation name, quoted or not.
+func (s *setScanner) charsetValue() (*SetVar, error) {
+	s.skipBlank()
+	switch ch := s.peek(); ch {
+	case '\'', '"', '`':
+		v, err := s.quoted(ch)
+		if err != nil {
+			return nil, err
+		}
+		return &SetVar{Value: v, Kind: SetValueString}, nil
+	}
+	if w := s.word(); w != "" {
+		return &SetVar{Value: w, Kind: SetValueIdent}, nil
+	}
+	return nil, fmt.Errorf("missing.charset.at.position.%d", s.pos)
+}
+
+// transaction scans the 'TRANSACTION characteristic [, characteristic]...',
+// the characteristics are ISOLATION LEVEL level, READ WRITE and READ ONLY.
+func (s *setScanner) transaction(scope string) ([]*SetVar, error) {
+	var vars []*SetVar
+	for {
+		switch {
+		case s.keyword("isolation"):
+			if !s.keyword("level") {
+				return nil, fmt.Errorf("missing.'level'.after.'isolation'")
+			}
+			first := strings.ToLower(s.word())
+			level := first
+			if first == "repeatable" || first == "read" {
+				level += " " + strings.ToLower(s.word())
+			}
+			value, ok := isolationLevels[level]
+			if !ok {
+				return nil, fmt.Errorf("unknown.isolation.level[%s]", level)
+			}
+			vars = append(vars, &SetVar{Scope: scope, Name: "transaction_isolation", Value: value, Kind: SetValueString})
+		case s.keyword("read"):
+			var value string
+			switch strings.ToLower(s.word()) {
+			case "only":
+				value = "1"
+			case "write":
+				value = "0"
+			default:
+				return nil, fmt.Errorf("missing.'only'.or.'write'.after.'read'")
+			}
+			vars = append(vars, &SetVar{Scope: scope, Name: "transaction_read_only", Value: value, Kind: SetValueNumber})
+		default:
+			return nil, fmt.Errorf("unknown.transaction.characteristic.at.position.%d", s.pos)
+		}
+
+		// The ',' may separate the characteristics or the assignments.
+		pos := s.pos
+		if !s.consume(',') {
+			return vars, nil
+		}
+		next := s.pos
+		w := strings.ToLower(s.word())
+		s.pos = next
+		if w != "isolation" && w != "read" {
+			s.pos = pos
+			return vars, nil
+		}
+	}
+}
@@ -0,0 +1,124 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSetVars(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []*SetVar
+	}{
+		{
+			"SET autocommit=0",
+			[]*SetVar{{Scope: SetScopeSession, Name: "autocommit", Value: "0", Kind: SetValueNumber}},
+		},
+		{
+			"/* c */ set @@SESSION.AutoCommit = ON;",
+			[]*SetVar{{Scope: SetScopeSession, Name: "autocommit", Value: "ON", Kind: SetValueIdent}},
+		},
+		{
+			"SET GLOBAL max_connections = 1000, sort_buffer_size = -1, LOCAL sql_mode='a,b'",
+			[]*SetVar{
+				{Scope: SetScopeGlobal, Name: "max_connections", Value: "1000", Kind: SetValueNumber},
+				{Scope: SetScopeGlobal, Name: "sort_buffer_size", Value: "-1", Kind: SetValueNumber},
+				{Scope: SetScopeSession, Name: "sql_mode", Value: "a,b", Kind: SetValueString},
+			},
+		},
+		{
+			"SET @@global.wait_timeout=10, @@autocommit=1",
+			[]*SetVar{
+				{Scope: SetScopeGlobal, Name: "wait_timeout", Value: "10", Kind: SetValueNumber},
+				{Scope: SetScopeSession, Name: "autocommit", Value: "1", Kind: SetValueNumber},
+			},
+		},
+		{
+			"SET @A := 'it''s', @`b`=CONCAT('x', ','), @'c'=NULL",
+			[]*SetVar{
+				{Scope: SetScopeUser, Name: "a", Value: "it's", Kind: SetValueString},
+				{Scope: SetScopeUser, Name: "b", Value: "CONCAT('x', ',')", Kind: SetValueExpr},
+				{Scope: SetScopeUser, Name: "c", Value: "NULL", Kind: SetValueIdent},
+			},
+		},
+		{
+			"SET NAMES 'utf8mb4' COLLATE utf8mb4_bin",
+			[]*SetVar{
+				{Scope: SetScopeSession, Name: "names", Value: "utf8mb4", Kind: SetValueString},
+				{Scope: SetScopeSession, Name: "collation_connection", Value: "utf8mb4_bin", Kind: SetValueIdent},
+			},
+		},
+		{
+			"SET CHARACTER SET utf8, CHARSET DEFAULT",
+			[]*SetVar{
+				{Scope: SetScopeSession, Name: "charset", Value: "utf8", Kind: SetValueIdent},
+				{Scope: SetScopeSession, Name: "charset", Value: "DEFAULT", Kind: SetValueIdent},
+			},
+		},
+		{
+			"SET SESSION TRANSACTION ISOLATION LEVEL READ COMMITTED, READ ONLY",
+			[]*SetVar{
+				{Scope: SetScopeSession, Name: "transaction_isolation", Value: "READ-COMMITTED", Kind: SetValueString},
+				{Scope: SetScopeSession, Name: "transaction_read_only", Value: "1", Kind: SetValueNumber},
+			},
+		},
+		{
+			"SET TRANSACTION READ WRITE, read_buffer_size=1",
+			[]*SetVar{
+				{Scope: SetScopeNextTransaction, Name: "transaction_read_only", Value: "0", Kind: SetValueNumber},
+				{Scope: SetScopeSession, Name: "read_buffer_size", Value: "1", Kind: SetValueNumber},
+			},
+		},
+		{
+			"SET GLOBAL TRANSACTION ISOLATION LEVEL SERIALIZABLE",
+			[]*SetVar{{Scope: SetScopeGlobal, Name: "transaction_isolation", Value: "SERIALIZABLE", Kind: SetValueString}},
+		},
+	}
+	for _, test := range tests {
+		got, err := ParseSetVars(test.sql)
+		assert.Nil(t, err, test.sql)
+		assert.Equal(t, test.want, got, test.sql)
+	}
+
+	bads := []string{
+		"SELECT 1",
+		"SET",
+		"SET autocommit",
+		"SET autocommit=",
+		"SET a=1,",
+		"SET a=(1",
+		"SET a='x",
+		"SET @@foo.a=1",
+		"SET TRANSACTION ISOLATION LEVEL CHAOS",
+		"SET TRANSACTION READ",
+	}
+	for _, bad := range bads {
+		_, err := ParseSetVars(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestSetVarBool(t *testing.T) {
+	for _, v := range []string{"1", "ON", "true"} {
+		b, err := (&SetVar{Name: "autocommit", Value: v}).Bool()
+		assert.Nil(t, err)
+		assert.True(t, b)
+	}
+	for _, v := range []string{"0", "off", "FALSE"} {
+		b, err := (&SetVar{Name: "autocommit", Value: v}).Bool()
+		assert.Nil(t, err)
+		assert.False(t, b)
+	}
+	_, err := (&SetVar{Name: "autocommit", Value: "2"}).Bool()
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,166 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SQLMode is the bitmask of the sql_mode, the bits are the same as the MySQL server.
+type SQLMode uint64
+
+// The sql_mode bits.
+const (
+	ModeRealAsFloat SQLMode = 1 << iota
+	ModePipesAsConcat
+	ModeANSIQuotes
+	ModeIgnoreSpace
+	modeNotUsed
+	ModeOnlyFullGroupBy
+	ModeNoUnsignedSubtraction
+	ModeNoDirInCreate
+	ModePostgreSQL
+	ModeOracle
+	ModeMSSQL
+	ModeDB2
+	ModeMaxDB
+	ModeNoKeyOptions
+	ModeNoTableOptions
+	ModeNoFieldOptions
+	ModeMySQL323
+	ModeMySQL40
+	ModeANSI
+	ModeNoAutoValueOnZero
+	ModeNoBackslashEscapes
+	ModeStrictTransTables
+	ModeStrictAllTables
+	ModeNoZeroInDate
+	ModeNoZeroDate
+	ModeInvalidDates
+	ModeErrorForDivisionByZero
+	ModeTraditional
+	ModeNoAutoCreateUser
+	ModeHighNotPrecedence
+	ModeNoEngineSubstitution
+	ModePadCharToFullLength
+	ModeTimeTruncateFractional
+)
+
+const (
+	// DefaultSQLMode is the MySQL 5.7 default.
+	DefaultSQLMode = ModeOnlyFullGroupBy | ModeStrictTransTables | ModeNoZeroInDate | ModeNoZeroDate |
+		ModeErrorForDivisionByZero | ModeNoAutoCreateUser | ModeNoEngineSubstitution
+)
+
+// sqlModeNames are in the bit order.
+var sqlModeNames = []string{
+	"REAL_AS_FLOAT",
+	"PIPES_AS_CONCAT",
+	"ANSI_QUOTES",
+	"IGNORE_SPACE",
+	"NOT_USED",
+	"ONLY_FULL_GROUP_BY",
+	"NO_UNSIGNED_SUBTRACTION",
+	"NO_DIR_IN_CREATE",
+	"POSTGRESQL",
+	"ORACLE",
+	"MSSQL",
+	"DB2",
+	"MAXDB",
+	"NO_KEY_OPTIONS",
+	"NO_TABLE_OPTIONS",
+	"NO_FIELD_OPTIONS",
+	"MYSQL323",
+	"MYSQL40",
+	"ANSI",
+	"NO_AUTO_VALUE_ON_ZERO",
+	"NO_BACKSLASH_ESCAPES",
+	"STRICT_TRANS_TABLES",
+	"STRICT_ALL_TABLES",
+	"NO_ZERO_IN_DATE",
+	"NO_ZERO_DATE",
+	"INVALID_DATES",
+	"ERROR_FOR_DIVISION_BY_ZERO",
+	"TRADITIONAL",
+	"NO_AUTO_CREATE_USER",
+	"HIGH_NOT_PRECEDENCE",
+	"NO_ENGINE_SUBSTITUTION",
+	"PAD_CHAR_TO_FULL_LENGTH",
+	"TIME_TRUNCATE_FRACTIONAL",
+}
+
+// sqlModeCombinations are the modes expanded to the others, like MySQL does.
+var sqlModeCombinations = map[SQLMode]SQLMode{
+	ModeANSI:        ModeRealAsFloat | ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeOnlyFullGroupBy,
+	ModeTraditional: ModeStrictTransTables | ModeStrictAllTables | ModeNoZeroInDate | ModeNoZeroDate | ModeErrorForDivisionByZero | ModeNoAutoCreateUser | ModeNoEngineSubstitution,
+	ModeDB2:         ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeNoKeyOptions | ModeNoTableOptions | ModeNoFieldOptions,
+	ModeMaxDB:       ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeNoKeyOptions | ModeNoTableOptions | ModeNoFieldOptions | ModeNoAutoCreateUser,
+	ModeMSSQL:       ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeNoKeyOptions | ModeNoTableOptions | ModeNoFieldOptions,
+	ModeOracle:      ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeNoKeyOptions | ModeNoTableOptions | ModeNoFieldOptions | ModeNoAutoCreateUser,
+	ModePostgreSQL:  ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeNoKeyOptions | ModeNoTableOptions | ModeNoFieldOptions,
+	ModeMySQL323:    ModeHighNotPrecedence,
+	ModeMySQL40:     ModeHighNotPrecedence,
+}
+
+// ParseSQLMode parses the comma separated modes like 'STRICT_TRANS_TABLES,ANSI_QUOTES', case insensitive.
+// The combination modes like ANSI and TRADITIONAL are expanded.
+func ParseSQLMode(s string) (SQLMode, error) {
+	var mode SQLMode
+	for _, name := range strings.Split(s, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		bit, ok := sqlModeBit(name)
+		if !ok {
+			return 0, fmt.Errorf("sqlmode.unknown[%s]", name)
+		}
+		mode |= bit | sqlModeCombinations[bit]
+	}
+	return mode, nil
+}
+
+func sqlModeBit(name string) (SQLMode, bool) {
+	for i, n := range sqlModeNames {
+		if n == name && SQLMode(1)<<uint(i) != modeNotUsed {
+			return SQLMode(1) << uint(i), true
+		}
+	}
+	return 0, false
+}
+
+// Has checks all the bits of the m are set.
+func (mode SQLMode) Has(m SQLMode) bool {
+	return mode&m == m
+}
+
+// String returns the modes in the @@sql_mode format.
+func (mode SQLMode) String() string {
+	var names []string
+	for i, name := range sqlModeNames {
+		if mode&(SQLMode(1)<<uint(i)) != 0 {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// ParseWithSQLMode parses the sql by the lexical rules of the sql_mode,
+// the ANSI_QUOTES makes the '"' quote the identifiers and the NO_BACKSLASH_ESCAPES keeps the '\' in strings.
+func ParseWithSQLMode(sql string, mode SQLMode) (Statement, error) {
+	tokenizer := NewStringTokenizer(sql)
+	tokenizer.SQLMode = mode
+	if yyParse(tokenizer) != 0 {
+		return nil, errors.New(tokenizer.LastError)
+	}
+	return tokenizer.ParseTree, nil
+}
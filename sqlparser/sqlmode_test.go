@@ -0,0 +1,72 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSQLMode(t *testing.T) {
+	tests := []struct {
+		in   string
+		want SQLMode
+		str  string
+	}{
+		{"", 0, ""},
+		{"ansi_quotes, NO_BACKSLASH_ESCAPES", ModeANSIQuotes | ModeNoBackslashEscapes, "ANSI_QUOTES,NO_BACKSLASH_ESCAPES"},
+		{"ANSI", ModeANSI | ModeRealAsFloat | ModePipesAsConcat | ModeANSIQuotes | ModeIgnoreSpace | ModeOnlyFullGroupBy, "REAL_AS_FLOAT,PIPES_AS_CONCAT,ANSI_QUOTES,IGNORE_SPACE,ONLY_FULL_GROUP_BY,ANSI"},
+		{
+			"ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION",
+			DefaultSQLMode,
+			"ONLY_FULL_GROUP_BY,STRICT_TRANS_TABLES,NO_ZERO_IN_DATE,NO_ZERO_DATE,ERROR_FOR_DIVISION_BY_ZERO,NO_AUTO_CREATE_USER,NO_ENGINE_SUBSTITUTION",
+		},
+	}
+	for _, test := range tests {
+		got, err := ParseSQLMode(test.in)
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.want, got, test.in)
+		assert.Equal(t, test.str, got.String(), test.in)
+	}
+
+	mode, err := ParseSQLMode("TRADITIONAL")
+	assert.Nil(t, err)
+	assert.True(t, mode.Has(ModeStrictAllTables|ModeTraditional))
+	assert.False(t, mode.Has(ModeANSIQuotes))
+
+	for _, bad := range []string{"ANSI_QUOTE", "NOT_USED", "STRICT_TRANS_TABLES,xx"} {
+		_, err := ParseSQLMode(bad)
+		assert.NotNil(t, err, bad)
+	}
+}
+
+func TestParseWithSQLMode(t *testing.T) {
+	tests := []struct {
+		sql  string
+		mode SQLMode
+		want string
+	}{
+		{`select "a" from t`, 0, "select 'a' from t"},
+		{`select "a" from t`, ModeANSIQuotes, "select a from t"},
+		{`select "a""b", 'c' from "t"`, ModeANSIQuotes, "select `a\"b`, 'c' from t"},
+		{`select 'a\'b' from t`, 0, "select 'a\\'b' from t"},
+		{`select 'a\' from t`, ModeNoBackslashEscapes, "select 'a\\\\' from t"},
+	}
+	for _, test := range tests {
+		stmt, err := ParseWithSQLMode(test.sql, test.mode)
+		assert.Nil(t, err, test.sql)
+		assert.Equal(t, test.want, String(stmt), test.sql)
+	}
+
+	// The backslash escapes the quote by default.
+	_, err := ParseWithSQLMode(`select 'a\' from t`, 0)
+	assert.NotNil(t, err)
+}
@@ -41,6 +41,9 @@ type Tokenizer struct {
 	ParseTree     Statement
 	partialDDL    *DDL
 	nesting       int
+
+	// SQLMode changes the lexical rules by the ANSI_QUOTES and the NO_BACKSLASH_ESCAPES.
+	SQLMode SQLMode
 }
 
 // NewStringTokenizer creates a new Tokenizer for the
@@ -502,10 +505,15 @@ func (tkn *Tokenizer) Scan() (int, []byte) {
 				return NE, nil
 			}
 			return int(ch), nil
-		case '\'', '"':
+		case '"':
+			if tkn.SQLMode&ModeANSIQuotes != 0 {
+				return tkn.scanLiteralIdentifier('"')
+			}
+			return tkn.scanString(ch, STRING)
+		case '\'':
 			return tkn.scanString(ch, STRING)
 		case '`':
-			return tkn.scanLiteralIdentifier()
+			return tkn.scanLiteralIdentifier('`')
 		default:
 			return LEX_ERROR, []byte{byte(ch)}
 		}
@@ -552,22 +560,22 @@ func (tkn *Tokenizer) scanHex() (int, []byte) {
 	return HEX, buffer.Bytes()
 }
 
-func (tkn *Tokenizer) scanLiteralIdentifier() (int, []byte) {
+func (tkn *Tokenizer) scanLiteralIdentifier(quote uint16) (int, []byte) {
 	buffer := &bytes2.Buffer{}
 	backTickSeen := false
 	for {
 		if backTickSeen {
-			if tkn.lastChar != '`' {
+			if tkn.lastChar != quote {
 				break
 			}
 			backTickSeen = false
-			buffer.WriteByte('`')
+			buffer.WriteByte(byte(quote))
 			tkn.next()
 			continue
 		}
 		// The previous char was not a backtick.
 		switch tkn.lastChar {
-		case '`':
+		case quote:
 			backTickSeen = true
 		case eofChar:
 			// Premature EOF.
@@ -668,7 +676,7 @@ func (tkn *Tokenizer) scanString(delim uint16, typ int) (int, []byte) {
 			} else {
 				break
 			}
-		} else if ch == '\\' {
+		} else if ch == '\\' && tkn.SQLMode&ModeNoBackslashEscapes == 0 {
 			if tkn.lastChar == eofChar {
 				return LEX_ERROR, buffer.Bytes()
 			}
@@ -0,0 +1,172 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// UserVarLookup returns the value of the user variable by the lowercased name, false if it's not defined.
+type UserVarLookup func(name string) (sqltypes.Value, bool)
+
+// SubstituteUserVars replaces the @var references of the sql by their literal values, the undefined ones by NULL.
+// The assignment targets like 'SET @x = ...', '@x := ...' and 'INTO @x' are kept, so are the quoted strings,
+// the comments and the account names like 'u'@'host'. The mode decides the quoting of the '"' and the '\'.
+func SubstituteUserVars(sql string, mode SQLMode, lookup UserVarLookup) string {
+	s := &userVarScanner{sql: sql, mode: mode, lookup: lookup}
+	return s.substitute()
+}
+
+type userVarScanner struct {
+	sql    string
+	mode   SQLMode
+	lookup UserVarLookup
+	buf    bytes.Buffer
+}
+
+func (s *userVarScanner) substitute() string {
+	sql := s.sql
+	isSet := Preview(sql) == StmtSet
+	// target is true at the start of a SET assignment or in the INTO list.
+	target := false
+	into := false
+	depth := 0
+
+	for i := 0; i < len(sql); {
+		ch := sql[i]
+		// The quoted strings, the quoted identifiers and the comments.
+		if j := s.skipLiteral(i); j != i {
+			s.buf.WriteString(sql[i:j])
+			i = j
+			if ch == '\'' || ch == '"' || ch == '`' {
+				into = false
+			}
+			continue
+		}
+		switch {
+		case ch == '@':
+			// The account name like 'u'@'host' or u@host.
+			if i > 0 && (isWordChar(sql[i-1]) || sql[i-1] == '\'' || sql[i-1] == '"' || sql[i-1] == '`') {
+				s.buf.WriteByte(ch)
+				i++
+				continue
+			}
+			if strings.HasPrefix(sql[i:], "@@") {
+				j := i + 2
+				for j < len(sql) && (isWordChar(sql[j]) || sql[j] == '.') {
+					j++
+				}
+				s.buf.WriteString(sql[i:j])
+				i = j
+				target = false
+				continue
+			}
+			name, j := s.userVarName(i + 1)
+			if target || into || isAssignment(sql[j:]) {
+				s.buf.WriteString(sql[i:j])
+			} else {
+				s.writeValue(name)
+			}
+			i = j
+			target = false
+		case isWordChar(ch):
+			j := i
+			for j < len(sql) && isWordChar(sql[j]) {
+				j++
+			}
+			word := strings.ToLower(sql[i:j])
+			s.buf.WriteString(sql[i:j])
+			i = j
+			into = word == "into"
+			switch word {
+			case "set":
+				target = isSet && depth == 0
+			case "session", "global", "local", "persist", "persist_only":
+			default:
+				target = false
+			}
+		default:
+			switch ch {
+			case '(':
+				depth++
+			case ')':
+				depth--
+			case ',':
+				if isSet && depth == 0 {
+					target = true
+				}
+			case ' ', '\t', '\r', '\n':
+			default:
+				into = false
+			}
+			s.buf.WriteByte(ch)
+			i++
+		}
+	}
+	return s.buf.String()
+}
+
+// skipLiteral returns the position after the quoted string, the quoted identifier or the comment starts at i,
+// it's the i if there is none of them.
+func (s *userVarScanner) skipLiteral(i int) int {
+	j := SkipLiteral(s.sql, i, s.mode)
+	if j == i || j == len(s.sql) {
+		return j
+	}
+	return j + 1
+}
+
+// userVarName returns the lowercased name starts at i and the position after it.
+func (s *userVarScanner) userVarName(i int) (string, int) {
+	if i < len(s.sql) {
+		switch quote := s.sql[i]; quote {
+		case '\'', '"', '`':
+			j := s.skipLiteral(i)
+			name := strings.Trim(s.sql[i:j], string(quote))
+			name = strings.Replace(name, string([]byte{quote, quote}), string(quote), -1)
+			return strings.ToLower(name), j
+		}
+	}
+	j := i
+	for j < len(s.sql) && (isWordChar(s.sql[j]) || s.sql[j] == '.') {
+		j++
+	}
+	return strings.ToLower(s.sql[i:j]), j
+}
+
+// writeValue writes the literal of the variable, the strings are quoted by the mode.
+func (s *userVarScanner) writeValue(name string) {
+	v, ok := s.lookup(name)
+	if !ok || v.IsNull() {
+		s.buf.WriteString("NULL")
+		return
+	}
+	EncodeValue(&s.buf, v, s.mode)
+}
+
+// EncodeValue writes the SQL literal of the value by the sql_mode, the strings are escaped by the backslashes
+// or quoted by doubling the quotes if the NO_BACKSLASH_ESCAPES is set as it keeps the '\' in strings.
+func EncodeValue(buf *bytes.Buffer, v sqltypes.Value, mode SQLMode) {
+	if sqltypes.IsQuoted(v.Type()) && mode&ModeNoBackslashEscapes != 0 {
+		buf.WriteByte('\'')
+		buf.WriteString(strings.Replace(v.String(), "'", "''", -1))
+		buf.WriteByte('\'')
+		return
+	}
+	v.EncodeSQL(buf)
+}
+
+// isAssignment checks the rest starts with the ':='.
+func isAssignment(rest string) bool {
+	return strings.HasPrefix(strings.TrimLeft(rest, " \t\r\n"), ":=")
+}
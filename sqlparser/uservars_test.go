@@ -0,0 +1,57 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package sqlparser
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubstituteUserVars(t *testing.T) {
+	vars := map[string]sqltypes.Value{
+		"a":   sqltypes.NewInt64(1),
+		"b":   sqltypes.NewVarChar("it's"),
+		"x.y": sqltypes.NewInt64(2),
+	}
+	lookup := func(name string) (sqltypes.Value, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+
+	tests := []struct {
+		mode SQLMode
+		in   string
+		out  string
+	}{
+		{0, "select @a, @B, @c", "select 1, 'it\\'s', NULL"},
+		{0, "select * from t where a=@a and b in (@`b`, @'x.y')", "select * from t where a=1 and b in ('it\\'s', 2)"},
+		{0, "select @@autocommit, @@session.sql_mode, @a", "select @@autocommit, @@session.sql_mode, 1"},
+		// The assignment targets are kept.
+		{0, "set @a = @b, @c := @a + 1", "set @a = 'it\\'s', @c := 1 + 1"},
+		{0, "set session sql_mode = '', @c = f(@a, @b)", "set session sql_mode = '', @c = f(1, 'it\\'s')"},
+		{0, "select @c := @a + 1", "select @c := 1 + 1"},
+		{0, "select a into @c, @d from t where b=@a", "select a into @c, @d from t where b=1"},
+		// The strings, the comments and the account names are not touched.
+		{0, "select '@a', \"@a\", `@a` /* @a */ from t -- @a", "select '@a', \"@a\", `@a` /* @a */ from t -- @a"},
+		{0, "select 'x\\'@a', @a # @a", "select 'x\\'@a', 1 # @a"},
+		{0, "grant all on *.* to 'u'@'%', u@localhost", "grant all on *.* to 'u'@'%', u@localhost"},
+		// The NO_BACKSLASH_ESCAPES quotes by doubling.
+		{ModeNoBackslashEscapes, "select 'x\\', @b", "select 'x\\', 'it''s'"},
+		// The ANSI_QUOTES makes the '"' quote an identifier which has no escapes.
+		{0, "select \"@a\\\", @a\"", "select \"@a\\\", @a\""},
+		{ModeANSIQuotes, "select \"@a\\\", @a", "select \"@a\\\", 1"},
+	}
+	for _, test := range tests {
+		got := SubstituteUserVars(test.in, test.mode, lookup)
+		assert.Equal(t, test.out, got, test.in)
+	}
+}
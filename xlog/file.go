@@ -0,0 +1,70 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xlog
+
+import (
+	"os"
+	"sync"
+)
+
+// FileWriter is the log file, Reopen reopens the path once the logrotate moved it away.
+type FileWriter struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// OpenFileWriter opens the path for appending, it's created if not exists.
+func OpenFileWriter(path string) (*FileWriter, error) {
+	w := &FileWriter{path: path}
+	if err := w.Reopen(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// NewFileLog creates the Log writing to the file of the path.
+func NewFileLog(path string, opts ...Option) (*Log, error) {
+	w, err := OpenFileWriter(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewXLog(w, opts...), nil
+}
+
+// Write impl.
+func (w *FileWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Write(p)
+}
+
+// Reopen closes the file and opens the path again.
+func (w *FileWriter) Reopen() error {
+	f, err := os.OpenFile(w.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	old := w.file
+	w.file = f
+	w.mu.Unlock()
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+// Close closes the file.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.file.Close()
+}
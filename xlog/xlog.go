@@ -15,6 +15,7 @@ import (
 	"log"
 	"log/syslog"
 	"os"
+	"sync/atomic"
 )
 
 var (
@@ -46,7 +47,9 @@ const (
 )
 
 type Log struct {
-	opts *Options
+	// The level is changed at runtime by the SetLevel, it's first for the alignment of the atomic.
+	level int32
+	opts  *Options
 	*log.Logger
 }
 
@@ -67,7 +70,8 @@ func NewXLog(w io.Writer, opts ...Option) *Log {
 	options := newOptions(opts...)
 
 	l := &Log{
-		opts: options,
+		level: int32(options.Level),
+		opts:  options,
 	}
 	l.Logger = log.New(w, l.opts.Name, D_LOG_FLAGS)
 	defaultlog = l
@@ -88,38 +92,52 @@ func GetLog() *Log {
 	return defaultlog
 }
 
-func (t *Log) SetLevel(level string) {
+// ParseLevel returns the level of the name like "WARNING", false if it's unknown.
+func ParseLevel(name string) (LogLevel, bool) {
 	for i, v := range LevelNames {
-		if level == v {
-			t.opts.Level = LogLevel(i)
-			return
+		if v != "" && name == v {
+			return LogLevel(i), true
 		}
 	}
+	return 0, false
+}
+
+// SetLevel sets the level by the name, the unknown one is ignored.
+// It's safe to call while the log is written.
+func (t *Log) SetLevel(level string) {
+	if l, ok := ParseLevel(level); ok {
+		atomic.StoreInt32(&t.level, int32(l))
+	}
+}
+
+// Level returns the level of the log.
+func (t *Log) Level() LogLevel {
+	return LogLevel(atomic.LoadInt32(&t.level))
 }
 
 func (t *Log) Debug(format string, v ...interface{}) {
-	if DEBUG < t.opts.Level {
+	if DEBUG < t.Level() {
 		return
 	}
 	t.log("\t  [DEBUG]  \t%s", fmt.Sprintf(format, v...))
 }
 
 func (t *Log) Info(format string, v ...interface{}) {
-	if INFO < t.opts.Level {
+	if INFO < t.Level() {
 		return
 	}
 	t.log("\t  [INFO]  \t%s", fmt.Sprintf(format, v...))
 }
 
 func (t *Log) Warning(format string, v ...interface{}) {
-	if WARNING < t.opts.Level {
+	if WARNING < t.Level() {
 		return
 	}
 	t.log("\t  [WARNING]  \t%s", fmt.Sprintf(format, v...))
 }
 
 func (t *Log) Error(format string, v ...interface{}) {
-	if ERROR < t.opts.Level {
+	if ERROR < t.Level() {
 		return
 	}
 	msg := fmt.Sprintf(format, v...)
@@ -130,7 +148,7 @@ func (t *Log) Error(format string, v ...interface{}) {
 }
 
 func (t *Log) Fatal(format string, v ...interface{}) {
-	if FATAL < t.opts.Level {
+	if FATAL < t.Level() {
 		return
 	}
 	t.log("\t  [FATAL+EXIT]  \t%s", fmt.Sprintf(format, v...))
@@ -138,7 +156,7 @@ func (t *Log) Fatal(format string, v ...interface{}) {
 }
 
 func (t *Log) Panic(format string, v ...interface{}) {
-	if PANIC < t.opts.Level {
+	if PANIC < t.Level() {
 		return
 	}
 	msg := fmt.Sprintf("\t  [PANIC]  \t %s", fmt.Sprintf(format, v...))
@@ -150,6 +168,14 @@ func (t *Log) Close() {
 	// nothing
 }
 
+// Reopen reopens the log file like the FLUSH LOGS, it's a noop if the log doesn't write to the FileWriter.
+func (t *Log) Reopen() error {
+	if w, ok := t.Writer().(*FileWriter); ok {
+		return w.Reopen()
+	}
+	return nil
+}
+
 func (t *Log) log(format string, v ...interface{}) {
 	t.Output(3, fmt.Sprintf(format, v...)+"\n")
 }
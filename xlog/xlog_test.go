@@ -10,6 +10,10 @@
 package xlog
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -104,28 +108,57 @@ func TestLogLevel(t *testing.T) {
 	{
 		log.SetLevel("DEBUG")
 		want := DEBUG
-		got := log.opts.Level
+		got := log.Level()
 		Assert(t, want == got, "want[%v]!=got[%v]", want, got)
 	}
 
 	{
 		log.SetLevel("DEBUGX")
 		want := DEBUG
-		got := log.opts.Level
+		got := log.Level()
 		Assert(t, want == got, "want[%v]!=got[%v]", want, got)
 	}
 
 	{
 		log.SetLevel("PANIC")
 		want := PANIC
-		got := log.opts.Level
+		got := log.Level()
 		Assert(t, want == got, "want[%v]!=got[%v]", want, got)
 	}
 
 	{
 		log.SetLevel("WARNING")
 		want := WARNING
-		got := log.opts.Level
+		got := log.Level()
 		Assert(t, want == got, "want[%v]!=got[%v]", want, got)
 	}
 }
+
+func TestFileLogReopen(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xlog")
+	Assert(t, err == nil, "%v", err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "server.log")
+
+	log, err := NewFileLog(path)
+	Assert(t, err == nil, "%v", err)
+	log.Info("BEFORE")
+
+	// The logrotate moves the file away.
+	rotated := path + ".1"
+	Assert(t, os.Rename(path, rotated) == nil, "rename")
+	log.Info("MOVED")
+	Assert(t, log.Reopen() == nil, "reopen")
+	log.Info("AFTER")
+	log.Writer().(*FileWriter).Close()
+
+	old, err := ioutil.ReadFile(rotated)
+	Assert(t, err == nil, "%v", err)
+	Assert(t, strings.Contains(string(old), "BEFORE") && strings.Contains(string(old), "MOVED"), "old[%s]", old)
+	cur, err := ioutil.ReadFile(path)
+	Assert(t, err == nil, "%v", err)
+	Assert(t, strings.Contains(string(cur), "AFTER") && !strings.Contains(string(cur), "MOVED"), "cur[%s]", cur)
+
+	// The std log isn't reopened.
+	Assert(t, NewStdLog().Reopen() == nil, "std")
+}
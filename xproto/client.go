@@ -0,0 +1,269 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Conn is the X Protocol client connection.
+type Conn struct {
+	conn         net.Conn
+	packets      *Packets
+	connectionID uint64
+	closed       bool
+}
+
+// NewConn connects to the address like '127.0.0.1:33060' and authenticates by MYSQL41.
+func NewConn(address string, user string, password string, schema string) (*Conn, error) {
+	netConn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	c := &Conn{conn: netConn, packets: NewPackets(netConn)}
+	if err := c.authenticate(user, password, schema); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Conn) authenticate(user string, password string, schema string) error {
+	start := &AuthenticateStart{MechName: "MYSQL41"}
+	if err := c.packets.Write(SESS_AUTHENTICATE_START, start.Pack()); err != nil {
+		return err
+	}
+	payload, err := c.expect(SESS_AUTHENTICATE_CONTINUE_SERVER)
+	if err != nil {
+		return err
+	}
+	challenge := &AuthData{}
+	if err := challenge.UnPack(payload); err != nil {
+		return err
+	}
+
+	var resp bytes.Buffer
+	resp.WriteString(schema)
+	resp.WriteByte(0)
+	resp.WriteString(user)
+	resp.WriteByte(0)
+	if password != "" {
+		resp.WriteByte('*')
+		resp.WriteString(strings.ToUpper(hex.EncodeToString(proto.NativePassword(password, challenge.Data))))
+	}
+	if err := c.packets.Write(SESS_AUTHENTICATE_CONTINUE, (&AuthData{Data: resp.Bytes()}).Pack()); err != nil {
+		return err
+	}
+	_, err = c.expect(SESS_AUTHENTICATE_OK)
+	return err
+}
+
+// next reads the next message, the notices are handled by the fn if not nil,
+// and the ERROR is returned as the *sqldb.SQLError.
+func (c *Conn) next(fn func(*Notice) error) (byte, []byte, error) {
+	for {
+		typ, payload, err := c.packets.Next()
+		if err != nil {
+			c.Cleanup()
+			return 0, nil, err
+		}
+		switch typ {
+		case NOTICE:
+			notice := &Notice{}
+			if err := notice.UnPack(payload); err != nil {
+				return 0, nil, err
+			}
+			if err := c.handleNotice(notice, fn); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case ERROR:
+			e := &Error{}
+			if err := e.UnPack(payload); err != nil {
+				return 0, nil, err
+			}
+			if e.Severity == SeverityFatal {
+				c.Cleanup()
+			}
+			return 0, nil, e.SQLError()
+		}
+		return typ, payload, nil
+	}
+}
+
+func (c *Conn) handleNotice(notice *Notice, fn func(*Notice) error) error {
+	if notice.Type == NoticeSessionStateChanged {
+		param, value, err := notice.SessionStateChanged()
+		if err != nil {
+			return err
+		}
+		if param == StateClientIDAssigned && value != nil {
+			c.connectionID = value.UInt
+		}
+	}
+	if fn != nil {
+		return fn(notice)
+	}
+	return nil
+}
+
+// expect reads the next message which must be the typ.
+func (c *Conn) expect(typ byte) ([]byte, error) {
+	got, payload, err := c.next(nil)
+	if err != nil {
+		return nil, err
+	}
+	if got != typ {
+		return nil, fmt.Errorf("xproto.unexpected.message[%d].want[%d]", got, typ)
+	}
+	return payload, nil
+}
+
+// ConnectionID returns the id assigned by the server.
+func (c *Conn) ConnectionID() uint64 {
+	return c.connectionID
+}
+
+// StmtExecute executes the statement and fetches the first result set.
+func (c *Conn) StmtExecute(stmt *StmtExecute) (*sqltypes.Result, error) {
+	if err := c.packets.Write(SQL_STMT_EXECUTE, stmt.Pack()); err != nil {
+		return nil, err
+	}
+	return c.readResult()
+}
+
+// FetchAll executes the SQL and fetches all rows.
+func (c *Conn) FetchAll(sql string) (*sqltypes.Result, error) {
+	return c.StmtExecute(&StmtExecute{Namespace: "sql", Stmt: sql})
+}
+
+// Exec executes the SQL without the rows.
+func (c *Conn) Exec(sql string) error {
+	_, err := c.FetchAll(sql)
+	return err
+}
+
+// Ping runs the mysqlx ping admin command.
+func (c *Conn) Ping() error {
+	_, err := c.StmtExecute(&StmtExecute{Namespace: "mysqlx", Stmt: "ping"})
+	return err
+}
+
+// Crud sends the crud message as-is and fetches the result.
+func (c *Conn) Crud(crud *Crud) (*sqltypes.Result, error) {
+	if err := c.packets.Write(crud.Type, crud.Payload); err != nil {
+		return nil, err
+	}
+	return c.readResult()
+}
+
+// readResult reads the result until the STMT_EXECUTE_OK,
+// the result sets after the first one are drained.
+func (c *Conn) readResult() (*sqltypes.Result, error) {
+	var columns []*ColumnMetaData
+	qr := &sqltypes.Result{}
+	done := false
+	onNotice := func(notice *Notice) error {
+		if notice.Type != NoticeSessionStateChanged {
+			return nil
+		}
+		param, value, err := notice.SessionStateChanged()
+		if err != nil || value == nil {
+			return err
+		}
+		switch param {
+		case StateRowsAffected:
+			qr.RowsAffected = value.UInt
+		case StateGeneratedInsertID:
+			qr.InsertID = value.UInt
+		}
+		return nil
+	}
+
+	for {
+		typ, payload, err := c.next(onNotice)
+		if err != nil {
+			return nil, err
+		}
+		switch typ {
+		case RESULTSET_COLUMN_META_DATA:
+			if done {
+				continue
+			}
+			col := &ColumnMetaData{}
+			if err := col.UnPack(payload); err != nil {
+				return nil, err
+			}
+			columns = append(columns, col)
+			qr.Fields = append(qr.Fields, col.Field())
+		case RESULTSET_ROW:
+			if done {
+				continue
+			}
+			fields, err := unpackRow(payload)
+			if err != nil {
+				return nil, err
+			}
+			if len(fields) != len(columns) {
+				return nil, fmt.Errorf("xproto.row.fields[%d].mismatch.columns[%d]", len(fields), len(columns))
+			}
+			row := make([]sqltypes.Value, 0, len(fields))
+			for i, data := range fields {
+				v, err := decodeField(data, columns[i], qr.Fields[i].Type)
+				if err != nil {
+					return nil, err
+				}
+				row = append(row, v)
+			}
+			qr.Rows = append(qr.Rows, row)
+		case RESULTSET_FETCH_DONE, RESULTSET_FETCH_DONE_MORE_RESULTSETS, RESULTSET_FETCH_DONE_MORE_OUT_PARAMS, RESULTSET_FETCH_SUSPENDED:
+			done = len(columns) > 0
+		case SQL_STMT_EXECUTE_OK:
+			return qr, nil
+		default:
+			return nil, fmt.Errorf("xproto.unexpected.message[%d]", typ)
+		}
+	}
+}
+
+// Cleanup closes the connection without the CON_CLOSE.
+func (c *Conn) Cleanup() {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	c.closed = true
+}
+
+// Closed checks the connection is closed.
+func (c *Conn) Closed() bool {
+	return c.closed
+}
+
+// Close sends the CON_CLOSE and closes the connection.
+func (c *Conn) Close() error {
+	if c.closed {
+		return nil
+	}
+	defer c.Cleanup()
+	if err := c.packets.Write(CON_CLOSE, nil); err != nil {
+		return err
+	}
+	_, err := c.expect(OK)
+	return err
+}
@@ -0,0 +1,830 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"fmt"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+)
+
+// The client message types.
+// https://dev.mysql.com/doc/internals/en/x-protocol-messages-messages.html
+const (
+	CON_CAPABILITIES_GET       = 1
+	CON_CAPABILITIES_SET       = 2
+	CON_CLOSE                  = 3
+	SESS_AUTHENTICATE_START    = 4
+	SESS_AUTHENTICATE_CONTINUE = 5
+	SESS_RESET                 = 6
+	SESS_CLOSE                 = 7
+	SQL_STMT_EXECUTE           = 12
+	CRUD_FIND                  = 17
+	CRUD_INSERT                = 18
+	CRUD_UPDATE                = 19
+	CRUD_DELETE                = 20
+	EXPECT_OPEN                = 24
+	EXPECT_CLOSE               = 25
+)
+
+// The server message types, the SESS_AUTHENTICATE_CONTINUE is suffixed to not clash with the client one.
+const (
+	OK                                   = 0
+	ERROR                                = 1
+	CONN_CAPABILITIES                    = 2
+	SESS_AUTHENTICATE_CONTINUE_SERVER    = 3
+	SESS_AUTHENTICATE_OK                 = 4
+	NOTICE                               = 11
+	RESULTSET_COLUMN_META_DATA           = 12
+	RESULTSET_ROW                        = 13
+	RESULTSET_FETCH_DONE                 = 14
+	RESULTSET_FETCH_SUSPENDED            = 15
+	RESULTSET_FETCH_DONE_MORE_RESULTSETS = 16
+	SQL_STMT_EXECUTE_OK                  = 17
+	RESULTSET_FETCH_DONE_MORE_OUT_PARAMS = 18
+)
+
+// The X Protocol errors, the classic ones are in sqldb.
+const (
+	ER_UNKNOWN_COM_ERROR             = 1047
+	ER_X_BAD_MESSAGE                 = 5000
+	ER_X_CAPABILITIES_PREPARE_FAILED = 5001
+	ER_X_INVALID_NAMESPACE           = 5162
+	ER_X_INVALID_ADMIN_COMMAND       = 5163
+)
+
+// The error severities.
+const (
+	SeverityError = 0
+	SeverityFatal = 1
+)
+
+// Ok is the Mysqlx.Ok message.
+type Ok struct {
+	Msg string
+}
+
+// Pack packs the message.
+func (o *Ok) Pack() []byte {
+	e := &encoder{}
+	if o.Msg != "" {
+		e.string(1, o.Msg)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message.
+func (o *Ok) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.num == 1 {
+			o.Msg = string(f.data)
+		}
+	}
+	return nil
+}
+
+// Error is the Mysqlx.Error message, the session is closed after a fatal one.
+type Error struct {
+	Severity uint32
+	Code     uint32
+	Msg      string
+	SQLState string
+}
+
+// NewError creates the error message from the error, the non-SQLError is ER_UNKNOWN_ERROR.
+func NewError(err error, severity uint32) *Error {
+	se, ok := err.(*sqldb.SQLError)
+	if !ok {
+		se = sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "%v", err)
+	}
+	return &Error{Severity: severity, Code: uint32(se.Num), Msg: se.Message, SQLState: se.State}
+}
+
+// SQLError returns the error as the *sqldb.SQLError.
+func (e *Error) SQLError() *sqldb.SQLError {
+	return sqldb.NewSQLError1(uint16(e.Code), e.SQLState, "%s", e.Msg)
+}
+
+// Pack packs the message.
+func (e *Error) Pack() []byte {
+	enc := &encoder{}
+	enc.uvarint(1, uint64(e.Severity))
+	enc.uvarint(2, uint64(e.Code))
+	enc.string(3, e.Msg)
+	enc.string(4, e.SQLState)
+	return enc.buf
+}
+
+// UnPack unpacks the message.
+func (e *Error) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			e.Severity = uint32(f.varint)
+		case 2:
+			e.Code = uint32(f.varint)
+		case 3:
+			e.Msg = string(f.data)
+		case 4:
+			e.SQLState = string(f.data)
+		}
+	}
+	return nil
+}
+
+// The Mysqlx.Datatypes.Scalar types.
+const (
+	V_SINT   = 1
+	V_UINT   = 2
+	V_NULL   = 3
+	V_OCTETS = 4
+	V_DOUBLE = 5
+	V_FLOAT  = 6
+	V_BOOL   = 7
+	V_STRING = 8
+)
+
+// Scalar is the Mysqlx.Datatypes.Scalar, only the field of the Type is used.
+type Scalar struct {
+	Type        uint32
+	SInt        int64
+	UInt        uint64
+	Octets      []byte
+	ContentType uint32
+	Double      float64
+	Float       float32
+	Bool        bool
+	String      string
+}
+
+func (s *Scalar) pack() []byte {
+	e := &encoder{}
+	e.uvarint(1, uint64(s.Type))
+	switch s.Type {
+	case V_SINT:
+		e.svarint(2, s.SInt)
+	case V_UINT:
+		e.uvarint(3, s.UInt)
+	case V_OCTETS:
+		o := &encoder{}
+		o.bytes(1, s.Octets)
+		if s.ContentType != 0 {
+			o.uvarint(2, uint64(s.ContentType))
+		}
+		e.bytes(5, o.buf)
+	case V_DOUBLE:
+		e.double(6, s.Double)
+	case V_FLOAT:
+		e.float(7, s.Float)
+	case V_BOOL:
+		e.bool(8, s.Bool)
+	case V_STRING:
+		o := &encoder{}
+		o.string(1, s.String)
+		e.bytes(9, o.buf)
+	}
+	return e.buf
+}
+
+func (s *Scalar) unpack(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			s.Type = uint32(f.varint)
+		case 2:
+			s.SInt = f.svarint()
+		case 3:
+			s.UInt = f.varint
+		case 5:
+			octets, err := decodeFields(f.data)
+			if err != nil {
+				return err
+			}
+			for _, o := range octets {
+				switch o.num {
+				case 1:
+					s.Octets = o.data
+				case 2:
+					s.ContentType = uint32(o.varint)
+				}
+			}
+		case 6:
+			s.Double = f.double()
+		case 7:
+			s.Float = f.float()
+		case 8:
+			s.Bool = f.varint != 0
+		case 9:
+			str, err := decodeFields(f.data)
+			if err != nil {
+				return err
+			}
+			for _, o := range str {
+				if o.num == 1 {
+					s.String = string(o.data)
+				}
+			}
+		}
+	}
+	if s.Type < V_SINT || s.Type > V_STRING {
+		return fmt.Errorf("xproto.invalid.scalar.type[%d]", s.Type)
+	}
+	return nil
+}
+
+// The Mysqlx.Datatypes.Any types.
+const (
+	ANY_SCALAR = 1
+	ANY_OBJECT = 2
+	ANY_ARRAY  = 3
+)
+
+// Any is the Mysqlx.Datatypes.Any, the objects are not supported.
+type Any struct {
+	Type   uint32
+	Scalar *Scalar
+	Array  []*Any
+}
+
+// NewScalarAny creates the scalar any.
+func NewScalarAny(s *Scalar) *Any {
+	return &Any{Type: ANY_SCALAR, Scalar: s}
+}
+
+// NewStringAny creates the V_STRING scalar any.
+func NewStringAny(s string) *Any {
+	return NewScalarAny(&Scalar{Type: V_STRING, String: s})
+}
+
+func (a *Any) pack() []byte {
+	e := &encoder{}
+	e.uvarint(1, uint64(a.Type))
+	switch a.Type {
+	case ANY_SCALAR:
+		e.bytes(2, a.Scalar.pack())
+	case ANY_ARRAY:
+		arr := &encoder{}
+		for _, v := range a.Array {
+			arr.bytes(1, v.pack())
+		}
+		e.bytes(4, arr.buf)
+	}
+	return e.buf
+}
+
+func (a *Any) unpack(data []byte) error {
+	fields, err := decodeFields(data)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			a.Type = uint32(f.varint)
+		case 2:
+			a.Scalar = &Scalar{}
+			if err := a.Scalar.unpack(f.data); err != nil {
+				return err
+			}
+		case 4:
+			values, err := decodeFields(f.data)
+			if err != nil {
+				return err
+			}
+			for _, v := range values {
+				if v.num != 1 {
+					continue
+				}
+				elem := &Any{}
+				if err := elem.unpack(v.data); err != nil {
+					return err
+				}
+				a.Array = append(a.Array, elem)
+			}
+		}
+	}
+	switch a.Type {
+	case ANY_SCALAR:
+		if a.Scalar == nil {
+			return fmt.Errorf("xproto.any.scalar.missing")
+		}
+	case ANY_ARRAY:
+	default:
+		return fmt.Errorf("xproto.unsupported.any.type[%d]", a.Type)
+	}
+	return nil
+}
+
+// Capabilities is the Mysqlx.Connection.Capabilities, the CapabilitiesSet carries it in the field 1.
+type Capabilities struct {
+	Names  []string
+	Values []*Any
+}
+
+// Add adds the capability.
+func (c *Capabilities) Add(name string, value *Any) {
+	c.Names = append(c.Names, name)
+	c.Values = append(c.Values, value)
+}
+
+// Get returns the capability by the name, nil if not found.
+func (c *Capabilities) Get(name string) *Any {
+	for i, n := range c.Names {
+		if n == name {
+			return c.Values[i]
+		}
+	}
+	return nil
+}
+
+// Pack packs the message.
+func (c *Capabilities) Pack() []byte {
+	e := &encoder{}
+	for i, name := range c.Names {
+		capability := &encoder{}
+		capability.string(1, name)
+		capability.bytes(2, c.Values[i].pack())
+		e.bytes(1, capability.buf)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message.
+func (c *Capabilities) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.num != 1 {
+			continue
+		}
+		items, err := decodeFields(f.data)
+		if err != nil {
+			return err
+		}
+		var name string
+		value := &Any{}
+		for _, item := range items {
+			switch item.num {
+			case 1:
+				name = string(item.data)
+			case 2:
+				if err := value.unpack(item.data); err != nil {
+					return err
+				}
+			}
+		}
+		c.Add(name, value)
+	}
+	return nil
+}
+
+// AuthenticateStart is the Mysqlx.Session.AuthenticateStart.
+type AuthenticateStart struct {
+	MechName        string
+	AuthData        []byte
+	InitialResponse []byte
+}
+
+// Pack packs the message.
+func (a *AuthenticateStart) Pack() []byte {
+	e := &encoder{}
+	e.string(1, a.MechName)
+	if a.AuthData != nil {
+		e.bytes(2, a.AuthData)
+	}
+	if a.InitialResponse != nil {
+		e.bytes(3, a.InitialResponse)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message.
+func (a *AuthenticateStart) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			a.MechName = string(f.data)
+		case 2:
+			a.AuthData = f.data
+		case 3:
+			a.InitialResponse = f.data
+		}
+	}
+	return nil
+}
+
+// AuthData is the Mysqlx.Session.AuthenticateContinue and AuthenticateOk, both carry the auth data only.
+type AuthData struct {
+	Data []byte
+}
+
+// Pack packs the message.
+func (a *AuthData) Pack() []byte {
+	e := &encoder{}
+	if a.Data != nil {
+		e.bytes(1, a.Data)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message.
+func (a *AuthData) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if f.num == 1 {
+			a.Data = f.data
+		}
+	}
+	return nil
+}
+
+// StmtExecute is the Mysqlx.Sql.StmtExecute.
+type StmtExecute struct {
+	Namespace       string
+	Stmt            string
+	Args            []*Any
+	CompactMetadata bool
+}
+
+// Pack packs the message.
+func (s *StmtExecute) Pack() []byte {
+	e := &encoder{}
+	e.string(1, s.Stmt)
+	for _, arg := range s.Args {
+		e.bytes(2, arg.pack())
+	}
+	if s.Namespace != "" {
+		e.string(3, s.Namespace)
+	}
+	if s.CompactMetadata {
+		e.bool(4, true)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message, the namespace defaults to 'sql'.
+func (s *StmtExecute) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	s.Namespace = "sql"
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			s.Stmt = string(f.data)
+		case 2:
+			arg := &Any{}
+			if err := arg.unpack(f.data); err != nil {
+				return err
+			}
+			s.Args = append(s.Args, arg)
+		case 3:
+			s.Namespace = string(f.data)
+		case 4:
+			s.CompactMetadata = f.varint != 0
+		}
+	}
+	return nil
+}
+
+// The Mysqlx.Crud.DataModel.
+const (
+	DataModelDocument = 1
+	DataModelTable    = 2
+)
+
+// Crud is the Mysqlx.Crud Find, Insert, Update and Delete.
+// Only the collection and the data model are decoded, the payload is kept as-is to be proxied.
+type Crud struct {
+	Type      byte
+	Schema    string
+	Name      string
+	DataModel uint32
+	Payload   []byte
+}
+
+// crudFieldNumbers returns the field numbers of the collection and the data model in the message.
+func crudFieldNumbers(typ byte) (int, int, error) {
+	switch typ {
+	case CRUD_FIND, CRUD_UPDATE:
+		return 2, 3, nil
+	case CRUD_INSERT, CRUD_DELETE:
+		return 1, 2, nil
+	}
+	return 0, 0, fmt.Errorf("xproto.invalid.crud.type[%d]", typ)
+}
+
+// NewCrud creates the crud message on the collection without the criteria, like a full Find or Delete.
+func NewCrud(typ byte, schema string, name string, dataModel uint32) (*Crud, error) {
+	collectionNum, dataModelNum, err := crudFieldNumbers(typ)
+	if err != nil {
+		return nil, err
+	}
+	collection := &encoder{}
+	collection.string(1, name)
+	collection.string(2, schema)
+	e := &encoder{}
+	e.bytes(collectionNum, collection.buf)
+	e.uvarint(dataModelNum, uint64(dataModel))
+	return &Crud{Type: typ, Schema: schema, Name: name, DataModel: dataModel, Payload: e.buf}, nil
+}
+
+// UnPackCrud unpacks the crud message of the type.
+func UnPackCrud(typ byte, payload []byte) (*Crud, error) {
+	collectionNum, dataModelNum, err := crudFieldNumbers(typ)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return nil, err
+	}
+	crud := &Crud{Type: typ, DataModel: DataModelDocument, Payload: payload}
+	for _, f := range fields {
+		switch f.num {
+		case collectionNum:
+			items, err := decodeFields(f.data)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range items {
+				switch item.num {
+				case 1:
+					crud.Name = string(item.data)
+				case 2:
+					crud.Schema = string(item.data)
+				}
+			}
+		case dataModelNum:
+			crud.DataModel = uint32(f.varint)
+		}
+	}
+	if crud.Name == "" {
+		return nil, fmt.Errorf("xproto.crud.collection.missing")
+	}
+	return crud, nil
+}
+
+// The Mysqlx.Resultset.ColumnMetaData.FieldType.
+const (
+	FIELD_TYPE_SINT     = 1
+	FIELD_TYPE_UINT     = 2
+	FIELD_TYPE_DOUBLE   = 5
+	FIELD_TYPE_FLOAT    = 6
+	FIELD_TYPE_BYTES    = 7
+	FIELD_TYPE_TIME     = 10
+	FIELD_TYPE_DATETIME = 12
+	FIELD_TYPE_SET      = 15
+	FIELD_TYPE_ENUM     = 16
+	FIELD_TYPE_BIT      = 17
+	FIELD_TYPE_DECIMAL  = 18
+)
+
+// The content types of the BYTES and DATETIME.
+const (
+	ContentTypeGeometry = 1
+	ContentTypeJSON     = 2
+	ContentTypeXML      = 3
+
+	ContentTypeDate     = 1
+	ContentTypeDatetime = 2
+)
+
+// The column flags, the first bit depends on the type.
+const (
+	FlagUintZerofill      = 0x0001
+	FlagUnsigned          = 0x0001
+	FlagBytesRightpad     = 0x0001
+	FlagDatetimeTimestamp = 0x0001
+	FlagNotNull           = 0x0010
+	FlagPrimaryKey        = 0x0020
+	FlagUniqueKey         = 0x0040
+	FlagMultipleKey       = 0x0080
+	FlagAutoIncrement     = 0x0100
+)
+
+// ColumnMetaData is the Mysqlx.Resultset.ColumnMetaData.
+type ColumnMetaData struct {
+	Type             uint32
+	Name             string
+	OriginalName     string
+	Table            string
+	OriginalTable    string
+	Schema           string
+	Catalog          string
+	Collation        uint64
+	FractionalDigits uint32
+	Length           uint32
+	Flags            uint32
+	ContentType      uint32
+}
+
+// Pack packs the message.
+func (c *ColumnMetaData) Pack() []byte {
+	e := &encoder{}
+	e.uvarint(1, uint64(c.Type))
+	e.string(2, c.Name)
+	e.string(3, c.OriginalName)
+	e.string(4, c.Table)
+	e.string(5, c.OriginalTable)
+	e.string(6, c.Schema)
+	e.string(7, c.Catalog)
+	if c.Collation != 0 {
+		e.uvarint(8, c.Collation)
+	}
+	e.uvarint(9, uint64(c.FractionalDigits))
+	e.uvarint(10, uint64(c.Length))
+	e.uvarint(11, uint64(c.Flags))
+	if c.ContentType != 0 {
+		e.uvarint(12, uint64(c.ContentType))
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message.
+func (c *ColumnMetaData) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			c.Type = uint32(f.varint)
+		case 2:
+			c.Name = string(f.data)
+		case 3:
+			c.OriginalName = string(f.data)
+		case 4:
+			c.Table = string(f.data)
+		case 5:
+			c.OriginalTable = string(f.data)
+		case 6:
+			c.Schema = string(f.data)
+		case 7:
+			c.Catalog = string(f.data)
+		case 8:
+			c.Collation = f.varint
+		case 9:
+			c.FractionalDigits = uint32(f.varint)
+		case 10:
+			c.Length = uint32(f.varint)
+		case 11:
+			c.Flags = uint32(f.varint)
+		case 12:
+			c.ContentType = uint32(f.varint)
+		}
+	}
+	return nil
+}
+
+// packRow packs the Mysqlx.Resultset.Row, the empty field is the NULL.
+func packRow(fields [][]byte) []byte {
+	e := &encoder{}
+	for _, f := range fields {
+		e.bytes(1, f)
+	}
+	return e.buf
+}
+
+func unpackRow(payload []byte) ([][]byte, error) {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return nil, err
+	}
+	row := make([][]byte, 0, len(fields))
+	for _, f := range fields {
+		if f.num == 1 {
+			row = append(row, f.data)
+		}
+	}
+	return row, nil
+}
+
+// The Mysqlx.Notice.Frame types and scopes.
+const (
+	NoticeWarning                = 1
+	NoticeSessionVariableChanged = 2
+	NoticeSessionStateChanged    = 3
+
+	NoticeScopeGlobal = 1
+	NoticeScopeLocal  = 2
+)
+
+// The Mysqlx.Notice.SessionStateChanged parameters.
+const (
+	StateCurrentSchema     = 1
+	StateAccountExpired    = 2
+	StateGeneratedInsertID = 3
+	StateRowsAffected      = 4
+	StateRowsFound         = 5
+	StateRowsMatched       = 6
+	StateTrxCommitted      = 7
+	StateTrxRolledback     = 9
+	StateProducedMessage   = 10
+	StateClientIDAssigned  = 11
+)
+
+// Notice is the Mysqlx.Notice.Frame, the payload is decoded by the type.
+type Notice struct {
+	Type    uint32
+	Scope   uint32
+	Payload []byte
+}
+
+// NewSessionStateChanged creates the local SESSION_STATE_CHANGED notice.
+func NewSessionStateChanged(param uint32, value *Scalar) *Notice {
+	e := &encoder{}
+	e.uvarint(1, uint64(param))
+	e.bytes(2, value.pack())
+	return &Notice{Type: NoticeSessionStateChanged, Scope: NoticeScopeLocal, Payload: e.buf}
+}
+
+// SessionStateChanged decodes the payload of the SESSION_STATE_CHANGED notice.
+func (n *Notice) SessionStateChanged() (uint32, *Scalar, error) {
+	if n.Type != NoticeSessionStateChanged {
+		return 0, nil, fmt.Errorf("xproto.notice[%d].not.session.state.changed", n.Type)
+	}
+	fields, err := decodeFields(n.Payload)
+	if err != nil {
+		return 0, nil, err
+	}
+	var param uint32
+	var value *Scalar
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			param = uint32(f.varint)
+		case 2:
+			if value == nil {
+				value = &Scalar{}
+				if err := value.unpack(f.data); err != nil {
+					return 0, nil, err
+				}
+			}
+		}
+	}
+	return param, value, nil
+}
+
+// Pack packs the message.
+func (n *Notice) Pack() []byte {
+	e := &encoder{}
+	e.uvarint(1, uint64(n.Type))
+	if n.Scope != 0 {
+		e.uvarint(2, uint64(n.Scope))
+	}
+	if n.Payload != nil {
+		e.bytes(3, n.Payload)
+	}
+	return e.buf
+}
+
+// UnPack unpacks the message, the scope defaults to GLOBAL.
+func (n *Notice) UnPack(payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return err
+	}
+	n.Scope = NoticeScopeGlobal
+	for _, f := range fields {
+		switch f.num {
+		case 1:
+			n.Type = uint32(f.varint)
+		case 2:
+			n.Scope = uint32(f.varint)
+		case 3:
+			n.Payload = f.data
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"testing"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWire(t *testing.T) {
+	e := &encoder{}
+	e.uvarint(1, 300)
+	e.svarint(2, -3)
+	e.string(3, "xelabs")
+	e.double(4, 1.5)
+	e.float(5, -2.5)
+	e.bool(6, true)
+	// field 1 varint 300.
+	assert.Equal(t, []byte{0x08, 0xac, 0x02}, e.buf[:3])
+
+	fields, err := decodeFields(e.buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(fields))
+	assert.Equal(t, uint64(300), fields[0].varint)
+	assert.Equal(t, int64(-3), fields[1].svarint())
+	assert.Equal(t, "xelabs", string(fields[2].data))
+	assert.Equal(t, 1.5, fields[3].double())
+	assert.Equal(t, float32(-2.5), fields[4].float())
+	assert.Equal(t, uint64(1), fields[5].varint)
+
+	bads := [][]byte{
+		{0x08},
+		{0x1a, 0x05, 'a'},
+		{0x21, 0x01},
+		{0x2d, 0x01},
+		{0x0b},
+		{0x80},
+	}
+	for _, bad := range bads {
+		_, err := decodeFields(bad)
+		assert.NotNilf(t, err, "%v", bad)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	// Ok.
+	{
+		want := &Ok{Msg: "bye!"}
+		got := &Ok{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+	}
+
+	// Error.
+	{
+		want := NewError(sqldb.NewSQLError1(sqldb.ER_ACCESS_DENIED_ERROR, "28000", "Access denied for user %s", "mock"), SeverityFatal)
+		got := &Error{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+		assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), got.SQLError().Num)
+		assert.Equal(t, "28000", got.SQLError().State)
+		assert.Equal(t, uint32(sqldb.ER_UNKNOWN_ERROR), NewError(assert.AnError, SeverityError).Code)
+	}
+
+	// Capabilities.
+	{
+		want := &Capabilities{}
+		want.Add("tls", NewScalarAny(&Scalar{Type: V_BOOL, Bool: true}))
+		want.Add("authentication.mechanisms", &Any{Type: ANY_ARRAY, Array: []*Any{NewStringAny("MYSQL41"), NewStringAny("PLAIN")}})
+		got := &Capabilities{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+		assert.True(t, got.Get("tls").Scalar.Bool)
+		assert.Nil(t, got.Get("compression"))
+	}
+
+	// StmtExecute.
+	{
+		want := &StmtExecute{
+			Namespace: "sql",
+			Stmt:      "SELECT ?, ?, ?, ?",
+			Args: []*Any{
+				NewScalarAny(&Scalar{Type: V_SINT, SInt: -42}),
+				NewScalarAny(&Scalar{Type: V_UINT, UInt: 42}),
+				NewScalarAny(&Scalar{Type: V_OCTETS, Octets: []byte(`{"a":1}`), ContentType: ContentTypeJSON}),
+				NewScalarAny(&Scalar{Type: V_DOUBLE, Double: 0.5}),
+				NewScalarAny(&Scalar{Type: V_NULL}),
+			},
+			CompactMetadata: true,
+		}
+		got := &StmtExecute{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+
+		// The namespace defaults to sql.
+		got = &StmtExecute{}
+		assert.Nil(t, got.UnPack((&StmtExecute{Stmt: "SELECT 1"}).Pack()))
+		assert.Equal(t, "sql", got.Namespace)
+
+		// Bad scalar type.
+		bad := &StmtExecute{Stmt: "SELECT ?", Args: []*Any{NewScalarAny(&Scalar{Type: 99})}}
+		assert.NotNil(t, got.UnPack(bad.Pack()))
+	}
+
+	// ColumnMetaData.
+	{
+		want := &ColumnMetaData{
+			Type:             FIELD_TYPE_DATETIME,
+			Name:             "ts",
+			OriginalName:     "ts",
+			Table:            "t1",
+			OriginalTable:    "t1",
+			Schema:           "db1",
+			Catalog:          "def",
+			FractionalDigits: 3,
+			Length:           23,
+			Flags:            FlagNotNull,
+			ContentType:      ContentTypeDatetime,
+		}
+		got := &ColumnMetaData{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+	}
+
+	// Notice.
+	{
+		want := NewSessionStateChanged(StateRowsAffected, &Scalar{Type: V_UINT, UInt: 3})
+		got := &Notice{}
+		assert.Nil(t, got.UnPack(want.Pack()))
+		assert.Equal(t, want, got)
+		param, value, err := got.SessionStateChanged()
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(StateRowsAffected), param)
+		assert.Equal(t, uint64(3), value.UInt)
+
+		_, _, err = (&Notice{Type: NoticeWarning}).SessionStateChanged()
+		assert.NotNil(t, err)
+	}
+
+	// Crud.
+	for _, typ := range []byte{CRUD_FIND, CRUD_INSERT, CRUD_UPDATE, CRUD_DELETE} {
+		want, err := NewCrud(typ, "db1", "docs", DataModelDocument)
+		assert.Nil(t, err)
+		got, err := UnPackCrud(typ, want.Payload)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := NewCrud(SQL_STMT_EXECUTE, "db1", "docs", DataModelDocument)
+	assert.NotNil(t, err)
+	_, err = UnPackCrud(CRUD_FIND, nil)
+	assert.NotNil(t, err)
+}
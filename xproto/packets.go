@@ -0,0 +1,91 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package xproto implements the MySQL X Protocol (mysqlx) framing, the core Mysqlx.Sql and Mysqlx.Crud messages,
+// the client and the listener, so the document-store clients can be served on port 33060 too.
+package xproto
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// DefaultPort is the X Protocol port of the MySQL.
+	DefaultPort = 33060
+
+	// MaxFrameSize is the max message size, same as the default mysqlx_max_allowed_packet.
+	MaxFrameSize = 64 * 1024 * 1024
+)
+
+// Packets is the X Protocol framing.
+// Each message is a 4-bytes little-endian size, the 1-byte type and the protobuf payload,
+// the size includes the type.
+type Packets struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	buf    []byte
+}
+
+// NewPackets creates the packets on the conn.
+func NewPackets(conn net.Conn) *Packets {
+	return &Packets{
+		conn:   conn,
+		reader: bufio.NewReaderSize(conn, 16*1024),
+	}
+}
+
+// Next reads the next message.
+func (p *Packets) Next() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(p.reader, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.LittleEndian.Uint32(header[:4])
+	if size == 0 || size > MaxFrameSize {
+		return 0, nil, fmt.Errorf("xproto.invalid.frame.size[%d]", size)
+	}
+	payload := make([]byte, size-1)
+	if _, err := io.ReadFull(p.reader, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// Append appends the message to the buffer, it's sent by Flush.
+func (p *Packets) Append(typ byte, payload []byte) error {
+	if len(payload)+1 > MaxFrameSize {
+		return fmt.Errorf("xproto.frame.too.large[%d]", len(payload))
+	}
+	p.buf = binary.LittleEndian.AppendUint32(p.buf, uint32(len(payload)+1))
+	p.buf = append(p.buf, typ)
+	p.buf = append(p.buf, payload...)
+	return nil
+}
+
+// Flush writes the buffered messages.
+func (p *Packets) Flush() error {
+	if len(p.buf) == 0 {
+		return nil
+	}
+	_, err := p.conn.Write(p.buf)
+	p.buf = p.buf[:0]
+	return err
+}
+
+// Write writes the message and the buffered ones.
+func (p *Packets) Write(typ byte, payload []byte) error {
+	if err := p.Append(typ, payload); err != nil {
+		return err
+	}
+	return p.Flush()
+}
@@ -0,0 +1,308 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"runtime/debug"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Handler is the X Protocol session handler.
+type Handler interface {
+	// NewSession is called when a session is coming.
+	NewSession(session *Session)
+
+	// SessionClosed is called when a session exit.
+	SessionClosed(session *Session)
+
+	// Check the MYSQL41 authentication, the session carries the user, schema, salt and scramble.
+	AuthCheck(session *Session) error
+
+	// Handle the Mysqlx.Sql.StmtExecute of the 'sql' namespace.
+	StmtExecute(session *Session, stmt *StmtExecute, callback func(*sqltypes.Result) error) error
+}
+
+// CrudHandler is the optional Handler to serve the Mysqlx.Crud messages,
+// the messages are answered as unexpected if the handler doesn't implement it.
+type CrudHandler interface {
+	// Handle the Find, Insert, Update and Delete.
+	Crud(session *Session, crud *Crud, callback func(*sqltypes.Result) error) error
+}
+
+// Listener is the X Protocol server.
+type Listener struct {
+	// Logger.
+	log *xlog.Log
+
+	address string
+
+	// Query handler.
+	handler Handler
+
+	// This is the main listener socket.
+	listener net.Listener
+
+	// Incrementing ID for connection id.
+	connectionID uint32
+}
+
+// NewListener creates a new Listener, the address is like ':33060'.
+func NewListener(log *xlog.Log, address string, handler Handler) (*Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Listener{
+		log:          log,
+		address:      address,
+		handler:      handler,
+		listener:     listener,
+		connectionID: 1,
+	}, nil
+}
+
+// Accept runs an accept loop until the listener is closed.
+func (l *Listener) Accept() {
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			// Close() was probably called.
+			return
+		}
+		ID := l.connectionID
+		l.connectionID++
+		go l.handle(conn, ID)
+	}
+}
+
+// handle is called in a go routine for each client connection.
+func (l *Listener) handle(conn net.Conn, ID uint32) {
+	log := l.log
+
+	// Catch panics, and close the connection in any case.
+	defer func() {
+		conn.Close()
+		if x := recover(); x != nil {
+			log.Error("xproto.server.handle.panic:\n%v\n%s", x, debug.Stack())
+		}
+	}()
+	session := newSession(log, ID, conn)
+
+	// Session register.
+	l.handler.NewSession(session)
+	defer l.handler.SessionClosed(session)
+
+	authenticated := false
+	for {
+		typ, payload, err := session.packets.Next()
+		if err != nil {
+			return
+		}
+
+		switch typ {
+		case CON_CAPABILITIES_GET:
+			err = session.packets.Write(CONN_CAPABILITIES, l.capabilities().Pack())
+		case CON_CAPABILITIES_SET:
+			err = l.handleCapabilitiesSet(session, payload)
+		case CON_CLOSE:
+			session.writeOk()
+			return
+		case SESS_AUTHENTICATE_START:
+			var ok bool
+			if ok, err = l.handleAuthenticate(session, payload); err == nil && !ok {
+				// The failed authentication is fatal.
+				return
+			}
+			authenticated = ok
+		case SESS_CLOSE:
+			// The connection can be authenticated again.
+			authenticated = false
+			err = session.writeOk()
+		case SESS_RESET, EXPECT_OPEN, EXPECT_CLOSE:
+			if !authenticated {
+				err = l.writeUnexpected(session, typ)
+				break
+			}
+			err = session.writeOk()
+		case SQL_STMT_EXECUTE:
+			if !authenticated {
+				err = l.writeUnexpected(session, typ)
+				break
+			}
+			err = l.handleStmtExecute(session, payload)
+		case CRUD_FIND, CRUD_INSERT, CRUD_UPDATE, CRUD_DELETE:
+			if !authenticated {
+				err = l.writeUnexpected(session, typ)
+				break
+			}
+			err = l.handleCrud(session, typ, payload)
+		default:
+			err = l.writeUnexpected(session, typ)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// capabilities returns the capabilities reported by the CapabilitiesGet.
+func (l *Listener) capabilities() *Capabilities {
+	caps := &Capabilities{}
+	caps.Add("authentication.mechanisms", &Any{Type: ANY_ARRAY, Array: []*Any{NewStringAny("MYSQL41")}})
+	caps.Add("doc.formats", NewStringAny("text"))
+	caps.Add("node_type", NewStringAny("mysql"))
+	return caps
+}
+
+// handleCapabilitiesSet accepts the capabilities but the TLS, the error returned is the write error.
+func (l *Listener) handleCapabilitiesSet(session *Session, payload []byte) error {
+	fields, err := decodeFields(payload)
+	if err != nil {
+		return session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityError)
+	}
+	caps := &Capabilities{}
+	for _, f := range fields {
+		if f.num == 1 {
+			if err := caps.UnPack(f.data); err != nil {
+				return session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityError)
+			}
+		}
+	}
+	if caps.Get("tls") != nil {
+		return session.writeError(sqldb.NewSQLError1(ER_X_CAPABILITIES_PREPARE_FAILED, "HY000", "Capability prepare failed for 'tls'"), SeverityError)
+	}
+	return session.writeOk()
+}
+
+// handleAuthenticate runs the MYSQL41 challenge-response, returns false if the authentication failed.
+// The response is the 'schema\0user\0*HEX(scramble)', the hash is empty if the password is.
+func (l *Listener) handleAuthenticate(session *Session, payload []byte) (bool, error) {
+	start := &AuthenticateStart{}
+	if err := start.UnPack(payload); err != nil {
+		return false, session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityFatal)
+	}
+	if start.MechName != "MYSQL41" {
+		return false, session.writeError(sqldb.NewSQLError1(sqldb.ER_NOT_SUPPORTED_AUTH_MODE, "HY000", "Invalid authentication method %s", start.MechName), SeverityFatal)
+	}
+	if err := session.packets.Write(SESS_AUTHENTICATE_CONTINUE_SERVER, (&AuthData{Data: session.Salt()}).Pack()); err != nil {
+		return false, err
+	}
+
+	typ, payload, err := session.packets.Next()
+	if err != nil {
+		return false, err
+	}
+	if typ != SESS_AUTHENTICATE_CONTINUE {
+		return false, l.writeUnexpected(session, typ)
+	}
+	resp := &AuthData{}
+	if err := resp.UnPack(payload); err != nil {
+		return false, session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityFatal)
+	}
+	parts := bytes.SplitN(resp.Data, []byte{0}, 3)
+	if len(parts) != 3 {
+		return false, session.writeError(sqldb.NewSQLError1(sqldb.ER_ACCESS_DENIED_ERROR, "HY000", "Invalid user or password"), SeverityFatal)
+	}
+	var scramble []byte
+	if hash := parts[2]; len(hash) > 0 {
+		if hash[0] != '*' {
+			return false, session.writeError(sqldb.NewSQLError1(sqldb.ER_ACCESS_DENIED_ERROR, "HY000", "Invalid user or password"), SeverityFatal)
+		}
+		if scramble, err = hex.DecodeString(string(hash[1:])); err != nil {
+			return false, session.writeError(sqldb.NewSQLError1(sqldb.ER_ACCESS_DENIED_ERROR, "HY000", "Invalid user or password"), SeverityFatal)
+		}
+	}
+	session.mu.Lock()
+	session.schema = string(parts[0])
+	session.user = string(parts[1])
+	session.scramble = scramble
+	session.mu.Unlock()
+
+	if err := l.handler.AuthCheck(session); err != nil {
+		l.log.Warning("xproto.server.user[%+v].auth.check.failed", session.User())
+		return false, session.writeError(err, SeverityFatal)
+	}
+
+	notice := NewSessionStateChanged(StateClientIDAssigned, &Scalar{Type: V_UINT, UInt: uint64(session.ID())})
+	if err := session.packets.Append(NOTICE, notice.Pack()); err != nil {
+		return false, err
+	}
+	return true, session.packets.Write(SESS_AUTHENTICATE_OK, (&AuthData{}).Pack())
+}
+
+// handleStmtExecute handles the StmtExecute, the error returned is the write error.
+func (l *Listener) handleStmtExecute(session *Session, payload []byte) error {
+	stmt := &StmtExecute{}
+	if err := stmt.UnPack(payload); err != nil {
+		return session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityError)
+	}
+
+	switch stmt.Namespace {
+	case "sql":
+	case "mysqlx", "xplugin":
+		// The ping is the only admin command served.
+		if stmt.Stmt != "ping" {
+			return session.writeError(sqldb.NewSQLError1(ER_X_INVALID_ADMIN_COMMAND, "HY000", "Unknown mysqlx command %s", stmt.Stmt), SeverityError)
+		}
+		return session.packets.Write(SQL_STMT_EXECUTE_OK, nil)
+	default:
+		return session.writeError(sqldb.NewSQLError1(ER_X_INVALID_NAMESPACE, "HY000", "Unknown namespace %s", stmt.Namespace), SeverityError)
+	}
+
+	w := &resultWriter{session: session}
+	if err := l.handler.StmtExecute(session, stmt, w.write); err != nil {
+		l.log.Error("xproto.server.handle.stmt.from.session[%v].error:%+v.stmt[%s]", session.ID(), err, stmt.Stmt)
+		return session.writeError(err, SeverityError)
+	}
+	return w.finish()
+}
+
+// handleCrud handles the Crud messages, the error returned is the write error.
+func (l *Listener) handleCrud(session *Session, typ byte, payload []byte) error {
+	ch, ok := l.handler.(CrudHandler)
+	if !ok {
+		return l.writeUnexpected(session, typ)
+	}
+
+	crud, err := UnPackCrud(typ, payload)
+	if err != nil {
+		return session.writeError(sqldb.NewSQLError1(ER_X_BAD_MESSAGE, "HY000", "%v", err), SeverityError)
+	}
+	w := &resultWriter{session: session}
+	if err := ch.Crud(session, crud, w.write); err != nil {
+		l.log.Error("xproto.server.handle.crud.from.session[%v].error:%+v", session.ID(), err)
+		return session.writeError(err, SeverityError)
+	}
+	return w.finish()
+}
+
+func (l *Listener) writeUnexpected(session *Session, typ byte) error {
+	l.log.Error("xproto.session[%v].message[%d].unexpected", session.ID(), typ)
+	sqlErr := sqldb.NewSQLError1(ER_UNKNOWN_COM_ERROR, "HY000", "Unexpected message received")
+	return session.writeError(sqlErr, SeverityError)
+}
+
+// Addr returns the listen address.
+func (l *Listener) Addr() string {
+	return l.address
+}
+
+// Close close the listener and all connections.
+func (l *Listener) Close() {
+	l.listener.Close()
+}
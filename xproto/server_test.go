@@ -0,0 +1,280 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+type testHandler struct {
+	mu       sync.Mutex
+	sessions map[uint32]*Session
+	results  map[string][]*sqltypes.Result
+	cruds    []*Crud
+}
+
+func newTestHandler() *testHandler {
+	return &testHandler{
+		sessions: make(map[uint32]*Session),
+		results:  make(map[string][]*sqltypes.Result),
+	}
+}
+
+func (h *testHandler) NewSession(session *Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sessions[session.ID()] = session
+}
+
+func (h *testHandler) SessionClosed(session *Session) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.sessions, session.ID())
+}
+
+func (h *testHandler) AuthCheck(session *Session) error {
+	if session.User() != "mock" || !session.CheckPassword("pwd") {
+		return sqldb.NewSQLError1(sqldb.ER_ACCESS_DENIED_ERROR, "HY000", "Invalid user or password")
+	}
+	return nil
+}
+
+func (h *testHandler) StmtExecute(session *Session, stmt *StmtExecute, callback func(*sqltypes.Result) error) error {
+	h.mu.Lock()
+	results, ok := h.results[strings.ToLower(stmt.Stmt)]
+	h.mu.Unlock()
+	if !ok {
+		return sqldb.NewSQLError1(sqldb.ER_SYNTAX_ERROR, "42000", "unsupported query: %s", stmt.Stmt)
+	}
+	for _, qr := range results {
+		if err := callback(qr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *testHandler) Crud(session *Session, crud *Crud, callback func(*sqltypes.Result) error) error {
+	h.mu.Lock()
+	h.cruds = append(h.cruds, crud)
+	h.mu.Unlock()
+	return callback(&sqltypes.Result{RowsAffected: 2})
+}
+
+func (h *testHandler) addQuery(query string, results ...*sqltypes.Result) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.results[strings.ToLower(query)] = results
+}
+
+func (h *testHandler) sessionNum() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.sessions)
+}
+
+// stmtHandler hides the Crud of the handler.
+type stmtHandler struct {
+	Handler
+}
+
+func mockXServer(t *testing.T, h Handler) *Listener {
+	var err error
+	var svr *Listener
+
+	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
+	for i := 0; i < 5; i++ {
+		addr := fmt.Sprintf("127.0.0.1:%d", 20000+rand.Intn(10000))
+		if svr, err = NewListener(log, addr, h); err == nil {
+			break
+		}
+	}
+	assert.Nil(t, err)
+	go svr.Accept()
+	return svr
+}
+
+func TestServerStmtExecute(t *testing.T) {
+	th := newTestHandler()
+	svr := mockXServer(t, th)
+	defer svr.Close()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: sqltypes.Int32},
+			{Name: "name", Type: sqltypes.VarChar, Charset: 33},
+			{Name: "ts", Type: sqltypes.Datetime},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(sqltypes.Int32, []byte("1")), sqltypes.MakeTrusted(sqltypes.VarChar, []byte("a")), sqltypes.MakeTrusted(sqltypes.Datetime, []byte("2017-01-02 03:04:05"))},
+			{sqltypes.MakeTrusted(sqltypes.Int32, []byte("-2")), sqltypes.NULL, sqltypes.NULL},
+		},
+	}
+	th.addQuery("SELECT * FROM t1", result)
+	// The rows are streamed by two callbacks.
+	th.addQuery("SELECT * FROM t2", &sqltypes.Result{Fields: result.Fields, Rows: result.Rows[:1]}, &sqltypes.Result{Rows: result.Rows[1:]})
+	th.addQuery("INSERT INTO t1 VALUES(3)", &sqltypes.Result{RowsAffected: 1, InsertID: 3})
+
+	conn, err := NewConn(svr.Addr(), "mock", "pwd", "db1")
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Equal(t, 1, th.sessionNum())
+	assert.NotEqual(t, uint64(0), conn.ConnectionID())
+
+	for _, query := range []string{"SELECT * FROM t1", "SELECT * FROM t2"} {
+		got, err := conn.FetchAll(query)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(got.Fields))
+		assert.Equal(t, "id", got.Fields[0].Name)
+		assert.Equal(t, sqltypes.Int64, got.Fields[0].Type)
+		assert.Equal(t, sqltypes.VarChar, got.Fields[1].Type)
+		assert.Equal(t, 2, len(got.Rows))
+		assert.Equal(t, "1", got.Rows[0][0].String())
+		assert.Equal(t, "a", got.Rows[0][1].String())
+		assert.Equal(t, "2017-01-02 03:04:05", got.Rows[0][2].String())
+		assert.Equal(t, "-2", got.Rows[1][0].String())
+		assert.True(t, got.Rows[1][1].IsNull())
+	}
+
+	got, err := conn.FetchAll("INSERT INTO t1 VALUES(3)")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1), got.RowsAffected)
+	assert.Equal(t, uint64(3), got.InsertID)
+	assert.Equal(t, 0, len(got.Fields))
+
+	// The error is not fatal.
+	err = conn.Exec("SELECT * FROM t3")
+	assert.NotNil(t, err)
+	assert.Equal(t, uint16(sqldb.ER_SYNTAX_ERROR), err.(*sqldb.SQLError).Num)
+	assert.Equal(t, "42000", err.(*sqldb.SQLError).State)
+	assert.False(t, conn.Closed())
+
+	// Admin commands.
+	assert.Nil(t, conn.Ping())
+	_, err = conn.StmtExecute(&StmtExecute{Namespace: "mysqlx", Stmt: "list_objects"})
+	assert.Equal(t, uint16(ER_X_INVALID_ADMIN_COMMAND), err.(*sqldb.SQLError).Num)
+	_, err = conn.StmtExecute(&StmtExecute{Namespace: "js", Stmt: "1"})
+	assert.Equal(t, uint16(ER_X_INVALID_NAMESPACE), err.(*sqldb.SQLError).Num)
+
+	assert.Nil(t, conn.Close())
+	assert.True(t, conn.Closed())
+	for i := 0; i < 100 && th.sessionNum() > 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 0, th.sessionNum())
+}
+
+func TestServerAuth(t *testing.T) {
+	th := newTestHandler()
+	svr := mockXServer(t, th)
+	defer svr.Close()
+
+	_, err := NewConn(svr.Addr(), "mock", "bad", "")
+	assert.NotNil(t, err)
+	assert.Equal(t, uint16(sqldb.ER_ACCESS_DENIED_ERROR), err.(*sqldb.SQLError).Num)
+
+	_, err = NewConn(svr.Addr(), "mock", "", "")
+	assert.NotNil(t, err)
+
+	conn, err := NewConn(svr.Addr(), "mock", "pwd", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+	assert.Nil(t, conn.packets.Write(SESS_CLOSE, nil))
+	_, err = conn.expect(OK)
+	assert.Nil(t, err)
+	// The session is closed.
+	err = conn.Exec("SELECT 1")
+	assert.Equal(t, uint16(ER_UNKNOWN_COM_ERROR), err.(*sqldb.SQLError).Num)
+
+	// Unsupported mechanism.
+	assert.Nil(t, conn.packets.Write(SESS_AUTHENTICATE_START, (&AuthenticateStart{MechName: "SHA256_MEMORY"}).Pack()))
+	_, err = conn.expect(SESS_AUTHENTICATE_CONTINUE_SERVER)
+	assert.Equal(t, uint16(sqldb.ER_NOT_SUPPORTED_AUTH_MODE), err.(*sqldb.SQLError).Num)
+	assert.True(t, conn.Closed())
+}
+
+func TestServerCapabilities(t *testing.T) {
+	th := newTestHandler()
+	svr := mockXServer(t, th)
+	defer svr.Close()
+
+	conn, err := NewConn(svr.Addr(), "mock", "pwd", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	assert.Nil(t, conn.packets.Write(CON_CAPABILITIES_GET, nil))
+	payload, err := conn.expect(CONN_CAPABILITIES)
+	assert.Nil(t, err)
+	caps := &Capabilities{}
+	assert.Nil(t, caps.UnPack(payload))
+	assert.Equal(t, "MYSQL41", caps.Get("authentication.mechanisms").Array[0].Scalar.String)
+
+	set := func(name string, value *Any) error {
+		caps := &Capabilities{}
+		caps.Add(name, value)
+		e := &encoder{}
+		e.bytes(1, caps.Pack())
+		assert.Nil(t, conn.packets.Write(CON_CAPABILITIES_SET, e.buf))
+		_, err := conn.expect(OK)
+		return err
+	}
+	assert.Nil(t, set("client.interactive", NewScalarAny(&Scalar{Type: V_BOOL, Bool: true})))
+	err = set("tls", NewScalarAny(&Scalar{Type: V_BOOL, Bool: true}))
+	assert.Equal(t, uint16(ER_X_CAPABILITIES_PREPARE_FAILED), err.(*sqldb.SQLError).Num)
+
+	for _, typ := range []byte{EXPECT_OPEN, EXPECT_CLOSE, SESS_RESET} {
+		assert.Nil(t, conn.packets.Write(typ, nil))
+		_, err = conn.expect(OK)
+		assert.Nil(t, err)
+	}
+	assert.Nil(t, conn.packets.Write(99, nil))
+	_, err = conn.expect(OK)
+	assert.Equal(t, uint16(ER_UNKNOWN_COM_ERROR), err.(*sqldb.SQLError).Num)
+}
+
+func TestServerCrud(t *testing.T) {
+	th := newTestHandler()
+	svr := mockXServer(t, th)
+	defer svr.Close()
+
+	conn, err := NewConn(svr.Addr(), "mock", "pwd", "")
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	crud, err := NewCrud(CRUD_DELETE, "db1", "docs", DataModelDocument)
+	assert.Nil(t, err)
+	got, err := conn.Crud(crud)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), got.RowsAffected)
+	assert.Equal(t, 1, len(th.cruds))
+	assert.Equal(t, "db1", th.cruds[0].Schema)
+	assert.Equal(t, "docs", th.cruds[0].Name)
+
+	// Without the CrudHandler.
+	svr2 := mockXServer(t, &stmtHandler{th})
+	defer svr2.Close()
+	conn2, err := NewConn(svr2.Addr(), "mock", "pwd", "")
+	assert.Nil(t, err)
+	defer conn2.Close()
+	_, err = conn2.Crud(crud)
+	assert.Equal(t, uint16(ER_UNKNOWN_COM_ERROR), err.(*sqldb.SQLError).Num)
+}
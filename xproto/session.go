@@ -0,0 +1,200 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"net"
+	"sync"
+
+	"github.com/XeLabs/go-mysqlstack/proto"
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+	"github.com/XeLabs/go-mysqlstack/xlog"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Session is the X Protocol session of the Listener.
+type Session struct {
+	id       uint32
+	mu       sync.RWMutex
+	log      *xlog.Log
+	conn     net.Conn
+	user     string
+	schema   string
+	salt     []byte
+	scramble []byte
+	packets  *Packets
+}
+
+func newSession(log *xlog.Log, ID uint32, conn net.Conn) *Session {
+	salt := make([]byte, 20)
+	if _, err := rand.Read(salt); err != nil {
+		copy(salt, proto.DefaultSalt)
+	}
+	// The salt is sent as the string, keep it without the NULs.
+	for i := range salt {
+		salt[i] = salt[i]&0x7f | 0x01
+	}
+	return &Session{
+		id:      ID,
+		log:     log,
+		conn:    conn,
+		salt:    salt,
+		packets: NewPackets(conn),
+	}
+}
+
+func (s *Session) writeError(err error, severity uint32) error {
+	return s.packets.Write(ERROR, NewError(err, severity).Pack())
+}
+
+func (s *Session) writeOk() error {
+	return s.packets.Write(OK, (&Ok{}).Pack())
+}
+
+// resultWriter writes the results to the session, the callback may be called repeatedly to stream the rows.
+type resultWriter struct {
+	session      *Session
+	columns      []*ColumnMetaData
+	fields       []*querypb.Field
+	rowsAffected uint64
+	insertID     uint64
+}
+
+func (w *resultWriter) write(result *sqltypes.Result) error {
+	packets := w.session.packets
+	if w.columns == nil && len(result.Fields) > 0 {
+		w.fields = result.Fields
+		w.columns = make([]*ColumnMetaData, 0, len(result.Fields))
+		for _, f := range result.Fields {
+			c := ColumnFromField(f)
+			w.columns = append(w.columns, c)
+			if err := packets.Append(RESULTSET_COLUMN_META_DATA, c.Pack()); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, row := range result.Rows {
+		if len(row) != len(w.columns) {
+			return sqldb.NewSQLError(sqldb.ER_UNKNOWN_ERROR, "row.columns[%d].mismatch.fields[%d]", len(row), len(w.columns))
+		}
+		fields := make([][]byte, 0, len(row))
+		for i, v := range row {
+			data, err := encodeField(v, w.columns[i])
+			if err != nil {
+				return err
+			}
+			fields = append(fields, data)
+		}
+		if err := packets.Append(RESULTSET_ROW, packRow(fields)); err != nil {
+			return err
+		}
+	}
+	w.rowsAffected += result.RowsAffected
+	if result.InsertID != 0 {
+		w.insertID = result.InsertID
+	}
+	return packets.Flush()
+}
+
+// finish writes the FETCH_DONE, the state notices and the STMT_EXECUTE_OK.
+func (w *resultWriter) finish() error {
+	packets := w.session.packets
+	if w.columns != nil {
+		if err := packets.Append(RESULTSET_FETCH_DONE, nil); err != nil {
+			return err
+		}
+	}
+	notice := NewSessionStateChanged(StateRowsAffected, &Scalar{Type: V_UINT, UInt: w.rowsAffected})
+	if err := packets.Append(NOTICE, notice.Pack()); err != nil {
+		return err
+	}
+	if w.insertID != 0 {
+		notice = NewSessionStateChanged(StateGeneratedInsertID, &Scalar{Type: V_UINT, UInt: w.insertID})
+		if err := packets.Append(NOTICE, notice.Pack()); err != nil {
+			return err
+		}
+	}
+	if err := packets.Append(SQL_STMT_EXECUTE_OK, nil); err != nil {
+		return err
+	}
+	return packets.Flush()
+}
+
+// Close closes the connection of the session.
+func (s *Session) Close() {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+// ID returns the session id, it's sent as the CLIENT_ID_ASSIGNED.
+func (s *Session) ID() uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.id
+}
+
+// Addr returns the remote address.
+func (s *Session) Addr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.conn != nil {
+		return s.conn.RemoteAddr().String()
+	}
+	return "unknow"
+}
+
+// SetSchema sets the current schema.
+func (s *Session) SetSchema(schema string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schema = schema
+}
+
+// Schema returns the current schema.
+func (s *Session) Schema() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.schema
+}
+
+// User returns the user of the authentication.
+func (s *Session) User() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.user
+}
+
+// Salt returns the MYSQL41 challenge.
+func (s *Session) Salt() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.salt
+}
+
+// Scramble returns the MYSQL41 response, nil if the password is empty.
+func (s *Session) Scramble() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.scramble
+}
+
+// CheckPassword checks the scramble is the password's one.
+func (s *Session) CheckPassword(password string) bool {
+	return bytes.Equal(s.Scramble(), proto.NativePassword(password, s.Salt()))
+}
@@ -0,0 +1,527 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/XeLabs/go-mysqlstack/sqldb"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// ColumnFromField builds the column meta data from the classic field.
+func ColumnFromField(f *querypb.Field) *ColumnMetaData {
+	c := &ColumnMetaData{
+		Name:             f.Name,
+		OriginalName:     f.OrgName,
+		Table:            f.Table,
+		OriginalTable:    f.OrgTable,
+		Schema:           f.Database,
+		Catalog:          "def",
+		Collation:        uint64(f.Charset),
+		FractionalDigits: f.Decimals,
+		Length:           f.ColumnLength,
+	}
+	if f.Flags&uint32(querypb.MySqlFlag_NOT_NULL_FLAG) != 0 {
+		c.Flags |= FlagNotNull
+	}
+	if f.Flags&uint32(querypb.MySqlFlag_PRI_KEY_FLAG) != 0 {
+		c.Flags |= FlagPrimaryKey
+	}
+	if f.Flags&uint32(querypb.MySqlFlag_AUTO_INCREMENT_FLAG) != 0 {
+		c.Flags |= FlagAutoIncrement
+	}
+
+	switch t := f.Type; {
+	case sqltypes.IsSigned(t), t == sqltypes.Year:
+		c.Type = FIELD_TYPE_SINT
+	case sqltypes.IsUnsigned(t):
+		c.Type = FIELD_TYPE_UINT
+	case t == sqltypes.Float32:
+		c.Type = FIELD_TYPE_FLOAT
+	case t == sqltypes.Float64:
+		c.Type = FIELD_TYPE_DOUBLE
+	case t == sqltypes.Decimal:
+		c.Type = FIELD_TYPE_DECIMAL
+	case t == sqltypes.Date:
+		c.Type, c.ContentType = FIELD_TYPE_DATETIME, ContentTypeDate
+	case t == sqltypes.Datetime:
+		c.Type, c.ContentType = FIELD_TYPE_DATETIME, ContentTypeDatetime
+	case t == sqltypes.Timestamp:
+		c.Type, c.ContentType = FIELD_TYPE_DATETIME, ContentTypeDatetime
+		c.Flags |= FlagDatetimeTimestamp
+	case t == sqltypes.Time:
+		c.Type = FIELD_TYPE_TIME
+	case t == sqltypes.Bit:
+		c.Type = FIELD_TYPE_BIT
+	case t == sqltypes.Enum:
+		c.Type = FIELD_TYPE_ENUM
+	case t == sqltypes.Set:
+		c.Type = FIELD_TYPE_SET
+	case t == sqltypes.TypeJSON:
+		c.Type, c.ContentType = FIELD_TYPE_BYTES, ContentTypeJSON
+	case t == sqltypes.Geometry:
+		c.Type, c.ContentType = FIELD_TYPE_BYTES, ContentTypeGeometry
+	default:
+		c.Type = FIELD_TYPE_BYTES
+		if t == sqltypes.Char || t == sqltypes.Binary {
+			c.Flags |= FlagBytesRightpad
+		}
+	}
+	return c
+}
+
+// Field builds the classic field from the column meta data.
+// The X Protocol doesn't carry the integer widths, the integers are the 64-bit ones
+// and the BYTES are the VARBINARY if the collation is binary, otherwise the VARCHAR.
+func (c *ColumnMetaData) Field() *querypb.Field {
+	f := &querypb.Field{
+		Name:         c.Name,
+		OrgName:      c.OriginalName,
+		Table:        c.Table,
+		OrgTable:     c.OriginalTable,
+		Database:     c.Schema,
+		Charset:      uint32(c.Collation),
+		Decimals:     c.FractionalDigits,
+		ColumnLength: c.Length,
+	}
+	if c.Flags&FlagNotNull != 0 {
+		f.Flags |= uint32(querypb.MySqlFlag_NOT_NULL_FLAG)
+	}
+	if c.Flags&FlagPrimaryKey != 0 {
+		f.Flags |= uint32(querypb.MySqlFlag_PRI_KEY_FLAG)
+	}
+	if c.Flags&FlagAutoIncrement != 0 {
+		f.Flags |= uint32(querypb.MySqlFlag_AUTO_INCREMENT_FLAG)
+	}
+
+	switch c.Type {
+	case FIELD_TYPE_SINT:
+		f.Type = sqltypes.Int64
+	case FIELD_TYPE_UINT:
+		f.Type = sqltypes.Uint64
+	case FIELD_TYPE_FLOAT:
+		f.Type = sqltypes.Float32
+	case FIELD_TYPE_DOUBLE:
+		f.Type = sqltypes.Float64
+	case FIELD_TYPE_DECIMAL:
+		f.Type = sqltypes.Decimal
+	case FIELD_TYPE_DATETIME:
+		switch {
+		case c.ContentType == ContentTypeDate:
+			f.Type = sqltypes.Date
+		case c.Flags&FlagDatetimeTimestamp != 0:
+			f.Type = sqltypes.Timestamp
+		default:
+			f.Type = sqltypes.Datetime
+		}
+	case FIELD_TYPE_TIME:
+		f.Type = sqltypes.Time
+	case FIELD_TYPE_BIT:
+		f.Type = sqltypes.Bit
+	case FIELD_TYPE_ENUM:
+		f.Type = sqltypes.Enum
+	case FIELD_TYPE_SET:
+		f.Type = sqltypes.Set
+	default:
+		switch {
+		case c.ContentType == ContentTypeJSON:
+			f.Type = sqltypes.TypeJSON
+		case c.ContentType == ContentTypeGeometry:
+			f.Type = sqltypes.Geometry
+		case c.Collation == sqldb.CharacterSetBinary:
+			f.Type = sqltypes.VarBinary
+		default:
+			f.Type = sqltypes.VarChar
+		}
+	}
+	return f
+}
+
+// encodeField encodes the value in the X Protocol row encoding of the column, the NULL is empty.
+// https://dev.mysql.com/doc/internals/en/x-protocol-messages-messages.html#resultset-row
+func encodeField(v sqltypes.Value, c *ColumnMetaData) ([]byte, error) {
+	if v.IsNull() {
+		return []byte{}, nil
+	}
+
+	raw := v.Raw()
+	switch c.Type {
+	case FIELD_TYPE_SINT:
+		n, err := strconv.ParseInt(string(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.sint.value[%s]", raw)
+		}
+		return binary.AppendUvarint(nil, uint64(n<<1)^uint64(n>>63)), nil
+	case FIELD_TYPE_UINT:
+		n, err := strconv.ParseUint(string(raw), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.uint.value[%s]", raw)
+		}
+		return binary.AppendUvarint(nil, n), nil
+	case FIELD_TYPE_DOUBLE:
+		f, err := strconv.ParseFloat(string(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.double.value[%s]", raw)
+		}
+		return binary.LittleEndian.AppendUint64(nil, math.Float64bits(f)), nil
+	case FIELD_TYPE_FLOAT:
+		f, err := strconv.ParseFloat(string(raw), 32)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.float.value[%s]", raw)
+		}
+		return binary.LittleEndian.AppendUint32(nil, math.Float32bits(float32(f))), nil
+	case FIELD_TYPE_DECIMAL:
+		return encodeDecimal(string(raw))
+	case FIELD_TYPE_DATETIME:
+		return encodeDatetime(string(raw))
+	case FIELD_TYPE_TIME:
+		return encodeTime(string(raw))
+	case FIELD_TYPE_BIT:
+		if len(raw) > 8 {
+			return nil, fmt.Errorf("xproto.invalid.bit.value[%v]", raw)
+		}
+		var n uint64
+		for _, b := range raw {
+			n = n<<8 | uint64(b)
+		}
+		return binary.AppendUvarint(nil, n), nil
+	case FIELD_TYPE_SET:
+		// The empty set is the single 0x01.
+		if len(raw) == 0 {
+			return []byte{0x01}, nil
+		}
+		var buf []byte
+		for _, elem := range strings.Split(string(raw), ",") {
+			buf = binary.AppendUvarint(buf, uint64(len(elem)))
+			buf = append(buf, elem...)
+		}
+		return buf, nil
+	}
+	// BYTES and ENUM are suffixed with 0x00 to tell the empty string from the NULL.
+	buf := make([]byte, len(raw)+1)
+	copy(buf, raw)
+	return buf, nil
+}
+
+// decodeField decodes the row field of the column to the value of the Field type.
+func decodeField(data []byte, c *ColumnMetaData, typ querypb.Type) (sqltypes.Value, error) {
+	if len(data) == 0 {
+		return sqltypes.NULL, nil
+	}
+
+	var raw []byte
+	switch c.Type {
+	case FIELD_TYPE_SINT:
+		n, size := binary.Uvarint(data)
+		if size != len(data) {
+			return sqltypes.NULL, fmt.Errorf("xproto.invalid.sint.field:%v", data)
+		}
+		raw = strconv.AppendInt(nil, int64(n>>1)^-int64(n&1), 10)
+	case FIELD_TYPE_UINT:
+		n, size := binary.Uvarint(data)
+		if size != len(data) {
+			return sqltypes.NULL, fmt.Errorf("xproto.invalid.uint.field:%v", data)
+		}
+		raw = strconv.AppendUint(nil, n, 10)
+	case FIELD_TYPE_DOUBLE:
+		if len(data) != 8 {
+			return sqltypes.NULL, fmt.Errorf("xproto.invalid.double.field:%v", data)
+		}
+		raw = strconv.AppendFloat(nil, math.Float64frombits(binary.LittleEndian.Uint64(data)), 'g', -1, 64)
+	case FIELD_TYPE_FLOAT:
+		if len(data) != 4 {
+			return sqltypes.NULL, fmt.Errorf("xproto.invalid.float.field:%v", data)
+		}
+		raw = strconv.AppendFloat(nil, float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), 'g', -1, 32)
+	case FIELD_TYPE_DECIMAL:
+		s, err := decodeDecimal(data)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		raw = []byte(s)
+	case FIELD_TYPE_DATETIME:
+		s, err := decodeDatetime(data, c)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		raw = []byte(s)
+	case FIELD_TYPE_TIME:
+		s, err := decodeTime(data, c)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		raw = []byte(s)
+	case FIELD_TYPE_BIT:
+		n, size := binary.Uvarint(data)
+		if size != len(data) {
+			return sqltypes.NULL, fmt.Errorf("xproto.invalid.bit.field:%v", data)
+		}
+		raw = binary.BigEndian.AppendUint64(nil, n)
+		if c.Length > 0 && c.Length < 64 {
+			raw = raw[8-(c.Length+7)/8:]
+		}
+	case FIELD_TYPE_SET:
+		if len(data) == 1 && data[0] == 0x01 {
+			raw = []byte{}
+			break
+		}
+		var elems []string
+		for len(data) > 0 {
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return sqltypes.NULL, fmt.Errorf("xproto.invalid.set.field:%v", data)
+			}
+			elems = append(elems, string(data[n:n+int(size)]))
+			data = data[n+int(size):]
+		}
+		raw = []byte(strings.Join(elems, ","))
+	default:
+		raw = data[:len(data)-1]
+	}
+	return sqltypes.MakeTrusted(typ, raw), nil
+}
+
+// splitFrac splits the '05.123' to 5 and 123000 microseconds.
+func splitFrac(s string) (uint64, uint64, error) {
+	sec, frac := s, ""
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		sec, frac = s[:i], s[i+1:]
+	}
+	n, err := strconv.ParseUint(sec, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(frac) > 6 {
+		return 0, 0, fmt.Errorf("xproto.invalid.fraction[%s]", s)
+	}
+	var usec uint64
+	if frac != "" {
+		if usec, err = strconv.ParseUint(frac+strings.Repeat("0", 6-len(frac)), 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	return n, usec, nil
+}
+
+// formatFrac formats the microseconds in the fractional digits of the column.
+func formatFrac(usec uint64, c *ColumnMetaData) string {
+	digits := int(c.FractionalDigits)
+	if digits <= 0 || digits > 6 {
+		return ""
+	}
+	return "." + fmt.Sprintf("%06d", usec)[:digits]
+}
+
+// encodeDatetime encodes the 'YYYY-MM-DD[ hh:mm:ss[.frac]]' as the varints.
+func encodeDatetime(s string) ([]byte, error) {
+	date, clock := s, ""
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		date, clock = s[:i], s[i+1:]
+	}
+
+	var buf []byte
+	parts := strings.Split(date, "-")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("xproto.invalid.datetime.value[%s]", s)
+	}
+	for _, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.datetime.value[%s]", s)
+		}
+		buf = binary.AppendUvarint(buf, n)
+	}
+	if clock == "" {
+		return buf, nil
+	}
+
+	parts = strings.Split(clock, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("xproto.invalid.datetime.value[%s]", s)
+	}
+	for _, p := range parts[:2] {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.datetime.value[%s]", s)
+		}
+		buf = binary.AppendUvarint(buf, n)
+	}
+	sec, usec, err := splitFrac(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("xproto.invalid.datetime.value[%s]", s)
+	}
+	buf = binary.AppendUvarint(buf, sec)
+	if usec > 0 {
+		buf = binary.AppendUvarint(buf, usec)
+	}
+	return buf, nil
+}
+
+// readVarints reads the varints of the field.
+func readVarints(data []byte) ([]uint64, error) {
+	var values []uint64
+	for len(data) > 0 {
+		n, size := binary.Uvarint(data)
+		if size <= 0 {
+			return nil, fmt.Errorf("xproto.invalid.varint:%v", data)
+		}
+		values = append(values, n)
+		data = data[size:]
+	}
+	return values, nil
+}
+
+func decodeDatetime(data []byte, c *ColumnMetaData) (string, error) {
+	values, err := readVarints(data)
+	if err != nil || len(values) < 3 || len(values) > 7 {
+		return "", fmt.Errorf("xproto.invalid.datetime.field:%v", data)
+	}
+	date := fmt.Sprintf("%04d-%02d-%02d", values[0], values[1], values[2])
+	if c.ContentType == ContentTypeDate {
+		return date, nil
+	}
+	clock := make([]uint64, 4)
+	copy(clock, values[3:])
+	return fmt.Sprintf("%s %02d:%02d:%02d%s", date, clock[0], clock[1], clock[2], formatFrac(clock[3], c)), nil
+}
+
+// encodeTime encodes the '[-]hhh:mm:ss[.frac]' as the sign byte and the varints.
+func encodeTime(s string) ([]byte, error) {
+	buf := []byte{0x00}
+	clock := s
+	if strings.HasPrefix(clock, "-") {
+		buf[0] = 0x01
+		clock = clock[1:]
+	}
+	parts := strings.Split(clock, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("xproto.invalid.time.value[%s]", s)
+	}
+	for _, p := range parts[:2] {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("xproto.invalid.time.value[%s]", s)
+		}
+		buf = binary.AppendUvarint(buf, n)
+	}
+	sec, usec, err := splitFrac(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("xproto.invalid.time.value[%s]", s)
+	}
+	buf = binary.AppendUvarint(buf, sec)
+	if usec > 0 {
+		buf = binary.AppendUvarint(buf, usec)
+	}
+	return buf, nil
+}
+
+func decodeTime(data []byte, c *ColumnMetaData) (string, error) {
+	if data[0] > 0x01 {
+		return "", fmt.Errorf("xproto.invalid.time.field:%v", data)
+	}
+	values, err := readVarints(data[1:])
+	if err != nil || len(values) > 4 {
+		return "", fmt.Errorf("xproto.invalid.time.field:%v", data)
+	}
+	sign := ""
+	if data[0] == 0x01 {
+		sign = "-"
+	}
+	clock := make([]uint64, 4)
+	copy(clock, values)
+	return fmt.Sprintf("%s%02d:%02d:%02d%s", sign, clock[0], clock[1], clock[2], formatFrac(clock[3], c)), nil
+}
+
+// encodeDecimal encodes the decimal as the scale byte and the BCD digits ended by the sign nibble,
+// 0xc for the positive and 0xd for the negative.
+func encodeDecimal(s string) ([]byte, error) {
+	digits := s
+	sign := byte(0x0c)
+	if strings.HasPrefix(digits, "-") {
+		sign = 0x0d
+		digits = digits[1:]
+	}
+	scale := 0
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		scale = len(digits) - i - 1
+		digits = digits[:i] + digits[i+1:]
+	}
+	if digits == "" || scale > 255 {
+		return nil, fmt.Errorf("xproto.invalid.decimal.value[%s]", s)
+	}
+
+	nibbles := make([]byte, 0, len(digits)+2)
+	for _, d := range []byte(digits) {
+		if d < '0' || d > '9' {
+			return nil, fmt.Errorf("xproto.invalid.decimal.value[%s]", s)
+		}
+		nibbles = append(nibbles, d-'0')
+	}
+	nibbles = append(nibbles, sign)
+	if len(nibbles)%2 != 0 {
+		nibbles = append(nibbles, 0x00)
+	}
+
+	buf := []byte{byte(scale)}
+	for i := 0; i < len(nibbles); i += 2 {
+		buf = append(buf, nibbles[i]<<4|nibbles[i+1])
+	}
+	return buf, nil
+}
+
+func decodeDecimal(data []byte) (string, error) {
+	scale := int(data[0])
+	var digits bytes.Buffer
+	negative := false
+	ended := false
+	for _, b := range data[1:] {
+		for _, nibble := range []byte{b >> 4, b & 0x0f} {
+			switch {
+			case nibble <= 9:
+				digits.WriteByte('0' + nibble)
+			case nibble == 0x0c:
+				ended = true
+			case nibble == 0x0d:
+				negative, ended = true, true
+			default:
+				return "", fmt.Errorf("xproto.invalid.decimal.field:%v", data)
+			}
+			if ended {
+				break
+			}
+		}
+		if ended {
+			break
+		}
+	}
+	if !ended || digits.Len() == 0 {
+		return "", fmt.Errorf("xproto.invalid.decimal.field:%v", data)
+	}
+
+	s := digits.String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if negative {
+		s = "-" + s
+	}
+	return s, nil
+}
@@ -0,0 +1,124 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	querypb "github.com/XeLabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/XeLabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestFieldValue(t *testing.T) {
+	tests := []struct {
+		field *querypb.Field
+		in    string
+		want  string
+		data  []byte
+	}{
+		{&querypb.Field{Type: sqltypes.Int32}, "-1", "-1", []byte{0x01}},
+		{&querypb.Field{Type: sqltypes.Year}, "2017", "2017", nil},
+		{&querypb.Field{Type: sqltypes.Uint64}, "18446744073709551615", "18446744073709551615", nil},
+		{&querypb.Field{Type: sqltypes.Float64}, "3.25", "3.25", nil},
+		{&querypb.Field{Type: sqltypes.Float32}, "-0.5", "-0.5", nil},
+		{&querypb.Field{Type: sqltypes.Decimal}, "-12.345", "-12.345", []byte{0x03, 0x12, 0x34, 0x5d}},
+		{&querypb.Field{Type: sqltypes.Decimal}, "1.5", "1.5", []byte{0x01, 0x15, 0xc0}},
+		{&querypb.Field{Type: sqltypes.Decimal}, "0.05", "0.05", nil},
+		{&querypb.Field{Type: sqltypes.Decimal}, "100", "100", nil},
+		{&querypb.Field{Type: sqltypes.Date}, "2017-01-02", "2017-01-02", []byte{0xe1, 0x0f, 0x01, 0x02}},
+		{&querypb.Field{Type: sqltypes.Datetime}, "2017-01-02 03:04:05", "2017-01-02 03:04:05", nil},
+		{&querypb.Field{Type: sqltypes.Datetime, Decimals: 3}, "2017-01-02 03:04:05.120", "2017-01-02 03:04:05.120", nil},
+		{&querypb.Field{Type: sqltypes.Timestamp, Decimals: 6}, "2017-01-02 03:04:05.000001", "2017-01-02 03:04:05.000001", nil},
+		{&querypb.Field{Type: sqltypes.Time}, "-838:59:59", "-838:59:59", nil},
+		{&querypb.Field{Type: sqltypes.Time, Decimals: 2}, "01:02:03.50", "01:02:03.50", nil},
+		{&querypb.Field{Type: sqltypes.Bit, ColumnLength: 16}, "\x01\x02", "\x01\x02", []byte{0x82, 0x02}},
+		{&querypb.Field{Type: sqltypes.Enum}, "a", "a", nil},
+		{&querypb.Field{Type: sqltypes.Set}, "a,bc", "a,bc", []byte{0x01, 'a', 0x02, 'b', 'c'}},
+		{&querypb.Field{Type: sqltypes.Set}, "", "", []byte{0x01}},
+		{&querypb.Field{Type: sqltypes.VarChar, Charset: 33}, "", "", []byte{0x00}},
+		{&querypb.Field{Type: sqltypes.VarBinary, Charset: 63}, "\x00\xff", "\x00\xff", nil},
+		{&querypb.Field{Type: sqltypes.TypeJSON}, `{"a": 1}`, `{"a": 1}`, nil},
+		{&querypb.Field{Type: sqltypes.Geometry}, "\x01", "\x01", nil},
+	}
+
+	for _, test := range tests {
+		col := ColumnFromField(test.field)
+		field := col.Field()
+		data, err := encodeField(sqltypes.MakeTrusted(test.field.Type, []byte(test.in)), col)
+		assert.Nil(t, err, test.in)
+		if test.data != nil {
+			assert.Equal(t, test.data, data, test.in)
+		}
+
+		got, err := decodeField(data, col, field.Type)
+		assert.Nil(t, err, test.in)
+		assert.Equal(t, test.want, string(got.Raw()), test.in)
+	}
+
+	// The integer widths are lost.
+	assert.Equal(t, sqltypes.Int64, ColumnFromField(&querypb.Field{Type: sqltypes.Int8}).Field().Type)
+	assert.Equal(t, sqltypes.Timestamp, ColumnFromField(&querypb.Field{Type: sqltypes.Timestamp}).Field().Type)
+	assert.Equal(t, sqltypes.VarChar, ColumnFromField(&querypb.Field{Type: sqltypes.Char, Charset: 33}).Field().Type)
+
+	// NULL.
+	col := ColumnFromField(&querypb.Field{Type: sqltypes.VarChar})
+	data, err := encodeField(sqltypes.NULL, col)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(data))
+	got, err := decodeField(data, col, sqltypes.VarChar)
+	assert.Nil(t, err)
+	assert.True(t, got.IsNull())
+}
+
+func TestFieldValueError(t *testing.T) {
+	encodes := []struct {
+		typ querypb.Type
+		in  string
+	}{
+		{sqltypes.Int64, "x"},
+		{sqltypes.Uint64, "-1"},
+		{sqltypes.Float64, "x"},
+		{sqltypes.Float32, "x"},
+		{sqltypes.Decimal, "1.x"},
+		{sqltypes.Decimal, "-"},
+		{sqltypes.Datetime, "2017-01"},
+		{sqltypes.Datetime, "2017-01-02 03:04"},
+		{sqltypes.Datetime, "2017-01-02 03:04:05.1234567"},
+		{sqltypes.Time, "03:04"},
+		{sqltypes.Time, "03:x:04"},
+		{sqltypes.Bit, "123456789"},
+	}
+	for _, test := range encodes {
+		_, err := encodeField(sqltypes.MakeTrusted(test.typ, []byte(test.in)), ColumnFromField(&querypb.Field{Type: test.typ}))
+		assert.NotNil(t, err, test.in)
+	}
+
+	decodes := []struct {
+		typ  querypb.Type
+		data []byte
+	}{
+		{sqltypes.Int64, []byte{0x80}},
+		{sqltypes.Uint64, []byte{0x01, 0x02}},
+		{sqltypes.Float64, []byte{0x01}},
+		{sqltypes.Float32, []byte{0x01}},
+		{sqltypes.Decimal, []byte{0x01, 0x12}},
+		{sqltypes.Decimal, []byte{0x01, 0xfc}},
+		{sqltypes.Datetime, []byte{0x01, 0x02}},
+		{sqltypes.Time, []byte{0x02}},
+		{sqltypes.Set, []byte{0x05, 'a'}},
+	}
+	for _, test := range decodes {
+		col := ColumnFromField(&querypb.Field{Type: test.typ})
+		_, err := decodeField(test.data, col, test.typ)
+		assert.NotNilf(t, err, "%v", test.data)
+	}
+}
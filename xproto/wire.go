@@ -0,0 +1,137 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package xproto
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// The protobuf wire types.
+// https://developers.google.com/protocol-buffers/docs/encoding
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder encodes the protobuf message fields.
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) tag(num int, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(num)<<3|uint64(wire))
+}
+
+func (e *encoder) uvarint(num int, v uint64) {
+	e.tag(num, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, v)
+}
+
+// svarint encodes the sint64 in the zigzag encoding.
+func (e *encoder) svarint(num int, v int64) {
+	e.uvarint(num, uint64(v<<1)^uint64(v>>63))
+}
+
+func (e *encoder) bool(num int, v bool) {
+	if v {
+		e.uvarint(num, 1)
+	} else {
+		e.uvarint(num, 0)
+	}
+}
+
+func (e *encoder) bytes(num int, v []byte) {
+	e.tag(num, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(v)))
+	e.buf = append(e.buf, v...)
+}
+
+func (e *encoder) string(num int, v string) {
+	e.bytes(num, []byte(v))
+}
+
+func (e *encoder) double(num int, v float64) {
+	e.tag(num, wireFixed64)
+	e.buf = binary.LittleEndian.AppendUint64(e.buf, math.Float64bits(v))
+}
+
+func (e *encoder) float(num int, v float32) {
+	e.tag(num, wireFixed32)
+	e.buf = binary.LittleEndian.AppendUint32(e.buf, math.Float32bits(v))
+}
+
+// field is a decoded protobuf field, the fixed values are in the varint.
+type field struct {
+	num    int
+	wire   int
+	varint uint64
+	data   []byte
+}
+
+// svarint returns the zigzag decoded sint64.
+func (f *field) svarint() int64 {
+	return int64(f.varint>>1) ^ -int64(f.varint&1)
+}
+
+func (f *field) double() float64 {
+	return math.Float64frombits(f.varint)
+}
+
+func (f *field) float() float32 {
+	return math.Float32frombits(uint32(f.varint))
+}
+
+// decodeFields decodes the fields of the message in the order of the wire.
+func decodeFields(data []byte) ([]field, error) {
+	var fields []field
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("xproto.invalid.field.key:%v", data)
+		}
+		data = data[n:]
+
+		f := field{num: int(key >> 3), wire: int(key & 7)}
+		switch f.wire {
+		case wireVarint:
+			if f.varint, n = binary.Uvarint(data); n <= 0 {
+				return nil, fmt.Errorf("xproto.invalid.field[%d].varint:%v", f.num, data)
+			}
+			data = data[n:]
+		case wireFixed64:
+			if len(data) < 8 {
+				return nil, fmt.Errorf("xproto.invalid.field[%d].fixed64:%v", f.num, data)
+			}
+			f.varint = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case wireFixed32:
+			if len(data) < 4 {
+				return nil, fmt.Errorf("xproto.invalid.field[%d].fixed32:%v", f.num, data)
+			}
+			f.varint = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case wireBytes:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, fmt.Errorf("xproto.invalid.field[%d].bytes:%v", f.num, data)
+			}
+			f.data = data[n : n+int(size)]
+			data = data[n+int(size):]
+		default:
+			return nil, fmt.Errorf("xproto.unsupported.field[%d].wire.type[%d]", f.num, f.wire)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}